@@ -22,38 +22,18 @@
 package fosite
 
 import (
-	"encoding/json"
-	"fmt"
+	"context"
 	"net/http"
+
+	"github.com/pkg/errors"
 )
 
 func (f *Fosite) WriteAccessError(rw http.ResponseWriter, req AccessRequester, err error) {
-	f.writeJsonError(rw, req, err)
-}
-
-func (f *Fosite) writeJsonError(rw http.ResponseWriter, requester AccessRequester, err error) {
-	rw.Header().Set("Content-Type", "application/json;charset=UTF-8")
-	rw.Header().Set("Cache-Control", "no-store")
-	rw.Header().Set("Pragma", "no-cache")
-
-	rfcerr := ErrorToRFC6749Error(err).WithLegacyFormat(f.UseLegacyErrorFormat).WithExposeDebug(f.SendDebugMessagesToClients)
-
-	if requester != nil {
-		rfcerr = rfcerr.WithLocalizer(f.MessageCatalog, getLangFromRequester(requester))
-	}
-
-	js, err := json.Marshal(rfcerr)
-	if err != nil {
-		if f.SendDebugMessagesToClients {
-			errorMessage := EscapeJSONString(err.Error())
-			http.Error(rw, fmt.Sprintf(`{"error":"server_error","error_description":"%s"}`, errorMessage), http.StatusInternalServerError)
-		} else {
-			http.Error(rw, `{"error":"server_error"}`, http.StatusInternalServerError)
+	if f.DPoPStrategy != nil && errors.Is(err, ErrUseDPoPNonce) {
+		if nonce, nonceErr := f.DPoPStrategy.NewNonce(context.Background()); nonceErr == nil && nonce != "" {
+			rw.Header().Set(DPoPNonceHeaderName, nonce)
 		}
-		return
 	}
 
-	rw.WriteHeader(rfcerr.CodeField)
-	// ignoring the error because the connection is broken when it happens
-	_, _ = rw.Write(js)
+	f.writeError(rw, TokenEndpoint, req, err)
 }
@@ -58,7 +58,23 @@ import (
 //   client MUST authenticate with the authorization server as described
 //   in Section 3.2.1.
 func (f *Fosite) NewAccessRequest(ctx context.Context, r *http.Request, session Session) (AccessRequester, error) {
+	ctx = f.withStoragePolicy(ctx)
+	ctx = ensureRequestID(ctx, r)
+	ctx, span := f.startSpan(ctx, "fosite.NewAccessRequest")
+	accessRequest, err := f.newAccessRequest(ctx, r, session)
+	err = attachRequestID(ctx, err)
+	f.reportRequestFailed(ctx, TokenEndpoint, err)
+	endSpan(span, accessRequest, err)
+	return accessRequest, err
+}
+
+func (f *Fosite) newAccessRequest(ctx context.Context, r *http.Request, session Session) (AccessRequester, error) {
 	accessRequest := NewAccessRequest(session)
+	accessRequest.PersistedParameters = f.SanitationWhiteList
+	if f.GrantStorage != nil {
+		// The grant_id is carried over from the authorize request, see validateAuthorizeGrantManagement.
+		accessRequest.PersistedParameters = append(append([]string{}, f.SanitationWhiteList...), "grant_id")
+	}
 	accessRequest.Request.Lang = i18n.GetLangFromRequest(f.MessageCatalog, r)
 
 	ctx = context.WithValue(ctx, RequestContextKey, r)
@@ -73,12 +89,27 @@ func (f *Fosite) NewAccessRequest(ctx context.Context, r *http.Request, session
 	}
 
 	accessRequest.Form = r.PostForm
+	if f.GrantStorage != nil {
+		// The grant_id of the token response is the one of the authorize request and can not be chosen by the client.
+		accessRequest.Form.Del("grant_id")
+	}
 	if session == nil {
 		return accessRequest, errors.New("Session must not be nil")
 	}
 
 	accessRequest.SetRequestedScopes(RemoveEmpty(strings.Split(r.PostForm.Get("scope"), " ")))
-	accessRequest.SetRequestedAudience(GetAudiences(r.PostForm))
+	resources, err := GetResources(r.PostForm)
+	if err != nil {
+		return accessRequest, err
+	}
+	accessRequest.SetRequestedAudience(append(GetAudiences(r.PostForm), resources...))
+
+	details, err := GetAuthorizationDetails(r.PostForm)
+	if err != nil {
+		return accessRequest, err
+	}
+	accessRequest.SetRequestedAuthorizationDetails(details)
+
 	accessRequest.GrantTypes = RemoveEmpty(strings.Split(r.PostForm.Get("grant_type"), " "))
 	if len(accessRequest.GrantTypes) < 1 {
 		return accessRequest, errorsx.WithStack(ErrInvalidRequest.WithHint("Request parameter 'grant_type' is missing"))
@@ -89,6 +120,10 @@ func (f *Fosite) NewAccessRequest(ctx context.Context, r *http.Request, session
 		accessRequest.Client = client
 	}
 
+	if err := f.beforeAccessRequest(ctx, accessRequest); err != nil {
+		return accessRequest, err
+	}
+
 	var found = false
 	for _, loader := range f.TokenEndpointHandlers {
 		// Is the loader responsible for handling the request?
@@ -105,7 +140,9 @@ func (f *Fosite) NewAccessRequest(ctx context.Context, r *http.Request, session
 		}
 
 		// All good.
-		if err := loader.HandleTokenEndpointRequest(ctx, accessRequest); err == nil {
+		if err := f.traceHandler(ctx, "fosite.TokenEndpointHandler.HandleTokenEndpointRequest", loader, func(ctx context.Context) error {
+			return loader.HandleTokenEndpointRequest(ctx, accessRequest)
+		}); err == nil {
 			found = true
 		} else if errors.Is(err, ErrUnknownRequest) {
 			// This is a duplicate because it should already have been handled by
@@ -118,7 +155,18 @@ func (f *Fosite) NewAccessRequest(ctx context.Context, r *http.Request, session
 	}
 
 	if !found {
-		return nil, errorsx.WithStack(ErrInvalidRequest)
+		// None of the handlers is responsible for the grant type, see https://tools.ietf.org/html/rfc6749#section-5.2
+		return nil, errorsx.WithStack(ErrUnsupportedGrantType.WithHintf("The authorization grant type '%s' is not supported by the authorization server.", strings.Join(accessRequest.GrantTypes, " ")))
+	}
+
+	if err := f.validateAuthorizationDetails(ctx, accessRequest.GetClient(), accessRequest.GetRequestedAuthorizationDetails()); err != nil {
+		return accessRequest, err
+	} else if err := f.handleDPoPAccessRequest(ctx, r, accessRequest); err != nil {
+		return accessRequest, err
+	} else if err := f.handleCertificateBoundAccessRequest(ctx, r, accessRequest); err != nil {
+		return accessRequest, err
+	} else if err := f.requireSenderConstrainedAccessRequest(accessRequest); err != nil {
+		return accessRequest, err
 	}
 	return accessRequest, nil
 }
@@ -74,7 +74,7 @@ func TestNewAccessRequest(t *testing.T) {
 				"grant_type": {"foo"},
 			},
 			mock:      func() {},
-			expectErr: ErrInvalidRequest,
+			expectErr: ErrUnsupportedGrantType,
 		},
 		{
 			header: http.Header{},
@@ -83,7 +83,7 @@ func TestNewAccessRequest(t *testing.T) {
 				"grant_type": {"foo"},
 				"client_id":  {""},
 			},
-			expectErr: ErrInvalidRequest,
+			expectErr: ErrUnsupportedGrantType,
 			mock:      func() {},
 		},
 		{
@@ -267,7 +267,7 @@ func TestNewAccessRequestWithoutClientAuth(t *testing.T) {
 				store.EXPECT().GetClient(gomock.Any(), gomock.Any()).Times(0)
 			},
 			method:    "POST",
-			expectErr: ErrInvalidRequest,
+			expectErr: ErrUnsupportedGrantType,
 			handlers:  TokenEndpointHandlers{},
 		},
 		// Handler can skip client auth and ignores missing client.
@@ -0,0 +1,108 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+)
+
+// AccessRequestMiddleware is an extension point for cross-cutting concerns of the token endpoint, e.g. fraud
+// scoring, quotas or claims enrichment, which would otherwise require TokenEndpointHandlers reimplementing the
+// grants. Errors should be RFC6749Errors, other errors are returned as ErrServerError.
+type AccessRequestMiddleware interface {
+	// BeforeAccessRequest is called by NewAccessRequest before the TokenEndpointHandlers validate the grant of the
+	// request. The client is nil if client authentication failed, it may be skipped by some grants. The original
+	// *http.Request is stored under RequestContextKey.
+	BeforeAccessRequest(ctx context.Context, requester AccessRequester) error
+
+	// BeforeAccessResponse is called by NewAccessResponse before the TokenEndpointHandlers issue the tokens, once
+	// the grant was validated and the session of the grant was restored, e.g. to enrich the claims of the session.
+	BeforeAccessResponse(ctx context.Context, requester AccessRequester) error
+
+	// AfterAccessResponse is called by NewAccessResponse once the tokens were issued, e.g. to add response
+	// parameters using responder.SetExtra. The tokens have already been persisted when an error is returned.
+	AfterAccessResponse(ctx context.Context, requester AccessRequester, responder AccessResponder) error
+}
+
+// AccessRequestMiddlewareFuncs is an adapter to allow the use of ordinary functions as AccessRequestMiddleware.
+// Nil functions are skipped.
+type AccessRequestMiddlewareFuncs struct {
+	BeforeRequest  func(ctx context.Context, requester AccessRequester) error
+	BeforeResponse func(ctx context.Context, requester AccessRequester) error
+	AfterResponse  func(ctx context.Context, requester AccessRequester, responder AccessResponder) error
+}
+
+func (f AccessRequestMiddlewareFuncs) BeforeAccessRequest(ctx context.Context, requester AccessRequester) error {
+	if f.BeforeRequest == nil {
+		return nil
+	}
+	return f.BeforeRequest(ctx, requester)
+}
+
+func (f AccessRequestMiddlewareFuncs) BeforeAccessResponse(ctx context.Context, requester AccessRequester) error {
+	if f.BeforeResponse == nil {
+		return nil
+	}
+	return f.BeforeResponse(ctx, requester)
+}
+
+func (f AccessRequestMiddlewareFuncs) AfterAccessResponse(ctx context.Context, requester AccessRequester, responder AccessResponder) error {
+	if f.AfterResponse == nil {
+		return nil
+	}
+	return f.AfterResponse(ctx, requester, responder)
+}
+
+// AccessRequestMiddlewares is a list of AccessRequestMiddleware which are called in order.
+type AccessRequestMiddlewares []AccessRequestMiddleware
+
+// Append adds an AccessRequestMiddleware to this list. Duplicate types are not ignored.
+func (a *AccessRequestMiddlewares) Append(m AccessRequestMiddleware) {
+	*a = append(*a, m)
+}
+
+func (f *Fosite) beforeAccessRequest(ctx context.Context, requester AccessRequester) error {
+	for _, m := range f.AccessRequestMiddlewares {
+		if err := m.BeforeAccessRequest(ctx, requester); err != nil {
+			return extensionError(err)
+		}
+	}
+	return nil
+}
+
+func (f *Fosite) beforeAccessResponse(ctx context.Context, requester AccessRequester) error {
+	for _, m := range f.AccessRequestMiddlewares {
+		if err := m.BeforeAccessResponse(ctx, requester); err != nil {
+			return extensionError(err)
+		}
+	}
+	return nil
+}
+
+func (f *Fosite) afterAccessResponse(ctx context.Context, requester AccessRequester, responder AccessResponder) error {
+	for _, m := range f.AccessRequestMiddlewares {
+		if err := m.AfterAccessResponse(ctx, requester, responder); err != nil {
+			return extensionError(err)
+		}
+	}
+	return nil
+}
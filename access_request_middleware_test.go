@@ -0,0 +1,115 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/storage"
+)
+
+func TestAccessRequestMiddlewares(t *testing.T) {
+	var calls []string
+	var rejectRequest, failResponse error
+	fraud := AccessRequestMiddlewareFuncs{
+		BeforeRequest: func(ctx context.Context, requester AccessRequester) error {
+			calls = append(calls, "before-request:"+requester.GetClient().GetID())
+			return rejectRequest
+		},
+		AfterResponse: func(ctx context.Context, requester AccessRequester, responder AccessResponder) error {
+			calls = append(calls, "after-response")
+			responder.SetExtra("fraud_score", 0.1)
+			return failResponse
+		},
+	}
+	enrich := AccessRequestMiddlewareFuncs{
+		BeforeResponse: func(ctx context.Context, requester AccessRequester) error {
+			calls = append(calls, "before-response")
+			requester.GetSession().(*DefaultSession).Extra = map[string]interface{}{"tenant": "acme"}
+			return nil
+		},
+	}
+
+	config := &compose.Config{AccessRequestMiddlewares: AccessRequestMiddlewares{fraud, enrich}}
+	f := compose.Compose(
+		config,
+		storage.NewExampleStore(),
+		compose.NewOAuth2HMACStrategy(config, []byte("some-secret-thats-random-some-secret-thats-random-"), nil),
+		nil,
+		compose.OAuth2ClientCredentialsGrantFactory,
+		compose.OAuth2TokenIntrospectionFactory,
+	)
+	ctx := context.Background()
+
+	token := func() (AccessResponder, error) {
+		r := httptest.NewRequest("POST", "/token", strings.NewReader(url.Values{"grant_type": {"client_credentials"}}.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.SetBasicAuth("my-client", "foobar")
+		ar, err := f.NewAccessRequest(ctx, r, new(DefaultSession))
+		if err != nil {
+			return nil, err
+		}
+		return f.NewAccessResponse(ctx, ar)
+	}
+
+	t.Run("case=should call the middlewares in order", func(t *testing.T) {
+		calls = nil
+		resp, err := token()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"before-request:my-client", "before-response", "after-response"}, calls)
+		assert.Equal(t, 0.1, resp.GetExtra("fraud_score"))
+
+		_, ar, err := f.IntrospectToken(ctx, resp.GetAccessToken(), AccessToken, new(DefaultSession))
+		require.NoError(t, err)
+		assert.Equal(t, "acme", ar.GetSession().(*DefaultSession).Extra["tenant"])
+	})
+
+	t.Run("case=should reject the request before the grant is validated", func(t *testing.T) {
+		calls = nil
+		rejectRequest = ErrAccessDenied.WithHint("The request looks fraudulent.")
+		defer func() { rejectRequest = nil }()
+
+		_, err := token()
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, ErrAccessDenied), "%+v", err)
+		assert.Equal(t, []string{"before-request:my-client"}, calls)
+	})
+
+	t.Run("case=should convert other errors to server errors", func(t *testing.T) {
+		failResponse = errors.New("scoring service unavailable")
+		defer func() { failResponse = nil }()
+
+		_, err := token()
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, ErrServerError), "%+v", err)
+	})
+}
@@ -23,6 +23,7 @@ package fosite
 
 import (
 	"context"
+	"strings"
 
 	"github.com/ory/x/errorsx"
 
@@ -30,6 +31,21 @@ import (
 )
 
 func (f *Fosite) NewAccessResponse(ctx context.Context, requester AccessRequester) (AccessResponder, error) {
+	ctx = f.withStoragePolicy(ctx)
+	ctx = ensureRequestID(ctx, nil)
+	ctx, span := f.startSpan(ctx, "fosite.NewAccessResponse")
+	response, err := f.newAccessResponse(ctx, requester)
+	err = attachRequestID(ctx, err)
+	if err != nil {
+		f.reportRequestFailed(ctx, TokenEndpoint, err)
+	} else if f.MetricsReporter != nil {
+		f.MetricsReporter.TokenIssued(ctx, strings.Join(requester.GetGrantTypes(), " "))
+	}
+	endSpan(span, requester, err)
+	return response, err
+}
+
+func (f *Fosite) newAccessResponse(ctx context.Context, requester AccessRequester) (AccessResponder, error) {
 	var err error
 	var tk TokenEndpointHandler
 
@@ -38,8 +54,20 @@ func (f *Fosite) NewAccessResponse(ctx context.Context, requester AccessRequeste
 	ctx = context.WithValue(ctx, AccessRequestContextKey, requester)
 	ctx = context.WithValue(ctx, AccessResponseContextKey, response)
 
+	if f.TokenQuotaPolicy != nil {
+		if err := f.TokenQuotaPolicy.EnforceTokenQuota(ctx, requester); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := f.beforeAccessResponse(ctx, requester); err != nil {
+		return nil, err
+	}
+
 	for _, tk = range f.TokenEndpointHandlers {
-		if err = tk.PopulateTokenEndpointResponse(ctx, requester, response); err == nil {
+		if err = f.traceHandler(ctx, "fosite.TokenEndpointHandler.PopulateTokenEndpointResponse", tk, func(ctx context.Context) error {
+			return tk.PopulateTokenEndpointResponse(ctx, requester, response)
+		}); err == nil {
 			// do nothing
 		} else if errors.Is(err, ErrUnknownRequest) {
 			// do nothing
@@ -48,9 +76,33 @@ func (f *Fosite) NewAccessResponse(ctx context.Context, requester AccessRequeste
 		}
 	}
 
+	// https://tools.ietf.org/html/rfc9396#section-7
+	if f.AuthorizationDetailsTypes != nil && len(requester.GetAuthorizationDetails()) > 0 {
+		response.SetExtra("authorization_details", requester.GetAuthorizationDetails())
+	}
+
+	// https://openid.net/specs/fapi-grant-management.html#section-5
+	if f.GrantStorage != nil && requester.GetRequestForm().Get("grant_id") != "" {
+		response.SetExtra("grant_id", requester.GetRequestForm().Get("grant_id"))
+	}
+
+	if f.DPoPStrategy != nil && GetConfirmation(requester.GetSession(), DPoPConfirmationMethod) != "" {
+		response.SetTokenType(DPoPTokenType)
+	}
+
 	if response.GetAccessToken() == "" || response.GetTokenType() == "" {
 		return nil, errorsx.WithStack(ErrServerError.WithHint("An internal server occurred while trying to complete the request.").WithDebug("Access token or token type not set by TokenEndpointHandlers.").WithLocalizer(f.MessageCatalog, getLangFromRequester(requester)))
 	}
 
+	if err := f.afterAccessResponse(ctx, requester, response); err != nil {
+		return nil, err
+	}
+
+	if f.EventListener != nil && requester.GetGrantTypes().ExactOne("refresh_token") {
+		f.EventListener.OnTokenRefreshed(ctx, requester, response)
+	} else if f.EventListener != nil {
+		f.EventListener.OnTokenIssued(ctx, requester, response)
+	}
+
 	return response, nil
 }
@@ -8,8 +8,14 @@ import (
 	"github.com/ory/x/errorsx"
 )
 
+// AudienceMatchingStrategy is a strategy for matching the requested audiences (needle) against the audiences a client
+// is allowed to request (haystack). It returns an error unless all requested audiences match.
 type AudienceMatchingStrategy func(haystack []string, needle []string) error
 
+// DefaultAudienceMatchingStrategy assumes that audiences are URLs and matches them by URL hierarchy: a requested
+// audience matches an allowed one with the same scheme and host if its path is the allowed path or below it, e.g.
+// "https://api.example.com/users" matches "https://api.example.com" and "https://api.example.com/", but
+// "https://api.example.com/usersettings" does not match "https://api.example.com/users".
 func DefaultAudienceMatchingStrategy(haystack []string, needle []string) error {
 	if len(needle) == 0 {
 		return nil
@@ -71,6 +77,85 @@ func ExactAudienceMatchingStrategy(haystack []string, needle []string) error {
 	return nil
 }
 
+// WildcardAudienceMatchingStrategy compares audiences as strings, where a "*" in an allowed audience matches any
+// sequence of characters except the URL delimiters "/", "?", "#", "@" and "\". For example
+// "https://*.example.com/api" matches "https://eu.example.com/api", and "https://api.example.com/*" matches
+// "https://api.example.com/users" but not "https://api.example.com/users/1".
+func WildcardAudienceMatchingStrategy(haystack []string, needle []string) error {
+	for _, n := range needle {
+		var found bool
+		for _, h := range haystack {
+			if matchAudienceWildcard(h, n) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return errorsx.WithStack(ErrInvalidRequest.WithHintf(`Requested audience "%s" has not been whitelisted by the OAuth 2.0 Client.`, n))
+		}
+	}
+
+	return nil
+}
+
+// audienceWildcardDelimiters are the characters a "*" in an allowed audience does not match.
+const audienceWildcardDelimiters = "/?#@\\"
+
+func matchAudienceWildcard(pattern, audience string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] != '*' {
+			if len(audience) == 0 || pattern[0] != audience[0] {
+				return false
+			}
+			pattern, audience = pattern[1:], audience[1:]
+			continue
+		}
+
+		// The wildcard consumes up to the next delimiter of the audience, so that it can not span the host, path,
+		// query or fragment, e.g. "https://evil.com?.example.com/api" does not match "https://*.example.com/api".
+		pattern = pattern[1:]
+		for i := 0; i <= len(audience); i++ {
+			if matchAudienceWildcard(pattern, audience[i:]) {
+				return true
+			}
+			if i < len(audience) && strings.IndexByte(audienceWildcardDelimiters, audience[i]) >= 0 {
+				return false
+			}
+		}
+		return false
+	}
+	return len(audience) == 0
+}
+
+// The names of the built-in audience matching strategies clients can select using AudienceMatchingStrategyClient.
+const (
+	AudienceMatchingStrategyURLHierarchy = "url_hierarchy"
+	AudienceMatchingStrategyExact        = "exact"
+	AudienceMatchingStrategyWildcard     = "wildcard"
+)
+
+// AudienceMatchingStrategies maps the names clients select audience matching strategies with to the strategies.
+// Custom strategies can be added before the provider starts serving requests.
+var AudienceMatchingStrategies = map[string]AudienceMatchingStrategy{
+	AudienceMatchingStrategyURLHierarchy: DefaultAudienceMatchingStrategy,
+	AudienceMatchingStrategyExact:        ExactAudienceMatchingStrategy,
+	AudienceMatchingStrategyWildcard:     WildcardAudienceMatchingStrategy,
+}
+
+// ClientAudienceMatchingStrategy returns the audience matching strategy selected by the client if it implements
+// AudienceMatchingStrategyClient, and fallback if the client did not select one or selected an unknown one.
+func ClientAudienceMatchingStrategy(client Client, fallback AudienceMatchingStrategy) AudienceMatchingStrategy {
+	ac, ok := client.(AudienceMatchingStrategyClient)
+	if !ok || ac.GetAudienceMatchingStrategy() == "" {
+		return fallback
+	}
+	if strategy, found := AudienceMatchingStrategies[ac.GetAudienceMatchingStrategy()]; found {
+		return strategy
+	}
+	return fallback
+}
+
 // GetAudiences allows audiences to be provided as repeated "audience" form parameter,
 // or as a space-delimited "audience" form parameter if it is not repeated.
 // RFC 8693 in section 2.1 specifies that multiple audience values should be multiple
@@ -88,13 +173,52 @@ func GetAudiences(form url.Values) []string {
 	}
 }
 
+// GetResources returns the resource indicators of the request as defined in https://tools.ietf.org/html/rfc8707. The
+// "resource" form parameter may be repeated, and each value must be an absolute URI without a fragment.
+func GetResources(form url.Values) ([]string, error) {
+	resources := RemoveEmpty(form["resource"])
+	for _, resource := range resources {
+		u, err := url.Parse(resource)
+		if err != nil {
+			return nil, errorsx.WithStack(ErrInvalidTarget.WithHintf("Unable to parse resource indicator '%s'.", resource).WithWrap(err).WithDebug(err.Error()))
+		} else if !u.IsAbs() || u.Fragment != "" {
+			return nil, errorsx.WithStack(ErrInvalidTarget.WithHintf("Resource indicator '%s' must be an absolute URI without a fragment.", resource))
+		}
+	}
+	return resources, nil
+}
+
+// NarrowAudience implements the audience restriction of access tokens at the token endpoint as defined in
+// https://tools.ietf.org/html/rfc8707#section-2.2. It returns the resources if any were requested and the granted
+// audience otherwise. Each resource must be part of the granted audience.
+func NarrowAudience(resources []string, granted Arguments) (Arguments, error) {
+	if len(resources) == 0 {
+		return granted, nil
+	}
+
+	for _, resource := range resources {
+		if !granted.Has(resource) {
+			return nil, errorsx.WithStack(ErrInvalidTarget.WithHintf("Resource indicator '%s' has not been granted.", resource))
+		}
+	}
+	return resources, nil
+}
+
 func (f *Fosite) validateAuthorizeAudience(r *http.Request, request *AuthorizeRequest) error {
 	audience := GetAudiences(request.Form)
+	audienceStrategy := ClientAudienceMatchingStrategy(request.Client, f.AudienceMatchingStrategy)
+
+	if err := audienceStrategy(request.Client.GetAudience(), audience); err != nil {
+		return err
+	}
 
-	if err := f.AudienceMatchingStrategy(request.Client.GetAudience(), audience); err != nil {
+	resources, err := GetResources(request.Form)
+	if err != nil {
 		return err
+	} else if err := audienceStrategy(request.Client.GetAudience(), resources); err != nil {
+		return errorsx.WithStack(ErrInvalidTarget.WithHint("The OAuth 2.0 Client is not allowed to request the given resource.").WithWrap(err).WithDebug(err.Error()))
 	}
 
-	request.SetRequestedAudience(Arguments(audience))
+	request.SetRequestedAudience(Arguments(append(audience, resources...)))
 	return nil
 }
@@ -2,6 +2,7 @@ package fosite
 
 import (
 	"fmt"
+	"net/url"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -252,3 +253,106 @@ func TestExactAudienceMatchingStrategy(t *testing.T) {
 		})
 	}
 }
+
+func TestWildcardAudienceMatchingStrategy(t *testing.T) {
+	for k, tc := range []struct {
+		h   []string
+		n   []string
+		err bool
+	}{
+		{h: []string{}, n: []string{}},
+		{h: []string{}, n: []string{"https://api.example.com"}, err: true},
+		{h: []string{"https://api.example.com"}, n: []string{"https://api.example.com"}},
+		{h: []string{"https://api.example.com"}, n: []string{"https://api.example.com/users"}, err: true},
+		{h: []string{"https://*.example.com/api"}, n: []string{"https://eu.example.com/api"}},
+		{h: []string{"https://*.example.com/api"}, n: []string{"https://eu.example.com/api/users"}, err: true},
+		{h: []string{"https://*.example.com/api"}, n: []string{"https://evil.com/.example.com/api"}, err: true},
+		{h: []string{"https://*.example.com/api"}, n: []string{"https://evil.com?.example.com/api"}, err: true},
+		{h: []string{"https://*.example.com/api"}, n: []string{"https://evil.com#.example.com/api"}, err: true},
+		{h: []string{"https://*.example.com/api"}, n: []string{"https://user@evil.com@.example.com/api"}, err: true},
+		{h: []string{"https://*.example.com/api"}, n: []string{"https://evil.com@eu.example.com/api"}, err: true},
+		{h: []string{"https://*.example.com/api"}, n: []string{`https://evil.com\.example.com/api`}, err: true},
+		{h: []string{"https://*.example.com/api"}, n: []string{"https://eu.api.example.com/api"}},
+		{h: []string{"https://api.example.com/*"}, n: []string{"https://api.example.com/users", "https://api.example.com/groups"}},
+		{h: []string{"https://api.example.com/*"}, n: []string{"https://api.example.com/users/1"}, err: true},
+		{h: []string{"https://api.example.com/*/*"}, n: []string{"https://api.example.com/users/1"}},
+		{h: []string{"urn:api:*"}, n: []string{"urn:api:users"}},
+		{h: []string{"urn:api:*", "https://api.example.com"}, n: []string{"urn:api:users", "https://other.example.com"}, err: true},
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			err := WildcardAudienceMatchingStrategy(tc.h, tc.n)
+			if tc.err {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestClientAudienceMatchingStrategy(t *testing.T) {
+	newClient := func(strategy string) Client {
+		return &DefaultOpenIDConnectClient{DefaultClient: &DefaultClient{}, AudienceMatchingStrategy: strategy}
+	}
+	haystack := []string{"https://api.example.com"}
+	needle := []string{"https://api.example.com/users"}
+
+	require.NoError(t, ClientAudienceMatchingStrategy(&DefaultClient{}, DefaultAudienceMatchingStrategy)(haystack, needle))
+	require.NoError(t, ClientAudienceMatchingStrategy(newClient(""), DefaultAudienceMatchingStrategy)(haystack, needle))
+	require.NoError(t, ClientAudienceMatchingStrategy(newClient("unknown"), DefaultAudienceMatchingStrategy)(haystack, needle))
+	require.Error(t, ClientAudienceMatchingStrategy(newClient(AudienceMatchingStrategyExact), DefaultAudienceMatchingStrategy)(haystack, needle))
+	require.NoError(t, ClientAudienceMatchingStrategy(newClient(AudienceMatchingStrategyURLHierarchy), ExactAudienceMatchingStrategy)(haystack, needle))
+}
+
+func TestGetResources(t *testing.T) {
+	for k, c := range []struct {
+		resources []string
+		expectErr error
+	}{
+		{resources: nil},
+		{resources: []string{"https://api.example.com", "https://other.example.com/v1"}},
+		{resources: []string{"api"}, expectErr: ErrInvalidTarget},
+		{resources: []string{"https://api.example.com#foo"}, expectErr: ErrInvalidTarget},
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			resources, err := GetResources(url.Values{"resource": c.resources})
+			if c.expectErr != nil {
+				require.ErrorIs(t, err, c.expectErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, RemoveEmpty(c.resources), resources)
+		})
+	}
+}
+
+func TestNarrowAudience(t *testing.T) {
+	granted := Arguments{"https://api.example.com", "https://other.example.com"}
+
+	audience, err := NarrowAudience(nil, granted)
+	require.NoError(t, err)
+	require.Equal(t, granted, audience)
+
+	audience, err = NarrowAudience([]string{"https://other.example.com"}, granted)
+	require.NoError(t, err)
+	require.Equal(t, Arguments{"https://other.example.com"}, audience)
+
+	_, err = NarrowAudience([]string{"https://evil.example.com"}, granted)
+	require.ErrorIs(t, err, ErrInvalidTarget)
+}
+
+func TestValidateAuthorizeAudienceWithResources(t *testing.T) {
+	f := &Fosite{AudienceMatchingStrategy: DefaultAudienceMatchingStrategy}
+	newRequest := func(form url.Values) *AuthorizeRequest {
+		ar := NewAuthorizeRequest()
+		ar.Client = &DefaultClient{Audience: []string{"https://api.example.com"}}
+		ar.Form = form
+		return ar
+	}
+
+	ar := newRequest(url.Values{"resource": {"https://api.example.com/v1"}, "audience": {"https://api.example.com"}})
+	require.NoError(t, f.validateAuthorizeAudience(nil, ar))
+	require.Equal(t, Arguments{"https://api.example.com", "https://api.example.com/v1"}, ar.GetRequestedAudience())
+
+	require.ErrorIs(t, f.validateAuthorizeAudience(nil, newRequest(url.Values{"resource": {"https://evil.example.com"}})), ErrInvalidTarget)
+}
@@ -0,0 +1,206 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"reflect"
+
+	"github.com/ory/go-convenience/stringslice"
+	"github.com/ory/x/errorsx"
+)
+
+// AuthorizationDetail is a single entry of the authorization_details parameter as defined in
+// https://tools.ietf.org/html/rfc9396#section-2. Fields specific to the authorization details type are kept in Extra.
+type AuthorizationDetail struct {
+	Type       string                 `json:"type"`
+	Locations  []string               `json:"locations,omitempty"`
+	Actions    []string               `json:"actions,omitempty"`
+	DataTypes  []string               `json:"datatypes,omitempty"`
+	Identifier string                 `json:"identifier,omitempty"`
+	Privileges []string               `json:"privileges,omitempty"`
+	Extra      map[string]interface{} `json:"-"`
+}
+
+// AuthorizationDetails is the list of authorization details of a request.
+type AuthorizationDetails []AuthorizationDetail
+
+// AuthorizationDetailValidator validates the type-specific fields of an authorization detail. It should return
+// ErrInvalidAuthorizationDetails if the detail is malformed or the client is not allowed to request it.
+type AuthorizationDetailValidator func(ctx context.Context, client Client, detail AuthorizationDetail) error
+
+// AuthorizationDetailsClient can be implemented by clients which are restricted to a set of authorization details
+// types, see https://tools.ietf.org/html/rfc9396#section-10
+type AuthorizationDetailsClient interface {
+	// GetAuthorizationDetailsTypes returns the authorization details types the client may request. All types
+	// supported by the authorization server may be requested if empty.
+	GetAuthorizationDetailsTypes() []string
+}
+
+var authorizationDetailFields = []string{"type", "locations", "actions", "datatypes", "identifier", "privileges"}
+
+func (d AuthorizationDetail) MarshalJSON() ([]byte, error) {
+	type detail AuthorizationDetail
+	common, err := json.Marshal(detail(d))
+	if err != nil || len(d.Extra) == 0 {
+		return common, err
+	}
+
+	fields := map[string]interface{}{}
+	for k, v := range d.Extra {
+		fields[k] = v
+	}
+	if err := json.Unmarshal(common, &fields); err != nil {
+		return nil, err
+	}
+	return json.Marshal(fields)
+}
+
+func (d *AuthorizationDetail) UnmarshalJSON(data []byte) error {
+	type detail AuthorizationDetail
+	var common detail
+	if err := json.Unmarshal(data, &common); err != nil {
+		return err
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	for _, k := range authorizationDetailFields {
+		delete(fields, k)
+	}
+	if len(fields) > 0 {
+		common.Extra = fields
+	}
+
+	*d = AuthorizationDetail(common)
+	return nil
+}
+
+// equal compares two authorization details field by field after normalizing them to their JSON representation.
+func (d AuthorizationDetail) equal(other AuthorizationDetail) bool {
+	var a, b interface{}
+	if raw, err := json.Marshal(d); err != nil || json.Unmarshal(raw, &a) != nil {
+		return false
+	}
+	if raw, err := json.Marshal(other); err != nil || json.Unmarshal(raw, &b) != nil {
+		return false
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// Types returns the distinct types of the authorization details.
+func (d AuthorizationDetails) Types() []string {
+	types := make([]string, 0, len(d))
+	for _, detail := range d {
+		if !stringslice.Has(types, detail.Type) {
+			types = append(types, detail.Type)
+		}
+	}
+	return types
+}
+
+// Contains returns true if an authorization detail equal to the given one is part of the list.
+func (d AuthorizationDetails) Contains(detail AuthorizationDetail) bool {
+	for _, candidate := range d {
+		if candidate.equal(detail) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetAuthorizationDetails parses the authorization_details form parameter. It returns nil if the parameter is not set.
+func GetAuthorizationDetails(form url.Values) (AuthorizationDetails, error) {
+	raw := form.Get("authorization_details")
+	if raw == "" {
+		return nil, nil
+	}
+
+	var details AuthorizationDetails
+	if err := json.Unmarshal([]byte(raw), &details); err != nil {
+		return nil, errorsx.WithStack(ErrInvalidAuthorizationDetails.WithHint("Unable to decode the 'authorization_details' parameter, it must be a JSON array of objects.").WithWrap(err).WithDebug(err.Error()))
+	}
+
+	for _, detail := range details {
+		if detail.Type == "" {
+			return nil, errorsx.WithStack(ErrInvalidAuthorizationDetails.WithHint("Each authorization detail must contain the 'type' field."))
+		}
+	}
+	return details, nil
+}
+
+// NarrowAuthorizationDetails returns the authorization details requested at the token endpoint, or all granted
+// authorization details if none were requested. An error is returned if a requested authorization detail has not been
+// granted, see https://tools.ietf.org/html/rfc9396#section-6.1
+func NarrowAuthorizationDetails(requested, granted AuthorizationDetails) (AuthorizationDetails, error) {
+	if len(requested) == 0 {
+		return granted, nil
+	}
+
+	for _, detail := range requested {
+		if !granted.Contains(detail) {
+			return nil, errorsx.WithStack(ErrInvalidAuthorizationDetails.WithHintf("The authorization detail of type '%s' has not been granted.", detail.Type))
+		}
+	}
+	return requested, nil
+}
+
+// validateAuthorizationDetails validates the requested types against the types supported by the authorization server
+// and the types allowed for the client.
+func (f *Fosite) validateAuthorizationDetails(ctx context.Context, client Client, details AuthorizationDetails) error {
+	var allowed []string
+	if c, ok := client.(AuthorizationDetailsClient); ok {
+		allowed = c.GetAuthorizationDetailsTypes()
+	}
+
+	for _, detail := range details {
+		validator, ok := f.AuthorizationDetailsTypes[detail.Type]
+		if !ok {
+			return errorsx.WithStack(ErrInvalidAuthorizationDetails.WithHintf("The authorization details type '%s' is not supported.", detail.Type))
+		} else if len(allowed) > 0 && !stringslice.Has(allowed, detail.Type) {
+			return errorsx.WithStack(ErrInvalidAuthorizationDetails.WithHintf("The OAuth 2.0 Client is not allowed to request authorization details of type '%s'.", detail.Type))
+		}
+
+		if validator != nil {
+			if err := validator(ctx, client, detail); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (f *Fosite) validateAuthorizeAuthorizationDetails(ctx context.Context, request *AuthorizeRequest) error {
+	details, err := GetAuthorizationDetails(request.Form)
+	if err != nil {
+		return err
+	} else if err := f.validateAuthorizationDetails(ctx, request.Client, details); err != nil {
+		return err
+	}
+
+	request.SetRequestedAuthorizationDetails(details)
+	return nil
+}
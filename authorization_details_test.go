@@ -0,0 +1,164 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/storage"
+)
+
+func TestAuthorizationDetailJSON(t *testing.T) {
+	raw := `{"type":"payment_initiation","actions":["initiate"],"locations":["https://bank.example.com"],"instructedAmount":{"currency":"EUR","amount":"123.50"},"creditorName":"Merchant A"}`
+
+	var detail AuthorizationDetail
+	require.NoError(t, json.Unmarshal([]byte(raw), &detail))
+	assert.Equal(t, "payment_initiation", detail.Type)
+	assert.Equal(t, []string{"initiate"}, detail.Actions)
+	assert.Equal(t, []string{"https://bank.example.com"}, detail.Locations)
+	assert.Equal(t, "Merchant A", detail.Extra["creditorName"])
+	assert.NotContains(t, detail.Extra, "type")
+
+	out, err := json.Marshal(detail)
+	require.NoError(t, err)
+	assert.JSONEq(t, raw, string(out))
+}
+
+func TestGetAuthorizationDetails(t *testing.T) {
+	for k, c := range []struct {
+		raw       string
+		expectErr error
+		expect    AuthorizationDetails
+	}{
+		{raw: ""},
+		{raw: "foo", expectErr: ErrInvalidAuthorizationDetails},
+		{raw: `{"type":"foo"}`, expectErr: ErrInvalidAuthorizationDetails},
+		{raw: `[{"actions":["read"]}]`, expectErr: ErrInvalidAuthorizationDetails},
+		{raw: `[{"type":"foo","actions":["read"]},{"type":"bar"}]`, expect: AuthorizationDetails{{Type: "foo", Actions: []string{"read"}}, {Type: "bar"}}},
+	} {
+		details, err := GetAuthorizationDetails(url.Values{"authorization_details": {c.raw}})
+		if c.expectErr != nil {
+			assert.ErrorIs(t, err, c.expectErr, "%d", k)
+			continue
+		}
+		require.NoError(t, err, "%d", k)
+		assert.Equal(t, c.expect, details, "%d", k)
+	}
+}
+
+func TestNarrowAuthorizationDetails(t *testing.T) {
+	read := AuthorizationDetail{Type: "account_information", Actions: []string{"read"}, Extra: map[string]interface{}{"accounts": []interface{}{"foo"}}}
+	write := AuthorizationDetail{Type: "account_information", Actions: []string{"write"}}
+	granted := AuthorizationDetails{read, write}
+
+	details, err := NarrowAuthorizationDetails(nil, granted)
+	require.NoError(t, err)
+	assert.Equal(t, granted, details)
+
+	details, err = NarrowAuthorizationDetails(AuthorizationDetails{{Type: "account_information", Actions: []string{"read"}, Extra: map[string]interface{}{"accounts": []string{"foo"}}}}, granted)
+	require.NoError(t, err)
+	assert.Len(t, details, 1)
+
+	_, err = NarrowAuthorizationDetails(AuthorizationDetails{{Type: "account_information", Actions: []string{"delete"}}}, granted)
+	assert.ErrorIs(t, err, ErrInvalidAuthorizationDetails)
+}
+
+func TestAuthorizationDetailsFlow(t *testing.T) {
+	ctx := context.Background()
+	payment := `[{"type":"payment_initiation","instructedAmount":{"currency":"EUR","amount":"123.50"}}]`
+
+	f := compose.ComposeAllEnabled(&compose.Config{
+		TokenURL: "https://www.ory.sh/token",
+		AuthorizationDetailsTypes: map[string]AuthorizationDetailValidator{
+			"payment_initiation": func(_ context.Context, _ Client, detail AuthorizationDetail) error {
+				if _, ok := detail.Extra["instructedAmount"]; !ok {
+					return ErrInvalidAuthorizationDetails.WithHint("The instructed amount is missing.")
+				}
+				return nil
+			},
+		},
+	}, storage.NewExampleStore(), []byte("some-secret-thats-random-some-secret-thats-random-"), nil).(*Fosite)
+
+	authorize := func(t *testing.T, details string) (AuthorizeRequester, error) {
+		r, err := http.NewRequest("GET", "https://www.ory.sh/auth?"+url.Values{
+			"response_type":         {"code"},
+			"client_id":             {"my-client"},
+			"redirect_uri":          {"http://localhost:3846/callback"},
+			"scope":                 {"photos"},
+			"state":                 {"some-random-state"},
+			"authorization_details": {details},
+		}.Encode(), nil)
+		require.NoError(t, err)
+		return f.NewAuthorizeRequest(ctx, r)
+	}
+
+	t.Run("case=rejects unsupported types", func(t *testing.T) {
+		_, err := authorize(t, `[{"type":"account_information"}]`)
+		assert.ErrorIs(t, err, ErrInvalidAuthorizationDetails)
+	})
+
+	t.Run("case=rejects details failing validation", func(t *testing.T) {
+		_, err := authorize(t, `[{"type":"payment_initiation"}]`)
+		assert.ErrorIs(t, err, ErrInvalidAuthorizationDetails)
+	})
+
+	ar, err := authorize(t, payment)
+	require.NoError(t, err)
+	require.Len(t, ar.GetRequestedAuthorizationDetails(), 1)
+	ar.GrantScope("photos")
+	ar.GrantAuthorizationDetails(ar.GetRequestedAuthorizationDetails())
+
+	authorizeResponse, err := f.NewAuthorizeResponse(ctx, ar, new(DefaultSession))
+	require.NoError(t, err)
+
+	r, err := http.NewRequest("POST", "https://www.ory.sh/token", strings.NewReader(url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {authorizeResponse.GetCode()},
+		"redirect_uri": {"http://localhost:3846/callback"},
+	}.Encode()))
+	require.NoError(t, err)
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.SetBasicAuth("my-client", "foobar")
+
+	accessRequest, err := f.NewAccessRequest(ctx, r, new(DefaultSession))
+	require.NoError(t, err)
+	accessResponse, err := f.NewAccessResponse(ctx, accessRequest)
+	require.NoError(t, err)
+
+	details, ok := accessResponse.GetExtra("authorization_details").(AuthorizationDetails)
+	require.True(t, ok)
+	assert.Equal(t, "payment_initiation", details[0].Type)
+
+	_, introspected, err := f.IntrospectToken(ctx, accessResponse.GetAccessToken(), AccessToken, new(DefaultSession))
+	require.NoError(t, err)
+	assert.Equal(t, details, introspected.GetAuthorizationDetails())
+}
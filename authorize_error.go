@@ -22,40 +22,28 @@
 package fosite
 
 import (
-	"encoding/json"
-	"fmt"
 	"net/http"
 )
 
 func (f *Fosite) WriteAuthorizeError(rw http.ResponseWriter, ar AuthorizeRequester, err error) {
+	f.GetEventListener().OnAuthorizationDenied(requestContext(err), ar, err)
+
 	rw.Header().Set("Cache-Control", "no-store")
 	rw.Header().Set("Pragma", "no-cache")
+	setRequestIDHeader(rw, err)
 
 	if f.ResponseModeHandler().ResponseModes().Has(ar.GetResponseMode()) {
 		f.ResponseModeHandler().WriteAuthorizeError(rw, ar, err)
 		return
 	}
 
-	rfcerr := ErrorToRFC6749Error(err).WithLegacyFormat(f.UseLegacyErrorFormat).WithExposeDebug(f.SendDebugMessagesToClients).WithLocalizer(f.MessageCatalog, getLangFromRequester(ar))
 	if !ar.IsRedirectURIValid() {
-		rw.Header().Set("Content-Type", "application/json;charset=UTF-8")
-
-		js, err := json.Marshal(rfcerr)
-		if err != nil {
-			if f.SendDebugMessagesToClients {
-				errorMessage := EscapeJSONString(err.Error())
-				http.Error(rw, fmt.Sprintf(`{"error":"server_error","error_description":"%s"}`, errorMessage), http.StatusInternalServerError)
-			} else {
-				http.Error(rw, `{"error":"server_error"}`, http.StatusInternalServerError)
-			}
-			return
-		}
-
-		rw.WriteHeader(rfcerr.CodeField)
-		_, _ = rw.Write(js)
+		f.writeErrorResponse(rw, AuthorizeEndpoint, ar, err)
 		return
 	}
 
+	rfcerr := f.exposeDebug(ar, ErrorToRFC6749Error(err).WithLegacyFormat(f.UseLegacyErrorFormat)).WithLocalizer(f.MessageCatalog, getLangFromRequester(ar))
+
 	redirectURI := ar.GetRedirectURI()
 
 	// The endpoint URI MUST NOT include a fragment component.
@@ -64,6 +52,16 @@ func (f *Fosite) WriteAuthorizeError(rw http.ResponseWriter, ar AuthorizeRequest
 	errors := rfcerr.ToValues()
 	errors.Set("state", ar.GetState())
 
+	// https://tools.ietf.org/html/rfc9207#section-2
+	if issuer := f.authorizeResponseIssuer(ar); issuer != "" {
+		errors.Set("iss", issuer)
+	}
+
+	if f.JARMStrategy != nil && IsJARMResponseMode(ar.GetResponseMode()) {
+		f.writeJARMResponse(rw, ar, errors)
+		return
+	}
+
 	var redirectURIString string
 	if ar.GetResponseMode() == ResponseModeFormPost {
 		rw.Header().Set("Content-Type", "text/html;charset=UTF-8")
@@ -79,13 +79,23 @@ var FormPostDefaultTemplate = template.Must(template.New("form_post").Parse(`<ht
 //     particular end-user authorization and validates this redirect URI
 //     with the redirect URI passed to the token's endpoint, such an
 //     attack is detected (see Section 5.2.4.5).
+//
+// Registered loopback redirect URIs match requested ones with any port, see LoopbackRedirectURIMatcher. Use
+// MatchRedirectURI to match using another RedirectURIMatcher.
 func MatchRedirectURIWithClientRedirectURIs(rawurl string, client Client) (*url.URL, error) {
+	return MatchRedirectURI(rawurl, client, LoopbackRedirectURIMatcher)
+}
+
+// MatchRedirectURI returns the redirect URI to use for the given redirect_uri parameter, which is matched against the
+// redirect URIs registered by the client using matcher. If no redirect_uri was given and the client registered exactly
+// one, that one is used. Does not perform uri validation.
+func MatchRedirectURI(rawurl string, client Client, matcher RedirectURIMatcher) (*url.URL, error) {
 	if rawurl == "" && len(client.GetRedirectURIs()) == 1 {
 		if redirectURIFromClient, err := url.Parse(client.GetRedirectURIs()[0]); err == nil && IsValidRedirectURI(redirectURIFromClient) {
 			// If no redirect_uri was given and the client has exactly one valid redirect_uri registered, use that instead
 			return redirectURIFromClient, nil
 		}
-	} else if redirectTo, ok := isMatchingRedirectURI(rawurl, client.GetRedirectURIs()); rawurl != "" && ok {
+	} else if redirectTo, ok := matcher.MatchRedirectURI(rawurl, client); rawurl != "" && ok {
 		// If a redirect_uri was given and the clients knows it (simple string comparison!)
 		// return it.
 		if parsed, err := url.Parse(redirectTo); err == nil && IsValidRedirectURI(parsed) {
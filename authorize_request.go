@@ -23,6 +23,8 @@ package fosite
 
 import (
 	"net/url"
+
+	"github.com/ory/fosite/token/jwt"
 )
 
 type ResponseModeType string
@@ -42,6 +44,15 @@ type AuthorizeRequest struct {
 	HandledResponseTypes Arguments        `json:"handledResponseTypes" gorethink:"handledResponseTypes"`
 	ResponseMode         ResponseModeType `json:"ResponseModes" gorethink:"ResponseModes"`
 	DefaultResponseMode  ResponseModeType `json:"DefaultResponseMode" gorethink:"DefaultResponseMode"`
+	RequestObjectClaims  jwt.MapClaims    `json:"requestObjectClaims" gorethink:"requestObjectClaims"`
+	RequestedClaims      *ClaimsRequest   `json:"requestedClaims,omitempty" gorethink:"requestedClaims"`
+
+	// Issuer is the issuer returned in the "iss" parameter of the authorization response, see
+	// https://tools.ietf.org/html/rfc9207
+	Issuer string `json:"issuer,omitempty" gorethink:"issuer"`
+
+	// redirectURIMatcher is the matcher the redirect URI was validated with, see IsRedirectURIValid.
+	redirectURIMatcher RedirectURIMatcher
 
 	Request
 }
@@ -67,7 +78,13 @@ func (d *AuthorizeRequest) IsRedirectURIValid() bool {
 		return false
 	}
 
-	redirectURI, err := MatchRedirectURIWithClientRedirectURIs(raw, d.GetClient())
+	// Requests which were not created by the provider, e.g. loaded from storage, use the default of the provider.
+	matcher := d.redirectURIMatcher
+	if matcher == nil {
+		matcher = ExactRedirectURIMatcher
+	}
+
+	redirectURI, err := MatchRedirectURI(raw, d.GetClient(), matcher)
 	if err != nil {
 		return false
 	}
@@ -114,3 +131,15 @@ func (d *AuthorizeRequest) SetDefaultResponseMode(defaultResponseMode ResponseMo
 func (d *AuthorizeRequest) GetDefaultResponseMode() ResponseModeType {
 	return d.DefaultResponseMode
 }
+
+func (d *AuthorizeRequest) GetRequestObjectClaims() jwt.MapClaims {
+	return d.RequestObjectClaims
+}
+
+func (d *AuthorizeRequest) GetRequestedClaims() *ClaimsRequest {
+	return d.RequestedClaims
+}
+
+func (d *AuthorizeRequest) GetIssuer() string {
+	return d.Issuer
+}
@@ -23,6 +23,7 @@ package fosite
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -46,17 +47,42 @@ func wrapSigningKeyFailure(outer *RFC6749Error, inner error) *RFC6749Error {
 	return outer
 }
 
-func (f *Fosite) authorizeRequestParametersFromOpenIDConnectRequest(request *AuthorizeRequest) error {
+// isJWTSecuredAuthorizationRequestEnabled returns true if request objects are processed as defined in
+// https://tools.ietf.org/html/rfc9101 instead of the OpenID Connect rules only.
+func (f *Fosite) isJWTSecuredAuthorizationRequestEnabled() bool {
+	return f.EnableJWTSecuredAuthorizationRequests || f.RequireSignedRequestObject
+}
+
+// requestObjectParameter converts a request object claim to its authorization request parameter representation.
+// Claims which are not strings, such as max_age or claims, are JSON encoded.
+func requestObjectParameter(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+
+	out, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%s", value)
+	}
+	return string(out)
+}
+
+func (f *Fosite) authorizeRequestParametersFromOpenIDConnectRequest(ctx context.Context, request *AuthorizeRequest) error {
 	var scope Arguments = RemoveEmpty(strings.Split(request.Form.Get("scope"), " "))
 
+	hasRequestObject := len(request.Form.Get("request")+request.Form.Get("request_uri")) > 0
+	if f.RequireSignedRequestObject && !hasRequestObject {
+		return errorsx.WithStack(ErrInvalidRequest.WithHint("Authorization requests must be sent as signed request objects using the 'request' or 'request_uri' parameter."))
+	}
+
 	// Even if a scope parameter is present in the Request Object value, a scope parameter MUST always be passed using
 	// the OAuth 2.0 request syntax containing the openid scope value to indicate to the underlying OAuth 2.0 logic that this is an OpenID Connect request.
 	// Source: http://openid.net/specs/openid-connect-core-1_0.html#CodeFlowAuth
-	if !scope.Has("openid") {
+	if !scope.Has("openid") && !f.isJWTSecuredAuthorizationRequestEnabled() {
 		return nil
 	}
 
-	if len(request.Form.Get("request")+request.Form.Get("request_uri")) == 0 {
+	if !hasRequestObject {
 		return nil
 	} else if len(request.Form.Get("request")) > 0 && len(request.Form.Get("request_uri")) > 0 {
 		return errorsx.WithStack(ErrInvalidRequest.WithHint("OpenID Connect parameters 'request' and 'request_uri' were both given, but you can use at most one."))
@@ -114,26 +140,29 @@ func (f *Fosite) authorizeRequestParametersFromOpenIDConnectRequest(request *Aut
 		}
 
 		if t.Method == jwt.SigningMethodNone {
+			if f.RequireSignedRequestObject {
+				return nil, errorsx.WithStack(ErrInvalidRequestObject.WithHint("The request object must be signed, but it uses signing algorithm 'none'."))
+			}
 			return jwt.UnsafeAllowNoneSignatureType, nil
 		}
 
 		switch t.Method {
 		case jose.RS256, jose.RS384, jose.RS512:
-			key, err := f.findClientPublicJWK(oidcClient, t, true)
+			key, err := f.findClientPublicJWK(ctx, oidcClient, t, true)
 			if err != nil {
 				return nil, wrapSigningKeyFailure(
 					ErrInvalidRequestObject.WithHint("Unable to retrieve RSA signing key from OAuth 2.0 Client."), err)
 			}
 			return key, nil
 		case jose.ES256, jose.ES384, jose.ES512:
-			key, err := f.findClientPublicJWK(oidcClient, t, false)
+			key, err := f.findClientPublicJWK(ctx, oidcClient, t, false)
 			if err != nil {
 				return nil, wrapSigningKeyFailure(
 					ErrInvalidRequestObject.WithHint("Unable to retrieve ECDSA signing key from OAuth 2.0 Client."), err)
 			}
 			return key, nil
 		case jose.PS256, jose.PS384, jose.PS512:
-			key, err := f.findClientPublicJWK(oidcClient, t, true)
+			key, err := f.findClientPublicJWK(ctx, oidcClient, t, true)
 			if err != nil {
 				return nil, wrapSigningKeyFailure(
 					ErrInvalidRequestObject.WithHint("Unable to retrieve RSA signing key from OAuth 2.0 Client."), err)
@@ -158,8 +187,37 @@ func (f *Fosite) authorizeRequestParametersFromOpenIDConnectRequest(request *Aut
 	}
 
 	claims := token.Claims
+
+	// https://tools.ietf.org/html/rfc9101#section-6.3
+	// The client ID values in the "client_id" request parameter and in the Request Object "client_id" claim MUST
+	// be identical.
+	if clientID, ok := claims["client_id"]; ok && clientID != request.GetClient().GetID() {
+		return errorsx.WithStack(ErrInvalidRequestObject.WithHint("The 'client_id' claim of the request object does not match the OAuth 2.0 Client of the request."))
+	} else if issuer, ok := claims["iss"]; ok && issuer != request.GetClient().GetID() {
+		return errorsx.WithStack(ErrInvalidRequestObject.WithHint("The 'iss' claim of the request object does not match the OAuth 2.0 Client of the request."))
+	}
+
+	request.RequestObjectClaims = claims
+	if f.isJWTSecuredAuthorizationRequestEnabled() {
+		// https://tools.ietf.org/html/rfc9101#section-5
+		// The authorization server MUST only use the parameters in the Request Object even if the same parameter
+		// is provided in the query parameter.
+		for k := range request.Form {
+			if _, ok := claims[k]; !ok && k != "client_id" && k != "request" && k != "request_uri" {
+				request.Form.Del(k)
+			}
+		}
+
+		for k, v := range claims {
+			request.Form.Set(k, requestObjectParameter(v))
+		}
+
+		request.State = request.Form.Get("state")
+		return nil
+	}
+
 	for k, v := range claims {
-		request.Form.Set(k, fmt.Sprintf("%s", v))
+		request.Form.Set(k, requestObjectParameter(v))
 	}
 
 	claimScope := RemoveEmpty(strings.Split(request.Form.Get("scope"), " "))
@@ -179,7 +237,7 @@ func (f *Fosite) validateAuthorizeRedirectURI(_ *http.Request, request *Authoriz
 	rawRedirURI := request.Form.Get("redirect_uri")
 
 	// Validate redirect uri
-	redirectURI, err := MatchRedirectURIWithClientRedirectURIs(rawRedirURI, request.Client)
+	redirectURI, err := MatchRedirectURI(rawRedirURI, request.Client, f.GetRedirectURIMatcher())
 	if err != nil {
 		return err
 	} else if !IsValidRedirectURI(redirectURI) {
@@ -192,7 +250,7 @@ func (f *Fosite) validateAuthorizeRedirectURI(_ *http.Request, request *Authoriz
 func (f *Fosite) validateAuthorizeScope(_ *http.Request, request *AuthorizeRequest) error {
 	scope := RemoveEmpty(strings.Split(request.Form.Get("scope"), " "))
 	for _, permission := range scope {
-		if !f.ScopeStrategy(request.Client.GetScopes(), permission) {
+		if !ClientScopeStrategy(request.Client, f.ScopeStrategy)(request.Client.GetScopes(), permission) {
 			return errorsx.WithStack(ErrInvalidScope.WithHintf("The OAuth 2.0 Client is not allowed to request scope '%s'.", permission))
 		}
 	}
@@ -212,6 +270,16 @@ func (f *Fosite) validateResponseTypes(r *http.Request, request *AuthorizeReques
 		return errorsx.WithStack(ErrUnsupportedResponseType.WithHint("`The request is missing the 'response_type' parameter."))
 	}
 
+	if f.RejectFrontChannelAccessTokens && Arguments(responseTypes).Has("token") {
+		return errorsx.WithStack(ErrUnsupportedResponseType.WithHintf("The response_type '%s' is not allowed because access tokens must not be issued by the authorization endpoint.", r.Form.Get("response_type")))
+	}
+
+	if f.ResponseTypeRegistry != nil {
+		if _, ok := f.ResponseTypeRegistry.Handlers(responseTypes); !ok {
+			return errorsx.WithStack(ErrUnsupportedResponseType.WithHintf("The response_type '%s' is not supported by the authorization server.", r.Form.Get("response_type")))
+		}
+	}
+
 	var found bool
 	for _, t := range request.GetClient().GetResponseTypes() {
 		if Arguments(responseTypes).Matches(RemoveEmpty(strings.Split(t, " "))...) {
@@ -238,6 +306,11 @@ func (f *Fosite) ParseResponseMode(r *http.Request, request *AuthorizeRequest) e
 		request.ResponseMode = ResponseModeQuery
 	case string(ResponseModeFormPost):
 		request.ResponseMode = ResponseModeFormPost
+	case string(ResponseModeJWT), string(ResponseModeQueryJWT), string(ResponseModeFragmentJWT), string(ResponseModeFormPostJWT):
+		if f.JARMStrategy == nil {
+			return errorsx.WithStack(ErrUnsupportedResponseMode.WithHintf("Request with unsupported response_mode \"%s\".", responseMode))
+		}
+		request.ResponseMode = ResponseModeType(responseMode)
 	default:
 		rm := ResponseModeType(responseMode)
 		if f.ResponseModeHandler().ResponseModes().Has(rm) {
@@ -276,68 +349,120 @@ func (f *Fosite) validateResponseMode(r *http.Request, request *AuthorizeRequest
 }
 
 func (f *Fosite) NewAuthorizeRequest(ctx context.Context, r *http.Request) (AuthorizeRequester, error) {
+	ctx = f.withStoragePolicy(ctx)
+	ctx = ensureRequestID(ctx, r)
+	ctx, span := f.startSpan(ctx, "fosite.NewAuthorizeRequest")
+	request, err := f.newAuthorizeRequest(ctx, r)
+	err = attachRequestID(ctx, err)
+	f.reportRequestFailed(ctx, AuthorizeEndpoint, err)
+	endSpan(span, request, err)
+	return request, err
+}
+
+func (f *Fosite) newAuthorizeRequest(ctx context.Context, r *http.Request) (AuthorizeRequester, error) {
 	request := NewAuthorizeRequest()
+	request.PersistedParameters = f.SanitationWhiteList
+	request.redirectURIMatcher = f.GetRedirectURIMatcher()
 	request.Request.Lang = i18n.GetLangFromRequest(f.MessageCatalog, r)
 
 	ctx = context.WithValue(ctx, RequestContextKey, r)
 	ctx = context.WithValue(ctx, AuthorizeRequestContextKey, request)
 
+	if err := f.setAuthorizeRequestIssuer(ctx, request); err != nil {
+		return request, err
+	}
+
 	if err := r.ParseMultipartForm(1 << 20); err != nil && err != http.ErrNotMultipart {
 		return request, errorsx.WithStack(ErrInvalidRequest.WithHint("Unable to parse HTTP body, make sure to send a properly formatted form request body.").WithWrap(err).WithDebug(err.Error()))
 	}
 	request.Form = r.Form
+	request.Request.Lang = i18n.GetLangFromLocales(f.MessageCatalog, request.Form.Get("ui_locales"), request.Request.Lang)
 
 	// Save state to the request to be returned in error conditions (https://github.com/ory/hydra/issues/1642)
 	request.State = request.Form.Get("state")
 
-	client, err := f.Store.GetClient(ctx, request.GetRequestForm().Get("client_id"))
-	if err != nil {
+	client, err := f.getClient(ctx, request.GetRequestForm().Get("client_id"))
+	if errors.Is(err, context.DeadlineExceeded) {
+		return request, errorsx.WithStack(ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	} else if err != nil {
 		return request, errorsx.WithStack(ErrInvalidClient.WithHint("The requested OAuth 2.0 Client does not exist.").WithWrap(err).WithDebug(err.Error()))
 	}
 	request.Client = client
 
+	// If the request references a pushed authorization request, the pushed parameters replace the ones
+	// sent to the authorization endpoint.
+	if err := f.authorizeRequestFromPAR(ctx, r, request); err != nil {
+		return request, err
+	}
+
+	if err := f.validateAuthorizeRequest(ctx, r, request); err != nil {
+		return request, err
+	}
+
+	return request, nil
+}
+
+func (f *Fosite) validateAuthorizeRequest(ctx context.Context, r *http.Request, request *AuthorizeRequest) error {
 	// Now that the base fields (state and client) are populated, we extract all the information
 	// from the request object or request object uri, if one is set.
 	//
 	// All other parse methods should come afterwards so that we ensure that the data is taken
 	// from the request_object if set.
-	if err := f.authorizeRequestParametersFromOpenIDConnectRequest(request); err != nil {
-		return request, err
+	if err := f.authorizeRequestParametersFromOpenIDConnectRequest(ctx, request); err != nil {
+		return err
 	}
 
 	// The request context is now fully available and we can start processing the individual
 	// fields.
 	if err := f.ParseResponseMode(r, request); err != nil {
-		return request, err
+		return err
 	}
 
 	if err := f.validateAuthorizeRedirectURI(r, request); err != nil {
-		return request, err
+		return err
 	}
 
 	if err := f.validateAuthorizeScope(r, request); err != nil {
-		return request, err
+		return err
 	}
 
 	if err := f.validateAuthorizeAudience(r, request); err != nil {
-		return request, err
+		return err
+	}
+
+	if err := f.validateAuthorizeAuthorizationDetails(ctx, request); err != nil {
+		return err
+	}
+
+	if err := f.validateAuthorizeClaims(request); err != nil {
+		return err
+	}
+
+	if err := f.validateAuthorizePrompt(request); err != nil {
+		return err
+	}
+
+	if err := f.validateAuthorizeGrantManagement(ctx, request); err != nil {
+		return err
 	}
 
 	if len(request.Form.Get("registration")) > 0 {
-		return request, errorsx.WithStack(ErrRegistrationNotSupported)
+		return errorsx.WithStack(ErrRegistrationNotSupported)
 	}
 
 	if err := f.validateResponseTypes(r, request); err != nil {
-		return request, err
+		return err
 	}
 
+	f.validateAuthorizeOfflineAccess(ctx, request)
+
 	if err := f.validateResponseMode(r, request); err != nil {
-		return request, err
+		return err
 	}
 
 	// A fallback handler to set the default response mode in cases where we can not reach the Authorize Handlers
 	// but still need the e.g. correct error response mode.
-	if request.GetResponseMode() == ResponseModeDefault {
+	if request.GetResponseMode() == ResponseModeDefault || request.GetResponseMode() == ResponseModeJWT {
 		if request.ResponseTypes.ExactOne("code") {
 			request.SetDefaultResponseMode(ResponseModeQuery)
 		} else {
@@ -354,8 +479,8 @@ func (f *Fosite) NewAuthorizeRequest(ctx context.Context, r *http.Request) (Auth
 	// The "state" parameter should not	be guessable
 	if len(request.State) < f.GetMinParameterEntropy() {
 		// We're assuming that using less then, by default, 8 characters for the state can not be considered "unguessable"
-		return request, errorsx.WithStack(ErrInvalidState.WithHintf("Request parameter 'state' must be at least be %d characters long to ensure sufficient entropy.", f.GetMinParameterEntropy()))
+		return errorsx.WithStack(ErrInvalidState.WithHintf("Request parameter 'state' must be at least be %d characters long to ensure sufficient entropy.", f.GetMinParameterEntropy()))
 	}
 
-	return request, nil
+	return f.runAuthorizeRequestValidators(ctx, request)
 }
@@ -22,6 +22,7 @@
 package fosite
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
 	"encoding/json"
@@ -211,7 +212,7 @@ func TestAuthorizeRequestParametersFromOpenIDConnectRequest(t *testing.T) {
 				},
 			}
 
-			err := f.authorizeRequestParametersFromOpenIDConnectRequest(req)
+			err := f.authorizeRequestParametersFromOpenIDConnectRequest(context.Background(), req)
 			if tc.expectErr != nil {
 				require.EqualError(t, err, tc.expectErr.Error(), "%+v", err)
 				if tc.expectErrReason != "" {
@@ -234,3 +235,106 @@ func TestAuthorizeRequestParametersFromOpenIDConnectRequest(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthorizeRequestParametersFromJWTSecuredAuthorizationRequest(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	client := &DefaultOpenIDConnectClient{
+		DefaultClient:                 &DefaultClient{ID: "foo"},
+		JSONWebKeys:                   &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{KeyID: "kid-foo", Use: "sig", Key: &key.PublicKey}}},
+		RequestObjectSigningAlgorithm: "RS256",
+	}
+
+	requestObject := mustGenerateAssertion(t, jwt.MapClaims{
+		"iss":           "foo",
+		"client_id":     "foo",
+		"scope":         "photos",
+		"response_type": "code",
+		"state":         "some-state",
+		"max_age":       300,
+		"claims":        map[string]interface{}{"id_token": map[string]interface{}{"acr": nil}},
+	}, key, "kid-foo")
+
+	for k, tc := range []struct {
+		d          string
+		f          *Fosite
+		client     Client
+		form       url.Values
+		expectErr  error
+		expectForm url.Values
+	}{
+		{
+			d:    "should only use the parameters of the request object",
+			f:    &Fosite{EnableJWTSecuredAuthorizationRequests: true},
+			form: url.Values{"client_id": {"foo"}, "scope": {"openid offline"}, "response_type": {"token"}, "redirect_uri": {"https://foo.bar/cb"}, "request": {requestObject}},
+			expectForm: url.Values{
+				"iss":           {"foo"},
+				"client_id":     {"foo"},
+				"scope":         {"photos"},
+				"response_type": {"code"},
+				"state":         {"some-state"},
+				"max_age":       {"300"},
+				"claims":        {`{"id_token":{"acr":null}}`},
+				"request":       {requestObject},
+			},
+		},
+		{
+			d:    "should merge the parameters of OpenID Connect requests if not enabled",
+			f:    &Fosite{},
+			form: url.Values{"client_id": {"foo"}, "scope": {"openid"}, "redirect_uri": {"https://foo.bar/cb"}, "request": {requestObject}},
+			expectForm: url.Values{
+				"iss":           {"foo"},
+				"client_id":     {"foo"},
+				"scope":         {"photos openid"},
+				"response_type": {"code"},
+				"state":         {"some-state"},
+				"max_age":       {"300"},
+				"claims":        {`{"id_token":{"acr":null}}`},
+				"redirect_uri":  {"https://foo.bar/cb"},
+				"request":       {requestObject},
+			},
+		},
+		{
+			d:         "should fail because the client_id does not match",
+			f:         &Fosite{EnableJWTSecuredAuthorizationRequests: true},
+			form:      url.Values{"request": {mustGenerateAssertion(t, jwt.MapClaims{"client_id": "bar"}, key, "kid-foo")}},
+			expectErr: ErrInvalidRequestObject,
+		},
+		{
+			d:         "should fail because the issuer does not match",
+			f:         &Fosite{EnableJWTSecuredAuthorizationRequests: true},
+			form:      url.Values{"request": {mustGenerateAssertion(t, jwt.MapClaims{"iss": "bar"}, key, "kid-foo")}},
+			expectErr: ErrInvalidRequestObject,
+		},
+		{
+			d:         "should fail because a request object is required",
+			f:         &Fosite{RequireSignedRequestObject: true},
+			form:      url.Values{"client_id": {"foo"}, "scope": {"photos"}},
+			expectErr: ErrInvalidRequest,
+		},
+		{
+			d:         "should fail because the request object must be signed",
+			f:         &Fosite{RequireSignedRequestObject: true},
+			client:    &DefaultOpenIDConnectClient{DefaultClient: &DefaultClient{ID: "foo"}, JSONWebKeys: client.JSONWebKeys},
+			form:      url.Values{"request": {mustGenerateNoneAssertion(t, jwt.MapClaims{"scope": "photos"})}},
+			expectErr: ErrInvalidRequestObject,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, tc.d), func(t *testing.T) {
+			if tc.client == nil {
+				tc.client = client
+			}
+			req := &AuthorizeRequest{Request: Request{Client: tc.client, Form: tc.form}}
+
+			err := tc.f.authorizeRequestParametersFromOpenIDConnectRequest(context.Background(), req)
+			if tc.expectErr != nil {
+				require.EqualError(t, err, tc.expectErr.Error(), "%+v", err)
+				return
+			}
+			require.NoErrorf(t, err, "%+v", err)
+			assert.Equal(t, tc.expectForm, req.Form)
+			assert.Equal(t, "some-state", req.GetState())
+			assert.EqualValues(t, 300, req.GetRequestObjectClaims()["max_age"])
+		})
+	}
+}
@@ -112,6 +112,15 @@ func TestAuthorizeRequest(t *testing.T) {
 			},
 			isRedirValid: true,
 		},
+		{
+			ar: &AuthorizeRequest{
+				Request: Request{
+					Client: &DefaultClient{RedirectURIs: []string{"http://127.0.0.1/cb"}},
+				},
+				RedirectURI: urlparse("http://127.0.0.1:8080/cb"),
+			},
+			isRedirValid: false,
+		},
 	} {
 		assert.Equal(t, c.ar.Client, c.ar.GetClient(), "%d", k)
 		assert.Equal(t, c.ar.RedirectURI, c.ar.GetRedirectURI(), "%d", k)
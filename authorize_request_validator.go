@@ -0,0 +1,75 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+
+	"github.com/ory/x/errorsx"
+	"github.com/pkg/errors"
+)
+
+// AuthorizeRequestValidator is an extension point for custom validations of authorization requests, e.g. scopes
+// banned for a tenant or geographic restrictions. Validators run once NewAuthorizeRequest and
+// NewPushedAuthorizeRequest parsed and validated the request, so the client, redirect URI, response types, scopes
+// and audiences are available, and the original *http.Request is stored under RequestContextKey.
+type AuthorizeRequestValidator interface {
+	// ValidateAuthorizeRequest returns an error if the request must be rejected. Errors should be RFC6749Errors such
+	// as ErrAccessDenied or ErrInvalidScope, other errors are returned as ErrServerError.
+	ValidateAuthorizeRequest(ctx context.Context, requester AuthorizeRequester) error
+}
+
+// AuthorizeRequestValidatorFunc is an adapter to allow the use of ordinary functions as AuthorizeRequestValidator.
+type AuthorizeRequestValidatorFunc func(ctx context.Context, requester AuthorizeRequester) error
+
+func (f AuthorizeRequestValidatorFunc) ValidateAuthorizeRequest(ctx context.Context, requester AuthorizeRequester) error {
+	return f(ctx, requester)
+}
+
+// AuthorizeRequestValidators is a list of AuthorizeRequestValidator which are run in order.
+type AuthorizeRequestValidators []AuthorizeRequestValidator
+
+// Append adds an AuthorizeRequestValidator to this list. Unlike the handler lists, duplicate types are not ignored,
+// as validators are commonly AuthorizeRequestValidatorFuncs.
+func (a *AuthorizeRequestValidators) Append(v AuthorizeRequestValidator) {
+	*a = append(*a, v)
+}
+
+// runAuthorizeRequestValidators returns the error of the first validator rejecting the request.
+func (f *Fosite) runAuthorizeRequestValidators(ctx context.Context, request AuthorizeRequester) error {
+	for _, v := range f.AuthorizeRequestValidators {
+		if err := v.ValidateAuthorizeRequest(ctx, request); err != nil {
+			return extensionError(err)
+		}
+	}
+	return nil
+}
+
+// extensionError returns errors of extension points unchanged if they are RFC6749Errors and as ErrServerError
+// otherwise.
+func extensionError(err error) error {
+	var rfcErr *RFC6749Error
+	if errors.As(err, &rfcErr) {
+		return errorsx.WithStack(err)
+	}
+	return errorsx.WithStack(ErrServerError.WithWrap(err).WithDebug(err.Error()))
+}
@@ -0,0 +1,106 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/storage"
+)
+
+func TestAuthorizeRequestValidators(t *testing.T) {
+	var calls []string
+	bannedScopes := AuthorizeRequestValidatorFunc(func(ctx context.Context, requester AuthorizeRequester) error {
+		calls = append(calls, "banned-scopes")
+		if requester.GetRequestedScopes().Has("photos") {
+			return ErrInvalidScope.WithHint("The scope 'photos' is not available for this tenant.")
+		}
+		return nil
+	})
+	geo := AuthorizeRequestValidatorFunc(func(ctx context.Context, requester AuthorizeRequester) error {
+		calls = append(calls, "geo")
+		r, ok := ctx.Value(RequestContextKey).(*http.Request)
+		require.True(t, ok)
+		switch r.Header.Get("X-Country") {
+		case "xx":
+			return ErrAccessDenied.WithHint("Requests from this country are not allowed.")
+		case "broken":
+			return errors.New("geo lookup failed")
+		}
+		return nil
+	})
+
+	config := &compose.Config{AuthorizeRequestValidators: AuthorizeRequestValidators{bannedScopes, geo}}
+	f := compose.Compose(
+		config,
+		storage.NewExampleStore(),
+		compose.NewOAuth2HMACStrategy(config, []byte("some-secret-thats-random-some-secret-thats-random-"), nil),
+		nil,
+		compose.OAuth2AuthorizeExplicitFactory,
+	)
+
+	for k, c := range []struct {
+		d           string
+		scope       string
+		country     string
+		expectErr   error
+		expectCalls []string
+	}{
+		{d: "should pass all validators", scope: "fosite", expectCalls: []string{"banned-scopes", "geo"}},
+		{d: "should stop at the first failing validator", scope: "fosite photos", expectErr: ErrInvalidScope, expectCalls: []string{"banned-scopes"}},
+		{d: "should return the error of the validator", scope: "fosite", country: "xx", expectErr: ErrAccessDenied, expectCalls: []string{"banned-scopes", "geo"}},
+		{d: "should convert other errors to server errors", scope: "fosite", country: "broken", expectErr: ErrServerError, expectCalls: []string{"banned-scopes", "geo"}},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			calls = nil
+			query := url.Values{
+				"client_id":     {"my-client"},
+				"response_type": {"code"},
+				"redirect_uri":  {"http://localhost:3846/callback"},
+				"state":         {"some-random-state"},
+				"scope":         {c.scope},
+			}
+			r := httptest.NewRequest("GET", "/auth?"+query.Encode(), nil)
+			r.Header.Set("X-Country", c.country)
+
+			_, err := f.NewAuthorizeRequest(context.Background(), r)
+			if c.expectErr != nil {
+				require.Error(t, err)
+				assert.True(t, errors.Is(err, c.expectErr), "%+v", err)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, c.expectCalls, calls)
+		})
+	}
+}
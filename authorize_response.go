@@ -52,6 +52,12 @@ func (a *AuthorizeResponse) AddHeader(key, value string) {
 	a.Header.Add(key, value)
 }
 
+func (a *AuthorizeResponse) AddCookie(cookie *http.Cookie) {
+	if v := cookie.String(); v != "" {
+		a.Header.Add("Set-Cookie", v)
+	}
+}
+
 func (a *AuthorizeResponse) GetParameters() url.Values {
 	return a.Parameters
 }
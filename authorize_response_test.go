@@ -22,6 +22,7 @@
 package fosite
 
 import (
+	"net/http"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -40,4 +41,9 @@ func TestAuthorizeResponse(t *testing.T) {
 	assert.Equal(t, "bar", ar.GetParameters().Get("foo"))
 	assert.Equal(t, "foo", ar.GetHeader().Get("foo"))
 	assert.Equal(t, "bar", ar.GetParameters().Get("bar"))
+
+	ar.AddCookie(&http.Cookie{Name: "session_state", Value: "foo", Path: "/", Secure: true})
+	ar.AddCookie(&http.Cookie{Name: "other", Value: "bar", HttpOnly: true})
+	ar.AddCookie(&http.Cookie{Name: "in valid", Value: "baz"})
+	assert.Equal(t, []string{"session_state=foo; Path=/; Secure", "other=bar; HttpOnly"}, ar.GetHeader()["Set-Cookie"])
 }
@@ -30,6 +30,13 @@ import (
 )
 
 func (f *Fosite) NewAuthorizeResponse(ctx context.Context, ar AuthorizeRequester, session Session) (AuthorizeResponder, error) {
+	ctx = f.withStoragePolicy(ctx)
+	ctx = ensureRequestID(ctx, nil)
+	response, err := f.newAuthorizeResponse(ctx, ar, session)
+	return response, attachRequestID(ctx, err)
+}
+
+func (f *Fosite) newAuthorizeResponse(ctx context.Context, ar AuthorizeRequester, session Session) (AuthorizeResponder, error) {
 	var resp = &AuthorizeResponse{
 		Header:     http.Header{},
 		Parameters: url.Values{},
@@ -39,7 +46,30 @@ func (f *Fosite) NewAuthorizeResponse(ctx context.Context, ar AuthorizeRequester
 	ctx = context.WithValue(ctx, AuthorizeResponseContextKey, resp)
 
 	ar.SetSession(session)
-	for _, h := range f.AuthorizeEndpointHandlers {
+	recordConsent, err := f.handleConsent(ctx, ar, session)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := f.includeGrantedScopes(ctx, ar, session); err != nil {
+		return nil, err
+	}
+
+	if err := f.revokeOfflineAccess(ctx, ar); err != nil {
+		return nil, err
+	}
+
+	grant, err := f.prepareGrant(ctx, ar, session)
+	if err != nil {
+		return nil, err
+	}
+
+	handlers, err := f.authorizeEndpointHandlers(ctx, ar)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, h := range handlers {
 		if err := h.HandleAuthorizeEndpointRequest(ctx, ar, resp); err != nil {
 			return nil, err
 		}
@@ -53,5 +83,29 @@ func (f *Fosite) NewAuthorizeResponse(ctx context.Context, ar AuthorizeRequester
 		return nil, ErrUnsupportedResponseMode.WithHintf("Insecure response_mode '%s' for the response_type '%s'.", ar.GetResponseMode(), ar.GetResponseTypes())
 	}
 
+	// https://openid.net/specs/oauth-v2-jarm.html#section-2.3.1
+	// query.jwt MUST NOT be used in conjunction with response types that contain "token" or "id_token" unless the
+	// response JWT is encrypted.
+	if f.JARMStrategy != nil && ar.GetResponseMode() == ResponseModeQueryJWT && ar.GetDefaultResponseMode() == ResponseModeFragment && !isJARMResponseEncrypted(ar.GetClient()) {
+		return nil, ErrUnsupportedResponseMode.WithHintf("Insecure response_mode '%s' for the response_type '%s'.", ar.GetResponseMode(), ar.GetResponseTypes())
+	}
+
+	if err := f.addSessionState(ctx, ar, session, resp); err != nil {
+		return nil, err
+	}
+
+	if recordConsent {
+		if err := f.ConsentStrategy.RecordConsent(ctx, ar, session.GetSubject()); err != nil {
+			return nil, errorsx.WithStack(ErrServerError.WithWrap(err).WithDebug(err.Error()))
+		}
+	}
+
+	if grant != nil {
+		if err := f.recordGrant(ctx, ar, grant); err != nil {
+			return nil, errorsx.WithStack(ErrServerError.WithWrap(err).WithDebug(err.Error()))
+		}
+	}
+
+	f.GetEventListener().OnAuthorizationGranted(ctx, ar, resp)
 	return resp, nil
 }
@@ -40,7 +40,7 @@ func TestNewAuthorizeResponse(t *testing.T) {
 	ar := NewMockAuthorizeRequester(ctrl)
 	defer ctrl.Finish()
 
-	ctx := context.Background()
+	ctx := WithRequestID(context.Background(), "request-id")
 	oauth2 := &Fosite{
 		AuthorizeEndpointHandlers: AuthorizeEndpointHandlers{handlers[0]},
 	}
@@ -101,7 +101,7 @@ func TestNewAuthorizeResponse(t *testing.T) {
 				ar.EXPECT().GetResponseTypes().Return([]string{"token", "code"})
 			},
 			isErr:     true,
-			expectErr: ErrUnsupportedResponseMode.WithHintf("Insecure response_mode '%s' for the response_type '%s'.", ResponseModeQuery, fosite.Arguments{"token", "code"}),
+			expectErr: ErrUnsupportedResponseMode.WithHintf("Insecure response_mode '%s' for the response_type '%s'.", ResponseModeQuery, fosite.Arguments{"token", "code"}).WithRequestID("request-id"),
 		},
 	} {
 		c.mock()
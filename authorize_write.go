@@ -23,19 +23,30 @@ package fosite
 
 import (
 	"net/http"
+
+	"github.com/ory/x/errorsx"
 )
 
 func (f *Fosite) WriteAuthorizeResponse(rw http.ResponseWriter, ar AuthorizeRequester, resp AuthorizeResponder) {
-	// Set custom headers, e.g. "X-MySuperCoolCustomHeader" or "X-DONT-CACHE-ME"...
+	// Set custom headers and cookies, e.g. "X-MySuperCoolCustomHeader", "Content-Security-Policy" or "Set-Cookie".
+	// All values are copied because headers such as Set-Cookie may not be combined into one.
 	wh := rw.Header()
 	rh := resp.GetHeader()
-	for k := range rh {
-		wh.Set(k, rh.Get(k))
+	for k, vs := range rh {
+		wh.Del(k)
+		for _, v := range vs {
+			wh.Add(k, v)
+		}
 	}
 
 	wh.Set("Cache-Control", "no-store")
 	wh.Set("Pragma", "no-cache")
 
+	// https://tools.ietf.org/html/rfc9207#section-2
+	if issuer := f.authorizeResponseIssuer(ar); issuer != "" {
+		resp.AddParameter("iss", issuer)
+	}
+
 	redir := ar.GetRedirectURI()
 	switch rm := ar.GetResponseMode(); rm {
 	case ResponseModeFormPost:
@@ -60,11 +71,18 @@ func (f *Fosite) WriteAuthorizeResponse(rw http.ResponseWriter, ar AuthorizeRequ
 		URLSetFragment(redir, resp.GetParameters())
 		sendRedirect(redir.String(), rw)
 		return
+	case ResponseModeJWT, ResponseModeQueryJWT, ResponseModeFragmentJWT, ResponseModeFormPostJWT:
+		f.writeJARMResponse(rw, ar, resp.GetParameters())
+		return
 	default:
 		if f.ResponseModeHandler().ResponseModes().Has(rm) {
 			f.ResponseModeHandler().WriteAuthorizeResponse(rw, ar, resp)
 			return
 		}
+
+		// The response mode is neither built-in nor handled by an extension. Writing nothing would leave the
+		// user agent with an empty response, so we explain what went wrong instead.
+		f.writeError(rw, AuthorizeEndpoint, ar, errorsx.WithStack(ErrUnsupportedResponseMode.WithHintf("Unable to write the authorization response using the unsupported response_mode \"%s\".", rm)))
 	}
 }
 
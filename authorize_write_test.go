@@ -23,6 +23,7 @@ package fosite_test
 
 import (
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"testing"
 
@@ -166,6 +167,27 @@ func TestWriteAuthorizeResponse(t *testing.T) {
 				}, header)
 			},
 		},
+		{
+			setup: func() {
+				redir, _ := url.Parse("https://foobar.com/?foo=bar")
+				ar.EXPECT().GetRedirectURI().Return(redir)
+				ar.EXPECT().GetResponseMode().Return(ResponseModeQuery)
+				resp.EXPECT().GetParameters().Return(url.Values{"code": {"poz65kqoneu"}})
+				resp.EXPECT().GetHeader().Return(http.Header{
+					"Set-Cookie":              {"session_state=foo; Path=/; Secure", "other=bar; HttpOnly"},
+					"Content-Security-Policy": {"default-src 'self'"},
+					"Cache-Control":           {"public"},
+				})
+
+				rw.EXPECT().Header().Return(header).Times(2)
+				rw.EXPECT().WriteHeader(http.StatusSeeOther)
+			},
+			expect: func() {
+				assert.Equal(t, []string{"session_state=foo; Path=/; Secure", "other=bar; HttpOnly"}, header["Set-Cookie"])
+				assert.Equal(t, "default-src 'self'", header.Get("Content-Security-Policy"))
+				assert.Equal(t, []string{"no-store"}, header["Cache-Control"])
+			},
+		},
 		{
 			setup: func() {
 				redir, _ := url.Parse("https://foobar.com/?foo=bar")
@@ -190,3 +212,60 @@ func TestWriteAuthorizeResponse(t *testing.T) {
 		t.Logf("Passed test case %d", k)
 	}
 }
+
+type staticResponseModeHandler struct {
+	mode ResponseModeType
+}
+
+func (h *staticResponseModeHandler) ResponseModes() ResponseModeTypes {
+	return ResponseModeTypes{h.mode}
+}
+
+func (h *staticResponseModeHandler) WriteAuthorizeResponse(rw http.ResponseWriter, ar AuthorizeRequester, resp AuthorizeResponder) {
+	rw.Header().Set("X-Response-Mode", string(h.mode))
+	rw.WriteHeader(http.StatusOK)
+}
+
+func (h *staticResponseModeHandler) WriteAuthorizeError(rw http.ResponseWriter, ar AuthorizeRequester, err error) {
+	rw.Header().Set("X-Response-Mode", string(h.mode))
+	rw.WriteHeader(http.StatusBadRequest)
+}
+
+func TestWriteAuthorizeResponseWithCustomResponseModes(t *testing.T) {
+	handlers := ResponseModeHandlers{
+		&staticResponseModeHandler{mode: "web_message"},
+		&staticResponseModeHandler{mode: "post_message"},
+		&staticResponseModeHandler{mode: "web_message"},
+	}
+	assert.Equal(t, ResponseModeTypes{"web_message", "post_message"}, handlers.ResponseModes())
+
+	f := &Fosite{ResponseModeHandlerExtension: handlers}
+	redir, _ := url.Parse("https://foobar.com/cb")
+	for _, tc := range []struct {
+		mode         ResponseModeType
+		expectCode   int
+		expectHeader string
+	}{
+		{mode: "web_message", expectCode: http.StatusOK, expectHeader: "web_message"},
+		{mode: "post_message", expectCode: http.StatusOK, expectHeader: "post_message"},
+		{mode: "unknown", expectCode: http.StatusBadRequest},
+	} {
+		t.Run("mode="+string(tc.mode), func(t *testing.T) {
+			ar := NewAuthorizeRequest()
+			ar.RedirectURI = redir
+			ar.ResponseMode = tc.mode
+
+			rw := httptest.NewRecorder()
+			f.WriteAuthorizeResponse(rw, ar, NewAuthorizeResponse())
+			assert.Equal(t, tc.expectCode, rw.Code)
+			assert.Equal(t, tc.expectHeader, rw.Header().Get("X-Response-Mode"))
+			if tc.expectHeader == "" {
+				assert.Contains(t, rw.Body.String(), ErrUnsupportedResponseMode.ErrorField)
+			}
+
+			rw = httptest.NewRecorder()
+			f.WriteAuthorizeError(rw, ar, ErrAccessDenied)
+			assert.Equal(t, tc.expectHeader, rw.Header().Get("X-Response-Mode"))
+		})
+	}
+}
@@ -0,0 +1,91 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/ory/x/errorsx"
+
+	"github.com/ory/fosite/i18n"
+)
+
+// NewBackchannelAuthenticationRequest implements
+// * https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#auth_request
+//   Client Initiated Backchannel Authentication defines an authentication request that is requested
+//   directly from the Client to the OpenID Provider without going through the user's browser. The
+//   Client MUST authenticate to the Backchannel Authentication Endpoint using the authentication
+//   method registered for its client_id.
+func (f *Fosite) NewBackchannelAuthenticationRequest(ctx context.Context, r *http.Request) (BackchannelAuthenticationRequester, error) {
+	ctx = f.withStoragePolicy(ctx)
+	ctx = ensureRequestID(ctx, r)
+	request, err := f.newBackchannelAuthenticationRequest(ctx, r)
+	return request, attachRequestID(ctx, err)
+}
+
+func (f *Fosite) newBackchannelAuthenticationRequest(ctx context.Context, r *http.Request) (BackchannelAuthenticationRequester, error) {
+	request := NewBackchannelAuthenticationRequest()
+	request.PersistedParameters = f.SanitationWhiteList
+	request.Lang = i18n.GetLangFromRequest(f.MessageCatalog, r)
+
+	ctx = context.WithValue(ctx, RequestContextKey, r)
+	ctx = context.WithValue(ctx, BackchannelRequestContextKey, request)
+
+	if r.Method != "POST" {
+		return request, errorsx.WithStack(ErrInvalidRequest.WithHintf("HTTP method is '%s', expected 'POST'.", r.Method))
+	} else if err := r.ParseMultipartForm(1 << 20); err != nil && err != http.ErrNotMultipart {
+		return request, errorsx.WithStack(ErrInvalidRequest.WithHint("Unable to parse HTTP body, make sure to send a properly formatted form request body.").WithWrap(err).WithDebug(err.Error()))
+	} else if len(r.PostForm) == 0 {
+		return request, errorsx.WithStack(ErrInvalidRequest.WithHint("The POST body can not be empty."))
+	}
+	request.Form = r.PostForm
+
+	if request.Form.Get("request") != "" {
+		return request, errorsx.WithStack(ErrRequestNotSupported.WithHint("Signed backchannel authentication requests are not supported."))
+	}
+
+	client, err := f.AuthenticateClient(ctx, r, r.PostForm)
+	if err != nil {
+		return request, err
+	} else if client.IsPublic() {
+		return request, errorsx.WithStack(ErrUnauthorizedClient.WithHint("Only confidential clients are allowed to use the backchannel authentication endpoint."))
+	}
+	request.Client = client
+
+	scope := RemoveEmpty(strings.Split(request.Form.Get("scope"), " "))
+	for _, permission := range scope {
+		if !ClientScopeStrategy(client, f.ScopeStrategy)(client.GetScopes(), permission) {
+			return request, errorsx.WithStack(ErrInvalidScope.WithHintf("The OAuth 2.0 Client is not allowed to request scope '%s'.", permission))
+		}
+	}
+	request.SetRequestedScopes(scope)
+
+	audience := GetAudiences(request.Form)
+	if err := GetAudienceMatchingStrategy(ctx, client, f.AudienceMatchingStrategy)(client.GetAudience(), audience); err != nil {
+		return request, err
+	}
+	request.SetRequestedAudience(audience)
+
+	return request, nil
+}
@@ -0,0 +1,156 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/handler/ciba"
+	"github.com/ory/fosite/storage"
+)
+
+func newCIBAProvider(store *storage.MemoryStore) *Fosite {
+	store.Clients["ciba-client"] = &ciba.DefaultClient{
+		DefaultClient: &DefaultClient{
+			ID:         "ciba-client",
+			Secret:     []byte(`$2a$10$IxMdI6d.LIRZPpSfEwNoeu4rY3FhDREsxFJXikcgdRRAStxUlsuEO`), // = "foobar"
+			GrantTypes: []string{"urn:openid:params:grant-type:ciba"},
+			Scopes:     []string{"openid", "photos"},
+		},
+		BackchannelTokenDeliveryMode: ciba.DeliveryModePoll,
+	}
+	store.Clients["public"] = &DefaultClient{ID: "public", Public: true, Scopes: []string{"openid"}}
+	return compose.ComposeAllEnabled(&compose.Config{
+		CIBAPollingInterval: time.Second,
+	}, store, []byte("some-secret-thats-random-some-secret-thats-random-"), nil).(*Fosite)
+}
+
+func newBackchannelAuthenticationHTTPRequest(t *testing.T, method string, form url.Values, username, password string) *http.Request {
+	r, err := http.NewRequest(method, "https://www.ory.sh/bc-authorize", strings.NewReader(form.Encode()))
+	require.NoError(t, err)
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if username != "" {
+		r.SetBasicAuth(username, password)
+	}
+	return r
+}
+
+func TestNewBackchannelAuthenticationRequest(t *testing.T) {
+	f := newCIBAProvider(storage.NewExampleStore())
+	valid := url.Values{"scope": {"openid photos"}, "login_hint": {"peter"}}
+
+	for _, c := range []struct {
+		d         string
+		r         *http.Request
+		expectErr error
+	}{
+		{
+			d:         "fails with GET requests",
+			r:         newBackchannelAuthenticationHTTPRequest(t, "GET", valid, "ciba-client", "foobar"),
+			expectErr: ErrInvalidRequest,
+		},
+		{
+			d:         "fails with an empty body",
+			r:         newBackchannelAuthenticationHTTPRequest(t, "POST", url.Values{}, "ciba-client", "foobar"),
+			expectErr: ErrInvalidRequest,
+		},
+		{
+			d:         "fails with signed requests",
+			r:         newBackchannelAuthenticationHTTPRequest(t, "POST", url.Values{"request": {"eyJ..."}}, "ciba-client", "foobar"),
+			expectErr: ErrRequestNotSupported,
+		},
+		{
+			d:         "fails with invalid client credentials",
+			r:         newBackchannelAuthenticationHTTPRequest(t, "POST", valid, "ciba-client", "foobaz"),
+			expectErr: ErrInvalidClient,
+		},
+		{
+			d:         "fails with public clients",
+			r:         newBackchannelAuthenticationHTTPRequest(t, "POST", url.Values{"client_id": {"public"}, "scope": {"openid"}, "login_hint": {"peter"}}, "", ""),
+			expectErr: ErrUnauthorizedClient,
+		},
+		{
+			d:         "fails with scopes the client may not request",
+			r:         newBackchannelAuthenticationHTTPRequest(t, "POST", url.Values{"scope": {"openid offline"}, "login_hint": {"peter"}}, "ciba-client", "foobar"),
+			expectErr: ErrInvalidScope,
+		},
+		{
+			d: "passes",
+			r: newBackchannelAuthenticationHTTPRequest(t, "POST", valid, "ciba-client", "foobar"),
+		},
+	} {
+		t.Run("case="+c.d, func(t *testing.T) {
+			ar, err := f.NewBackchannelAuthenticationRequest(context.Background(), c.r)
+			if c.expectErr != nil {
+				require.Error(t, err)
+				assert.EqualError(t, err, c.expectErr.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "ciba-client", ar.GetClient().GetID())
+			assert.Equal(t, Arguments{"openid", "photos"}, ar.GetRequestedScopes())
+		})
+	}
+}
+
+func TestWriteBackchannelAuthenticationResponse(t *testing.T) {
+	f := newCIBAProvider(storage.NewExampleStore())
+	r := newBackchannelAuthenticationHTTPRequest(t, "POST", url.Values{"scope": {"openid"}, "login_hint": {"peter"}}, "ciba-client", "foobar")
+
+	ar, err := f.NewBackchannelAuthenticationRequest(context.Background(), r)
+	require.NoError(t, err)
+	resp, err := f.NewBackchannelAuthenticationResponse(context.Background(), ar, new(DefaultSession))
+	require.NoError(t, err)
+
+	rw := httptest.NewRecorder()
+	f.WriteBackchannelAuthenticationResponse(rw, ar, resp)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "no-store", rw.Header().Get("Cache-Control"))
+
+	var body map[string]interface{}
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&body))
+	assert.Equal(t, resp.GetAuthRequestID(), body["auth_req_id"])
+	assert.EqualValues(t, 1, body["interval"])
+	assert.NotZero(t, body["expires_in"])
+
+	t.Run("case=writes errors", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		f.WriteBackchannelAuthenticationError(rw, ar, ErrUnknownUserID)
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(rw.Body).Decode(&body))
+		assert.Equal(t, "unknown_user_id", body["error"])
+	})
+}
@@ -0,0 +1,88 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"net/http"
+	"time"
+)
+
+// BackchannelAuthenticationResponse is an implementation of BackchannelAuthenticationResponder
+type BackchannelAuthenticationResponse struct {
+	Header        http.Header
+	AuthRequestID string
+	ExpiresIn     time.Duration
+	Interval      time.Duration
+}
+
+func NewBackchannelAuthenticationResponse() *BackchannelAuthenticationResponse {
+	return &BackchannelAuthenticationResponse{
+		Header: http.Header{},
+	}
+}
+
+func (b *BackchannelAuthenticationResponse) GetAuthRequestID() string {
+	return b.AuthRequestID
+}
+
+func (b *BackchannelAuthenticationResponse) SetAuthRequestID(id string) {
+	b.AuthRequestID = id
+}
+
+func (b *BackchannelAuthenticationResponse) GetExpiresIn() time.Duration {
+	return b.ExpiresIn
+}
+
+func (b *BackchannelAuthenticationResponse) SetExpiresIn(expiresIn time.Duration) {
+	b.ExpiresIn = expiresIn
+}
+
+func (b *BackchannelAuthenticationResponse) GetInterval() time.Duration {
+	return b.Interval
+}
+
+func (b *BackchannelAuthenticationResponse) SetInterval(interval time.Duration) {
+	b.Interval = interval
+}
+
+func (b *BackchannelAuthenticationResponse) GetHeader() http.Header {
+	return b.Header
+}
+
+func (b *BackchannelAuthenticationResponse) AddHeader(key, value string) {
+	b.Header.Add(key, value)
+}
+
+// ToMap converts the response to a map as defined in
+// https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#auth_ack
+func (b *BackchannelAuthenticationResponse) ToMap() map[string]interface{} {
+	res := map[string]interface{}{
+		"auth_req_id": b.AuthRequestID,
+		"expires_in":  int64(b.ExpiresIn / time.Second),
+	}
+
+	if b.Interval > 0 {
+		res["interval"] = int64(b.Interval / time.Second)
+	}
+
+	return res
+}
@@ -0,0 +1,58 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+
+	"github.com/ory/x/errorsx"
+)
+
+// NewBackchannelAuthenticationResponse iterates through all BackchannelAuthenticationEndpointHandlers and returns
+// the backchannel authentication response. The session is stored alongside the auth_req_id and can be amended once
+// the end user has been authenticated.
+func (f *Fosite) NewBackchannelAuthenticationResponse(ctx context.Context, requester BackchannelAuthenticationRequester, session Session) (BackchannelAuthenticationResponder, error) {
+	ctx = f.withStoragePolicy(ctx)
+	ctx = ensureRequestID(ctx, nil)
+	response, err := f.newBackchannelAuthenticationResponse(ctx, requester, session)
+	return response, attachRequestID(ctx, err)
+}
+
+func (f *Fosite) newBackchannelAuthenticationResponse(ctx context.Context, requester BackchannelAuthenticationRequester, session Session) (BackchannelAuthenticationResponder, error) {
+	response := NewBackchannelAuthenticationResponse()
+
+	ctx = context.WithValue(ctx, BackchannelRequestContextKey, requester)
+	ctx = context.WithValue(ctx, BackchannelResponseContextKey, response)
+
+	requester.SetSession(session)
+	for _, h := range f.BackchannelAuthenticationEndpointHandlers {
+		if err := h.HandleBackchannelAuthenticationEndpointRequest(ctx, requester, response); err != nil {
+			return nil, err
+		}
+	}
+
+	if response.GetAuthRequestID() == "" {
+		return nil, errorsx.WithStack(ErrServerError.WithHint("An internal server occurred while trying to complete the request.").WithDebug("The auth_req_id was not set by BackchannelAuthenticationEndpointHandlers.").WithLocalizer(f.MessageCatalog, getLangFromRequester(requester)))
+	}
+
+	return response, nil
+}
@@ -0,0 +1,57 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// WriteBackchannelAuthenticationError writes an error response as defined in
+// https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#auth_error_response
+func (f *Fosite) WriteBackchannelAuthenticationError(rw http.ResponseWriter, requester BackchannelAuthenticationRequester, err error) {
+	f.writeError(rw, BackchannelAuthenticationEndpoint, requester, err)
+}
+
+// WriteBackchannelAuthenticationResponse writes the backchannel authentication response as defined in
+// https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#auth_ack
+func (f *Fosite) WriteBackchannelAuthenticationResponse(rw http.ResponseWriter, requester BackchannelAuthenticationRequester, responder BackchannelAuthenticationResponder) {
+	// Set custom headers, e.g. "X-MySuperCoolCustomHeader" or "X-DONT-CACHE-ME"...
+	wh := rw.Header()
+	rh := responder.GetHeader()
+	for k := range rh {
+		wh.Set(k, rh.Get(k))
+	}
+
+	wh.Set("Cache-Control", "no-store")
+	wh.Set("Pragma", "no-cache")
+
+	js, err := json.Marshal(responder.ToMap())
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	wh.Set("Content-Type", "application/json;charset=UTF-8")
+	rw.WriteHeader(http.StatusOK)
+	_, _ = rw.Write(js)
+}
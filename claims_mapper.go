@@ -0,0 +1,58 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+
+	"github.com/ory/fosite/token/jwt"
+)
+
+// ClaimsMapper adds, removes or transforms the claims of a token right before it is signed. The mapper receives
+// the requester, which exposes the client, the granted scopes and audience, and the session.
+//
+// Mappers are applied by the JWT access token strategy (tokenType AccessToken) and the OpenID Connect ID token
+// strategy (tokenType IDToken). Claims removed by a mapper are not restored, so take care when removing claims
+// such as "exp" or "iss" which are required by the specifications.
+type ClaimsMapper interface {
+	// MapClaims modifies the claims in place. Returning an error aborts the token issuance.
+	MapClaims(ctx context.Context, tokenType TokenType, requester Requester, claims jwt.MapClaims) error
+}
+
+// ClaimsMapperFunc is an adapter to allow the use of ordinary functions as ClaimsMapper.
+type ClaimsMapperFunc func(ctx context.Context, tokenType TokenType, requester Requester, claims jwt.MapClaims) error
+
+func (f ClaimsMapperFunc) MapClaims(ctx context.Context, tokenType TokenType, requester Requester, claims jwt.MapClaims) error {
+	return f(ctx, tokenType, requester, claims)
+}
+
+// ClaimsMappers applies several claims mappers in order and stops at the first error.
+type ClaimsMappers []ClaimsMapper
+
+func (ms ClaimsMappers) MapClaims(ctx context.Context, tokenType TokenType, requester Requester, claims jwt.MapClaims) error {
+	for _, m := range ms {
+		if err := m.MapClaims(ctx, tokenType, requester, claims); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/ory/x/errorsx"
+)
+
+// ClaimsRequest is the value of the claims request parameter, which requests individual claims to be returned in
+// the ID token or the UserInfo response, see https://openid.net/specs/openid-connect-core-1_0.html#ClaimsParameter
+type ClaimsRequest struct {
+	UserInfo ClaimRequests `json:"userinfo,omitempty"`
+	IDToken  ClaimRequests `json:"id_token,omitempty"`
+}
+
+// ClaimRequests maps claim names to the way they are requested. A nil ClaimRequest requests the claim in the
+// default manner, i.e. as a voluntary claim.
+type ClaimRequests map[string]*ClaimRequest
+
+// ClaimRequest specifies how an individual claim is requested.
+type ClaimRequest struct {
+	// Essential indicates whether the claim is necessary to ensure a smooth authorization experience for the
+	// specific task requested by the end-user.
+	Essential bool `json:"essential,omitempty"`
+
+	// Value requests the claim to be returned with this particular value.
+	Value interface{} `json:"value,omitempty"`
+
+	// Values requests the claim to be returned with one of these values, in order of preference.
+	Values []interface{} `json:"values,omitempty"`
+}
+
+// IsEssential returns true if the claim is requested as an essential claim.
+func (r *ClaimRequest) IsEssential() bool {
+	return r != nil && r.Essential
+}
+
+// Names returns the names of all requested claims, sorted alphabetically.
+func (rs ClaimRequests) Names() []string {
+	names := make([]string, 0, len(rs))
+	for name := range rs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Essential returns the names of the claims requested as essential claims, sorted alphabetically.
+func (rs ClaimRequests) Essential() []string {
+	var names []string
+	for _, name := range rs.Names() {
+		if rs[name].IsEssential() {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Voluntary returns the names of the claims requested as voluntary claims, sorted alphabetically.
+func (rs ClaimRequests) Voluntary() []string {
+	var names []string
+	for _, name := range rs.Names() {
+		if !rs[name].IsEssential() {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Has returns true if the claim is requested.
+func (rs ClaimRequests) Has(name string) bool {
+	_, ok := rs[name]
+	return ok
+}
+
+// ParseClaimsRequest parses and validates the value of the claims request parameter. It returns nil if the value
+// is empty. Members other than userinfo and id_token are ignored as required by the specification.
+func ParseClaimsRequest(raw string) (*ClaimsRequest, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	var request ClaimsRequest
+	if err := json.Unmarshal([]byte(raw), &request); err != nil {
+		return nil, errorsx.WithStack(ErrInvalidRequest.WithHint("Unable to parse the 'claims' parameter, it must be a JSON object.").WithWrap(err).WithDebug(err.Error()))
+	}
+
+	for _, rs := range []ClaimRequests{request.UserInfo, request.IDToken} {
+		for name, r := range rs {
+			if r != nil && r.Value != nil && len(r.Values) > 0 {
+				return nil, errorsx.WithStack(ErrInvalidRequest.WithHintf("The claim '%s' of the 'claims' parameter must not contain both 'value' and 'values'.", name))
+			}
+		}
+	}
+
+	return &request, nil
+}
+
+// ClaimsRequestFromRequester returns the claims requested by the claims parameter of the request. Authorization
+// requests expose the parsed parameter, other requests are only considered if the claims parameter is still part
+// of their request form, e.g. because it was added to the sanitation white list.
+func ClaimsRequestFromRequester(requester Requester) *ClaimsRequest {
+	if ar, ok := requester.(AuthorizeRequester); ok {
+		return ar.GetRequestedClaims()
+	}
+
+	request, _ := ParseClaimsRequest(requester.GetRequestForm().Get("claims"))
+	return request
+}
+
+func (f *Fosite) validateAuthorizeClaims(request *AuthorizeRequest) error {
+	claims, err := ParseClaimsRequest(request.Form.Get("claims"))
+	if err != nil {
+		return err
+	}
+
+	request.RequestedClaims = claims
+	return nil
+}
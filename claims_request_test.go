@@ -0,0 +1,118 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/storage"
+)
+
+func TestParseClaimsRequest(t *testing.T) {
+	for k, c := range []struct {
+		raw       string
+		expectErr error
+		expect    *ClaimsRequest
+	}{
+		{raw: ""},
+		{raw: "not-json", expectErr: ErrInvalidRequest},
+		{raw: `{"id_token":[]}`, expectErr: ErrInvalidRequest},
+		{raw: `{"userinfo":{"acr":{"value":"a","values":["b"]}}}`, expectErr: ErrInvalidRequest},
+		{
+			raw: `{"userinfo":{"given_name":{"essential":true},"email":null},"id_token":{"auth_time":{"essential":true},"acr":{"values":["urn:mace:incommon:iap:silver"]}},"unknown":{}}`,
+			expect: &ClaimsRequest{
+				UserInfo: ClaimRequests{
+					"given_name": {Essential: true},
+					"email":      nil,
+				},
+				IDToken: ClaimRequests{
+					"auth_time": {Essential: true},
+					"acr":       {Values: []interface{}{"urn:mace:incommon:iap:silver"}},
+				},
+			},
+		},
+	} {
+		request, err := ParseClaimsRequest(c.raw)
+		if c.expectErr != nil {
+			assert.ErrorIs(t, err, c.expectErr, "case %d", k)
+			continue
+		}
+		require.NoError(t, err, "case %d", k)
+		assert.Equal(t, c.expect, request, "case %d", k)
+	}
+}
+
+func TestClaimRequests(t *testing.T) {
+	requests := ClaimRequests{
+		"given_name": {Essential: true},
+		"email":      nil,
+		"nickname":   {Essential: false},
+		"auth_time":  {Essential: true},
+	}
+	assert.Equal(t, []string{"auth_time", "email", "given_name", "nickname"}, requests.Names())
+	assert.Equal(t, []string{"auth_time", "given_name"}, requests.Essential())
+	assert.Equal(t, []string{"email", "nickname"}, requests.Voluntary())
+	assert.True(t, requests.Has("email"))
+	assert.False(t, requests.Has("picture"))
+}
+
+func TestAuthorizeRequestClaimsParameter(t *testing.T) {
+	ctx := context.Background()
+	f := compose.ComposeAllEnabled(&compose.Config{}, storage.NewExampleStore(), []byte("some-secret-thats-random-some-secret-thats-random-"), nil).(*Fosite)
+
+	authorize := func(t *testing.T, claims string) (AuthorizeRequester, error) {
+		r, err := http.NewRequest("GET", "https://www.ory.sh/auth?"+url.Values{
+			"response_type": {"code"},
+			"client_id":     {"my-client"},
+			"redirect_uri":  {"http://localhost:3846/callback"},
+			"scope":         {"openid"},
+			"state":         {"some-random-state"},
+			"claims":        {claims},
+		}.Encode(), nil)
+		require.NoError(t, err)
+		return f.NewAuthorizeRequest(ctx, r)
+	}
+
+	_, err := authorize(t, `{"id_token":"email"}`)
+	assert.ErrorIs(t, err, ErrInvalidRequest)
+
+	ar, err := authorize(t, `{"id_token":{"email":{"essential":true}},"userinfo":{"picture":null}}`)
+	require.NoError(t, err)
+	require.NotNil(t, ar.GetRequestedClaims())
+	assert.Equal(t, []string{"email"}, ar.GetRequestedClaims().IDToken.Essential())
+	assert.Equal(t, []string{"picture"}, ar.GetRequestedClaims().UserInfo.Voluntary())
+	assert.Equal(t, ar.GetRequestedClaims(), ClaimsRequestFromRequester(ar))
+
+	ar, err = authorize(t, "")
+	require.NoError(t, err)
+	assert.Nil(t, ar.GetRequestedClaims())
+
+	assert.True(t, f.NewDiscoveryMetadata(ctx, DiscoveryConfiguration{}).ClaimsParameterSupported)
+}
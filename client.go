@@ -21,7 +21,11 @@
 
 package fosite
 
-import jose "gopkg.in/square/go-jose.v2"
+import (
+	"time"
+
+	jose "gopkg.in/square/go-jose.v2"
+)
 
 // Client represents a client or an app.
 type Client interface {
@@ -59,6 +63,23 @@ type ClientWithSecretRotation interface {
 	GetRotatedHashes() [][]byte
 }
 
+// ClientWithPlaintextSecret extends Client interface by a method providing the secret in plain text, which is
+// required for verifying client assertions of the client_secret_jwt authentication method.
+type ClientWithPlaintextSecret interface {
+	Client
+	// GetPlaintextSecret returns the unhashed client secret.
+	GetPlaintextSecret() []byte
+}
+
+// TokenEndpointAuthMethodClient extends Client interface by the client authentication method it registered, see
+// https://openid.net/specs/openid-connect-registration-1_0.html#ClientMetadata. Clients implementing this
+// interface, which includes OpenIDConnectClient, must authenticate using exactly that method.
+type TokenEndpointAuthMethodClient interface {
+	Client
+	// GetTokenEndpointAuthMethod returns the requested Client Authentication method for the Token Endpoint.
+	GetTokenEndpointAuthMethod() string
+}
+
 // OpenIDConnectClient represents a client capable of performing OpenID Connect requests.
 type OpenIDConnectClient interface {
 	// GetRequestURIs is an array of request_uri values that are pre-registered by the RP for use at the OP. Servers MAY
@@ -79,7 +100,7 @@ type OpenIDConnectClient interface {
 	GetRequestObjectSigningAlgorithm() string
 
 	// Requested Client Authentication method for the Token Endpoint. The options are client_secret_post,
-	// client_secret_basic, client_secret_jwt, private_key_jwt, and none.
+	// client_secret_basic, client_secret_jwt, private_key_jwt, tls_client_auth, self_signed_tls_client_auth, and none.
 	GetTokenEndpointAuthMethod() string
 
 	// JWS [JWS] alg algorithm [JWA] that MUST be used for signing the JWT [JWT] used to authenticate the
@@ -87,12 +108,124 @@ type OpenIDConnectClient interface {
 	GetTokenEndpointAuthSigningAlgorithm() string
 }
 
+// TLSClientAuthClient represents a client capable of authenticating using the tls_client_auth method, see
+// https://tools.ietf.org/html/rfc8705#section-2.1.2. Exactly one of the values should be set.
+type TLSClientAuthClient interface {
+	// GetTLSClientAuthSubjectDN returns the expected subject distinguished name of the client certificate.
+	GetTLSClientAuthSubjectDN() string
+
+	// GetTLSClientAuthSANDNS returns the expected dNSName SAN entry of the client certificate.
+	GetTLSClientAuthSANDNS() string
+
+	// GetTLSClientAuthSANURI returns the expected uniformResourceIdentifier SAN entry of the client certificate.
+	GetTLSClientAuthSANURI() string
+
+	// GetTLSClientAuthSANIP returns the expected iPAddress SAN entry of the client certificate.
+	GetTLSClientAuthSANIP() string
+
+	// GetTLSClientAuthSANEmail returns the expected rfc822Name SAN entry of the client certificate.
+	GetTLSClientAuthSANEmail() string
+}
+
+// IDTokenEncryptionClient represents a client which registered that ID tokens must be encrypted, see
+// https://openid.net/specs/openid-connect-registration-1_0.html#ClientMetadata
+type IDTokenEncryptionClient interface {
+	// GetIDTokenEncryptedResponseAlg returns the JWE alg used for encrypting ID tokens. ID tokens are not encrypted
+	// if empty.
+	GetIDTokenEncryptedResponseAlg() string
+
+	// GetIDTokenEncryptedResponseEnc returns the JWE enc used for encrypting ID tokens.
+	GetIDTokenEncryptedResponseEnc() string
+}
+
+// IDTokenSigningClient represents a client which registered the JWS alg ID tokens must be signed with, see
+// https://openid.net/specs/openid-connect-registration-1_0.html#ClientMetadata
+type IDTokenSigningClient interface {
+	// GetIDTokenSignedResponseAlg returns the JWS alg used for signing ID tokens. The provider's default is used
+	// if empty.
+	GetIDTokenSignedResponseAlg() string
+}
+
+// UserInfoSigningClient represents a client which registered the JWS alg UserInfo responses must be signed with, see
+// https://openid.net/specs/openid-connect-registration-1_0.html#ClientMetadata
+type UserInfoSigningClient interface {
+	// GetUserInfoSignedResponseAlg returns the JWS alg used for signing UserInfo responses. The response is plain
+	// JSON if empty.
+	GetUserInfoSignedResponseAlg() string
+}
+
+// SubjectTypeClient represents a client which registered the subject identifier type of the "sub" claims it
+// receives, see https://openid.net/specs/openid-connect-core-1_0.html#SubjectIDTypes
+type SubjectTypeClient interface {
+	// GetSubjectType returns the subject identifier type, e.g. "public" or "pairwise". The provider's default is
+	// used if empty.
+	GetSubjectType() string
+
+	// GetSectorIdentifierURI returns the sector_identifier_uri whose host is used as the sector identifier of
+	// pairwise subject identifiers.
+	GetSectorIdentifierURI() string
+}
+
+// ScopeStrategyClient represents a client which selects the scope strategy its requested scopes are matched with,
+// e.g. legacy clients of mixed-tenant deployments.
+type ScopeStrategyClient interface {
+	// GetScopeStrategy returns the name of the scope strategy in ScopeStrategies, e.g. ScopeStrategyExact. The
+	// provider's scope strategy is used if empty.
+	GetScopeStrategy() string
+}
+
+// AudienceMatchingStrategyClient represents a client which selects the audience matching strategy its requested
+// audiences are matched with.
+type AudienceMatchingStrategyClient interface {
+	// GetAudienceMatchingStrategy returns the name of the audience matching strategy in AudienceMatchingStrategies,
+	// e.g. AudienceMatchingStrategyExact. The provider's audience matching strategy is used if empty.
+	GetAudienceMatchingStrategy() string
+}
+
+// RefreshTokenLifespanClient represents a client which overrides the provider's refresh token lifespans.
+type RefreshTokenLifespanClient interface {
+	// GetRefreshTokenLifespan returns the lifespan of a refresh token, which is renewed each time the refresh
+	// token is used. The provider's default is used if zero.
+	GetRefreshTokenLifespan() time.Duration
+
+	// GetRefreshTokenMaxLifespan returns the maximum lifetime of the refresh tokens issued for a grant, regardless
+	// of how often they are used. The provider's default is used if zero.
+	GetRefreshTokenMaxLifespan() time.Duration
+}
+
 // ResponseModeClient represents a client capable of handling response_mode
 type ResponseModeClient interface {
 	// GetResponseMode returns the response modes that client is allowed to send
 	GetResponseModes() []ResponseModeType
 }
 
+// PostLogoutRedirectURIClient is a client which registered the URIs the end-user may be redirected to after logging
+// out, see https://openid.net/specs/openid-connect-rpinitiated-1_0.html#ClientMetadata
+type PostLogoutRedirectURIClient interface {
+	// GetPostLogoutRedirectURIs returns the client's allowed post logout redirect URIs.
+	GetPostLogoutRedirectURIs() []string
+}
+
+// BackChannelLogoutClient is a client which registered a back-channel logout URI, see
+// https://openid.net/specs/openid-connect-backchannel-1_0.html#BCRegistration
+type BackChannelLogoutClient interface {
+	// GetBackChannelLogoutURI returns the URI logout tokens are sent to. Clients without a URI are not notified.
+	GetBackChannelLogoutURI() string
+
+	// GetBackChannelLogoutSessionRequired returns true if the client requires the "sid" claim in logout tokens.
+	GetBackChannelLogoutSessionRequired() bool
+}
+
+// PKCEClient is a client which declares its own PKCE policy in addition to the policy of the PKCE handler.
+type PKCEClient interface {
+	// RequirePKCE returns true if the client must use PKCE with the authorize code flow.
+	RequirePKCE() bool
+
+	// RejectPlainPKCEChallengeMethod returns true if the client must use the S256 code challenge method, even if
+	// the plain method is enabled.
+	RejectPlainPKCEChallengeMethod() bool
+}
+
 // DefaultClient is a simple default implementation of the Client interface.
 type DefaultClient struct {
 	ID             string   `json:"id"`
@@ -114,6 +247,33 @@ type DefaultOpenIDConnectClient struct {
 	RequestURIs                       []string            `json:"request_uris"`
 	RequestObjectSigningAlgorithm     string              `json:"request_object_signing_alg"`
 	TokenEndpointAuthSigningAlgorithm string              `json:"token_endpoint_auth_signing_alg"`
+	TLSClientAuthSubjectDN            string              `json:"tls_client_auth_subject_dn,omitempty"`
+	TLSClientAuthSANDNS               string              `json:"tls_client_auth_san_dns,omitempty"`
+	TLSClientAuthSANURI               string              `json:"tls_client_auth_san_uri,omitempty"`
+	TLSClientAuthSANIP                string              `json:"tls_client_auth_san_ip,omitempty"`
+	TLSClientAuthSANEmail             string              `json:"tls_client_auth_san_email,omitempty"`
+	AuthorizationSignedResponseAlg    string              `json:"authorization_signed_response_alg,omitempty"`
+	AuthorizationEncryptedResponseAlg string              `json:"authorization_encrypted_response_alg,omitempty"`
+	AuthorizationEncryptedResponseEnc string              `json:"authorization_encrypted_response_enc,omitempty"`
+	IDTokenSignedResponseAlg          string              `json:"id_token_signed_response_alg,omitempty"`
+	IDTokenEncryptedResponseAlg       string              `json:"id_token_encrypted_response_alg,omitempty"`
+	IDTokenEncryptedResponseEnc       string              `json:"id_token_encrypted_response_enc,omitempty"`
+	AuthorizationDetailsTypes         []string            `json:"authorization_details_types,omitempty"`
+	IntrospectionSignedResponseAlg    string              `json:"introspection_signed_response_alg,omitempty"`
+	IntrospectionEncryptedResponseAlg string              `json:"introspection_encrypted_response_alg,omitempty"`
+	IntrospectionEncryptedResponseEnc string              `json:"introspection_encrypted_response_enc,omitempty"`
+	PostLogoutRedirectURIs            []string            `json:"post_logout_redirect_uris,omitempty"`
+	BackChannelLogoutURI              string              `json:"backchannel_logout_uri,omitempty"`
+	BackChannelLogoutSessionRequired  bool                `json:"backchannel_logout_session_required,omitempty"`
+	FrontChannelLogoutURI             string              `json:"frontchannel_logout_uri,omitempty"`
+	FrontChannelLogoutSessionRequired bool                `json:"frontchannel_logout_session_required,omitempty"`
+	PKCERequired                      bool                `json:"require_pkce,omitempty"`
+	PKCEPlainChallengeMethodRejected  bool                `json:"reject_pkce_plain_challenge_method,omitempty"`
+	UserInfoSignedResponseAlg         string              `json:"userinfo_signed_response_alg,omitempty"`
+	SubjectType                       string              `json:"subject_type,omitempty"`
+	SectorIdentifierURI               string              `json:"sector_identifier_uri,omitempty"`
+	ScopeStrategy                     string              `json:"scope_strategy,omitempty"`
+	AudienceMatchingStrategy          string              `json:"audience_matching_strategy,omitempty"`
 }
 
 type DefaultResponseModeClient struct {
@@ -182,7 +342,9 @@ func (c *DefaultOpenIDConnectClient) GetJSONWebKeys() *jose.JSONWebKeySet {
 }
 
 func (c *DefaultOpenIDConnectClient) GetTokenEndpointAuthSigningAlgorithm() string {
-	if c.TokenEndpointAuthSigningAlgorithm == "" {
+	if c.TokenEndpointAuthSigningAlgorithm == "" && c.TokenEndpointAuthMethod == "client_secret_jwt" {
+		return "HS256"
+	} else if c.TokenEndpointAuthSigningAlgorithm == "" {
 		return "RS256"
 	} else {
 		return c.TokenEndpointAuthSigningAlgorithm
@@ -201,6 +363,114 @@ func (c *DefaultOpenIDConnectClient) GetRequestURIs() []string {
 	return c.RequestURIs
 }
 
+func (c *DefaultOpenIDConnectClient) GetTLSClientAuthSubjectDN() string {
+	return c.TLSClientAuthSubjectDN
+}
+
+func (c *DefaultOpenIDConnectClient) GetTLSClientAuthSANDNS() string {
+	return c.TLSClientAuthSANDNS
+}
+
+func (c *DefaultOpenIDConnectClient) GetTLSClientAuthSANURI() string {
+	return c.TLSClientAuthSANURI
+}
+
+func (c *DefaultOpenIDConnectClient) GetTLSClientAuthSANIP() string {
+	return c.TLSClientAuthSANIP
+}
+
+func (c *DefaultOpenIDConnectClient) GetTLSClientAuthSANEmail() string {
+	return c.TLSClientAuthSANEmail
+}
+
+func (c *DefaultOpenIDConnectClient) GetAuthorizationSignedResponseAlg() string {
+	return c.AuthorizationSignedResponseAlg
+}
+
+func (c *DefaultOpenIDConnectClient) GetAuthorizationEncryptedResponseAlg() string {
+	return c.AuthorizationEncryptedResponseAlg
+}
+
+func (c *DefaultOpenIDConnectClient) GetAuthorizationEncryptedResponseEnc() string {
+	return c.AuthorizationEncryptedResponseEnc
+}
+
+func (c *DefaultOpenIDConnectClient) GetIDTokenSignedResponseAlg() string {
+	return c.IDTokenSignedResponseAlg
+}
+
+func (c *DefaultOpenIDConnectClient) GetAuthorizationDetailsTypes() []string {
+	return c.AuthorizationDetailsTypes
+}
+
+func (c *DefaultOpenIDConnectClient) GetIntrospectionSignedResponseAlg() string {
+	return c.IntrospectionSignedResponseAlg
+}
+
+func (c *DefaultOpenIDConnectClient) GetIntrospectionEncryptedResponseAlg() string {
+	return c.IntrospectionEncryptedResponseAlg
+}
+
+func (c *DefaultOpenIDConnectClient) GetIntrospectionEncryptedResponseEnc() string {
+	return c.IntrospectionEncryptedResponseEnc
+}
+
+func (c *DefaultOpenIDConnectClient) GetIDTokenEncryptedResponseAlg() string {
+	return c.IDTokenEncryptedResponseAlg
+}
+
+func (c *DefaultOpenIDConnectClient) GetIDTokenEncryptedResponseEnc() string {
+	return c.IDTokenEncryptedResponseEnc
+}
+
+func (c *DefaultOpenIDConnectClient) GetPostLogoutRedirectURIs() []string {
+	return c.PostLogoutRedirectURIs
+}
+
+func (c *DefaultOpenIDConnectClient) GetBackChannelLogoutURI() string {
+	return c.BackChannelLogoutURI
+}
+
+func (c *DefaultOpenIDConnectClient) GetBackChannelLogoutSessionRequired() bool {
+	return c.BackChannelLogoutSessionRequired
+}
+
+func (c *DefaultOpenIDConnectClient) GetFrontChannelLogoutURI() string {
+	return c.FrontChannelLogoutURI
+}
+
+func (c *DefaultOpenIDConnectClient) GetFrontChannelLogoutSessionRequired() bool {
+	return c.FrontChannelLogoutSessionRequired
+}
+
+func (c *DefaultOpenIDConnectClient) RequirePKCE() bool {
+	return c.PKCERequired
+}
+
+func (c *DefaultOpenIDConnectClient) RejectPlainPKCEChallengeMethod() bool {
+	return c.PKCEPlainChallengeMethodRejected
+}
+
+func (c *DefaultOpenIDConnectClient) GetUserInfoSignedResponseAlg() string {
+	return c.UserInfoSignedResponseAlg
+}
+
+func (c *DefaultOpenIDConnectClient) GetSubjectType() string {
+	return c.SubjectType
+}
+
+func (c *DefaultOpenIDConnectClient) GetSectorIdentifierURI() string {
+	return c.SectorIdentifierURI
+}
+
+func (c *DefaultOpenIDConnectClient) GetScopeStrategy() string {
+	return c.ScopeStrategy
+}
+
+func (c *DefaultOpenIDConnectClient) GetAudienceMatchingStrategy() string {
+	return c.AudienceMatchingStrategy
+}
+
 func (c *DefaultResponseModeClient) GetResponseModes() []ResponseModeType {
 	return c.ResponseModes
 }
@@ -25,11 +25,8 @@ import (
 	"context"
 	"crypto/ecdsa"
 	"crypto/rsa"
-	"encoding/json"
-	"fmt"
 	"net/http"
 	"net/url"
-	"time"
 
 	"github.com/ory/x/errorsx"
 
@@ -41,14 +38,44 @@ import (
 // ClientAuthenticationStrategy provides a method signature for authenticating a client request
 type ClientAuthenticationStrategy func(context.Context, *http.Request, url.Values) (Client, error)
 
+// ClientAuthenticationHandler authenticates clients using a single client authentication method, such as
+// client_secret_basic or private_key_jwt.
+type ClientAuthenticationHandler interface {
+	// AuthenticateClient authenticates the client making the request. It returns ErrUnknownRequest if the request
+	// does not use the handler's authentication method, in which case the next handler is tried.
+	AuthenticateClient(ctx context.Context, f *Fosite, r *http.Request, form url.Values) (Client, error)
+}
+
 const clientAssertionJWTBearerType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
 
-func (f *Fosite) findClientPublicJWK(oidcClient OpenIDConnectClient, t *jwt.Token, expectsRSAKey bool) (interface{}, error) {
+func (f *Fosite) findClientPublicJWK(ctx context.Context, oidcClient OpenIDConnectClient, t *jwt.Token, expectsRSAKey bool) (interface{}, error) {
 	if set := oidcClient.GetJSONWebKeys(); set != nil {
 		return findPublicKey(t, set, expectsRSAKey)
 	}
 
-	if location := oidcClient.GetJSONWebKeysURI(); len(location) > 0 {
+	if location := oidcClient.GetJSONWebKeysURI(); len(location) > 0 && f.JSONWebKeyCache != nil {
+		fetch := func(ctx context.Context) (*jose.JSONWebKeySet, error) {
+			return f.JWKSFetcherStrategy.Resolve(location, true)
+		}
+
+		keys, err := f.JSONWebKeyCache.Resolve(ctx, location, fetch)
+		if err != nil {
+			return nil, err
+		}
+
+		if key, err := findPublicKey(t, keys, expectsRSAKey); err == nil {
+			return key, nil
+		}
+
+		// The keys might have been rotated since they were cached.
+		f.JSONWebKeyCache.Invalidate(ctx, location)
+		keys, err = f.JSONWebKeyCache.Resolve(ctx, location, fetch)
+		if err != nil {
+			return nil, err
+		}
+
+		return findPublicKey(t, keys, expectsRSAKey)
+	} else if len(location) > 0 {
 		keys, err := f.JWKSFetcherStrategy.Resolve(location, false)
 		if err != nil {
 			return nil, err
@@ -72,182 +99,58 @@ func (f *Fosite) findClientPublicJWK(oidcClient OpenIDConnectClient, t *jwt.Toke
 // AuthenticateClient authenticates client requests using the configured strategy
 // `Fosite.ClientAuthenticationStrategy`, if nil it uses `Fosite.DefaultClientAuthenticationStrategy`
 func (f *Fosite) AuthenticateClient(ctx context.Context, r *http.Request, form url.Values) (Client, error) {
-	if f.ClientAuthenticationStrategy == nil {
-		return f.DefaultClientAuthenticationStrategy(ctx, r, form)
+	strategy := f.ClientAuthenticationStrategy
+	if strategy == nil {
+		strategy = f.DefaultClientAuthenticationStrategy
 	}
-	return f.ClientAuthenticationStrategy(ctx, r, form)
+
+	client, err := strategy(ctx, r, form)
+	if err != nil {
+		f.GetEventListener().OnClientAuthenticationFailed(ctx, r, err)
+		return nil, err
+	}
+	return client, nil
 }
 
-// DefaultClientAuthenticationStrategy provides the fosite's default client authentication strategy,
-// HTTP Basic Authentication and JWT Bearer
+// DefaultClientAuthenticationStrategy provides the fosite's default client authentication strategy. It tries the
+// handlers of `Fosite.ClientAuthenticationHandlers` in order until one of them handles the request. If no handlers
+// are configured, DefaultClientAuthenticationHandlers is used.
 func (f *Fosite) DefaultClientAuthenticationStrategy(ctx context.Context, r *http.Request, form url.Values) (Client, error) {
-	if assertionType := form.Get("client_assertion_type"); assertionType == clientAssertionJWTBearerType {
-		assertion := form.Get("client_assertion")
-		if len(assertion) == 0 {
-			return nil, errorsx.WithStack(ErrInvalidRequest.WithHintf("The client_assertion request parameter must be set when using client_assertion_type of '%s'.", clientAssertionJWTBearerType))
-		}
-
-		var clientID string
-		var client Client
-
-		token, err := jwt.ParseWithClaims(assertion, jwt.MapClaims{}, func(t *jwt.Token) (interface{}, error) {
-			var err error
-			clientID, _, err = clientCredentialsFromRequestBody(form, false)
-			if err != nil {
-				return nil, err
-			}
-
-			if clientID == "" {
-				claims := t.Claims
-				if sub, ok := claims["sub"].(string); !ok {
-					return nil, errorsx.WithStack(ErrInvalidClient.WithHint("The claim 'sub' from the client_assertion JSON Web Token is undefined."))
-				} else {
-					clientID = sub
-				}
-			}
-
-			client, err = f.Store.GetClient(ctx, clientID)
-			if err != nil {
-				return nil, errorsx.WithStack(ErrInvalidClient.WithWrap(err).WithDebug(err.Error()))
-			}
-
-			oidcClient, ok := client.(OpenIDConnectClient)
-			if !ok {
-				return nil, errorsx.WithStack(ErrInvalidRequest.WithHint("The server configuration does not support OpenID Connect specific authentication methods."))
-			}
-
-			switch oidcClient.GetTokenEndpointAuthMethod() {
-			case "private_key_jwt":
-				break
-			case "none":
-				return nil, errorsx.WithStack(ErrInvalidClient.WithHint("This requested OAuth 2.0 client does not support client authentication, however 'client_assertion' was provided in the request."))
-			case "client_secret_post":
-				fallthrough
-			case "client_secret_basic":
-				return nil, errorsx.WithStack(ErrInvalidClient.WithHintf("This requested OAuth 2.0 client only supports client authentication method '%s', however 'client_assertion' was provided in the request.", oidcClient.GetTokenEndpointAuthMethod()))
-			case "client_secret_jwt":
-				fallthrough
-			default:
-				return nil, errorsx.WithStack(ErrInvalidClient.WithHintf("This requested OAuth 2.0 client only supports client authentication method '%s', however that method is not supported by this server.", oidcClient.GetTokenEndpointAuthMethod()))
-			}
-
-			if oidcClient.GetTokenEndpointAuthSigningAlgorithm() != fmt.Sprintf("%s", t.Header["alg"]) {
-				return nil, errorsx.WithStack(ErrInvalidClient.WithHintf("The 'client_assertion' uses signing algorithm '%s' but the requested OAuth 2.0 Client enforces signing algorithm '%s'.", t.Header["alg"], oidcClient.GetTokenEndpointAuthSigningAlgorithm()))
-			}
-			switch t.Method {
-			case jose.RS256, jose.RS384, jose.RS512:
-				return f.findClientPublicJWK(oidcClient, t, true)
-			case jose.ES256, jose.ES384, jose.ES512:
-				return f.findClientPublicJWK(oidcClient, t, false)
-			case jose.PS256, jose.PS384, jose.PS512:
-				return f.findClientPublicJWK(oidcClient, t, true)
-			case jose.HS256, jose.HS384, jose.HS512:
-				return nil, errorsx.WithStack(ErrInvalidClient.WithHint("This authorization server does not support client authentication method 'client_secret_jwt'."))
-			default:
-				return nil, errorsx.WithStack(ErrInvalidClient.WithHintf("The 'client_assertion' request parameter uses unsupported signing algorithm '%s'.", t.Header["alg"]))
-			}
-		})
-		if err != nil {
-			// Do not re-process already enhanced errors
-			var e *jwt.ValidationError
-			if errors.As(err, &e) {
-				if e.Inner != nil {
-					return nil, e.Inner
-				}
-				return nil, errorsx.WithStack(ErrInvalidClient.WithHint("Unable to verify the integrity of the 'client_assertion' value.").WithWrap(err).WithDebug(err.Error()))
-			}
-			return nil, err
-		} else if err := token.Claims.Valid(); err != nil {
-			return nil, errorsx.WithStack(ErrInvalidClient.WithHint("Unable to verify the request object because its claims could not be validated, check if the expiry time is set correctly.").WithWrap(err).WithDebug(err.Error()))
-		}
-
-		claims := token.Claims
-		var jti string
-		if !claims.VerifyIssuer(clientID, true) {
-			return nil, errorsx.WithStack(ErrInvalidClient.WithHint("Claim 'iss' from 'client_assertion' must match the 'client_id' of the OAuth 2.0 Client."))
-		} else if f.TokenURL == "" {
-			return nil, errorsx.WithStack(ErrMisconfiguration.WithHint("The authorization server's token endpoint URL has not been set."))
-		} else if sub, ok := claims["sub"].(string); !ok || sub != clientID {
-			return nil, errorsx.WithStack(ErrInvalidClient.WithHint("Claim 'sub' from 'client_assertion' must match the 'client_id' of the OAuth 2.0 Client."))
-		} else if jti, ok = claims["jti"].(string); !ok || len(jti) == 0 {
-			return nil, errorsx.WithStack(ErrInvalidClient.WithHint("Claim 'jti' from 'client_assertion' must be set but is not."))
-		} else if f.Store.ClientAssertionJWTValid(ctx, jti) != nil {
-			return nil, errorsx.WithStack(ErrJTIKnown.WithHint("Claim 'jti' from 'client_assertion' MUST only be used once."))
-		}
-
-		// type conversion according to jwt.MapClaims.VerifyExpiresAt
-		var expiry int64
-		err = nil
-		switch exp := claims["exp"].(type) {
-		case float64:
-			expiry = int64(exp)
-		case int64:
-			expiry = exp
-		case json.Number:
-			expiry, err = exp.Int64()
-		default:
-			err = ErrInvalidClient.WithHint("Unable to type assert the expiry time from claims. This should not happen as we validate the expiry time already earlier with token.Claims.Valid()")
-		}
+	handlers := f.ClientAuthenticationHandlers
+	if len(handlers) == 0 {
+		handlers = DefaultClientAuthenticationHandlers()
+	}
 
-		if err != nil {
-			return nil, errorsx.WithStack(err)
-		}
-		if err := f.Store.SetClientAssertionJWT(ctx, jti, time.Unix(expiry, 0)); err != nil {
+	for _, h := range handlers {
+		client, err := h.AuthenticateClient(ctx, f, r, form)
+		if errors.Is(err, ErrUnknownRequest) {
+			continue
+		} else if err != nil {
 			return nil, err
 		}
-
-		if auds, ok := claims["aud"].([]interface{}); !ok {
-			if !claims.VerifyAudience(f.TokenURL, true) {
-				return nil, errorsx.WithStack(ErrInvalidClient.WithHintf("Claim 'audience' from 'client_assertion' must match the authorization server's token endpoint '%s'.", f.TokenURL))
-			}
-		} else {
-			var found bool
-			for _, aud := range auds {
-				if a, ok := aud.(string); ok && a == f.TokenURL {
-					found = true
-					break
-				}
-			}
-
-			if !found {
-				return nil, errorsx.WithStack(ErrInvalidClient.WithHintf("Claim 'audience' from 'client_assertion' must match the authorization server's token endpoint '%s'.", f.TokenURL))
-			}
-		}
-
 		return client, nil
+	}
+
+	if assertionType := form.Get("client_assertion_type"); assertionType == clientAssertionJWTBearerType {
+		return nil, errorsx.WithStack(ErrInvalidClient.WithHint("The 'client_assertion' request parameter uses a client authentication method which is not supported by this server."))
 	} else if len(assertionType) > 0 {
 		return nil, errorsx.WithStack(ErrInvalidRequest.WithHintf("Unknown client_assertion_type '%s'.", assertionType))
 	}
 
-	clientID, clientSecret, err := clientCredentialsFromRequest(r, form)
-	if err != nil {
+	if _, _, err := clientCredentialsFromRequest(r, form); err != nil {
 		return nil, err
 	}
 
-	client, err := f.Store.GetClient(ctx, clientID)
-	if err != nil {
-		return nil, errorsx.WithStack(ErrInvalidClient.WithWrap(err).WithDebug(err.Error()))
-	}
-
-	if oidcClient, ok := client.(OpenIDConnectClient); !ok {
-		// If this isn't an OpenID Connect client then we actually don't care about any of this, just continue!
-	} else if ok && form.Get("client_id") != "" && form.Get("client_secret") != "" && oidcClient.GetTokenEndpointAuthMethod() != "client_secret_post" {
-		return nil, errorsx.WithStack(ErrInvalidClient.WithHintf("The OAuth 2.0 Client supports client authentication method '%s', but method 'client_secret_post' was requested. You must configure the OAuth 2.0 client's 'token_endpoint_auth_method' value to accept 'client_secret_post'.", oidcClient.GetTokenEndpointAuthMethod()))
-	} else if _, _, basicOk := r.BasicAuth(); basicOk && ok && oidcClient.GetTokenEndpointAuthMethod() != "client_secret_basic" {
-		return nil, errorsx.WithStack(ErrInvalidClient.WithHintf("The OAuth 2.0 Client supports client authentication method '%s', but method 'client_secret_basic' was requested. You must configure the OAuth 2.0 client's 'token_endpoint_auth_method' value to accept 'client_secret_basic'.", oidcClient.GetTokenEndpointAuthMethod()))
-	} else if ok && oidcClient.GetTokenEndpointAuthMethod() != "none" && client.IsPublic() {
-		return nil, errorsx.WithStack(ErrInvalidClient.WithHintf("The OAuth 2.0 Client supports client authentication method '%s', but method 'none' was requested. You must configure the OAuth 2.0 client's 'token_endpoint_auth_method' value to accept 'none'.", oidcClient.GetTokenEndpointAuthMethod()))
-	}
-
-	if client.IsPublic() {
-		return client, nil
-	}
+	return nil, errorsx.WithStack(ErrInvalidClient.WithHint("The client authentication method used by the request is not supported by this server."))
+}
 
-	// Enforce client authentication
-	if err := f.checkClientSecret(ctx, client, []byte(clientSecret)); err != nil {
+func (f *Fosite) getClientToAuthenticate(ctx context.Context, clientID string) (Client, error) {
+	client, err := f.getClient(ctx, clientID)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return nil, errorsx.WithStack(ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	} else if err != nil {
 		return nil, errorsx.WithStack(ErrInvalidClient.WithWrap(err).WithDebug(err.Error()))
 	}
-
 	return client, nil
 }
 
@@ -255,6 +158,7 @@ func (f *Fosite) checkClientSecret(ctx context.Context, client Client, clientSec
 	var err error
 	err = f.Hasher.Compare(ctx, client.GetHashedSecret(), clientSecret)
 	if err == nil {
+		f.rehashClientSecret(ctx, client, clientSecret)
 		return nil
 	}
 	cc, ok := client.(ClientWithSecretRotation)
@@ -271,6 +175,18 @@ func (f *Fosite) checkClientSecret(ctx context.Context, client Client, clientSec
 	return err
 }
 
+func (f *Fosite) rehashClientSecret(ctx context.Context, client Client, clientSecret []byte) {
+	if f.ClientSecretRehasher == nil || !NeedsRehash(f.Hasher, client.GetHashedSecret()) {
+		return
+	}
+
+	hash, err := f.Hasher.Hash(ctx, clientSecret)
+	if err != nil {
+		return
+	}
+	_ = f.ClientSecretRehasher(ctx, client, hash)
+}
+
 func findPublicKey(t *jwt.Token, set *jose.JSONWebKeySet, expectsRSAKey bool) (interface{}, error) {
 	keys := set.Keys
 	if len(keys) == 0 {
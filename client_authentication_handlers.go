@@ -0,0 +1,410 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ory/x/errorsx"
+	"github.com/pkg/errors"
+	jose "gopkg.in/square/go-jose.v2"
+
+	"github.com/ory/fosite/token/jwt"
+)
+
+// DefaultClientAuthenticationHandlers returns the client authentication methods supported by default, in the order
+// they are tried.
+func DefaultClientAuthenticationHandlers() ClientAuthenticationHandlers {
+	return ClientAuthenticationHandlers{
+		&PrivateKeyJWTClientAuthenticationHandler{},
+		&ClientSecretJWTClientAuthenticationHandler{},
+		&ClientSecretBasicClientAuthenticationHandler{},
+		&ClientSecretPostClientAuthenticationHandler{},
+		&TLSClientAuthenticationHandler{},
+		&NoneClientAuthenticationHandler{},
+	}
+}
+
+// ClientSecretBasicClientAuthenticationHandler implements the client_secret_basic authentication method, see
+// https://tools.ietf.org/html/rfc6749#section-2.3.1
+type ClientSecretBasicClientAuthenticationHandler struct{}
+
+func (h *ClientSecretBasicClientAuthenticationHandler) AuthenticateClient(ctx context.Context, f *Fosite, r *http.Request, form url.Values) (Client, error) {
+	id, secret, ok := r.BasicAuth()
+	if !ok {
+		return nil, errorsx.WithStack(ErrUnknownRequest)
+	}
+
+	clientID, err := url.QueryUnescape(id)
+	if err != nil {
+		return nil, errorsx.WithStack(ErrInvalidRequest.WithHint("The client id in the HTTP authorization header could not be decoded from 'application/x-www-form-urlencoded'.").WithWrap(err).WithDebug(err.Error()))
+	}
+
+	clientSecret, err := url.QueryUnescape(secret)
+	if err != nil {
+		return nil, errorsx.WithStack(ErrInvalidRequest.WithHint("The client secret in the HTTP authorization header could not be decoded from 'application/x-www-form-urlencoded'.").WithWrap(err).WithDebug(err.Error()))
+	}
+
+	return f.authenticateClientWithSecret(ctx, clientID, clientSecret, "client_secret_basic")
+}
+
+func (h *ClientSecretBasicClientAuthenticationHandler) PopulateDiscoveryMetadata(ctx context.Context, metadata *DiscoveryMetadata) {
+	metadata.TokenEndpointAuthMethodsSupported = append(metadata.TokenEndpointAuthMethodsSupported, "client_secret_basic")
+}
+
+// ClientSecretPostClientAuthenticationHandler implements the client_secret_post authentication method, see
+// https://tools.ietf.org/html/rfc6749#section-2.3.1
+type ClientSecretPostClientAuthenticationHandler struct{}
+
+func (h *ClientSecretPostClientAuthenticationHandler) AuthenticateClient(ctx context.Context, f *Fosite, r *http.Request, form url.Values) (Client, error) {
+	if form.Get("client_secret") == "" {
+		return nil, errorsx.WithStack(ErrUnknownRequest)
+	}
+
+	clientID, clientSecret, err := clientCredentialsFromRequestBody(form, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return f.authenticateClientWithSecret(ctx, clientID, clientSecret, "client_secret_post")
+}
+
+func (h *ClientSecretPostClientAuthenticationHandler) PopulateDiscoveryMetadata(ctx context.Context, metadata *DiscoveryMetadata) {
+	metadata.TokenEndpointAuthMethodsSupported = append(metadata.TokenEndpointAuthMethodsSupported, "client_secret_post")
+}
+
+// NoneClientAuthenticationHandler authenticates public clients which only send their client_id.
+type NoneClientAuthenticationHandler struct{}
+
+func (h *NoneClientAuthenticationHandler) AuthenticateClient(ctx context.Context, f *Fosite, r *http.Request, form url.Values) (Client, error) {
+	if !isClientIDOnlyRequest(r, form) {
+		return nil, errorsx.WithStack(ErrUnknownRequest)
+	}
+
+	client, err := f.getClientToAuthenticate(ctx, form.Get("client_id"))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkTokenEndpointAuthMethod(client, "none"); err != nil {
+		return nil, err
+	}
+
+	if !client.IsPublic() {
+		return nil, errorsx.WithStack(ErrInvalidClient.WithHint("The OAuth 2.0 Client is confidential but the request does not contain any client credentials."))
+	}
+
+	return client, nil
+}
+
+func (h *NoneClientAuthenticationHandler) PopulateDiscoveryMetadata(ctx context.Context, metadata *DiscoveryMetadata) {
+	metadata.TokenEndpointAuthMethodsSupported = append(metadata.TokenEndpointAuthMethodsSupported, "none")
+}
+
+// TLSClientAuthenticationHandler implements the tls_client_auth and self_signed_tls_client_auth authentication
+// methods, see https://tools.ietf.org/html/rfc8705#section-2
+type TLSClientAuthenticationHandler struct{}
+
+func (h *TLSClientAuthenticationHandler) AuthenticateClient(ctx context.Context, f *Fosite, r *http.Request, form url.Values) (Client, error) {
+	if !isClientIDOnlyRequest(r, form) || clientCertificateFromRequest(r) == nil {
+		return nil, errorsx.WithStack(ErrUnknownRequest)
+	}
+
+	client, err := f.getClientToAuthenticate(ctx, form.Get("client_id"))
+	if err != nil {
+		return nil, err
+	}
+
+	oidcClient, ok := client.(OpenIDConnectClient)
+	if !ok {
+		return nil, errorsx.WithStack(ErrUnknownRequest)
+	}
+
+	switch method := oidcClient.GetTokenEndpointAuthMethod(); method {
+	case TLSClientAuthMethod, SelfSignedTLSClientAuthMethod:
+		if err := f.authenticateClientWithCertificate(ctx, r, oidcClient, method); err != nil {
+			return nil, err
+		}
+		return client, nil
+	}
+
+	return nil, errorsx.WithStack(ErrUnknownRequest)
+}
+
+func (h *TLSClientAuthenticationHandler) PopulateDiscoveryMetadata(ctx context.Context, metadata *DiscoveryMetadata) {
+	metadata.TokenEndpointAuthMethodsSupported = append(metadata.TokenEndpointAuthMethodsSupported, TLSClientAuthMethod, SelfSignedTLSClientAuthMethod)
+}
+
+// PrivateKeyJWTClientAuthenticationHandler implements the private_key_jwt authentication method, see
+// https://openid.net/specs/openid-connect-core-1_0.html#ClientAuthentication
+type PrivateKeyJWTClientAuthenticationHandler struct{}
+
+func (h *PrivateKeyJWTClientAuthenticationHandler) AuthenticateClient(ctx context.Context, f *Fosite, r *http.Request, form url.Values) (Client, error) {
+	return f.authenticateClientWithAssertion(ctx, form, "private_key_jwt", false, func(t *jwt.Token, client OpenIDConnectClient) (interface{}, error) {
+		switch t.Method {
+		case jose.RS256, jose.RS384, jose.RS512:
+			return f.findClientPublicJWK(ctx, client, t, true)
+		case jose.ES256, jose.ES384, jose.ES512:
+			return f.findClientPublicJWK(ctx, client, t, false)
+		case jose.PS256, jose.PS384, jose.PS512:
+			return f.findClientPublicJWK(ctx, client, t, true)
+		default:
+			return nil, errorsx.WithStack(ErrInvalidClient.WithHintf("The 'client_assertion' request parameter uses unsupported signing algorithm '%s'.", t.Header["alg"]))
+		}
+	})
+}
+
+func (h *PrivateKeyJWTClientAuthenticationHandler) PopulateDiscoveryMetadata(ctx context.Context, metadata *DiscoveryMetadata) {
+	metadata.TokenEndpointAuthMethodsSupported = append(metadata.TokenEndpointAuthMethodsSupported, "private_key_jwt")
+	metadata.TokenEndpointAuthSigningAlgValuesSupported = append(metadata.TokenEndpointAuthSigningAlgValuesSupported,
+		string(jose.RS256), string(jose.RS384), string(jose.RS512),
+		string(jose.ES256), string(jose.ES384), string(jose.ES512),
+		string(jose.PS256), string(jose.PS384), string(jose.PS512),
+	)
+}
+
+// ClientSecretJWTClientAuthenticationHandler implements the client_secret_jwt authentication method, see
+// https://openid.net/specs/openid-connect-core-1_0.html#ClientAuthentication
+//
+// Client assertions are verified using the client's secret, so only clients implementing ClientWithPlaintextSecret
+// can use this method.
+type ClientSecretJWTClientAuthenticationHandler struct{}
+
+func (h *ClientSecretJWTClientAuthenticationHandler) AuthenticateClient(ctx context.Context, f *Fosite, r *http.Request, form url.Values) (Client, error) {
+	return f.authenticateClientWithAssertion(ctx, form, "client_secret_jwt", true, func(t *jwt.Token, client OpenIDConnectClient) (interface{}, error) {
+		pc, ok := client.(ClientWithPlaintextSecret)
+		if !ok || len(pc.GetPlaintextSecret()) == 0 {
+			return nil, errorsx.WithStack(ErrInvalidClient.WithHint("This authorization server does not support client authentication method 'client_secret_jwt' for this OAuth 2.0 Client because its secret is not available."))
+		}
+		return &jose.JSONWebKey{Key: pc.GetPlaintextSecret()}, nil
+	})
+}
+
+func (h *ClientSecretJWTClientAuthenticationHandler) PopulateDiscoveryMetadata(ctx context.Context, metadata *DiscoveryMetadata) {
+	metadata.TokenEndpointAuthMethodsSupported = append(metadata.TokenEndpointAuthMethodsSupported, "client_secret_jwt")
+	metadata.TokenEndpointAuthSigningAlgValuesSupported = append(metadata.TokenEndpointAuthSigningAlgValuesSupported,
+		string(jose.HS256), string(jose.HS384), string(jose.HS512),
+	)
+}
+
+// isClientIDOnlyRequest returns true if the request identifies the client by client_id without sending a secret.
+func isClientIDOnlyRequest(r *http.Request, form url.Values) bool {
+	if _, _, ok := r.BasicAuth(); ok {
+		return false
+	}
+	return form.Get("client_id") != "" && form.Get("client_secret") == "" && form.Get("client_assertion_type") == ""
+}
+
+// checkTokenEndpointAuthMethod ensures that clients implementing TokenEndpointAuthMethodClient registered the
+// authentication method used by the request. Other clients may use any method.
+func checkTokenEndpointAuthMethod(client Client, method string) error {
+	mc, ok := client.(TokenEndpointAuthMethodClient)
+	if !ok {
+		return nil
+	}
+
+	if registered := mc.GetTokenEndpointAuthMethod(); registered != method {
+		return errorsx.WithStack(ErrInvalidClient.WithHintf("The OAuth 2.0 Client supports client authentication method '%s', but method '%s' was requested. You must configure the OAuth 2.0 client's 'token_endpoint_auth_method' value to accept '%s'.", registered, method, method))
+	}
+	return nil
+}
+
+func (f *Fosite) authenticateClientWithSecret(ctx context.Context, clientID, clientSecret, method string) (Client, error) {
+	client, err := f.getClientToAuthenticate(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkTokenEndpointAuthMethod(client, method); err != nil {
+		return nil, err
+	}
+
+	if client.IsPublic() {
+		// Public OpenID Connect clients must use method 'none'.
+		if err := checkTokenEndpointAuthMethod(client, "none"); err != nil {
+			return nil, err
+		}
+		return client, nil
+	}
+
+	// Enforce client authentication
+	if err := CheckAuthenticationAllowed(ctx, f.FailedAuthenticationRecorder, ClientAuthentication, client.GetID()); err != nil {
+		return nil, err
+	} else if err := f.checkClientSecret(ctx, client, []byte(clientSecret)); err != nil {
+		return nil, RecordFailedAuthentication(ctx, f.FailedAuthenticationRecorder, ClientAuthentication, client.GetID(), errorsx.WithStack(ErrInvalidClient.WithWrap(err).WithDebug(err.Error())))
+	}
+	RecordSuccessfulAuthentication(ctx, f.FailedAuthenticationRecorder, ClientAuthentication, client.GetID())
+
+	return client, nil
+}
+
+// clientAssertionKeyFunc returns the key verifying the client assertion of the given client.
+type clientAssertionKeyFunc func(t *jwt.Token, client OpenIDConnectClient) (interface{}, error)
+
+func isSymmetricSigningMethod(method jose.SignatureAlgorithm) bool {
+	switch method {
+	case jose.HS256, jose.HS384, jose.HS512:
+		return true
+	default:
+		return false
+	}
+}
+
+// authenticateClientWithAssertion authenticates clients using a JWT client assertion, see
+// https://tools.ietf.org/html/rfc7523#section-2.2. Assertions signed with a symmetric algorithm are handled by
+// client_secret_jwt, all others by private_key_jwt.
+func (f *Fosite) authenticateClientWithAssertion(ctx context.Context, form url.Values, method string, symmetric bool, keyFunc clientAssertionKeyFunc) (Client, error) {
+	if form.Get("client_assertion_type") != clientAssertionJWTBearerType {
+		return nil, errorsx.WithStack(ErrUnknownRequest)
+	}
+
+	assertion := form.Get("client_assertion")
+	if len(assertion) == 0 {
+		return nil, errorsx.WithStack(ErrInvalidRequest.WithHintf("The client_assertion request parameter must be set when using client_assertion_type of '%s'.", clientAssertionJWTBearerType))
+	}
+
+	var clientID string
+	var client Client
+
+	token, err := jwt.ParseWithClaims(assertion, jwt.MapClaims{}, func(t *jwt.Token) (interface{}, error) {
+		if isSymmetricSigningMethod(t.Method) != symmetric {
+			return nil, errorsx.WithStack(ErrUnknownRequest)
+		}
+
+		var err error
+		clientID, _, err = clientCredentialsFromRequestBody(form, false)
+		if err != nil {
+			return nil, err
+		}
+
+		if clientID == "" {
+			claims := t.Claims
+			if sub, ok := claims["sub"].(string); !ok {
+				return nil, errorsx.WithStack(ErrInvalidClient.WithHint("The claim 'sub' from the client_assertion JSON Web Token is undefined."))
+			} else {
+				clientID = sub
+			}
+		}
+
+		client, err = f.getClientToAuthenticate(ctx, clientID)
+		if err != nil {
+			return nil, err
+		}
+
+		oidcClient, ok := client.(OpenIDConnectClient)
+		if !ok {
+			return nil, errorsx.WithStack(ErrInvalidRequest.WithHint("The server configuration does not support OpenID Connect specific authentication methods."))
+		}
+
+		switch registered := oidcClient.GetTokenEndpointAuthMethod(); registered {
+		case method:
+			break
+		case "none":
+			return nil, errorsx.WithStack(ErrInvalidClient.WithHint("This requested OAuth 2.0 client does not support client authentication, however 'client_assertion' was provided in the request."))
+		default:
+			return nil, errorsx.WithStack(ErrInvalidClient.WithHintf("This requested OAuth 2.0 client only supports client authentication method '%s', however 'client_assertion' for method '%s' was provided in the request.", registered, method))
+		}
+
+		if oidcClient.GetTokenEndpointAuthSigningAlgorithm() != fmt.Sprintf("%s", t.Header["alg"]) {
+			return nil, errorsx.WithStack(ErrInvalidClient.WithHintf("The 'client_assertion' uses signing algorithm '%s' but the requested OAuth 2.0 Client enforces signing algorithm '%s'.", t.Header["alg"], oidcClient.GetTokenEndpointAuthSigningAlgorithm()))
+		}
+
+		return keyFunc(t, oidcClient)
+	})
+	if err != nil {
+		// Do not re-process already enhanced errors
+		var e *jwt.ValidationError
+		if errors.As(err, &e) {
+			if e.Inner != nil {
+				return nil, e.Inner
+			}
+			return nil, errorsx.WithStack(ErrInvalidClient.WithHint("Unable to verify the integrity of the 'client_assertion' value.").WithWrap(err).WithDebug(err.Error()))
+		}
+		return nil, err
+	} else if err := token.Claims.Valid(); err != nil {
+		return nil, errorsx.WithStack(ErrInvalidClient.WithHint("Unable to verify the request object because its claims could not be validated, check if the expiry time is set correctly.").WithWrap(err).WithDebug(err.Error()))
+	}
+
+	claims := token.Claims
+	var jti string
+	if !claims.VerifyIssuer(clientID, true) {
+		return nil, errorsx.WithStack(ErrInvalidClient.WithHint("Claim 'iss' from 'client_assertion' must match the 'client_id' of the OAuth 2.0 Client."))
+	} else if f.TokenURL == "" {
+		return nil, errorsx.WithStack(ErrMisconfiguration.WithHint("The authorization server's token endpoint URL has not been set."))
+	} else if sub, ok := claims["sub"].(string); !ok || sub != clientID {
+		return nil, errorsx.WithStack(ErrInvalidClient.WithHint("Claim 'sub' from 'client_assertion' must match the 'client_id' of the OAuth 2.0 Client."))
+	} else if jti, ok = claims["jti"].(string); !ok || len(jti) == 0 {
+		return nil, errorsx.WithStack(ErrInvalidClient.WithHint("Claim 'jti' from 'client_assertion' must be set but is not."))
+	} else if StorageOperation(ctx, "ClientAssertionJWTValid", func(ctx context.Context) error {
+		return f.Store.ClientAssertionJWTValid(ctx, jti)
+	}) != nil {
+		return nil, errorsx.WithStack(ErrJTIKnown.WithHint("Claim 'jti' from 'client_assertion' MUST only be used once."))
+	}
+
+	// type conversion according to jwt.MapClaims.VerifyExpiresAt
+	var expiry int64
+	err = nil
+	switch exp := claims["exp"].(type) {
+	case float64:
+		expiry = int64(exp)
+	case int64:
+		expiry = exp
+	case json.Number:
+		expiry, err = exp.Int64()
+	default:
+		err = ErrInvalidClient.WithHint("Unable to type assert the expiry time from claims. This should not happen as we validate the expiry time already earlier with token.Claims.Valid()")
+	}
+
+	if err != nil {
+		return nil, errorsx.WithStack(err)
+	}
+	if err := StorageOperation(ctx, "SetClientAssertionJWT", func(ctx context.Context) error {
+		return f.Store.SetClientAssertionJWT(ctx, jti, time.Unix(expiry, 0))
+	}); err != nil {
+		return nil, err
+	}
+
+	if auds, ok := claims["aud"].([]interface{}); !ok {
+		if !claims.VerifyAudience(f.TokenURL, true) {
+			return nil, errorsx.WithStack(ErrInvalidClient.WithHintf("Claim 'audience' from 'client_assertion' must match the authorization server's token endpoint '%s'.", f.TokenURL))
+		}
+	} else {
+		var found bool
+		for _, aud := range auds {
+			if a, ok := aud.(string); ok && a == f.TokenURL {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return nil, errorsx.WithStack(ErrInvalidClient.WithHintf("Claim 'audience' from 'client_assertion' must match the authorization server's token endpoint '%s'.", f.TokenURL))
+		}
+	}
+
+	return client, nil
+}
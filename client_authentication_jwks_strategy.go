@@ -22,9 +22,13 @@
 package fosite
 
 import (
+	"context"
 	"encoding/json"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/ory/x/errorsx"
 
@@ -40,17 +44,58 @@ type JWKSFetcherStrategy interface {
 	Resolve(location string, forceRefresh bool) (*jose.JSONWebKeySet, error)
 }
 
+// DefaultJWKSMaxResponseSize is the default maximum size of JSON Web Key Set documents fetched by
+// DefaultJWKSFetcherStrategy.
+const DefaultJWKSMaxResponseSize = 1 << 20
+
+// JWKSFetcherOptions configure the DefaultJWKSFetcherStrategy returned by NewJWKSFetcherStrategy.
+type JWKSFetcherOptions struct {
+	// HTTPClient is used to fetch the JSON Web Key Sets. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+
+	// Timeout limits the duration of a single fetch. Defaults to no timeout besides the one of HTTPClient.
+	Timeout time.Duration
+
+	// MaxResponseSize is the maximum size of a JSON Web Key Set document in bytes. Defaults to
+	// DefaultJWKSMaxResponseSize.
+	MaxResponseSize int64
+
+	// TTL, if set, is the time after which cached key sets are fetched again. By default, key sets are cached
+	// until a refresh is forced, which happens if a key is not found in the cached key set.
+	TTL time.Duration
+}
+
 type DefaultJWKSFetcherStrategy struct {
-	client *http.Client
-	keys   map[string]jose.JSONWebKeySet
+	client    *http.Client
+	timeout   time.Duration
+	maxSize   int64
+	ttl       time.Duration
+	keys      map[string]jose.JSONWebKeySet
+	fetchedAt map[string]time.Time
 	sync.Mutex
 }
 
 func NewDefaultJWKSFetcherStrategy() JWKSFetcherStrategy {
-	return &DefaultJWKSFetcherStrategy{
-		keys:   make(map[string]jose.JSONWebKeySet),
-		client: http.DefaultClient,
+	return NewJWKSFetcherStrategy(JWKSFetcherOptions{})
+}
+
+// NewJWKSFetcherStrategy returns a DefaultJWKSFetcherStrategy configured by the given options.
+func NewJWKSFetcherStrategy(opts JWKSFetcherOptions) JWKSFetcherStrategy {
+	s := &DefaultJWKSFetcherStrategy{
+		keys:      make(map[string]jose.JSONWebKeySet),
+		fetchedAt: make(map[string]time.Time),
+		client:    opts.HTTPClient,
+		timeout:   opts.Timeout,
+		maxSize:   opts.MaxResponseSize,
+		ttl:       opts.TTL,
+	}
+	if s.client == nil {
+		s.client = http.DefaultClient
 	}
+	if s.maxSize == 0 {
+		s.maxSize = DefaultJWKSMaxResponseSize
+	}
+	return s
 }
 
 func (s *DefaultJWKSFetcherStrategy) Resolve(location string, forceRefresh bool) (*jose.JSONWebKeySet, error) {
@@ -58,25 +103,57 @@ func (s *DefaultJWKSFetcherStrategy) Resolve(location string, forceRefresh bool)
 	defer s.Unlock()
 
 	keys, ok := s.keys[location]
+	if ok && s.ttl > 0 && time.Since(s.fetchedAt[location]) > s.ttl {
+		ok = false
+	}
+
 	if !ok || forceRefresh {
-		response, err := s.client.Get(location)
+		set, err := s.fetch(location)
 		if err != nil {
-			return nil, errorsx.WithStack(ErrServerError.WithHintf("Unable to fetch JSON Web Keys from location '%s'. Check for typos or other network issues.", location).WithWrap(err).WithDebug(err.Error()))
+			return nil, err
 		}
-		defer response.Body.Close()
 
-		if response.StatusCode < 200 || response.StatusCode >= 400 {
-			return nil, errorsx.WithStack(ErrServerError.WithHintf("Expected successful status code in range of 200 - 399 from location '%s' but received code %d.", location, response.StatusCode))
-		}
+		s.keys[location] = *set
+		s.fetchedAt[location] = time.Now()
+		return set, nil
+	}
 
-		var set jose.JSONWebKeySet
-		if err := json.NewDecoder(response.Body).Decode(&set); err != nil {
-			return nil, errorsx.WithStack(ErrServerError.WithHintf("Unable to decode JSON Web Keys from location '%s'. Please check for typos and if the URL returns valid JSON.", location).WithWrap(err).WithDebug(err.Error()))
-		}
+	return &keys, nil
+}
 
-		s.keys[location] = set
-		return &set, nil
+func (s *DefaultJWKSFetcherStrategy) fetch(location string) (*jose.JSONWebKeySet, error) {
+	ctx := context.Background()
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
 	}
 
-	return &keys, nil
+	req, err := http.NewRequestWithContext(ctx, "GET", location, nil)
+	if err != nil {
+		return nil, errorsx.WithStack(ErrServerError.WithHintf("Unable to fetch JSON Web Keys from location '%s'. Check for typos or other network issues.", location).WithWrap(err).WithDebug(err.Error()))
+	}
+
+	response, err := s.client.Do(req)
+	if err != nil {
+		return nil, errorsx.WithStack(ErrServerError.WithHintf("Unable to fetch JSON Web Keys from location '%s'. Check for typos or other network issues.", location).WithWrap(err).WithDebug(err.Error()))
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 400 {
+		return nil, errorsx.WithStack(ErrServerError.WithHintf("Expected successful status code in range of 200 - 399 from location '%s' but received code %d.", location, response.StatusCode))
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(response.Body, s.maxSize+1))
+	if err != nil {
+		return nil, errorsx.WithStack(ErrServerError.WithHintf("Unable to fetch JSON Web Keys from location '%s'. Check for typos or other network issues.", location).WithWrap(err).WithDebug(err.Error()))
+	} else if int64(len(body)) > s.maxSize {
+		return nil, errorsx.WithStack(ErrServerError.WithHintf("The JSON Web Keys from location '%s' exceed the maximum size of %d bytes.", location, s.maxSize))
+	}
+
+	var set jose.JSONWebKeySet
+	if err := json.Unmarshal(body, &set); err != nil {
+		return nil, errorsx.WithStack(ErrServerError.WithHintf("Unable to decode JSON Web Keys from location '%s'. Please check for typos and if the URL returns valid JSON.", location).WithWrap(err).WithDebug(err.Error()))
+	}
+	return &set, nil
 }
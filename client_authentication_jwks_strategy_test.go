@@ -25,7 +25,9 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -106,4 +108,55 @@ func TestDefaultJWKSFetcherStrategy(t *testing.T) {
 		_, err := s.Resolve(ts.URL, true)
 		require.Error(t, err)
 	})
+
+	t.Run("case=error_max_size", func(t *testing.T) {
+		h = func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"keys":[],"padding":"` + strings.Repeat("a", 128) + `"}`))
+		}
+		ts := httptest.NewServer(h)
+		defer ts.Close()
+
+		_, err := NewJWKSFetcherStrategy(JWKSFetcherOptions{MaxResponseSize: 64}).Resolve(ts.URL, true)
+		require.Error(t, err)
+
+		_, err = NewJWKSFetcherStrategy(JWKSFetcherOptions{MaxResponseSize: 1024}).Resolve(ts.URL, true)
+		require.NoError(t, err)
+	})
+
+	t.Run("case=error_timeout", func(t *testing.T) {
+		h = func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(time.Millisecond * 100)
+			w.Write([]byte(`{"keys":[]}`))
+		}
+		ts := httptest.NewServer(h)
+		defer ts.Close()
+
+		_, err := NewJWKSFetcherStrategy(JWKSFetcherOptions{Timeout: time.Millisecond * 10}).Resolve(ts.URL, true)
+		require.Error(t, err)
+	})
+}
+
+func TestJWKSFetcherStrategyWithOptions(t *testing.T) {
+	var requests int
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(`{"keys":[]}`))
+	}))
+	defer ts.Close()
+
+	s := NewJWKSFetcherStrategy(JWKSFetcherOptions{HTTPClient: ts.Client(), TTL: time.Millisecond * 20})
+
+	_, err := s.Resolve(ts.URL, false)
+	require.NoError(t, err)
+	_, err = s.Resolve(ts.URL, false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, requests)
+
+	time.Sleep(time.Millisecond * 30)
+	_, err = s.Resolve(ts.URL, false)
+	require.NoError(t, err)
+	assert.Equal(t, 2, requests, "expired key sets must be fetched again")
+
+	_, err = NewDefaultJWKSFetcherStrategy().Resolve(ts.URL, false)
+	require.Error(t, err, "the default HTTP client does not trust the test certificate")
 }
@@ -586,3 +586,207 @@ func TestAuthenticateClientTwice(t *testing.T) {
 	assert.EqualError(t, err, ErrJTIKnown.Error())
 	assert.Nil(t, c)
 }
+
+func TestAuthenticateClientWithJSONWebKeyCache(t *testing.T) {
+	const at = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+	key, rotated := internal.MustRSAKey(), internal.MustRSAKey()
+	jwks := &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{KeyID: "kid-foo", Use: "sig", Key: &key.PublicKey}}}
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		require.NoError(t, json.NewEncoder(w).Encode(jwks))
+	}))
+	defer ts.Close()
+
+	store := storage.NewMemoryStore()
+	store.Clients["bar"] = &DefaultOpenIDConnectClient{
+		DefaultClient:           &DefaultClient{ID: "bar"},
+		JSONWebKeysURI:          ts.URL,
+		TokenEndpointAuthMethod: "private_key_jwt",
+	}
+	f := &Fosite{
+		JWKSFetcherStrategy: NewDefaultJWKSFetcherStrategy(),
+		JSONWebKeyCache:     NewMemoryJSONWebKeyCache(time.Minute, 0),
+		Store:               store,
+		Hasher:              &BCrypt{WorkFactor: 6},
+		TokenURL:            "token-url",
+	}
+
+	authenticate := func(key *rsa.PrivateKey, kid, jti string) error {
+		_, err := f.AuthenticateClient(context.Background(), new(http.Request), url.Values{"client_id": []string{"bar"}, "client_assertion": {mustGenerateRSAAssertion(t, jwt.MapClaims{
+			"sub": "bar",
+			"exp": time.Now().Add(time.Hour).Unix(),
+			"iss": "bar",
+			"jti": jti,
+			"aud": "token-url",
+		}, key, kid)}, "client_assertion_type": []string{at}})
+		return err
+	}
+
+	require.NoError(t, authenticate(key, "kid-foo", "1"))
+	require.NoError(t, authenticate(key, "kid-foo", "2"))
+	assert.Equal(t, 1, requests, "the key set must be served from the cache")
+
+	jwks = &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{KeyID: "kid-bar", Use: "sig", Key: &rotated.PublicKey}}}
+	require.NoError(t, authenticate(rotated, "kid-bar", "3"))
+	assert.Equal(t, 2, requests, "the key set must be fetched again if the key is unknown")
+}
+
+type plaintextSecretClient struct {
+	*DefaultOpenIDConnectClient
+	secret []byte
+}
+
+func (c *plaintextSecretClient) GetPlaintextSecret() []byte {
+	return c.secret
+}
+
+func TestAuthenticateClientWithClientSecretJWT(t *testing.T) {
+	const at = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+	secret := []byte("aaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbbbbbcccccccccccccccccccccddddddddddddddddddddddd")
+
+	newForm := func(jti string) url.Values {
+		return url.Values{"client_assertion": {mustGenerateHSAssertion(t, jwt.MapClaims{
+			"sub": "bar",
+			"exp": time.Now().Add(time.Hour).Unix(),
+			"iss": "bar",
+			"jti": jti,
+			"aud": "token-url",
+		}, nil, "")}, "client_assertion_type": []string{at}}
+	}
+
+	for k, tc := range []struct {
+		d         string
+		client    Client
+		expectErr error
+	}{
+		{
+			d: "should pass because the client has a plaintext secret",
+			client: &plaintextSecretClient{
+				DefaultOpenIDConnectClient: &DefaultOpenIDConnectClient{DefaultClient: &DefaultClient{ID: "bar"}, TokenEndpointAuthMethod: "client_secret_jwt"},
+				secret:                     secret,
+			},
+		},
+		{
+			d: "should fail because the client's secret does not match",
+			client: &plaintextSecretClient{
+				DefaultOpenIDConnectClient: &DefaultOpenIDConnectClient{DefaultClient: &DefaultClient{ID: "bar"}, TokenEndpointAuthMethod: "client_secret_jwt"},
+				secret:                     []byte("another-secret-another-secret-another-secret"),
+			},
+			expectErr: ErrInvalidClient,
+		},
+		{
+			d:         "should fail because the client has no plaintext secret",
+			client:    &DefaultOpenIDConnectClient{DefaultClient: &DefaultClient{ID: "bar"}, TokenEndpointAuthMethod: "client_secret_jwt"},
+			expectErr: ErrInvalidClient,
+		},
+		{
+			d: "should fail because the client uses private_key_jwt",
+			client: &plaintextSecretClient{
+				DefaultOpenIDConnectClient: &DefaultOpenIDConnectClient{DefaultClient: &DefaultClient{ID: "bar"}, TokenEndpointAuthMethod: "private_key_jwt"},
+				secret:                     secret,
+			},
+			expectErr: ErrInvalidClient,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, tc.d), func(t *testing.T) {
+			store := storage.NewMemoryStore()
+			store.Clients["bar"] = tc.client
+			f := &Fosite{Store: store, Hasher: &BCrypt{WorkFactor: 6}, TokenURL: "token-url"}
+
+			c, err := f.AuthenticateClient(context.Background(), new(http.Request), newForm(fmt.Sprintf("jti-%d", k)))
+			if tc.expectErr != nil {
+				require.EqualError(t, err, tc.expectErr.Error())
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.client, c)
+		})
+	}
+}
+
+type staticClientAuthenticationHandler struct {
+	client Client
+}
+
+func (h *staticClientAuthenticationHandler) AuthenticateClient(ctx context.Context, f *Fosite, r *http.Request, form url.Values) (Client, error) {
+	if form.Get("static") == "" {
+		return nil, errors.WithStack(ErrUnknownRequest)
+	}
+	return h.client, nil
+}
+
+func TestAuthenticateClientWithClientAuthenticationHandlers(t *testing.T) {
+	hasher := &BCrypt{WorkFactor: 6}
+	secret, err := hasher.Hash(context.Background(), []byte("bar"))
+	require.NoError(t, err)
+
+	client := &DefaultOpenIDConnectClient{DefaultClient: &DefaultClient{ID: "foo", Secret: secret}, TokenEndpointAuthMethod: "client_secret_post"}
+	store := storage.NewMemoryStore()
+	store.Clients[client.ID] = client
+
+	static := &DefaultClient{ID: "static"}
+	f := &Fosite{Store: store, Hasher: hasher}
+	f.ClientAuthenticationHandlers.Append(&staticClientAuthenticationHandler{client: static})
+	f.ClientAuthenticationHandlers.Append(&staticClientAuthenticationHandler{})
+	f.ClientAuthenticationHandlers.Append(&ClientSecretPostClientAuthenticationHandler{})
+	require.Len(t, f.ClientAuthenticationHandlers, 2)
+
+	c, err := f.AuthenticateClient(context.Background(), new(http.Request), url.Values{"static": {"true"}})
+	require.NoError(t, err)
+	assert.Equal(t, static, c)
+
+	c, err = f.AuthenticateClient(context.Background(), new(http.Request), url.Values{"client_id": {"foo"}, "client_secret": {"bar"}})
+	require.NoError(t, err)
+	assert.Equal(t, client, c)
+
+	// Methods which are not registered are not supported.
+	r := &http.Request{Header: clientBasicAuthHeader("foo", "bar")}
+	_, err = f.AuthenticateClient(context.Background(), r, url.Values{})
+	require.EqualError(t, err, ErrInvalidClient.Error())
+}
+
+type authMethodClient struct {
+	*DefaultClient
+	method string
+}
+
+func (c *authMethodClient) GetTokenEndpointAuthMethod() string {
+	return c.method
+}
+
+func TestAuthenticateClientEnforcesTokenEndpointAuthMethod(t *testing.T) {
+	hasher := &BCrypt{WorkFactor: 6}
+	secret, err := hasher.Hash(context.Background(), []byte("bar"))
+	require.NoError(t, err)
+
+	store := storage.NewMemoryStore()
+	f := &Fosite{Store: store, Hasher: hasher}
+
+	for k, tc := range []struct {
+		method    string
+		r         *http.Request
+		form      url.Values
+		expectErr error
+	}{
+		{method: "client_secret_basic", r: &http.Request{Header: clientBasicAuthHeader("foo", "bar")}, form: url.Values{}},
+		{method: "client_secret_post", r: new(http.Request), form: url.Values{"client_id": {"foo"}, "client_secret": {"bar"}}},
+		{method: "private_key_jwt", r: &http.Request{Header: clientBasicAuthHeader("foo", "bar")}, form: url.Values{}, expectErr: ErrInvalidClient},
+		{method: "client_secret_basic", r: new(http.Request), form: url.Values{"client_id": {"foo"}, "client_secret": {"bar"}}, expectErr: ErrInvalidClient},
+	} {
+		t.Run(fmt.Sprintf("case=%d/method=%s", k, tc.method), func(t *testing.T) {
+			client := &authMethodClient{DefaultClient: &DefaultClient{ID: "foo", Secret: secret}, method: tc.method}
+			store.Clients[client.ID] = client
+
+			c, err := f.AuthenticateClient(context.Background(), tc.r, tc.form)
+			if tc.expectErr != nil {
+				require.EqualError(t, err, tc.expectErr.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, client, c)
+		})
+	}
+}
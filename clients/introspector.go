@@ -0,0 +1,403 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+// Package clients implements clients of the endpoints of fosite based authorization servers, for example for
+// resource servers.
+package clients
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ory/x/errorsx"
+
+	"github.com/ory/fosite"
+)
+
+const (
+	// DefaultIntrospectionFailureThreshold is the default number of consecutive failures opening the circuit of
+	// the Introspector.
+	DefaultIntrospectionFailureThreshold = 5
+
+	// DefaultIntrospectionOpenDuration is the default duration for which the circuit of the Introspector stays
+	// open.
+	DefaultIntrospectionOpenDuration = time.Second * 30
+
+	// DefaultIntrospectionCacheSize is the default maximum number of cached introspection results.
+	DefaultIntrospectionCacheSize = 10000
+
+	// maxIntrospectionResponseSize limits the size of introspection responses.
+	maxIntrospectionResponseSize = 1 << 20
+)
+
+// IntrospectorOptions configure the Introspector returned by NewIntrospector.
+type IntrospectorOptions struct {
+	// URL is the location of the introspection endpoint. Required.
+	URL string
+
+	// ClientID and ClientSecret authenticate the resource server using client_secret_basic.
+	ClientID     string
+	ClientSecret string
+
+	// HTTPClient is used to call the introspection endpoint. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+
+	// Timeout limits the duration of a single introspection request. Defaults to no timeout besides the one of
+	// HTTPClient and the context.
+	Timeout time.Duration
+
+	// CacheTTL, if set, caches the results of active tokens for the given duration, but never beyond the
+	// expiry of the token. Revoked tokens therefore remain active for up to CacheTTL. Inactive tokens are not
+	// cached.
+	CacheTTL time.Duration
+
+	// CacheSize is the maximum number of cached results. Defaults to DefaultIntrospectionCacheSize.
+	CacheSize int
+
+	// FailureThreshold is the number of consecutive failures, such as network errors or server errors, after
+	// which the circuit opens and introspection fails immediately. Defaults to
+	// DefaultIntrospectionFailureThreshold.
+	FailureThreshold int
+
+	// OpenDuration is the duration the circuit stays open before introspection requests are attempted again.
+	// Defaults to DefaultIntrospectionOpenDuration.
+	OpenDuration time.Duration
+}
+
+// IntrospectionResult is the response of an introspection endpoint for an active token, see
+// https://tools.ietf.org/html/rfc7662#section-2.2
+type IntrospectionResult struct {
+	Active    bool
+	Scope     fosite.Arguments
+	ClientID  string
+	Username  string
+	TokenType string
+	TokenUse  string
+	Subject   string
+	Issuer    string
+	JTI       string
+	Audience  []string
+	ExpiresAt time.Time
+	IssuedAt  time.Time
+	NotBefore time.Time
+
+	// Extra contains the members of the response which have no field of their own.
+	Extra map[string]interface{}
+}
+
+// Introspector validates tokens using the introspection endpoint of an authorization server as defined in
+// https://tools.ietf.org/html/rfc7662. It caches results and stops calling the endpoint while it is failing.
+// Failures are reported using the errors of fosite: ErrInactiveToken if the token is not active, the error of the
+// endpoint's error response, for example ErrInvalidClient, and ErrTemporarilyUnavailable if the endpoint can not be
+// reached.
+type Introspector struct {
+	opts IntrospectorOptions
+
+	cache   map[string]*IntrospectionResult
+	expires map[string]time.Time
+
+	failures  int
+	openUntil time.Time
+	sync.Mutex
+}
+
+// NewIntrospector returns an Introspector configured by the given options.
+func NewIntrospector(opts IntrospectorOptions) *Introspector {
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = http.DefaultClient
+	}
+	if opts.CacheSize == 0 {
+		opts.CacheSize = DefaultIntrospectionCacheSize
+	}
+	if opts.FailureThreshold == 0 {
+		opts.FailureThreshold = DefaultIntrospectionFailureThreshold
+	}
+	if opts.OpenDuration == 0 {
+		opts.OpenDuration = DefaultIntrospectionOpenDuration
+	}
+	return &Introspector{
+		opts:    opts,
+		cache:   make(map[string]*IntrospectionResult),
+		expires: make(map[string]time.Time),
+	}
+}
+
+// IntrospectToken introspects the token. The token type hint is optional. The scopes, if any, are sent as the
+// "scope" parameter, which fosite uses to check that the scopes were granted.
+func (i *Introspector) IntrospectToken(ctx context.Context, token string, tokenTypeHint fosite.TokenType, scopes ...string) (*IntrospectionResult, error) {
+	key := cacheKey(token, tokenTypeHint, scopes)
+	if result, ok := i.cached(key); ok {
+		return result, nil
+	}
+
+	if err := i.allow(); err != nil {
+		return nil, err
+	}
+
+	result, err := i.introspect(ctx, token, tokenTypeHint, scopes)
+	i.record(err)
+	if err != nil {
+		return nil, err
+	}
+
+	if !result.Active {
+		return nil, errorsx.WithStack(fosite.ErrInactiveToken)
+	}
+
+	i.store(key, result)
+	return result, nil
+}
+
+func (i *Introspector) introspect(ctx context.Context, token string, tokenTypeHint fosite.TokenType, scopes []string) (*IntrospectionResult, error) {
+	if i.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, i.opts.Timeout)
+		defer cancel()
+	}
+
+	form := url.Values{"token": {token}}
+	if tokenTypeHint != "" {
+		form.Set("token_type_hint", string(tokenTypeHint))
+	}
+	if len(scopes) > 0 {
+		form.Set("scope", strings.Join(scopes, " "))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", i.opts.URL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, errorsx.WithStack(fosite.ErrMisconfiguration.WithHintf("Unable to create the introspection request for '%s'.", i.opts.URL).WithWrap(err).WithDebug(err.Error()))
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if i.opts.ClientID != "" {
+		// https://tools.ietf.org/html/rfc6749#section-2.3.1
+		req.SetBasicAuth(url.QueryEscape(i.opts.ClientID), url.QueryEscape(i.opts.ClientSecret))
+	}
+	if id := fosite.RequestIDFromContext(ctx); id != "" {
+		req.Header.Set(fosite.RequestIDHeader, id)
+	}
+
+	res, err := i.opts.HTTPClient.Do(req)
+	if err != nil {
+		return nil, errorsx.WithStack(fosite.ErrTemporarilyUnavailable.WithHint("Unable to reach the introspection endpoint.").WithWrap(err).WithDebug(err.Error()))
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, maxIntrospectionResponseSize))
+	if err != nil {
+		return nil, errorsx.WithStack(fosite.ErrTemporarilyUnavailable.WithHint("Unable to read the introspection response.").WithWrap(err).WithDebug(err.Error()))
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, responseError(res.StatusCode, body)
+	}
+
+	result, err := decodeIntrospectionResult(body)
+	if err != nil {
+		return nil, errorsx.WithStack(fosite.ErrServerError.WithHint("Unable to decode the introspection response.").WithWrap(err).WithDebug(err.Error()))
+	}
+	return result, nil
+}
+
+// responseError returns the error of an error response of the introspection endpoint.
+func responseError(status int, body []byte) error {
+	rfcerr := new(fosite.RFC6749Error)
+	if err := json.Unmarshal(body, rfcerr); err != nil || rfcerr.ErrorField == "" {
+		rfcerr = fosite.ErrServerError.WithHintf("The introspection endpoint responded with status code %d.", status)
+		if status >= http.StatusInternalServerError {
+			rfcerr = fosite.ErrTemporarilyUnavailable.WithHintf("The introspection endpoint responded with status code %d.", status)
+		}
+		return errorsx.WithStack(rfcerr.WithDebug(string(body)))
+	}
+
+	rfcerr.CodeField = status
+	return errorsx.WithStack(rfcerr)
+}
+
+// cached returns the cached result of the key, if it did not expire.
+func (i *Introspector) cached(key string) (*IntrospectionResult, bool) {
+	if i.opts.CacheTTL <= 0 {
+		return nil, false
+	}
+
+	i.Lock()
+	defer i.Unlock()
+
+	result, ok := i.cache[key]
+	if !ok {
+		return nil, false
+	} else if time.Now().After(i.expires[key]) {
+		delete(i.cache, key)
+		delete(i.expires, key)
+		return nil, false
+	}
+	return result, true
+}
+
+func (i *Introspector) store(key string, result *IntrospectionResult) {
+	if i.opts.CacheTTL <= 0 {
+		return
+	}
+
+	expires := time.Now().Add(i.opts.CacheTTL)
+	if !result.ExpiresAt.IsZero() && result.ExpiresAt.Before(expires) {
+		expires = result.ExpiresAt
+	}
+
+	i.Lock()
+	defer i.Unlock()
+
+	if len(i.cache) >= i.opts.CacheSize {
+		now := time.Now()
+		for k, e := range i.expires {
+			if now.After(e) {
+				delete(i.cache, k)
+				delete(i.expires, k)
+			}
+		}
+		if len(i.cache) >= i.opts.CacheSize {
+			return
+		}
+	}
+
+	i.cache[key] = result
+	i.expires[key] = expires
+}
+
+// allow returns ErrTemporarilyUnavailable while the circuit is open.
+func (i *Introspector) allow() error {
+	i.Lock()
+	defer i.Unlock()
+
+	if time.Now().Before(i.openUntil) {
+		return errorsx.WithStack(fosite.ErrTemporarilyUnavailable.WithHintf("The introspection endpoint failed %d times in a row, introspection is suspended until '%s'.", i.failures, i.openUntil))
+	}
+	return nil
+}
+
+// record opens the circuit after FailureThreshold consecutive failures. Only failures of the endpoint count,
+// error responses such as invalid_client do not.
+func (i *Introspector) record(err error) {
+	i.Lock()
+	defer i.Unlock()
+
+	if err == nil || !isFailure(err) {
+		i.failures = 0
+		return
+	}
+
+	i.failures++
+	if i.failures >= i.opts.FailureThreshold {
+		i.openUntil = time.Now().Add(i.opts.OpenDuration)
+	}
+}
+
+func isFailure(err error) bool {
+	return fosite.ErrorToRFC6749Error(err).CodeField >= http.StatusInternalServerError
+}
+
+func cacheKey(token string, tokenTypeHint fosite.TokenType, scopes []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(append([]string{token, string(tokenTypeHint)}, scopes...), "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+func decodeIntrospectionResult(body []byte) (*IntrospectionResult, error) {
+	var claims map[string]interface{}
+	d := json.NewDecoder(bytes.NewReader(body))
+	d.UseNumber()
+	if err := d.Decode(&claims); err != nil {
+		return nil, err
+	}
+
+	result := &IntrospectionResult{Extra: map[string]interface{}{}}
+	for k, v := range claims {
+		switch k {
+		case "active":
+			result.Active, _ = v.(bool)
+		case "scope":
+			if s, ok := v.(string); ok && s != "" {
+				result.Scope = strings.Split(s, " ")
+			}
+		case "client_id":
+			result.ClientID, _ = v.(string)
+		case "username":
+			result.Username, _ = v.(string)
+		case "token_type":
+			result.TokenType, _ = v.(string)
+		case "token_use":
+			result.TokenUse, _ = v.(string)
+		case "sub":
+			result.Subject, _ = v.(string)
+		case "iss":
+			result.Issuer, _ = v.(string)
+		case "jti":
+			result.JTI, _ = v.(string)
+		case "aud":
+			result.Audience = toStrings(v)
+		case "exp":
+			result.ExpiresAt = toTime(v)
+		case "iat":
+			result.IssuedAt = toTime(v)
+		case "nbf":
+			result.NotBefore = toTime(v)
+		default:
+			result.Extra[k] = v
+		}
+	}
+	return result, nil
+}
+
+func toStrings(v interface{}) []string {
+	switch a := v.(type) {
+	case string:
+		return []string{a}
+	case []interface{}:
+		result := make([]string, 0, len(a))
+		for _, s := range a {
+			if s, ok := s.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+func toTime(v interface{}) time.Time {
+	if n, ok := v.(json.Number); ok {
+		if i, err := n.Int64(); err == nil {
+			return time.Unix(i, 0).UTC()
+		} else if f, err := n.Float64(); err == nil {
+			return time.Unix(int64(f), 0).UTC()
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,160 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/storage"
+)
+
+func TestIntrospector(t *testing.T) {
+	config := &compose.Config{}
+	provider := compose.Compose(config, storage.NewExampleStore(), compose.NewOAuth2HMACStrategy(config, []byte("some-secret-thats-random-some-secret-thats-random-"), nil), nil,
+		compose.OAuth2ClientCredentialsGrantFactory, compose.OAuth2TokenIntrospectionFactory)
+
+	var introspections int32
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		ctx := context.Background()
+		switch r.URL.Path {
+		case "/token":
+			ar, err := provider.NewAccessRequest(ctx, r, new(fosite.DefaultSession))
+			if err != nil {
+				provider.WriteAccessError(rw, ar, err)
+				return
+			}
+			ar.GrantScope("photos")
+			response, err := provider.NewAccessResponse(ctx, ar)
+			if err != nil {
+				provider.WriteAccessError(rw, ar, err)
+				return
+			}
+			provider.WriteAccessResponse(rw, ar, response)
+		case "/introspect":
+			atomic.AddInt32(&introspections, 1)
+			ir, err := provider.NewIntrospectionRequest(ctx, r, new(fosite.DefaultSession))
+			if err != nil {
+				provider.WriteIntrospectionError(rw, err)
+				return
+			}
+			provider.WriteIntrospectionResponse(rw, ir)
+		}
+	}))
+	defer ts.Close()
+
+	req, err := http.NewRequest("POST", ts.URL+"/token", strings.NewReader(url.Values{"grant_type": {"client_credentials"}, "scope": {"photos"}}.Encode()))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth("my-client", "foobar")
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&token))
+
+	introspector := NewIntrospector(IntrospectorOptions{URL: ts.URL + "/introspect", ClientID: "my-client", ClientSecret: "foobar", CacheTTL: time.Minute})
+
+	for k, c := range []struct {
+		d         string
+		token     string
+		scopes    []string
+		expectErr error
+	}{
+		{d: "should pass", token: token.AccessToken, scopes: []string{"photos"}},
+		{d: "should fail because the scope was not granted", token: token.AccessToken, scopes: []string{"openid"}, expectErr: fosite.ErrInactiveToken},
+		{d: "should fail because the token is unknown", token: "foo.bar", expectErr: fosite.ErrInactiveToken},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			result, err := introspector.IntrospectToken(context.Background(), c.token, fosite.AccessToken, c.scopes...)
+			if c.expectErr != nil {
+				assert.True(t, errors.Is(err, c.expectErr), "%+v", err)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, result.Active)
+			assert.Equal(t, "my-client", result.ClientID)
+			assert.Equal(t, fosite.Arguments{"photos"}, result.Scope)
+			assert.False(t, result.ExpiresAt.IsZero())
+		})
+	}
+
+	t.Run("case=should cache active tokens", func(t *testing.T) {
+		before := atomic.LoadInt32(&introspections)
+		_, err := introspector.IntrospectToken(context.Background(), token.AccessToken, fosite.AccessToken, "photos")
+		require.NoError(t, err)
+		assert.Equal(t, before, atomic.LoadInt32(&introspections))
+	})
+
+	t.Run("case=should return the error of the endpoint", func(t *testing.T) {
+		introspector := NewIntrospector(IntrospectorOptions{URL: ts.URL + "/introspect", ClientID: "my-client", ClientSecret: "wrong"})
+		_, err := introspector.IntrospectToken(context.Background(), token.AccessToken, "")
+		assert.True(t, errors.Is(err, fosite.ErrRequestUnauthorized), "%+v", err)
+	})
+}
+
+func TestIntrospectorCircuitBreaker(t *testing.T) {
+	var calls int32
+	var healthy int32
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if atomic.LoadInt32(&healthy) == 0 {
+			rw.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = rw.Write([]byte(`{"active":true,"aud":"api","exp":1e10}`))
+	}))
+	defer ts.Close()
+
+	introspector := NewIntrospector(IntrospectorOptions{URL: ts.URL, FailureThreshold: 2, OpenDuration: time.Millisecond * 100})
+
+	for i := 0; i < 4; i++ {
+		_, err := introspector.IntrospectToken(context.Background(), "token", "")
+		assert.True(t, errors.Is(err, fosite.ErrTemporarilyUnavailable), "%+v", err)
+	}
+	assert.EqualValues(t, 2, atomic.LoadInt32(&calls), "the circuit should open after two failures")
+
+	atomic.StoreInt32(&healthy, 1)
+	time.Sleep(time.Millisecond * 150)
+
+	result, err := introspector.IntrospectToken(context.Background(), "token", "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"api"}, result.Audience)
+	assert.EqualValues(t, 3, atomic.LoadInt32(&calls))
+}
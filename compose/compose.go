@@ -25,6 +25,7 @@ import (
 	"crypto/rsa"
 
 	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/openid"
 	"github.com/ory/fosite/token/jwt"
 )
 
@@ -52,17 +53,30 @@ type Factory func(config *Config, storage interface{}, strategy interface{}) int
 //  )
 //
 // Compose makes use of interface{} types in order to be able to handle a all types of stores, strategies and handlers.
+// Use New to validate the configuration at startup instead.
 func Compose(config *Config, storage interface{}, strategy interface{}, hasher fosite.Hasher, factories ...Factory) fosite.OAuth2Provider {
 	if hasher == nil {
 		hasher = &fosite.BCrypt{WorkFactor: config.GetHashCost()}
 	}
 
+	if config.EnforceFAPI2 {
+		config = config.withFAPI2()
+		factories = append(factories[:len(factories):len(factories)], fapi2PARFactory)
+	}
+
+	if config.EnforceOAuth21 {
+		config = config.withOAuth21()
+		// The PKCE handler must be added after the authorize code handler. It is ignored if already added.
+		factories = append(factories[:len(factories):len(factories)], oauth21PKCEFactory)
+	}
+
 	f := &fosite.Fosite{
 		Store:                        storage.(fosite.Storage),
 		AuthorizeEndpointHandlers:    fosite.AuthorizeEndpointHandlers{},
 		TokenEndpointHandlers:        fosite.TokenEndpointHandlers{},
 		TokenIntrospectionHandlers:   fosite.TokenIntrospectionHandlers{},
 		RevocationHandlers:           fosite.RevocationHandlers{},
+		DeviceEndpointHandlers:       fosite.DeviceEndpointHandlers{},
 		Hasher:                       hasher,
 		ScopeStrategy:                config.GetScopeStrategy(),
 		AudienceMatchingStrategy:     config.GetAudienceStrategy(),
@@ -72,12 +86,84 @@ func Compose(config *Config, storage interface{}, strategy interface{}, hasher f
 		MinParameterEntropy:          config.GetMinParameterEntropy(),
 		UseLegacyErrorFormat:         config.UseLegacyErrorFormat,
 		ClientAuthenticationStrategy: config.GetClientAuthenticationStrategy(),
+		ClientAuthenticationHandlers: config.GetClientAuthenticationHandlers(),
 		ResponseModeHandlerExtension: config.ResponseModeHandlerExtension,
 		MessageCatalog:               config.MessageCatalog,
+
+		PushedAuthorizeEndpointHandlers: fosite.PushedAuthorizeEndpointHandlers{},
+		PushedAuthorizeRequestURIPrefix: config.GetPushedAuthorizeRequestURIPrefix(),
+		EnforcePushedAuthorize:          config.EnforcePushedAuthorize,
+
+		BackchannelAuthenticationEndpointHandlers: fosite.BackchannelAuthenticationEndpointHandlers{},
+
+		DPoPStrategy:      config.DPoPStrategy,
+		DPoPProofLifespan: config.GetDPoPProofLifespan(),
+
+		TLSClientCertificateBoundAccessTokens: config.TLSClientCertificateBoundAccessTokens,
+
+		JARMStrategy: config.JARMStrategy,
+
+		EnableJWTSecuredAuthorizationRequests: config.EnableJWTSecuredAuthorizationRequests,
+		RequireSignedRequestObject:            config.RequireSignedRequestObject,
+
+		AuthorizationDetailsTypes: config.AuthorizationDetailsTypes,
+
+		IntrospectionJWTStrategy: config.IntrospectionJWTStrategy,
+		EventListener:            config.EventListener,
+		Tracer:                   config.GetTracer(),
+		MetricsReporter:          config.MetricsReporter,
+
+		ConsentStrategy: config.ConsentStrategy,
+
+		IDTokenHintStrategy:  config.IDTokenHintStrategy,
+		SessionStateStrategy: config.SessionStateStrategy,
+
+		RejectFrontChannelAccessTokens:       config.EnforceOAuth21,
+		RequireSenderConstrainedAccessTokens: config.EnforceFAPI2,
+
+		IssuerProvider: config.IssuerProvider,
+		StoragePolicy:  config.StoragePolicy,
+
+		SanitationWhiteList: config.SanitationWhiteList,
+		ErrorWriter:         config.ErrorWriter,
+		DebugPolicy:         config.DebugPolicy,
+		TokenQuotaPolicy:    config.TokenQuotaPolicy,
+
+		FailedAuthenticationRecorder:  config.FailedAuthenticationRecorder,
+		ClientSecretRehasher:          config.ClientSecretRehasher,
+		JSONWebKeyCache:               config.JSONWebKeyCache,
+		IntrospectionResponseEnricher: config.IntrospectionResponseEnricher,
+		IntrospectionPolicy:           config.IntrospectionPolicy,
+		GrantStorage:                  config.GrantStorage,
+		GrantManagementActionRequired: config.GrantManagementActionRequired,
+
+		OmitAuthorizationResponseIssuer:    config.OmitAuthorizationResponseIssuer,
+		RequireAuthorizationResponseIssuer: config.RequireAuthorizationResponseIssuer,
+		RedirectURIMatcher:                 config.RedirectURIMatcher,
+		SubjectIdentifierAlgorithms:        config.SubjectIdentifierAlgorithms,
+		OfflineAccessPolicy:                config.OfflineAccessPolicy,
+		AuthorizeRequestValidators:         config.AuthorizeRequestValidators,
+		AccessRequestMiddlewares:           config.AccessRequestMiddlewares,
+		ResponseTypeRegistry:               config.ResponseTypeRegistry,
+	}
+
+	if f.IDTokenHintStrategy == nil {
+		if cs, ok := strategy.(*CommonStrategy); ok {
+			if ds, ok := cs.OpenIDConnectTokenStrategy.(*openid.DefaultStrategy); ok {
+				f.IDTokenHintStrategy = ds.JWTStrategy
+			}
+		}
 	}
 
+	// Client authentication handlers created by factories are tried before the configured ones.
+	var clientAuthenticationHandlers fosite.ClientAuthenticationHandlers
 	for _, factory := range factories {
 		res := factory(config, storage, strategy)
+		if config.EnforceOAuth21 && !isAllowedByOAuth21(res) {
+			continue
+		} else if config.EnforceFAPI2 && !isAllowedByFAPI2(res) {
+			continue
+		}
 		if ah, ok := res.(fosite.AuthorizeEndpointHandler); ok {
 			f.AuthorizeEndpointHandlers.Append(ah)
 		}
@@ -90,6 +176,25 @@ func Compose(config *Config, storage interface{}, strategy interface{}, hasher f
 		if rh, ok := res.(fosite.RevocationHandler); ok {
 			f.RevocationHandlers.Append(rh)
 		}
+		if dh, ok := res.(fosite.DeviceEndpointHandler); ok {
+			f.DeviceEndpointHandlers.Append(dh)
+		}
+		if ph, ok := res.(fosite.PushedAuthorizeEndpointHandler); ok {
+			f.PushedAuthorizeEndpointHandlers.Append(ph)
+		}
+		if bh, ok := res.(fosite.BackchannelAuthenticationEndpointHandler); ok {
+			f.BackchannelAuthenticationEndpointHandlers.Append(bh)
+		}
+		if ch, ok := res.(fosite.ClientAuthenticationHandler); ok {
+			clientAuthenticationHandlers.Append(ch)
+		}
+	}
+
+	if len(clientAuthenticationHandlers) > 0 {
+		for _, ch := range f.ClientAuthenticationHandlers {
+			clientAuthenticationHandlers.Append(ch)
+		}
+		f.ClientAuthenticationHandlers = clientAuthenticationHandlers
 	}
 
 	return f
@@ -103,6 +208,9 @@ func ComposeAllEnabled(config *Config, storage interface{}, secret []byte, key *
 		&CommonStrategy{
 			CoreStrategy:               NewOAuth2HMACStrategy(config, secret, nil),
 			OpenIDConnectTokenStrategy: NewOpenIDConnectStrategy(config, key),
+			RFC8628CodeStrategy:        NewDeviceStrategy(config, secret, nil),
+			AuthRequestIDStrategy:      NewCIBAStrategy(config, secret, nil),
+			PreAuthorizedCodeStrategy:  NewPreAuthorizedCodeStrategy(config, secret, nil),
 			JWTStrategy: &jwt.RS256JWTStrategy{
 				PrivateKey: key,
 			},
@@ -115,6 +223,11 @@ func ComposeAllEnabled(config *Config, storage interface{}, secret []byte, key *
 		OAuth2RefreshTokenGrantFactory,
 		OAuth2ResourceOwnerPasswordCredentialsFactory,
 		RFC7523AssertionGrantFactory,
+		RFC7522AssertionGrantFactory,
+		RFC8628DeviceFactory,
+		RFC8693TokenExchangeFactory,
+		OpenIDConnectCIBAFactory,
+		OID4VCIPreAuthorizedCodeFactory,
 
 		OpenIDConnectExplicitFactory,
 		OpenIDConnectImplicitFactory,
@@ -125,5 +238,7 @@ func ComposeAllEnabled(config *Config, storage interface{}, secret []byte, key *
 		OAuth2TokenRevocationFactory,
 
 		OAuth2PKCEFactory,
+
+		PushedAuthorizeHandlerFactory,
 	)
 }
@@ -0,0 +1,51 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package compose
+
+import (
+	"github.com/ory/fosite/handler/ciba"
+	"github.com/ory/fosite/handler/oauth2"
+	"github.com/ory/fosite/handler/openid"
+)
+
+// OpenIDConnectCIBAFactory creates an OpenID Connect Client Initiated Backchannel Authentication handler and
+// registers the backchannel authentication endpoint as well as the CIBA grant at the token endpoint.
+func OpenIDConnectCIBAFactory(config *Config, storage interface{}, strategy interface{}) interface{} {
+	return &ciba.Handler{
+		AuthRequestIDStrategy: strategy.(ciba.AuthRequestIDStrategy),
+		CIBAStorage:           storage.(ciba.CIBAStorage),
+		AccessTokenStrategy:   strategy.(oauth2.AccessTokenStrategy),
+		RefreshTokenStrategy:  strategy.(oauth2.RefreshTokenStrategy),
+		AccessTokenStorage:    storage.(oauth2.AccessTokenStorage),
+		RefreshTokenStorage:   storage.(oauth2.RefreshTokenStorage),
+		IDTokenHandleHelper: &openid.IDTokenHandleHelper{
+			IDTokenStrategy: strategy.(openid.OpenIDConnectTokenStrategy),
+			LogoutStorage:   logoutStorage(storage),
+		},
+		Notifier:              config.GetCIBANotifier(),
+		AccessTokenLifespan:   config.GetAccessTokenLifespan(),
+		RefreshTokenLifespan:  config.GetRefreshTokenLifespan(),
+		AuthRequestIDLifespan: config.GetCIBAAuthRequestIDLifespan(),
+		PollingInterval:       config.GetCIBAPollingInterval(),
+		RefreshTokenScopes:    config.GetRefreshTokenScopes(),
+	}
+}
@@ -0,0 +1,52 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package compose
+
+import (
+	"github.com/ory/fosite/handler/extension"
+	"github.com/ory/fosite/handler/oauth2"
+)
+
+// ExtensionGrantFactory returns a factory which creates a handler for the extension grant type, see
+// https://tools.ietf.org/html/rfc6749#section-4.5. The validator checks the grant specific parameters, the factory
+// takes care of client, scope and audience checks and issuing the access token. It returns an error if the grant
+// type is not an absolute URI. Use extension.Handler directly for grants which do not require client
+// authentication.
+func ExtensionGrantFactory(grantType string, validator extension.GrantValidator) (Factory, error) {
+	if err := extension.ValidateGrantType(grantType); err != nil {
+		return nil, err
+	}
+
+	return func(config *Config, storage interface{}, strategy interface{}) interface{} {
+		return &extension.Handler{
+			GrantType:                grantType,
+			Validator:                validator,
+			ScopeStrategy:            config.GetScopeStrategy(),
+			AudienceMatchingStrategy: config.GetAudienceStrategy(),
+			HandleHelper: &oauth2.HandleHelper{
+				AccessTokenStrategy: strategy.(oauth2.AccessTokenStrategy),
+				AccessTokenStorage:  storage.(oauth2.AccessTokenStorage),
+				AccessTokenLifespan: config.GetAccessTokenLifespan(),
+			},
+		}
+	}, nil
+}
@@ -0,0 +1,101 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package compose
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/openid"
+)
+
+// ComposeFAPI2 validates the config against the FAPI 2.0 Security Profile using ValidateFAPI2 and composes a provider
+// with EnforceFAPI2 enabled. See Compose for the arguments.
+func ComposeFAPI2(config *Config, storage interface{}, strategy interface{}, hasher fosite.Hasher, factories ...Factory) (fosite.OAuth2Provider, error) {
+	if err := config.ValidateFAPI2(); err != nil {
+		return nil, err
+	}
+
+	c := *config
+	c.EnforceFAPI2 = true
+	return Compose(&c, storage, strategy, hasher, factories...), nil
+}
+
+// ValidateFAPI2 returns an error describing all settings which can not be used with the FAPI 2.0 Security Profile
+// and are not applied by EnforceFAPI2 itself.
+func (c *Config) ValidateFAPI2() error {
+	var violations []string
+
+	if c.DPoPStrategy == nil && !c.TLSClientCertificateBoundAccessTokens {
+		violations = append(violations, "access tokens must be sender-constrained, set DPoPStrategy or TLSClientCertificateBoundAccessTokens")
+	}
+
+	if c.EnablePKCEPlainChallengeMethod {
+		violations = append(violations, "the PKCE plain challenge method must not be enabled")
+	}
+
+	for _, h := range c.ClientAuthenticationHandlers {
+		switch h.(type) {
+		case *fosite.PrivateKeyJWTClientAuthenticationHandler, *fosite.TLSClientAuthenticationHandler:
+		default:
+			violations = append(violations, fmt.Sprintf("the client authentication handler %T must not be used, only private_key_jwt and tls_client_auth are allowed", h))
+		}
+	}
+
+	if len(violations) > 0 {
+		return fmt.Errorf("the configuration does not comply with FAPI 2.0: %s", strings.Join(violations, "; "))
+	}
+	return nil
+}
+
+// withFAPI2 returns a copy of the config with the settings required by FAPI 2.0.
+func (c *Config) withFAPI2() *Config {
+	config := *c
+	config.EnforceOAuth21 = true
+	config.EnforcePushedAuthorize = true
+	if len(config.ClientAuthenticationHandlers) == 0 {
+		config.ClientAuthenticationHandlers = fosite.ClientAuthenticationHandlers{
+			&fosite.PrivateKeyJWTClientAuthenticationHandler{},
+			&fosite.TLSClientAuthenticationHandler{},
+		}
+	}
+	return &config
+}
+
+// isAllowedByFAPI2 returns false for the handlers of response types returning anything but the authorization code
+// from the authorization endpoint.
+func isAllowedByFAPI2(handler interface{}) bool {
+	switch handler.(type) {
+	case *openid.OpenIDConnectImplicitHandler, *openid.OpenIDConnectHybridHandler:
+		return false
+	}
+	return true
+}
+
+// fapi2PARFactory creates a PAR handler if the storage supports it.
+func fapi2PARFactory(config *Config, storage interface{}, strategy interface{}) interface{} {
+	if _, ok := storage.(fosite.PARStorage); !ok {
+		return nil
+	}
+	return PushedAuthorizeHandlerFactory(config, storage, strategy)
+}
@@ -0,0 +1,205 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package compose
+
+import (
+	"container/list"
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/ory/x/errorsx"
+
+	"github.com/ory/fosite"
+)
+
+// TenantContextKey holds the ID of the tenant of a request routed by a MultiTenantProvider.
+const TenantContextKey = fosite.ContextKey("tenant")
+
+// TenantResolver returns the tenant a request is addressed to, for example by its host or path.
+type TenantResolver interface {
+	ResolveTenant(ctx context.Context, r *http.Request) (string, error)
+}
+
+// TenantResolverFunc is a function implementing TenantResolver.
+type TenantResolverFunc func(ctx context.Context, r *http.Request) (string, error)
+
+func (f TenantResolverFunc) ResolveTenant(ctx context.Context, r *http.Request) (string, error) {
+	return f(ctx, r)
+}
+
+// Tenant is the configuration of a single tenant of a MultiTenantProvider.
+type Tenant struct {
+	// Config configures the tenant, for example its IDTokenIssuer and ScopeStrategy.
+	Config *Config
+
+	// Storage stores the clients and tokens of the tenant.
+	Storage interface{}
+
+	// Strategy issues the tokens of the tenant using its signing keys, for example a CommonStrategy.
+	Strategy interface{}
+}
+
+// TenantSource returns the configuration of a tenant.
+type TenantSource interface {
+	GetTenant(ctx context.Context, id string) (*Tenant, error)
+}
+
+// StaticTenants is a TenantSource serving a fixed set of tenants.
+type StaticTenants map[string]*Tenant
+
+func (t StaticTenants) GetTenant(_ context.Context, id string) (*Tenant, error) {
+	tenant, ok := t[id]
+	if !ok {
+		return nil, errorsx.WithStack(fosite.ErrInvalidRequest.WithHintf("The tenant '%s' does not exist.", id))
+	}
+	return tenant, nil
+}
+
+// MultiTenantProvider routes requests to the OAuth2Provider of their tenant. Providers are composed from the
+// tenant's configuration on first use using the same factories for all tenants, and are shared by all requests of
+// the tenant. At most MaxTenants providers are kept; the least recently used provider is dropped when a provider
+// for another tenant is composed.
+//
+//	provider := compose.NewMultiTenantProvider(resolver, tenants, compose.OAuth2AuthorizeExplicitFactory)
+//
+//	func tokenEndpoint(rw http.ResponseWriter, r *http.Request) {
+//		ctx, oauth2, err := provider.ProviderForRequest(r.Context(), r)
+//		// ...
+//		ar, err := oauth2.NewAccessRequest(ctx, r, new(fosite.DefaultSession))
+//	}
+type MultiTenantProvider struct {
+	Resolver TenantResolver
+	Tenants  TenantSource
+
+	// Factories create the handlers of each tenant.
+	Factories []Factory
+
+	// Hasher is shared by all tenants. Defaults to BCrypt using the hash cost of the tenant's Config.
+	Hasher fosite.Hasher
+
+	// MaxTenants limits the number of providers kept in memory. Zero means no limit.
+	MaxTenants int
+
+	mu        sync.Mutex
+	providers map[string]*list.Element
+	lru       *list.List
+}
+
+type tenantProvider struct {
+	id       string
+	provider fosite.OAuth2Provider
+}
+
+// NewMultiTenantProvider returns a MultiTenantProvider composing the providers of the tenants using factories.
+func NewMultiTenantProvider(resolver TenantResolver, tenants TenantSource, factories ...Factory) *MultiTenantProvider {
+	return &MultiTenantProvider{
+		Resolver:  resolver,
+		Tenants:   tenants,
+		Factories: factories,
+	}
+}
+
+// ProviderForRequest resolves the tenant of the request and returns its provider. The returned context carries
+// the request and the tenant ID, and should be passed to all methods of the provider handling the request.
+func (p *MultiTenantProvider) ProviderForRequest(ctx context.Context, r *http.Request) (context.Context, fosite.OAuth2Provider, error) {
+	id, err := p.Resolver.ResolveTenant(ctx, r)
+	if err != nil {
+		return ctx, nil, err
+	}
+
+	provider, err := p.Provider(ctx, id)
+	if err != nil {
+		return ctx, nil, err
+	}
+
+	ctx = context.WithValue(ctx, fosite.RequestContextKey, r)
+	ctx = context.WithValue(ctx, TenantContextKey, id)
+	return ctx, provider, nil
+}
+
+// Provider returns the provider of the tenant, composing it if it is not kept in memory.
+func (p *MultiTenantProvider) Provider(ctx context.Context, id string) (fosite.OAuth2Provider, error) {
+	if provider, ok := p.cached(id); ok {
+		return provider, nil
+	}
+
+	tenant, err := p.Tenants.GetTenant(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	hasher := p.Hasher
+	if hasher == nil {
+		hasher = &fosite.BCrypt{WorkFactor: tenant.Config.GetHashCost()}
+	}
+
+	return p.store(id, Compose(tenant.Config, tenant.Storage, tenant.Strategy, hasher, p.Factories...)), nil
+}
+
+// Evict drops the provider of the tenant, for example after its configuration changed. The provider is composed
+// again on the next request of the tenant.
+func (p *MultiTenantProvider) Evict(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if e, ok := p.providers[id]; ok {
+		p.lru.Remove(e)
+		delete(p.providers, id)
+	}
+}
+
+func (p *MultiTenantProvider) cached(id string) (fosite.OAuth2Provider, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	e, ok := p.providers[id]
+	if !ok {
+		return nil, false
+	}
+	p.lru.MoveToFront(e)
+	return e.Value.(*tenantProvider).provider, true
+}
+
+func (p *MultiTenantProvider) store(id string, provider fosite.OAuth2Provider) fosite.OAuth2Provider {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.providers == nil {
+		p.providers = make(map[string]*list.Element)
+		p.lru = list.New()
+	}
+
+	// Another request of the tenant may have composed the provider concurrently.
+	if e, ok := p.providers[id]; ok {
+		p.lru.MoveToFront(e)
+		return e.Value.(*tenantProvider).provider
+	}
+
+	p.providers[id] = p.lru.PushFront(&tenantProvider{id: id, provider: provider})
+	for p.MaxTenants > 0 && p.lru.Len() > p.MaxTenants {
+		oldest := p.lru.Back()
+		p.lru.Remove(oldest)
+		delete(p.providers, oldest.Value.(*tenantProvider).id)
+	}
+	return provider
+}
@@ -42,6 +42,8 @@ func OAuth2AuthorizeExplicitFactory(config *Config, storage interface{}, strateg
 		TokenRevocationStorage:   storage.(oauth2.TokenRevocationStorage),
 		IsRedirectURISecure:      config.GetRedirectSecureChecker(),
 		RefreshTokenScopes:       config.GetRefreshTokenScopes(),
+		RefreshTokenMaxLifespan:  config.RefreshTokenMaxLifespan,
+		RequireOfflineAccess:     config.OfflineAccessPolicy != nil,
 	}
 }
 
@@ -56,6 +58,7 @@ func OAuth2ClientCredentialsGrantFactory(config *Config, storage interface{}, st
 		},
 		ScopeStrategy:            config.GetScopeStrategy(),
 		AudienceMatchingStrategy: config.GetAudienceStrategy(),
+		DefaultScopePolicy:       config.ClientCredentialsScopePolicy,
 	}
 }
 
@@ -71,6 +74,11 @@ func OAuth2RefreshTokenGrantFactory(config *Config, storage interface{}, strateg
 		ScopeStrategy:            config.GetScopeStrategy(),
 		AudienceMatchingStrategy: config.GetAudienceStrategy(),
 		RefreshTokenScopes:       config.GetRefreshTokenScopes(),
+		RefreshTokenMaxLifespan:  config.RefreshTokenMaxLifespan,
+
+		RefreshTokenRotationGracePeriod:            config.RefreshTokenRotationGracePeriod,
+		DisableRotationGracePeriodForPublicClients: config.EnforceOAuth21,
+		RefreshTokenReuseHook:                      config.RefreshTokenReuseHook,
 	}
 }
 
@@ -96,11 +104,16 @@ func OAuth2ResourceOwnerPasswordCredentialsFactory(config *Config, storage inter
 			AccessTokenStorage:   storage.(oauth2.AccessTokenStorage),
 			AccessTokenLifespan:  config.GetAccessTokenLifespan(),
 			RefreshTokenLifespan: config.GetRefreshTokenLifespan(),
+
+			RefreshTokenMaxLifespan: config.RefreshTokenMaxLifespan,
 		},
 		RefreshTokenStrategy:     strategy.(oauth2.RefreshTokenStrategy),
 		ScopeStrategy:            config.GetScopeStrategy(),
 		AudienceMatchingStrategy: config.GetAudienceStrategy(),
 		RefreshTokenScopes:       config.GetRefreshTokenScopes(),
+
+		FailedAuthenticationRecorder: config.FailedAuthenticationRecorder,
+		ResourceOwnerAuthenticator:   config.ResourceOwnerAuthenticator,
 	}
 }
 
@@ -110,6 +123,7 @@ func OAuth2TokenRevocationFactory(config *Config, storage interface{}, strategy
 		TokenRevocationStorage: storage.(oauth2.TokenRevocationStorage),
 		AccessTokenStrategy:    strategy.(oauth2.AccessTokenStrategy),
 		RefreshTokenStrategy:   strategy.(oauth2.RefreshTokenStrategy),
+		RevocationPolicy:       config.RevocationPolicy,
 	}
 }
 
@@ -0,0 +1,54 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package compose
+
+import (
+	"github.com/ory/fosite/handler/oauth2"
+	"github.com/ory/fosite/handler/pkce"
+)
+
+// withOAuth21 returns a copy of the config with the settings required by OAuth 2.1.
+func (c *Config) withOAuth21() *Config {
+	config := *c
+	config.EnforcePKCE = true
+	config.EnablePKCEPlainChallengeMethod = false
+	return &config
+}
+
+// isAllowedByOAuth21 returns false for the handlers of grants which have been removed by OAuth 2.1.
+func isAllowedByOAuth21(handler interface{}) bool {
+	switch handler.(type) {
+	case *oauth2.AuthorizeImplicitGrantTypeHandler, *oauth2.ResourceOwnerPasswordCredentialsGrantHandler:
+		return false
+	}
+	return true
+}
+
+// oauth21PKCEFactory creates a PKCE handler if the storage and strategy support it.
+func oauth21PKCEFactory(config *Config, storage interface{}, strategy interface{}) interface{} {
+	if _, ok := storage.(pkce.PKCERequestStorage); !ok {
+		return nil
+	} else if _, ok := strategy.(oauth2.AuthorizeCodeStrategy); !ok {
+		return nil
+	}
+	return OAuth2PKCEFactory(config, storage, strategy)
+}
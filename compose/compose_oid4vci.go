@@ -0,0 +1,55 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package compose
+
+import (
+	"github.com/ory/fosite/handler/oauth2"
+	"github.com/ory/fosite/handler/oid4vci"
+)
+
+// OID4VCIPreAuthorizedCodeFactory creates an OpenID for Verifiable Credential Issuance pre-authorized code grant
+// handler. It returns nil if the storage does not implement oid4vci.PreAuthorizedCodeStorage or the strategy does not
+// provide an oid4vci.PreAuthorizedCodeStrategy.
+func OID4VCIPreAuthorizedCodeFactory(config *Config, storage interface{}, strategy interface{}) interface{} {
+	codeStorage, ok := storage.(oid4vci.PreAuthorizedCodeStorage)
+	if !ok {
+		return nil
+	}
+
+	codeStrategy, ok := strategy.(oid4vci.PreAuthorizedCodeStrategy)
+	if !ok {
+		return nil
+	} else if common, ok := strategy.(*CommonStrategy); ok && common.PreAuthorizedCodeStrategy == nil {
+		return nil
+	}
+
+	return &oid4vci.Handler{
+		Strategy:                     codeStrategy,
+		Storage:                      codeStorage,
+		AccessTokenStrategy:          strategy.(oauth2.AccessTokenStrategy),
+		AccessTokenStorage:           storage.(oauth2.AccessTokenStorage),
+		AccessTokenLifespan:          config.GetAccessTokenLifespan(),
+		PreAuthorizedCodeLifespan:    config.GetPreAuthorizedCodeLifespan(),
+		AllowAnonymousAccess:         config.PreAuthorizedCodeAllowAnonymousAccess,
+		FailedAuthenticationRecorder: config.FailedAuthenticationRecorder,
+	}
+}
@@ -22,6 +22,7 @@
 package compose
 
 import (
+	"github.com/ory/fosite"
 	"github.com/ory/fosite/handler/oauth2"
 	"github.com/ory/fosite/handler/openid"
 	"github.com/ory/fosite/token/jwt"
@@ -35,9 +36,9 @@ func OpenIDConnectExplicitFactory(config *Config, storage interface{}, strategy
 		OpenIDConnectRequestStorage: storage.(openid.OpenIDConnectRequestStorage),
 		IDTokenHandleHelper: &openid.IDTokenHandleHelper{
 			IDTokenStrategy: strategy.(openid.OpenIDConnectTokenStrategy),
+			LogoutStorage:   logoutStorage(storage),
 		},
-		OpenIDConnectRequestValidator: openid.NewOpenIDConnectRequestValidator(config.AllowedPromptValues, strategy.(jwt.JWTStrategy)).
-			WithRedirectSecureChecker(config.GetRedirectSecureChecker()),
+		OpenIDConnectRequestValidator: openIDConnectRequestValidator(config, storage, strategy),
 	}
 }
 
@@ -48,6 +49,7 @@ func OpenIDConnectRefreshFactory(config *Config, storage interface{}, strategy i
 	return &openid.OpenIDConnectRefreshHandler{
 		IDTokenHandleHelper: &openid.IDTokenHandleHelper{
 			IDTokenStrategy: strategy.(openid.OpenIDConnectTokenStrategy),
+			LogoutStorage:   logoutStorage(storage),
 		},
 	}
 }
@@ -65,10 +67,10 @@ func OpenIDConnectImplicitFactory(config *Config, storage interface{}, strategy
 		ScopeStrategy: config.GetScopeStrategy(),
 		IDTokenHandleHelper: &openid.IDTokenHandleHelper{
 			IDTokenStrategy: strategy.(openid.OpenIDConnectTokenStrategy),
+			LogoutStorage:   logoutStorage(storage),
 		},
-		OpenIDConnectRequestValidator: openid.NewOpenIDConnectRequestValidator(config.AllowedPromptValues, strategy.(jwt.JWTStrategy)).
-			WithRedirectSecureChecker(config.GetRedirectSecureChecker()),
-		MinParameterEntropy: config.GetMinParameterEntropy(),
+		OpenIDConnectRequestValidator: openIDConnectRequestValidator(config, storage, strategy),
+		MinParameterEntropy:           config.GetMinParameterEntropy(),
 	}
 }
 
@@ -95,10 +97,60 @@ func OpenIDConnectHybridFactory(config *Config, storage interface{}, strategy in
 		},
 		IDTokenHandleHelper: &openid.IDTokenHandleHelper{
 			IDTokenStrategy: strategy.(openid.OpenIDConnectTokenStrategy),
+			LogoutStorage:   logoutStorage(storage),
 		},
-		OpenIDConnectRequestStorage: storage.(openid.OpenIDConnectRequestStorage),
-		OpenIDConnectRequestValidator: openid.NewOpenIDConnectRequestValidator(config.AllowedPromptValues, strategy.(jwt.JWTStrategy)).
-			WithRedirectSecureChecker(config.GetRedirectSecureChecker()),
-		MinParameterEntropy: config.GetMinParameterEntropy(),
+		OpenIDConnectRequestStorage:   storage.(openid.OpenIDConnectRequestStorage),
+		OpenIDConnectRequestValidator: openIDConnectRequestValidator(config, storage, strategy),
+		MinParameterEntropy:           config.GetMinParameterEntropy(),
+	}
+}
+
+// NewBackChannelLogoutHandler creates a handler which sends logout tokens signed by strategy, typically the
+// JWTStrategy ID tokens are signed with, to the clients of a session. The storage must implement
+// fosite.LogoutStorage.
+func NewBackChannelLogoutHandler(config *Config, storage interface{}, strategy jwt.JWTStrategy) *openid.BackChannelLogoutHandler {
+	return &openid.BackChannelLogoutHandler{
+		JWTStrategy:    strategy,
+		Issuer:         config.IDTokenIssuer,
+		IssuerProvider: config.IssuerProvider,
+		LogoutStorage:  storage.(fosite.LogoutStorage),
+		ClientManager:  storage.(fosite.ClientManager),
+
+		SubjectIdentifierAlgorithms: config.SubjectIdentifierAlgorithms,
+	}
+}
+
+// openIDConnectRequestValidator returns the request validator of the OpenID Connect authorize handlers.
+func openIDConnectRequestValidator(config *Config, storage interface{}, strategy interface{}) *openid.OpenIDConnectRequestValidator {
+	var nonceStorage openid.NonceStorage
+	if config.EnableNonceReplayProtection {
+		nonceStorage = storage.(openid.NonceStorage)
+	}
+
+	return openid.NewOpenIDConnectRequestValidator(config.AllowedPromptValues, strategy.(jwt.JWTStrategy)).
+		WithRedirectSecureChecker(config.GetRedirectSecureChecker()).
+		WithAuthenticationContextPolicy(config.AuthenticationContextPolicy).
+		WithNoncePolicy(config.RequireOpenIDConnectNonce, config.MinNonceEntropy, nonceStorage, config.NonceLifespan)
+}
+
+// logoutStorage returns the storage if it implements fosite.LogoutStorage, so that the clients of sessions are
+// recorded for back-channel logout.
+func logoutStorage(storage interface{}) fosite.LogoutStorage {
+	ls, _ := storage.(fosite.LogoutStorage)
+	return ls
+}
+
+// NewUserInfoHandler returns a UserInfo endpoint handler validating access tokens using the provider and signing
+// responses with the strategy for clients which registered a userinfo_signed_response_alg.
+func NewUserInfoHandler(config *Config, provider fosite.OAuth2Provider, strategy jwt.JWTStrategy) *openid.UserInfoHandler {
+	return &openid.UserInfoHandler{
+		Introspector:     provider,
+		UserInfoProvider: config.UserInfoProvider,
+		ScopeClaims:      config.ScopeClaims,
+		Issuer:           config.IDTokenIssuer,
+		IssuerProvider:   config.IssuerProvider,
+		JWTStrategy:      strategy,
+
+		SubjectIdentifierAlgorithms: config.SubjectIdentifierAlgorithms,
 	}
 }
@@ -0,0 +1,432 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package compose
+
+import (
+	"crypto/rsa"
+	"fmt"
+	"path"
+	"reflect"
+	"runtime"
+	"strings"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/ciba"
+	"github.com/ory/fosite/handler/oauth2"
+	"github.com/ory/fosite/handler/oid4vci"
+	"github.com/ory/fosite/handler/openid"
+	"github.com/ory/fosite/handler/rfc8628"
+	"github.com/ory/fosite/token/jwt"
+)
+
+// Feature enables the handlers of a grant or endpoint in New.
+type Feature string
+
+const (
+	FeatureAuthorizeCode         Feature = "authorize_code"
+	FeatureImplicit              Feature = "implicit"
+	FeatureClientCredentials     Feature = "client_credentials"
+	FeatureRefreshToken          Feature = "refresh_token"
+	FeatureResourceOwnerPassword Feature = "password"
+	FeatureJWTBearerGrant        Feature = "jwt_bearer"
+	FeatureSAML2BearerGrant      Feature = "saml2_bearer"
+	FeatureDeviceAuthorization   Feature = "device_authorization"
+	FeatureTokenExchange         Feature = "token_exchange"
+	FeatureCIBA                  Feature = "ciba"
+	FeaturePreAuthorizedCode     Feature = "pre_authorized_code"
+
+	// FeatureOpenIDConnect enables OpenID Connect for the authorize code flow, and for the implicit, hybrid and
+	// refresh flows if FeatureImplicit or FeatureRefreshToken are enabled as well.
+	FeatureOpenIDConnect Feature = "openid_connect"
+
+	FeatureIntrospection   Feature = "introspection"
+	FeatureRevocation      Feature = "revocation"
+	FeaturePKCE            Feature = "pkce"
+	FeaturePushedAuthorize Feature = "pushed_authorize"
+)
+
+// AllFeatures are the features enabled by ComposeAllEnabled, in the order their handlers are registered.
+var AllFeatures = []Feature{
+	FeatureAuthorizeCode,
+	FeatureImplicit,
+	FeatureClientCredentials,
+	FeatureRefreshToken,
+	FeatureResourceOwnerPassword,
+	FeatureJWTBearerGrant,
+	FeatureSAML2BearerGrant,
+	FeatureDeviceAuthorization,
+	FeatureTokenExchange,
+	FeatureCIBA,
+	FeaturePreAuthorizedCode,
+	FeatureOpenIDConnect,
+	FeatureIntrospection,
+	FeatureRevocation,
+	FeaturePKCE,
+	FeaturePushedAuthorize,
+}
+
+// Options are the options of New.
+type Options struct {
+	// Config configures the handlers. Defaults to an empty Config.
+	Config *Config
+
+	// Storage stores the clients and tokens and must implement the storage interfaces of all enabled features.
+	Storage interface{}
+
+	// Strategy issues tokens, for example a *CommonStrategy. If nil, a CommonStrategy is created from Secret and
+	// PrivateKey.
+	Strategy interface{}
+
+	// Secret signs the opaque tokens if Strategy is nil. It must be at least 32 bytes long.
+	Secret []byte
+
+	// PrivateKey signs ID tokens and JWTs if Strategy is nil.
+	PrivateKey *rsa.PrivateKey
+
+	// Hasher hashes client secrets. Defaults to BCrypt.
+	Hasher fosite.Hasher
+
+	// Features are the enabled features.
+	Features []Feature
+
+	// Factories create additional handlers, which are registered after the handlers of the features.
+	Factories []Factory
+}
+
+// Option configures Options.
+type Option func(o *Options)
+
+// WithConfig sets the Config.
+func WithConfig(config *Config) Option {
+	return func(o *Options) {
+		o.Config = config
+	}
+}
+
+// WithStrategy sets the strategy issuing tokens, for example a *CommonStrategy.
+func WithStrategy(strategy interface{}) Option {
+	return func(o *Options) {
+		o.Strategy = strategy
+	}
+}
+
+// WithSecret sets the secret signing the opaque tokens if no strategy is set.
+func WithSecret(secret []byte) Option {
+	return func(o *Options) {
+		o.Secret = secret
+	}
+}
+
+// WithPrivateKey sets the key signing ID tokens and JWTs if no strategy is set.
+func WithPrivateKey(key *rsa.PrivateKey) Option {
+	return func(o *Options) {
+		o.PrivateKey = key
+	}
+}
+
+// WithHasher sets the hasher of client secrets.
+func WithHasher(hasher fosite.Hasher) Option {
+	return func(o *Options) {
+		o.Hasher = hasher
+	}
+}
+
+// WithFeatures enables features.
+func WithFeatures(features ...Feature) Option {
+	return func(o *Options) {
+		o.Features = append(o.Features, features...)
+	}
+}
+
+// WithFactories adds factories creating additional handlers.
+func WithFactories(factories ...Factory) Option {
+	return func(o *Options) {
+		o.Factories = append(o.Factories, factories...)
+	}
+}
+
+// NewOptions returns the Options for storage after applying opts.
+func NewOptions(storage interface{}, opts ...Option) *Options {
+	o := &Options{Storage: storage}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// New validates the options and composes an OAuth2Provider from them. Unlike Compose, incoherent options, for
+// example OpenID Connect without an OpenID Connect strategy, are reported at startup instead of failing requests:
+//
+//	provider, err := compose.New(store,
+//		compose.WithConfig(config),
+//		compose.WithSecret(secret),
+//		compose.WithPrivateKey(key),
+//		compose.WithFeatures(compose.FeatureAuthorizeCode, compose.FeatureRefreshToken, compose.FeatureOpenIDConnect),
+//	)
+func New(storage interface{}, opts ...Option) (fosite.OAuth2Provider, error) {
+	o := NewOptions(storage, opts...)
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	return o.Compose(), nil
+}
+
+// Compose composes an OAuth2Provider from the options without validating them.
+func (o *Options) Compose() fosite.OAuth2Provider {
+	return Compose(o.config(), o.Storage, o.strategy(), o.Hasher, o.factories()...)
+}
+
+// ValidationError lists the problems of incoherent Options.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid compose options: " + strings.Join(e.Problems, "; ")
+}
+
+// Validate returns a *ValidationError if the options are incoherent, for example if a feature lacks the storage
+// or strategy it depends on.
+func (o *Options) Validate() error {
+	var problems []string
+	report := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	config := o.config()
+	if o.Storage == nil {
+		report("a storage is required")
+	} else if _, ok := o.Storage.(fosite.Storage); !ok {
+		report("the storage %T does not implement fosite.Storage", o.Storage)
+	}
+
+	if len(o.Features) == 0 && len(o.Factories) == 0 {
+		report("no feature is enabled")
+	}
+
+	for _, feature := range o.Features {
+		if _, ok := featureFactories[feature]; !ok {
+			report("the feature %q is unknown", feature)
+		}
+	}
+
+	if o.Strategy == nil && len(o.Secret) < 32 {
+		report("a strategy or a secret of at least 32 bytes is required, got a secret of %d bytes", len(o.Secret))
+	}
+
+	strategy := o.strategy()
+	oauth2Features := []Feature{FeatureAuthorizeCode, FeatureImplicit, FeatureClientCredentials, FeatureRefreshToken, FeatureResourceOwnerPassword, FeatureIntrospection, FeatureRevocation}
+	for _, feature := range oauth2Features {
+		if o.enabled(feature) && o.Strategy != nil && !hasCoreStrategy(strategy) {
+			report("the feature %q requires an OAuth2 core strategy", feature)
+		}
+	}
+
+	if o.enabled(FeatureOpenIDConnect) {
+		if !hasOpenIDConnectStrategy(strategy) {
+			report("the feature %q requires an OpenID Connect token strategy and a JWT strategy, for example set a private key", FeatureOpenIDConnect)
+		}
+		if !o.enabled(FeatureAuthorizeCode) {
+			report("the feature %q requires the feature %q", FeatureOpenIDConnect, FeatureAuthorizeCode)
+		}
+		if o.enabled(FeatureImplicit) && !config.RequireOpenIDConnectNonce {
+			report("the feature %q together with %q requires RequireOpenIDConnectNonce, because ID tokens returned from the authorization endpoint can be replayed without a nonce", FeatureImplicit, FeatureOpenIDConnect)
+		}
+	}
+
+	if o.enabled(FeaturePKCE) && !o.enabled(FeatureAuthorizeCode) {
+		report("the feature %q requires the feature %q", FeaturePKCE, FeatureAuthorizeCode)
+	}
+
+	if o.enabled(FeatureRefreshToken) && !o.enabled(FeatureAuthorizeCode) && !o.enabled(FeatureResourceOwnerPassword) &&
+		!o.enabled(FeatureDeviceAuthorization) && !o.enabled(FeatureCIBA) && !o.enabled(FeatureTokenExchange) {
+		report("the feature %q requires a feature issuing refresh tokens, for example %q", FeatureRefreshToken, FeatureAuthorizeCode)
+	}
+
+	if o.enabled(FeatureDeviceAuthorization) && !hasStrategy(strategy, func(cs *CommonStrategy) bool { return cs.RFC8628CodeStrategy != nil }, func(s interface{}) bool {
+		_, ok := s.(rfc8628.RFC8628CodeStrategy)
+		return ok
+	}) {
+		report("the feature %q requires a device code strategy", FeatureDeviceAuthorization)
+	}
+
+	if o.enabled(FeatureCIBA) && !hasStrategy(strategy, func(cs *CommonStrategy) bool { return cs.AuthRequestIDStrategy != nil }, func(s interface{}) bool {
+		_, ok := s.(ciba.AuthRequestIDStrategy)
+		return ok
+	}) {
+		report("the feature %q requires an auth_req_id strategy", FeatureCIBA)
+	}
+
+	if o.enabled(FeaturePreAuthorizedCode) && !hasStrategy(strategy, func(cs *CommonStrategy) bool { return cs.PreAuthorizedCodeStrategy != nil }, func(s interface{}) bool {
+		_, ok := s.(oid4vci.PreAuthorizedCodeStrategy)
+		return ok
+	}) {
+		report("the feature %q requires a pre-authorized code strategy", FeaturePreAuthorizedCode)
+	}
+
+	if config.EnforceOAuth21 {
+		for _, feature := range []Feature{FeatureImplicit, FeatureResourceOwnerPassword} {
+			if o.enabled(feature) {
+				report("the feature %q is disabled by EnforceOAuth21", feature)
+			}
+		}
+	}
+
+	if config.EnforceFAPI2 {
+		if _, ok := o.Storage.(fosite.PARStorage); !ok {
+			report("EnforceFAPI2 requires a storage implementing fosite.PARStorage")
+		}
+	}
+
+	if config.EnforcePushedAuthorize && !o.enabled(FeaturePushedAuthorize) && !config.EnforceFAPI2 {
+		report("EnforcePushedAuthorize requires the feature %q", FeaturePushedAuthorize)
+	}
+
+	// The factories panic if the storage or the strategy does not implement the interfaces they require. Only
+	// run them if the basic requirements are met, so that the problems above are not reported twice.
+	if len(problems) == 0 {
+		for _, factory := range o.factories() {
+			if err := tryFactory(factory, config, o.Storage, strategy); err != nil {
+				report("%s", err)
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+var featureFactories = map[Feature][]Factory{
+	FeatureAuthorizeCode:         {OAuth2AuthorizeExplicitFactory},
+	FeatureImplicit:              {OAuth2AuthorizeImplicitFactory},
+	FeatureClientCredentials:     {OAuth2ClientCredentialsGrantFactory},
+	FeatureRefreshToken:          {OAuth2RefreshTokenGrantFactory},
+	FeatureResourceOwnerPassword: {OAuth2ResourceOwnerPasswordCredentialsFactory},
+	FeatureJWTBearerGrant:        {RFC7523AssertionGrantFactory},
+	FeatureSAML2BearerGrant:      {RFC7522AssertionGrantFactory},
+	FeatureDeviceAuthorization:   {RFC8628DeviceFactory},
+	FeatureTokenExchange:         {RFC8693TokenExchangeFactory},
+	FeatureCIBA:                  {OpenIDConnectCIBAFactory},
+	FeaturePreAuthorizedCode:     {OID4VCIPreAuthorizedCodeFactory},
+	FeatureOpenIDConnect:         {OpenIDConnectExplicitFactory},
+	FeatureIntrospection:         {OAuth2TokenIntrospectionFactory},
+	FeatureRevocation:            {OAuth2TokenRevocationFactory},
+	FeaturePKCE:                  {OAuth2PKCEFactory},
+	FeaturePushedAuthorize:       {PushedAuthorizeHandlerFactory},
+}
+
+func (o *Options) config() *Config {
+	if o.Config == nil {
+		o.Config = new(Config)
+	}
+	return o.Config
+}
+
+func (o *Options) enabled(feature Feature) bool {
+	for _, f := range o.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// factories returns the factories of the enabled features in the order of AllFeatures followed by the additional
+// factories.
+func (o *Options) factories() []Factory {
+	var factories []Factory
+	for _, feature := range AllFeatures {
+		if !o.enabled(feature) {
+			continue
+		}
+		factories = append(factories, featureFactories[feature]...)
+		if feature == FeatureOpenIDConnect {
+			if o.enabled(FeatureImplicit) {
+				factories = append(factories, OpenIDConnectImplicitFactory, OpenIDConnectHybridFactory)
+			}
+			if o.enabled(FeatureRefreshToken) {
+				factories = append(factories, OpenIDConnectRefreshFactory)
+			}
+		}
+	}
+	return append(factories, o.Factories...)
+}
+
+// strategy returns Strategy, or a CommonStrategy created from Secret and PrivateKey.
+func (o *Options) strategy() interface{} {
+	if o.Strategy != nil {
+		return o.Strategy
+	}
+
+	config := o.config()
+	strategy := new(CommonStrategy)
+	if len(o.Secret) > 0 {
+		strategy.CoreStrategy = NewOAuth2HMACStrategy(config, o.Secret, nil)
+		strategy.RFC8628CodeStrategy = NewDeviceStrategy(config, o.Secret, nil)
+		strategy.AuthRequestIDStrategy = NewCIBAStrategy(config, o.Secret, nil)
+		strategy.PreAuthorizedCodeStrategy = NewPreAuthorizedCodeStrategy(config, o.Secret, nil)
+	}
+	if o.PrivateKey != nil {
+		strategy.OpenIDConnectTokenStrategy = NewOpenIDConnectStrategy(config, o.PrivateKey)
+		strategy.JWTStrategy = &jwt.RS256JWTStrategy{PrivateKey: o.PrivateKey}
+	}
+	return strategy
+}
+
+// hasStrategy reports whether strategy implements the interface checked by implements. The interfaces embedded in
+// a CommonStrategy are only implemented if they are set, which is checked by set.
+func hasStrategy(strategy interface{}, set func(cs *CommonStrategy) bool, implements func(strategy interface{}) bool) bool {
+	if cs, ok := strategy.(*CommonStrategy); ok {
+		return set(cs)
+	}
+	return implements(strategy)
+}
+
+func hasCoreStrategy(strategy interface{}) bool {
+	return hasStrategy(strategy, func(cs *CommonStrategy) bool { return cs.CoreStrategy != nil }, func(s interface{}) bool {
+		_, ok := s.(oauth2.CoreStrategy)
+		return ok
+	})
+}
+
+func hasOpenIDConnectStrategy(strategy interface{}) bool {
+	return hasStrategy(strategy, func(cs *CommonStrategy) bool {
+		return cs.OpenIDConnectTokenStrategy != nil && cs.JWTStrategy != nil
+	}, func(s interface{}) bool {
+		_, isOpenID := s.(openid.OpenIDConnectTokenStrategy)
+		_, isJWT := s.(jwt.JWTStrategy)
+		return isOpenID && isJWT
+	})
+}
+
+// tryFactory runs the factory and converts the panic of a failed type assertion into an error.
+func tryFactory(factory Factory, config *Config, storage interface{}, strategy interface{}) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%s: %v", path.Base(runtime.FuncForPC(reflect.ValueOf(factory).Pointer()).Name()), r)
+		}
+	}()
+	factory(config, storage, strategy)
+	return nil
+}
@@ -29,10 +29,11 @@ import (
 // OAuth2PKCEFactory creates a PKCE handler.
 func OAuth2PKCEFactory(config *Config, storage interface{}, strategy interface{}) interface{} {
 	return &pkce.Handler{
-		AuthorizeCodeStrategy:      strategy.(oauth2.AuthorizeCodeStrategy),
-		Storage:                    storage.(pkce.PKCERequestStorage),
-		Force:                      config.EnforcePKCE,
-		ForceForPublicClients:      config.EnforcePKCEForPublicClients,
-		EnablePlainChallengeMethod: config.EnablePKCEPlainChallengeMethod,
+		AuthorizeCodeStrategy:       strategy.(oauth2.AuthorizeCodeStrategy),
+		Storage:                     storage.(pkce.PKCERequestStorage),
+		Force:                       config.EnforcePKCE,
+		ForceForPublicClients:       config.EnforcePKCEForPublicClients,
+		ForceForConfidentialClients: config.EnforcePKCEForConfidentialClients,
+		EnablePlainChallengeMethod:  config.EnablePKCEPlainChallengeMethod,
 	}
 }
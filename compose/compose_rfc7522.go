@@ -0,0 +1,51 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package compose
+
+import (
+	"github.com/ory/fosite/handler/oauth2"
+	"github.com/ory/fosite/handler/rfc7522"
+)
+
+// RFC7522AssertionGrantFactory creates an OAuth2 SAML 2.0 bearer assertion grant handler if
+// GrantTypeSAML2BearerAssertionValidator is set. Used assertions are tracked if the storage implements
+// rfc7522.AssertionStorage.
+func RFC7522AssertionGrantFactory(config *Config, storage interface{}, strategy interface{}) interface{} {
+	if config.GrantTypeSAML2BearerAssertionValidator == nil {
+		return nil
+	}
+
+	assertionStorage, _ := storage.(rfc7522.AssertionStorage)
+	return &rfc7522.Handler{
+		Validator:      config.GrantTypeSAML2BearerAssertionValidator,
+		Storage:        assertionStorage,
+		ScopeStrategy:  config.GetScopeStrategy(),
+		TokenURL:       config.TokenURL,
+		SkipClientAuth: config.GrantTypeSAML2BearerCanSkipClientAuth,
+		ClockSkew:      config.GrantTypeSAML2BearerClockSkew,
+		HandleHelper: &oauth2.HandleHelper{
+			AccessTokenStrategy: strategy.(oauth2.AccessTokenStrategy),
+			AccessTokenStorage:  storage.(oauth2.AccessTokenStorage),
+			AccessTokenLifespan: config.GetAccessTokenLifespan(),
+		},
+	}
+}
@@ -17,6 +17,11 @@ func RFC7523AssertionGrantFactory(config *Config, storage interface{}, strategy
 		JWTIDOptional:            config.GrantTypeJWTBearerIDOptional,
 		JWTIssuedDateOptional:    config.GrantTypeJWTBearerIssuedDateOptional,
 		JWTMaxDuration:           config.GetJWTMaxDuration(),
+		JWTMaxIssuedAge:          config.GrantTypeJWTBearerMaxIssuedAge,
+		JWTClockSkew:             config.GrantTypeJWTBearerClockSkew,
+		JWTSingleAudience:        config.GrantTypeJWTBearerSingleAudience,
+		AllowUnregisteredScopes:  config.GrantTypeJWTBearerAllowUnregisteredScopes,
+		KeyCache:                 config.JSONWebKeyCache,
 		HandleHelper: &oauth2.HandleHelper{
 			AccessTokenStrategy: strategy.(oauth2.AccessTokenStrategy),
 			AccessTokenStorage:  storage.(oauth2.AccessTokenStorage),
@@ -24,3 +29,15 @@ func RFC7523AssertionGrantFactory(config *Config, storage interface{}, strategy
 		},
 	}
 }
+
+// RFC7523ClientAuthenticationFactory creates a handler authenticating clients using JWT bearer client assertions
+// verified with the keys of rfc7523.ClientAssertionKeyStorage. The handler is tried before the configured client
+// authentication handlers.
+func RFC7523ClientAuthenticationFactory(config *Config, storage interface{}, strategy interface{}) interface{} {
+	return &rfc7523.ClientAuthenticationHandler{
+		Storage:       storage.(rfc7523.ClientAssertionKeyStorage),
+		TokenURL:      config.TokenURL,
+		JWTIDOptional: config.GrantTypeJWTBearerIDOptional,
+		JWTClockSkew:  config.GrantTypeJWTBearerClockSkew,
+	}
+}
@@ -0,0 +1,46 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package compose
+
+import (
+	"github.com/ory/fosite/handler/oauth2"
+	"github.com/ory/fosite/handler/rfc8628"
+)
+
+// RFC8628DeviceFactory creates an OAuth2 Device Authorization Grant handler and registers the device authorization
+// endpoint as well as the device code grant at the token endpoint.
+func RFC8628DeviceFactory(config *Config, storage interface{}, strategy interface{}) interface{} {
+	return &rfc8628.DeviceHandler{
+		DeviceStrategy:            strategy.(rfc8628.RFC8628CodeStrategy),
+		DeviceStorage:             storage.(rfc8628.RFC8628CodeStorage),
+		AccessTokenStrategy:       strategy.(oauth2.AccessTokenStrategy),
+		RefreshTokenStrategy:      strategy.(oauth2.RefreshTokenStrategy),
+		AccessTokenStorage:        storage.(oauth2.AccessTokenStorage),
+		RefreshTokenStorage:       storage.(oauth2.RefreshTokenStorage),
+		AccessTokenLifespan:       config.GetAccessTokenLifespan(),
+		RefreshTokenLifespan:      config.GetRefreshTokenLifespan(),
+		DeviceAndUserCodeLifespan: config.GetDeviceAndUserCodeLifespan(),
+		VerificationURI:           config.DeviceVerificationURL,
+		PollingInterval:           config.GetDeviceAuthTokenPollingInterval(),
+		RefreshTokenScopes:        config.GetRefreshTokenScopes(),
+	}
+}
@@ -0,0 +1,50 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package compose
+
+import (
+	"github.com/ory/fosite/handler/oauth2"
+	"github.com/ory/fosite/handler/rfc8693"
+)
+
+// RFC8693TokenExchangeFactory creates an OAuth2 Token Exchange handler which accepts access and refresh tokens
+// issued by this authorization server as subject and actor tokens.
+func RFC8693TokenExchangeFactory(config *Config, storage interface{}, strategy interface{}) interface{} {
+	return &rfc8693.Handler{
+		TokenValidators: []rfc8693.TokenValidator{
+			&rfc8693.DefaultTokenValidator{
+				AccessTokenStrategy:  strategy.(oauth2.AccessTokenStrategy),
+				RefreshTokenStrategy: strategy.(oauth2.RefreshTokenStrategy),
+				AccessTokenStorage:   storage.(oauth2.AccessTokenStorage),
+				RefreshTokenStorage:  storage.(oauth2.RefreshTokenStorage),
+			},
+		},
+		ScopeStrategy:            config.GetScopeStrategy(),
+		AudienceMatchingStrategy: config.GetAudienceStrategy(),
+		AllowImpersonation:       config.GrantTypeTokenExchangeAllowImpersonation,
+		HandleHelper: &oauth2.HandleHelper{
+			AccessTokenStrategy: strategy.(oauth2.AccessTokenStrategy),
+			AccessTokenStorage:  storage.(oauth2.AccessTokenStorage),
+			AccessTokenLifespan: config.GetAccessTokenLifespan(),
+		},
+	}
+}
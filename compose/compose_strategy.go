@@ -25,8 +25,13 @@ import (
 	"crypto/ecdsa"
 	"crypto/rsa"
 
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/ciba"
 	"github.com/ory/fosite/handler/oauth2"
+	"github.com/ory/fosite/handler/oid4vci"
 	"github.com/ory/fosite/handler/openid"
+	"github.com/ory/fosite/handler/rfc8628"
+	"github.com/ory/fosite/token/aead"
 	"github.com/ory/fosite/token/hmac"
 	"github.com/ory/fosite/token/jwt"
 )
@@ -34,15 +39,43 @@ import (
 type CommonStrategy struct {
 	oauth2.CoreStrategy
 	openid.OpenIDConnectTokenStrategy
+	rfc8628.RFC8628CodeStrategy
+	ciba.AuthRequestIDStrategy
+	oid4vci.PreAuthorizedCodeStrategy
 	jwt.JWTStrategy
 }
 
+// newHMACStrategy returns the HMAC strategy issuing the opaque tokens of the configuration.
+func newHMACStrategy(config *Config, secret []byte, rotatedSecrets [][]byte) *hmac.HMACStrategy {
+	strategy := &hmac.HMACStrategy{
+		GlobalSecret:         secret,
+		RotatedGlobalSecrets: rotatedSecrets,
+		TokenEntropy:         config.GetTokenEntropy(),
+		VersionedTokens:      config.VersionedHMACTokens,
+	}
+	if config.HMACPreset != nil {
+		config.HMACPreset.Apply(strategy)
+	}
+	return strategy
+}
+
 func NewOAuth2HMACStrategy(config *Config, secret []byte, rotatedSecrets [][]byte) *oauth2.HMACSHAStrategy {
 	return &oauth2.HMACSHAStrategy{
-		Enigma: &hmac.HMACStrategy{
+		Enigma:                newHMACStrategy(config, secret, rotatedSecrets),
+		AccessTokenLifespan:   config.GetAccessTokenLifespan(),
+		AuthorizeCodeLifespan: config.GetAuthorizeCodeLifespan(),
+		RefreshTokenLifespan:  config.GetRefreshTokenLifespan(),
+		TokenPrefixes:         config.TokenPrefixes,
+	}
+}
+
+// NewOAuth2AEADStrategy returns a strategy which encrypts the expiry, the client and the scope of tokens into the
+// tokens. It is an alternative to NewOAuth2HMACStrategy.
+func NewOAuth2AEADStrategy(config *Config, secret []byte, rotatedSecrets [][]byte) *aead.Strategy {
+	return &aead.Strategy{
+		AEAD: &aead.AEADStrategy{
 			GlobalSecret:         secret,
 			RotatedGlobalSecrets: rotatedSecrets,
-			TokenEntropy:         config.GetTokenEntropy(),
 		},
 		AccessTokenLifespan:   config.GetAccessTokenLifespan(),
 		AuthorizeCodeLifespan: config.GetAuthorizeCodeLifespan(),
@@ -50,6 +83,53 @@ func NewOAuth2HMACStrategy(config *Config, secret []byte, rotatedSecrets [][]byt
 	}
 }
 
+func NewDeviceStrategy(config *Config, secret []byte, rotatedSecrets [][]byte) *rfc8628.DefaultDeviceStrategy {
+	return &rfc8628.DefaultDeviceStrategy{
+		Enigma:                    newHMACStrategy(config, secret, rotatedSecrets),
+		DeviceAndUserCodeLifespan: config.GetDeviceAndUserCodeLifespan(),
+	}
+}
+
+func NewPreAuthorizedCodeStrategy(config *Config, secret []byte, rotatedSecrets [][]byte) *oid4vci.DefaultPreAuthorizedCodeStrategy {
+	return &oid4vci.DefaultPreAuthorizedCodeStrategy{
+		Enigma:                    newHMACStrategy(config, secret, rotatedSecrets),
+		PreAuthorizedCodeLifespan: config.GetPreAuthorizedCodeLifespan(),
+	}
+}
+
+func NewCIBAStrategy(config *Config, secret []byte, rotatedSecrets [][]byte) *ciba.DefaultAuthRequestIDStrategy {
+	return &ciba.DefaultAuthRequestIDStrategy{
+		Enigma:                newHMACStrategy(config, secret, rotatedSecrets),
+		AuthRequestIDLifespan: config.GetCIBAAuthRequestIDLifespan(),
+	}
+}
+
+func NewJARMStrategy(config *Config, key *rsa.PrivateKey) *fosite.DefaultJARMStrategy {
+	return &fosite.DefaultJARMStrategy{
+		Signers: map[string]jwt.JWTStrategy{
+			"RS256": &jwt.RS256JWTStrategy{
+				PrivateKey: key,
+			},
+		},
+		Issuer:              config.IDTokenIssuer,
+		IssuerProvider:      config.IssuerProvider,
+		JWKSFetcherStrategy: config.GetJWKSFetcherStrategy(),
+	}
+}
+
+func NewIntrospectionJWTStrategy(config *Config, key *rsa.PrivateKey) *fosite.DefaultIntrospectionJWTStrategy {
+	return &fosite.DefaultIntrospectionJWTStrategy{
+		Signers: map[string]jwt.JWTStrategy{
+			"RS256": &jwt.RS256JWTStrategy{
+				PrivateKey: key,
+			},
+		},
+		Issuer:              config.IDTokenIssuer,
+		IssuerProvider:      config.IssuerProvider,
+		JWKSFetcherStrategy: config.GetJWKSFetcherStrategy(),
+	}
+}
+
 func NewOAuth2JWTStrategy(key *rsa.PrivateKey, strategy *oauth2.HMACSHAStrategy) *oauth2.DefaultJWTStrategy {
 	return &oauth2.DefaultJWTStrategy{
 		JWTStrategy: &jwt.RS256JWTStrategy{
@@ -59,6 +139,28 @@ func NewOAuth2JWTStrategy(key *rsa.PrivateKey, strategy *oauth2.HMACSHAStrategy)
 	}
 }
 
+// NewOAuth2JWTKeyProviderStrategy returns an RS256 access token strategy which signs with the current key of
+// the given provider and accepts any key it publishes.
+func NewOAuth2JWTKeyProviderStrategy(provider jwt.KeyProvider, strategy *oauth2.HMACSHAStrategy) *oauth2.DefaultJWTStrategy {
+	return &oauth2.DefaultJWTStrategy{
+		JWTStrategy: &jwt.RS256JWTStrategy{
+			KeyProvider: provider,
+		},
+		HMACSHAStrategy: strategy,
+	}
+}
+
+// NewOAuth2JWTSignerStrategy returns an access token strategy which delegates signing to the given signer, for
+// example a KMS or HSM backed jwt.Signer.
+func NewOAuth2JWTSignerStrategy(signer jwt.Signer, strategy *oauth2.HMACSHAStrategy) *oauth2.DefaultJWTStrategy {
+	return &oauth2.DefaultJWTStrategy{
+		JWTStrategy: &jwt.SignerJWTStrategy{
+			Signer: signer,
+		},
+		HMACSHAStrategy: strategy,
+	}
+}
+
 func NewOAuth2JWTECDSAStrategy(key *ecdsa.PrivateKey, strategy *oauth2.HMACSHAStrategy) *oauth2.DefaultJWTStrategy {
 	return &oauth2.DefaultJWTStrategy{
 		JWTStrategy: &jwt.ES256JWTStrategy{
@@ -68,6 +170,40 @@ func NewOAuth2JWTECDSAStrategy(key *ecdsa.PrivateKey, strategy *oauth2.HMACSHASt
 	}
 }
 
+// NewOAuth2SelfEncodedAuthorizeCodeStrategy returns a strategy which issues RS256 signed authorization codes that
+// are validated before hitting the storage. Access and refresh tokens are issued by the given strategy.
+func NewOAuth2SelfEncodedAuthorizeCodeStrategy(config *Config, key *rsa.PrivateKey, strategy oauth2.CoreStrategy) *oauth2.SelfEncodedAuthorizeCodeStrategy {
+	return &oauth2.SelfEncodedAuthorizeCodeStrategy{
+		CoreStrategy: strategy,
+		JWTStrategy: &jwt.RS256JWTStrategy{
+			PrivateKey: key,
+		},
+		Issuer:                config.IDTokenIssuer,
+		IssuerProvider:        config.IssuerProvider,
+		AuthorizeCodeLifespan: config.GetAuthorizeCodeLifespan(),
+	}
+}
+
+// NewOAuth2EncryptedRefreshTokenStrategy returns a strategy which issues stateless refresh tokens that are encrypted
+// with the secret and are not stored. Access tokens and authorization codes are issued by the given strategy.
+func NewOAuth2EncryptedRefreshTokenStrategy(config *Config, secret []byte, rotatedSecrets [][]byte, strategy oauth2.CoreStrategy) *oauth2.EncryptedRefreshTokenStrategy {
+	return &oauth2.EncryptedRefreshTokenStrategy{
+		CoreStrategy:         strategy,
+		GlobalSecret:         secret,
+		RotatedGlobalSecrets: rotatedSecrets,
+		RefreshTokenLifespan: config.GetRefreshTokenLifespan(),
+	}
+}
+
+// NewOAuth2PhantomTokenStrategy returns a strategy which hands out the opaque access tokens of the given strategy
+// and keeps the JWT issued by jwtStrategy for the introspection endpoint (phantom token pattern).
+func NewOAuth2PhantomTokenStrategy(strategy oauth2.CoreStrategy, jwtStrategy oauth2.AccessTokenStrategy) *oauth2.PhantomTokenStrategy {
+	return &oauth2.PhantomTokenStrategy{
+		CoreStrategy: strategy,
+		JWTStrategy:  jwtStrategy,
+	}
+}
+
 // Deprecated: Use NewOAuth2JWTStrategy(key, strategy).WithIssuer(issuer) instead.
 func NewOAuth2JWTStrategyWithIssuer(key *rsa.PrivateKey, strategy *oauth2.HMACSHAStrategy, issuer string) *oauth2.DefaultJWTStrategy {
 	return NewOAuth2JWTStrategy(key, strategy).WithIssuer(issuer)
@@ -85,7 +221,48 @@ func NewOpenIDConnectStrategy(config *Config, key *rsa.PrivateKey) *openid.Defau
 		},
 		Expiry:              config.GetIDTokenLifespan(),
 		Issuer:              config.IDTokenIssuer,
+		IssuerProvider:      config.IssuerProvider,
+		MinParameterEntropy: config.GetMinParameterEntropy(),
+		JWKSFetcherStrategy: config.GetJWKSFetcherStrategy(),
+		ClaimsMapper:        idTokenClaimsMapper(config),
+
+		SubjectIdentifierAlgorithms: config.SubjectIdentifierAlgorithms,
+	}
+}
+
+// NewOpenIDConnectKeyProviderStrategy returns an RS256 ID token strategy which signs with the current key of
+// the given provider.
+func NewOpenIDConnectKeyProviderStrategy(config *Config, provider jwt.KeyProvider) *openid.DefaultStrategy {
+	return &openid.DefaultStrategy{
+		JWTStrategy: &jwt.RS256JWTStrategy{
+			KeyProvider: provider,
+		},
+		Expiry:              config.GetIDTokenLifespan(),
+		Issuer:              config.IDTokenIssuer,
+		IssuerProvider:      config.IssuerProvider,
+		MinParameterEntropy: config.GetMinParameterEntropy(),
+		JWKSFetcherStrategy: config.GetJWKSFetcherStrategy(),
+		ClaimsMapper:        idTokenClaimsMapper(config),
+
+		SubjectIdentifierAlgorithms: config.SubjectIdentifierAlgorithms,
+	}
+}
+
+// NewOpenIDConnectSignerStrategy returns an ID token strategy which delegates signing to the given signer, for
+// example a KMS or HSM backed jwt.Signer.
+func NewOpenIDConnectSignerStrategy(config *Config, signer jwt.Signer) *openid.DefaultStrategy {
+	return &openid.DefaultStrategy{
+		JWTStrategy: &jwt.SignerJWTStrategy{
+			Signer: signer,
+		},
+		Expiry:              config.GetIDTokenLifespan(),
+		Issuer:              config.IDTokenIssuer,
+		IssuerProvider:      config.IssuerProvider,
 		MinParameterEntropy: config.GetMinParameterEntropy(),
+		JWKSFetcherStrategy: config.GetJWKSFetcherStrategy(),
+		ClaimsMapper:        idTokenClaimsMapper(config),
+
+		SubjectIdentifierAlgorithms: config.SubjectIdentifierAlgorithms,
 	}
 }
 
@@ -96,6 +273,28 @@ func NewOpenIDConnectECDSAStrategy(config *Config, key *ecdsa.PrivateKey) *openi
 		},
 		Expiry:              config.GetIDTokenLifespan(),
 		Issuer:              config.IDTokenIssuer,
+		IssuerProvider:      config.IssuerProvider,
 		MinParameterEntropy: config.GetMinParameterEntropy(),
+		JWKSFetcherStrategy: config.GetJWKSFetcherStrategy(),
+		ClaimsMapper:        idTokenClaimsMapper(config),
+
+		SubjectIdentifierAlgorithms: config.SubjectIdentifierAlgorithms,
+	}
+}
+
+// idTokenClaimsMapper returns the claims mapper applied to ID tokens, which populates the claims granted by
+// scopes if a UserInfoProvider is configured.
+func idTokenClaimsMapper(config *Config) fosite.ClaimsMapper {
+	if config.UserInfoProvider == nil {
+		return config.ClaimsMapper
+	}
+
+	mappers := fosite.ClaimsMappers{&openid.ScopeClaimsMapper{
+		UserInfoProvider: config.UserInfoProvider,
+		ScopeClaims:      config.ScopeClaims,
+	}}
+	if config.ClaimsMapper != nil {
+		mappers = append(mappers, config.ClaimsMapper)
 	}
+	return mappers
 }
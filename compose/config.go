@@ -22,11 +22,20 @@
 package compose
 
 import (
+	"context"
 	"net/url"
 	"time"
 
+	"go.opentelemetry.io/otel/trace"
+
 	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/ciba"
+	"github.com/ory/fosite/handler/oauth2"
+	"github.com/ory/fosite/handler/openid"
+	"github.com/ory/fosite/handler/rfc7522"
 	"github.com/ory/fosite/i18n"
+	"github.com/ory/fosite/token/hmac"
+	"github.com/ory/fosite/token/jwt"
 )
 
 type Config struct {
@@ -37,6 +46,10 @@ type Config struct {
 	// refresh tokens that never expire.
 	RefreshTokenLifespan time.Duration
 
+	// RefreshTokenMaxLifespan sets the absolute lifetime of the refresh tokens issued for a grant. Unlike
+	// RefreshTokenLifespan, it is not renewed when a refresh token is used. Defaults to no absolute lifetime.
+	RefreshTokenMaxLifespan time.Duration
+
 	// AuthorizeCodeLifespan sets how long an authorize code is going to be valid. Defaults to fifteen minutes.
 	AuthorizeCodeLifespan time.Duration
 
@@ -60,7 +73,9 @@ type Config struct {
 	// ScopeStrategy sets the scope strategy that should be supported, for example fosite.WildcardScopeStrategy.
 	ScopeStrategy fosite.ScopeStrategy
 
-	// AudienceMatchingStrategy sets the audience matching strategy that should be supported, defaults to fosite.DefaultsAudienceMatchingStrategy.
+	// AudienceMatchingStrategy sets the audience matching strategy that should be supported, defaults to fosite.DefaultAudienceMatchingStrategy,
+	// which matches URLs by hierarchy. Use fosite.ExactAudienceMatchingStrategy or fosite.WildcardAudienceMatchingStrategy for other layouts.
+	// Clients can select a different strategy by implementing fosite.AudienceMatchingStrategyClient.
 	AudienceMatchingStrategy fosite.AudienceMatchingStrategy
 
 	// EnforcePKCE, if set to true, requires clients to perform authorize code flows with PKCE. Defaults to false.
@@ -69,10 +84,13 @@ type Config struct {
 	// EnforcePKCEForPublicClients requires only public clients to use PKCE with the authorize code flow. Defaults to false.
 	EnforcePKCEForPublicClients bool
 
+	// EnforcePKCEForConfidentialClients requires confidential clients to use PKCE with the authorize code flow. Defaults to false.
+	EnforcePKCEForConfidentialClients bool
+
 	// EnablePKCEPlainChallengeMethod sets whether or not to allow the plain challenge method (S256 should be used whenever possible, plain is really discouraged). Defaults to false.
 	EnablePKCEPlainChallengeMethod bool
 
-	// AllowedPromptValues sets which OpenID Connect prompt values the server supports. Defaults to []string{"login", "none", "consent", "select_account"}.
+	// AllowedPromptValues sets which OpenID Connect prompt values the server supports. Defaults to []string{"login", "none", "consent", "select_account", "create"}.
 	AllowedPromptValues []string
 
 	// TokenURL is the the URL of the Authorization Server's Token Endpoint. If the authorization server is intended
@@ -84,16 +102,39 @@ type Config struct {
 	// client authentication method is used. Defaults to fosite.DefaultJWKSFetcherStrategy.
 	JWKSFetcher fosite.JWKSFetcherStrategy
 
+	// JWKSFetcherOptions configure the HTTP client, timeout, maximum response size and cache TTL of the default
+	// JWKSFetcher. They are ignored if JWKSFetcher is set.
+	JWKSFetcherOptions fosite.JWKSFetcherOptions
+
 	// TokenEntropy indicates the entropy of the random string, used as the "message" part of the HMAC token.
 	// Defaults to 32.
 	TokenEntropy int
 
+	// HMACPreset, if set, configures the entropy, signature length and hash function of HMAC tokens, for example
+	// hmac.HighEntropyPreset. It overrides TokenEntropy.
+	HMACPreset *hmac.Preset
+
+	// VersionedHMACTokens, if set to true, prefixes HMAC tokens with the ID of the secret they were signed with, so
+	// that rotated secrets can be looked up directly during validation.
+	VersionedHMACTokens bool
+
+	// TokenPrefixes, if set, prefixes HMAC access tokens, refresh tokens and authorize codes with the prefix of their
+	// token type so that leaked credential scanners can identify them, for example fosite.DefaultTokenPrefixes.
+	TokenPrefixes fosite.TokenPrefixes
+
 	// RedirectSecureChecker is a function that returns true if the provided URL can be securely used as a redirect URL.
 	RedirectSecureChecker func(*url.URL) bool
 
 	// RefreshTokenScopes defines which OAuth scopes will be given refresh tokens during the authorization code grant exchange. This defaults to "offline" and "offline_access". When set to an empty array, all exchanges will be given refresh tokens.
 	RefreshTokenScopes []string
 
+	// RefreshTokenRotationGracePeriod allows refresh tokens to be used again for the given duration after they have been
+	// exchanged for a new refresh token. Requires the storage to implement oauth2.RefreshTokenRotationStorage.
+	RefreshTokenRotationGracePeriod time.Duration
+
+	// RefreshTokenReuseHook, if set, is called when the reuse of a refresh token has been detected.
+	RefreshTokenReuseHook func(ctx context.Context, request fosite.Requester)
+
 	// MinParameterEntropy controls the minimum size of state and nonce parameters. Defaults to fosite.MinParameterEntropy.
 	MinParameterEntropy int
 
@@ -113,14 +154,295 @@ type Config struct {
 	// GrantTypeJWTBearerMaxDuration sets the maximum time after JWT issued date, during which the JWT is considered valid.
 	GrantTypeJWTBearerMaxDuration time.Duration
 
+	// GrantTypeJWTBearerMaxIssuedAge, if set, sets the maximum age of the "iat" (issued at) claim of a JWT.
+	GrantTypeJWTBearerMaxIssuedAge time.Duration
+
+	// GrantTypeJWTBearerClockSkew sets the leeway allowed when checking the "exp", "nbf" and "iat" claims of a JWT.
+	GrantTypeJWTBearerClockSkew time.Duration
+
+	// GrantTypeJWTBearerSingleAudience indicates, if the "aud" (audience) claim of a JWT must only contain the token URL.
+	GrantTypeJWTBearerSingleAudience bool
+
+	// GrantTypeJWTBearerAllowUnregisteredScopes indicates, if a JWT can be used to request scopes which are not
+	// registered for its public key.
+	GrantTypeJWTBearerAllowUnregisteredScopes bool
+
+	// GrantTypeSAML2BearerAssertionValidator verifies the SAML 2.0 assertions of the SAML 2.0 bearer assertion
+	// grant. The grant is only enabled if it is set.
+	GrantTypeSAML2BearerAssertionValidator rfc7522.AssertionValidator
+
+	// GrantTypeSAML2BearerCanSkipClientAuth indicates, if client authentication can be skipped, when using a SAML 2.0
+	// assertion as authorization grant.
+	GrantTypeSAML2BearerCanSkipClientAuth bool
+
+	// GrantTypeSAML2BearerClockSkew sets the leeway allowed when checking the validity period of a SAML 2.0 assertion.
+	GrantTypeSAML2BearerClockSkew time.Duration
+
+	// GrantTypeTokenExchangeAllowImpersonation, if set to true, allows token exchange requests without an actor
+	// token. By default, only delegation is possible.
+	GrantTypeTokenExchangeAllowImpersonation bool
+
 	// ClientAuthenticationStrategy indicates the Strategy to authenticate client requests
 	ClientAuthenticationStrategy fosite.ClientAuthenticationStrategy
 
-	// ResponseModeHandlerExtension provides a handler for custom response modes
+	// ClientAuthenticationHandlers sets the client authentication methods tried in order by the default client
+	// authentication strategy. Defaults to fosite.DefaultClientAuthenticationHandlers().
+	ClientAuthenticationHandlers fosite.ClientAuthenticationHandlers
+
+	// ResponseModeHandlerExtension provides a handler for custom response modes. Use fosite.ResponseModeHandlers
+	// to register more than one handler.
 	ResponseModeHandlerExtension fosite.ResponseModeHandler
 
 	// MessageCatalog is the message bundle used for i18n
 	MessageCatalog i18n.MessageCatalog
+
+	// PushedAuthorizeRequestURIPrefix is the prefix of request_uri values issued for pushed authorization requests.
+	// Defaults to fosite.PushedAuthorizeRequestURIPrefix.
+	PushedAuthorizeRequestURIPrefix string
+
+	// PushedAuthorizeContextLifespan sets how long a pushed authorization request's request_uri is going to be valid.
+	// Defaults to one minute.
+	PushedAuthorizeContextLifespan time.Duration
+
+	// EnforcePushedAuthorize, if set to true, requires all authorization requests to be pushed first.
+	EnforcePushedAuthorize bool
+
+	// DeviceVerificationURL is the URL of the end-user verification page of the device authorization grant.
+	DeviceVerificationURL string
+
+	// DeviceAndUserCodeLifespan sets how long the device and user code of the device authorization grant are going
+	// to be valid. Defaults to ten minutes.
+	DeviceAndUserCodeLifespan time.Duration
+
+	// DeviceAuthTokenPollingInterval sets the minimum amount of time devices should wait between polling requests
+	// to the token endpoint. Defaults to five seconds.
+	DeviceAuthTokenPollingInterval time.Duration
+
+	// PreAuthorizedCodeLifespan sets how long a pre-authorized code of a credential offer is going to be valid.
+	// Defaults to five minutes.
+	PreAuthorizedCodeLifespan time.Duration
+
+	// PreAuthorizedCodeAllowAnonymousAccess, if set to true, allows wallets to exchange pre-authorized codes without
+	// authenticating as an OAuth 2.0 Client.
+	PreAuthorizedCodeAllowAnonymousAccess bool
+
+	// CIBAAuthRequestIDLifespan sets how long the auth_req_id of a backchannel authentication request is going to be
+	// valid. Defaults to ten minutes.
+	CIBAAuthRequestIDLifespan time.Duration
+
+	// CIBAPollingInterval sets the minimum amount of time clients using the poll or ping token delivery mode should
+	// wait between polling requests to the token endpoint. Defaults to five seconds.
+	CIBAPollingInterval time.Duration
+
+	// CIBANotifier delivers ping and push notifications of backchannel authentication requests. Defaults to
+	// ciba.DefaultNotifier.
+	CIBANotifier ciba.Notifier
+
+	// DPoPStrategy enables DPoP-bound access tokens if set. Use &fosite.DefaultDPoPStrategy{} for an in-memory
+	// implementation.
+	DPoPStrategy fosite.DPoPStrategy
+
+	// DPoPProofLifespan sets the time frame in which DPoP proofs are accepted. Defaults to one minute.
+	DPoPProofLifespan time.Duration
+
+	// TLSClientCertificateBoundAccessTokens, if set to true, binds access tokens to the TLS client certificate
+	// presented at the token endpoint.
+	TLSClientCertificateBoundAccessTokens bool
+
+	// JARMStrategy enables JWT secured authorization responses if set. Use NewJARMStrategy for a strategy signing
+	// responses with an RSA key.
+	JARMStrategy fosite.JARMStrategy
+
+	// EnableJWTSecuredAuthorizationRequests, if set to true, processes request objects of all authorization requests
+	// as defined in RFC 9101, using only the parameters of the request object.
+	EnableJWTSecuredAuthorizationRequests bool
+
+	// RequireSignedRequestObject, if set to true, requires all authorization requests to be sent as signed request
+	// objects.
+	RequireSignedRequestObject bool
+
+	// AuthorizationDetailsTypes maps the supported authorization details types (RFC 9396) to an optional validator.
+	AuthorizationDetailsTypes map[string]fosite.AuthorizationDetailValidator
+
+	// IntrospectionJWTStrategy enables JWT introspection responses (RFC 9701) if set. Use NewIntrospectionJWTStrategy
+	// for a strategy signing responses with an RSA key.
+	IntrospectionJWTStrategy fosite.IntrospectionJWTStrategy
+
+	// EventListener is notified of security relevant events, for example to keep an audit trail.
+	EventListener fosite.EventListener
+
+	// TracerProvider instruments the endpoints with OpenTelemetry spans if set.
+	TracerProvider trace.TracerProvider
+
+	// MetricsReporter receives metrics such as the number of issued tokens if set. Use metrics.NewPrometheusReporter
+	// to expose them to Prometheus.
+	MetricsReporter fosite.MetricsReporter
+
+	// ConsentStrategy remembers the consent given by resource owners if set, e.g. a fosite.DefaultConsentStrategy.
+	ConsentStrategy fosite.ConsentStrategy
+
+	// IDTokenHintStrategy verifies the id_token_hint of logout requests. Defaults to the JWTStrategy of the
+	// OpenIDConnectTokenStrategy if the strategy passed to Compose is a CommonStrategy with an openid.DefaultStrategy.
+	IDTokenHintStrategy jwt.JWTStrategy
+
+	// SessionStateStrategy adds the session_state parameter to OpenID Connect authorize responses if set.
+	SessionStateStrategy fosite.SessionStateStrategy
+
+	// EnforceOAuth21, if set to true, applies the rules of the OAuth 2.1 draft
+	// (https://tools.ietf.org/html/draft-ietf-oauth-v2-1) to the composed provider:
+	//  * the implicit and resource owner password credentials grant handlers are not registered and response types
+	//    returning access tokens from the authorization endpoint are rejected,
+	//  * PKCE with the S256 challenge method is required for all clients; the PKCE handler is registered if missing,
+	//  * refresh tokens of public clients are strictly one-time use, i.e. RefreshTokenRotationGracePeriod only
+	//    applies to confidential clients.
+	// Redirect URIs are always compared exactly, except for the port of loopback redirect URIs as required by
+	// OAuth 2.1.
+	EnforceOAuth21 bool
+
+	// EnforceFAPI2, if set to true, applies the FAPI 2.0 Security Profile
+	// (https://openid.net/specs/fapi-2_0-security-profile.html) to the composed provider. It implies EnforceOAuth21
+	// and additionally:
+	//  * requires pushed authorization requests; the PAR handler is registered if missing,
+	//  * only issues authorization codes from the authorization endpoint, optionally using JARM,
+	//  * requires access tokens to be sender-constrained using DPoP or mTLS,
+	//  * only accepts the private_key_jwt and tls_client_auth client authentication methods unless
+	//    ClientAuthenticationHandlers is set.
+	// Use ComposeFAPI2 to validate the remaining settings, such as the sender-constraining method.
+	EnforceFAPI2 bool
+
+	// ClaimsMapper modifies the claims of ID tokens issued by the strategies returned from NewOpenIDConnectStrategy
+	// and its variants. Use oauth2.DefaultJWTStrategy.WithClaimsMapper to modify the claims of JWT access tokens.
+	ClaimsMapper fosite.ClaimsMapper
+
+	// UserInfoProvider, if set, populates ID tokens with the standard claims granted by the profile, email, address
+	// and phone scopes, or by the scopes configured in ScopeClaims.
+	UserInfoProvider openid.UserInfoProvider
+
+	// ScopeClaims maps scopes to the claims they grant. Defaults to openid.DefaultScopeClaims.
+	ScopeClaims openid.ScopeClaims
+
+	// AuthenticationContextPolicy decides whether the acr claim of the session satisfies the authentication context
+	// class references requested using acr_values or the claims parameter. Defaults to
+	// openid.DefaultAuthenticationContextPolicy, which only enforces essential acr claims.
+	AuthenticationContextPolicy openid.AuthenticationContextPolicy
+
+	// IssuerProvider, if set, takes precedence over IDTokenIssuer. It is used by the strategies and handlers created
+	// by compose, discovery, and introspection responses. Access token strategies created by NewOAuth2JWTStrategy
+	// must be configured using WithIssuerProvider.
+	IssuerProvider fosite.IssuerProvider
+
+	// StoragePolicy, if set, bounds the time storage operations may take and retries failed operations.
+	StoragePolicy *fosite.StoragePolicy
+
+	// SanitationWhiteList are form parameters, for example extension parameters like "tenant", which are kept when
+	// requests are sanitized for storage.
+	SanitationWhiteList []string
+
+	// ErrorWriter, if set, writes the error responses which are not redirected to the client.
+	ErrorWriter fosite.ErrorWriter
+
+	// DebugPolicy, if set, decides per request whether error debug messages are included in response payloads and
+	// takes precedence over SendDebugMessagesToClients.
+	DebugPolicy fosite.DebugPolicy
+
+	// TokenQuotaPolicy, if set, limits the active grants of subjects and clients, see oauth2.DefaultTokenQuotaPolicy.
+	TokenQuotaPolicy fosite.TokenQuotaPolicy
+
+	// FailedAuthenticationRecorder, if set, is informed about client secret and resource owner password attempts,
+	// for example to lock out identifiers after repeated failures.
+	FailedAuthenticationRecorder fosite.FailedAuthenticationRecorder
+
+	// ClientSecretRehasher, if set, is called with a new hash of the client secret after a successful login with a
+	// secret whose hash needs to be upgraded, see fosite.HasherChain.
+	ClientSecretRehasher fosite.ClientSecretRehasher
+
+	// JSONWebKeyCache, if set, caches the keys used to verify private_key_jwt client assertions and RFC7523 JWT
+	// bearer grants, see fosite.NewMemoryJSONWebKeyCache.
+	JSONWebKeyCache fosite.JSONWebKeyCache
+
+	// RequireOpenIDConnectNonce, if true, requires the nonce parameter in all OpenID Connect authorize requests,
+	// including the authorize code flow.
+	RequireOpenIDConnectNonce bool
+
+	// MinNonceEntropy, if set, is the minimum length of the nonce parameter which is enforced by the authorize
+	// endpoint in all OpenID Connect flows. Otherwise, the authorize code flow checks the nonce against
+	// MinParameterEntropy only when the ID token is issued.
+	MinNonceEntropy int
+
+	// EnableNonceReplayProtection, if true, rejects OpenID Connect authorize requests which reuse a nonce of the
+	// same client within NonceLifespan. The storage must implement openid.NonceStorage.
+	EnableNonceReplayProtection bool
+
+	// NonceLifespan sets the time during which a nonce can not be used again. Defaults to one hour.
+	NonceLifespan time.Duration
+
+	// RevocationPolicy decides whether revoking a token at the revocation endpoint revokes the other tokens of the
+	// grant as well. Defaults to fosite.CascadingRevocationPolicy.
+	RevocationPolicy fosite.RevocationPolicy
+
+	// IntrospectionResponseEnricher, if set, adds custom claims such as a tenant or permissions to the responses of
+	// active introspections.
+	IntrospectionResponseEnricher fosite.IntrospectionResponseEnricher
+
+	// IntrospectionPolicy, if set, restricts which tokens a client may introspect, see
+	// fosite.AudienceRestrictedIntrospectionPolicy.
+	IntrospectionPolicy fosite.IntrospectionPolicy
+
+	// GrantStorage enables grant management if set, e.g. a storage.MemoryStore.
+	GrantStorage fosite.GrantStorage
+
+	// GrantManagementActionRequired, if set to true, requires all authorize requests to set the
+	// grant_management_action parameter. Requires GrantStorage.
+	GrantManagementActionRequired bool
+
+	// OmitAuthorizationResponseIssuer, if set to true, does not add the "iss" parameter to authorization responses,
+	// which is added by default if IssuerProvider is set.
+	OmitAuthorizationResponseIssuer bool
+
+	// RequireAuthorizationResponseIssuer, if set to true, rejects authorization requests if the issuer for the "iss"
+	// parameter of authorization responses can not be determined.
+	RequireAuthorizationResponseIssuer bool
+
+	// RedirectURIMatcher matches the redirect_uri of authorization requests against the registered redirect URIs.
+	// Defaults to fosite.ExactRedirectURIMatcher.
+	RedirectURIMatcher fosite.RedirectURIMatcher
+
+	// SubjectIdentifierAlgorithms computes the "sub" value of ID tokens, UserInfo responses, logout tokens and
+	// introspection responses for each client, e.g. pairwise subject identifiers. If nil, all clients receive the
+	// subject of the session.
+	SubjectIdentifierAlgorithms fosite.SubjectIdentifierAlgorithms
+
+	// OfflineAccessPolicy decides whether the offline_access scope of OpenID Connect authorization requests is
+	// honored, e.g. fosite.ConsentRequiredOfflineAccessPolicy. If set, refresh tokens are only issued for OpenID
+	// Connect requests if offline_access was granted. If nil, offline_access is not enforced.
+	OfflineAccessPolicy fosite.OfflineAccessPolicy
+
+	// AuthorizeRequestValidators run custom validations of authorization requests, see
+	// fosite.AuthorizeRequestValidator.
+	AuthorizeRequestValidators fosite.AuthorizeRequestValidators
+
+	// AccessRequestMiddlewares handle cross-cutting concerns of token requests, see fosite.AccessRequestMiddleware.
+	AccessRequestMiddlewares fosite.AccessRequestMiddlewares
+
+	// ClientCredentialsScopePolicy selects the scopes of client credentials grants without the scope parameter, for
+	// example oauth2.AllClientScopesPolicy. Defaults to requesting no scopes.
+	ClientCredentialsScopePolicy oauth2.ClientCredentialsScopePolicy
+
+	// ResourceOwnerAuthenticator, if set, authenticates the resource owners of the password grant and enables
+	// multi-factor authentication challenges, see oauth2.ResourceOwnerAuthenticator.
+	ResourceOwnerAuthenticator oauth2.ResourceOwnerAuthenticator
+
+	// ResponseTypeRegistry, if set, defines the supported response types and the handlers responsible for each of
+	// them, see fosite.ResponseTypeRegistry.
+	ResponseTypeRegistry *fosite.ResponseTypeRegistry
+}
+
+// GetTracer returns the tracer of TracerProvider, or nil if no TracerProvider is set.
+func (c *Config) GetTracer() trace.Tracer {
+	if c.TracerProvider == nil {
+		return nil
+	}
+	return c.TracerProvider.Tracer(fosite.TracerName)
 }
 
 // GetScopeStrategy returns the scope strategy to be used. Defaults to glob scope strategy.
@@ -183,7 +505,7 @@ func (c *Config) GetHashCost() int {
 // GetJWKSFetcherStrategy returns the JWKSFetcherStrategy.
 func (c *Config) GetJWKSFetcherStrategy() fosite.JWKSFetcherStrategy {
 	if c.JWKSFetcher == nil {
-		c.JWKSFetcher = fosite.NewDefaultJWKSFetcherStrategy()
+		c.JWKSFetcher = fosite.NewJWKSFetcherStrategy(c.JWKSFetcherOptions)
 	}
 	return c.JWKSFetcher
 }
@@ -239,3 +561,87 @@ func (c *Config) GetJWTMaxDuration() time.Duration {
 func (c *Config) GetClientAuthenticationStrategy() fosite.ClientAuthenticationStrategy {
 	return c.ClientAuthenticationStrategy
 }
+
+// GetClientAuthenticationHandlers returns the client authentication methods used by the default client
+// authentication strategy. Defaults to fosite.DefaultClientAuthenticationHandlers().
+func (c *Config) GetClientAuthenticationHandlers() fosite.ClientAuthenticationHandlers {
+	if len(c.ClientAuthenticationHandlers) == 0 {
+		return fosite.DefaultClientAuthenticationHandlers()
+	}
+	return append(fosite.ClientAuthenticationHandlers{}, c.ClientAuthenticationHandlers...)
+}
+
+// GetDeviceAndUserCodeLifespan returns how long the device and user code should be valid. Defaults to ten minutes.
+func (c *Config) GetDeviceAndUserCodeLifespan() time.Duration {
+	if c.DeviceAndUserCodeLifespan == 0 {
+		return time.Minute * 10
+	}
+	return c.DeviceAndUserCodeLifespan
+}
+
+// GetDeviceAuthTokenPollingInterval returns the minimum polling interval of the device code grant. Defaults to five
+// seconds.
+func (c *Config) GetDeviceAuthTokenPollingInterval() time.Duration {
+	if c.DeviceAuthTokenPollingInterval == 0 {
+		return time.Second * 5
+	}
+	return c.DeviceAuthTokenPollingInterval
+}
+
+// GetPreAuthorizedCodeLifespan returns how long a pre-authorized code should be valid. Defaults to five minutes.
+func (c *Config) GetPreAuthorizedCodeLifespan() time.Duration {
+	if c.PreAuthorizedCodeLifespan == 0 {
+		return time.Minute * 5
+	}
+	return c.PreAuthorizedCodeLifespan
+}
+
+// GetCIBAAuthRequestIDLifespan returns how long the auth_req_id should be valid. Defaults to ten minutes.
+func (c *Config) GetCIBAAuthRequestIDLifespan() time.Duration {
+	if c.CIBAAuthRequestIDLifespan == 0 {
+		return time.Minute * 10
+	}
+	return c.CIBAAuthRequestIDLifespan
+}
+
+// GetCIBAPollingInterval returns the minimum polling interval of the CIBA grant. Defaults to five seconds.
+func (c *Config) GetCIBAPollingInterval() time.Duration {
+	if c.CIBAPollingInterval == 0 {
+		return ciba.DefaultPollingInterval
+	}
+	return c.CIBAPollingInterval
+}
+
+// GetCIBANotifier returns the notifier used for the ping and push token delivery modes. Defaults to
+// ciba.DefaultNotifier.
+func (c *Config) GetCIBANotifier() ciba.Notifier {
+	if c.CIBANotifier == nil {
+		return &ciba.DefaultNotifier{}
+	}
+	return c.CIBANotifier
+}
+
+// GetPushedAuthorizeRequestURIPrefix returns the prefix of request_uri values. Defaults to
+// fosite.PushedAuthorizeRequestURIPrefix.
+func (c *Config) GetPushedAuthorizeRequestURIPrefix() string {
+	if c.PushedAuthorizeRequestURIPrefix == "" {
+		return fosite.PushedAuthorizeRequestURIPrefix
+	}
+	return c.PushedAuthorizeRequestURIPrefix
+}
+
+// GetDPoPProofLifespan returns the time frame in which DPoP proofs are accepted. Defaults to one minute.
+func (c *Config) GetDPoPProofLifespan() time.Duration {
+	if c.DPoPProofLifespan == 0 {
+		return fosite.DPoPProofLifespan
+	}
+	return c.DPoPProofLifespan
+}
+
+// GetPushedAuthorizeContextLifespan returns how long a request_uri should be valid. Defaults to one minute.
+func (c *Config) GetPushedAuthorizeContextLifespan() time.Duration {
+	if c.PushedAuthorizeContextLifespan == 0 {
+		return time.Minute
+	}
+	return c.PushedAuthorizeContextLifespan
+}
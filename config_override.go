@@ -0,0 +1,107 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"time"
+)
+
+// WithAccessTokenLifespan returns a context which overrides the configured lifespan of the access tokens issued while
+// handling a single request, for example to issue short-lived tokens for one tenant or route without creating
+// another provider. The override must be set on the context passed to NewAccessResponse, NewAuthorizeResponse and
+// similar methods.
+func WithAccessTokenLifespan(ctx context.Context, lifespan time.Duration) context.Context {
+	return context.WithValue(ctx, AccessTokenLifespanContextKey, lifespan)
+}
+
+// WithRefreshTokenLifespan is WithAccessTokenLifespan for refresh tokens. A negative lifespan issues refresh tokens
+// which do not expire.
+func WithRefreshTokenLifespan(ctx context.Context, lifespan time.Duration) context.Context {
+	return context.WithValue(ctx, RefreshTokenLifespanContextKey, lifespan)
+}
+
+// WithAuthorizeCodeLifespan is WithAccessTokenLifespan for authorize codes.
+func WithAuthorizeCodeLifespan(ctx context.Context, lifespan time.Duration) context.Context {
+	return context.WithValue(ctx, AuthorizeCodeLifespanContextKey, lifespan)
+}
+
+// WithIDTokenLifespan is WithAccessTokenLifespan for ID tokens.
+func WithIDTokenLifespan(ctx context.Context, lifespan time.Duration) context.Context {
+	return context.WithValue(ctx, IDTokenLifespanContextKey, lifespan)
+}
+
+// WithIssuer returns a context which overrides the issuer of a single request, see GetIssuer.
+func WithIssuer(ctx context.Context, issuer string) context.Context {
+	return context.WithValue(ctx, IssuerContextKey, issuer)
+}
+
+// WithAudienceMatchingStrategy returns a context which overrides the configured audience matching strategy of a
+// single request. Strategies selected by the client, see AudienceMatchingStrategyClient, take precedence.
+func WithAudienceMatchingStrategy(ctx context.Context, strategy AudienceMatchingStrategy) context.Context {
+	return context.WithValue(ctx, AudienceMatchingStrategyContextKey, strategy)
+}
+
+// GetAccessTokenLifespan returns the lifespan set using WithAccessTokenLifespan, or lifespan if the context does not
+// override it. Handlers call it with their configured lifespan.
+func GetAccessTokenLifespan(ctx context.Context, lifespan time.Duration) time.Duration {
+	return lifespanFromContext(ctx, AccessTokenLifespanContextKey, lifespan)
+}
+
+// GetRefreshTokenLifespan returns the lifespan set using WithRefreshTokenLifespan, or lifespan if the context does not
+// override it.
+func GetRefreshTokenLifespan(ctx context.Context, lifespan time.Duration) time.Duration {
+	return lifespanFromContext(ctx, RefreshTokenLifespanContextKey, lifespan)
+}
+
+// GetAuthorizeCodeLifespan returns the lifespan set using WithAuthorizeCodeLifespan, or lifespan if the context does
+// not override it.
+func GetAuthorizeCodeLifespan(ctx context.Context, lifespan time.Duration) time.Duration {
+	return lifespanFromContext(ctx, AuthorizeCodeLifespanContextKey, lifespan)
+}
+
+// GetIDTokenLifespan returns the lifespan set using WithIDTokenLifespan, or lifespan if the context does not override
+// it.
+func GetIDTokenLifespan(ctx context.Context, lifespan time.Duration) time.Duration {
+	return lifespanFromContext(ctx, IDTokenLifespanContextKey, lifespan)
+}
+
+// GetAudienceMatchingStrategy returns the audience matching strategy selected by the client, the strategy set using
+// WithAudienceMatchingStrategy, or fallback, in that order.
+func GetAudienceMatchingStrategy(ctx context.Context, client Client, fallback AudienceMatchingStrategy) AudienceMatchingStrategy {
+	if ctx != nil {
+		if strategy, ok := ctx.Value(AudienceMatchingStrategyContextKey).(AudienceMatchingStrategy); ok && strategy != nil {
+			fallback = strategy
+		}
+	}
+	return ClientAudienceMatchingStrategy(client, fallback)
+}
+
+func lifespanFromContext(ctx context.Context, key ContextKey, lifespan time.Duration) time.Duration {
+	if ctx == nil {
+		return lifespan
+	}
+	if fromContext, ok := ctx.Value(key).(time.Duration); ok {
+		return fromContext
+	}
+	return lifespan
+}
@@ -0,0 +1,63 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/fosite"
+)
+
+func TestConfigOverrides(t *testing.T) {
+	ctx := context.Background()
+	assert.Equal(t, time.Hour, GetAccessTokenLifespan(ctx, time.Hour))
+	assert.Equal(t, time.Hour, GetRefreshTokenLifespan(nil, time.Hour))
+
+	ctx = WithAccessTokenLifespan(ctx, time.Minute)
+	ctx = WithRefreshTokenLifespan(ctx, -1)
+	ctx = WithAuthorizeCodeLifespan(ctx, time.Second)
+	ctx = WithIDTokenLifespan(ctx, time.Second*2)
+	ctx = WithIssuer(ctx, "https://tenant.example.com")
+
+	assert.Equal(t, time.Minute, GetAccessTokenLifespan(ctx, time.Hour))
+	assert.Equal(t, time.Duration(-1), GetRefreshTokenLifespan(ctx, time.Hour))
+	assert.Equal(t, time.Second, GetAuthorizeCodeLifespan(ctx, time.Hour))
+	assert.Equal(t, time.Second*2, GetIDTokenLifespan(ctx, time.Hour))
+
+	issuer, err := GetIssuer(ctx, StaticIssuerProvider("https://example.com"), "")
+	require.NoError(t, err)
+	assert.Equal(t, "https://tenant.example.com", issuer)
+
+	client := &DefaultClient{}
+	audience := []string{"https://*.example.com"}
+	requested := []string{"https://api.example.com"}
+	assert.Error(t, GetAudienceMatchingStrategy(ctx, client, DefaultAudienceMatchingStrategy)(audience, requested))
+
+	ctx = WithAudienceMatchingStrategy(ctx, WildcardAudienceMatchingStrategy)
+	assert.NoError(t, GetAudienceMatchingStrategy(ctx, client, DefaultAudienceMatchingStrategy)(audience, requested))
+	assert.Error(t, GetAudienceMatchingStrategy(ctx, &DefaultOpenIDConnectClient{DefaultClient: client, AudienceMatchingStrategy: AudienceMatchingStrategyExact}, DefaultAudienceMatchingStrategy)(audience, requested))
+}
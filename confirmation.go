@@ -0,0 +1,114 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"github.com/ory/x/errorsx"
+
+	"github.com/ory/fosite/token/jwt"
+)
+
+// ConfirmationClaim is the name of the claim binding a token to a key, see https://tools.ietf.org/html/rfc7800#section-3.1
+const ConfirmationClaim = "cnf"
+
+// jwtClaimsSession is implemented by sessions which keep their claims in a JWT claims container, such as
+// oauth2.JWTSession.
+type jwtClaimsSession interface {
+	GetJWTClaims() jwt.JWTClaimsContainer
+}
+
+// jwtClaimsAdder is implemented by *jwt.JWTClaims.
+type jwtClaimsAdder interface {
+	Add(key string, value interface{})
+}
+
+// GetConfirmation returns the value of the given confirmation method (e.g. "jkt") of the session's "cnf" claim, or
+// an empty string if the token is not bound to a key.
+func GetConfirmation(session Session, method string) string {
+	var claims map[string]interface{}
+	if s, ok := session.(jwtClaimsSession); ok {
+		claims = s.GetJWTClaims().ToMapClaims()
+	} else if s, ok := session.(ExtraClaimsSession); ok {
+		claims = s.GetExtraClaims()
+	}
+
+	switch cnf := claims[ConfirmationClaim].(type) {
+	case map[string]interface{}:
+		value, _ := cnf[method].(string)
+		return value
+	case map[string]string:
+		return cnf[method]
+	}
+	return ""
+}
+
+// SetConfirmation binds the session's tokens to a key by adding the given confirmation method and value to the
+// session's "cnf" claim. It returns false if the session is unable to store extra claims.
+func SetConfirmation(session Session, method, value string) bool {
+	var claims map[string]interface{}
+	if s, ok := session.(jwtClaimsSession); ok {
+		claims = s.GetJWTClaims().ToMapClaims()
+	} else if s, ok := session.(ExtraClaimsSession); ok {
+		claims = s.GetExtraClaims()
+	} else {
+		return false
+	}
+
+	cnf := map[string]interface{}{}
+	switch existing := claims[ConfirmationClaim].(type) {
+	case map[string]interface{}:
+		for k, v := range existing {
+			cnf[k] = v
+		}
+	case map[string]string:
+		for k, v := range existing {
+			cnf[k] = v
+		}
+	}
+	cnf[method] = value
+
+	if s, ok := session.(jwtClaimsSession); ok {
+		adder, ok := s.GetJWTClaims().(jwtClaimsAdder)
+		if !ok {
+			return false
+		}
+		adder.Add(ConfirmationClaim, cnf)
+		return true
+	}
+
+	claims[ConfirmationClaim] = cnf
+	return true
+}
+
+// requireSenderConstrainedAccessRequest returns an error if RequireSenderConstrainedAccessTokens is enabled but the
+// access token would neither be bound to a DPoP key nor to a TLS client certificate.
+func (f *Fosite) requireSenderConstrainedAccessRequest(requester AccessRequester) error {
+	if !f.RequireSenderConstrainedAccessTokens {
+		return nil
+	}
+
+	session := requester.GetSession()
+	if GetConfirmation(session, DPoPConfirmationMethod) == "" && GetConfirmation(session, CertificateConfirmationMethod) == "" {
+		return errorsx.WithStack(ErrInvalidRequest.WithHint("Access tokens must be sender-constrained, but the request contains neither a DPoP proof nor a TLS client certificate."))
+	}
+	return nil
+}
@@ -0,0 +1,246 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"time"
+
+	"github.com/ory/x/errorsx"
+	"github.com/pkg/errors"
+)
+
+// Consent holds the scopes and audiences a resource owner consented to for a client.
+type Consent struct {
+	ClientID        string    `json:"client_id"`
+	Subject         string    `json:"subject"`
+	GrantedScope    Arguments `json:"granted_scope"`
+	GrantedAudience Arguments `json:"granted_audience"`
+	GrantedAt       time.Time `json:"granted_at"`
+}
+
+// ConsentStorage persists the consent given by resource owners.
+type ConsentStorage interface {
+	// GetConsent returns the consent of the subject for the client or ErrNotFound.
+	GetConsent(ctx context.Context, clientID, subject string) (*Consent, error)
+
+	// SetConsent creates or replaces the consent of consent.Subject for consent.ClientID.
+	SetConsent(ctx context.Context, consent *Consent) error
+
+	// RevokeConsent removes the consent of the subject for the client.
+	RevokeConsent(ctx context.Context, clientID, subject string) error
+}
+
+// ConsentStrategy decides whether the resource owner has to be asked for consent and remembers the consent given.
+//
+// If a ConsentStrategy is configured, NewAuthorizeResponse grants the requested scopes and audiences automatically
+// if the application did not grant any and the resource owner consented to all of them before. If consent is
+// required instead, ErrConsentRequired is returned. Scopes and audiences granted by the application are recorded.
+type ConsentStrategy interface {
+	// IsConsentRequired returns false if the subject previously consented to all scopes and audiences requested
+	// from the client and did not ask to be prompted for consent again.
+	IsConsentRequired(ctx context.Context, requester AuthorizeRequester, subject string) (bool, error)
+
+	// RecordConsent remembers the scopes and audiences granted in the request.
+	RecordConsent(ctx context.Context, requester AuthorizeRequester, subject string) error
+}
+
+// IncrementalConsentStrategy is implemented by ConsentStrategy implementations which support incremental
+// authorization. If an authorize request sets include_granted_scopes=true, NewAuthorizeResponse also grants the scopes
+// and audiences the resource owner consented to before, as long as the client is still allowed to request them.
+type IncrementalConsentStrategy interface {
+	// GetGrantedConsent returns the consent the subject gave to the client of the request before or nil.
+	GetGrantedConsent(ctx context.Context, requester AuthorizeRequester, subject string) (*Consent, error)
+}
+
+// DefaultConsentStrategy is a ConsentStrategy backed by a ConsentStorage. It implements IncrementalConsentStrategy.
+type DefaultConsentStrategy struct {
+	Storage ConsentStorage
+
+	// ScopeStrategy is used to match the requested scopes against the granted ones. Defaults to
+	// HierarchicScopeStrategy.
+	ScopeStrategy ScopeStrategy
+
+	// AudienceMatchingStrategy is used to match the requested audience against the granted one. Defaults to
+	// DefaultAudienceMatchingStrategy.
+	AudienceMatchingStrategy AudienceMatchingStrategy
+
+	// ConsentLifespan limits how long consent is remembered. Leave to 0 to remember consent until it is revoked.
+	ConsentLifespan time.Duration
+}
+
+func (s *DefaultConsentStrategy) IsConsentRequired(ctx context.Context, requester AuthorizeRequester, subject string) (bool, error) {
+	if isPromptRequested(requester, "consent") {
+		return true, nil
+	}
+	if len(requester.GetRequestedScopes()) == 0 && len(requester.GetRequestedAudience()) == 0 {
+		return false, nil
+	}
+
+	consent, err := s.GetGrantedConsent(ctx, requester, subject)
+	if err != nil {
+		return false, err
+	} else if consent == nil {
+		return true, nil
+	}
+
+	scopeStrategy := s.ScopeStrategy
+	if scopeStrategy == nil {
+		scopeStrategy = HierarchicScopeStrategy
+	}
+	for _, scope := range requester.GetRequestedScopes() {
+		if !scopeStrategy(consent.GrantedScope, scope) {
+			return true, nil
+		}
+	}
+
+	audienceStrategy := s.AudienceMatchingStrategy
+	if audienceStrategy == nil {
+		audienceStrategy = DefaultAudienceMatchingStrategy
+	}
+	if err := audienceStrategy(consent.GrantedAudience, requester.GetRequestedAudience()); err != nil {
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// RecordConsent adds the scopes and audiences granted in the request to the consent stored for the subject.
+func (s *DefaultConsentStrategy) RecordConsent(ctx context.Context, requester AuthorizeRequester, subject string) error {
+	consent, err := s.GetGrantedConsent(ctx, requester, subject)
+	if err != nil {
+		return err
+	} else if consent == nil {
+		consent = &Consent{ClientID: requester.GetClient().GetID(), Subject: subject}
+	}
+
+	for _, scope := range requester.GetGrantedScopes() {
+		consent.GrantedScope = appendUnique(consent.GrantedScope, scope)
+	}
+	for _, audience := range requester.GetGrantedAudience() {
+		consent.GrantedAudience = appendUnique(consent.GrantedAudience, audience)
+	}
+	consent.GrantedAt = time.Now().UTC()
+
+	return StorageOperation(ctx, "SetConsent", func(ctx context.Context) error {
+		return s.Storage.SetConsent(ctx, consent)
+	})
+}
+
+// GetGrantedConsent returns the consent stored for the subject and the client of the request or nil if there is
+// none or it expired.
+func (s *DefaultConsentStrategy) GetGrantedConsent(ctx context.Context, requester AuthorizeRequester, subject string) (*Consent, error) {
+	var consent *Consent
+	err := StorageOperation(ctx, "GetConsent", func(ctx context.Context) (err error) {
+		consent, err = s.Storage.GetConsent(ctx, requester.GetClient().GetID(), subject)
+		return err
+	})
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if s.ConsentLifespan > 0 && consent.GrantedAt.Add(s.ConsentLifespan).Before(time.Now().UTC()) {
+		return nil, nil
+	}
+	return consent, nil
+}
+
+func appendUnique(values Arguments, value string) Arguments {
+	if values.Has(value) {
+		return values
+	}
+	return append(values, value)
+}
+
+// IsConsentRequired returns true if a ConsentStrategy is configured and the resource owner has to be asked for
+// consent before NewAuthorizeResponse can be called without granting any scopes.
+func (f *Fosite) IsConsentRequired(ctx context.Context, ar AuthorizeRequester, session Session) (bool, error) {
+	ctx = f.withStoragePolicy(ctx)
+	ctx = ensureRequestID(ctx, nil)
+	if f.ConsentStrategy == nil {
+		return false, nil
+	}
+	required, err := f.ConsentStrategy.IsConsentRequired(ctx, ar, session.GetSubject())
+	if err != nil {
+		return false, attachRequestID(ctx, errorsx.WithStack(ErrServerError.WithWrap(err).WithDebug(err.Error())))
+	}
+	return required, nil
+}
+
+// handleConsent grants the requested scopes and audiences if the resource owner consented to them before and the
+// application did not grant any itself. It returns true if the grants of the request need to be recorded.
+func (f *Fosite) handleConsent(ctx context.Context, ar AuthorizeRequester, session Session) (bool, error) {
+	if f.ConsentStrategy == nil {
+		return false, nil
+	}
+	if len(ar.GetGrantedScopes()) > 0 || len(ar.GetGrantedAudience()) > 0 {
+		return true, nil
+	}
+
+	required, err := f.IsConsentRequired(ctx, ar, session)
+	if err != nil {
+		return false, err
+	} else if required {
+		return false, errorsx.WithStack(ErrConsentRequired.WithHint("The resource owner has not yet consented to the requested scopes and audiences."))
+	}
+
+	for _, scope := range ar.GetRequestedScopes() {
+		ar.GrantScope(scope)
+	}
+	for _, audience := range ar.GetRequestedAudience() {
+		ar.GrantAudience(audience)
+	}
+
+	f.GetEventListener().OnConsentSkipped(ctx, ar)
+	return false, nil
+}
+
+// includeGrantedScopes grants the scopes and audiences the resource owner consented to before if the request sets
+// include_granted_scopes=true and the ConsentStrategy implements IncrementalConsentStrategy.
+func (f *Fosite) includeGrantedScopes(ctx context.Context, ar AuthorizeRequester, session Session) error {
+	strategy, ok := f.ConsentStrategy.(IncrementalConsentStrategy)
+	if !ok || ar.GetRequestForm().Get("include_granted_scopes") != "true" {
+		return nil
+	}
+
+	consent, err := strategy.GetGrantedConsent(ctx, ar, session.GetSubject())
+	if err != nil {
+		return errorsx.WithStack(ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	} else if consent == nil {
+		return nil
+	}
+
+	client := ar.GetClient()
+	for _, scope := range consent.GrantedScope {
+		if !ar.GetGrantedScopes().Has(scope) && ClientScopeStrategy(client, f.ScopeStrategy)(client.GetScopes(), scope) {
+			ar.GrantScope(scope)
+		}
+	}
+	for _, audience := range consent.GrantedAudience {
+		if !ar.GetGrantedAudience().Has(audience) && GetAudienceMatchingStrategy(ctx, client, f.AudienceMatchingStrategy)(client.GetAudience(), []string{audience}) == nil {
+			ar.GrantAudience(audience)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/storage"
+)
+
+func TestConsentStrategy(t *testing.T) {
+	store := storage.NewExampleStore()
+	listener := &recordingEventListener{}
+	config := &compose.Config{
+		EventListener:   listener,
+		ConsentStrategy: &DefaultConsentStrategy{Storage: store},
+	}
+	f := compose.Compose(
+		config,
+		store,
+		compose.NewOAuth2HMACStrategy(config, []byte("some-secret-thats-random-some-secret-thats-random-"), nil),
+		nil,
+		compose.OAuth2AuthorizeImplicitFactory,
+	)
+	ctx := context.Background()
+	session := &DefaultSession{Subject: "peter"}
+
+	authorize := func(t *testing.T, scope, prompt string, grant ...string) (bool, error) {
+		r := httptest.NewRequest("GET", "/auth?"+url.Values{
+			"client_id":     {"my-client"},
+			"response_type": {"token"},
+			"redirect_uri":  {"http://localhost:3846/callback"},
+			"state":         {"some-random-state"},
+			"scope":         {scope},
+			"prompt":        {prompt},
+		}.Encode(), nil)
+		ar, err := f.NewAuthorizeRequest(ctx, r)
+		require.NoError(t, err)
+
+		required, err := f.IsConsentRequired(ctx, ar, session)
+		require.NoError(t, err)
+		for _, s := range grant {
+			ar.GrantScope(s)
+		}
+
+		_, err = f.NewAuthorizeResponse(ctx, ar, session)
+		if err == nil {
+			assert.EqualValues(t, ar.GetRequestedScopes(), ar.GetGrantedScopes())
+		}
+		return required, err
+	}
+
+	required, err := authorize(t, "photos", "")
+	assert.True(t, required)
+	assert.True(t, errors.Is(err, ErrConsentRequired))
+
+	required, err = authorize(t, "photos", "", "photos")
+	assert.True(t, required)
+	require.NoError(t, err)
+
+	consent, err := store.GetConsent(ctx, "my-client", "peter")
+	require.NoError(t, err)
+	assert.EqualValues(t, Arguments{"photos"}, consent.GrantedScope)
+
+	listener.events = nil
+	required, err = authorize(t, "photos", "")
+	assert.False(t, required)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"consent_skipped:my-client", "granted:my-client"}, listener.events)
+
+	required, err = authorize(t, "photos", "consent")
+	assert.True(t, required)
+	assert.True(t, errors.Is(err, ErrConsentRequired))
+
+	required, err = authorize(t, "photos fosite", "")
+	assert.True(t, required)
+	assert.True(t, errors.Is(err, ErrConsentRequired))
+
+	require.NoError(t, store.RevokeConsent(ctx, "my-client", "peter"))
+	required, err = authorize(t, "photos", "")
+	assert.True(t, required)
+	assert.True(t, errors.Is(err, ErrConsentRequired))
+}
+
+func TestDefaultConsentStrategy(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	s := &DefaultConsentStrategy{Storage: store, ConsentLifespan: time.Hour}
+
+	ar := NewAuthorizeRequest()
+	ar.Client = &DefaultClient{ID: "foo"}
+	ar.RequestedScope = Arguments{"photos.read"}
+	ar.RequestedAudience = Arguments{"https://api"}
+
+	required, err := s.IsConsentRequired(ctx, ar, "peter")
+	require.NoError(t, err)
+	assert.True(t, required)
+
+	require.NoError(t, store.SetConsent(ctx, &Consent{ClientID: "foo", Subject: "peter", GrantedScope: Arguments{"photos"}, GrantedAudience: Arguments{"https://api"}, GrantedAt: time.Now().UTC()}))
+	required, err = s.IsConsentRequired(ctx, ar, "peter")
+	require.NoError(t, err)
+	assert.False(t, required, "hierarchic scopes and audiences are matched")
+
+	require.NoError(t, store.SetConsent(ctx, &Consent{ClientID: "foo", Subject: "peter", GrantedScope: Arguments{"photos"}, GrantedAudience: Arguments{"https://api"}, GrantedAt: time.Now().UTC().Add(-2 * time.Hour)}))
+	required, err = s.IsConsentRequired(ctx, ar, "peter")
+	require.NoError(t, err)
+	assert.True(t, required, "expired consent is ignored")
+
+	ar.GrantScope("photos.read")
+	require.NoError(t, s.RecordConsent(ctx, ar, "peter"))
+	consent, err := store.GetConsent(ctx, "foo", "peter")
+	require.NoError(t, err)
+	assert.EqualValues(t, Arguments{"photos.read"}, consent.GrantedScope, "expired consent is replaced")
+}
+
+func TestIncrementalAuthorization(t *testing.T) {
+	store := storage.NewExampleStore()
+	config := &compose.Config{ConsentStrategy: &DefaultConsentStrategy{Storage: store}}
+	f := compose.Compose(
+		config,
+		store,
+		compose.NewOAuth2HMACStrategy(config, []byte("some-secret-thats-random-some-secret-thats-random-"), nil),
+		nil,
+		compose.OAuth2AuthorizeImplicitFactory,
+	)
+	ctx := context.Background()
+	session := &DefaultSession{Subject: "peter"}
+
+	authorize := func(t *testing.T, scope, includeGrantedScopes string) AuthorizeRequester {
+		r := httptest.NewRequest("GET", "/auth?"+url.Values{
+			"client_id":              {"my-client"},
+			"response_type":          {"token"},
+			"redirect_uri":           {"http://localhost:3846/callback"},
+			"state":                  {"some-random-state"},
+			"scope":                  {scope},
+			"include_granted_scopes": {includeGrantedScopes},
+		}.Encode(), nil)
+		ar, err := f.NewAuthorizeRequest(ctx, r)
+		require.NoError(t, err)
+		for _, s := range ar.GetRequestedScopes() {
+			ar.GrantScope(s)
+		}
+
+		_, err = f.NewAuthorizeResponse(ctx, ar, session)
+		require.NoError(t, err)
+		return ar
+	}
+
+	assert.EqualValues(t, Arguments{"fosite"}, authorize(t, "fosite", "true").GetGrantedScopes())
+	assert.EqualValues(t, Arguments{"photos"}, authorize(t, "photos", "").GetGrantedScopes())
+	assert.EqualValues(t, Arguments{"openid", "fosite", "photos"}, authorize(t, "openid", "true").GetGrantedScopes())
+
+	consent, err := store.GetConsent(ctx, "my-client", "peter")
+	require.NoError(t, err)
+	assert.EqualValues(t, Arguments{"fosite", "photos", "openid"}, consent.GrantedScope)
+
+	t.Run("case=should not include scopes the client may no longer request", func(t *testing.T) {
+		store.Clients["my-client"].(*DefaultClient).Scopes = []string{"fosite", "openid"}
+		assert.EqualValues(t, Arguments{"openid", "fosite"}, authorize(t, "openid", "true").GetGrantedScopes())
+	})
+}
@@ -30,9 +30,42 @@ func NewContext() context.Context {
 type ContextKey string
 
 const (
-	RequestContextKey           = ContextKey("request")
-	AccessRequestContextKey     = ContextKey("accessRequest")
-	AccessResponseContextKey    = ContextKey("accessResponse")
-	AuthorizeRequestContextKey  = ContextKey("authorizeRequest")
-	AuthorizeResponseContextKey = ContextKey("authorizeResponse")
+	RequestContextKey                 = ContextKey("request")
+	AccessRequestContextKey           = ContextKey("accessRequest")
+	AccessResponseContextKey          = ContextKey("accessResponse")
+	AuthorizeRequestContextKey        = ContextKey("authorizeRequest")
+	AuthorizeResponseContextKey       = ContextKey("authorizeResponse")
+	DeviceRequestContextKey           = ContextKey("deviceRequest")
+	DeviceResponseContextKey          = ContextKey("deviceResponse")
+	PushedAuthorizeResponseContextKey = ContextKey("pushedAuthorizeResponse")
+	ResourceRequestContextKey         = ContextKey("resourceRequest")
+	BackchannelRequestContextKey      = ContextKey("backchannelRequest")
+	BackchannelResponseContextKey     = ContextKey("backchannelResponse")
+
+	// AuthenticationRequirementsContextKey holds the AuthenticationRequirements IntrospectToken enforces for
+	// access tokens.
+	AuthenticationRequirementsContextKey = ContextKey("authenticationRequirements")
+
+	// IssuerContextKey holds the issuer of the request. It takes precedence over the configured issuers, see
+	// GetIssuer.
+	IssuerContextKey = ContextKey("issuer")
+
+	// StoragePolicyContextKey holds the StoragePolicy applied by StorageOperation.
+	StoragePolicyContextKey = ContextKey("storagePolicy")
+
+	// StorageTransactionContextKey marks contexts carrying a storage transaction, see storage.MaybeBeginTx.
+	// StorageOperation never retries operations within a transaction.
+	StorageTransactionContextKey = ContextKey("storageTransaction")
+
+	// RequestIDContextKey holds the correlation ID of the request, see WithRequestID.
+	RequestIDContextKey = ContextKey("requestID")
+
+	// AccessTokenLifespanContextKey, RefreshTokenLifespanContextKey, AuthorizeCodeLifespanContextKey,
+	// IDTokenLifespanContextKey and AudienceMatchingStrategyContextKey hold configuration overrides of a single
+	// request, see WithAccessTokenLifespan.
+	AccessTokenLifespanContextKey      = ContextKey("accessTokenLifespan")
+	RefreshTokenLifespanContextKey     = ContextKey("refreshTokenLifespan")
+	AuthorizeCodeLifespanContextKey    = ContextKey("authorizeCodeLifespan")
+	IDTokenLifespanContextKey          = ContextKey("idTokenLifespan")
+	AudienceMatchingStrategyContextKey = ContextKey("audienceMatchingStrategy")
 )
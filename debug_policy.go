@@ -0,0 +1,76 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+// DebugPolicy decides per request whether the debug information of an error, which may contain sensitive data,
+// is sent to the client. The debug information is always kept on the error, for example for logging.
+type DebugPolicy interface {
+	// ExposeDebug returns true if the debug information of err may be sent in the response to the request. The
+	// requester is nil if the request is not known, for example for introspection errors.
+	ExposeDebug(requester Requester, err *RFC6749Error) bool
+}
+
+// DebugPolicyFunc is a function implementing DebugPolicy.
+type DebugPolicyFunc func(requester Requester, err *RFC6749Error) bool
+
+func (f DebugPolicyFunc) ExposeDebug(requester Requester, err *RFC6749Error) bool {
+	return f(requester, err)
+}
+
+var (
+	// ExposeDebugPolicy sends the debug information to all clients.
+	ExposeDebugPolicy DebugPolicy = DebugPolicyFunc(func(Requester, *RFC6749Error) bool { return true })
+
+	// RedactDebugPolicy never sends the debug information to clients.
+	RedactDebugPolicy DebugPolicy = DebugPolicyFunc(func(Requester, *RFC6749Error) bool { return false })
+)
+
+// TrustedClientsDebugPolicy sends the debug information only to the listed clients, for example first party
+// clients, and never to requests without an identified client.
+type TrustedClientsDebugPolicy struct {
+	ClientIDs []string
+}
+
+var _ DebugPolicy = (*TrustedClientsDebugPolicy)(nil)
+
+func (p *TrustedClientsDebugPolicy) ExposeDebug(requester Requester, _ *RFC6749Error) bool {
+	if requester == nil || requester.GetClient() == nil {
+		return false
+	}
+	return StringInSlice(requester.GetClient().GetID(), p.ClientIDs)
+}
+
+// GetDebugPolicy returns DebugPolicy if set. Defaults to ExposeDebugPolicy if SendDebugMessagesToClients is set and
+// RedactDebugPolicy otherwise.
+func (f *Fosite) GetDebugPolicy() DebugPolicy {
+	if f.DebugPolicy != nil {
+		return f.DebugPolicy
+	} else if f.SendDebugMessagesToClients {
+		return ExposeDebugPolicy
+	}
+	return RedactDebugPolicy
+}
+
+// exposeDebug applies the DebugPolicy to the error response to the request.
+func (f *Fosite) exposeDebug(requester Requester, err *RFC6749Error) *RFC6749Error {
+	return err.WithExposeDebug(f.GetDebugPolicy().ExposeDebug(requester, err))
+}
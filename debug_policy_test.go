@@ -0,0 +1,69 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/fosite"
+)
+
+func TestDebugPolicy(t *testing.T) {
+	requestOf := func(clientID string) AccessRequester {
+		ar := NewAccessRequest(nil)
+		ar.Client = &DefaultClient{ID: clientID}
+		return ar
+	}
+	trusted := &TrustedClientsDebugPolicy{ClientIDs: []string{"first-party"}}
+
+	for _, c := range []struct {
+		d         string
+		f         *Fosite
+		requester AccessRequester
+		expose    bool
+	}{
+		{d: "should redact debug messages by default", f: &Fosite{}, requester: requestOf("first-party")},
+		{d: "should expose debug messages if SendDebugMessagesToClients is set", f: &Fosite{SendDebugMessagesToClients: true}, requester: requestOf("third-party"), expose: true},
+		{d: "should prefer the policy over SendDebugMessagesToClients", f: &Fosite{SendDebugMessagesToClients: true, DebugPolicy: RedactDebugPolicy}, requester: requestOf("first-party")},
+		{d: "should expose debug messages to trusted clients", f: &Fosite{DebugPolicy: trusted}, requester: requestOf("first-party"), expose: true},
+		{d: "should redact debug messages for other clients", f: &Fosite{DebugPolicy: trusted}, requester: requestOf("third-party")},
+		{d: "should redact debug messages without a request", f: &Fosite{DebugPolicy: trusted}},
+	} {
+		t.Run("case="+c.d, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			c.f.WriteAccessError(rw, c.requester, ErrInvalidRequest.WithDebug("the database is unreachable"))
+
+			var body map[string]interface{}
+			require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &body))
+			if c.expose {
+				assert.Contains(t, body["error_description"], "the database is unreachable")
+			} else {
+				assert.NotContains(t, body["error_description"], "the database is unreachable")
+			}
+		})
+	}
+}
@@ -0,0 +1,250 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ory/go-convenience/stringslice"
+)
+
+// describedGrantTypes are the grant types each token endpoint handler is probed for in addition to the grant types
+// announced by the handlers using DiscoveryMetadataProvider.
+var describedGrantTypes = []string{
+	"authorization_code",
+	"refresh_token",
+	"client_credentials",
+	"password",
+	"urn:ietf:params:oauth:grant-type:jwt-bearer",
+	"urn:ietf:params:oauth:grant-type:saml2-bearer",
+	"urn:ietf:params:oauth:grant-type:device_code",
+	"urn:ietf:params:oauth:grant-type:token-exchange",
+	"urn:ietf:params:oauth:grant-type:pre-authorized_code",
+	"urn:openid:params:grant-type:ciba",
+}
+
+// HandlerDescription describes a handler registered with the provider.
+type HandlerDescription struct {
+	// Endpoint is the endpoint the handler is registered for, for example TokenEndpoint.
+	Endpoint string `json:"endpoint"`
+
+	// Position is the position of the handler in the handlers of the endpoint, starting at zero. Handlers are called
+	// in this order.
+	Position int `json:"position"`
+
+	// Type is the Go type of the handler, for example "*oauth2.ClientCredentialsGrantHandler".
+	Type string `json:"type"`
+
+	// GrantTypes are the grant types a token endpoint handler is responsible for.
+	GrantTypes []string `json:"grant_types,omitempty"`
+
+	// ResponseTypes are the response types an authorize endpoint handler announces or is registered for in the
+	// ResponseTypeRegistry.
+	ResponseTypes []string `json:"response_types,omitempty"`
+}
+
+// ProviderDescription describes the handlers registered with the provider, see Describe.
+type ProviderDescription struct {
+	Handlers []HandlerDescription `json:"handlers"`
+
+	// Gaps are problems which make requests fail, for example authorization codes which are issued but can not be
+	// exchanged at the token endpoint.
+	Gaps []string `json:"gaps,omitempty"`
+
+	// Overlaps list the grant and response types several handlers are responsible for. This is expected for
+	// handlers which extend each other, for example the OpenID Connect and PKCE handlers of the authorize code
+	// flow, but may also point to handlers which unexpectedly compete for the same requests.
+	Overlaps []string `json:"overlaps,omitempty"`
+}
+
+// String returns a human readable description of the handlers, gaps and overlaps.
+func (d *ProviderDescription) String() string {
+	var b strings.Builder
+	for _, h := range d.Handlers {
+		fmt.Fprintf(&b, "%s[%d] %s", h.Endpoint, h.Position, h.Type)
+		if len(h.GrantTypes) > 0 {
+			fmt.Fprintf(&b, " grant_types=%s", strings.Join(h.GrantTypes, ","))
+		}
+		if len(h.ResponseTypes) > 0 {
+			fmt.Fprintf(&b, " response_types=%s", strings.Join(h.ResponseTypes, ","))
+		}
+		b.WriteString("\n")
+	}
+	for _, gap := range d.Gaps {
+		fmt.Fprintf(&b, "gap: %s\n", gap)
+	}
+	for _, overlap := range d.Overlaps {
+		fmt.Fprintf(&b, "overlap: %s\n", overlap)
+	}
+	return b.String()
+}
+
+// Describe lists the registered handlers in the order they are called, the grant and response types they are
+// responsible for, and detected gaps and overlaps. It helps to debug errors such as "The handler is not responsible
+// for this request" without reading the composition of the provider.
+func (f *Fosite) Describe() *ProviderDescription {
+	ctx := context.Background()
+	d := new(ProviderDescription)
+
+	grantTypes := append([]string{}, describedGrantTypes...)
+	for _, h := range f.TokenEndpointHandlers {
+		grantTypes = append(grantTypes, discoveredValues(ctx, h).GrantTypesSupported...)
+	}
+	grantTypes = stringslice.Unique(grantTypes)
+
+	grantTypeHandlers := map[string][]string{}
+	for k, h := range f.TokenEndpointHandlers {
+		desc := HandlerDescription{Endpoint: TokenEndpoint, Position: k, Type: handlerType(h)}
+		for _, grantType := range grantTypes {
+			if canHandleGrantType(h, grantType) {
+				desc.GrantTypes = append(desc.GrantTypes, grantType)
+				grantTypeHandlers[grantType] = append(grantTypeHandlers[grantType], desc.Type)
+			}
+		}
+		d.Handlers = append(d.Handlers, desc)
+	}
+
+	responseTypeHandlers := map[string][]string{}
+	for k, h := range f.AuthorizeEndpointHandlers {
+		desc := HandlerDescription{Endpoint: AuthorizeEndpoint, Position: k, Type: handlerType(h)}
+		if f.ResponseTypeRegistry != nil {
+			for _, responseType := range f.ResponseTypeRegistry.ResponseTypes() {
+				handlers, _ := f.ResponseTypeRegistry.Handlers(strings.Split(responseType, " "))
+				for _, rh := range handlers {
+					if rh == h {
+						desc.ResponseTypes = append(desc.ResponseTypes, responseType)
+						break
+					}
+				}
+			}
+		} else {
+			desc.ResponseTypes = stringslice.Unique(discoveredValues(ctx, h).ResponseTypesSupported)
+		}
+		for _, responseType := range desc.ResponseTypes {
+			responseTypeHandlers[responseType] = append(responseTypeHandlers[responseType], desc.Type)
+		}
+		d.Handlers = append(d.Handlers, desc)
+	}
+
+	for k, h := range f.TokenIntrospectionHandlers {
+		d.Handlers = append(d.Handlers, HandlerDescription{Endpoint: IntrospectionEndpoint, Position: k, Type: handlerType(h)})
+	}
+	for k, h := range f.RevocationHandlers {
+		d.Handlers = append(d.Handlers, HandlerDescription{Endpoint: RevocationEndpoint, Position: k, Type: handlerType(h)})
+	}
+	for k, h := range f.PushedAuthorizeEndpointHandlers {
+		d.Handlers = append(d.Handlers, HandlerDescription{Endpoint: PushedAuthorizationEndpoint, Position: k, Type: handlerType(h)})
+	}
+	for k, h := range f.DeviceEndpointHandlers {
+		d.Handlers = append(d.Handlers, HandlerDescription{Endpoint: DeviceAuthorizationEndpoint, Position: k, Type: handlerType(h)})
+	}
+	for k, h := range f.BackchannelAuthenticationEndpointHandlers {
+		d.Handlers = append(d.Handlers, HandlerDescription{Endpoint: BackchannelAuthenticationEndpoint, Position: k, Type: handlerType(h)})
+	}
+
+	if len(f.TokenEndpointHandlers) == 0 {
+		d.Gaps = append(d.Gaps, "No token endpoint handler is registered, all token requests fail with unsupported_grant_type.")
+	}
+	if len(f.TokenIntrospectionHandlers) == 0 {
+		d.Gaps = append(d.Gaps, "No token introspection handler is registered, tokens can not be introspected.")
+	}
+	if len(f.RevocationHandlers) == 0 {
+		d.Gaps = append(d.Gaps, "No revocation handler is registered, tokens can not be revoked.")
+	}
+	if issuesCode(responseTypeHandlers) && len(grantTypeHandlers["authorization_code"]) == 0 {
+		d.Gaps = append(d.Gaps, "Authorization codes are issued by the authorize endpoint, but no token endpoint handler exchanges them.")
+	}
+	if len(grantTypeHandlers["urn:ietf:params:oauth:grant-type:device_code"]) > 0 && len(f.DeviceEndpointHandlers) == 0 {
+		d.Gaps = append(d.Gaps, "Device codes are exchanged at the token endpoint, but no device authorization endpoint handler issues them.")
+	} else if len(f.DeviceEndpointHandlers) > 0 && len(grantTypeHandlers["urn:ietf:params:oauth:grant-type:device_code"]) == 0 {
+		d.Gaps = append(d.Gaps, "Device codes are issued by the device authorization endpoint, but no token endpoint handler exchanges them.")
+	}
+	if len(grantTypeHandlers["urn:openid:params:grant-type:ciba"]) > 0 && len(f.BackchannelAuthenticationEndpointHandlers) == 0 {
+		d.Gaps = append(d.Gaps, "The CIBA grant is handled by the token endpoint, but no backchannel authentication endpoint handler issues auth_req_id values.")
+	} else if len(f.BackchannelAuthenticationEndpointHandlers) > 0 && len(grantTypeHandlers["urn:openid:params:grant-type:ciba"]) == 0 {
+		d.Gaps = append(d.Gaps, "auth_req_id values are issued by the backchannel authentication endpoint, but no token endpoint handler exchanges them.")
+	}
+	if f.EnforcePushedAuthorize && len(f.PushedAuthorizeEndpointHandlers) == 0 {
+		d.Gaps = append(d.Gaps, "Pushed authorization requests are enforced, but no pushed authorization endpoint handler is registered.")
+	}
+
+	d.Overlaps = append(overlaps("grant type", grantTypeHandlers), overlaps("response type", responseTypeHandlers)...)
+	return d
+}
+
+func handlerType(h interface{}) string {
+	return reflect.TypeOf(h).String()
+}
+
+func discoveredValues(ctx context.Context, h interface{}) *DiscoveryMetadata {
+	metadata := new(DiscoveryMetadata)
+	if p, ok := h.(DiscoveryMetadataProvider); ok {
+		p.PopulateDiscoveryMetadata(ctx, metadata)
+	}
+	return metadata
+}
+
+// canHandleGrantType probes the handler with an empty access request of the grant type. Handlers which inspect
+// more than the grant type and panic are considered not responsible.
+func canHandleGrantType(h TokenEndpointHandler, grantType string) (ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+
+	request := NewAccessRequest(new(DefaultSession))
+	request.GrantTypes = Arguments{grantType}
+	request.Form = url.Values{"grant_type": {grantType}}
+	request.Client = new(DefaultClient)
+	return h.CanHandleTokenEndpointRequest(request)
+}
+
+func issuesCode(responseTypeHandlers map[string][]string) bool {
+	for responseType := range responseTypeHandlers {
+		if Arguments(strings.Split(responseType, " ")).Has("code") {
+			return true
+		}
+	}
+	return false
+}
+
+func overlaps(kind string, handlers map[string][]string) []string {
+	values := make([]string, 0, len(handlers))
+	for value := range handlers {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+
+	var result []string
+	for _, value := range values {
+		if len(handlers[value]) > 1 {
+			result = append(result, fmt.Sprintf("The %s '%s' is handled by %d handlers, which are called in order: %s.", kind, value, len(handlers[value]), strings.Join(handlers[value], ", ")))
+		}
+	}
+	return result
+}
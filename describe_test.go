@@ -0,0 +1,88 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/storage"
+)
+
+func TestDescribe(t *testing.T) {
+	secret := []byte("some-secret-thats-random-some-secret-thats-random-")
+
+	t.Run("case=all enabled", func(t *testing.T) {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+
+		d := compose.ComposeAllEnabled(&compose.Config{}, storage.NewExampleStore(), secret, key).Describe()
+		assert.Empty(t, d.Gaps, "%s", d)
+
+		var found bool
+		for _, h := range d.Handlers {
+			if h.Type == "*oauth2.ClientCredentialsGrantHandler" {
+				found = true
+				assert.Equal(t, TokenEndpoint, h.Endpoint)
+				assert.Equal(t, []string{"client_credentials"}, h.GrantTypes)
+			}
+			if h.Type == "*oauth2.AuthorizeExplicitGrantHandler" && h.Endpoint == AuthorizeEndpoint {
+				assert.Equal(t, []string{"code"}, h.ResponseTypes)
+			}
+		}
+		assert.True(t, found)
+		assert.Contains(t, d.Overlaps, "The grant type 'authorization_code' is handled by 3 handlers, which are called in order: *oauth2.AuthorizeExplicitGrantHandler, *openid.OpenIDConnectExplicitHandler, *pkce.Handler.")
+		assert.Contains(t, d.String(), "token[0] *oauth2.AuthorizeExplicitGrantHandler grant_types=authorization_code\n")
+	})
+
+	t.Run("case=gaps", func(t *testing.T) {
+		config := &compose.Config{}
+		d := compose.Compose(config, storage.NewExampleStore(), compose.NewOAuth2HMACStrategy(config, secret, nil), nil,
+			compose.OAuth2AuthorizeImplicitFactory, compose.OAuth2ClientCredentialsGrantFactory).Describe()
+
+		assert.Equal(t, []string{
+			"No token introspection handler is registered, tokens can not be introspected.",
+			"No revocation handler is registered, tokens can not be revoked.",
+		}, d.Gaps)
+		assert.Empty(t, d.Overlaps)
+		assert.Equal(t, "token[0] *oauth2.ClientCredentialsGrantHandler grant_types=client_credentials\n"+
+			"authorize[0] *oauth2.AuthorizeImplicitGrantTypeHandler response_types=token\n"+
+			"gap: No token introspection handler is registered, tokens can not be introspected.\n"+
+			"gap: No revocation handler is registered, tokens can not be revoked.\n", d.String())
+	})
+
+	t.Run("case=code can not be exchanged", func(t *testing.T) {
+		f := &Fosite{AuthorizeEndpointHandlers: AuthorizeEndpointHandlers{}}
+		config := &compose.Config{}
+		f.AuthorizeEndpointHandlers.Append(compose.OAuth2AuthorizeExplicitFactory(config, storage.NewExampleStore(), compose.NewOAuth2HMACStrategy(config, secret, nil)).(AuthorizeEndpointHandler))
+
+		d := f.Describe()
+		assert.Contains(t, d.Gaps, "No token endpoint handler is registered, all token requests fail with unsupported_grant_type.")
+		assert.Contains(t, d.Gaps, "Authorization codes are issued by the authorize endpoint, but no token endpoint handler exchanges them.")
+	})
+}
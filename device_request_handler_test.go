@@ -0,0 +1,184 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/fosite"
+	"github.com/ory/fosite/internal"
+)
+
+func TestNewDeviceAuthorizeRequest(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := internal.NewMockStorage(ctrl)
+	defer ctrl.Finish()
+
+	client := &DefaultClient{ID: "foo", Public: true, Scopes: []string{"foo", "bar"}, Audience: []string{"https://www.ory.sh/api"}}
+	fosite := &Fosite{Store: store, ScopeStrategy: ExactScopeStrategy, AudienceMatchingStrategy: DefaultAudienceMatchingStrategy}
+	for k, c := range []struct {
+		d         string
+		method    string
+		form      url.Values
+		mock      func()
+		expectErr error
+		expect    *DeviceRequest
+	}{
+		{
+			d:         "should fail because of the method",
+			method:    "GET",
+			form:      url.Values{"client_id": {"foo"}},
+			mock:      func() {},
+			expectErr: ErrInvalidRequest,
+		},
+		{
+			d:         "should fail because the body is empty",
+			method:    "POST",
+			form:      url.Values{},
+			mock:      func() {},
+			expectErr: ErrInvalidRequest,
+		},
+		{
+			d:      "should fail because the client is unknown",
+			method: "POST",
+			form:   url.Values{"client_id": {"foo"}},
+			mock: func() {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq("foo")).Return(nil, errors.New("foo"))
+			},
+			expectErr: ErrInvalidClient,
+		},
+		{
+			d:      "should fail because the scope is not allowed",
+			method: "POST",
+			form:   url.Values{"client_id": {"foo"}, "scope": {"foo baz"}},
+			mock: func() {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq("foo")).Return(client, nil)
+			},
+			expectErr: ErrInvalidScope,
+		},
+		{
+			d:      "should fail because the audience is not allowed",
+			method: "POST",
+			form:   url.Values{"client_id": {"foo"}, "audience": {"https://www.ory.sh/not-api"}},
+			mock: func() {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq("foo")).Return(client, nil)
+			},
+			expectErr: ErrInvalidRequest,
+		},
+		{
+			d:      "should pass",
+			method: "POST",
+			form:   url.Values{"client_id": {"foo"}, "scope": {"foo bar"}, "audience": {"https://www.ory.sh/api"}},
+			mock: func() {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq("foo")).Return(client, nil)
+			},
+			expect: &DeviceRequest{
+				Request: Request{
+					Client:            client,
+					RequestedScope:    Arguments{"foo", "bar"},
+					RequestedAudience: Arguments{"https://www.ory.sh/api"},
+				},
+			},
+		},
+	} {
+		t.Run(c.d, func(t *testing.T) {
+			c.mock()
+			r := &http.Request{
+				Header:   http.Header{"Content-Type": {"application/x-www-form-urlencoded"}},
+				PostForm: c.form,
+				Form:     c.form,
+				Method:   c.method,
+			}
+			if c.method == "POST" {
+				r.Body = http.NoBody
+			}
+
+			ar, err := fosite.NewDeviceAuthorizeRequest(context.Background(), r)
+			if c.expectErr != nil {
+				assert.EqualError(t, err, c.expectErr.Error(), "%d", k)
+				return
+			}
+
+			require.NoError(t, err, "%d", k)
+			assert.Equal(t, c.expect.Client, ar.GetClient())
+			assert.Equal(t, c.expect.RequestedScope, ar.GetRequestedScopes())
+			assert.Equal(t, c.expect.RequestedAudience, ar.GetRequestedAudience())
+		})
+	}
+}
+
+func TestNewDeviceAuthorizeResponse(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	handler := internal.NewMockDeviceEndpointHandler(ctrl)
+	defer ctrl.Finish()
+
+	fosite := &Fosite{DeviceEndpointHandlers: DeviceEndpointHandlers{handler}}
+	session := new(DefaultSession)
+
+	handler.EXPECT().HandleDeviceEndpointRequest(gomock.Any(), gomock.Any(), gomock.Any()).Return(ErrServerError)
+	_, err := fosite.NewDeviceAuthorizeResponse(context.Background(), NewDeviceRequest(), session)
+	assert.EqualError(t, err, ErrServerError.Error())
+
+	handler.EXPECT().HandleDeviceEndpointRequest(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+	_, err = fosite.NewDeviceAuthorizeResponse(context.Background(), NewDeviceRequest(), session)
+	assert.EqualError(t, err, ErrServerError.Error())
+
+	handler.EXPECT().HandleDeviceEndpointRequest(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, _ DeviceRequester, resp DeviceResponder) error {
+		resp.SetDeviceCode("device")
+		resp.SetUserCode("user")
+		return nil
+	})
+	req := NewDeviceRequest()
+	resp, err := fosite.NewDeviceAuthorizeResponse(context.Background(), req, session)
+	require.NoError(t, err)
+	assert.Equal(t, "device", resp.GetDeviceCode())
+	assert.Equal(t, session, req.GetSession())
+}
+
+func TestWriteDeviceAuthorizeResponse(t *testing.T) {
+	f := &Fosite{}
+	rw := httptest.NewRecorder()
+
+	resp := NewDeviceResponse()
+	resp.SetDeviceCode("device")
+	resp.SetUserCode("BCDFGHJK")
+	resp.SetVerificationURI("https://www.example.com/device")
+	resp.SetExpiresIn(time.Minute * 10)
+	resp.SetInterval(time.Second * 5)
+	resp.AddHeader("X-Foo", "bar")
+
+	f.WriteDeviceAuthorizeResponse(rw, NewDeviceRequest(), resp)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "no-store", rw.Header().Get("Cache-Control"))
+	assert.Equal(t, "bar", rw.Header().Get("X-Foo"))
+	assert.JSONEq(t, `{"device_code":"device","user_code":"BCDFGHJK","verification_uri":"https://www.example.com/device","expires_in":600,"interval":5}`, rw.Body.String())
+}
@@ -0,0 +1,120 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"net/http"
+	"time"
+)
+
+// DeviceResponse is an implementation of DeviceResponder
+type DeviceResponse struct {
+	Header                  http.Header
+	DeviceCode              string
+	UserCode                string
+	VerificationURI         string
+	VerificationURIComplete string
+	ExpiresIn               time.Duration
+	Interval                time.Duration
+}
+
+func NewDeviceResponse() *DeviceResponse {
+	return &DeviceResponse{
+		Header: http.Header{},
+	}
+}
+
+func (d *DeviceResponse) GetDeviceCode() string {
+	return d.DeviceCode
+}
+
+func (d *DeviceResponse) SetDeviceCode(code string) {
+	d.DeviceCode = code
+}
+
+func (d *DeviceResponse) GetUserCode() string {
+	return d.UserCode
+}
+
+func (d *DeviceResponse) SetUserCode(code string) {
+	d.UserCode = code
+}
+
+func (d *DeviceResponse) GetVerificationURI() string {
+	return d.VerificationURI
+}
+
+func (d *DeviceResponse) SetVerificationURI(uri string) {
+	d.VerificationURI = uri
+}
+
+func (d *DeviceResponse) GetVerificationURIComplete() string {
+	return d.VerificationURIComplete
+}
+
+func (d *DeviceResponse) SetVerificationURIComplete(uri string) {
+	d.VerificationURIComplete = uri
+}
+
+func (d *DeviceResponse) GetExpiresIn() time.Duration {
+	return d.ExpiresIn
+}
+
+func (d *DeviceResponse) SetExpiresIn(expiresIn time.Duration) {
+	d.ExpiresIn = expiresIn
+}
+
+func (d *DeviceResponse) GetInterval() time.Duration {
+	return d.Interval
+}
+
+func (d *DeviceResponse) SetInterval(interval time.Duration) {
+	d.Interval = interval
+}
+
+func (d *DeviceResponse) GetHeader() http.Header {
+	return d.Header
+}
+
+func (d *DeviceResponse) AddHeader(key, value string) {
+	d.Header.Add(key, value)
+}
+
+// ToMap converts the response to a map as defined in https://tools.ietf.org/html/rfc8628#section-3.2
+func (d *DeviceResponse) ToMap() map[string]interface{} {
+	res := map[string]interface{}{
+		"device_code":      d.DeviceCode,
+		"user_code":        d.UserCode,
+		"verification_uri": d.VerificationURI,
+		"expires_in":       int64(d.ExpiresIn / time.Second),
+	}
+
+	if d.VerificationURIComplete != "" {
+		res["verification_uri_complete"] = d.VerificationURIComplete
+	}
+
+	if d.Interval > 0 {
+		res["interval"] = int64(d.Interval / time.Second)
+	}
+
+	return res
+}
@@ -0,0 +1,58 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+
+	"github.com/ory/x/errorsx"
+)
+
+// NewDeviceAuthorizeResponse iterates through all DeviceEndpointHandlers and returns the device
+// authorization response. The session is stored alongside the device and user code and can be
+// amended once the user verified the user code.
+func (f *Fosite) NewDeviceAuthorizeResponse(ctx context.Context, requester DeviceRequester, session Session) (DeviceResponder, error) {
+	ctx = f.withStoragePolicy(ctx)
+	ctx = ensureRequestID(ctx, nil)
+	response, err := f.newDeviceAuthorizeResponse(ctx, requester, session)
+	return response, attachRequestID(ctx, err)
+}
+
+func (f *Fosite) newDeviceAuthorizeResponse(ctx context.Context, requester DeviceRequester, session Session) (DeviceResponder, error) {
+	response := NewDeviceResponse()
+
+	ctx = context.WithValue(ctx, DeviceRequestContextKey, requester)
+	ctx = context.WithValue(ctx, DeviceResponseContextKey, response)
+
+	requester.SetSession(session)
+	for _, h := range f.DeviceEndpointHandlers {
+		if err := h.HandleDeviceEndpointRequest(ctx, requester, response); err != nil {
+			return nil, err
+		}
+	}
+
+	if response.GetDeviceCode() == "" || response.GetUserCode() == "" {
+		return nil, errorsx.WithStack(ErrServerError.WithHint("An internal server occurred while trying to complete the request.").WithDebug("Device code or user code not set by DeviceEndpointHandlers.").WithLocalizer(f.MessageCatalog, getLangFromRequester(requester)))
+	}
+
+	return response, nil
+}
@@ -0,0 +1,56 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// WriteDeviceAuthorizeError writes an error response as defined in https://tools.ietf.org/html/rfc6749#section-5.2
+func (f *Fosite) WriteDeviceAuthorizeError(rw http.ResponseWriter, requester DeviceRequester, err error) {
+	f.writeError(rw, DeviceAuthorizationEndpoint, requester, err)
+}
+
+// WriteDeviceAuthorizeResponse writes the device authorization response as defined in
+// https://tools.ietf.org/html/rfc8628#section-3.2
+func (f *Fosite) WriteDeviceAuthorizeResponse(rw http.ResponseWriter, requester DeviceRequester, responder DeviceResponder) {
+	// Set custom headers, e.g. "X-MySuperCoolCustomHeader" or "X-DONT-CACHE-ME"...
+	wh := rw.Header()
+	rh := responder.GetHeader()
+	for k := range rh {
+		wh.Set(k, rh.Get(k))
+	}
+
+	wh.Set("Cache-Control", "no-store")
+	wh.Set("Pragma", "no-cache")
+
+	js, err := json.Marshal(responder.ToMap())
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	wh.Set("Content-Type", "application/json;charset=UTF-8")
+	rw.WriteHeader(http.StatusOK)
+	_, _ = rw.Write(js)
+}
@@ -0,0 +1,312 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/ory/go-convenience/stringslice"
+)
+
+// DiscoveryConfiguration contains the authorization server metadata which can not be derived from the provider,
+// such as the issuer and the location of endpoints which are served outside of fosite's control.
+type DiscoveryConfiguration struct {
+	Issuer                             string
+	AuthorizationEndpoint              string
+	TokenEndpoint                      string
+	JWKSURI                            string
+	UserinfoEndpoint                   string
+	RegistrationEndpoint               string
+	RevocationEndpoint                 string
+	IntrospectionEndpoint              string
+	DeviceAuthorizationEndpoint        string
+	PushedAuthorizationRequestEndpoint string
+	BackchannelAuthenticationEndpoint  string
+	EndSessionEndpoint                 string
+	CheckSessionIframe                 string
+	ServiceDocumentation               string
+	GrantManagementEndpoint            string
+
+	ScopesSupported                  []string
+	ClaimsSupported                  []string
+	SubjectTypesSupported            []string
+	IDTokenSigningAlgValuesSupported []string
+	ACRValuesSupported               []string
+
+	// FrontchannelLogoutSupported and BackchannelLogoutSupported announce support for OpenID Connect front-channel
+	// and back-channel logout, which are carried out by the application. The session variants announce that the
+	// "sid" is sent as well.
+	FrontchannelLogoutSupported        bool
+	FrontchannelLogoutSessionSupported bool
+	BackchannelLogoutSupported         bool
+	BackchannelLogoutSessionSupported  bool
+}
+
+// DiscoveryMetadata is the authorization server metadata document as defined in
+// https://tools.ietf.org/html/rfc8414#section-2 and
+// https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderMetadata
+type DiscoveryMetadata struct {
+	Issuer                                     string   `json:"issuer"`
+	AuthorizationEndpoint                      string   `json:"authorization_endpoint,omitempty"`
+	TokenEndpoint                              string   `json:"token_endpoint,omitempty"`
+	JWKSURI                                    string   `json:"jwks_uri,omitempty"`
+	UserinfoEndpoint                           string   `json:"userinfo_endpoint,omitempty"`
+	RegistrationEndpoint                       string   `json:"registration_endpoint,omitempty"`
+	RevocationEndpoint                         string   `json:"revocation_endpoint,omitempty"`
+	IntrospectionEndpoint                      string   `json:"introspection_endpoint,omitempty"`
+	DeviceAuthorizationEndpoint                string   `json:"device_authorization_endpoint,omitempty"`
+	PushedAuthorizationRequestEndpoint         string   `json:"pushed_authorization_request_endpoint,omitempty"`
+	BackchannelAuthenticationEndpoint          string   `json:"backchannel_authentication_endpoint,omitempty"`
+	EndSessionEndpoint                         string   `json:"end_session_endpoint,omitempty"`
+	CheckSessionIframe                         string   `json:"check_session_iframe,omitempty"`
+	ServiceDocumentation                       string   `json:"service_documentation,omitempty"`
+	GrantManagementEndpoint                    string   `json:"grant_management_endpoint,omitempty"`
+	ScopesSupported                            []string `json:"scopes_supported,omitempty"`
+	ClaimsSupported                            []string `json:"claims_supported,omitempty"`
+	ResponseTypesSupported                     []string `json:"response_types_supported"`
+	ResponseModesSupported                     []string `json:"response_modes_supported,omitempty"`
+	GrantTypesSupported                        []string `json:"grant_types_supported,omitempty"`
+	SubjectTypesSupported                      []string `json:"subject_types_supported,omitempty"`
+	IDTokenSigningAlgValuesSupported           []string `json:"id_token_signing_alg_values_supported,omitempty"`
+	ACRValuesSupported                         []string `json:"acr_values_supported,omitempty"`
+	TokenEndpointAuthMethodsSupported          []string `json:"token_endpoint_auth_methods_supported,omitempty"`
+	TokenEndpointAuthSigningAlgValuesSupported []string `json:"token_endpoint_auth_signing_alg_values_supported,omitempty"`
+	CodeChallengeMethodsSupported              []string `json:"code_challenge_methods_supported,omitempty"`
+	AuthorizationSigningAlgValuesSupported     []string `json:"authorization_signing_alg_values_supported,omitempty"`
+	DPoPSigningAlgValuesSupported              []string `json:"dpop_signing_alg_values_supported,omitempty"`
+	BackchannelTokenDeliveryModesSupported     []string `json:"backchannel_token_delivery_modes_supported,omitempty"`
+	AuthorizationDetailsTypesSupported         []string `json:"authorization_details_types_supported,omitempty"`
+	GrantManagementActionsSupported            []string `json:"grant_management_actions_supported,omitempty"`
+	PromptValuesSupported                      []string `json:"prompt_values_supported,omitempty"`
+	RequestParameterSupported                  bool     `json:"request_parameter_supported"`
+	RequestURIParameterSupported               bool     `json:"request_uri_parameter_supported"`
+	ClaimsParameterSupported                   bool     `json:"claims_parameter_supported"`
+	RequireSignedRequestObject                 bool     `json:"require_signed_request_object,omitempty"`
+	RequirePushedAuthorizationRequests         bool     `json:"require_pushed_authorization_requests,omitempty"`
+	TLSClientCertificateBoundAccessTokens      bool     `json:"tls_client_certificate_bound_access_tokens,omitempty"`
+	FrontchannelLogoutSupported                bool     `json:"frontchannel_logout_supported,omitempty"`
+	FrontchannelLogoutSessionSupported         bool     `json:"frontchannel_logout_session_supported,omitempty"`
+	BackchannelLogoutSupported                 bool     `json:"backchannel_logout_supported,omitempty"`
+	BackchannelLogoutSessionSupported          bool     `json:"backchannel_logout_session_supported,omitempty"`
+	GrantManagementActionRequired              bool     `json:"grant_management_action_required,omitempty"`
+	AuthorizationResponseIssParameterSupported bool     `json:"authorization_response_iss_parameter_supported,omitempty"`
+}
+
+// DiscoveryMetadataProvider is implemented by handlers which contribute to the authorization server metadata, for
+// example by adding the grant types or response types they implement.
+type DiscoveryMetadataProvider interface {
+	// PopulateDiscoveryMetadata adds the capabilities of the handler to the metadata.
+	PopulateDiscoveryMetadata(ctx context.Context, metadata *DiscoveryMetadata)
+}
+
+// NewDiscoveryMetadata creates the authorization server metadata of the provider. Grant types, response types, and
+// client authentication methods are collected from all registered handlers which implement
+// DiscoveryMetadataProvider, the remaining values are taken from config. The issuer is taken from the
+// IssuerProvider if config does not set it.
+func (f *Fosite) NewDiscoveryMetadata(ctx context.Context, config DiscoveryConfiguration) *DiscoveryMetadata {
+	metadata := &DiscoveryMetadata{
+		Issuer:                             config.Issuer,
+		AuthorizationEndpoint:              config.AuthorizationEndpoint,
+		TokenEndpoint:                      config.TokenEndpoint,
+		JWKSURI:                            config.JWKSURI,
+		UserinfoEndpoint:                   config.UserinfoEndpoint,
+		RegistrationEndpoint:               config.RegistrationEndpoint,
+		RevocationEndpoint:                 config.RevocationEndpoint,
+		IntrospectionEndpoint:              config.IntrospectionEndpoint,
+		DeviceAuthorizationEndpoint:        config.DeviceAuthorizationEndpoint,
+		PushedAuthorizationRequestEndpoint: config.PushedAuthorizationRequestEndpoint,
+		BackchannelAuthenticationEndpoint:  config.BackchannelAuthenticationEndpoint,
+		EndSessionEndpoint:                 config.EndSessionEndpoint,
+		CheckSessionIframe:                 config.CheckSessionIframe,
+		ServiceDocumentation:               config.ServiceDocumentation,
+		GrantManagementEndpoint:            config.GrantManagementEndpoint,
+		ScopesSupported:                    config.ScopesSupported,
+		ClaimsSupported:                    config.ClaimsSupported,
+		SubjectTypesSupported:              config.SubjectTypesSupported,
+		IDTokenSigningAlgValuesSupported:   config.IDTokenSigningAlgValuesSupported,
+		ACRValuesSupported:                 config.ACRValuesSupported,
+		ResponseTypesSupported:             []string{},
+		ResponseModesSupported: []string{
+			string(ResponseModeQuery),
+			string(ResponseModeFragment),
+			string(ResponseModeFormPost),
+		},
+		RequestParameterSupported:             f.EnableJWTSecuredAuthorizationRequests,
+		RequestURIParameterSupported:          f.EnableJWTSecuredAuthorizationRequests,
+		ClaimsParameterSupported:              true,
+		RequireSignedRequestObject:            f.RequireSignedRequestObject,
+		RequirePushedAuthorizationRequests:    f.EnforcePushedAuthorize,
+		TLSClientCertificateBoundAccessTokens: f.TLSClientCertificateBoundAccessTokens,
+		FrontchannelLogoutSupported:           config.FrontchannelLogoutSupported,
+		FrontchannelLogoutSessionSupported:    config.FrontchannelLogoutSessionSupported,
+		BackchannelLogoutSupported:            config.BackchannelLogoutSupported,
+		BackchannelLogoutSessionSupported:     config.BackchannelLogoutSessionSupported,
+	}
+
+	if metadata.Issuer == "" && f.IssuerProvider != nil {
+		// Providers deriving the issuer from the request need the discovery request under RequestContextKey.
+		if issuer, err := f.IssuerProvider.GetIssuer(ctx); err == nil {
+			metadata.Issuer = issuer
+		}
+	}
+
+	if metadata.TokenEndpoint == "" {
+		metadata.TokenEndpoint = f.TokenURL
+	}
+
+	if f.JARMStrategy != nil {
+		metadata.ResponseModesSupported = append(metadata.ResponseModesSupported,
+			string(ResponseModeJWT),
+			string(ResponseModeQueryJWT),
+			string(ResponseModeFragmentJWT),
+			string(ResponseModeFormPostJWT),
+		)
+	}
+
+	if f.ResponseModeHandlerExtension != nil {
+		for _, mode := range f.ResponseModeHandlerExtension.ResponseModes() {
+			if mode != ResponseModeDefault {
+				metadata.ResponseModesSupported = append(metadata.ResponseModesSupported, string(mode))
+			}
+		}
+	}
+
+	if f.DPoPStrategy != nil {
+		for _, alg := range dpopSigningAlgorithms {
+			metadata.DPoPSigningAlgValuesSupported = append(metadata.DPoPSigningAlgValuesSupported, string(alg))
+		}
+	}
+
+	for detailType := range f.AuthorizationDetailsTypes {
+		metadata.AuthorizationDetailsTypesSupported = append(metadata.AuthorizationDetailsTypesSupported, detailType)
+	}
+	sort.Strings(metadata.AuthorizationDetailsTypesSupported)
+
+	// https://tools.ietf.org/html/rfc9207#section-3
+	metadata.AuthorizationResponseIssParameterSupported = !f.OmitAuthorizationResponseIssuer && (f.IssuerProvider != nil || f.RequireAuthorizationResponseIssuer)
+
+	if f.GrantStorage != nil {
+		metadata.GrantManagementActionsSupported = []string{GrantManagementActionCreate, GrantManagementActionMerge, GrantManagementActionReplace}
+		metadata.GrantManagementActionRequired = f.GrantManagementActionRequired
+	}
+
+	clientAuthenticationHandlers := f.ClientAuthenticationHandlers
+	if len(clientAuthenticationHandlers) == 0 {
+		clientAuthenticationHandlers = DefaultClientAuthenticationHandlers()
+	}
+
+	var handlers []interface{}
+	for _, h := range f.AuthorizeEndpointHandlers {
+		handlers = append(handlers, h)
+	}
+	for _, h := range f.TokenEndpointHandlers {
+		handlers = append(handlers, h)
+	}
+	for _, h := range f.TokenIntrospectionHandlers {
+		handlers = append(handlers, h)
+	}
+	for _, h := range f.RevocationHandlers {
+		handlers = append(handlers, h)
+	}
+	for _, h := range f.DeviceEndpointHandlers {
+		handlers = append(handlers, h)
+	}
+	for _, h := range f.PushedAuthorizeEndpointHandlers {
+		handlers = append(handlers, h)
+	}
+	for _, h := range f.BackchannelAuthenticationEndpointHandlers {
+		handlers = append(handlers, h)
+	}
+	for _, h := range clientAuthenticationHandlers {
+		handlers = append(handlers, h)
+	}
+
+	for _, h := range handlers {
+		if p, ok := h.(DiscoveryMetadataProvider); ok {
+			p.PopulateDiscoveryMetadata(ctx, metadata)
+		}
+	}
+
+	if f.ResponseTypeRegistry != nil {
+		metadata.ResponseTypesSupported = f.ResponseTypeRegistry.ResponseTypes()
+	}
+
+	if f.RejectFrontChannelAccessTokens {
+		responseTypes := metadata.ResponseTypesSupported[:0]
+		for _, responseType := range metadata.ResponseTypesSupported {
+			if !Arguments(strings.Split(responseType, " ")).Has("token") {
+				responseTypes = append(responseTypes, responseType)
+			}
+		}
+		metadata.ResponseTypesSupported = responseTypes
+	}
+
+	if len(metadata.SubjectTypesSupported) == 0 && len(f.SubjectIdentifierAlgorithms) > 0 {
+		metadata.SubjectTypesSupported = f.SubjectIdentifierAlgorithms.SubjectTypes()
+	}
+
+	// OpenID Connect requires these values to be published.
+	if stringslice.Has(metadata.ScopesSupported, "openid") {
+		if len(metadata.SubjectTypesSupported) == 0 {
+			metadata.SubjectTypesSupported = []string{"public"}
+		}
+		if len(metadata.IDTokenSigningAlgValuesSupported) == 0 {
+			metadata.IDTokenSigningAlgValuesSupported = []string{"RS256"}
+		}
+	}
+
+	for _, values := range []*[]string{
+		&metadata.ScopesSupported,
+		&metadata.ResponseTypesSupported,
+		&metadata.ResponseModesSupported,
+		&metadata.GrantTypesSupported,
+		&metadata.TokenEndpointAuthMethodsSupported,
+		&metadata.TokenEndpointAuthSigningAlgValuesSupported,
+		&metadata.CodeChallengeMethodsSupported,
+		&metadata.BackchannelTokenDeliveryModesSupported,
+		&metadata.PromptValuesSupported,
+	} {
+		if len(*values) > 0 {
+			*values = stringslice.Unique(*values)
+		}
+	}
+
+	return metadata
+}
+
+// WriteDiscoveryResponse writes the authorization server metadata. The same document may be served at
+// /.well-known/oauth-authorization-server and /.well-known/openid-configuration.
+func (f *Fosite) WriteDiscoveryResponse(ctx context.Context, rw http.ResponseWriter, metadata *DiscoveryMetadata) {
+	js, err := json.Marshal(metadata)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	rw.WriteHeader(http.StatusOK)
+	_, _ = rw.Write(js)
+}
@@ -0,0 +1,95 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/internal"
+	"github.com/ory/fosite/storage"
+)
+
+func TestNewDiscoveryMetadata(t *testing.T) {
+	f := compose.ComposeAllEnabled(&compose.Config{TokenURL: "https://auth.example.com/token"}, storage.NewExampleStore(), []byte("some-secret-thats-random-some-secret-thats-random-"), internal.MustRSAKey()).(*Fosite)
+
+	metadata := f.NewDiscoveryMetadata(context.Background(), DiscoveryConfiguration{
+		Issuer:                "https://auth.example.com",
+		AuthorizationEndpoint: "https://auth.example.com/auth",
+		JWKSURI:               "https://auth.example.com/.well-known/jwks.json",
+		ScopesSupported:       []string{"offline", "openid"},
+	})
+
+	assert.Equal(t, "https://auth.example.com", metadata.Issuer)
+	assert.Equal(t, "https://auth.example.com/token", metadata.TokenEndpoint)
+	assert.Equal(t, []string{"offline", "openid"}, metadata.ScopesSupported)
+	assert.Subset(t, metadata.ResponseTypesSupported, []string{"code", "token", "id_token", "id_token token", "code id_token", "code token", "code id_token token"})
+	assert.Subset(t, metadata.GrantTypesSupported, []string{
+		"authorization_code",
+		"implicit",
+		"refresh_token",
+		"client_credentials",
+		"password",
+		"urn:ietf:params:oauth:grant-type:jwt-bearer",
+		"urn:ietf:params:oauth:grant-type:device_code",
+		"urn:ietf:params:oauth:grant-type:token-exchange",
+		"urn:openid:params:grant-type:ciba",
+	})
+	assert.Equal(t, []string{"query", "fragment", "form_post"}, metadata.ResponseModesSupported)
+	assert.Equal(t, []string{"private_key_jwt", "client_secret_jwt", "client_secret_basic", "client_secret_post", "tls_client_auth", "self_signed_tls_client_auth", "none"}, metadata.TokenEndpointAuthMethodsSupported)
+	assert.Equal(t, []string{"S256"}, metadata.CodeChallengeMethodsSupported)
+	assert.Equal(t, []string{"public"}, metadata.SubjectTypesSupported)
+	assert.Equal(t, []string{"RS256"}, metadata.IDTokenSigningAlgValuesSupported)
+	assert.True(t, metadata.RequestParameterSupported)
+	assert.Empty(t, metadata.DPoPSigningAlgValuesSupported)
+
+	rw := httptest.NewRecorder()
+	f.WriteDiscoveryResponse(context.Background(), rw, metadata)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "application/json;charset=UTF-8", rw.Header().Get("Content-Type"))
+
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &decoded))
+	assert.Equal(t, "https://auth.example.com", decoded["issuer"])
+	assert.Equal(t, "https://auth.example.com/auth", decoded["authorization_endpoint"])
+	assert.NotContains(t, decoded, "userinfo_endpoint")
+}
+
+func TestNewDiscoveryMetadataWithoutHandlers(t *testing.T) {
+	f := &Fosite{TokenURL: "https://auth.example.com/token", JARMStrategy: &DefaultJARMStrategy{}, EnforcePushedAuthorize: true}
+
+	metadata := f.NewDiscoveryMetadata(context.Background(), DiscoveryConfiguration{Issuer: "https://auth.example.com"})
+	assert.Empty(t, metadata.ResponseTypesSupported)
+	assert.Empty(t, metadata.GrantTypesSupported)
+	assert.Empty(t, metadata.SubjectTypesSupported)
+	assert.Contains(t, metadata.ResponseModesSupported, "query.jwt")
+	assert.True(t, metadata.RequirePushedAuthorizationRequests)
+	assert.False(t, metadata.RequestParameterSupported)
+}
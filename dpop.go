@@ -0,0 +1,399 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ory/x/errorsx"
+	"github.com/pkg/errors"
+	jose "gopkg.in/square/go-jose.v2"
+	josejwt "gopkg.in/square/go-jose.v2/jwt"
+)
+
+const (
+	// DPoPHeaderName is the name of the HTTP header carrying the DPoP proof.
+	DPoPHeaderName = "DPoP"
+
+	// DPoPNonceHeaderName is the name of the HTTP header used to provide a DPoP nonce to the client.
+	DPoPNonceHeaderName = "DPoP-Nonce"
+
+	// DPoPTokenType is the token type of DPoP-bound access tokens.
+	DPoPTokenType = "DPoP"
+
+	// DPoPConfirmationMethod is the confirmation method of the "cnf" claim carrying the JWK SHA-256 thumbprint of the
+	// DPoP proof key.
+	DPoPConfirmationMethod = "jkt"
+
+	// DPoPProofLifespan is the default time frame in which a DPoP proof is accepted.
+	DPoPProofLifespan = time.Minute
+
+	dpopProofJWTType = "dpop+jwt"
+)
+
+var dpopSigningAlgorithms = []jose.SignatureAlgorithm{
+	jose.RS256, jose.RS384, jose.RS512,
+	jose.PS256, jose.PS384, jose.PS512,
+	jose.ES256, jose.ES384, jose.ES512,
+	jose.EdDSA,
+}
+
+// DPoPStrategy customizes how DPoP nonces are issued and validated and how replayed DPoP proofs are detected,
+// see https://tools.ietf.org/html/rfc9449
+type DPoPStrategy interface {
+	// NewNonce returns a nonce which the client must include in its next DPoP proof. An empty string indicates that
+	// no nonce is required.
+	NewNonce(ctx context.Context) (string, error)
+
+	// ValidateNonce validates the "nonce" claim of a DPoP proof. It must return ErrUseDPoPNonce if a nonce is required
+	// but missing, unknown, or expired.
+	ValidateNonce(ctx context.Context, nonce string) error
+
+	// SetProofUsed marks the DPoP proof identified by jti as used until expiresAt. It must return ErrJTIKnown if the
+	// proof has been used before.
+	SetProofUsed(ctx context.Context, jti string, expiresAt time.Time) error
+}
+
+// DPoPClient may be implemented by clients which only accept DPoP-bound access tokens.
+type DPoPClient interface {
+	// GetDPoPBoundAccessTokens returns true if the client must always present a DPoP proof at the token endpoint.
+	GetDPoPBoundAccessTokens() bool
+}
+
+// DPoPProof is a validated DPoP proof.
+type DPoPProof struct {
+	// JTI is the unique identifier of the proof.
+	JTI string
+
+	// HTTPMethod is the HTTP method of the request the proof was created for.
+	HTTPMethod string
+
+	// HTTPURI is the HTTP URI of the request the proof was created for.
+	HTTPURI string
+
+	// IssuedAt is the creation time of the proof.
+	IssuedAt time.Time
+
+	// Nonce is the nonce provided by the server, if any.
+	Nonce string
+
+	// JSONWebKey is the public key the proof was signed with.
+	JSONWebKey *jose.JSONWebKey
+
+	// Thumbprint is the base64url encoded JWK SHA-256 thumbprint of JSONWebKey.
+	Thumbprint string
+}
+
+type dpopProofClaims struct {
+	JTI             string               `json:"jti"`
+	HTTPMethod      string               `json:"htm"`
+	HTTPURI         string               `json:"htu"`
+	IssuedAt        *josejwt.NumericDate `json:"iat"`
+	Nonce           string               `json:"nonce"`
+	AccessTokenHash string               `json:"ath"`
+}
+
+// ValidateDPoPProof validates the DPoP proof of the given request as specified in
+// https://tools.ietf.org/html/rfc9449#section-4.3. If accessToken is not empty, the proof must contain the hash of
+// the access token. The returned error is nil and the proof is nil if the request does not contain a DPoP proof.
+func (f *Fosite) ValidateDPoPProof(ctx context.Context, r *http.Request, requestURI string, accessToken string) (*DPoPProof, error) {
+	values := r.Header.Values(DPoPHeaderName)
+	if len(values) == 0 {
+		return nil, nil
+	} else if len(values) > 1 {
+		return nil, errorsx.WithStack(ErrInvalidDPoPProof.WithHintf("The request must contain exactly one '%s' header.", DPoPHeaderName))
+	}
+
+	token, err := josejwt.ParseSigned(values[0])
+	if err != nil {
+		return nil, errorsx.WithStack(ErrInvalidDPoPProof.WithHint("Unable to parse the DPoP proof.").WithWrap(err).WithDebug(err.Error()))
+	} else if len(token.Headers) != 1 {
+		return nil, errorsx.WithStack(ErrInvalidDPoPProof.WithHint("The DPoP proof must contain exactly one signature."))
+	}
+
+	header := token.Headers[0]
+	if typ, _ := header.ExtraHeaders[jose.HeaderType].(string); typ != dpopProofJWTType {
+		return nil, errorsx.WithStack(ErrInvalidDPoPProof.WithHintf("The DPoP proof must have the 'typ' header '%s'.", dpopProofJWTType))
+	}
+
+	var supported bool
+	for _, alg := range dpopSigningAlgorithms {
+		if header.Algorithm == string(alg) {
+			supported = true
+			break
+		}
+	}
+	if !supported {
+		return nil, errorsx.WithStack(ErrInvalidDPoPProof.WithHintf("The DPoP proof is signed with the unsupported algorithm '%s'.", header.Algorithm))
+	}
+
+	if header.JSONWebKey == nil || !header.JSONWebKey.Valid() || !header.JSONWebKey.IsPublic() {
+		return nil, errorsx.WithStack(ErrInvalidDPoPProof.WithHint("The 'jwk' header of the DPoP proof must contain a valid public key."))
+	}
+
+	var claims dpopProofClaims
+	if err := token.Claims(header.JSONWebKey.Key, &claims); err != nil {
+		return nil, errorsx.WithStack(ErrInvalidDPoPProof.WithHint("Unable to verify the signature of the DPoP proof.").WithWrap(err).WithDebug(err.Error()))
+	}
+
+	if claims.JTI == "" {
+		return nil, errorsx.WithStack(ErrInvalidDPoPProof.WithHint("The DPoP proof must contain the 'jti' claim."))
+	} else if claims.IssuedAt == nil {
+		return nil, errorsx.WithStack(ErrInvalidDPoPProof.WithHint("The DPoP proof must contain the 'iat' claim."))
+	} else if claims.HTTPMethod != r.Method {
+		return nil, errorsx.WithStack(ErrInvalidDPoPProof.WithHintf("The 'htm' claim of the DPoP proof must be '%s'.", r.Method))
+	} else if !dpopURIMatches(claims.HTTPURI, requestURI) {
+		return nil, errorsx.WithStack(ErrInvalidDPoPProof.WithHint("The 'htu' claim of the DPoP proof does not match the request URI.").WithDebugf("Expected '%s' but got '%s'.", requestURI, claims.HTTPURI))
+	}
+
+	lifespan := f.GetDPoPProofLifespan()
+	issuedAt := claims.IssuedAt.Time().UTC()
+	if now := time.Now().UTC(); issuedAt.Before(now.Add(-lifespan)) || issuedAt.After(now.Add(lifespan)) {
+		return nil, errorsx.WithStack(ErrInvalidDPoPProof.WithHint("The DPoP proof is either expired or issued in the future."))
+	}
+
+	if accessToken != "" {
+		hash := sha256.Sum256([]byte(accessToken))
+		if claims.AccessTokenHash != base64.RawURLEncoding.EncodeToString(hash[:]) {
+			return nil, errorsx.WithStack(ErrInvalidDPoPProof.WithHint("The 'ath' claim of the DPoP proof does not match the access token."))
+		}
+	}
+
+	strategy := f.GetDPoPStrategy()
+	if err := strategy.ValidateNonce(ctx, claims.Nonce); err != nil {
+		return nil, err
+	}
+
+	if err := strategy.SetProofUsed(ctx, claims.JTI, issuedAt.Add(lifespan)); errors.Is(err, ErrJTIKnown) {
+		return nil, errorsx.WithStack(ErrInvalidDPoPProof.WithHint("The DPoP proof has been used before.").WithWrap(err).WithDebug(err.Error()))
+	} else if err != nil {
+		return nil, errorsx.WithStack(ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	thumbprint, err := header.JSONWebKey.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return nil, errorsx.WithStack(ErrInvalidDPoPProof.WithHint("Unable to compute the thumbprint of the DPoP proof key.").WithWrap(err).WithDebug(err.Error()))
+	}
+
+	return &DPoPProof{
+		JTI:        claims.JTI,
+		HTTPMethod: claims.HTTPMethod,
+		HTTPURI:    claims.HTTPURI,
+		IssuedAt:   issuedAt,
+		Nonce:      claims.Nonce,
+		JSONWebKey: header.JSONWebKey,
+		Thumbprint: base64.RawURLEncoding.EncodeToString(thumbprint),
+	}, nil
+}
+
+// handleDPoPAccessRequest validates the DPoP proof sent to the token endpoint and binds the requester's session to
+// the proof key.
+func (f *Fosite) handleDPoPAccessRequest(ctx context.Context, r *http.Request, requester AccessRequester) error {
+	if f.DPoPStrategy == nil {
+		return nil
+	}
+
+	requestURI := f.TokenURL
+	if requestURI == "" {
+		requestURI = dpopRequestURI(r)
+	}
+
+	proof, err := f.ValidateDPoPProof(ctx, r, requestURI, "")
+	if err != nil {
+		return err
+	}
+
+	bound := GetConfirmation(requester.GetSession(), DPoPConfirmationMethod)
+	if proof == nil {
+		if bound != "" {
+			return errorsx.WithStack(ErrInvalidDPoPProof.WithHintf("The grant is DPoP-bound, but the '%s' header is missing.", DPoPHeaderName))
+		} else if c, ok := requester.GetClient().(DPoPClient); ok && c.GetDPoPBoundAccessTokens() {
+			return errorsx.WithStack(ErrInvalidDPoPProof.WithHintf("The OAuth 2.0 Client requires DPoP-bound access tokens, but the '%s' header is missing.", DPoPHeaderName))
+		}
+		return nil
+	}
+
+	if bound != "" && bound != proof.Thumbprint {
+		return errorsx.WithStack(ErrInvalidDPoPProof.WithHint("The DPoP proof was signed with a different key than the one the grant is bound to."))
+	}
+
+	if !SetConfirmation(requester.GetSession(), DPoPConfirmationMethod, proof.Thumbprint) {
+		return errorsx.WithStack(ErrServerError.WithHint("Unable to bind the access token to the DPoP proof key.").WithDebug("The session does not support extra claims."))
+	}
+	return nil
+}
+
+// verifyDPoPBinding verifies that the DPoP proof of the protected resource request stored in the context under
+// ResourceRequestContextKey matches the key the access token is bound to. DPoP-bound access tokens are rejected if
+// the protected resource request is not available.
+func (f *Fosite) verifyDPoPBinding(ctx context.Context, token string, requester AccessRequester) error {
+	bound := GetConfirmation(requester.GetSession(), DPoPConfirmationMethod)
+	if bound == "" {
+		return nil
+	} else if f.DPoPStrategy == nil {
+		return errorsx.WithStack(ErrRequestUnauthorized.WithHint("The access token is DPoP-bound, but DPoP is not enabled."))
+	}
+
+	r, ok := ctx.Value(ResourceRequestContextKey).(*http.Request)
+	if !ok {
+		return errorsx.WithStack(ErrRequestUnauthorized.WithHint("The access token is DPoP-bound, but the protected resource request is not available to verify the DPoP proof."))
+	}
+
+	proof, err := f.ValidateDPoPProof(ctx, r, dpopRequestURI(r), token)
+	if err != nil {
+		return err
+	} else if proof == nil {
+		return errorsx.WithStack(ErrRequestUnauthorized.WithHintf("The access token is DPoP-bound, but the '%s' header is missing.", DPoPHeaderName))
+	} else if proof.Thumbprint != bound {
+		return errorsx.WithStack(ErrRequestUnauthorized.WithHint("The DPoP proof was signed with a different key than the one the access token is bound to."))
+	}
+	return nil
+}
+
+// dpopRequestURI reconstructs the absolute URI of the request without query and fragment.
+func dpopRequestURI(r *http.Request) string {
+	u := *r.URL
+	if u.Host == "" {
+		u.Host = r.Host
+	}
+	if u.Scheme == "" {
+		u.Scheme = "http"
+		if r.TLS != nil {
+			u.Scheme = "https"
+		}
+	}
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String()
+}
+
+// dpopURIMatches compares two URIs ignoring their query and fragment components.
+func dpopURIMatches(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+
+	normalize := func(u *url.URL) string {
+		path := u.EscapedPath()
+		if path == "" {
+			path = "/"
+		}
+		return strings.ToLower(u.Scheme) + "://" + strings.ToLower(u.Host) + path
+	}
+	return normalize(ua) == normalize(ub)
+}
+
+// DefaultDPoPStrategy keeps nonces and the identifiers of used DPoP proofs in memory. It is not suited for
+// deployments consisting of multiple instances.
+type DefaultDPoPStrategy struct {
+	// RequireNonce, if set to true, requires all DPoP proofs to contain a nonce issued by NewNonce.
+	RequireNonce bool
+
+	// NonceLifespan defines how long a nonce is valid. Defaults to five minutes.
+	NonceLifespan time.Duration
+
+	nonces      map[string]time.Time
+	usedProofs  map[string]time.Time
+	noncesMutex sync.Mutex
+	proofsMutex sync.Mutex
+}
+
+// NewNonce returns a random nonce if RequireNonce is set.
+func (s *DefaultDPoPStrategy) NewNonce(ctx context.Context) (string, error) {
+	if !s.RequireNonce {
+		return "", nil
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", errorsx.WithStack(err)
+	}
+	nonce := base64.RawURLEncoding.EncodeToString(b)
+
+	lifespan := s.NonceLifespan
+	if lifespan == 0 {
+		lifespan = time.Minute * 5
+	}
+
+	s.noncesMutex.Lock()
+	defer s.noncesMutex.Unlock()
+	if s.nonces == nil {
+		s.nonces = map[string]time.Time{}
+	}
+	removeExpired(s.nonces)
+	s.nonces[nonce] = time.Now().UTC().Add(lifespan)
+	return nonce, nil
+}
+
+// ValidateNonce checks that the nonce has been issued by NewNonce and has not expired yet.
+func (s *DefaultDPoPStrategy) ValidateNonce(ctx context.Context, nonce string) error {
+	if !s.RequireNonce {
+		return nil
+	} else if nonce == "" {
+		return errorsx.WithStack(ErrUseDPoPNonce.WithHint("The DPoP proof must contain a nonce."))
+	}
+
+	s.noncesMutex.Lock()
+	defer s.noncesMutex.Unlock()
+	if expiresAt, ok := s.nonces[nonce]; !ok || expiresAt.Before(time.Now().UTC()) {
+		return errorsx.WithStack(ErrUseDPoPNonce.WithHint("The nonce of the DPoP proof is unknown or expired."))
+	}
+	return nil
+}
+
+// SetProofUsed remembers the jti until expiresAt.
+func (s *DefaultDPoPStrategy) SetProofUsed(ctx context.Context, jti string, expiresAt time.Time) error {
+	s.proofsMutex.Lock()
+	defer s.proofsMutex.Unlock()
+	if s.usedProofs == nil {
+		s.usedProofs = map[string]time.Time{}
+	}
+	removeExpired(s.usedProofs)
+	if _, ok := s.usedProofs[jti]; ok {
+		return errorsx.WithStack(ErrJTIKnown)
+	}
+	s.usedProofs[jti] = expiresAt
+	return nil
+}
+
+func removeExpired(m map[string]time.Time) {
+	now := time.Now().UTC()
+	for k, expiresAt := range m {
+		if expiresAt.Before(now) {
+			delete(m, k)
+		}
+	}
+}
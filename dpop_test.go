@@ -0,0 +1,280 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	jose "gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	. "github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/storage"
+)
+
+type dpopProofClaims struct {
+	JTI   string           `json:"jti,omitempty"`
+	HTM   string           `json:"htm,omitempty"`
+	HTU   string           `json:"htu,omitempty"`
+	IAT   *jwt.NumericDate `json:"iat,omitempty"`
+	Nonce string           `json:"nonce,omitempty"`
+	ATH   string           `json:"ath,omitempty"`
+}
+
+func newDPoPProof(t *testing.T, key *ecdsa.PrivateKey, typ string, claims dpopProofClaims) string {
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.ES256, Key: key},
+		(&jose.SignerOptions{EmbedJWK: true}).WithType(jose.ContentType(typ)),
+	)
+	require.NoError(t, err)
+
+	proof, err := jwt.Signed(signer).Claims(claims).CompactSerialize()
+	require.NoError(t, err)
+	return proof
+}
+
+func accessTokenHash(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+func TestValidateDPoPProof(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	valid := func() dpopProofClaims {
+		return dpopProofClaims{
+			JTI: uuid.New(),
+			HTM: "POST",
+			HTU: "https://www.ory.sh/token",
+			IAT: jwt.NewNumericDate(time.Now()),
+		}
+	}
+
+	for k, c := range []struct {
+		d           string
+		typ         string
+		claims      func(dpopProofClaims) dpopProofClaims
+		strategy    DPoPStrategy
+		accessToken string
+		replay      bool
+		expectErr   error
+	}{
+		{d: "passes", claims: func(c dpopProofClaims) dpopProofClaims { return c }},
+		{d: "passes and ignores the query", claims: func(c dpopProofClaims) dpopProofClaims { c.HTU += "?foo=bar"; return c }},
+		{d: "fails with the wrong type", typ: "JWT", claims: func(c dpopProofClaims) dpopProofClaims { return c }, expectErr: ErrInvalidDPoPProof},
+		{d: "fails without jti", claims: func(c dpopProofClaims) dpopProofClaims { c.JTI = ""; return c }, expectErr: ErrInvalidDPoPProof},
+		{d: "fails with the wrong method", claims: func(c dpopProofClaims) dpopProofClaims { c.HTM = "GET"; return c }, expectErr: ErrInvalidDPoPProof},
+		{d: "fails with the wrong uri", claims: func(c dpopProofClaims) dpopProofClaims { c.HTU = "https://www.ory.sh/auth"; return c }, expectErr: ErrInvalidDPoPProof},
+		{d: "fails with an expired proof", claims: func(c dpopProofClaims) dpopProofClaims {
+			c.IAT = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+			return c
+		}, expectErr: ErrInvalidDPoPProof},
+		{d: "fails when replayed", claims: func(c dpopProofClaims) dpopProofClaims { return c }, replay: true, expectErr: ErrInvalidDPoPProof},
+		{d: "fails without access token hash", claims: func(c dpopProofClaims) dpopProofClaims { return c }, accessToken: "foo", expectErr: ErrInvalidDPoPProof},
+		{d: "passes with access token hash", claims: func(c dpopProofClaims) dpopProofClaims { c.ATH = accessTokenHash("foo"); return c }, accessToken: "foo"},
+		{d: "fails without nonce", claims: func(c dpopProofClaims) dpopProofClaims { return c }, strategy: &DefaultDPoPStrategy{RequireNonce: true}, expectErr: ErrUseDPoPNonce},
+		{d: "fails with unknown nonce", claims: func(c dpopProofClaims) dpopProofClaims { c.Nonce = "foo"; return c }, strategy: &DefaultDPoPStrategy{RequireNonce: true}, expectErr: ErrUseDPoPNonce},
+	} {
+		t.Run(c.d, func(t *testing.T) {
+			typ := c.typ
+			if typ == "" {
+				typ = "dpop+jwt"
+			}
+			strategy := c.strategy
+			if strategy == nil {
+				strategy = &DefaultDPoPStrategy{}
+			}
+			f := &Fosite{DPoPStrategy: strategy}
+
+			proof := newDPoPProof(t, key, typ, c.claims(valid()))
+			r := httptest.NewRequest("POST", "https://www.ory.sh/token", nil)
+			r.Header.Set(DPoPHeaderName, proof)
+
+			if c.replay {
+				_, err := f.ValidateDPoPProof(context.Background(), r, "https://www.ory.sh/token", c.accessToken)
+				require.NoError(t, err)
+			}
+
+			result, err := f.ValidateDPoPProof(context.Background(), r, "https://www.ory.sh/token", c.accessToken)
+			if c.expectErr != nil {
+				require.ErrorIs(t, err, c.expectErr, "%d", k)
+				return
+			}
+			require.NoError(t, err, "%d", k)
+			assert.NotEmpty(t, result.Thumbprint)
+		})
+	}
+
+	t.Run("passes with issued nonce", func(t *testing.T) {
+		strategy := &DefaultDPoPStrategy{RequireNonce: true}
+		nonce, err := strategy.NewNonce(context.Background())
+		require.NoError(t, err)
+
+		claims := valid()
+		claims.Nonce = nonce
+		r := httptest.NewRequest("POST", "https://www.ory.sh/token", nil)
+		r.Header.Set(DPoPHeaderName, newDPoPProof(t, key, "dpop+jwt", claims))
+
+		_, err = (&Fosite{DPoPStrategy: strategy}).ValidateDPoPProof(context.Background(), r, "https://www.ory.sh/token", "")
+		require.NoError(t, err)
+	})
+
+	t.Run("returns nil without proof", func(t *testing.T) {
+		proof, err := (&Fosite{}).ValidateDPoPProof(context.Background(), httptest.NewRequest("POST", "https://www.ory.sh/token", nil), "https://www.ory.sh/token", "")
+		require.NoError(t, err)
+		assert.Nil(t, proof)
+	})
+}
+
+func TestDPoPBoundAccessToken(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	f := compose.ComposeAllEnabled(&compose.Config{
+		TokenURL:     "https://www.ory.sh/token",
+		DPoPStrategy: &DefaultDPoPStrategy{},
+	}, storage.NewExampleStore(), []byte("some-secret-thats-random-some-secret-thats-random-"), nil).(*Fosite)
+
+	issue := func(t *testing.T, proof string) (AccessResponder, error) {
+		r, err := http.NewRequest("POST", "https://www.ory.sh/token", strings.NewReader(url.Values{"grant_type": {"client_credentials"}}.Encode()))
+		require.NoError(t, err)
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.SetBasicAuth("my-client", "foobar")
+		if proof != "" {
+			r.Header.Set(DPoPHeaderName, proof)
+		}
+
+		ar, err := f.NewAccessRequest(context.Background(), r, new(DefaultSession))
+		if err != nil {
+			return nil, err
+		}
+		return f.NewAccessResponse(context.Background(), ar)
+	}
+	resourceRequest := func(token string, proofKey *ecdsa.PrivateKey) *http.Request {
+		r := httptest.NewRequest("GET", "https://www.ory.sh/api/photos?page=1", nil)
+		r.Header.Set("Authorization", "DPoP "+token)
+		if proofKey != nil {
+			r.Header.Set(DPoPHeaderName, newDPoPProof(t, proofKey, "dpop+jwt", dpopProofClaims{
+				JTI: uuid.New(),
+				HTM: "GET",
+				HTU: "https://www.ory.sh/api/photos",
+				IAT: jwt.NewNumericDate(time.Now()),
+				ATH: accessTokenHash(token),
+			}))
+		}
+		return r
+	}
+
+	t.Run("case=issues bearer tokens without proof", func(t *testing.T) {
+		resp, err := issue(t, "")
+		require.NoError(t, err)
+		assert.Equal(t, "bearer", resp.GetTokenType())
+	})
+
+	t.Run("case=fails with invalid proof", func(t *testing.T) {
+		_, err := issue(t, newDPoPProof(t, key, "dpop+jwt", dpopProofClaims{JTI: uuid.New(), HTM: "POST", HTU: "https://www.ory.sh/auth", IAT: jwt.NewNumericDate(time.Now())}))
+		require.ErrorIs(t, err, ErrInvalidDPoPProof)
+	})
+
+	t.Run("case=requires a proof if sender-constrained access tokens are required", func(t *testing.T) {
+		f.RequireSenderConstrainedAccessTokens = true
+		defer func() { f.RequireSenderConstrainedAccessTokens = false }()
+
+		_, err := issue(t, "")
+		require.ErrorIs(t, err, ErrInvalidRequest)
+
+		_, err = issue(t, newDPoPProof(t, key, "dpop+jwt", dpopProofClaims{JTI: uuid.New(), HTM: "POST", HTU: "https://www.ory.sh/token", IAT: jwt.NewNumericDate(time.Now())}))
+		require.NoError(t, err)
+	})
+
+	resp, err := issue(t, newDPoPProof(t, key, "dpop+jwt", dpopProofClaims{JTI: uuid.New(), HTM: "POST", HTU: "https://www.ory.sh/token", IAT: jwt.NewNumericDate(time.Now())}))
+	require.NoError(t, err)
+	assert.Equal(t, DPoPTokenType, resp.GetTokenType())
+	token := resp.GetAccessToken()
+
+	t.Run("case=introspection endpoint exposes the confirmation", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "https://www.ory.sh/introspect", strings.NewReader(url.Values{"token": {token}}.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.SetBasicAuth("my-client", "foobar")
+
+		resp, err := f.NewIntrospectionRequest(context.Background(), r, new(DefaultSession))
+		require.NoError(t, err)
+		assert.True(t, resp.IsActive())
+		assert.NotEmpty(t, GetConfirmation(resp.GetAccessRequester().GetSession(), DPoPConfirmationMethod))
+	})
+
+	t.Run("case=fails without the resource request", func(t *testing.T) {
+		_, _, err := f.IntrospectToken(context.Background(), token, AccessToken, new(DefaultSession))
+		require.ErrorIs(t, err, ErrRequestUnauthorized)
+	})
+
+	for _, c := range []struct {
+		d         string
+		key       *ecdsa.PrivateKey
+		expectErr bool
+	}{
+		{d: "passes with the bound key", key: key},
+		{d: "fails without proof"},
+		{d: "fails with another key", key: otherKey, expectErr: true},
+	} {
+		t.Run("case=resource request "+c.d, func(t *testing.T) {
+			r := resourceRequest(token, c.key)
+			require.Equal(t, token, AccessTokenFromRequest(r))
+
+			ctx := context.WithValue(context.Background(), ResourceRequestContextKey, r)
+			_, _, err := f.IntrospectToken(ctx, AccessTokenFromRequest(r), AccessToken, new(DefaultSession))
+			if c.key == nil || c.expectErr {
+				require.ErrorIs(t, err, ErrRequestUnauthorized)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestWriteAccessErrorDPoPNonce(t *testing.T) {
+	rw := httptest.NewRecorder()
+	f := &Fosite{DPoPStrategy: &DefaultDPoPStrategy{RequireNonce: true}}
+	f.WriteAccessError(rw, nil, ErrUseDPoPNonce)
+
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.NotEmpty(t, rw.Header().Get(DPoPNonceHeaderName))
+	assert.Contains(t, rw.Body.String(), "use_dpop_nonce")
+}
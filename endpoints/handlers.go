@@ -0,0 +1,199 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+// Package endpoints provides ready-made http.Handler implementations of the endpoints of a fosite.OAuth2Provider
+// which can be mounted on any router:
+//
+//	mux.Handle("/oauth2/token", &endpoints.TokenHandler{Provider: provider})
+//	mux.Handle("/oauth2/introspect", &endpoints.IntrospectionHandler{Provider: provider})
+//	mux.Handle("/oauth2/revoke", &endpoints.RevocationHandler{Provider: provider})
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/ory/x/errorsx"
+	"gopkg.in/square/go-jose.v2"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/token/jwt"
+)
+
+// SessionFactory returns the session which is hydrated by the endpoint handling r, for example a *fosite.DefaultSession.
+type SessionFactory func(ctx context.Context, r *http.Request) fosite.Session
+
+func newSession(factory SessionFactory, ctx context.Context, r *http.Request) fosite.Session {
+	if factory == nil {
+		return new(fosite.DefaultSession)
+	}
+	return factory(ctx, r)
+}
+
+// AuthorizeHandler is an http.Handler serving the authorization endpoint.
+type AuthorizeHandler struct {
+	Provider fosite.OAuth2Provider
+
+	// Authorize authenticates the end-user, obtains their consent and grants the scopes and audiences of the
+	// request. It returns the session of the end-user. Returned errors are written as authorization errors, for
+	// example ErrAccessDenied or ErrLoginRequired. If Authorize writes the response itself, for example to
+	// redirect the end-user to a login page, it returns a nil session and a nil error.
+	Authorize func(ctx context.Context, rw http.ResponseWriter, r *http.Request, ar fosite.AuthorizeRequester) (fosite.Session, error)
+}
+
+var _ http.Handler = (*AuthorizeHandler)(nil)
+
+func (h *AuthorizeHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	ar, err := h.Provider.NewAuthorizeRequest(ctx, r)
+	if err != nil {
+		h.Provider.WriteAuthorizeError(rw, ar, err)
+		return
+	}
+
+	session, err := h.Authorize(ctx, rw, r, ar)
+	if err != nil {
+		h.Provider.WriteAuthorizeError(rw, ar, err)
+		return
+	} else if session == nil {
+		return
+	}
+
+	response, err := h.Provider.NewAuthorizeResponse(ctx, ar, session)
+	if err != nil {
+		h.Provider.WriteAuthorizeError(rw, ar, err)
+		return
+	}
+
+	h.Provider.WriteAuthorizeResponse(rw, ar, response)
+}
+
+// TokenHandler is an http.Handler serving the token endpoint.
+type TokenHandler struct {
+	Provider fosite.OAuth2Provider
+
+	// NewSession returns the session which is hydrated with the session of the grant. Defaults to a
+	// *fosite.DefaultSession.
+	NewSession SessionFactory
+
+	// Grant is called before the access response is created, for example to grant the requested scopes of the
+	// client credentials grant. Returned errors are written as access errors. Optional.
+	Grant func(ctx context.Context, ar fosite.AccessRequester) error
+}
+
+var _ http.Handler = (*TokenHandler)(nil)
+
+func (h *TokenHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	ar, err := h.Provider.NewAccessRequest(ctx, r, newSession(h.NewSession, ctx, r))
+	if err != nil {
+		h.Provider.WriteAccessError(rw, ar, err)
+		return
+	}
+
+	if h.Grant != nil {
+		if err := h.Grant(ctx, ar); err != nil {
+			h.Provider.WriteAccessError(rw, ar, err)
+			return
+		}
+	}
+
+	response, err := h.Provider.NewAccessResponse(ctx, ar)
+	if err != nil {
+		h.Provider.WriteAccessError(rw, ar, err)
+		return
+	}
+
+	h.Provider.WriteAccessResponse(rw, ar, response)
+}
+
+// IntrospectionHandler is an http.Handler serving the token introspection endpoint.
+type IntrospectionHandler struct {
+	Provider fosite.OAuth2Provider
+
+	// NewSession returns the session which is hydrated with the session of the token. Defaults to a
+	// *fosite.DefaultSession.
+	NewSession SessionFactory
+}
+
+var _ http.Handler = (*IntrospectionHandler)(nil)
+
+func (h *IntrospectionHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	ir, err := h.Provider.NewIntrospectionRequest(ctx, r, newSession(h.NewSession, ctx, r))
+	if err != nil {
+		h.Provider.WriteIntrospectionError(rw, err)
+		return
+	}
+
+	h.Provider.WriteIntrospectionResponse(rw, ir)
+}
+
+// RevocationHandler is an http.Handler serving the token revocation endpoint.
+type RevocationHandler struct {
+	Provider fosite.OAuth2Provider
+}
+
+var _ http.Handler = (*RevocationHandler)(nil)
+
+func (h *RevocationHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	h.Provider.WriteRevocationResponse(rw, h.Provider.NewRevocationRequest(r.Context(), r))
+}
+
+// JWKSHandler is an http.Handler serving the public keys of Keys as JSON Web Key Set. Private keys are never
+// exposed, even if Keys returns them.
+type JWKSHandler struct {
+	Keys jwt.KeyProvider
+}
+
+var _ http.Handler = (*JWKSHandler)(nil)
+
+func (h *JWKSHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	set, err := h.Keys.GetPublicKeys(r.Context())
+	if err != nil {
+		new(fosite.DefaultErrorWriter).WriteError(rw, "", nil, fosite.ErrorToRFC6749Error(errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))))
+		return
+	}
+
+	keys := make([]jose.JSONWebKey, 0, len(set.Keys))
+	for _, key := range set.Keys {
+		if !key.IsPublic() {
+			key = key.Public()
+		}
+		if key.Valid() {
+			keys = append(keys, key)
+		}
+	}
+
+	js, err := json.Marshal(&jose.JSONWebKeySet{Keys: keys})
+	if err != nil {
+		new(fosite.DefaultErrorWriter).WriteError(rw, "", nil, fosite.ErrorToRFC6749Error(errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))))
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	// ignoring the error because the connection is broken when it happens
+	_, _ = rw.Write(js)
+}
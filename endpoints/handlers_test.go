@@ -0,0 +1,173 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package endpoints_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/endpoints"
+	"github.com/ory/fosite/storage"
+	"github.com/ory/fosite/token/jwt"
+)
+
+func TestHandlers(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	provider := compose.ComposeAllEnabled(&compose.Config{}, storage.NewExampleStore(), []byte("some-secret-thats-random-some-secret-thats-random-"), key)
+
+	mux := http.NewServeMux()
+	mux.Handle("/auth", &endpoints.AuthorizeHandler{Provider: provider, Authorize: func(ctx context.Context, rw http.ResponseWriter, r *http.Request, ar fosite.AuthorizeRequester) (fosite.Session, error) {
+		if r.URL.Query().Get("login") == "" {
+			http.Redirect(rw, r, "/login", http.StatusFound)
+			return nil, nil
+		} else if r.URL.Query().Get("login") == "deny" {
+			return nil, fosite.ErrAccessDenied
+		}
+		ar.GrantScope("fosite")
+		return &fosite.DefaultSession{Subject: "peter"}, nil
+	}})
+	mux.Handle("/token", &endpoints.TokenHandler{Provider: provider, Grant: func(ctx context.Context, ar fosite.AccessRequester) error {
+		if ar.GetRequestedScopes().Has("fosite") {
+			ar.GrantScope("fosite")
+		}
+		return nil
+	}})
+	mux.Handle("/introspect", &endpoints.IntrospectionHandler{Provider: provider})
+	mux.Handle("/revoke", &endpoints.RevocationHandler{Provider: provider})
+	mux.Handle("/jwks", &endpoints.JWKSHandler{Keys: jwt.NewRotatingKeyProvider(&jose.JSONWebKey{Key: key, KeyID: "a", Algorithm: "RS256", Use: "sig"})})
+	mux.Handle("/private-jwks", &endpoints.JWKSHandler{Keys: privateKeys{{Key: key, KeyID: "b", Algorithm: "RS256", Use: "sig"}}})
+
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+
+	post := func(t *testing.T, path string, values url.Values) (*http.Response, map[string]interface{}) {
+		req, err := http.NewRequest("POST", ts.URL+path, strings.NewReader(values.Encode()))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth("my-client", "foobar")
+		res, err := client.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		body := map[string]interface{}{}
+		_ = json.NewDecoder(res.Body).Decode(&body)
+		return res, body
+	}
+
+	t.Run("endpoint=authorize", func(t *testing.T) {
+		authorize := func(login string) *http.Response {
+			res, err := client.Get(ts.URL + "/auth?" + url.Values{
+				"response_type": {"code"},
+				"client_id":     {"my-client"},
+				"redirect_uri":  {"http://localhost:3846/callback"},
+				"scope":         {"fosite"},
+				"state":         {"12345678901234567890"},
+				"login":         {login},
+			}.Encode())
+			require.NoError(t, err)
+			defer res.Body.Close()
+			return res
+		}
+
+		res := authorize("")
+		assert.Equal(t, http.StatusFound, res.StatusCode)
+		assert.Equal(t, "/login", res.Header.Get("Location"))
+
+		res = authorize("deny")
+		require.Equal(t, http.StatusSeeOther, res.StatusCode)
+		location, err := url.Parse(res.Header.Get("Location"))
+		require.NoError(t, err)
+		assert.Equal(t, "access_denied", location.Query().Get("error"))
+
+		res = authorize("peter")
+		require.Equal(t, http.StatusSeeOther, res.StatusCode)
+		location, err = url.Parse(res.Header.Get("Location"))
+		require.NoError(t, err)
+		assert.NotEmpty(t, location.Query().Get("code"))
+
+		res, body := post(t, "/token", url.Values{"grant_type": {"authorization_code"}, "code": {location.Query().Get("code")}, "redirect_uri": {"http://localhost:3846/callback"}})
+		assert.Equal(t, http.StatusOK, res.StatusCode, "%+v", body)
+		assert.NotEmpty(t, body["access_token"])
+	})
+
+	t.Run("endpoint=token,introspect,revoke", func(t *testing.T) {
+		res, body := post(t, "/token", url.Values{"grant_type": {"client_credentials"}})
+		require.Equal(t, http.StatusOK, res.StatusCode, "%+v", body)
+		token := body["access_token"].(string)
+
+		res, body = post(t, "/token", url.Values{"grant_type": {"foo"}})
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		assert.Equal(t, "unsupported_grant_type", body["error"])
+
+		_, body = post(t, "/introspect", url.Values{"token": {token}})
+		assert.Equal(t, true, body["active"])
+
+		res, _ = post(t, "/revoke", url.Values{"token": {token}})
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		_, body = post(t, "/introspect", url.Values{"token": {token}})
+		assert.Equal(t, false, body["active"])
+	})
+
+	t.Run("endpoint=jwks", func(t *testing.T) {
+		for path, kid := range map[string]string{"/jwks": "a", "/private-jwks": "b"} {
+			res, err := client.Get(ts.URL + path)
+			require.NoError(t, err)
+			defer res.Body.Close()
+
+			var set jose.JSONWebKeySet
+			require.NoError(t, json.NewDecoder(res.Body).Decode(&set))
+			require.Len(t, set.Keys, 1)
+			assert.Equal(t, kid, set.Keys[0].KeyID)
+			assert.True(t, set.Keys[0].IsPublic())
+		}
+	})
+}
+
+type privateKeys []jose.JSONWebKey
+
+func (k privateKeys) GetSigningKey(_ context.Context) (*jose.JSONWebKey, error) {
+	return &k[0], nil
+}
+
+func (k privateKeys) GetPublicKeys(_ context.Context) (*jose.JSONWebKeySet, error) {
+	return &jose.JSONWebKeySet{Keys: k}, nil
+}
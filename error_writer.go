@@ -0,0 +1,134 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+)
+
+// ErrorWriter writes the error responses of the endpoints which are not redirected to the client, for example to
+// change the format of the response, add correlation IDs, or render HTML pages for front-channel errors. Fosite
+// sets the caching headers, localizes the error and applies UseLegacyErrorFormat and the DebugPolicy
+// before calling the ErrorWriter.
+type ErrorWriter interface {
+	// WriteError writes the error response of the endpoint, for example TokenEndpoint. The requester is nil if the
+	// request is not known, for example for introspection errors.
+	WriteError(rw http.ResponseWriter, endpoint string, requester Requester, err *RFC6749Error)
+}
+
+// DefaultErrorWriter writes errors as JSON as defined in https://tools.ietf.org/html/rfc6749#section-5.2.
+type DefaultErrorWriter struct {
+	// SendDebugMessagesToClients exposes the reason if the error can not be encoded.
+	SendDebugMessagesToClients bool
+}
+
+var _ ErrorWriter = (*DefaultErrorWriter)(nil)
+
+func (w *DefaultErrorWriter) WriteError(rw http.ResponseWriter, _ string, _ Requester, rfcerr *RFC6749Error) {
+	rw.Header().Set("Content-Type", "application/json;charset=UTF-8")
+
+	js, err := json.Marshal(rfcerr)
+	if err != nil {
+		if w.SendDebugMessagesToClients {
+			errorMessage := EscapeJSONString(err.Error())
+			http.Error(rw, fmt.Sprintf(`{"error":"server_error","error_description":"%s"}`, errorMessage), http.StatusInternalServerError)
+		} else {
+			http.Error(rw, `{"error":"server_error"}`, http.StatusInternalServerError)
+		}
+		return
+	}
+
+	rw.WriteHeader(rfcerr.CodeField)
+	// ignoring the error because the connection is broken when it happens
+	_, _ = rw.Write(js)
+}
+
+// HTMLErrorWriter renders the errors of the front-channel endpoints, which are shown to the end-user in the
+// browser, using Template. Errors of other endpoints are written by Fallback, which defaults to a
+// DefaultErrorWriter.
+type HTMLErrorWriter struct {
+	// Template is executed with the *RFC6749Error.
+	Template *template.Template
+
+	// Endpoints are the endpoints whose errors are rendered. Defaults to AuthorizeEndpoint and LogoutEndpoint.
+	Endpoints []string
+
+	Fallback ErrorWriter
+}
+
+var _ ErrorWriter = (*HTMLErrorWriter)(nil)
+
+func (w *HTMLErrorWriter) WriteError(rw http.ResponseWriter, endpoint string, requester Requester, rfcerr *RFC6749Error) {
+	if !w.isFrontChannel(endpoint) {
+		w.fallback().WriteError(rw, endpoint, requester, rfcerr)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "text/html;charset=UTF-8")
+	rw.WriteHeader(rfcerr.CodeField)
+	// ignoring the error because the connection is broken when it happens
+	_ = w.Template.Execute(rw, rfcerr)
+}
+
+func (w *HTMLErrorWriter) isFrontChannel(endpoint string) bool {
+	endpoints := w.Endpoints
+	if len(endpoints) == 0 {
+		endpoints = []string{AuthorizeEndpoint, LogoutEndpoint}
+	}
+	return StringInSlice(endpoint, endpoints)
+}
+
+func (w *HTMLErrorWriter) fallback() ErrorWriter {
+	if w.Fallback == nil {
+		return new(DefaultErrorWriter)
+	}
+	return w.Fallback
+}
+
+// GetErrorWriter returns ErrorWriter if set. Defaults to a DefaultErrorWriter.
+func (f *Fosite) GetErrorWriter() ErrorWriter {
+	if f.ErrorWriter == nil {
+		return &DefaultErrorWriter{SendDebugMessagesToClients: f.SendDebugMessagesToClients}
+	}
+	return f.ErrorWriter
+}
+
+// writeError sets the caching headers and writes err using the ErrorWriter.
+func (f *Fosite) writeError(rw http.ResponseWriter, endpoint string, requester Requester, err error) {
+	rw.Header().Set("Cache-Control", "no-store")
+	rw.Header().Set("Pragma", "no-cache")
+	f.writeErrorResponse(rw, endpoint, requester, err)
+}
+
+// writeErrorResponse localizes err, sets the RequestIDHeader and writes err using the ErrorWriter.
+func (f *Fosite) writeErrorResponse(rw http.ResponseWriter, endpoint string, requester Requester, err error) {
+	setRequestIDHeader(rw, err)
+	rfcerr := f.exposeDebug(requester, ErrorToRFC6749Error(err).WithLegacyFormat(f.UseLegacyErrorFormat))
+	if requester != nil {
+		rfcerr = rfcerr.WithLocalizer(f.MessageCatalog, getLangFromRequester(requester))
+	}
+
+	f.GetErrorWriter().WriteError(rw, endpoint, requester, rfcerr)
+}
@@ -0,0 +1,94 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/fosite"
+)
+
+type correlationErrorWriter struct {
+	endpoint string
+}
+
+func (w *correlationErrorWriter) WriteError(rw http.ResponseWriter, endpoint string, _ Requester, err *RFC6749Error) {
+	w.endpoint = endpoint
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(err.CodeField)
+	_ = json.NewEncoder(rw).Encode(map[string]string{"code": err.ErrorField, "correlation_id": "abcd"})
+}
+
+func TestErrorWriter(t *testing.T) {
+	t.Run("case=should write access errors using the error writer", func(t *testing.T) {
+		w := new(correlationErrorWriter)
+		f := &Fosite{ErrorWriter: w}
+
+		rw := httptest.NewRecorder()
+		f.WriteAccessError(rw, NewAccessRequest(nil), ErrInvalidGrant)
+
+		assert.Equal(t, TokenEndpoint, w.endpoint)
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+		assert.Equal(t, "no-store", rw.Header().Get("Cache-Control"))
+		assert.JSONEq(t, `{"code":"invalid_grant","correlation_id":"abcd"}`, rw.Body.String())
+	})
+
+	t.Run("case=should write revocation errors using the error writer", func(t *testing.T) {
+		w := new(correlationErrorWriter)
+		f := &Fosite{ErrorWriter: w}
+
+		rw := httptest.NewRecorder()
+		f.WriteRevocationResponse(rw, ErrInvalidClient)
+
+		assert.Equal(t, RevocationEndpoint, w.endpoint)
+		assert.Equal(t, http.StatusUnauthorized, rw.Code)
+	})
+
+	t.Run("case=should render front-channel errors as HTML", func(t *testing.T) {
+		f := &Fosite{ErrorWriter: &HTMLErrorWriter{Template: template.Must(template.New("error").Parse(`<h1>{{ .ErrorField }}</h1>`))}}
+
+		ar := NewAuthorizeRequest()
+		ar.RedirectURI, _ = url.Parse("https://foobar.com/cb")
+
+		rw := httptest.NewRecorder()
+		f.WriteAuthorizeError(rw, ar, ErrInvalidRequest)
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+		assert.Equal(t, "text/html;charset=UTF-8", rw.Header().Get("Content-Type"))
+		assert.Equal(t, "<h1>invalid_request</h1>", rw.Body.String())
+
+		rw = httptest.NewRecorder()
+		f.WriteAccessError(rw, NewAccessRequest(nil), ErrInvalidRequest)
+		assert.Equal(t, "application/json;charset=UTF-8", rw.Header().Get("Content-Type"))
+
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &body))
+		assert.Equal(t, "invalid_request", body["error"])
+	})
+}
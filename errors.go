@@ -41,6 +41,15 @@ var (
 	// ErrInvalidatedAuthorizeCode is an error indicating that an authorization code has been
 	// used previously.
 	ErrInvalidatedAuthorizeCode = errors.New("Authorization code has ben invalidated")
+	// ErrInvalidatedDeviceCode is an error indicating that a device code has been used previously.
+	ErrInvalidatedDeviceCode = errors.New("Device code has been invalidated")
+	// ErrInvalidatedUserCode is an error indicating that a user code has been used previously.
+	ErrInvalidatedUserCode = errors.New("User code has been invalidated")
+	// ErrInvalidatedAuthRequestID is an error indicating that a backchannel authentication request has been used
+	// previously.
+	ErrInvalidatedAuthRequestID = errors.New("Backchannel authentication request has been invalidated")
+	// ErrInvalidatedPreAuthorizedCode is an error indicating that a pre-authorized code has been used previously.
+	ErrInvalidatedPreAuthorizedCode = errors.New("Pre-authorized code has been invalidated")
 	// ErrSerializationFailure is an error indicating that the transactional capable storage could not guarantee
 	// consistency of Update & Delete operations on the same rows between multiple sessions.
 	ErrSerializationFailure = errors.New("The request could not be completed due to concurrent access")
@@ -220,6 +229,86 @@ var (
 		ErrorField:       errJTIKnownName,
 		CodeField:        http.StatusBadRequest,
 	}
+	ErrAuthorizationPending = &RFC6749Error{
+		DescriptionField: "The authorization request is still pending as the end user hasn't yet completed the user-interaction steps.",
+		ErrorField:       errAuthorizationPendingName,
+		CodeField:        http.StatusBadRequest,
+	}
+	ErrSlowDown = &RFC6749Error{
+		DescriptionField: "The authorization request is still pending and polling should continue, but the interval MUST be increased by 5 seconds for this and all subsequent requests.",
+		ErrorField:       errSlowDownName,
+		CodeField:        http.StatusBadRequest,
+	}
+	ErrInvalidTarget = &RFC6749Error{
+		DescriptionField: "The requested resource or audience is invalid, unknown, or malformed.",
+		ErrorField:       errInvalidTargetName,
+		CodeField:        http.StatusBadRequest,
+	}
+	ErrDeviceExpiredToken = &RFC6749Error{
+		DescriptionField: "The device_code has expired, and the device authorization session has concluded.",
+		ErrorField:       errDeviceExpiredTokenName,
+		CodeField:        http.StatusBadRequest,
+	}
+	ErrInvalidDPoPProof = &RFC6749Error{
+		DescriptionField: "The DPoP proof is invalid.",
+		ErrorField:       errInvalidDPoPProofName,
+		CodeField:        http.StatusBadRequest,
+	}
+	ErrUseDPoPNonce = &RFC6749Error{
+		DescriptionField: "The authorization server requires a nonce in the DPoP proof.",
+		ErrorField:       errUseDPoPNonceName,
+		CodeField:        http.StatusBadRequest,
+	}
+	ErrExpiredLoginHintToken = &RFC6749Error{
+		DescriptionField: "The login_hint_token provided in the authentication request is not valid because it has expired.",
+		ErrorField:       errExpiredLoginHintTokenName,
+		CodeField:        http.StatusBadRequest,
+	}
+	ErrUnknownUserID = &RFC6749Error{
+		DescriptionField: "The OpenID Provider is not able to identify which end-user the Client wishes to be authenticated by means of the hint provided in the request.",
+		ErrorField:       errUnknownUserIDName,
+		CodeField:        http.StatusBadRequest,
+	}
+	ErrMissingUserCode = &RFC6749Error{
+		DescriptionField: "User code is required but was missing from the request.",
+		ErrorField:       errMissingUserCodeName,
+		CodeField:        http.StatusBadRequest,
+	}
+	ErrInvalidUserCode = &RFC6749Error{
+		DescriptionField: "User code was invalid.",
+		ErrorField:       errInvalidUserCodeName,
+		CodeField:        http.StatusBadRequest,
+	}
+	ErrInvalidBindingMessage = &RFC6749Error{
+		DescriptionField: "The binding message is invalid or unacceptable for use in the context of the given request.",
+		ErrorField:       errInvalidBindingMessageName,
+		CodeField:        http.StatusBadRequest,
+	}
+	ErrInvalidAuthorizationDetails = &RFC6749Error{
+		DescriptionField: "The authorization details are invalid, unknown, or malformed.",
+		ErrorField:       errInvalidAuthorizationDetailsName,
+		CodeField:        http.StatusBadRequest,
+	}
+	ErrInvalidGrantID = &RFC6749Error{
+		DescriptionField: "The grant_id is invalid, unknown, or does not belong to the client.",
+		ErrorField:       errInvalidGrantIDName,
+		CodeField:        http.StatusBadRequest,
+	}
+	ErrUnmetAuthenticationRequirements = &RFC6749Error{
+		DescriptionField: "The Authorization Server is unable to meet the requirements of the Relying Party for the authentication of the End-User.",
+		ErrorField:       errUnmetAuthenticationRequirementsName,
+		CodeField:        http.StatusBadRequest,
+	}
+	ErrInsufficientUserAuthentication = &RFC6749Error{
+		DescriptionField: "The authentication event associated with the access token does not meet the authentication requirements of the protected resource.",
+		ErrorField:       errInsufficientUserAuthenticationName,
+		CodeField:        http.StatusUnauthorized,
+	}
+	ErrMFARequired = &RFC6749Error{
+		DescriptionField: "The resource owner has to complete a multi-factor authentication challenge.",
+		ErrorField:       errMFARequiredName,
+		CodeField:        http.StatusForbidden,
+	}
 )
 
 const (
@@ -257,6 +346,25 @@ const (
 	errRequestURINotSupportedName   = "request_uri_not_supported"
 	errRegistrationNotSupportedName = "registration_not_supported"
 	errJTIKnownName                 = "jti_known"
+	errAuthorizationPendingName     = "authorization_pending"
+	errSlowDownName                 = "slow_down"
+	errDeviceExpiredTokenName       = "expired_token"
+	errInvalidTargetName            = "invalid_target"
+	errInvalidGrantIDName           = "invalid_grant_id"
+	errInvalidDPoPProofName         = "invalid_dpop_proof"
+	errUseDPoPNonceName             = "use_dpop_nonce"
+	errExpiredLoginHintTokenName    = "expired_login_hint_token"
+	errUnknownUserIDName            = "unknown_user_id"
+	errMissingUserCodeName          = "missing_user_code"
+	errInvalidUserCodeName          = "invalid_user_code"
+	errInvalidBindingMessageName    = "invalid_binding_message"
+
+	errInvalidAuthorizationDetailsName = "invalid_authorization_details"
+
+	errUnmetAuthenticationRequirementsName = "unmet_authentication_requirements"
+	errInsufficientUserAuthenticationName  = "insufficient_user_authentication"
+
+	errMFARequiredName = "mfa_required"
 )
 
 type (
@@ -275,6 +383,12 @@ type (
 		hintArgs    []interface{}
 		catalog     i18n.MessageCatalog
 		lang        language.Tag
+
+		// extraFields are added to the error response, see WithExtra.
+		extraFields map[string]string
+
+		// requestID is the correlation ID of the request which caused the error, see WithRequestID.
+		requestID string
 	}
 	stackTracer interface {
 		StackTrace() errors.StackTrace
@@ -364,8 +478,17 @@ func (e RFC6749Error) Error() string {
 	return e.ErrorField
 }
 
+// RequestID returns the correlation ID of the request which caused the error, if known.
 func (e *RFC6749Error) RequestID() string {
-	return ""
+	return e.requestID
+}
+
+// WithRequestID sets the correlation ID of the request which caused the error. It is part of the debug
+// information of the error response.
+func (e *RFC6749Error) WithRequestID(id string) *RFC6749Error {
+	err := *e
+	err.requestID = id
+	return &err
 }
 
 func (e *RFC6749Error) Reason() string {
@@ -445,6 +568,23 @@ func (e *RFC6749Error) WithLocalizer(catalog i18n.MessageCatalog, lang language.
 	return &err
 }
 
+// WithExtra adds a field to the error response, for example the mfa_token of ErrMFARequired. The standard fields
+// can not be overwritten.
+func (e *RFC6749Error) WithExtra(key, value string) *RFC6749Error {
+	err := *e
+	err.extraFields = make(map[string]string, len(e.extraFields)+1)
+	for k, v := range e.extraFields {
+		err.extraFields[k] = v
+	}
+	err.extraFields[key] = value
+	return &err
+}
+
+// Extra returns the value of a field added with WithExtra.
+func (e *RFC6749Error) Extra(key string) string {
+	return e.extraFields[key]
+}
+
 // Sanitize strips the debug field
 //
 // Deprecated: Use WithExposeDebug instead.
@@ -468,12 +608,22 @@ func (e *RFC6749Error) GetDescription() string {
 	if e.HintField != "" {
 		description += " " + e.HintField
 	}
-	if e.DebugField != "" && e.exposeDebug {
-		description += " " + e.DebugField
+	if debug := e.debug(); debug != "" && e.exposeDebug {
+		description += " " + debug
 	}
 	return strings.ReplaceAll(description, "\"", "'")
 }
 
+// debug returns the debug field including the request ID.
+func (e *RFC6749Error) debug() string {
+	if e.requestID == "" {
+		return e.DebugField
+	} else if e.DebugField == "" {
+		return "Request ID: " + e.requestID
+	}
+	return e.DebugField + " Request ID: " + e.requestID
+}
+
 // RFC6749ErrorJson is a helper struct for JSON encoding/decoding of RFC6749Error.
 type RFC6749ErrorJson struct {
 	Name        string `json:"error"`
@@ -505,7 +655,7 @@ func (e *RFC6749Error) UnmarshalJSON(b []byte) error {
 
 func (e RFC6749Error) MarshalJSON() ([]byte, error) {
 	if !e.useLegacyFormat {
-		return json.Marshal(&RFC6749ErrorJson{
+		return e.marshalWithExtra(&RFC6749ErrorJson{
 			Name:        e.ErrorField,
 			Description: e.GetDescription(),
 		})
@@ -513,31 +663,61 @@ func (e RFC6749Error) MarshalJSON() ([]byte, error) {
 
 	var debug string
 	if e.exposeDebug {
-		debug = e.DebugField
+		debug = e.debug()
 	}
 
-	return json.Marshal(&RFC6749ErrorJson{
+	e.computeHintField()
+	return e.marshalWithExtra(&RFC6749ErrorJson{
 		Name:        e.ErrorField,
-		Description: e.DescriptionField,
+		Description: i18n.GetMessageOrDefault(e.catalog, e.ErrorField, e.lang, e.DescriptionField),
 		Hint:        e.HintField,
 		Code:        e.CodeField,
 		Debug:       debug,
 	})
 }
 
+func (e RFC6749Error) marshalWithExtra(data *RFC6749ErrorJson) ([]byte, error) {
+	if len(e.extraFields) == 0 {
+		return json.Marshal(data)
+	}
+
+	js, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(js, &fields); err != nil {
+		return nil, err
+	}
+	for k, v := range e.extraFields {
+		if _, ok := fields[k]; !ok {
+			fields[k] = v
+		}
+	}
+	return json.Marshal(fields)
+}
+
 func (e *RFC6749Error) ToValues() url.Values {
 	values := url.Values{}
 	values.Set("error", e.ErrorField)
 	values.Set("error_description", e.GetDescription())
 
 	if e.useLegacyFormat {
-		values.Set("error_description", e.DescriptionField)
+		values.Set("error_description", i18n.GetMessageOrDefault(e.catalog, e.ErrorField, e.lang, e.DescriptionField))
+		e.computeHintField()
 		if e.HintField != "" {
 			values.Set("error_hint", e.HintField)
 		}
 
-		if e.DebugField != "" && e.exposeDebug {
-			values.Set("error_debug", e.DebugField)
+		if debug := e.debug(); debug != "" && e.exposeDebug {
+			values.Set("error_debug", debug)
+		}
+	}
+
+	for k, v := range e.extraFields {
+		if _, ok := values[k]; !ok {
+			values.Set(k, v)
 		}
 	}
 
@@ -22,11 +22,13 @@
 package fosite
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/ory/fosite/i18n"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"golang.org/x/text/language"
 )
 
@@ -47,6 +49,20 @@ func TestRFC6749Error(t *testing.T) {
 	})
 }
 
+func TestRFC6749ErrorWithExtra(t *testing.T) {
+	err := ErrMFARequired.WithExtra("mfa_token", "foo").WithExtra("error", "bar")
+	assert.Empty(t, ErrMFARequired.Extra("mfa_token"))
+	assert.Equal(t, "foo", err.Extra("mfa_token"))
+
+	js, jsonErr := json.Marshal(err)
+	require.NoError(t, jsonErr)
+	assert.JSONEq(t, `{"error":"mfa_required","error_description":"The resource owner has to complete a multi-factor authentication challenge.","mfa_token":"foo"}`, string(js))
+
+	values := err.ToValues()
+	assert.Equal(t, "foo", values.Get("mfa_token"))
+	assert.Equal(t, "mfa_required", values.Get("error"))
+}
+
 func TestErrorI18N(t *testing.T) {
 	catalog := i18n.NewDefaultMessageCatalog([]*i18n.DefaultLocaleBundle{
 		{
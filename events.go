@@ -0,0 +1,76 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"net/http"
+)
+
+// EventListener is notified of security relevant events, for example to keep an audit trail. The listener is called
+// synchronously with the context of the request which caused the event and should therefore return quickly. The
+// correlation ID of the request can be retrieved from the context using RequestIDFromContext. Embed
+// NoopEventListener to implement only some of the hooks.
+type EventListener interface {
+	// OnTokenIssued is called after tokens have been issued at the token endpoint using any grant other than the
+	// refresh token grant.
+	OnTokenIssued(ctx context.Context, request AccessRequester, response AccessResponder)
+
+	// OnTokenRefreshed is called after tokens have been issued using the refresh token grant.
+	OnTokenRefreshed(ctx context.Context, request AccessRequester, response AccessResponder)
+
+	// OnTokenRevoked is called after a token has been revoked at the revocation endpoint.
+	OnTokenRevoked(ctx context.Context, client Client, tokenTypeHint TokenType)
+
+	// OnAuthorizationGranted is called after an authorization response has been created.
+	OnAuthorizationGranted(ctx context.Context, request AuthorizeRequester, response AuthorizeResponder)
+
+	// OnAuthorizationDenied is called when an authorization error response is written, for example because the
+	// resource owner denied the request or the request was invalid.
+	OnAuthorizationDenied(ctx context.Context, request AuthorizeRequester, err error)
+
+	// OnClientAuthenticationFailed is called when a client could not be authenticated.
+	OnClientAuthenticationFailed(ctx context.Context, r *http.Request, err error)
+
+	// OnConsentSkipped is called when the authorization was granted without asking the resource owner for consent.
+	OnConsentSkipped(ctx context.Context, request AuthorizeRequester)
+}
+
+// NoopEventListener is an EventListener which ignores all events.
+type NoopEventListener struct{}
+
+var _ EventListener = NoopEventListener{}
+
+func (NoopEventListener) OnTokenIssued(context.Context, AccessRequester, AccessResponder) {}
+
+func (NoopEventListener) OnTokenRefreshed(context.Context, AccessRequester, AccessResponder) {}
+
+func (NoopEventListener) OnTokenRevoked(context.Context, Client, TokenType) {}
+
+func (NoopEventListener) OnAuthorizationGranted(context.Context, AuthorizeRequester, AuthorizeResponder) {
+}
+
+func (NoopEventListener) OnAuthorizationDenied(context.Context, AuthorizeRequester, error) {}
+
+func (NoopEventListener) OnClientAuthenticationFailed(context.Context, *http.Request, error) {}
+
+func (NoopEventListener) OnConsentSkipped(context.Context, AuthorizeRequester) {}
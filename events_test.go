@@ -0,0 +1,131 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/storage"
+)
+
+type recordingEventListener struct {
+	NoopEventListener
+	events []string
+}
+
+func (l *recordingEventListener) OnTokenIssued(_ context.Context, request AccessRequester, _ AccessResponder) {
+	l.events = append(l.events, "issued:"+request.GetGrantTypes()[0])
+}
+
+func (l *recordingEventListener) OnTokenRefreshed(context.Context, AccessRequester, AccessResponder) {
+	l.events = append(l.events, "refreshed")
+}
+
+func (l *recordingEventListener) OnTokenRevoked(_ context.Context, client Client, tokenTypeHint TokenType) {
+	l.events = append(l.events, "revoked:"+client.GetID()+":"+string(tokenTypeHint))
+}
+
+func (l *recordingEventListener) OnAuthorizationGranted(_ context.Context, request AuthorizeRequester, _ AuthorizeResponder) {
+	l.events = append(l.events, "granted:"+request.GetClient().GetID())
+}
+
+func (l *recordingEventListener) OnAuthorizationDenied(_ context.Context, _ AuthorizeRequester, err error) {
+	l.events = append(l.events, "denied:"+ErrorToRFC6749Error(err).ErrorField)
+}
+
+func (l *recordingEventListener) OnConsentSkipped(_ context.Context, request AuthorizeRequester) {
+	l.events = append(l.events, "consent_skipped:"+request.GetClient().GetID())
+}
+
+func (l *recordingEventListener) OnClientAuthenticationFailed(context.Context, *http.Request, error) {
+	l.events = append(l.events, "client_authentication_failed")
+}
+
+func TestEventListener(t *testing.T) {
+	listener := &recordingEventListener{}
+	config := &compose.Config{EventListener: listener}
+	f := compose.Compose(
+		config,
+		storage.NewExampleStore(),
+		compose.NewOAuth2HMACStrategy(config, []byte("some-secret-thats-random-some-secret-thats-random-"), nil),
+		nil,
+		compose.OAuth2AuthorizeImplicitFactory,
+		compose.OAuth2ResourceOwnerPasswordCredentialsFactory,
+		compose.OAuth2RefreshTokenGrantFactory,
+		compose.OAuth2TokenRevocationFactory,
+	)
+	ctx := context.Background()
+
+	newRequest := func(form url.Values, secret string) *http.Request {
+		r := httptest.NewRequest("POST", "/token", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.SetBasicAuth("my-client", secret)
+		return r
+	}
+
+	token := func(t *testing.T, form url.Values) AccessResponder {
+		ar, err := f.NewAccessRequest(ctx, newRequest(form, "foobar"), &DefaultSession{})
+		require.NoError(t, err)
+		ar.GrantScope("offline")
+		resp, err := f.NewAccessResponse(ctx, ar)
+		require.NoError(t, err)
+		return resp
+	}
+
+	resp := token(t, url.Values{"grant_type": {"password"}, "username": {"peter"}, "password": {"secret"}, "scope": {"offline"}})
+	token(t, url.Values{"grant_type": {"refresh_token"}, "refresh_token": {resp.ToMap()["refresh_token"].(string)}})
+	require.NoError(t, f.NewRevocationRequest(ctx, newRequest(url.Values{"token": {resp.GetAccessToken()}, "token_type_hint": {"access_token"}}, "foobar")))
+
+	_, err := f.NewAccessRequest(ctx, newRequest(url.Values{"grant_type": {"password"}}, "invalid"), &DefaultSession{})
+	require.Error(t, err)
+
+	r := httptest.NewRequest("GET", "/auth?"+url.Values{
+		"client_id":     {"my-client"},
+		"response_type": {"token"},
+		"redirect_uri":  {"http://localhost:3846/callback"},
+		"state":         {"some-state-value"},
+	}.Encode(), nil)
+	ar, err := f.NewAuthorizeRequest(ctx, r)
+	require.NoError(t, err)
+	_, err = f.NewAuthorizeResponse(ctx, ar, &DefaultSession{})
+	require.NoError(t, err)
+	f.WriteAuthorizeError(httptest.NewRecorder(), ar, ErrAccessDenied)
+
+	assert.Equal(t, []string{
+		"issued:password",
+		"refreshed",
+		"revoked:my-client:access_token",
+		"client_authentication_failed",
+		"granted:my-client",
+		"denied:access_denied",
+	}, listener.events)
+}
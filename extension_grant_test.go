@@ -0,0 +1,151 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/handler/extension"
+	"github.com/ory/fosite/storage"
+)
+
+func TestExtensionGrant(t *testing.T) {
+	const grantType = "urn:mycompany:params:grant-type:ticket"
+
+	store := storage.NewExampleStore()
+	store.Clients["ticket-client"] = &DefaultClient{
+		ID:         "ticket-client",
+		Secret:     []byte(`$2a$10$IxMdI6d.LIRZPpSfEwNoeu4rY3FhDREsxFJXikcgdRRAStxUlsuEO`), // = "foobar"
+		GrantTypes: []string{grantType},
+		Scopes:     []string{"photos"},
+	}
+
+	factory, err := compose.ExtensionGrantFactory(grantType, extension.GrantValidatorFunc(func(ctx context.Context, request AccessRequester) error {
+		switch request.GetRequestForm().Get("ticket") {
+		case "valid":
+			request.GetSession().(*DefaultSession).Subject = "peter"
+			for _, scope := range request.GetRequestedScopes() {
+				request.GrantScope(scope)
+			}
+			return nil
+		case "broken":
+			return errors.New("ticket storage is unavailable")
+		}
+		return ErrInvalidGrant.WithHint("The ticket is invalid.")
+	}))
+	require.NoError(t, err)
+	otherFactory, err := compose.ExtensionGrantFactory("urn:mycompany:params:grant-type:other", extension.GrantValidatorFunc(func(context.Context, AccessRequester) error {
+		return nil
+	}))
+	require.NoError(t, err)
+
+	config := new(compose.Config)
+	f := compose.Compose(
+		config,
+		store,
+		compose.NewOAuth2HMACStrategy(config, []byte("some-secret-thats-random-some-secret-thats-random-"), nil),
+		nil,
+		compose.OAuth2ClientCredentialsGrantFactory,
+		otherFactory,
+		factory,
+	)
+
+	for k, c := range []struct {
+		d         string
+		client    string
+		form      url.Values
+		expectErr error
+	}{
+		{
+			d:      "should issue an access token",
+			client: "ticket-client",
+			form:   url.Values{"grant_type": {grantType}, "ticket": {"valid"}, "scope": {"photos"}},
+		},
+		{
+			d:         "should fail because the ticket is invalid",
+			client:    "ticket-client",
+			form:      url.Values{"grant_type": {grantType}, "ticket": {"invalid"}},
+			expectErr: ErrInvalidGrant,
+		},
+		{
+			d:         "should fail with a server error if the validator fails",
+			client:    "ticket-client",
+			form:      url.Values{"grant_type": {grantType}, "ticket": {"broken"}},
+			expectErr: ErrServerError,
+		},
+		{
+			d:         "should fail because the scope is not allowed",
+			client:    "ticket-client",
+			form:      url.Values{"grant_type": {grantType}, "ticket": {"valid"}, "scope": {"fosite"}},
+			expectErr: ErrInvalidScope,
+		},
+		{
+			d:         "should fail because the client is not allowed to use the grant",
+			client:    "my-client",
+			form:      url.Values{"grant_type": {grantType}, "ticket": {"valid"}},
+			expectErr: ErrUnauthorizedClient,
+		},
+		{
+			d:         "should fail because the grant type is not supported",
+			client:    "ticket-client",
+			form:      url.Values{"grant_type": {"urn:mycompany:params:grant-type:unknown"}, "ticket": {"valid"}},
+			expectErr: ErrUnsupportedGrantType,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/token", strings.NewReader(c.form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			r.SetBasicAuth(c.client, "foobar")
+
+			ar, err := f.NewAccessRequest(context.Background(), r, new(DefaultSession))
+			if c.expectErr != nil {
+				require.EqualError(t, err, c.expectErr.Error())
+				return
+			}
+			require.NoError(t, err)
+
+			resp, err := f.NewAccessResponse(context.Background(), ar)
+			require.NoError(t, err)
+			assert.NotEmpty(t, resp.GetAccessToken())
+			assert.Equal(t, "peter", ar.GetSession().GetSubject())
+			assert.Equal(t, Arguments{"photos"}, ar.GetGrantedScopes())
+		})
+	}
+
+	t.Run("case=should reject grant types which are not absolute URIs", func(t *testing.T) {
+		_, err := compose.ExtensionGrantFactory("ticket", extension.GrantValidatorFunc(func(context.Context, AccessRequester) error {
+			return nil
+		}))
+		require.Error(t, err)
+	})
+}
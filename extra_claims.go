@@ -0,0 +1,108 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"encoding/json"
+	"math"
+)
+
+// ExtraClaims provides typed accessors for the extra claims of a session. The accessors also accept the types which
+// claims have after a JSON round-trip, for example a float64 or json.Number for Int64 and a []interface{} for Strings.
+// The second return value is false if the claim is not set or has a different type.
+type ExtraClaims map[string]interface{}
+
+// String returns the claim if it is a string.
+func (c ExtraClaims) String(key string) (string, bool) {
+	v, ok := c[key].(string)
+	return v, ok
+}
+
+// Bool returns the claim if it is a bool.
+func (c ExtraClaims) Bool(key string) (bool, bool) {
+	v, ok := c[key].(bool)
+	return v, ok
+}
+
+// Int64 returns the claim if it is an integer, or a float or json.Number without fractional part.
+func (c ExtraClaims) Int64(key string) (int64, bool) {
+	switch v := c[key].(type) {
+	case int:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case float64:
+		if v != math.Trunc(v) || v > math.MaxInt64 || v < math.MinInt64 {
+			return 0, false
+		}
+		return int64(v), true
+	case json.Number:
+		i, err := v.Int64()
+		return i, err == nil
+	}
+	return 0, false
+}
+
+// Float64 returns the claim if it is a number.
+func (c ExtraClaims) Float64(key string) (float64, bool) {
+	switch v := c[key].(type) {
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// Strings returns the claim if it is a list of strings.
+func (c ExtraClaims) Strings(key string) ([]string, bool) {
+	switch v := c[key].(type) {
+	case []string:
+		return v, true
+	case []interface{}:
+		values := make([]string, len(v))
+		for i, value := range v {
+			s, ok := value.(string)
+			if !ok {
+				return nil, false
+			}
+			values[i] = s
+		}
+		return values, true
+	}
+	return nil, false
+}
+
+// Map returns the claim if it is a JSON object.
+func (c ExtraClaims) Map(key string) (map[string]interface{}, bool) {
+	v, ok := c[key].(map[string]interface{})
+	return v, ok
+}
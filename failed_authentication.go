@@ -0,0 +1,160 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ory/x/errorsx"
+)
+
+// AuthenticationKind is the kind of credentials reported to the FailedAuthenticationRecorder.
+type AuthenticationKind string
+
+const (
+	// ClientAuthentication identifies the client secret of a client, the identifier is the client ID.
+	ClientAuthentication AuthenticationKind = "client"
+
+	// ResourceOwnerAuthentication identifies the password of a resource owner using the resource owner password
+	// credentials grant, the identifier is the username.
+	ResourceOwnerAuthentication AuthenticationKind = "resource_owner"
+
+	// TransactionCodeAuthentication identifies the transaction code sent alongside a pre-authorized code, the
+	// identifier is the signature of the pre-authorized code.
+	TransactionCodeAuthentication AuthenticationKind = "transaction_code"
+)
+
+// FailedAuthenticationRecorder is informed about authentication attempts using client secrets and resource owner
+// passwords, which enables rate limiting and lockout policies against brute-force attacks.
+type FailedAuthenticationRecorder interface {
+	// AuthenticationAllowed is called before the credentials are verified. Returning an error rejects the attempt
+	// with that error, for example because the identifier is locked out.
+	AuthenticationAllowed(ctx context.Context, kind AuthenticationKind, identifier string) error
+
+	// RecordFailedAuthentication is called when the credentials are invalid, with the error of the response.
+	// Returning an error, for example a lockout error, replaces the error of the response.
+	RecordFailedAuthentication(ctx context.Context, kind AuthenticationKind, identifier string, err error) error
+
+	// RecordSuccessfulAuthentication is called when the credentials are valid, for example to reset a counter.
+	RecordSuccessfulAuthentication(ctx context.Context, kind AuthenticationKind, identifier string)
+}
+
+// CheckAuthenticationAllowed asks the recorder, which may be nil, whether the identifier may authenticate.
+func CheckAuthenticationAllowed(ctx context.Context, recorder FailedAuthenticationRecorder, kind AuthenticationKind, identifier string) error {
+	if recorder == nil {
+		return nil
+	}
+	return recorder.AuthenticationAllowed(ctx, kind, identifier)
+}
+
+// RecordFailedAuthentication reports the failed attempt to the recorder, which may be nil, and returns the error of
+// the response.
+func RecordFailedAuthentication(ctx context.Context, recorder FailedAuthenticationRecorder, kind AuthenticationKind, identifier string, err error) error {
+	if recorder == nil {
+		return err
+	} else if lockoutErr := recorder.RecordFailedAuthentication(ctx, kind, identifier, err); lockoutErr != nil {
+		return lockoutErr
+	}
+	return err
+}
+
+// RecordSuccessfulAuthentication reports the successful attempt to the recorder, which may be nil.
+func RecordSuccessfulAuthentication(ctx context.Context, recorder FailedAuthenticationRecorder, kind AuthenticationKind, identifier string) {
+	if recorder != nil {
+		recorder.RecordSuccessfulAuthentication(ctx, kind, identifier)
+	}
+}
+
+// MemoryAuthenticationLockout locks identifiers out for LockoutDuration after MaxAttempts consecutive failed
+// attempts. It keeps its state in memory and is therefore only suited for single instance deployments.
+type MemoryAuthenticationLockout struct {
+	MaxAttempts     int
+	LockoutDuration time.Duration
+
+	mu       sync.Mutex
+	failures map[string]*authenticationFailures
+}
+
+type authenticationFailures struct {
+	count       int
+	lockedUntil time.Time
+}
+
+var _ FailedAuthenticationRecorder = (*MemoryAuthenticationLockout)(nil)
+
+// NewMemoryAuthenticationLockout returns a MemoryAuthenticationLockout.
+func NewMemoryAuthenticationLockout(maxAttempts int, lockoutDuration time.Duration) *MemoryAuthenticationLockout {
+	return &MemoryAuthenticationLockout{MaxAttempts: maxAttempts, LockoutDuration: lockoutDuration}
+}
+
+func (l *MemoryAuthenticationLockout) AuthenticationAllowed(_ context.Context, kind AuthenticationKind, identifier string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if failures, ok := l.failures[l.key(kind, identifier)]; ok && time.Now().Before(failures.lockedUntil) {
+		return lockoutError(kind)
+	}
+	return nil
+}
+
+func (l *MemoryAuthenticationLockout) RecordFailedAuthentication(_ context.Context, kind AuthenticationKind, identifier string, _ error) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.failures == nil {
+		l.failures = make(map[string]*authenticationFailures)
+	}
+
+	key := l.key(kind, identifier)
+	failures, ok := l.failures[key]
+	if !ok || (!failures.lockedUntil.IsZero() && time.Now().After(failures.lockedUntil)) {
+		failures = new(authenticationFailures)
+		l.failures[key] = failures
+	}
+
+	failures.count++
+	if l.MaxAttempts > 0 && failures.count >= l.MaxAttempts {
+		failures.lockedUntil = time.Now().Add(l.LockoutDuration)
+		return lockoutError(kind)
+	}
+	return nil
+}
+
+func (l *MemoryAuthenticationLockout) RecordSuccessfulAuthentication(_ context.Context, kind AuthenticationKind, identifier string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.failures, l.key(kind, identifier))
+}
+
+func (l *MemoryAuthenticationLockout) key(kind AuthenticationKind, identifier string) string {
+	return string(kind) + ":" + identifier
+}
+
+func lockoutError(kind AuthenticationKind) error {
+	const hint = "Too many failed authentication attempts, please try again later."
+	if kind == ClientAuthentication {
+		return errorsx.WithStack(ErrInvalidClient.WithHint(hint))
+	}
+	return errorsx.WithStack(ErrInvalidGrant.WithHint(hint))
+}
@@ -0,0 +1,94 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/storage"
+)
+
+func TestMemoryAuthenticationLockout(t *testing.T) {
+	ctx := context.Background()
+	lockout := NewMemoryAuthenticationLockout(2, time.Hour)
+
+	require.NoError(t, lockout.AuthenticationAllowed(ctx, ClientAuthentication, "foo"))
+	require.NoError(t, lockout.RecordFailedAuthentication(ctx, ClientAuthentication, "foo", ErrInvalidClient))
+	lockout.RecordSuccessfulAuthentication(ctx, ClientAuthentication, "foo")
+	require.NoError(t, lockout.RecordFailedAuthentication(ctx, ClientAuthentication, "foo", ErrInvalidClient))
+
+	err := lockout.RecordFailedAuthentication(ctx, ClientAuthentication, "foo", ErrInvalidClient)
+	assert.True(t, errors.Is(err, ErrInvalidClient), "%+v", err)
+	assert.True(t, errors.Is(lockout.AuthenticationAllowed(ctx, ClientAuthentication, "foo"), ErrInvalidClient))
+	assert.NoError(t, lockout.AuthenticationAllowed(ctx, ResourceOwnerAuthentication, "foo"))
+
+	lockout.LockoutDuration = 0
+	require.NoError(t, lockout.RecordFailedAuthentication(ctx, ResourceOwnerAuthentication, "bar", ErrInvalidGrant))
+	require.Error(t, lockout.RecordFailedAuthentication(ctx, ResourceOwnerAuthentication, "bar", ErrInvalidGrant))
+	require.NoError(t, lockout.AuthenticationAllowed(ctx, ResourceOwnerAuthentication, "bar"))
+}
+
+func TestFailedAuthenticationRecorder(t *testing.T) {
+	config := &compose.Config{FailedAuthenticationRecorder: NewMemoryAuthenticationLockout(2, time.Hour)}
+	f := compose.Compose(config, storage.NewExampleStore(), compose.NewOAuth2HMACStrategy(config, []byte("some-secret-thats-random-some-secret-thats-random-"), nil), nil, compose.OAuth2ResourceOwnerPasswordCredentialsFactory)
+
+	tokenRequest := func(clientSecret, password string) error {
+		r := httptest.NewRequest("POST", "/token", strings.NewReader(url.Values{"grant_type": {"password"}, "username": {"peter"}, "password": {password}}.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.SetBasicAuth("my-client", clientSecret)
+		_, err := f.NewAccessRequest(context.Background(), r, new(DefaultSession))
+		return err
+	}
+
+	t.Run("case=should lock out the resource owner", func(t *testing.T) {
+		require.NoError(t, tokenRequest("foobar", "secret"))
+		for i := 0; i < 2; i++ {
+			assert.True(t, errors.Is(tokenRequest("foobar", "wrong"), ErrInvalidGrant))
+		}
+
+		err := tokenRequest("foobar", "secret")
+		require.Error(t, err)
+		assert.Contains(t, ErrorToRFC6749Error(err).HintField, "Too many failed authentication attempts")
+	})
+
+	t.Run("case=should lock out the client", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			assert.True(t, errors.Is(tokenRequest("wrong", "secret"), ErrInvalidClient))
+		}
+
+		err := tokenRequest("foobar", "secret")
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, ErrInvalidClient), "%+v", err)
+		assert.Contains(t, ErrorToRFC6749Error(err).HintField, "Too many failed authentication attempts")
+	})
+}
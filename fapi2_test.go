@@ -0,0 +1,119 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/internal"
+	"github.com/ory/fosite/storage"
+)
+
+func TestValidateFAPI2(t *testing.T) {
+	for _, tc := range []struct {
+		d         string
+		config    *compose.Config
+		expectErr bool
+	}{
+		{d: "fails without sender-constrained access tokens", config: &compose.Config{}, expectErr: true},
+		{d: "passes with DPoP", config: &compose.Config{DPoPStrategy: &DefaultDPoPStrategy{}}},
+		{d: "passes with mTLS", config: &compose.Config{TLSClientCertificateBoundAccessTokens: true}},
+		{
+			d:         "fails with the plain PKCE challenge method",
+			config:    &compose.Config{DPoPStrategy: &DefaultDPoPStrategy{}, EnablePKCEPlainChallengeMethod: true},
+			expectErr: true,
+		},
+		{
+			d: "fails with client secrets",
+			config: &compose.Config{
+				DPoPStrategy:                 &DefaultDPoPStrategy{},
+				ClientAuthenticationHandlers: ClientAuthenticationHandlers{&PrivateKeyJWTClientAuthenticationHandler{}, &ClientSecretBasicClientAuthenticationHandler{}},
+			},
+			expectErr: true,
+		},
+	} {
+		t.Run("case="+tc.d, func(t *testing.T) {
+			err := tc.config.ValidateFAPI2()
+			if tc.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestComposeFAPI2(t *testing.T) {
+	_, err := compose.ComposeFAPI2(&compose.Config{}, storage.NewExampleStore(), nil, nil)
+	require.Error(t, err)
+
+	config := &compose.Config{DPoPStrategy: &DefaultDPoPStrategy{}}
+	store := storage.NewExampleStore()
+	p, err := compose.ComposeFAPI2(
+		config,
+		store,
+		&compose.CommonStrategy{
+			CoreStrategy:               compose.NewOAuth2HMACStrategy(config, []byte("some-secret-thats-random-some-secret-thats-random-"), nil),
+			OpenIDConnectTokenStrategy: compose.NewOpenIDConnectStrategy(config, internal.MustRSAKey()),
+		},
+		nil,
+		compose.OAuth2AuthorizeExplicitFactory,
+		compose.OAuth2AuthorizeImplicitFactory,
+		compose.OpenIDConnectExplicitFactory,
+		compose.OpenIDConnectHybridFactory,
+	)
+	require.NoError(t, err)
+	f := p.(*Fosite)
+	ctx := context.Background()
+	assert.False(t, config.EnforceFAPI2, "the config passed to ComposeFAPI2 must not be modified")
+
+	t.Run("case=requires pushed authorization requests", func(t *testing.T) {
+		_, err := f.NewAuthorizeRequest(ctx, httptest.NewRequest("GET", "/auth?"+url.Values{
+			"client_id":             {"my-client"},
+			"response_type":         {"code"},
+			"redirect_uri":          {"http://localhost:3846/callback"},
+			"state":                 {"some-random-state"},
+			"code_challenge":        {"challenge"},
+			"code_challenge_method": {"S256"},
+		}.Encode(), nil))
+		assert.True(t, errors.Is(err, ErrInvalidRequest), "%+v", err)
+	})
+
+	t.Run("case=publishes the profile", func(t *testing.T) {
+		metadata := f.NewDiscoveryMetadata(ctx, DiscoveryConfiguration{})
+		assert.Equal(t, []string{"code"}, metadata.ResponseTypesSupported)
+		assert.Equal(t, []string{"S256"}, metadata.CodeChallengeMethodsSupported)
+		assert.ElementsMatch(t, []string{"private_key_jwt", "tls_client_auth", "self_signed_tls_client_auth"}, metadata.TokenEndpointAuthMethodsSupported)
+		assert.True(t, metadata.RequirePushedAuthorizationRequests)
+		assert.True(t, f.RequireSenderConstrainedAccessTokens)
+		assert.Len(t, f.PushedAuthorizeEndpointHandlers, 1)
+	})
+}
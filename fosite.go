@@ -25,8 +25,12 @@ import (
 	"html/template"
 	"net/http"
 	"reflect"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/ory/fosite/i18n"
+	"github.com/ory/fosite/token/jwt"
 )
 
 // AuthorizeEndpointHandlers is a list of AuthorizeEndpointHandler
@@ -46,10 +50,11 @@ func (a *AuthorizeEndpointHandlers) Append(h AuthorizeEndpointHandler) {
 // TokenEndpointHandlers is a list of TokenEndpointHandler
 type TokenEndpointHandlers []TokenEndpointHandler
 
-// Append adds an TokenEndpointHandler to this list. Ignores duplicates based on reflect.TypeOf.
+// Append adds an TokenEndpointHandler to this list. Ignores duplicates based on reflect.TypeOf, and for a
+// GrantTypeHandler additionally on the grant type.
 func (t *TokenEndpointHandlers) Append(h TokenEndpointHandler) {
 	for _, this := range *t {
-		if reflect.TypeOf(this) == reflect.TypeOf(h) {
+		if reflect.TypeOf(this) == reflect.TypeOf(h) && !handleDifferentGrantTypes(this, h) {
 			return
 		}
 	}
@@ -57,6 +62,15 @@ func (t *TokenEndpointHandlers) Append(h TokenEndpointHandler) {
 	*t = append(*t, h)
 }
 
+func handleDifferentGrantTypes(a, b TokenEndpointHandler) bool {
+	ga, ok := a.(GrantTypeHandler)
+	if !ok {
+		return false
+	}
+	gb, ok := b.(GrantTypeHandler)
+	return ok && ga.HandledGrantType() != gb.HandledGrantType()
+}
+
 // TokenIntrospectionHandlers is a list of TokenValidator
 type TokenIntrospectionHandlers []TokenIntrospector
 
@@ -85,6 +99,62 @@ func (t *RevocationHandlers) Append(h RevocationHandler) {
 	*t = append(*t, h)
 }
 
+// DeviceEndpointHandlers is a list of DeviceEndpointHandler
+type DeviceEndpointHandlers []DeviceEndpointHandler
+
+// Append adds an DeviceEndpointHandler to this list. Ignores duplicates based on reflect.TypeOf.
+func (d *DeviceEndpointHandlers) Append(h DeviceEndpointHandler) {
+	for _, this := range *d {
+		if reflect.TypeOf(this) == reflect.TypeOf(h) {
+			return
+		}
+	}
+
+	*d = append(*d, h)
+}
+
+// PushedAuthorizeEndpointHandlers is a list of PushedAuthorizeEndpointHandler
+type PushedAuthorizeEndpointHandlers []PushedAuthorizeEndpointHandler
+
+// Append adds an PushedAuthorizeEndpointHandler to this list. Ignores duplicates based on reflect.TypeOf.
+func (p *PushedAuthorizeEndpointHandlers) Append(h PushedAuthorizeEndpointHandler) {
+	for _, this := range *p {
+		if reflect.TypeOf(this) == reflect.TypeOf(h) {
+			return
+		}
+	}
+
+	*p = append(*p, h)
+}
+
+// BackchannelAuthenticationEndpointHandlers is a list of BackchannelAuthenticationEndpointHandler
+type BackchannelAuthenticationEndpointHandlers []BackchannelAuthenticationEndpointHandler
+
+// Append adds an BackchannelAuthenticationEndpointHandler to this list. Ignores duplicates based on reflect.TypeOf.
+func (b *BackchannelAuthenticationEndpointHandlers) Append(h BackchannelAuthenticationEndpointHandler) {
+	for _, this := range *b {
+		if reflect.TypeOf(this) == reflect.TypeOf(h) {
+			return
+		}
+	}
+
+	*b = append(*b, h)
+}
+
+// ClientAuthenticationHandlers is a list of ClientAuthenticationHandler
+type ClientAuthenticationHandlers []ClientAuthenticationHandler
+
+// Append adds an ClientAuthenticationHandler to this list. Ignores duplicates based on reflect.TypeOf.
+func (c *ClientAuthenticationHandlers) Append(h ClientAuthenticationHandler) {
+	for _, this := range *c {
+		if reflect.TypeOf(this) == reflect.TypeOf(h) {
+			return
+		}
+	}
+
+	*c = append(*c, h)
+}
+
 // Fosite implements OAuth2Provider.
 type Fosite struct {
 	Store                      Storage
@@ -92,6 +162,7 @@ type Fosite struct {
 	TokenEndpointHandlers      TokenEndpointHandlers
 	TokenIntrospectionHandlers TokenIntrospectionHandlers
 	RevocationHandlers         RevocationHandlers
+	DeviceEndpointHandlers     DeviceEndpointHandlers
 	Hasher                     Hasher
 	ScopeStrategy              ScopeStrategy
 	AudienceMatchingStrategy   AudienceMatchingStrategy
@@ -99,12 +170,21 @@ type Fosite struct {
 	HTTPClient                 *http.Client
 	UseLegacyErrorFormat       bool
 
+	// PushedAuthorizeEndpointHandlers is a list of handlers that are called at the pushed authorization request endpoint.
+	PushedAuthorizeEndpointHandlers PushedAuthorizeEndpointHandlers
+
+	// BackchannelAuthenticationEndpointHandlers is a list of handlers that are called at the backchannel
+	// authentication endpoint.
+	BackchannelAuthenticationEndpointHandlers BackchannelAuthenticationEndpointHandlers
+
 	// TokenURL is the the URL of the Authorization Server's Token Endpoint.
 	TokenURL string
 
 	// SendDebugMessagesToClients if set to true, includes error debug messages in response payloads. Be aware that sensitive
 	// data may be exposed, depending on your implementation of Fosite. Such sensitive data might include database error
 	// codes or other information. Proceed with caution!
+	//
+	// Deprecated: Use DebugPolicy instead, which takes precedence if set.
 	SendDebugMessagesToClients bool
 
 	// MinParameterEntropy controls the minimum size of state and nonce parameters. Defaults to fosite.MinParameterEntropy.
@@ -116,14 +196,218 @@ type Fosite struct {
 	// ClientAuthenticationStrategy provides an extension point to plug a strategy to authenticate clients
 	ClientAuthenticationStrategy ClientAuthenticationStrategy
 
+	// ClientAuthenticationHandlers is the chain of client authentication methods tried by
+	// DefaultClientAuthenticationStrategy, in order. Defaults to DefaultClientAuthenticationHandlers().
+	ClientAuthenticationHandlers ClientAuthenticationHandlers
+
 	ResponseModeHandlerExtension ResponseModeHandler
 
 	// MessageCatalog is the catalog of messages used for i18n
 	MessageCatalog i18n.MessageCatalog
+
+	// PushedAuthorizeRequestURIPrefix is the prefix of request_uri values issued for pushed authorization requests.
+	// Defaults to fosite.PushedAuthorizeRequestURIPrefix.
+	PushedAuthorizeRequestURIPrefix string
+
+	// EnforcePushedAuthorize, if set to true, requires all authorization requests to be pushed to the pushed
+	// authorization request endpoint first.
+	EnforcePushedAuthorize bool
+
+	// DPoPStrategy enables DPoP (https://tools.ietf.org/html/rfc9449) if set. It controls how nonces are handled and
+	// how replayed proofs are detected.
+	DPoPStrategy DPoPStrategy
+
+	// DPoPProofLifespan sets the time frame in which DPoP proofs are accepted. Defaults to fosite.DPoPProofLifespan.
+	DPoPProofLifespan time.Duration
+
+	// TLSClientCertificateBoundAccessTokens, if set to true, binds access tokens to the TLS client certificate
+	// presented at the token endpoint (https://tools.ietf.org/html/rfc8705#section-3).
+	TLSClientCertificateBoundAccessTokens bool
+
+	// JARMStrategy enables JWT secured authorization responses (https://openid.net/specs/oauth-v2-jarm.html) if set.
+	JARMStrategy JARMStrategy
+
+	// EnableJWTSecuredAuthorizationRequests enables request objects (https://tools.ietf.org/html/rfc9101) for all
+	// authorization requests. Only the parameters of the request object are used, parameters sent outside of it are
+	// ignored. If false, request objects are only processed for OpenID Connect requests, and their claims take
+	// precedence over the request parameters.
+	EnableJWTSecuredAuthorizationRequests bool
+
+	// RequireSignedRequestObject, if set to true, requires all authorization requests to be sent as signed request
+	// objects. Implies EnableJWTSecuredAuthorizationRequests.
+	RequireSignedRequestObject bool
+
+	// AuthorizationDetailsTypes maps the authorization details types (https://tools.ietf.org/html/rfc9396) supported
+	// by the authorization server to an optional validator. Requests with authorization details of any other type are
+	// rejected.
+	AuthorizationDetailsTypes map[string]AuthorizationDetailValidator
+
+	// IntrospectionJWTStrategy enables JWT introspection responses (https://tools.ietf.org/html/rfc9701) if set. They
+	// are returned to clients which send "Accept: application/token-introspection+jwt" to the introspection endpoint.
+	IntrospectionJWTStrategy IntrospectionJWTStrategy
+
+	// EventListener is notified of security relevant events, such as issued tokens or failed client authentication.
+	EventListener EventListener
+
+	// Tracer instruments the endpoints with OpenTelemetry spans if set.
+	Tracer trace.Tracer
+
+	// MetricsReporter receives metrics such as the number of issued tokens or failed requests if set.
+	MetricsReporter MetricsReporter
+
+	// ConsentStrategy, if set, remembers the consent given by resource owners so that it is only asked for once.
+	// See ConsentStrategy for details.
+	ConsentStrategy ConsentStrategy
+
+	// IDTokenHintStrategy verifies the id_token_hint of logout requests. It must verify the signature of the ID tokens
+	// issued by this provider, typically using the same JWTStrategy as the OpenID Connect handlers.
+	IDTokenHintStrategy jwt.JWTStrategy
+
+	// SessionStateStrategy, if set, adds the session_state parameter of OpenID Connect Session Management
+	// (https://openid.net/specs/openid-connect-session-1_0.html) to authorize responses.
+	SessionStateStrategy SessionStateStrategy
+
+	// RejectFrontChannelAccessTokens, if set to true, rejects authorization requests with a response type containing
+	// "token", which would return an access token from the authorization endpoint, as required by OAuth 2.1 and
+	// FAPI 2.0.
+	RejectFrontChannelAccessTokens bool
+
+	// RequireSenderConstrainedAccessTokens, if set to true, rejects token requests unless the access token is bound
+	// to a DPoP proof key or to the TLS client certificate. Requires DPoPStrategy or
+	// TLSClientCertificateBoundAccessTokens to be set.
+	RequireSenderConstrainedAccessTokens bool
+
+	// IssuerProvider, if set, provides the issuer published by discovery and returned in the "iss" claim of
+	// introspection responses. It is also returned in the "iss" parameter of authorization responses, see
+	// OmitAuthorizationResponseIssuer.
+	IssuerProvider IssuerProvider
+
+	// StoragePolicy, if set, bounds the time storage operations may take and retries failed operations.
+	StoragePolicy *StoragePolicy
+
+	// SanitationWhiteList are form parameters, for example extension parameters like "tenant", which are kept when
+	// requests are sanitized for storage. Handlers carry them over from the authorize to the token request.
+	SanitationWhiteList []string
+
+	// ErrorWriter, if set, writes the error responses which are not redirected to the client. Defaults to a
+	// DefaultErrorWriter.
+	ErrorWriter ErrorWriter
+
+	// DebugPolicy, if set, decides per request whether error debug messages are included in response payloads, for
+	// example only for first party clients. Defaults to the policy set by SendDebugMessagesToClients.
+	DebugPolicy DebugPolicy
+
+	// TokenQuotaPolicy, if set, is consulted before tokens are issued to limit the active grants of subjects and
+	// clients.
+	TokenQuotaPolicy TokenQuotaPolicy
+
+	// FailedAuthenticationRecorder, if set, is informed about client secret attempts and may lock clients out.
+	FailedAuthenticationRecorder FailedAuthenticationRecorder
+
+	// ClientSecretRehasher, if set, is called with a fresh hash of the client secret after a client authenticated
+	// with a secret for which the Hasher reports NeedsRehash, for example to migrate stored secrets to HasherChain's
+	// default algorithm.
+	ClientSecretRehasher ClientSecretRehasher
+
+	// JSONWebKeyCache, if set, caches the JSON Web Key Sets fetched from the jwks_uri of clients using TTL and
+	// stale-while-revalidate semantics instead of caching them until a key is not found.
+	JSONWebKeyCache JSONWebKeyCache
+
+	// IntrospectionResponseEnricher, if set, adds custom claims to the responses of active introspections.
+	IntrospectionResponseEnricher IntrospectionResponseEnricher
+
+	// IntrospectionPolicy, if set, restricts which tokens a client may introspect, for example to the tokens issued
+	// for its audience, see AudienceRestrictedIntrospectionPolicy. Defaults to allowing all tokens.
+	IntrospectionPolicy IntrospectionPolicy
+
+	// GrantStorage, if set, enables grant management
+	// (https://openid.net/specs/fapi-grant-management.html). Authorize requests may then create, merge or replace
+	// grants using the grant_management_action and grant_id parameters and the grant_id is returned by the token
+	// endpoint. See GetGrant and RevokeGrant for the grant management endpoint.
+	GrantStorage GrantStorage
+
+	// GrantManagementActionRequired, if set to true, requires all authorize requests to set the
+	// grant_management_action parameter.
+	GrantManagementActionRequired bool
+
+	// OmitAuthorizationResponseIssuer, if set to true, does not add the "iss" parameter
+	// (https://tools.ietf.org/html/rfc9207) to authorization responses. By default it is added whenever the issuer
+	// is known.
+	OmitAuthorizationResponseIssuer bool
+
+	// RequireAuthorizationResponseIssuer, if set to true, rejects authorization requests if the issuer for the "iss"
+	// parameter of the authorization response can not be determined, for example because no IssuerProvider is set.
+	RequireAuthorizationResponseIssuer bool
+
+	// RedirectURIMatcher matches the redirect_uri of authorization requests against the registered redirect URIs.
+	// Defaults to ExactRedirectURIMatcher, use LoopbackRedirectURIMatcher to let native apps use any port with a
+	// registered loopback redirect URI.
+	RedirectURIMatcher RedirectURIMatcher
+
+	// SubjectIdentifierAlgorithms computes the "sub" value of introspection responses for the client the token was
+	// issued to. The subject types are advertised as subject_types_supported. If nil, the subject of the session is
+	// returned to all clients.
+	SubjectIdentifierAlgorithms SubjectIdentifierAlgorithms
+
+	// OfflineAccessPolicy, if set, decides whether the offline_access scope of OpenID Connect authorization
+	// requests is honored, e.g. ConsentRequiredOfflineAccessPolicy. If nil, offline_access is treated like any other
+	// scope. Set oauth2.AuthorizeExplicitGrantHandler.RequireOfflineAccess as well, so that refresh tokens are only
+	// issued if offline_access was granted.
+	OfflineAccessPolicy OfflineAccessPolicy
+
+	// AuthorizeRequestValidators are run in order after authorization requests have been parsed and validated.
+	AuthorizeRequestValidators AuthorizeRequestValidators
+
+	// AccessRequestMiddlewares are called in order by NewAccessRequest and NewAccessResponse, see
+	// AccessRequestMiddleware.
+	AccessRequestMiddlewares AccessRequestMiddlewares
+
+	// ResponseTypeRegistry, if set, defines the supported response types and the AuthorizeEndpointHandlers
+	// responsible for each of them, see ResponseTypeRegistry.
+	ResponseTypeRegistry *ResponseTypeRegistry
 }
 
 const MinParameterEntropy = 8
 
+// PushedAuthorizeRequestURIPrefix is the default prefix of request_uri values as recommended by
+// https://tools.ietf.org/html/rfc9126#section-2.2
+const PushedAuthorizeRequestURIPrefix = "urn:ietf:params:oauth:request_uri:"
+
+// GetPushedAuthorizeRequestURIPrefix returns PushedAuthorizeRequestURIPrefix if set. Defaults to
+// fosite.PushedAuthorizeRequestURIPrefix.
+func (f *Fosite) GetPushedAuthorizeRequestURIPrefix() string {
+	if f.PushedAuthorizeRequestURIPrefix == "" {
+		return PushedAuthorizeRequestURIPrefix
+	}
+	return f.PushedAuthorizeRequestURIPrefix
+}
+
+var defaultDPoPStrategy = &DefaultDPoPStrategy{}
+
+// GetDPoPStrategy returns DPoPStrategy if set. Defaults to a DefaultDPoPStrategy which does not require nonces.
+func (f *Fosite) GetDPoPStrategy() DPoPStrategy {
+	if f.DPoPStrategy == nil {
+		return defaultDPoPStrategy
+	}
+	return f.DPoPStrategy
+}
+
+// GetDPoPProofLifespan returns DPoPProofLifespan if set. Defaults to fosite.DPoPProofLifespan.
+func (f *Fosite) GetDPoPProofLifespan() time.Duration {
+	if f.DPoPProofLifespan == 0 {
+		return DPoPProofLifespan
+	}
+	return f.DPoPProofLifespan
+}
+
+// GetEventListener returns EventListener if set. Defaults to a NoopEventListener.
+func (f *Fosite) GetEventListener() EventListener {
+	if f.EventListener == nil {
+		return NoopEventListener{}
+	}
+	return f.EventListener
+}
+
 // GetMinParameterEntropy returns MinParameterEntropy if set. Defaults to fosite.MinParameterEntropy.
 func (f *Fosite) GetMinParameterEntropy() int {
 	if f.MinParameterEntropy == 0 {
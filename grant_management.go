@@ -0,0 +1,254 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ory/x/errorsx"
+	"github.com/pborman/uuid"
+	"github.com/pkg/errors"
+)
+
+const (
+	// GrantManagementActionCreate asks the authorization server to create a new grant, see
+	// https://openid.net/specs/fapi-grant-management.html#section-4.1
+	GrantManagementActionCreate = "create"
+
+	// GrantManagementActionMerge asks the authorization server to add the permissions of the request to the grant
+	// referenced by grant_id.
+	GrantManagementActionMerge = "merge"
+
+	// GrantManagementActionReplace asks the authorization server to replace the permissions of the grant referenced
+	// by grant_id with the permissions of the request.
+	GrantManagementActionReplace = "replace"
+)
+
+// Grant is the consolidated set of permissions a resource owner granted to a client, see
+// https://openid.net/specs/fapi-grant-management.html
+type Grant struct {
+	ID                   string               `json:"grant_id"`
+	ClientID             string               `json:"client_id"`
+	Subject              string               `json:"subject"`
+	Scopes               Arguments            `json:"scopes"`
+	Audience             Arguments            `json:"audience"`
+	AuthorizationDetails AuthorizationDetails `json:"authorization_details,omitempty"`
+	CreatedAt            time.Time            `json:"created_at"`
+	UpdatedAt            time.Time            `json:"updated_at"`
+
+	// RequestIDs are the IDs of the authorize requests which created or changed the grant. Tokens are issued with
+	// the ID of the authorize request, so they can be used to revoke the tokens of a grant.
+	RequestIDs []string `json:"request_ids"`
+}
+
+// GrantStorage persists the grants of grant management.
+type GrantStorage interface {
+	// GetGrant returns the grant or ErrNotFound.
+	GetGrant(ctx context.Context, id string) (*Grant, error)
+
+	// SetGrant creates or replaces the grant with grant.ID.
+	SetGrant(ctx context.Context, grant *Grant) error
+
+	// RevokeGrant removes the grant and revokes the access and refresh tokens issued for its RequestIDs.
+	RevokeGrant(ctx context.Context, id string) error
+}
+
+// validateAuthorizeGrantManagement validates the grant_management_action and grant_id parameters, see
+// https://openid.net/specs/fapi-grant-management.html#section-4.1. They are ignored if no GrantStorage is set.
+func (f *Fosite) validateAuthorizeGrantManagement(ctx context.Context, request *AuthorizeRequest) error {
+	if f.GrantStorage == nil {
+		return nil
+	}
+
+	action := request.Form.Get("grant_management_action")
+	grantID := request.Form.Get("grant_id")
+	switch action {
+	case "":
+		if f.GrantManagementActionRequired {
+			return errorsx.WithStack(ErrInvalidRequest.WithHint("The 'grant_management_action' request parameter is required."))
+		} else if grantID != "" {
+			return errorsx.WithStack(ErrInvalidRequest.WithHint("The 'grant_id' request parameter requires the 'grant_management_action' request parameter."))
+		}
+		return nil
+	case GrantManagementActionCreate:
+		if grantID != "" {
+			return errorsx.WithStack(ErrInvalidRequest.WithHintf("The 'grant_id' request parameter must not be set when using grant_management_action '%s'.", action))
+		}
+	case GrantManagementActionMerge, GrantManagementActionReplace:
+		if grantID == "" {
+			return errorsx.WithStack(ErrInvalidRequest.WithHintf("The 'grant_id' request parameter must be set when using grant_management_action '%s'.", action))
+		} else if _, err := f.getClientGrant(ctx, request.GetClient(), grantID); err != nil {
+			return err
+		}
+	default:
+		return errorsx.WithStack(ErrInvalidRequest.WithHintf("The grant_management_action '%s' is not supported.", action))
+	}
+
+	// The grant_id is kept with the authorize request so that it can be returned by the token endpoint.
+	request.PersistedParameters = append(append([]string{}, request.PersistedParameters...), "grant_id")
+	return nil
+}
+
+func (f *Fosite) getClientGrant(ctx context.Context, client Client, grantID string) (*Grant, error) {
+	var grant *Grant
+	err := StorageOperation(ctx, "GetGrant", func(ctx context.Context) (err error) {
+		grant, err = f.GrantStorage.GetGrant(ctx, grantID)
+		return err
+	})
+	if errors.Is(err, ErrNotFound) {
+		return nil, errorsx.WithStack(ErrInvalidGrantID.WithHint("The grant does not exist.").WithWrap(err).WithDebug(err.Error()))
+	} else if err != nil {
+		return nil, errorsx.WithStack(ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	} else if grant.ClientID != client.GetID() {
+		return nil, errorsx.WithStack(ErrInvalidGrantID.WithHint("The grant does not belong to the OAuth 2.0 Client."))
+	}
+	return grant, nil
+}
+
+// prepareGrant returns the grant changed by the authorize request or nil if the request does not use grant
+// management. The grant_id of a grant which is created is added to the form of the request, before the authorize
+// endpoint handlers persist it.
+func (f *Fosite) prepareGrant(ctx context.Context, ar AuthorizeRequester, session Session) (*Grant, error) {
+	if f.GrantStorage == nil {
+		return nil, nil
+	}
+
+	now := time.Now().UTC()
+	switch action := ar.GetRequestForm().Get("grant_management_action"); action {
+	case GrantManagementActionCreate:
+		grant := &Grant{ID: uuid.New(), ClientID: ar.GetClient().GetID(), Subject: session.GetSubject(), CreatedAt: now}
+		ar.GetRequestForm().Set("grant_id", grant.ID)
+		return grant, nil
+	case GrantManagementActionMerge, GrantManagementActionReplace:
+		grant, err := f.getClientGrant(ctx, ar.GetClient(), ar.GetRequestForm().Get("grant_id"))
+		if err != nil {
+			return nil, err
+		} else if grant.Subject != session.GetSubject() {
+			return nil, errorsx.WithStack(ErrInvalidGrantID.WithHint("The grant was given by another resource owner."))
+		}
+
+		if action == GrantManagementActionReplace {
+			grant.Scopes = Arguments{}
+			grant.Audience = Arguments{}
+			grant.AuthorizationDetails = nil
+		}
+		return grant, nil
+	}
+
+	return nil, nil
+}
+
+// recordGrant adds the scopes, audiences and authorization details granted in the request to the grant.
+func (f *Fosite) recordGrant(ctx context.Context, ar AuthorizeRequester, grant *Grant) error {
+	for _, scope := range ar.GetGrantedScopes() {
+		grant.Scopes = appendUnique(grant.Scopes, scope)
+	}
+	for _, audience := range ar.GetGrantedAudience() {
+		grant.Audience = appendUnique(grant.Audience, audience)
+	}
+	for _, detail := range ar.GetAuthorizationDetails() {
+		if !grant.AuthorizationDetails.Contains(detail) {
+			grant.AuthorizationDetails = append(grant.AuthorizationDetails, detail)
+		}
+	}
+	grant.RequestIDs = appendUnique(grant.RequestIDs, ar.GetID())
+	grant.UpdatedAt = time.Now().UTC()
+
+	return StorageOperation(ctx, "SetGrant", func(ctx context.Context) error {
+		return f.GrantStorage.SetGrant(ctx, grant)
+	})
+}
+
+// GetGrant returns the grant with the given ID if it belongs to the client, which is typically identified by the
+// access token sent to the grant management endpoint. It returns ErrNotFound if the grant does not exist or belongs
+// to another client.
+func (f *Fosite) GetGrant(ctx context.Context, client Client, grantID string) (*Grant, error) {
+	ctx = f.withStoragePolicy(ctx)
+	ctx = ensureRequestID(ctx, nil)
+	grant, err := f.getGrant(ctx, client, grantID)
+	return grant, attachRequestID(ctx, err)
+}
+
+func (f *Fosite) getGrant(ctx context.Context, client Client, grantID string) (*Grant, error) {
+	if f.GrantStorage == nil {
+		return nil, errorsx.WithStack(ErrNotFound.WithHint("Grant management is not enabled."))
+	}
+
+	grant, err := f.getClientGrant(ctx, client, grantID)
+	if errors.Is(err, ErrInvalidGrantID) {
+		return nil, errorsx.WithStack(ErrNotFound.WithWrap(err).WithDebug(err.Error()))
+	}
+	return grant, err
+}
+
+// RevokeGrant revokes the grant with the given ID and the tokens issued for it if the grant belongs to the client,
+// see https://openid.net/specs/fapi-grant-management.html#section-6.4
+func (f *Fosite) RevokeGrant(ctx context.Context, client Client, grantID string) error {
+	ctx = f.withStoragePolicy(ctx)
+	ctx = ensureRequestID(ctx, nil)
+	grant, err := f.GetGrant(ctx, client, grantID)
+	if err != nil {
+		return err
+	}
+
+	if err := StorageOperation(ctx, "RevokeGrant", func(ctx context.Context) error {
+		return f.GrantStorage.RevokeGrant(ctx, grant.ID)
+	}); err != nil {
+		return attachRequestID(ctx, errorsx.WithStack(ErrServerError.WithWrap(err).WithDebug(err.Error())))
+	}
+	return nil
+}
+
+type grantScope struct {
+	Scope    string    `json:"scope"`
+	Resource Arguments `json:"resource,omitempty"`
+}
+
+type grantResponse struct {
+	Scopes               []grantScope         `json:"scopes,omitempty"`
+	AuthorizationDetails AuthorizationDetails `json:"authorization_details,omitempty"`
+}
+
+// WriteGrant writes the response of the grant management query endpoint, see
+// https://openid.net/specs/fapi-grant-management.html#section-6.3
+func (f *Fosite) WriteGrant(ctx context.Context, rw http.ResponseWriter, grant *Grant) {
+	response := grantResponse{AuthorizationDetails: grant.AuthorizationDetails}
+	if len(grant.Scopes) > 0 || len(grant.Audience) > 0 {
+		response.Scopes = []grantScope{{Scope: strings.Join(grant.Scopes, " "), Resource: grant.Audience}}
+	}
+
+	js, err := json.Marshal(response)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	rw.Header().Set("Cache-Control", "no-store")
+	rw.Header().Set("Pragma", "no-cache")
+	rw.WriteHeader(http.StatusOK)
+	_, _ = rw.Write(js)
+}
@@ -0,0 +1,208 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/storage"
+)
+
+func TestGrantManagement(t *testing.T) {
+	store := storage.NewExampleStore()
+	config := &compose.Config{GrantStorage: store}
+	f := compose.Compose(
+		config,
+		store,
+		compose.NewOAuth2HMACStrategy(config, []byte("some-secret-thats-random-some-secret-thats-random-"), nil),
+		nil,
+		compose.OAuth2AuthorizeExplicitFactory,
+		compose.OAuth2RefreshTokenGrantFactory,
+		compose.OAuth2TokenIntrospectionFactory,
+	)
+	ctx := context.Background()
+	client := store.Clients["my-client"]
+
+	authorize := func(t *testing.T, subject, scope string, params url.Values) (AuthorizeResponder, error) {
+		query := url.Values{
+			"client_id":     {"my-client"},
+			"response_type": {"code"},
+			"redirect_uri":  {"http://localhost:3846/callback"},
+			"state":         {"some-random-state"},
+			"scope":         {scope},
+		}
+		for k, v := range params {
+			query[k] = v
+		}
+		ar, err := f.NewAuthorizeRequest(ctx, httptest.NewRequest("GET", "/auth?"+query.Encode(), nil))
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range ar.GetRequestedScopes() {
+			ar.GrantScope(s)
+		}
+		return f.NewAuthorizeResponse(ctx, ar, &DefaultSession{Subject: subject})
+	}
+
+	token := func(t *testing.T, code string) AccessResponder {
+		r := httptest.NewRequest("POST", "/token", strings.NewReader(url.Values{
+			"grant_type":   {"authorization_code"},
+			"code":         {code},
+			"redirect_uri": {"http://localhost:3846/callback"},
+			"grant_id":     {"chosen-by-the-client"},
+		}.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.SetBasicAuth("my-client", "foobar")
+		ar, err := f.NewAccessRequest(ctx, r, new(DefaultSession))
+		require.NoError(t, err)
+		resp, err := f.NewAccessResponse(ctx, ar)
+		require.NoError(t, err)
+		return resp
+	}
+
+	resp, err := authorize(t, "peter", "fosite", url.Values{"grant_management_action": {GrantManagementActionCreate}})
+	require.NoError(t, err)
+	created := token(t, resp.GetParameters().Get("code"))
+	grantID, _ := created.GetExtra("grant_id").(string)
+	require.NotEmpty(t, grantID)
+
+	grant, err := f.GetGrant(ctx, client, grantID)
+	require.NoError(t, err)
+	assert.Equal(t, Arguments{"fosite"}, grant.Scopes)
+	assert.Equal(t, "peter", grant.Subject)
+
+	resp, err = authorize(t, "peter", "photos", url.Values{"grant_management_action": {GrantManagementActionMerge}, "grant_id": {grantID}})
+	require.NoError(t, err)
+	assert.Equal(t, grantID, token(t, resp.GetParameters().Get("code")).GetExtra("grant_id"))
+	grant, err = f.GetGrant(ctx, client, grantID)
+	require.NoError(t, err)
+	assert.Equal(t, Arguments{"fosite", "photos"}, grant.Scopes)
+
+	resp, err = authorize(t, "peter", "openid", url.Values{"grant_management_action": {GrantManagementActionReplace}, "grant_id": {grantID}})
+	require.NoError(t, err)
+	replaced := token(t, resp.GetParameters().Get("code"))
+	grant, err = f.GetGrant(ctx, client, grantID)
+	require.NoError(t, err)
+	assert.Equal(t, Arguments{"openid"}, grant.Scopes)
+
+	rec := httptest.NewRecorder()
+	f.WriteGrant(ctx, rec, grant)
+	assert.JSONEq(t, `{"scopes":[{"scope":"openid"}]}`, rec.Body.String())
+
+	for k, c := range []struct {
+		d         string
+		subject   string
+		params    url.Values
+		expectErr error
+	}{
+		{
+			d:         "should fail because the action is not supported",
+			params:    url.Values{"grant_management_action": {"query"}, "grant_id": {grantID}},
+			expectErr: ErrInvalidRequest,
+		},
+		{
+			d:         "should fail because merge requires a grant_id",
+			params:    url.Values{"grant_management_action": {GrantManagementActionMerge}},
+			expectErr: ErrInvalidRequest,
+		},
+		{
+			d:         "should fail because create must not set a grant_id",
+			params:    url.Values{"grant_management_action": {GrantManagementActionCreate}, "grant_id": {grantID}},
+			expectErr: ErrInvalidRequest,
+		},
+		{
+			d:         "should fail because the grant_id requires an action",
+			params:    url.Values{"grant_id": {grantID}},
+			expectErr: ErrInvalidRequest,
+		},
+		{
+			d:         "should fail because the grant does not exist",
+			params:    url.Values{"grant_management_action": {GrantManagementActionMerge}, "grant_id": {"unknown"}},
+			expectErr: ErrInvalidGrantID,
+		},
+		{
+			d:         "should fail because the grant was given by another resource owner",
+			subject:   "alice",
+			params:    url.Values{"grant_management_action": {GrantManagementActionReplace}, "grant_id": {grantID}},
+			expectErr: ErrInvalidGrantID,
+		},
+		{
+			d: "should pass without grant management",
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			subject := c.subject
+			if subject == "" {
+				subject = "peter"
+			}
+			_, err := authorize(t, subject, "fosite", c.params)
+			if c.expectErr != nil {
+				require.EqualError(t, err, c.expectErr.Error())
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+
+	t.Run("case=should not return the grant to another client", func(t *testing.T) {
+		_, err := f.GetGrant(ctx, store.Clients["encoded:client"], grantID)
+		require.EqualError(t, err, ErrNotFound.Error())
+		require.EqualError(t, f.RevokeGrant(ctx, store.Clients["encoded:client"], grantID), ErrNotFound.Error())
+	})
+
+	t.Run("case=should revoke the grant and its tokens", func(t *testing.T) {
+		_, _, err := f.IntrospectToken(ctx, replaced.GetAccessToken(), AccessToken, new(DefaultSession))
+		require.NoError(t, err)
+
+		require.NoError(t, f.RevokeGrant(ctx, client, grantID))
+		_, err = f.GetGrant(ctx, client, grantID)
+		require.EqualError(t, err, ErrNotFound.Error())
+
+		for _, resp := range []AccessResponder{created, replaced} {
+			_, _, err = f.IntrospectToken(ctx, resp.GetAccessToken(), AccessToken, new(DefaultSession))
+			require.Error(t, err)
+		}
+	})
+
+	t.Run("case=should require an action", func(t *testing.T) {
+		f.(*Fosite).GrantManagementActionRequired = true
+		defer func() { f.(*Fosite).GrantManagementActionRequired = false }()
+
+		_, err := authorize(t, "peter", "fosite", nil)
+		require.EqualError(t, err, ErrInvalidRequest.Error())
+
+		metadata := f.NewDiscoveryMetadata(ctx, DiscoveryConfiguration{GrantManagementEndpoint: "https://www.example.com/grants"})
+		assert.Equal(t, []string{GrantManagementActionCreate, GrantManagementActionMerge, GrantManagementActionReplace}, metadata.GrantManagementActionsSupported)
+		assert.True(t, metadata.GrantManagementActionRequired)
+		assert.Equal(t, "https://www.example.com/grants", metadata.GrantManagementEndpoint)
+	})
+}
@@ -40,6 +40,8 @@ type AuthorizeEndpointHandler interface {
 	HandleAuthorizeEndpointRequest(ctx context.Context, requester AuthorizeRequester, responder AuthorizeResponder) error
 }
 
+// TokenEndpointHandler handles the grant types of token endpoint requests. Requests no handler is responsible for
+// are rejected with ErrUnsupportedGrantType. See the handler/extension package for implementing extension grants.
 type TokenEndpointHandler interface {
 	// PopulateTokenEndpointResponse is responsible for setting return values and should only be executed if
 	// the handler's HandleTokenEndpointRequest did not return ErrUnknownRequest.
@@ -59,6 +61,40 @@ type TokenEndpointHandler interface {
 	CanHandleTokenEndpointRequest(requester AccessRequester) bool
 }
 
+// GrantTypeHandler is implemented by TokenEndpointHandlers which can be registered once per grant type, for example
+// the handlers of extension grants.
+type GrantTypeHandler interface {
+	TokenEndpointHandler
+
+	// HandledGrantType returns the grant type the handler is responsible for.
+	HandledGrantType() string
+}
+
+// DeviceEndpointHandler is the interface that allows handling device authorization requests as defined in
+// https://tools.ietf.org/html/rfc8628#section-3.1
+type DeviceEndpointHandler interface {
+	// HandleDeviceEndpointRequest handles a device authorization endpoint request. If the handler is not
+	// responsible for the request, it must return nil and NOT modify session nor responder neither requester.
+	HandleDeviceEndpointRequest(ctx context.Context, requester DeviceRequester, responder DeviceResponder) error
+}
+
+// BackchannelAuthenticationEndpointHandler is the interface that allows handling backchannel authentication
+// requests as defined in https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html
+type BackchannelAuthenticationEndpointHandler interface {
+	// HandleBackchannelAuthenticationEndpointRequest handles a backchannel authentication endpoint request. If the
+	// handler is not responsible for the request, it must return nil and NOT modify session nor responder neither
+	// requester.
+	HandleBackchannelAuthenticationEndpointRequest(ctx context.Context, requester BackchannelAuthenticationRequester, responder BackchannelAuthenticationResponder) error
+}
+
+// PushedAuthorizeEndpointHandler is the interface that allows handling pushed authorization requests as defined in
+// https://tools.ietf.org/html/rfc9126#section-2
+type PushedAuthorizeEndpointHandler interface {
+	// HandlePushedAuthorizeEndpointRequest handles a pushed authorization endpoint request. If the handler is not
+	// responsible for the request, it must return nil and NOT modify session nor responder neither requester.
+	HandlePushedAuthorizeEndpointRequest(ctx context.Context, requester AuthorizeRequester, responder PushedAuthorizeResponder) error
+}
+
 // RevocationHandler is the interface that allows token revocation for an OAuth2.0 provider.
 // https://tools.ietf.org/html/rfc7009
 //
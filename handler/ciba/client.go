@@ -0,0 +1,76 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package ciba
+
+import (
+	"github.com/ory/fosite"
+)
+
+const (
+	// DeliveryModePoll lets the client poll the token endpoint until the end user has been authenticated.
+	DeliveryModePoll = "poll"
+
+	// DeliveryModePing notifies the client once the end user has been authenticated, after which the client calls
+	// the token endpoint.
+	DeliveryModePing = "ping"
+
+	// DeliveryModePush delivers the tokens to the client notification endpoint once the end user has been
+	// authenticated.
+	DeliveryModePush = "push"
+)
+
+// Client represents a client capable of using the CIBA grant, see
+// https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#registration
+type Client interface {
+	fosite.Client
+
+	// GetBackchannelTokenDeliveryMode returns one of "poll", "ping", or "push".
+	GetBackchannelTokenDeliveryMode() string
+
+	// GetBackchannelClientNotificationEndpoint returns the endpoint to which the OpenID Provider posts a
+	// notification after a successful or failed end user authentication. Required for the ping and push modes.
+	GetBackchannelClientNotificationEndpoint() string
+
+	// GetBackchannelUserCodeParameter returns true if the client supports the user_code parameter, in which case
+	// it is required in backchannel authentication requests.
+	GetBackchannelUserCodeParameter() bool
+}
+
+// DefaultClient is a simple default implementation of the Client interface.
+type DefaultClient struct {
+	*fosite.DefaultClient
+	BackchannelTokenDeliveryMode          string `json:"backchannel_token_delivery_mode"`
+	BackchannelClientNotificationEndpoint string `json:"backchannel_client_notification_endpoint,omitempty"`
+	BackchannelUserCodeParameter          bool   `json:"backchannel_user_code_parameter,omitempty"`
+}
+
+func (c *DefaultClient) GetBackchannelTokenDeliveryMode() string {
+	return c.BackchannelTokenDeliveryMode
+}
+
+func (c *DefaultClient) GetBackchannelClientNotificationEndpoint() string {
+	return c.BackchannelClientNotificationEndpoint
+}
+
+func (c *DefaultClient) GetBackchannelUserCodeParameter() bool {
+	return c.BackchannelUserCodeParameter
+}
@@ -0,0 +1,541 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package ciba
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/ory/x/errorsx"
+	"github.com/pkg/errors"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/oauth2"
+	"github.com/ory/fosite/handler/openid"
+	"github.com/ory/fosite/storage"
+)
+
+const grantTypeCIBA = "urn:openid:params:grant-type:ciba"
+
+// AuthRequestIDClaim is the ID token claim carrying the auth_req_id in the push delivery mode.
+const AuthRequestIDClaim = "urn:openid:params:jwt:claim:auth_req_id"
+
+// DefaultPollingInterval is the minimum amount of time the client should wait between polling requests if no
+// interval is configured.
+const DefaultPollingInterval = time.Second * 5
+
+// SlowDownIntervalIncrement is added to the polling interval each time the client is told to slow down.
+const SlowDownIntervalIncrement = time.Second * 5
+
+// storedParameters are the backchannel authentication request parameters which are kept alongside the pending
+// request, so that they are available when authenticating the end user and notifying the client.
+var storedParameters = []string{
+	"client_notification_token",
+	"acr_values",
+	"login_hint_token",
+	"id_token_hint",
+	"login_hint",
+	"binding_message",
+	"user_code",
+	"requested_expiry",
+}
+
+// Handler implements Client Initiated Backchannel Authentication as defined in
+// https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html. It handles the
+// backchannel authentication endpoint as well as the CIBA grant at the token endpoint and offers helpers for
+// resolving the request once the end user has been authenticated.
+type Handler struct {
+	AuthRequestIDStrategy AuthRequestIDStrategy
+	CIBAStorage           CIBAStorage
+	AccessTokenStrategy   oauth2.AccessTokenStrategy
+	RefreshTokenStrategy  oauth2.RefreshTokenStrategy
+	AccessTokenStorage    oauth2.AccessTokenStorage
+	RefreshTokenStorage   oauth2.RefreshTokenStorage
+
+	// IDTokenHandleHelper issues ID tokens if the openid scope has been granted. ID tokens are not issued if nil.
+	*openid.IDTokenHandleHelper
+
+	// Notifier delivers ping and push notifications. Required if clients use the ping or push delivery mode.
+	Notifier Notifier
+
+	// AccessTokenLifespan defines the lifetime of an access token.
+	AccessTokenLifespan time.Duration
+
+	// RefreshTokenLifespan defines the lifetime of a refresh token.
+	RefreshTokenLifespan time.Duration
+
+	// AuthRequestIDLifespan defines the lifetime of the auth_req_id. Clients may request a shorter lifetime using
+	// the requested_expiry parameter.
+	AuthRequestIDLifespan time.Duration
+
+	// PollingInterval is the minimum amount of time that the client should wait between polling requests to the
+	// token endpoint. Defaults to DefaultPollingInterval.
+	PollingInterval time.Duration
+
+	RefreshTokenScopes []string
+}
+
+func (c *Handler) pollingInterval() time.Duration {
+	if c.PollingInterval <= 0 {
+		return DefaultPollingInterval
+	}
+	return c.PollingInterval
+}
+
+func (c *Handler) getClient(client fosite.Client) (Client, error) {
+	if !client.GetGrantTypes().Has(grantTypeCIBA) {
+		return nil, errorsx.WithStack(fosite.ErrUnauthorizedClient.WithHintf("The OAuth 2.0 Client is not allowed to use authorization grant \"%s\".", grantTypeCIBA))
+	}
+
+	cc, ok := client.(Client)
+	if !ok {
+		return nil, errorsx.WithStack(fosite.ErrUnauthorizedClient.WithHint("The OAuth 2.0 Client is not registered for Client Initiated Backchannel Authentication."))
+	}
+
+	switch cc.GetBackchannelTokenDeliveryMode() {
+	case DeliveryModePoll:
+	case DeliveryModePing, DeliveryModePush:
+		if cc.GetBackchannelClientNotificationEndpoint() == "" {
+			return nil, errorsx.WithStack(fosite.ErrUnauthorizedClient.WithHintf("The OAuth 2.0 Client uses the token delivery mode '%s' but has no client notification endpoint registered.", cc.GetBackchannelTokenDeliveryMode()))
+		}
+	default:
+		return nil, errorsx.WithStack(fosite.ErrUnauthorizedClient.WithHintf("The OAuth 2.0 Client uses the unsupported token delivery mode '%s'.", cc.GetBackchannelTokenDeliveryMode()))
+	}
+	return cc, nil
+}
+
+// HandleBackchannelAuthenticationEndpointRequest implements
+// https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#auth_request_validation
+func (c *Handler) HandleBackchannelAuthenticationEndpointRequest(ctx context.Context, requester fosite.BackchannelAuthenticationRequester, responder fosite.BackchannelAuthenticationResponder) error {
+	client, err := c.getClient(requester.GetClient())
+	if err != nil {
+		return err
+	}
+
+	form := requester.GetRequestForm()
+	if !requester.GetRequestedScopes().Has("openid") {
+		return errorsx.WithStack(fosite.ErrInvalidScope.WithHint("The backchannel authentication request must contain the 'openid' scope."))
+	}
+
+	var hints int
+	for _, hint := range []string{"login_hint_token", "id_token_hint", "login_hint"} {
+		if form.Get(hint) != "" {
+			hints++
+		}
+	}
+	if hints != 1 {
+		return errorsx.WithStack(fosite.ErrInvalidRequest.WithHint("Exactly one of the parameters 'login_hint_token', 'id_token_hint', or 'login_hint' must be set."))
+	}
+
+	if client.GetBackchannelTokenDeliveryMode() != DeliveryModePoll && form.Get("client_notification_token") == "" {
+		return errorsx.WithStack(fosite.ErrInvalidRequest.WithHintf("The client_notification_token parameter is required when using the token delivery mode '%s'.", client.GetBackchannelTokenDeliveryMode()))
+	}
+
+	if client.GetBackchannelUserCodeParameter() && form.Get("user_code") == "" {
+		return errorsx.WithStack(fosite.ErrMissingUserCode)
+	}
+
+	lifespan := c.AuthRequestIDLifespan
+	if requested := form.Get("requested_expiry"); requested != "" {
+		seconds, err := strconv.ParseInt(requested, 10, 64)
+		if err != nil || seconds <= 0 {
+			return errorsx.WithStack(fosite.ErrInvalidRequest.WithHint("The requested_expiry parameter must be a positive integer."))
+		} else if expiry := time.Duration(seconds) * time.Second; expiry < lifespan {
+			lifespan = expiry
+		}
+	}
+
+	authReqID, signature, err := c.AuthRequestIDStrategy.GenerateAuthRequestID(ctx, requester)
+	if err != nil {
+		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	expiresAt := time.Now().UTC().Add(lifespan).Round(time.Second)
+	requester.GetSession().SetExpiresAt(fosite.AuthRequestID, expiresAt)
+
+	if err := fosite.StorageOperation(ctx, "CreateBackchannelAuthenticationSession", func(ctx context.Context) error {
+		return c.CIBAStorage.CreateBackchannelAuthenticationSession(ctx, signature, requester.Sanitize(storedParameters))
+	}); err != nil {
+		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	responder.SetAuthRequestID(authReqID)
+	responder.SetExpiresIn(time.Duration(expiresAt.UnixNano() - time.Now().UTC().UnixNano()))
+	if client.GetBackchannelTokenDeliveryMode() != DeliveryModePush {
+		responder.SetInterval(c.pollingInterval())
+	}
+	return nil
+}
+
+// HandleTokenEndpointRequest implements
+// https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#token_request
+func (c *Handler) HandleTokenEndpointRequest(ctx context.Context, request fosite.AccessRequester) error {
+	if !c.CanHandleTokenEndpointRequest(request) {
+		return errorsx.WithStack(fosite.ErrUnknownRequest)
+	}
+
+	client, err := c.getClient(request.GetClient())
+	if err != nil {
+		return err
+	} else if client.GetBackchannelTokenDeliveryMode() == DeliveryModePush {
+		return errorsx.WithStack(fosite.ErrUnauthorizedClient.WithHint("OAuth 2.0 Clients using the push token delivery mode must not call the token endpoint."))
+	}
+
+	authReqID := request.GetRequestForm().Get("auth_req_id")
+	if authReqID == "" {
+		return errorsx.WithStack(fosite.ErrInvalidRequest.WithHintf("The auth_req_id request parameter must be set when using grant_type of '%s'.", grantTypeCIBA))
+	}
+
+	signature := c.AuthRequestIDStrategy.AuthRequestIDSignature(authReqID)
+	var cibaRequest fosite.Requester
+	err = fosite.StorageOperation(ctx, "GetBackchannelAuthenticationSession", func(ctx context.Context) (err error) {
+		cibaRequest, err = c.CIBAStorage.GetBackchannelAuthenticationSession(ctx, signature, request.GetSession())
+		return err
+	})
+	if errors.Is(err, fosite.ErrNotFound) {
+		return errorsx.WithStack(fosite.ErrInvalidGrant.WithWrap(err).WithDebug(err.Error()))
+	} else if errors.Is(err, fosite.ErrInvalidatedAuthRequestID) {
+		return errorsx.WithStack(fosite.ErrInvalidGrant.WithHint("The auth_req_id has already been used."))
+	} else if err != nil && !errors.Is(err, fosite.ErrAuthorizationPending) && !errors.Is(err, fosite.ErrAccessDenied) {
+		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	} else if cibaRequest == nil {
+		return errorsx.WithStack(fosite.ErrServerError.
+			WithHint("Misconfigured code lead to an error that prohibited the OAuth 2.0 Framework from processing this request.").
+			WithDebug("GetBackchannelAuthenticationSession must return a value for \"fosite.Requester\"."))
+	}
+	state := err
+
+	// This needs to happen after store retrieval for the session to be hydrated properly
+	if err := c.AuthRequestIDStrategy.ValidateAuthRequestID(ctx, cibaRequest, authReqID); errors.Is(err, fosite.ErrTokenExpired) {
+		return errorsx.WithStack(fosite.ErrDeviceExpiredToken.WithDescription("The auth_req_id has expired, and the backchannel authentication session has concluded.").WithWrap(err).WithDebug(err.Error()))
+	} else if err != nil {
+		return errorsx.WithStack(fosite.ErrInvalidGrant.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	if cibaRequest.GetClient().GetID() != request.GetClient().GetID() {
+		return errorsx.WithStack(fosite.ErrInvalidGrant.WithHint("The OAuth 2.0 Client ID from this request does not match the one from the backchannel authentication request."))
+	}
+
+	if client.GetBackchannelTokenDeliveryMode() == DeliveryModePoll {
+		now := time.Now().UTC()
+		var lastPolledAt time.Time
+		var interval time.Duration
+		err := fosite.StorageOperation(ctx, "TouchBackchannelAuthenticationSession", func(ctx context.Context) (err error) {
+			lastPolledAt, interval, err = c.CIBAStorage.TouchBackchannelAuthenticationSession(ctx, signature, now)
+			return err
+		})
+		if err != nil {
+			return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+		} else if interval <= 0 {
+			interval = c.pollingInterval()
+		}
+
+		if !lastPolledAt.IsZero() && now.Sub(lastPolledAt) < interval {
+			// The interval MUST be increased by at least 5 seconds for this and all subsequent requests, see
+			// https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.11
+			interval += SlowDownIntervalIncrement
+			if err := fosite.StorageOperation(ctx, "SlowDownBackchannelAuthenticationSession", func(ctx context.Context) error {
+				return c.CIBAStorage.SlowDownBackchannelAuthenticationSession(ctx, signature, interval)
+			}); err != nil {
+				return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+			}
+			return errorsx.WithStack(fosite.ErrSlowDown.WithHintf("The polling interval has been increased to %d seconds.", int64(interval/time.Second)))
+		}
+	}
+
+	if errors.Is(state, fosite.ErrAuthorizationPending) {
+		return errorsx.WithStack(fosite.ErrAuthorizationPending)
+	} else if errors.Is(state, fosite.ErrAccessDenied) {
+		return errorsx.WithStack(fosite.ErrAccessDenied.WithHint("The end user denied the backchannel authentication request."))
+	}
+
+	request.SetRequestedScopes(cibaRequest.GetRequestedScopes())
+	request.SetRequestedAudience(cibaRequest.GetRequestedAudience())
+	request.SetSession(cibaRequest.GetSession().Clone())
+	request.SetID(cibaRequest.GetID())
+	fosite.CarryOverPersistedParameters(request, cibaRequest)
+	c.setTokenLifespans(ctx, request)
+
+	return nil
+}
+
+func (c *Handler) setTokenLifespans(ctx context.Context, request fosite.Requester) {
+	request.GetSession().SetExpiresAt(fosite.AccessToken, time.Now().UTC().Add(fosite.GetAccessTokenLifespan(ctx, c.AccessTokenLifespan)).Round(time.Second))
+	if lifespan := fosite.GetRefreshTokenLifespan(ctx, c.RefreshTokenLifespan); lifespan > -1 {
+		request.GetSession().SetExpiresAt(fosite.RefreshToken, time.Now().UTC().Add(lifespan).Round(time.Second))
+	}
+}
+
+func (c *Handler) canIssueRefreshToken(request fosite.Requester) bool {
+	// Require one of the refresh token scopes, if set.
+	if len(c.RefreshTokenScopes) > 0 && !request.GetGrantedScopes().HasOneOf(c.RefreshTokenScopes...) {
+		return false
+	}
+	// Do not issue a refresh token to clients that cannot use the refresh token grant type.
+	if !request.GetClient().GetGrantTypes().Has("refresh_token") {
+		return false
+	}
+	return true
+}
+
+func (c *Handler) PopulateTokenEndpointResponse(ctx context.Context, requester fosite.AccessRequester, responder fosite.AccessResponder) error {
+	if !c.CanHandleTokenEndpointRequest(requester) {
+		return errorsx.WithStack(fosite.ErrUnknownRequest)
+	}
+
+	authReqID := requester.GetRequestForm().Get("auth_req_id")
+	signature := c.AuthRequestIDStrategy.AuthRequestIDSignature(authReqID)
+	var cibaRequest fosite.Requester
+	err := fosite.StorageOperation(ctx, "GetBackchannelAuthenticationSession", func(ctx context.Context) (err error) {
+		cibaRequest, err = c.CIBAStorage.GetBackchannelAuthenticationSession(ctx, signature, requester.GetSession())
+		return err
+	})
+	if err != nil {
+		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	} else if err := c.AuthRequestIDStrategy.ValidateAuthRequestID(ctx, requester, authReqID); err != nil {
+		return errorsx.WithStack(fosite.ErrInvalidRequest.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	for _, scope := range cibaRequest.GetGrantedScopes() {
+		requester.GrantScope(scope)
+	}
+
+	for _, audience := range cibaRequest.GetGrantedAudience() {
+		requester.GrantAudience(audience)
+	}
+	requester.GrantAuthorizationDetails(cibaRequest.GetAuthorizationDetails())
+
+	return c.issueTokens(ctx, signature, requester, responder)
+}
+
+// issueTokens issues the access, refresh, and ID token for the approved request and invalidates the auth_req_id.
+func (c *Handler) issueTokens(ctx context.Context, signature string, requester fosite.AccessRequester, responder fosite.AccessResponder) error {
+	access, accessSignature, err := c.AccessTokenStrategy.GenerateAccessToken(ctx, requester)
+	if err != nil {
+		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	var refresh, refreshSignature string
+	if c.canIssueRefreshToken(requester) {
+		refresh, refreshSignature, err = c.RefreshTokenStrategy.GenerateRefreshToken(ctx, requester)
+		if err != nil {
+			return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+		}
+	}
+
+	ctx, err = storage.MaybeBeginTx(ctx, c.CIBAStorage)
+	if err != nil {
+		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	if err := fosite.StorageOperation(ctx, "InvalidateBackchannelAuthenticationSession", func(ctx context.Context) error {
+		return c.CIBAStorage.InvalidateBackchannelAuthenticationSession(ctx, signature)
+	}); err != nil {
+		return rollback(ctx, c.CIBAStorage, err)
+	} else if err := fosite.StorageOperation(ctx, "CreateAccessTokenSession", func(ctx context.Context) error {
+		return c.AccessTokenStorage.CreateAccessTokenSession(ctx, accessSignature, requester.Sanitize([]string{}))
+	}); err != nil {
+		return rollback(ctx, c.CIBAStorage, err)
+	} else if refreshSignature != "" {
+		if err := oauth2.CreateRefreshTokenSession(ctx, c.RefreshTokenStrategy, c.RefreshTokenStorage, refreshSignature, requester.Sanitize([]string{})); err != nil {
+			return rollback(ctx, c.CIBAStorage, err)
+		}
+	}
+
+	responder.SetAccessToken(access)
+	responder.SetTokenType("bearer")
+	responder.SetExpiresIn(getExpiresIn(requester, fosite.AccessToken, fosite.GetAccessTokenLifespan(ctx, c.AccessTokenLifespan), time.Now().UTC()))
+	responder.SetScopes(requester.GetGrantedScopes())
+	if refresh != "" {
+		responder.SetExtra("refresh_token", refresh)
+	}
+
+	if c.IDTokenHandleHelper != nil && requester.GetGrantedScopes().Has("openid") {
+		session, ok := requester.GetSession().(openid.Session)
+		if !ok {
+			return rollback(ctx, c.CIBAStorage, errors.New("Failed to generate id token because session must be of type fosite/handler/openid.Session."))
+		}
+
+		session.IDTokenClaims().AccessTokenHash = c.GetAccessTokenHash(ctx, requester, responder)
+		if err := c.IssueExplicitIDToken(ctx, requester, responder); err != nil {
+			return rollback(ctx, c.CIBAStorage, err)
+		}
+	}
+
+	if err := storage.MaybeCommitTx(ctx, c.CIBAStorage); err != nil {
+		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	return nil
+}
+
+func (c *Handler) CanSkipClientAuth(requester fosite.AccessRequester) bool {
+	return false
+}
+
+func (c *Handler) CanHandleTokenEndpointRequest(requester fosite.AccessRequester) bool {
+	// grant_type REQUIRED.
+	// Value MUST be set to "urn:openid:params:grant-type:ciba"
+	return requester.GetGrantTypes().ExactOne(grantTypeCIBA)
+}
+
+// GetBackchannelAuthenticationRequest returns the pending backchannel authentication request identified by the
+// auth_req_id, so that the end user can be authenticated on their authentication device.
+func (c *Handler) GetBackchannelAuthenticationRequest(ctx context.Context, authReqID string, session fosite.Session) (fosite.Requester, error) {
+	signature := c.AuthRequestIDStrategy.AuthRequestIDSignature(authReqID)
+	var request fosite.Requester
+	err := fosite.StorageOperation(ctx, "GetBackchannelAuthenticationSession", func(ctx context.Context) (err error) {
+		request, err = c.CIBAStorage.GetBackchannelAuthenticationSession(ctx, signature, session)
+		return err
+	})
+	if errors.Is(err, fosite.ErrNotFound) {
+		return nil, errorsx.WithStack(fosite.ErrInvalidGrant.WithHint("The auth_req_id is unknown.").WithWrap(err).WithDebug(err.Error()))
+	} else if errors.Is(err, fosite.ErrAuthorizationPending) {
+		// The request is pending which is what we expect here.
+	} else if err != nil {
+		return nil, errorsx.WithStack(fosite.ErrInvalidGrant.WithHint("The backchannel authentication request has already been resolved.").WithWrap(err).WithDebug(err.Error()))
+	}
+
+	if err := c.AuthRequestIDStrategy.ValidateAuthRequestID(ctx, request, authReqID); errors.Is(err, fosite.ErrTokenExpired) {
+		return nil, errorsx.WithStack(fosite.ErrDeviceExpiredToken.WithDescription("The auth_req_id has expired, and the backchannel authentication session has concluded.").WithWrap(err).WithDebug(err.Error()))
+	} else if err != nil {
+		return nil, errorsx.WithStack(fosite.ErrInvalidGrant.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	return request, nil
+}
+
+// ApproveBackchannelAuthenticationRequest marks the backchannel authentication request identified by the
+// auth_req_id as approved by the end user. The request should be the one returned by
+// GetBackchannelAuthenticationRequest, with the end user's session set and the consented scopes and audience
+// granted. Clients using the ping mode are notified, clients using the push mode receive their tokens.
+func (c *Handler) ApproveBackchannelAuthenticationRequest(ctx context.Context, authReqID string, request fosite.Requester) error {
+	client, err := c.getClient(request.GetClient())
+	if err != nil {
+		return err
+	}
+
+	// The end user's session usually replaces the one created at the backchannel authentication endpoint, so make
+	// sure the auth_req_id does not outlive its original lifespan.
+	if request.GetSession().GetExpiresAt(fosite.AuthRequestID).IsZero() {
+		request.GetSession().SetExpiresAt(fosite.AuthRequestID, request.GetRequestedAt().Add(c.AuthRequestIDLifespan).Round(time.Second))
+	}
+
+	signature := c.AuthRequestIDStrategy.AuthRequestIDSignature(authReqID)
+	if err := fosite.StorageOperation(ctx, "ApproveBackchannelAuthenticationSession", func(ctx context.Context) error {
+		return c.CIBAStorage.ApproveBackchannelAuthenticationSession(ctx, signature, request.Sanitize(storedParameters))
+	}); errors.Is(err, fosite.ErrInvalidatedAuthRequestID) {
+		return errorsx.WithStack(fosite.ErrInvalidGrant.WithHint("The backchannel authentication request has already been resolved."))
+	} else if err != nil {
+		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	notificationToken := request.GetRequestForm().Get("client_notification_token")
+	switch client.GetBackchannelTokenDeliveryMode() {
+	case DeliveryModePing:
+		return c.notify(ctx, client, notificationToken, map[string]interface{}{"auth_req_id": authReqID})
+	case DeliveryModePush:
+		accessRequest := fosite.NewAccessRequest(nil)
+		accessRequest.Merge(request)
+		accessRequest.GrantTypes = fosite.Arguments{grantTypeCIBA}
+		c.setTokenLifespans(ctx, accessRequest)
+
+		if session, ok := accessRequest.GetSession().(openid.Session); ok {
+			session.IDTokenClaims().Add(AuthRequestIDClaim, authReqID)
+		}
+
+		response := fosite.NewAccessResponse()
+		if err := c.issueTokens(ctx, signature, accessRequest, response); err != nil {
+			return err
+		}
+
+		payload := response.ToMap()
+		payload["auth_req_id"] = authReqID
+		return c.notify(ctx, client, notificationToken, payload)
+	}
+	return nil
+}
+
+// DenyBackchannelAuthenticationRequest marks the backchannel authentication request identified by the
+// auth_req_id as denied by the end user. Clients using the ping or push mode are notified.
+func (c *Handler) DenyBackchannelAuthenticationRequest(ctx context.Context, authReqID string, request fosite.Requester) error {
+	client, err := c.getClient(request.GetClient())
+	if err != nil {
+		return err
+	}
+
+	signature := c.AuthRequestIDStrategy.AuthRequestIDSignature(authReqID)
+	if err := fosite.StorageOperation(ctx, "DenyBackchannelAuthenticationSession", func(ctx context.Context) error {
+		return c.CIBAStorage.DenyBackchannelAuthenticationSession(ctx, signature)
+	}); errors.Is(err, fosite.ErrInvalidatedAuthRequestID) {
+		return errorsx.WithStack(fosite.ErrInvalidGrant.WithHint("The backchannel authentication request has already been resolved."))
+	} else if err != nil {
+		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	notificationToken := request.GetRequestForm().Get("client_notification_token")
+	switch client.GetBackchannelTokenDeliveryMode() {
+	case DeliveryModePing:
+		return c.notify(ctx, client, notificationToken, map[string]interface{}{"auth_req_id": authReqID})
+	case DeliveryModePush:
+		return c.notify(ctx, client, notificationToken, map[string]interface{}{
+			"auth_req_id":       authReqID,
+			"error":             fosite.ErrAccessDenied.ErrorField,
+			"error_description": "The end user denied the backchannel authentication request.",
+		})
+	}
+	return nil
+}
+
+func (c *Handler) notify(ctx context.Context, client Client, token string, payload map[string]interface{}) error {
+	if c.Notifier == nil {
+		return errorsx.WithStack(fosite.ErrMisconfiguration.WithDebug("A Notifier is required for the ping and push token delivery modes."))
+	}
+
+	if err := c.Notifier.Notify(ctx, client.GetBackchannelClientNotificationEndpoint(), token, payload); err != nil {
+		return errorsx.WithStack(fosite.ErrServerError.WithHint("Unable to notify the OAuth 2.0 Client.").WithWrap(err).WithDebug(err.Error()))
+	}
+	return nil
+}
+
+func rollback(ctx context.Context, s interface{}, err error) error {
+	if rollBackTxnErr := storage.MaybeRollbackTx(ctx, s); rollBackTxnErr != nil {
+		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebugf("error: %s; rollback error: %s", err, rollBackTxnErr))
+	}
+	return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+}
+
+func getExpiresIn(r fosite.Requester, key fosite.TokenType, defaultLifespan time.Duration, now time.Time) time.Duration {
+	if r.GetSession().GetExpiresAt(key).IsZero() {
+		return defaultLifespan
+	}
+	return time.Duration(r.GetSession().GetExpiresAt(key).UnixNano() - now.UnixNano())
+}
+
+func (c *Handler) PopulateDiscoveryMetadata(ctx context.Context, metadata *fosite.DiscoveryMetadata) {
+	metadata.GrantTypesSupported = append(metadata.GrantTypesSupported, grantTypeCIBA)
+	metadata.BackchannelTokenDeliveryModesSupported = append(metadata.BackchannelTokenDeliveryModesSupported, DeliveryModePoll)
+	if c.Notifier != nil {
+		metadata.BackchannelTokenDeliveryModesSupported = append(metadata.BackchannelTokenDeliveryModesSupported, DeliveryModePing, DeliveryModePush)
+	}
+}
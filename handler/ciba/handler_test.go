@@ -0,0 +1,390 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package ciba
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/oauth2"
+	"github.com/ory/fosite/storage"
+	"github.com/ory/fosite/token/hmac"
+)
+
+var _ CIBAStorage = storage.NewMemoryStore()
+
+var authRequestIDStrategy = &DefaultAuthRequestIDStrategy{
+	Enigma:                &hmac.HMACStrategy{GlobalSecret: []byte("foobarfoobarfoobarfoobarfoobarfoobarfoobarfoobar")},
+	AuthRequestIDLifespan: time.Minute * 10,
+}
+
+func newHandler(store *storage.MemoryStore, notifier Notifier) *Handler {
+	tokens := &oauth2.HMACSHAStrategy{
+		Enigma:               &hmac.HMACStrategy{GlobalSecret: []byte("foobarfoobarfoobarfoobarfoobarfoobarfoobarfoobar")},
+		AccessTokenLifespan:  time.Hour,
+		RefreshTokenLifespan: time.Hour,
+	}
+	return &Handler{
+		AuthRequestIDStrategy: authRequestIDStrategy,
+		CIBAStorage:           store,
+		AccessTokenStrategy:   tokens,
+		RefreshTokenStrategy:  tokens,
+		AccessTokenStorage:    store,
+		RefreshTokenStorage:   store,
+		Notifier:              notifier,
+		AccessTokenLifespan:   time.Hour,
+		RefreshTokenLifespan:  time.Hour,
+		AuthRequestIDLifespan: time.Minute * 10,
+		PollingInterval:       time.Millisecond * 50,
+	}
+}
+
+func newClient(mode string, endpoint string) *DefaultClient {
+	return &DefaultClient{
+		DefaultClient: &fosite.DefaultClient{
+			ID:         "foo",
+			GrantTypes: fosite.Arguments{grantTypeCIBA, "refresh_token"},
+			Scopes:     fosite.Arguments{"openid", "offline"},
+		},
+		BackchannelTokenDeliveryMode:          mode,
+		BackchannelClientNotificationEndpoint: endpoint,
+	}
+}
+
+func newBackchannelRequest(client fosite.Client, form url.Values) *fosite.BackchannelAuthenticationRequest {
+	req := fosite.NewBackchannelAuthenticationRequest()
+	req.Client = client
+	req.RequestedScope = fosite.Arguments{"openid", "offline"}
+	req.Session = new(fosite.DefaultSession)
+	req.Form = form
+	return req
+}
+
+func newAuthentication(t *testing.T, h *Handler, client fosite.Client, form url.Values) fosite.BackchannelAuthenticationResponder {
+	resp := fosite.NewBackchannelAuthenticationResponse()
+	require.NoError(t, h.HandleBackchannelAuthenticationEndpointRequest(context.Background(), newBackchannelRequest(client, form), resp))
+	return resp
+}
+
+func newTokenRequest(client fosite.Client, authReqID string) *fosite.AccessRequest {
+	req := fosite.NewAccessRequest(new(fosite.DefaultSession))
+	req.GrantTypes = fosite.Arguments{grantTypeCIBA}
+	req.Client = client
+	req.Form = url.Values{"auth_req_id": {authReqID}}
+	return req
+}
+
+func approve(t *testing.T, h *Handler, authReqID string) {
+	request, err := h.GetBackchannelAuthenticationRequest(context.Background(), authReqID, new(fosite.DefaultSession))
+	require.NoError(t, err)
+	request.GrantScope("openid")
+	request.GrantScope("offline")
+	require.NoError(t, h.ApproveBackchannelAuthenticationRequest(context.Background(), authReqID, request))
+}
+
+func TestHandler_HandleBackchannelAuthenticationEndpointRequest(t *testing.T) {
+	h := newHandler(storage.NewMemoryStore(), nil)
+	poll := newClient(DeliveryModePoll, "")
+
+	for _, c := range []struct {
+		d         string
+		client    fosite.Client
+		scopes    fosite.Arguments
+		form      url.Values
+		expectErr error
+	}{
+		{
+			d:         "fails when the client is not allowed to use the grant",
+			client:    &fosite.DefaultClient{GrantTypes: fosite.Arguments{"authorization_code"}},
+			form:      url.Values{"login_hint": {"peter"}},
+			expectErr: fosite.ErrUnauthorizedClient,
+		},
+		{
+			d:         "fails when the client has no delivery mode",
+			client:    newClient("", ""),
+			form:      url.Values{"login_hint": {"peter"}},
+			expectErr: fosite.ErrUnauthorizedClient,
+		},
+		{
+			d:         "fails when the ping client has no notification endpoint",
+			client:    newClient(DeliveryModePing, ""),
+			form:      url.Values{"login_hint": {"peter"}, "client_notification_token": {"token"}},
+			expectErr: fosite.ErrUnauthorizedClient,
+		},
+		{
+			d:         "fails without the openid scope",
+			client:    poll,
+			scopes:    fosite.Arguments{"offline"},
+			form:      url.Values{"login_hint": {"peter"}},
+			expectErr: fosite.ErrInvalidScope,
+		},
+		{
+			d:         "fails without a hint",
+			client:    poll,
+			form:      url.Values{},
+			expectErr: fosite.ErrInvalidRequest,
+		},
+		{
+			d:         "fails with more than one hint",
+			client:    poll,
+			form:      url.Values{"login_hint": {"peter"}, "id_token_hint": {"token"}},
+			expectErr: fosite.ErrInvalidRequest,
+		},
+		{
+			d:         "fails without client_notification_token in ping mode",
+			client:    newClient(DeliveryModePing, "https://client.example.com/cb"),
+			form:      url.Values{"login_hint": {"peter"}},
+			expectErr: fosite.ErrInvalidRequest,
+		},
+		{
+			d:         "fails without user_code if the client requires one",
+			client:    &DefaultClient{DefaultClient: poll.DefaultClient, BackchannelTokenDeliveryMode: DeliveryModePoll, BackchannelUserCodeParameter: true},
+			form:      url.Values{"login_hint": {"peter"}},
+			expectErr: fosite.ErrMissingUserCode,
+		},
+		{
+			d:         "fails with an invalid requested_expiry",
+			client:    poll,
+			form:      url.Values{"login_hint": {"peter"}, "requested_expiry": {"-1"}},
+			expectErr: fosite.ErrInvalidRequest,
+		},
+	} {
+		t.Run("case="+c.d, func(t *testing.T) {
+			req := newBackchannelRequest(c.client, c.form)
+			if c.scopes != nil {
+				req.RequestedScope = c.scopes
+			}
+			err := h.HandleBackchannelAuthenticationEndpointRequest(context.Background(), req, fosite.NewBackchannelAuthenticationResponse())
+			require.Error(t, err)
+			assert.EqualError(t, err, c.expectErr.Error())
+		})
+	}
+
+	t.Run("case=issues auth_req_id", func(t *testing.T) {
+		resp := newAuthentication(t, h, poll, url.Values{"login_hint": {"peter"}})
+		assert.NotEmpty(t, resp.GetAuthRequestID())
+		assert.InDelta(t, (time.Minute * 10).Seconds(), resp.GetExpiresIn().Seconds(), 2)
+		assert.Equal(t, time.Millisecond*50, resp.GetInterval())
+	})
+
+	t.Run("case=requested_expiry shortens the lifespan", func(t *testing.T) {
+		resp := newAuthentication(t, h, poll, url.Values{"login_hint": {"peter"}, "requested_expiry": {"120"}})
+		assert.InDelta(t, (time.Minute * 2).Seconds(), resp.GetExpiresIn().Seconds(), 2)
+	})
+
+	t.Run("case=push mode does not return an interval", func(t *testing.T) {
+		resp := newAuthentication(t, h, newClient(DeliveryModePush, "https://client.example.com/cb"), url.Values{"login_hint": {"peter"}, "client_notification_token": {"token"}})
+		assert.Zero(t, resp.GetInterval())
+	})
+}
+
+func TestHandler_CIBAGrant(t *testing.T) {
+	client := newClient(DeliveryModePoll, "")
+	form := url.Values{"login_hint": {"peter"}}
+
+	for _, c := range []struct {
+		d         string
+		setup     func(t *testing.T, h *Handler, authReqID string) *fosite.AccessRequest
+		expectErr error
+	}{
+		{
+			d: "fails with missing auth_req_id",
+			setup: func(t *testing.T, h *Handler, authReqID string) *fosite.AccessRequest {
+				return newTokenRequest(client, "")
+			},
+			expectErr: fosite.ErrInvalidRequest,
+		},
+		{
+			d: "fails with unknown auth_req_id",
+			setup: func(t *testing.T, h *Handler, authReqID string) *fosite.AccessRequest {
+				unknown, _, err := authRequestIDStrategy.GenerateAuthRequestID(context.Background(), nil)
+				require.NoError(t, err)
+				return newTokenRequest(client, unknown)
+			},
+			expectErr: fosite.ErrInvalidGrant,
+		},
+		{
+			d: "fails while authorization is pending",
+			setup: func(t *testing.T, h *Handler, authReqID string) *fosite.AccessRequest {
+				return newTokenRequest(client, authReqID)
+			},
+			expectErr: fosite.ErrAuthorizationPending,
+		},
+		{
+			d: "fails when polling too fast",
+			setup: func(t *testing.T, h *Handler, authReqID string) *fosite.AccessRequest {
+				require.Error(t, h.HandleTokenEndpointRequest(context.Background(), newTokenRequest(client, authReqID)))
+				return newTokenRequest(client, authReqID)
+			},
+			expectErr: fosite.ErrSlowDown,
+		},
+		{
+			d: "fails when the end user denied the request",
+			setup: func(t *testing.T, h *Handler, authReqID string) *fosite.AccessRequest {
+				request, err := h.GetBackchannelAuthenticationRequest(context.Background(), authReqID, new(fosite.DefaultSession))
+				require.NoError(t, err)
+				require.NoError(t, h.DenyBackchannelAuthenticationRequest(context.Background(), authReqID, request))
+				return newTokenRequest(client, authReqID)
+			},
+			expectErr: fosite.ErrAccessDenied,
+		},
+		{
+			d: "fails with a different client",
+			setup: func(t *testing.T, h *Handler, authReqID string) *fosite.AccessRequest {
+				approve(t, h, authReqID)
+				other := newClient(DeliveryModePoll, "")
+				other.DefaultClient = &fosite.DefaultClient{ID: "bar", GrantTypes: fosite.Arguments{grantTypeCIBA}}
+				return newTokenRequest(other, authReqID)
+			},
+			expectErr: fosite.ErrInvalidGrant,
+		},
+		{
+			d: "fails for push clients",
+			setup: func(t *testing.T, h *Handler, authReqID string) *fosite.AccessRequest {
+				return newTokenRequest(newClient(DeliveryModePush, "https://client.example.com/cb"), authReqID)
+			},
+			expectErr: fosite.ErrUnauthorizedClient,
+		},
+		{
+			d: "passes after approval",
+			setup: func(t *testing.T, h *Handler, authReqID string) *fosite.AccessRequest {
+				approve(t, h, authReqID)
+				return newTokenRequest(client, authReqID)
+			},
+		},
+	} {
+		t.Run("case="+c.d, func(t *testing.T) {
+			h := newHandler(storage.NewMemoryStore(), nil)
+			authReqID := newAuthentication(t, h, client, form).GetAuthRequestID()
+			req := c.setup(t, h, authReqID)
+
+			err := h.HandleTokenEndpointRequest(context.Background(), req)
+			if c.expectErr != nil {
+				require.Error(t, err)
+				assert.EqualError(t, err, c.expectErr.Error())
+				return
+			}
+			require.NoError(t, err)
+
+			resp := fosite.NewAccessResponse()
+			require.NoError(t, h.PopulateTokenEndpointResponse(context.Background(), req, resp))
+			assert.NotEmpty(t, resp.GetAccessToken())
+			assert.NotEmpty(t, resp.GetExtra("refresh_token"))
+			assert.Equal(t, "bearer", resp.GetTokenType())
+
+			t.Run("case=auth_req_id can only be used once", func(t *testing.T) {
+				err := h.HandleTokenEndpointRequest(context.Background(), newTokenRequest(client, authReqID))
+				require.Error(t, err)
+				assert.EqualError(t, err, fosite.ErrInvalidGrant.Error())
+			})
+		})
+	}
+}
+
+func TestHandler_SlowDown(t *testing.T) {
+	client := newClient(DeliveryModePoll, "")
+	store := storage.NewMemoryStore()
+	h := newHandler(store, nil)
+	authReqID := newAuthentication(t, h, client, url.Values{"login_hint": {"peter"}}).GetAuthRequestID()
+	signature := h.AuthRequestIDStrategy.AuthRequestIDSignature(authReqID)
+
+	require.ErrorIs(t, h.HandleTokenEndpointRequest(context.Background(), newTokenRequest(client, authReqID)), fosite.ErrAuthorizationPending)
+	for k, expected := range []time.Duration{
+		h.PollingInterval + SlowDownIntervalIncrement,
+		h.PollingInterval + 2*SlowDownIntervalIncrement,
+	} {
+		err := h.HandleTokenEndpointRequest(context.Background(), newTokenRequest(client, authReqID))
+		require.ErrorIs(t, err, fosite.ErrSlowDown, "%d", k)
+		assert.Contains(t, fosite.ErrorToRFC6749Error(err).HintField, fmt.Sprintf("%d seconds", int64(expected/time.Second)), "%d", k)
+
+		_, interval, err := store.TouchBackchannelAuthenticationSession(context.Background(), signature, time.Now().UTC())
+		require.NoError(t, err)
+		assert.Equal(t, expected, interval, "%d", k)
+	}
+}
+
+func TestHandler_Notifications(t *testing.T) {
+	var received []map[string]interface{}
+	var tokens []string
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		received = append(received, payload)
+		tokens = append(tokens, r.Header.Get("Authorization"))
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	form := url.Values{"login_hint": {"peter"}, "client_notification_token": {"notification-token"}}
+
+	t.Run("case=ping mode notifies the client", func(t *testing.T) {
+		received, tokens = nil, nil
+		h := newHandler(storage.NewMemoryStore(), &DefaultNotifier{})
+		authReqID := newAuthentication(t, h, newClient(DeliveryModePing, server.URL), form).GetAuthRequestID()
+		approve(t, h, authReqID)
+
+		require.Len(t, received, 1)
+		assert.Equal(t, map[string]interface{}{"auth_req_id": authReqID}, received[0])
+		assert.Equal(t, "Bearer notification-token", tokens[0])
+	})
+
+	t.Run("case=push mode delivers the tokens", func(t *testing.T) {
+		received, tokens = nil, nil
+		h := newHandler(storage.NewMemoryStore(), &DefaultNotifier{})
+		authReqID := newAuthentication(t, h, newClient(DeliveryModePush, server.URL), form).GetAuthRequestID()
+		approve(t, h, authReqID)
+
+		require.Len(t, received, 1)
+		assert.Equal(t, authReqID, received[0]["auth_req_id"])
+		assert.NotEmpty(t, received[0]["access_token"])
+		assert.NotEmpty(t, received[0]["refresh_token"])
+		assert.Equal(t, "Bearer notification-token", tokens[0])
+
+		t.Run("case=request can not be resolved twice", func(t *testing.T) {
+			request, err := h.GetBackchannelAuthenticationRequest(context.Background(), authReqID, new(fosite.DefaultSession))
+			require.Error(t, err)
+			assert.Nil(t, request)
+		})
+	})
+
+	t.Run("case=push mode notifies denials", func(t *testing.T) {
+		received, tokens = nil, nil
+		h := newHandler(storage.NewMemoryStore(), &DefaultNotifier{})
+		authReqID := newAuthentication(t, h, newClient(DeliveryModePush, server.URL), form).GetAuthRequestID()
+		request, err := h.GetBackchannelAuthenticationRequest(context.Background(), authReqID, new(fosite.DefaultSession))
+		require.NoError(t, err)
+		require.NoError(t, h.DenyBackchannelAuthenticationRequest(context.Background(), authReqID, request))
+
+		require.Len(t, received, 1)
+		assert.Equal(t, "access_denied", received[0]["error"])
+	})
+}
@@ -0,0 +1,77 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package ciba
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/ory/x/errorsx"
+	"github.com/pkg/errors"
+)
+
+// Notifier delivers ping and push notifications to the client notification endpoint, see
+// https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.10
+type Notifier interface {
+	// Notify posts the payload to the client notification endpoint, authenticating with the client notification
+	// token as bearer token.
+	Notify(ctx context.Context, endpoint string, clientNotificationToken string, payload map[string]interface{}) error
+}
+
+// DefaultNotifier posts notifications as JSON using the given HTTP client.
+type DefaultNotifier struct {
+	// HTTPClient is used to send the notifications. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+func (n *DefaultNotifier) Notify(ctx context.Context, endpoint string, clientNotificationToken string, payload map[string]interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return errorsx.WithStack(err)
+	}
+
+	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return errorsx.WithStack(err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+clientNotificationToken)
+
+	client := n.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return errorsx.WithStack(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return errors.Errorf("client notification endpoint responded with unexpected status code %d", res.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package ciba
+
+import (
+	"context"
+	"time"
+
+	"github.com/ory/fosite"
+)
+
+// CIBAStorage handles the storage of pending backchannel authentication requests.
+type CIBAStorage interface {
+	// CreateBackchannelAuthenticationSession stores the backchannel authentication request for a given auth_req_id
+	// signature.
+	CreateBackchannelAuthenticationSession(ctx context.Context, signature string, request fosite.Requester) (err error)
+
+	// GetBackchannelAuthenticationSession hydrates the session based on the given auth_req_id signature and returns
+	// the backchannel authentication request. As long as the end user has not been authenticated, this method should
+	// return fosite.ErrAuthorizationPending. If the end user denied the request, it should return
+	// fosite.ErrAccessDenied. If the auth_req_id has been invalidated with `InvalidateBackchannelAuthenticationSession`,
+	// this method should return fosite.ErrInvalidatedAuthRequestID.
+	//
+	// Make sure to also return the fosite.Requester value when returning any of the errors above!
+	GetBackchannelAuthenticationSession(ctx context.Context, signature string, session fosite.Session) (request fosite.Requester, err error)
+
+	// ApproveBackchannelAuthenticationSession marks the pending request as approved. The stored request must be
+	// replaced with the given request, as it contains the session of the authenticated end user and the granted
+	// scopes and audience. It should return fosite.ErrInvalidatedAuthRequestID if the request is no longer pending.
+	ApproveBackchannelAuthenticationSession(ctx context.Context, signature string, request fosite.Requester) (err error)
+
+	// DenyBackchannelAuthenticationSession marks the pending request as denied. It should return
+	// fosite.ErrInvalidatedAuthRequestID if the request is no longer pending.
+	DenyBackchannelAuthenticationSession(ctx context.Context, signature string) (err error)
+
+	// InvalidateBackchannelAuthenticationSession is called when the auth_req_id is being exchanged for tokens.
+	// Consecutive requests to GetBackchannelAuthenticationSession should return fosite.ErrInvalidatedAuthRequestID.
+	InvalidateBackchannelAuthenticationSession(ctx context.Context, signature string) (err error)
+
+	// TouchBackchannelAuthenticationSession records that the client polled the token endpoint at the given time and
+	// returns the time of the previous poll, or the zero time if this is the first poll, as well as the polling
+	// interval stored using SlowDownBackchannelAuthenticationSession, or zero if none was stored.
+	TouchBackchannelAuthenticationSession(ctx context.Context, signature string, now time.Time) (lastPolledAt time.Time, interval time.Duration, err error)
+
+	// SlowDownBackchannelAuthenticationSession stores the increased polling interval the client must wait between
+	// polling requests after it was told to slow down.
+	SlowDownBackchannelAuthenticationSession(ctx context.Context, signature string, interval time.Duration) (err error)
+}
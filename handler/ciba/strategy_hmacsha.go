@@ -0,0 +1,56 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package ciba
+
+import (
+	"context"
+	"time"
+
+	"github.com/ory/x/errorsx"
+
+	"github.com/ory/fosite"
+	enigma "github.com/ory/fosite/token/hmac"
+)
+
+type DefaultAuthRequestIDStrategy struct {
+	Enigma                *enigma.HMACStrategy
+	AuthRequestIDLifespan time.Duration
+}
+
+func (h *DefaultAuthRequestIDStrategy) AuthRequestIDSignature(token string) string {
+	return h.Enigma.Signature(token)
+}
+
+func (h *DefaultAuthRequestIDStrategy) GenerateAuthRequestID(_ context.Context, _ fosite.Requester) (token string, signature string, err error) {
+	return h.Enigma.Generate()
+}
+
+func (h *DefaultAuthRequestIDStrategy) ValidateAuthRequestID(_ context.Context, r fosite.Requester, token string) (err error) {
+	var exp = r.GetSession().GetExpiresAt(fosite.AuthRequestID)
+	if exp.IsZero() && r.GetRequestedAt().Add(h.AuthRequestIDLifespan).Before(time.Now().UTC()) {
+		return errorsx.WithStack(fosite.ErrTokenExpired.WithHintf("The auth_req_id expired at '%s'.", r.GetRequestedAt().Add(h.AuthRequestIDLifespan)))
+	}
+	if !exp.IsZero() && exp.Before(time.Now().UTC()) {
+		return errorsx.WithStack(fosite.ErrTokenExpired.WithHintf("The auth_req_id expired at '%s'.", exp))
+	}
+	return h.Enigma.Validate(token)
+}
@@ -0,0 +1,169 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+// Package extension implements extension grants as defined in https://tools.ietf.org/html/rfc6749#section-4.5,
+// which use an absolute URI as grant type. The Handler takes care of the parts all grants have in common, the
+// client, scope and audience checks, the access token lifespan and issuing the access token, while a
+// GrantValidator validates the parameters specific to the grant:
+//
+//	const grantType = "urn:mycompany:params:grant-type:x"
+//
+//	factory, err := compose.ExtensionGrantFactory(grantType, extension.GrantValidatorFunc(
+//		func(ctx context.Context, request fosite.AccessRequester) error {
+//			subject, err := verifyTicket(ctx, request.GetRequestForm().Get("ticket"))
+//			if err != nil {
+//				return errorsx.WithStack(fosite.ErrInvalidGrant.WithHint("The ticket is invalid.").WithWrap(err))
+//			}
+//
+//			request.GetSession().(*oauth2.JWTSession).JWTClaims.Subject = subject
+//			for _, scope := range request.GetRequestedScopes() {
+//				request.GrantScope(scope)
+//			}
+//			return nil
+//		}))
+//	if err != nil {
+//		// the grant type is not an absolute URI
+//	}
+//	provider := compose.Compose(config, store, strategy, nil, compose.OAuth2ClientCredentialsGrantFactory, factory)
+//
+// Clients have to be allowed to use the grant type. Requests with grant types no handler is registered for are
+// rejected with unsupported_grant_type.
+package extension
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"github.com/ory/x/errorsx"
+	"github.com/pkg/errors"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/oauth2"
+)
+
+// GrantValidator validates the grant specific parameters of a token request of an extension grant.
+type GrantValidator interface {
+	// ValidateGrant validates the request, for example an assertion in its form, and sets up its session and
+	// granted scopes. It is called after the client, scopes and audience have been checked. A *fosite.RFC6749Error,
+	// typically fosite.ErrInvalidGrant, is returned to the client, any other error results in a server_error.
+	ValidateGrant(ctx context.Context, request fosite.AccessRequester) error
+}
+
+// GrantValidatorFunc is an adapter to allow the use of ordinary functions as GrantValidator.
+type GrantValidatorFunc func(ctx context.Context, request fosite.AccessRequester) error
+
+func (f GrantValidatorFunc) ValidateGrant(ctx context.Context, request fosite.AccessRequester) error {
+	return f(ctx, request)
+}
+
+// Handler is a fosite.TokenEndpointHandler for the extension grant GrantType.
+type Handler struct {
+	// GrantType is the absolute URI of the grant, see ValidateGrantType.
+	GrantType string
+	Validator GrantValidator
+
+	ScopeStrategy            fosite.ScopeStrategy
+	AudienceMatchingStrategy fosite.AudienceMatchingStrategy
+
+	// SkipClientAuth indicates, if the grant can be used without client authentication. The client, scopes and
+	// audience are then checked by the Validator instead.
+	SkipClientAuth bool
+
+	*oauth2.HandleHelper
+}
+
+var _ fosite.GrantTypeHandler = (*Handler)(nil)
+
+// ValidateGrantType returns an error if the grant type can not be used for an extension grant, that is if it is
+// not an absolute URI.
+func ValidateGrantType(grantType string) error {
+	if u, err := url.Parse(grantType); err != nil {
+		return errors.Wrapf(err, "the extension grant type '%s' is not a valid URI", grantType)
+	} else if !u.IsAbs() {
+		return errors.Errorf("the extension grant type '%s' must be an absolute URI", grantType)
+	}
+	return nil
+}
+
+func (c *Handler) HandleTokenEndpointRequest(ctx context.Context, request fosite.AccessRequester) error {
+	if !c.CanHandleTokenEndpointRequest(request) {
+		return errorsx.WithStack(fosite.ErrUnknownRequest)
+	}
+
+	if !c.SkipClientAuth {
+		client := request.GetClient()
+		if !client.GetGrantTypes().Has(c.GrantType) {
+			return errorsx.WithStack(fosite.ErrUnauthorizedClient.WithHintf("The OAuth 2.0 Client is not allowed to use authorization grant '%s'.", c.GrantType))
+		}
+
+		for _, scope := range request.GetRequestedScopes() {
+			if !fosite.ClientScopeStrategy(client, c.ScopeStrategy)(client.GetScopes(), scope) {
+				return errorsx.WithStack(fosite.ErrInvalidScope.WithHintf("The OAuth 2.0 Client is not allowed to request scope '%s'.", scope))
+			}
+		}
+
+		if err := fosite.GetAudienceMatchingStrategy(ctx, client, c.AudienceMatchingStrategy)(client.GetAudience(), request.GetRequestedAudience()); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Validator.ValidateGrant(ctx, request); err != nil {
+		return validatorError(err)
+	}
+
+	request.GetSession().SetExpiresAt(fosite.AccessToken, time.Now().UTC().Add(fosite.GetAccessTokenLifespan(ctx, c.AccessTokenLifespan)).Round(time.Second))
+	return nil
+}
+
+func (c *Handler) PopulateTokenEndpointResponse(ctx context.Context, request fosite.AccessRequester, response fosite.AccessResponder) error {
+	if !c.CanHandleTokenEndpointRequest(request) {
+		return errorsx.WithStack(fosite.ErrUnknownRequest)
+	}
+
+	return c.IssueAccessToken(ctx, request, response)
+}
+
+func (c *Handler) CanSkipClientAuth(requester fosite.AccessRequester) bool {
+	return c.SkipClientAuth
+}
+
+func (c *Handler) CanHandleTokenEndpointRequest(requester fosite.AccessRequester) bool {
+	return c.GrantType != "" && requester.GetGrantTypes().ExactOne(c.GrantType)
+}
+
+func (c *Handler) HandledGrantType() string {
+	return c.GrantType
+}
+
+func (c *Handler) PopulateDiscoveryMetadata(ctx context.Context, metadata *fosite.DiscoveryMetadata) {
+	metadata.GrantTypesSupported = append(metadata.GrantTypesSupported, c.GrantType)
+}
+
+// validatorError returns errors of the Validator which are meant for the client, except ErrUnknownRequest which
+// would make fosite skip the handler, and wraps all others as server errors.
+func validatorError(err error) error {
+	var rfcErr *fosite.RFC6749Error
+	if errors.Is(err, fosite.ErrUnknownRequest) || !errors.As(err, &rfcErr) {
+		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+	return err
+}
@@ -0,0 +1,92 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package extension
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
+)
+
+func TestValidateGrantType(t *testing.T) {
+	for k, c := range []struct {
+		grantType string
+		valid     bool
+	}{
+		{grantType: "urn:mycompany:params:grant-type:x", valid: true},
+		{grantType: "https://mycompany.example/grant-type/x", valid: true},
+		{grantType: "client_credentials"},
+		{grantType: ""},
+		{grantType: "%zz:x"},
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			err := ValidateGrantType(c.grantType)
+			if c.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestHandler_HandleTokenEndpointRequest(t *testing.T) {
+	var validatorErr error
+	h := &Handler{
+		GrantType: "urn:mycompany:params:grant-type:x",
+		Validator: GrantValidatorFunc(func(context.Context, fosite.AccessRequester) error {
+			return validatorErr
+		}),
+		ScopeStrategy:            fosite.HierarchicScopeStrategy,
+		AudienceMatchingStrategy: fosite.DefaultAudienceMatchingStrategy,
+		SkipClientAuth:           true,
+	}
+
+	newRequest := func(grantType string) *fosite.AccessRequest {
+		areq := fosite.NewAccessRequest(new(fosite.DefaultSession))
+		areq.GrantTypes = fosite.Arguments{grantType}
+		return areq
+	}
+
+	assert.True(t, h.CanSkipClientAuth(newRequest(h.GrantType)))
+	assert.False(t, (&Handler{}).CanHandleTokenEndpointRequest(newRequest("")))
+	require.EqualError(t, h.HandleTokenEndpointRequest(context.Background(), newRequest("client_credentials")), fosite.ErrUnknownRequest.Error())
+
+	// The client is not checked if client authentication is skipped.
+	h.HandleHelper = nil
+	areq := newRequest(h.GrantType)
+	areq.SetRequestedScopes(fosite.Arguments{"foo"})
+	validatorErr = fosite.ErrInvalidGrant
+	require.EqualError(t, h.HandleTokenEndpointRequest(context.Background(), areq), fosite.ErrInvalidGrant.Error())
+
+	validatorErr = fosite.ErrUnknownRequest
+	require.EqualError(t, h.HandleTokenEndpointRequest(context.Background(), areq), fosite.ErrServerError.Error(), "the handler must not be skipped once it is responsible")
+
+	validatorErr = errors.New("foo")
+	require.EqualError(t, h.HandleTokenEndpointRequest(context.Background(), areq), fosite.ErrServerError.Error())
+}
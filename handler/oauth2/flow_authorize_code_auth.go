@@ -50,6 +50,10 @@ type AuthorizeExplicitGrantHandler struct {
 	// RefreshTokenLifespan defines the lifetime of a refresh token. Leave to 0 for unlimited lifetime.
 	RefreshTokenLifespan time.Duration
 
+	// RefreshTokenMaxLifespan defines the maximum lifetime of the refresh tokens issued for a grant, which is not
+	// renewed when a refresh token is used. Leave to 0 for unlimited lifetime.
+	RefreshTokenMaxLifespan time.Duration
+
 	ScopeStrategy            fosite.ScopeStrategy
 	AudienceMatchingStrategy fosite.AudienceMatchingStrategy
 
@@ -63,6 +67,11 @@ type AuthorizeExplicitGrantHandler struct {
 
 	RefreshTokenScopes []string
 
+	// RequireOfflineAccess must be set to true if fosite.Fosite.OfflineAccessPolicy is set. Refresh tokens are then
+	// only issued for OpenID Connect requests if the offline_access scope was granted, regardless of
+	// RefreshTokenScopes.
+	RequireOfflineAccess bool
+
 	// OmitRedirectScopeParam must be set to true if the scope query param is to be omitted
 	// in the authorization's redirect URI
 	OmitRedirectScopeParam bool
@@ -94,12 +103,12 @@ func (c *AuthorizeExplicitGrantHandler) HandleAuthorizeEndpointRequest(ctx conte
 
 	client := ar.GetClient()
 	for _, scope := range ar.GetRequestedScopes() {
-		if !c.ScopeStrategy(client.GetScopes(), scope) {
+		if !fosite.ClientScopeStrategy(client, c.ScopeStrategy)(client.GetScopes(), scope) {
 			return errorsx.WithStack(fosite.ErrInvalidScope.WithHintf("The OAuth 2.0 Client is not allowed to request scope '%s'.", scope))
 		}
 	}
 
-	if err := c.AudienceMatchingStrategy(client.GetAudience(), ar.GetRequestedAudience()); err != nil {
+	if err := fosite.GetAudienceMatchingStrategy(ctx, client, c.AudienceMatchingStrategy)(client.GetAudience(), ar.GetRequestedAudience()); err != nil {
 		return err
 	}
 
@@ -112,8 +121,10 @@ func (c *AuthorizeExplicitGrantHandler) IssueAuthorizeCode(ctx context.Context,
 		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
 	}
 
-	ar.GetSession().SetExpiresAt(fosite.AuthorizeCode, time.Now().UTC().Add(c.AuthCodeLifespan))
-	if err := c.CoreStorage.CreateAuthorizeCodeSession(ctx, signature, ar.Sanitize(c.GetSanitationWhiteList())); err != nil {
+	ar.GetSession().SetExpiresAt(fosite.AuthorizeCode, time.Now().UTC().Add(fosite.GetAuthorizeCodeLifespan(ctx, c.AuthCodeLifespan)))
+	if err := fosite.StorageOperation(ctx, "CreateAuthorizeCodeSession", func(ctx context.Context) error {
+		return c.CoreStorage.CreateAuthorizeCodeSession(ctx, signature, ar.Sanitize(c.GetSanitationWhiteList()))
+	}); err != nil {
 		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
 	}
 
@@ -136,3 +147,8 @@ func (c *AuthorizeExplicitGrantHandler) GetSanitationWhiteList() []string {
 		"redirect_uri",
 	}
 }
+
+func (c *AuthorizeExplicitGrantHandler) PopulateDiscoveryMetadata(ctx context.Context, metadata *fosite.DiscoveryMetadata) {
+	metadata.ResponseTypesSupported = append(metadata.ResponseTypesSupported, "code")
+	metadata.GrantTypesSupported = append(metadata.GrantTypesSupported, "authorization_code")
+}
@@ -46,8 +46,23 @@ func (c *AuthorizeExplicitGrantHandler) HandleTokenEndpointRequest(ctx context.C
 	}
 
 	code := request.GetRequestForm().Get("code")
+
+	// Self-encoded codes are validated before hitting the storage, so that bogus codes are rejected cheaply.
+	if stateless, ok := c.AuthorizeCodeStrategy.(StatelessAuthorizeCodeStrategy); ok {
+		claims, err := stateless.DecodeAuthorizeCode(ctx, code)
+		if err != nil {
+			return errorsx.WithStack(fosite.ErrInvalidGrant.WithWrap(err).WithDebug(err.Error()))
+		} else if err := validateAuthorizeCodeClaims(request, claims); err != nil {
+			return err
+		}
+	}
+
 	signature := c.AuthorizeCodeStrategy.AuthorizeCodeSignature(code)
-	authorizeRequest, err := c.CoreStorage.GetAuthorizeCodeSession(ctx, signature, request.GetSession())
+	var authorizeRequest fosite.Requester
+	err := fosite.StorageOperation(ctx, "GetAuthorizeCodeSession", func(ctx context.Context) (err error) {
+		authorizeRequest, err = c.CoreStorage.GetAuthorizeCodeSession(ctx, signature, request.GetSession())
+		return err
+	})
 	if errors.Is(err, fosite.ErrInvalidatedAuthorizeCode) {
 		if authorizeRequest == nil {
 			return fosite.ErrServerError.
@@ -59,11 +74,25 @@ func (c *AuthorizeExplicitGrantHandler) HandleTokenEndpointRequest(ctx context.C
 		reqID := authorizeRequest.GetID()
 		hint := "The authorization code has already been used."
 		debug := ""
-		if revErr := c.TokenRevocationStorage.RevokeAccessToken(ctx, reqID); revErr != nil {
+		if rs, ok := c.TokenRevocationStorage.(AuthorizeCodeRevocationStorage); ok {
+			if revErr := fosite.StorageOperation(ctx, "RevokeTokensByAuthorizeCode", func(ctx context.Context) error {
+				return rs.RevokeTokensByAuthorizeCode(ctx, signature)
+			}); revErr != nil {
+				hint += " Additionally, an error occurred during processing the token revocation."
+				debug += "Revocation of tokens lead to error " + revErr.Error() + "."
+			}
+			return errorsx.WithStack(fosite.ErrInvalidGrant.WithHint(hint).WithDebug(debug))
+		}
+
+		if revErr := fosite.StorageOperation(ctx, "RevokeAccessToken", func(ctx context.Context) error {
+			return c.TokenRevocationStorage.RevokeAccessToken(ctx, reqID)
+		}); revErr != nil {
 			hint += " Additionally, an error occurred during processing the access token revocation."
 			debug += "Revocation of access_token lead to error " + revErr.Error() + "."
 		}
-		if revErr := c.TokenRevocationStorage.RevokeRefreshToken(ctx, reqID); revErr != nil {
+		if revErr := fosite.StorageOperation(ctx, "RevokeRefreshToken", func(ctx context.Context) error {
+			return c.TokenRevocationStorage.RevokeRefreshToken(ctx, reqID)
+		}); revErr != nil {
 			hint += " Additionally, an error occurred during processing the refresh token revocation."
 			debug += "Revocation of refresh_token lead to error " + revErr.Error() + "."
 		}
@@ -102,18 +131,30 @@ func (c *AuthorizeExplicitGrantHandler) HandleTokenEndpointRequest(ctx context.C
 		return errorsx.WithStack(fosite.ErrInvalidGrant.WithHint("The \"redirect_uri\" from this request does not match the one from the authorize request."))
 	}
 
+	// https://tools.ietf.org/html/rfc8707#section-2.2
+	resources, err := fosite.GetResources(request.GetRequestForm())
+	if err != nil {
+		return err
+	} else if _, err := fosite.NarrowAudience(resources, authorizeRequest.GetGrantedAudience()); err != nil {
+		return err
+	}
+
+	// https://tools.ietf.org/html/rfc9396#section-6.1
+	if _, err := fosite.NarrowAuthorizationDetails(request.GetRequestedAuthorizationDetails(), authorizeRequest.GetAuthorizationDetails()); err != nil {
+		return err
+	}
+
 	// Checking of POST client_id skipped, because:
 	// If the client type is confidential or the client was issued client
 	// credentials (or assigned other authentication requirements), the
 	// client MUST authenticate with the authorization server as described
 	// in Section 3.2.1.
-	request.SetSession(authorizeRequest.GetSession())
+	request.SetSession(authorizeRequest.GetSession().Clone())
+	fosite.CarryOverPersistedParameters(request, authorizeRequest)
 	request.SetID(authorizeRequest.GetID())
 
-	request.GetSession().SetExpiresAt(fosite.AccessToken, time.Now().UTC().Add(c.AccessTokenLifespan).Round(time.Second))
-	if c.RefreshTokenLifespan > -1 {
-		request.GetSession().SetExpiresAt(fosite.RefreshToken, time.Now().UTC().Add(c.RefreshTokenLifespan).Round(time.Second))
-	}
+	request.GetSession().SetExpiresAt(fosite.AccessToken, time.Now().UTC().Add(fosite.GetAccessTokenLifespan(ctx, c.AccessTokenLifespan)).Round(time.Second))
+	setRefreshTokenExpiry(request, fosite.GetRefreshTokenLifespan(ctx, c.RefreshTokenLifespan), c.RefreshTokenMaxLifespan, time.Now().UTC())
 
 	return nil
 }
@@ -123,6 +164,10 @@ func canIssueRefreshToken(c *AuthorizeExplicitGrantHandler, request fosite.Reque
 	if len(c.RefreshTokenScopes) > 0 && !request.GetGrantedScopes().HasOneOf(c.RefreshTokenScopes...) {
 		return false
 	}
+	// Require offline_access for OpenID Connect requests, see https://openid.net/specs/openid-connect-core-1_0.html#OfflineAccess
+	if c.RequireOfflineAccess && request.GetGrantedScopes().Has("openid") && !request.GetGrantedScopes().Has(fosite.OfflineAccessScope) {
+		return false
+	}
 	// Do not issue a refresh token to clients that cannot use the refresh token grant type.
 	if !request.GetClient().GetGrantTypes().Has("refresh_token") {
 		return false
@@ -137,7 +182,11 @@ func (c *AuthorizeExplicitGrantHandler) PopulateTokenEndpointResponse(ctx contex
 
 	code := requester.GetRequestForm().Get("code")
 	signature := c.AuthorizeCodeStrategy.AuthorizeCodeSignature(code)
-	authorizeRequest, err := c.CoreStorage.GetAuthorizeCodeSession(ctx, signature, requester.GetSession())
+	var authorizeRequest fosite.Requester
+	err := fosite.StorageOperation(ctx, "GetAuthorizeCodeSession", func(ctx context.Context) (err error) {
+		authorizeRequest, err = c.CoreStorage.GetAuthorizeCodeSession(ctx, signature, requester.GetSession())
+		return err
+	})
 	if err != nil {
 		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
 	} else if err := c.AuthorizeCodeStrategy.ValidateAuthorizeCode(ctx, requester, code); err != nil {
@@ -149,9 +198,23 @@ func (c *AuthorizeExplicitGrantHandler) PopulateTokenEndpointResponse(ctx contex
 		requester.GrantScope(scope)
 	}
 
-	for _, audience := range authorizeRequest.GetGrantedAudience() {
-		requester.GrantAudience(audience)
+	resources, err := fosite.GetResources(requester.GetRequestForm())
+	if err != nil {
+		return err
+	}
+	audience, err := fosite.NarrowAudience(resources, authorizeRequest.GetGrantedAudience())
+	if err != nil {
+		return err
+	}
+	for _, aud := range audience {
+		requester.GrantAudience(aud)
+	}
+
+	details, err := fosite.NarrowAuthorizationDetails(requester.GetRequestedAuthorizationDetails(), authorizeRequest.GetAuthorizationDetails())
+	if err != nil {
+		return err
 	}
+	requester.GrantAuthorizationDetails(details)
 
 	access, accessSignature, err := c.AccessTokenStrategy.GenerateAccessToken(ctx, requester)
 	if err != nil {
@@ -159,8 +222,9 @@ func (c *AuthorizeExplicitGrantHandler) PopulateTokenEndpointResponse(ctx contex
 	}
 
 	var refresh, refreshSignature string
+	refreshReq := sanitizeRefreshTokenRequest(requester, authorizeRequest.GetGrantedAudience())
 	if canIssueRefreshToken(c, authorizeRequest) {
-		refresh, refreshSignature, err = c.RefreshTokenStrategy.GenerateRefreshToken(ctx, requester)
+		refresh, refreshSignature, err = c.RefreshTokenStrategy.GenerateRefreshToken(ctx, refreshReq)
 		if err != nil {
 			return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
 		}
@@ -171,18 +235,22 @@ func (c *AuthorizeExplicitGrantHandler) PopulateTokenEndpointResponse(ctx contex
 		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
 	}
 
-	if err := c.CoreStorage.InvalidateAuthorizeCodeSession(ctx, signature); err != nil {
+	if err := fosite.StorageOperation(ctx, "InvalidateAuthorizeCodeSession", func(ctx context.Context) error {
+		return c.CoreStorage.InvalidateAuthorizeCodeSession(ctx, signature)
+	}); err != nil {
 		if rollBackTxnErr := storage.MaybeRollbackTx(ctx, c.CoreStorage); rollBackTxnErr != nil {
 			return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebugf("error: %s; rollback error: %s", err, rollBackTxnErr))
 		}
 		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
-	} else if err := c.CoreStorage.CreateAccessTokenSession(ctx, accessSignature, requester.Sanitize([]string{})); err != nil {
+	} else if err := fosite.StorageOperation(ctx, "CreateAccessTokenSession", func(ctx context.Context) error {
+		return c.CoreStorage.CreateAccessTokenSession(ctx, accessSignature, requester.Sanitize([]string{}))
+	}); err != nil {
 		if rollBackTxnErr := storage.MaybeRollbackTx(ctx, c.CoreStorage); rollBackTxnErr != nil {
 			return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebugf("error: %s; rollback error: %s", err, rollBackTxnErr))
 		}
 		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
 	} else if refreshSignature != "" {
-		if err := c.CoreStorage.CreateRefreshTokenSession(ctx, refreshSignature, requester.Sanitize([]string{})); err != nil {
+		if err := CreateRefreshTokenSession(ctx, c.RefreshTokenStrategy, c.CoreStorage, refreshSignature, refreshReq); err != nil {
 			if rollBackTxnErr := storage.MaybeRollbackTx(ctx, c.CoreStorage); rollBackTxnErr != nil {
 				return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebugf("error: %s; rollback error: %s", err, rollBackTxnErr))
 			}
@@ -192,7 +260,7 @@ func (c *AuthorizeExplicitGrantHandler) PopulateTokenEndpointResponse(ctx contex
 
 	responder.SetAccessToken(access)
 	responder.SetTokenType("bearer")
-	responder.SetExpiresIn(getExpiresIn(requester, fosite.AccessToken, c.AccessTokenLifespan, time.Now().UTC()))
+	responder.SetExpiresIn(getExpiresIn(requester, fosite.AccessToken, fosite.GetAccessTokenLifespan(ctx, c.AccessTokenLifespan), time.Now().UTC()))
 	responder.SetScopes(requester.GetGrantedScopes())
 	if refresh != "" {
 		responder.SetExtra("refresh_token", refresh)
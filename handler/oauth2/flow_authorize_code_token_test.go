@@ -220,6 +220,91 @@ func TestAuthorizeCode_PopulateTokenEndpointResponse(t *testing.T) {
 						assert.Equal(t, "foo", aresp.GetExtra("scope"))
 					},
 				},
+				{
+					areq: &fosite.AccessRequest{
+						GrantTypes: fosite.Arguments{"authorization_code"},
+						Request: fosite.Request{
+							Form: url.Values{},
+							Client: &fosite.DefaultClient{
+								GrantTypes: fosite.Arguments{"authorization_code", "refresh_token"},
+							},
+							GrantedScope: fosite.Arguments{"openid", "offline"},
+							Session:      &fosite.DefaultSession{},
+							RequestedAt:  time.Now().UTC(),
+						},
+					},
+					setup: func(t *testing.T, areq *fosite.AccessRequest) {
+						h.RequireOfflineAccess = true
+						code, sig, err := strategy.GenerateAuthorizeCode(nil, nil)
+						require.NoError(t, err)
+						areq.Form.Add("code", code)
+
+						require.NoError(t, store.CreateAuthorizeCodeSession(nil, sig, areq))
+					},
+					description: "should not have refresh token without offline_access if it is required",
+					check: func(t *testing.T, aresp *fosite.AccessResponse) {
+						assert.NotEmpty(t, aresp.AccessToken)
+						assert.Empty(t, aresp.GetExtra("refresh_token"))
+						assert.Equal(t, "openid offline", aresp.GetExtra("scope"))
+					},
+				},
+				{
+					areq: &fosite.AccessRequest{
+						GrantTypes: fosite.Arguments{"authorization_code"},
+						Request: fosite.Request{
+							Form: url.Values{},
+							Client: &fosite.DefaultClient{
+								GrantTypes: fosite.Arguments{"authorization_code", "refresh_token"},
+							},
+							GrantedScope: fosite.Arguments{"openid"},
+							Session:      &fosite.DefaultSession{},
+							RequestedAt:  time.Now().UTC(),
+						},
+					},
+					setup: func(t *testing.T, areq *fosite.AccessRequest) {
+						h.RequireOfflineAccess = true
+						h.RefreshTokenScopes = []string{}
+						code, sig, err := strategy.GenerateAuthorizeCode(nil, nil)
+						require.NoError(t, err)
+						areq.Form.Add("code", code)
+
+						require.NoError(t, store.CreateAuthorizeCodeSession(nil, sig, areq))
+					},
+					description: "should not have refresh token without offline_access if it is required and no refresh token scopes are set",
+					check: func(t *testing.T, aresp *fosite.AccessResponse) {
+						assert.NotEmpty(t, aresp.AccessToken)
+						assert.Empty(t, aresp.GetExtra("refresh_token"))
+					},
+				},
+				{
+					areq: &fosite.AccessRequest{
+						GrantTypes: fosite.Arguments{"authorization_code"},
+						Request: fosite.Request{
+							Form: url.Values{},
+							Client: &fosite.DefaultClient{
+								GrantTypes: fosite.Arguments{"authorization_code", "refresh_token"},
+							},
+							GrantedScope: fosite.Arguments{"openid", "offline_access"},
+							Session:      &fosite.DefaultSession{},
+							RequestedAt:  time.Now().UTC(),
+						},
+					},
+					setup: func(t *testing.T, areq *fosite.AccessRequest) {
+						h.RequireOfflineAccess = true
+						h.RefreshTokenScopes = []string{"offline", "offline_access"}
+						code, sig, err := strategy.GenerateAuthorizeCode(nil, nil)
+						require.NoError(t, err)
+						areq.Form.Add("code", code)
+
+						require.NoError(t, store.CreateAuthorizeCodeSession(nil, sig, areq))
+					},
+					description: "should pass with offline_access if it is required",
+					check: func(t *testing.T, aresp *fosite.AccessResponse) {
+						assert.NotEmpty(t, aresp.AccessToken)
+						assert.NotEmpty(t, aresp.GetExtra("refresh_token"))
+						assert.Equal(t, "openid offline_access", aresp.GetExtra("scope"))
+					},
+				},
 			} {
 				t.Run("case="+c.description, func(t *testing.T) {
 					h = AuthorizeExplicitGrantHandler{
@@ -456,6 +541,63 @@ func TestAuthorizeCode_HandleTokenEndpointRequest(t *testing.T) {
 	}
 }
 
+func TestAuthorizeCode_HandleTokenEndpointRequestRevokesTokensOfReusedCode(t *testing.T) {
+	store := storage.NewMemoryStore()
+	h := AuthorizeExplicitGrantHandler{
+		CoreStorage:              store,
+		AuthorizeCodeStrategy:    hmacshaStrategy,
+		ScopeStrategy:            fosite.HierarchicScopeStrategy,
+		AudienceMatchingStrategy: fosite.DefaultAudienceMatchingStrategy,
+		TokenRevocationStorage:   store,
+		AuthCodeLifespan:         time.Minute,
+	}
+
+	authreq := &fosite.AuthorizeRequest{Request: fosite.Request{
+		ID:          "request-id",
+		Client:      &fosite.DefaultClient{ID: "foo", GrantTypes: fosite.Arguments{"authorization_code"}},
+		Session:     &fosite.DefaultSession{},
+		RequestedAt: time.Now().UTC(),
+	}}
+	code, signature, err := hmacshaStrategy.GenerateAuthorizeCode(nil, nil)
+	require.NoError(t, err)
+	require.NoError(t, store.CreateAuthorizeCodeSession(nil, signature, authreq))
+	require.NoError(t, store.InvalidateAuthorizeCodeSession(nil, signature))
+
+	// Tokens issued from the code, including those of a refresh which keep the request ID.
+	for _, sig := range []string{"at-1", "at-2"} {
+		require.NoError(t, store.CreateAccessTokenSession(nil, sig, authreq))
+	}
+	for _, sig := range []string{"rt-1", "rt-2"} {
+		require.NoError(t, store.CreateRefreshTokenSession(nil, sig, authreq))
+	}
+	other := &fosite.Request{ID: "other-request-id", Session: &fosite.DefaultSession{}}
+	require.NoError(t, store.CreateAccessTokenSession(nil, "at-other", other))
+
+	areq := &fosite.AccessRequest{
+		GrantTypes: fosite.Arguments{"authorization_code"},
+		Request: fosite.Request{
+			Form:        url.Values{"code": {code}},
+			Client:      &fosite.DefaultClient{ID: "foo", GrantTypes: fosite.Arguments{"authorization_code"}},
+			Session:     &fosite.DefaultSession{},
+			RequestedAt: time.Now().UTC(),
+		},
+	}
+	err = h.HandleTokenEndpointRequest(context.Background(), areq)
+	require.EqualError(t, err, fosite.ErrInvalidGrant.Error())
+	assert.Equal(t, "The authorization code has already been used.", fosite.ErrorToRFC6749Error(err).HintField)
+
+	for _, sig := range []string{"at-1", "at-2"} {
+		_, err := store.GetAccessTokenSession(nil, sig, nil)
+		assert.ErrorIs(t, err, fosite.ErrNotFound, sig)
+	}
+	for _, sig := range []string{"rt-1", "rt-2"} {
+		_, err := store.GetRefreshTokenSession(nil, sig, nil)
+		assert.ErrorIs(t, err, fosite.ErrInactiveToken, sig)
+	}
+	_, err = store.GetAccessTokenSession(nil, "at-other", nil)
+	assert.NoError(t, err, "tokens of other grants must not be revoked")
+}
+
 func TestAuthorizeCodeTransactional_HandleTokenEndpointRequest(t *testing.T) {
 	var mockTransactional *internal.MockTransactional
 	var mockCoreStore *internal.MockCoreStorage
@@ -476,6 +618,7 @@ func TestAuthorizeCodeTransactional_HandleTokenEndpointRequest(t *testing.T) {
 	request.Form = url.Values{"code": {token}}
 	response := fosite.NewAccessResponse()
 	propagatedContext := context.Background()
+	transactionContext := context.WithValue(propagatedContext, fosite.StorageTransactionContextKey, true)
 
 	// some storage implementation that has support for transactions, notice the embedded type `storage.Transactional`
 	type transactionalStore struct {
@@ -507,17 +650,17 @@ func TestAuthorizeCodeTransactional_HandleTokenEndpointRequest(t *testing.T) {
 					Times(1)
 				mockCoreStore.
 					EXPECT().
-					CreateAccessTokenSession(propagatedContext, gomock.Any(), gomock.Any()).
+					CreateAccessTokenSession(transactionContext, gomock.Any(), gomock.Any()).
 					Return(nil).
 					Times(1)
 				mockCoreStore.
 					EXPECT().
-					CreateRefreshTokenSession(propagatedContext, gomock.Any(), gomock.Any()).
+					CreateRefreshTokenSession(transactionContext, gomock.Any(), gomock.Any()).
 					Return(nil).
 					Times(1)
 				mockTransactional.
 					EXPECT().
-					Commit(propagatedContext).
+					Commit(transactionContext).
 					Return(nil).
 					Times(1)
 			},
@@ -541,7 +684,7 @@ func TestAuthorizeCodeTransactional_HandleTokenEndpointRequest(t *testing.T) {
 					Times(1)
 				mockTransactional.
 					EXPECT().
-					Rollback(propagatedContext).
+					Rollback(transactionContext).
 					Return(nil).
 					Times(1)
 			},
@@ -566,12 +709,12 @@ func TestAuthorizeCodeTransactional_HandleTokenEndpointRequest(t *testing.T) {
 					Times(1)
 				mockCoreStore.
 					EXPECT().
-					CreateAccessTokenSession(propagatedContext, gomock.Any(), gomock.Any()).
+					CreateAccessTokenSession(transactionContext, gomock.Any(), gomock.Any()).
 					Return(errors.New("Whoops, a nasty database error occurred!")).
 					Times(1)
 				mockTransactional.
 					EXPECT().
-					Rollback(propagatedContext).
+					Rollback(transactionContext).
 					Return(nil).
 					Times(1)
 			},
@@ -611,7 +754,7 @@ func TestAuthorizeCodeTransactional_HandleTokenEndpointRequest(t *testing.T) {
 					Times(1)
 				mockTransactional.
 					EXPECT().
-					Rollback(propagatedContext).
+					Rollback(transactionContext).
 					Return(errors.New("Whoops, unable to rollback transaction!")).
 					Times(1)
 			},
@@ -636,17 +779,17 @@ func TestAuthorizeCodeTransactional_HandleTokenEndpointRequest(t *testing.T) {
 					Times(1)
 				mockCoreStore.
 					EXPECT().
-					CreateAccessTokenSession(propagatedContext, gomock.Any(), gomock.Any()).
+					CreateAccessTokenSession(transactionContext, gomock.Any(), gomock.Any()).
 					Return(nil).
 					Times(1)
 				mockCoreStore.
 					EXPECT().
-					CreateRefreshTokenSession(propagatedContext, gomock.Any(), gomock.Any()).
+					CreateRefreshTokenSession(transactionContext, gomock.Any(), gomock.Any()).
 					Return(nil).
 					Times(1)
 				mockTransactional.
 					EXPECT().
-					Commit(propagatedContext).
+					Commit(transactionContext).
 					Return(errors.New("Whoops, unable to commit transaction!")).
 					Times(1)
 			},
@@ -66,12 +66,12 @@ func (c *AuthorizeImplicitGrantTypeHandler) HandleAuthorizeEndpointRequest(ctx c
 
 	client := ar.GetClient()
 	for _, scope := range ar.GetRequestedScopes() {
-		if !c.ScopeStrategy(client.GetScopes(), scope) {
+		if !fosite.ClientScopeStrategy(client, c.ScopeStrategy)(client.GetScopes(), scope) {
 			return errorsx.WithStack(fosite.ErrInvalidScope.WithHintf("The OAuth 2.0 Client is not allowed to request scope '%s'.", scope))
 		}
 	}
 
-	if err := c.AudienceMatchingStrategy(client.GetAudience(), ar.GetRequestedAudience()); err != nil {
+	if err := fosite.GetAudienceMatchingStrategy(ctx, client, c.AudienceMatchingStrategy)(client.GetAudience(), ar.GetRequestedAudience()); err != nil {
 		return err
 	}
 
@@ -84,7 +84,7 @@ func (c *AuthorizeImplicitGrantTypeHandler) HandleAuthorizeEndpointRequest(ctx c
 func (c *AuthorizeImplicitGrantTypeHandler) IssueImplicitAccessToken(ctx context.Context, ar fosite.AuthorizeRequester, resp fosite.AuthorizeResponder) error {
 	// Only override expiry if none is set.
 	if ar.GetSession().GetExpiresAt(fosite.AccessToken).IsZero() {
-		ar.GetSession().SetExpiresAt(fosite.AccessToken, time.Now().UTC().Add(c.AccessTokenLifespan).Round(time.Second))
+		ar.GetSession().SetExpiresAt(fosite.AccessToken, time.Now().UTC().Add(fosite.GetAccessTokenLifespan(ctx, c.AccessTokenLifespan)).Round(time.Second))
 	}
 
 	// Generate the code
@@ -93,11 +93,13 @@ func (c *AuthorizeImplicitGrantTypeHandler) IssueImplicitAccessToken(ctx context
 		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
 	}
 
-	if err := c.AccessTokenStorage.CreateAccessTokenSession(ctx, signature, ar.Sanitize([]string{})); err != nil {
+	if err := fosite.StorageOperation(ctx, "CreateAccessTokenSession", func(ctx context.Context) error {
+		return c.AccessTokenStorage.CreateAccessTokenSession(ctx, signature, ar.Sanitize([]string{}))
+	}); err != nil {
 		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
 	}
 	resp.AddParameter("access_token", token)
-	resp.AddParameter("expires_in", strconv.FormatInt(int64(getExpiresIn(ar, fosite.AccessToken, c.AccessTokenLifespan, time.Now().UTC())/time.Second), 10))
+	resp.AddParameter("expires_in", strconv.FormatInt(int64(getExpiresIn(ar, fosite.AccessToken, fosite.GetAccessTokenLifespan(ctx, c.AccessTokenLifespan), time.Now().UTC())/time.Second), 10))
 	resp.AddParameter("token_type", "bearer")
 	resp.AddParameter("state", ar.GetState())
 	resp.AddParameter("scope", strings.Join(ar.GetGrantedScopes(), " "))
@@ -106,3 +108,8 @@ func (c *AuthorizeImplicitGrantTypeHandler) IssueImplicitAccessToken(ctx context
 
 	return nil
 }
+
+func (c *AuthorizeImplicitGrantTypeHandler) PopulateDiscoveryMetadata(ctx context.Context, metadata *fosite.DiscoveryMetadata) {
+	metadata.ResponseTypesSupported = append(metadata.ResponseTypesSupported, "token")
+	metadata.GrantTypesSupported = append(metadata.GrantTypesSupported, "implicit")
+}
@@ -22,6 +22,7 @@
 package oauth2
 
 import (
+	"context"
 	"fmt"
 	"testing"
 	"time"
@@ -164,7 +165,14 @@ func TestDefaultResponseMode_AuthorizeImplicit_EndpointHandler(t *testing.T) {
 		ResponseTypes: fosite.Arguments{"token"},
 	}
 
-	store.EXPECT().CreateAccessTokenSession(nil, "ats", gomock.Eq(areq.Sanitize([]string{}))).AnyTimes().Return(nil)
+	store.EXPECT().CreateAccessTokenSession(nil, "ats", gomock.Any()).DoAndReturn(func(_ context.Context, _ string, req fosite.Requester) error {
+		assert.Equal(t, areq.GetID(), req.GetID())
+		assert.Equal(t, areq.Sanitize([]string{}).GetRequestForm(), req.GetRequestForm())
+		assert.NotSame(t, areq.GetSession(), req.GetSession(), "the stored request must not alias the session")
+		assert.False(t, req.GetSession().GetExpiresAt(fosite.AccessToken).IsZero())
+		assert.Equal(t, areq.GetSession().GetExpiresAt(fosite.AccessToken), req.GetSession().GetExpiresAt(fosite.AccessToken))
+		return nil
+	})
 
 	aresp.EXPECT().AddParameter("access_token", "access.ats")
 	aresp.EXPECT().AddParameter("expires_in", gomock.Any())
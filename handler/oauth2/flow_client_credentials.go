@@ -34,22 +34,28 @@ type ClientCredentialsGrantHandler struct {
 	*HandleHelper
 	ScopeStrategy            fosite.ScopeStrategy
 	AudienceMatchingStrategy fosite.AudienceMatchingStrategy
+
+	// DefaultScopePolicy, if set, selects the scopes requested by clients which do not send the scope parameter.
+	DefaultScopePolicy ClientCredentialsScopePolicy
 }
 
 // IntrospectTokenEndpointRequest implements https://tools.ietf.org/html/rfc6749#section-4.4.2
-func (c *ClientCredentialsGrantHandler) HandleTokenEndpointRequest(_ context.Context, request fosite.AccessRequester) error {
+func (c *ClientCredentialsGrantHandler) HandleTokenEndpointRequest(ctx context.Context, request fosite.AccessRequester) error {
 	if !c.CanHandleTokenEndpointRequest(request) {
 		return errorsx.WithStack(fosite.ErrUnknownRequest)
 	}
 
 	client := request.GetClient()
+	if c.DefaultScopePolicy != nil && len(request.GetRequestedScopes()) == 0 {
+		request.SetRequestedScopes(c.DefaultScopePolicy.DefaultScopes(ctx, request))
+	}
 	for _, scope := range request.GetRequestedScopes() {
-		if !c.ScopeStrategy(client.GetScopes(), scope) {
+		if !fosite.ClientScopeStrategy(client, c.ScopeStrategy)(client.GetScopes(), scope) {
 			return errorsx.WithStack(fosite.ErrInvalidScope.WithHintf("The OAuth 2.0 Client is not allowed to request scope '%s'.", scope))
 		}
 	}
 
-	if err := c.AudienceMatchingStrategy(client.GetAudience(), request.GetRequestedAudience()); err != nil {
+	if err := fosite.GetAudienceMatchingStrategy(ctx, client, c.AudienceMatchingStrategy)(client.GetAudience(), request.GetRequestedAudience()); err != nil {
 		return err
 	}
 
@@ -61,7 +67,7 @@ func (c *ClientCredentialsGrantHandler) HandleTokenEndpointRequest(_ context.Con
 	}
 	// if the client is not public, he has already been authenticated by the access request handler.
 
-	request.GetSession().SetExpiresAt(fosite.AccessToken, time.Now().UTC().Add(c.AccessTokenLifespan))
+	request.GetSession().SetExpiresAt(fosite.AccessToken, time.Now().UTC().Add(fosite.GetAccessTokenLifespan(ctx, c.AccessTokenLifespan)))
 	return nil
 }
 
@@ -87,3 +93,7 @@ func (c *ClientCredentialsGrantHandler) CanHandleTokenEndpointRequest(requester
 	// Value MUST be set to "client_credentials".
 	return requester.GetGrantTypes().ExactOne("client_credentials")
 }
+
+func (c *ClientCredentialsGrantHandler) PopulateDiscoveryMetadata(ctx context.Context, metadata *fosite.DiscoveryMetadata) {
+	metadata.GrantTypesSupported = append(metadata.GrantTypesSupported, "client_credentials")
+}
@@ -0,0 +1,55 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package oauth2
+
+import (
+	"context"
+
+	"github.com/ory/fosite"
+)
+
+// ClientCredentialsScopePolicy decides which scopes a client credentials grant requests if the client does not send
+// the scope parameter. The returned scopes are validated against the client's scopes like requested ones.
+type ClientCredentialsScopePolicy interface {
+	// DefaultScopes returns the scopes to request on behalf of the client. No scopes are requested if it returns none.
+	DefaultScopes(ctx context.Context, requester fosite.AccessRequester) fosite.Arguments
+}
+
+// ClientCredentialsScopePolicyFunc is an adapter to allow the use of ordinary functions as ClientCredentialsScopePolicy.
+type ClientCredentialsScopePolicyFunc func(ctx context.Context, requester fosite.AccessRequester) fosite.Arguments
+
+func (f ClientCredentialsScopePolicyFunc) DefaultScopes(ctx context.Context, requester fosite.AccessRequester) fosite.Arguments {
+	return f(ctx, requester)
+}
+
+var (
+	// NoDefaultScopesPolicy requests no scopes if the scope parameter is missing. This is the behavior if no policy is
+	// set.
+	NoDefaultScopesPolicy ClientCredentialsScopePolicy = ClientCredentialsScopePolicyFunc(func(_ context.Context, _ fosite.AccessRequester) fosite.Arguments {
+		return nil
+	})
+
+	// AllClientScopesPolicy requests all scopes the client is registered with if the scope parameter is missing.
+	AllClientScopesPolicy ClientCredentialsScopePolicy = ClientCredentialsScopePolicyFunc(func(_ context.Context, requester fosite.AccessRequester) fosite.Arguments {
+		return append(fosite.Arguments{}, requester.GetClient().GetScopes()...)
+	})
+)
@@ -22,6 +22,7 @@
 package oauth2
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"testing"
@@ -88,6 +89,21 @@ func TestClientCredentials_HandleTokenEndpointRequest(t *testing.T) {
 				})
 			},
 		},
+		{
+			description: "should fail because the client selected the exact scope strategy",
+			expectErr:   fosite.ErrInvalidScope,
+			mock: func() {
+				areq.EXPECT().GetGrantTypes().Return(fosite.Arguments{"client_credentials"})
+				areq.EXPECT().GetRequestedScopes().Return([]string{"foo", "baz.bar"})
+				areq.EXPECT().GetClient().Return(&fosite.DefaultOpenIDConnectClient{
+					DefaultClient: &fosite.DefaultClient{
+						GrantTypes: fosite.Arguments{"client_credentials"},
+						Scopes:     []string{"foo", "baz"},
+					},
+					ScopeStrategy: fosite.ScopeStrategyExact,
+				})
+			},
+		},
 		{
 			description: "should pass",
 			mock: func() {
@@ -114,6 +130,67 @@ func TestClientCredentials_HandleTokenEndpointRequest(t *testing.T) {
 	}
 }
 
+func TestClientCredentials_HandleTokenEndpointRequest_DefaultScopes(t *testing.T) {
+	client := &fosite.DefaultClient{
+		GrantTypes: fosite.Arguments{"client_credentials"},
+		Scopes:     []string{"foo", "bar"},
+	}
+	for k, c := range []struct {
+		description string
+		policy      ClientCredentialsScopePolicy
+		requested   fosite.Arguments
+		expectErr   error
+		expect      fosite.Arguments
+	}{
+		{
+			description: "should request no scopes without a policy",
+		},
+		{
+			description: "should request no scopes with NoDefaultScopesPolicy",
+			policy:      NoDefaultScopesPolicy,
+		},
+		{
+			description: "should request all client scopes with AllClientScopesPolicy",
+			policy:      AllClientScopesPolicy,
+			expect:      fosite.Arguments{"foo", "bar"},
+		},
+		{
+			description: "should not apply the policy if scopes are requested",
+			policy:      AllClientScopesPolicy,
+			requested:   fosite.Arguments{"bar"},
+			expect:      fosite.Arguments{"bar"},
+		},
+		{
+			description: "should validate the scopes of a custom policy",
+			policy: ClientCredentialsScopePolicyFunc(func(_ context.Context, _ fosite.AccessRequester) fosite.Arguments {
+				return fosite.Arguments{"baz"}
+			}),
+			expectErr: fosite.ErrInvalidScope,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.description), func(t *testing.T) {
+			h := ClientCredentialsGrantHandler{
+				HandleHelper:             &HandleHelper{AccessTokenLifespan: time.Hour},
+				ScopeStrategy:            fosite.HierarchicScopeStrategy,
+				AudienceMatchingStrategy: fosite.DefaultAudienceMatchingStrategy,
+				DefaultScopePolicy:       c.policy,
+			}
+			areq := fosite.NewAccessRequest(new(fosite.DefaultSession))
+			areq.GrantTypes = fosite.Arguments{"client_credentials"}
+			areq.Client = client
+			areq.SetRequestedScopes(c.requested)
+
+			err := h.HandleTokenEndpointRequest(context.Background(), areq)
+			if c.expectErr != nil {
+				require.EqualError(t, err, c.expectErr.Error())
+				return
+			}
+			require.NoError(t, err)
+			require.ElementsMatch(t, c.expect, areq.GetRequestedScopes())
+		})
+	}
+}
+
 func TestClientCredentials_PopulateTokenEndpointResponse(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	store := internal.NewMockClientCredentialsGrantStorage(ctrl)
@@ -173,3 +250,28 @@ func TestClientCredentials_PopulateTokenEndpointResponse(t *testing.T) {
 		})
 	}
 }
+
+func TestClientCredentials_ContextOverrides(t *testing.T) {
+	h := ClientCredentialsGrantHandler{
+		HandleHelper:             &HandleHelper{AccessTokenLifespan: time.Hour},
+		ScopeStrategy:            fosite.HierarchicScopeStrategy,
+		AudienceMatchingStrategy: fosite.DefaultAudienceMatchingStrategy,
+	}
+
+	newRequest := func() *fosite.AccessRequest {
+		areq := fosite.NewAccessRequest(new(fosite.DefaultSession))
+		areq.GrantTypes = fosite.Arguments{"client_credentials"}
+		areq.Client = &fosite.DefaultClient{GrantTypes: fosite.Arguments{"client_credentials"}, Audience: []string{"https://*.example.com"}}
+		areq.RequestedAudience = fosite.Arguments{"https://api.example.com"}
+		return areq
+	}
+
+	areq := newRequest()
+	require.EqualError(t, h.HandleTokenEndpointRequest(context.Background(), areq), fosite.ErrInvalidRequest.Error())
+
+	ctx := fosite.WithAudienceMatchingStrategy(context.Background(), fosite.WildcardAudienceMatchingStrategy)
+	ctx = fosite.WithAccessTokenLifespan(ctx, time.Minute)
+	areq = newRequest()
+	require.NoError(t, h.HandleTokenEndpointRequest(ctx, areq))
+	require.WithinDuration(t, time.Now().UTC().Add(time.Minute), areq.GetSession().GetExpiresAt(fosite.AccessToken), time.Second*5)
+}
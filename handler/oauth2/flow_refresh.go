@@ -43,12 +43,31 @@ type RefreshTokenGrantHandler struct {
 	// AccessTokenLifespan defines the lifetime of an access token.
 	AccessTokenLifespan time.Duration
 
-	// RefreshTokenLifespan defines the lifetime of a refresh token.
+	// RefreshTokenLifespan defines the lifetime of a refresh token. The lifetime is renewed each time the refresh
+	// token is used.
 	RefreshTokenLifespan time.Duration
 
+	// RefreshTokenMaxLifespan defines the maximum lifetime of the refresh tokens issued for a grant, which is not
+	// renewed when a refresh token is used. Leave to 0 for unlimited lifetime.
+	RefreshTokenMaxLifespan time.Duration
+
 	ScopeStrategy            fosite.ScopeStrategy
 	AudienceMatchingStrategy fosite.AudienceMatchingStrategy
 	RefreshTokenScopes       []string
+
+	// RefreshTokenRotationGracePeriod allows a refresh token to be used again for the given duration after it has been
+	// exchanged for a new one, for example if the client did not receive the token response. Reuse detection applies
+	// once the grace period has passed. Requires TokenRevocationStorage to implement RefreshTokenRotationStorage.
+	// Defaults to no grace period.
+	RefreshTokenRotationGracePeriod time.Duration
+
+	// DisableRotationGracePeriodForPublicClients, if set to true, applies RefreshTokenRotationGracePeriod to
+	// confidential clients only, so that refresh tokens of public clients are strictly one-time use.
+	DisableRotationGracePeriodForPublicClients bool
+
+	// RefreshTokenReuseHook, if set, is called when a refresh token is used again after it has been exchanged for a
+	// new one. At that point, all tokens of the grant have already been revoked.
+	RefreshTokenReuseHook func(ctx context.Context, request fosite.Requester)
 }
 
 // HandleTokenEndpointRequest implements https://tools.ietf.org/html/rfc6749#section-6
@@ -63,13 +82,21 @@ func (c *RefreshTokenGrantHandler) HandleTokenEndpointRequest(ctx context.Contex
 
 	refresh := request.GetRequestForm().Get("refresh_token")
 	signature := c.RefreshTokenStrategy.RefreshTokenSignature(refresh)
-	originalRequest, err := c.TokenRevocationStorage.GetRefreshTokenSession(ctx, signature, request.GetSession())
+	originalRequest, err := GetRefreshTokenSession(ctx, c.RefreshTokenStrategy, c.TokenRevocationStorage, refresh, request.GetSession())
+	if errors.Is(err, fosite.ErrInactiveToken) && c.isRotatedWithinGracePeriod(ctx, request.GetClient(), signature) {
+		err = nil
+	}
+
 	if errors.Is(err, fosite.ErrInactiveToken) {
 		// Detected refresh token reuse
 		if rErr := c.handleRefreshTokenReuse(ctx, signature, originalRequest); rErr != nil {
 			return errorsx.WithStack(fosite.ErrServerError.WithWrap(rErr).WithDebug(rErr.Error()))
 		}
 
+		if c.RefreshTokenReuseHook != nil {
+			c.RefreshTokenReuseHook(ctx, originalRequest)
+		}
+
 		return errorsx.WithStack(fosite.ErrInactiveToken.WithWrap(err).WithDebug(err.Error()))
 	} else if errors.Is(err, fosite.ErrNotFound) {
 		return errorsx.WithStack(fosite.ErrInvalidGrant.WithWrap(err).WithDebugf("The refresh token has not been found: %s", err.Error()))
@@ -94,28 +121,42 @@ func (c *RefreshTokenGrantHandler) HandleTokenEndpointRequest(ctx context.Contex
 	}
 
 	request.SetSession(originalRequest.GetSession().Clone())
+	fosite.CarryOverPersistedParameters(request, originalRequest)
 	request.SetRequestedScopes(originalRequest.GetRequestedScopes())
 	request.SetRequestedAudience(originalRequest.GetRequestedAudience())
 
 	for _, scope := range originalRequest.GetGrantedScopes() {
-		if !c.ScopeStrategy(request.GetClient().GetScopes(), scope) {
+		if !fosite.ClientScopeStrategy(request.GetClient(), c.ScopeStrategy)(request.GetClient().GetScopes(), scope) {
 			return errorsx.WithStack(fosite.ErrInvalidScope.WithHintf("The OAuth 2.0 Client is not allowed to request scope '%s'.", scope))
 		}
 		request.GrantScope(scope)
 	}
 
-	if err := c.AudienceMatchingStrategy(request.GetClient().GetAudience(), originalRequest.GetGrantedAudience()); err != nil {
+	if err := fosite.GetAudienceMatchingStrategy(ctx, request.GetClient(), c.AudienceMatchingStrategy)(request.GetClient().GetAudience(), originalRequest.GetGrantedAudience()); err != nil {
 		return err
 	}
 
-	for _, audience := range originalRequest.GetGrantedAudience() {
-		request.GrantAudience(audience)
+	// https://tools.ietf.org/html/rfc8707#section-2.2
+	resources, err := fosite.GetResources(request.GetRequestForm())
+	if err != nil {
+		return err
+	}
+	audience, err := fosite.NarrowAudience(resources, originalRequest.GetGrantedAudience())
+	if err != nil {
+		return err
+	}
+	for _, aud := range audience {
+		request.GrantAudience(aud)
 	}
 
-	request.GetSession().SetExpiresAt(fosite.AccessToken, time.Now().UTC().Add(c.AccessTokenLifespan).Round(time.Second))
-	if c.RefreshTokenLifespan > -1 {
-		request.GetSession().SetExpiresAt(fosite.RefreshToken, time.Now().UTC().Add(c.RefreshTokenLifespan).Round(time.Second))
+	details, err := fosite.NarrowAuthorizationDetails(request.GetRequestedAuthorizationDetails(), originalRequest.GetAuthorizationDetails())
+	if err != nil {
+		return err
 	}
+	request.GrantAuthorizationDetails(details)
+
+	request.GetSession().SetExpiresAt(fosite.AccessToken, time.Now().UTC().Add(fosite.GetAccessTokenLifespan(ctx, c.AccessTokenLifespan)).Round(time.Second))
+	setRefreshTokenExpiry(request, fosite.GetRefreshTokenLifespan(ctx, c.RefreshTokenLifespan), c.RefreshTokenMaxLifespan, time.Now().UTC())
 
 	return nil
 }
@@ -131,41 +172,54 @@ func (c *RefreshTokenGrantHandler) PopulateTokenEndpointResponse(ctx context.Con
 		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
 	}
 
-	refreshToken, refreshSignature, err := c.RefreshTokenStrategy.GenerateRefreshToken(ctx, requester)
-	if err != nil {
-		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
-	}
-
-	signature := c.RefreshTokenStrategy.RefreshTokenSignature(requester.GetRequestForm().Get("refresh_token"))
+	refresh := requester.GetRequestForm().Get("refresh_token")
+	signature := c.RefreshTokenStrategy.RefreshTokenSignature(refresh)
 
 	ctx, err = storage.MaybeBeginTx(ctx, c.TokenRevocationStorage)
 	if err != nil {
 		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
 	}
 
-	ts, err := c.TokenRevocationStorage.GetRefreshTokenSession(ctx, signature, nil)
-	if err != nil {
+	ts, err := GetRefreshTokenSession(ctx, c.RefreshTokenStrategy, c.TokenRevocationStorage, refresh, nil)
+	rotated := errors.Is(err, fosite.ErrInactiveToken) && c.isRotatedWithinGracePeriod(ctx, requester.GetClient(), signature)
+	if err != nil && !rotated {
+		return c.handleRefreshTokenEndpointStorageError(ctx, true, err)
+	} else if err := fosite.StorageOperation(ctx, "RevokeAccessToken", func(ctx context.Context) error {
+		return c.TokenRevocationStorage.RevokeAccessToken(ctx, ts.GetID())
+	}); err != nil {
 		return c.handleRefreshTokenEndpointStorageError(ctx, true, err)
-	} else if err := c.TokenRevocationStorage.RevokeAccessToken(ctx, ts.GetID()); err != nil {
+	} else if err := fosite.StorageOperation(ctx, "RevokeRefreshToken", func(ctx context.Context) error {
+		return c.TokenRevocationStorage.RevokeRefreshToken(ctx, ts.GetID())
+	}); err != nil {
 		return c.handleRefreshTokenEndpointStorageError(ctx, true, err)
-	} else if err := c.TokenRevocationStorage.RevokeRefreshToken(ctx, ts.GetID()); err != nil {
+	} else if err := c.rotateRefreshToken(ctx, requester.GetClient(), signature, rotated); err != nil {
 		return c.handleRefreshTokenEndpointStorageError(ctx, true, err)
 	}
 
 	storeReq := requester.Sanitize([]string{})
 	storeReq.SetID(ts.GetID())
 
-	if err := c.TokenRevocationStorage.CreateAccessTokenSession(ctx, accessSignature, storeReq); err != nil {
+	if err := fosite.StorageOperation(ctx, "CreateAccessTokenSession", func(ctx context.Context) error {
+		return c.TokenRevocationStorage.CreateAccessTokenSession(ctx, accessSignature, storeReq)
+	}); err != nil {
+		return c.handleRefreshTokenEndpointStorageError(ctx, true, err)
+	}
+
+	// The refresh token is generated after the previous one has been revoked, as stateless refresh tokens issued until
+	// the revocation are rejected.
+	refreshReq := sanitizeRefreshTokenRequest(storeReq, ts.GetGrantedAudience())
+	refreshToken, refreshSignature, err := c.RefreshTokenStrategy.GenerateRefreshToken(ctx, refreshReq)
+	if err != nil {
 		return c.handleRefreshTokenEndpointStorageError(ctx, true, err)
 	}
 
-	if err := c.TokenRevocationStorage.CreateRefreshTokenSession(ctx, refreshSignature, storeReq); err != nil {
+	if err := CreateRefreshTokenSession(ctx, c.RefreshTokenStrategy, c.TokenRevocationStorage, refreshSignature, refreshReq); err != nil {
 		return c.handleRefreshTokenEndpointStorageError(ctx, true, err)
 	}
 
 	responder.SetAccessToken(accessToken)
 	responder.SetTokenType("bearer")
-	responder.SetExpiresIn(getExpiresIn(requester, fosite.AccessToken, c.AccessTokenLifespan, time.Now().UTC()))
+	responder.SetExpiresIn(getExpiresIn(requester, fosite.AccessToken, fosite.GetAccessTokenLifespan(ctx, c.AccessTokenLifespan), time.Now().UTC()))
 	responder.SetScopes(requester.GetGrantedScopes())
 	responder.SetExtra("refresh_token", refreshToken)
 
@@ -176,6 +230,46 @@ func (c *RefreshTokenGrantHandler) PopulateTokenEndpointResponse(ctx context.Con
 	return nil
 }
 
+// isRotatedWithinGracePeriod returns true if the refresh token has been exchanged for a new one during the grace period.
+func (c *RefreshTokenGrantHandler) isRotatedWithinGracePeriod(ctx context.Context, client fosite.Client, signature string) bool {
+	rs, ok := c.TokenRevocationStorage.(RefreshTokenRotationStorage)
+	gracePeriod := c.rotationGracePeriod(client)
+	if gracePeriod <= 0 || !ok {
+		return false
+	}
+
+	var rotatedAt time.Time
+	err := fosite.StorageOperation(ctx, "GetRefreshTokenRotatedAt", func(ctx context.Context) (err error) {
+		rotatedAt, err = rs.GetRefreshTokenRotatedAt(ctx, signature)
+		return err
+	})
+	if err != nil {
+		return false
+	}
+	return time.Now().UTC().Before(rotatedAt.Add(gracePeriod))
+}
+
+// rotateRefreshToken records when the refresh token has been exchanged for a new one, which starts the grace period.
+// Refresh tokens used again during the grace period keep the time of their first rotation. The grace period does not
+// apply to stateless refresh tokens.
+func (c *RefreshTokenGrantHandler) rotateRefreshToken(ctx context.Context, client fosite.Client, signature string, rotated bool) error {
+	rs, ok := c.TokenRevocationStorage.(RefreshTokenRotationStorage)
+	if _, stateless := c.RefreshTokenStrategy.(StatelessRefreshTokenStrategy); c.rotationGracePeriod(client) <= 0 || !ok || rotated || stateless {
+		return nil
+	}
+	return fosite.StorageOperation(ctx, "RotateRefreshToken", func(ctx context.Context) error {
+		return rs.RotateRefreshToken(ctx, signature, time.Now().UTC())
+	})
+}
+
+// rotationGracePeriod returns the rotation grace period which applies to the client.
+func (c *RefreshTokenGrantHandler) rotationGracePeriod(client fosite.Client) time.Duration {
+	if c.DisableRotationGracePeriodForPublicClients && client.IsPublic() {
+		return 0
+	}
+	return c.RefreshTokenRotationGracePeriod
+}
+
 // Reference: https://tools.ietf.org/html/rfc6819#section-5.2.2.3
 //
 //     The basic idea is to change the refresh token
@@ -192,7 +286,9 @@ func (c *RefreshTokenGrantHandler) handleRefreshTokenReuse(ctx context.Context,
 		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
 	}
 
-	if err := c.TokenRevocationStorage.DeleteRefreshTokenSession(ctx, signature); err != nil {
+	if err := fosite.StorageOperation(ctx, "DeleteRefreshTokenSession", func(ctx context.Context) error {
+		return c.TokenRevocationStorage.DeleteRefreshTokenSession(ctx, signature)
+	}); err != nil {
 		return c.handleRefreshTokenEndpointStorageError(ctx, true, err)
 	} else if err := c.TokenRevocationStorage.RevokeRefreshToken(
 		ctx, req.GetID(),
@@ -244,3 +340,7 @@ func (c *RefreshTokenGrantHandler) CanHandleTokenEndpointRequest(requester fosit
 	// Value MUST be set to "refresh_token".
 	return requester.GetGrantTypes().ExactOne("refresh_token")
 }
+
+func (c *RefreshTokenGrantHandler) PopulateDiscoveryMetadata(ctx context.Context, metadata *fosite.DiscoveryMetadata) {
+	metadata.GrantTypesSupported = append(metadata.GrantTypesSupported, "refresh_token")
+}
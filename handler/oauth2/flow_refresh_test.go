@@ -229,6 +229,62 @@ func TestRefreshFlow_HandleTokenEndpointRequest(t *testing.T) {
 						assert.Equal(t, time.Now().Add(time.Hour).UTC().Round(time.Second), areq.GetSession().GetExpiresAt(fosite.RefreshToken))
 					},
 				},
+				{
+					description: "should narrow the audience to the requested resource",
+					setup: func() {
+						areq.GrantTypes = fosite.Arguments{"refresh_token"}
+						areq.Client = &fosite.DefaultClient{
+							ID:         "foo",
+							GrantTypes: fosite.Arguments{"refresh_token"},
+							Scopes:     []string{"foo", "offline"},
+							Audience:   []string{"https://api.example.com", "https://other.example.com"},
+						}
+
+						token, sig, err := strategy.GenerateRefreshToken(nil, nil)
+						require.NoError(t, err)
+
+						areq.Form.Add("refresh_token", token)
+						areq.Form.Add("resource", "https://other.example.com")
+						err = store.CreateRefreshTokenSession(nil, sig, &fosite.Request{
+							Client:          areq.Client,
+							GrantedScope:    fosite.Arguments{"foo", "offline"},
+							GrantedAudience: fosite.Arguments{"https://api.example.com", "https://other.example.com"},
+							Session:         sess,
+							Form:            url.Values{},
+						})
+						require.NoError(t, err)
+					},
+					expect: func(t *testing.T) {
+						assert.Equal(t, fosite.Arguments{"https://other.example.com"}, areq.GrantedAudience)
+					},
+				},
+				{
+					description: "should fail because the requested resource has not been granted",
+					setup: func() {
+						areq.GrantTypes = fosite.Arguments{"refresh_token"}
+						areq.Client = &fosite.DefaultClient{
+							ID:         "foo",
+							GrantTypes: fosite.Arguments{"refresh_token"},
+							Scopes:     []string{"foo", "offline"},
+							Audience:   []string{"https://api.example.com", "https://other.example.com"},
+						}
+
+						token, sig, err := strategy.GenerateRefreshToken(nil, nil)
+						require.NoError(t, err)
+
+						areq.Form.Add("refresh_token", token)
+						areq.Form.Add("resource", "https://other.example.com")
+						err = store.CreateRefreshTokenSession(nil, sig, &fosite.Request{
+							Client:          areq.Client,
+							GrantedScope:    fosite.Arguments{"foo", "offline"},
+							GrantedAudience: fosite.Arguments{"https://api.example.com"},
+							Session:         sess,
+							Form:            url.Values{},
+						})
+						require.NoError(t, err)
+					},
+					expectErr: fosite.ErrInvalidTarget,
+				},
 				{
 					description: "should deny access on token reuse",
 					setup: func() {
@@ -296,6 +352,7 @@ func TestRefreshFlowTransactional_HandleTokenEndpointRequest(t *testing.T) {
 	var mockRevocationStore *internal.MockTokenRevocationStorage
 	request := fosite.NewAccessRequest(&fosite.DefaultSession{})
 	propagatedContext := context.Background()
+	transactionContext := context.WithValue(propagatedContext, fosite.StorageTransactionContextKey, true)
 
 	type transactionalStore struct {
 		storage.Transactional
@@ -327,22 +384,22 @@ func TestRefreshFlowTransactional_HandleTokenEndpointRequest(t *testing.T) {
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					DeleteRefreshTokenSession(propagatedContext, gomock.Any()).
+					DeleteRefreshTokenSession(transactionContext, gomock.Any()).
 					Return(nil).
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					RevokeRefreshToken(propagatedContext, gomock.Any()).
+					RevokeRefreshToken(transactionContext, gomock.Any()).
 					Return(nil).
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					RevokeAccessToken(propagatedContext, gomock.Any()).
+					RevokeAccessToken(transactionContext, gomock.Any()).
 					Return(nil).
 					Times(1)
 				mockTransactional.
 					EXPECT().
-					Commit(propagatedContext).
+					Commit(transactionContext).
 					Return(nil).
 					Times(1)
 			},
@@ -460,12 +517,198 @@ func TestRefreshFlow_PopulateTokenEndpointResponse(t *testing.T) {
 	}
 }
 
+func TestRefreshFlow_RotationGracePeriod(t *testing.T) {
+	store := storage.NewMemoryStore()
+	var reused fosite.Requester
+	h := RefreshTokenGrantHandler{
+		TokenRevocationStorage:          store,
+		RefreshTokenStrategy:            &hmacshaStrategy,
+		AccessTokenStrategy:             &hmacshaStrategy,
+		AccessTokenLifespan:             time.Hour,
+		RefreshTokenLifespan:            time.Hour,
+		ScopeStrategy:                   fosite.HierarchicScopeStrategy,
+		AudienceMatchingStrategy:        fosite.DefaultAudienceMatchingStrategy,
+		RefreshTokenRotationGracePeriod: time.Minute,
+		RefreshTokenReuseHook: func(_ context.Context, request fosite.Requester) {
+			reused = request
+		},
+	}
+	client := &fosite.DefaultClient{ID: "foo", GrantTypes: fosite.Arguments{"refresh_token"}, Scopes: []string{"offline"}}
+
+	refresh := func(t *testing.T, token string) (string, error) {
+		areq := fosite.NewAccessRequest(&fosite.DefaultSession{})
+		areq.GrantTypes = fosite.Arguments{"refresh_token"}
+		areq.Client = client
+		areq.Form = url.Values{"refresh_token": {token}}
+		if err := h.HandleTokenEndpointRequest(context.Background(), areq); err != nil {
+			return "", err
+		}
+
+		aresp := fosite.NewAccessResponse()
+		if err := h.PopulateTokenEndpointResponse(context.Background(), areq, aresp); err != nil {
+			return "", err
+		}
+		return aresp.ToMap()["refresh_token"].(string), nil
+	}
+
+	first, signature, err := hmacshaStrategy.GenerateRefreshToken(nil, nil)
+	require.NoError(t, err)
+	require.NoError(t, store.CreateRefreshTokenSession(nil, signature, &fosite.Request{
+		ID:           "req-id",
+		Client:       client,
+		GrantedScope: fosite.Arguments{"offline"},
+		Session:      &fosite.DefaultSession{},
+		Form:         url.Values{},
+	}))
+
+	second, err := refresh(t, first)
+	require.NoError(t, err)
+
+	t.Run("case=the rotated token can be used again during the grace period", func(t *testing.T) {
+		third, err := refresh(t, first)
+		require.NoError(t, err)
+		assert.NotEqual(t, second, third)
+		second = third
+	})
+
+	t.Run("case=reuse after the grace period revokes the token chain", func(t *testing.T) {
+		require.NoError(t, store.RotateRefreshToken(nil, signature, time.Now().UTC().Add(-time.Hour)))
+
+		_, err := refresh(t, first)
+		require.EqualError(t, err, fosite.ErrInactiveToken.Error())
+		require.NotNil(t, reused)
+		assert.Equal(t, "req-id", reused.GetID())
+
+		_, err = refresh(t, second)
+		require.EqualError(t, err, fosite.ErrInactiveToken.Error())
+	})
+}
+
+func TestRefreshFlow_RotationGracePeriodPublicClients(t *testing.T) {
+	store := storage.NewMemoryStore()
+	h := RefreshTokenGrantHandler{
+		TokenRevocationStorage:                     store,
+		RefreshTokenStrategy:                       &hmacshaStrategy,
+		AccessTokenStrategy:                        &hmacshaStrategy,
+		AccessTokenLifespan:                        time.Hour,
+		RefreshTokenLifespan:                       time.Hour,
+		ScopeStrategy:                              fosite.HierarchicScopeStrategy,
+		AudienceMatchingStrategy:                   fosite.DefaultAudienceMatchingStrategy,
+		RefreshTokenRotationGracePeriod:            time.Minute,
+		DisableRotationGracePeriodForPublicClients: true,
+	}
+	client := &fosite.DefaultClient{ID: "foo", GrantTypes: fosite.Arguments{"refresh_token"}, Scopes: []string{"offline"}, Public: true}
+
+	refresh := func(token string) error {
+		areq := fosite.NewAccessRequest(&fosite.DefaultSession{})
+		areq.GrantTypes = fosite.Arguments{"refresh_token"}
+		areq.Client = client
+		areq.Form = url.Values{"refresh_token": {token}}
+		if err := h.HandleTokenEndpointRequest(context.Background(), areq); err != nil {
+			return err
+		}
+		return h.PopulateTokenEndpointResponse(context.Background(), areq, fosite.NewAccessResponse())
+	}
+
+	token, signature, err := hmacshaStrategy.GenerateRefreshToken(nil, nil)
+	require.NoError(t, err)
+	require.NoError(t, store.CreateRefreshTokenSession(nil, signature, &fosite.Request{
+		ID:           "req-id",
+		Client:       client,
+		GrantedScope: fosite.Arguments{"offline"},
+		Session:      &fosite.DefaultSession{},
+		Form:         url.Values{},
+	}))
+
+	require.NoError(t, refresh(token))
+	require.EqualError(t, refresh(token), fosite.ErrInactiveToken.Error())
+}
+
+type refreshTokenLifespanClient struct {
+	*fosite.DefaultClient
+	lifespan    time.Duration
+	maxLifespan time.Duration
+}
+
+func (c *refreshTokenLifespanClient) GetRefreshTokenLifespan() time.Duration {
+	return c.lifespan
+}
+
+func (c *refreshTokenLifespanClient) GetRefreshTokenMaxLifespan() time.Duration {
+	return c.maxLifespan
+}
+
+func TestRefreshFlow_RefreshTokenExpiry(t *testing.T) {
+	h := RefreshTokenGrantHandler{
+		RefreshTokenStrategy:     &hmacshaStrategy,
+		AccessTokenLifespan:      time.Hour,
+		RefreshTokenLifespan:     time.Hour,
+		RefreshTokenMaxLifespan:  time.Hour * 24,
+		ScopeStrategy:            fosite.HierarchicScopeStrategy,
+		AudienceMatchingStrategy: fosite.DefaultAudienceMatchingStrategy,
+	}
+	defaultClient := &fosite.DefaultClient{ID: "foo", GrantTypes: fosite.Arguments{"refresh_token"}, Scopes: []string{"offline"}}
+
+	for k, c := range []struct {
+		description       string
+		client            fosite.Client
+		session           map[fosite.TokenType]time.Time
+		expectRefresh     time.Duration
+		expectMaxLifespan time.Duration
+	}{
+		{
+			description:       "should renew the refresh token and set the absolute expiry",
+			client:            defaultClient,
+			expectRefresh:     time.Hour,
+			expectMaxLifespan: time.Hour * 24,
+		},
+		{
+			description:       "should not extend the refresh token beyond the absolute expiry",
+			client:            defaultClient,
+			session:           map[fosite.TokenType]time.Time{fosite.RefreshTokenSession: time.Now().UTC().Add(time.Minute * 30).Round(time.Second)},
+			expectRefresh:     time.Minute * 30,
+			expectMaxLifespan: time.Minute * 30,
+		},
+		{
+			description:       "should use the lifespans of the client",
+			client:            &refreshTokenLifespanClient{DefaultClient: defaultClient, lifespan: time.Minute, maxLifespan: time.Hour * 2},
+			expectRefresh:     time.Minute,
+			expectMaxLifespan: time.Hour * 2,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.description), func(t *testing.T) {
+			store := storage.NewMemoryStore()
+			h.TokenRevocationStorage = store
+
+			token, signature, err := hmacshaStrategy.GenerateRefreshToken(nil, nil)
+			require.NoError(t, err)
+			require.NoError(t, store.CreateRefreshTokenSession(nil, signature, &fosite.Request{
+				Client:       c.client,
+				GrantedScope: fosite.Arguments{"offline"},
+				Session:      &fosite.DefaultSession{ExpiresAt: c.session},
+				Form:         url.Values{},
+			}))
+
+			areq := fosite.NewAccessRequest(&fosite.DefaultSession{})
+			areq.GrantTypes = fosite.Arguments{"refresh_token"}
+			areq.Client = c.client
+			areq.Form = url.Values{"refresh_token": {token}}
+			require.NoError(t, h.HandleTokenEndpointRequest(context.Background(), areq))
+
+			now := time.Now().UTC()
+			assert.WithinDuration(t, now.Add(c.expectRefresh), areq.GetSession().GetExpiresAt(fosite.RefreshToken), time.Second*2)
+			assert.WithinDuration(t, now.Add(c.expectMaxLifespan), areq.GetSession().GetExpiresAt(fosite.RefreshTokenSession), time.Second*2)
+		})
+	}
+}
+
 func TestRefreshFlowTransactional_PopulateTokenEndpointResponse(t *testing.T) {
 	var mockTransactional *internal.MockTransactional
 	var mockRevocationStore *internal.MockTokenRevocationStorage
 	request := fosite.NewAccessRequest(&fosite.DefaultSession{})
 	response := fosite.NewAccessResponse()
 	propagatedContext := context.Background()
+	transactionContext := context.WithValue(propagatedContext, fosite.StorageTransactionContextKey, true)
 
 	// some storage implementation that has support for transactions, notice the embedded type `storage.Transactional`
 	type transactionalStore struct {
@@ -489,32 +732,32 @@ func TestRefreshFlowTransactional_PopulateTokenEndpointResponse(t *testing.T) {
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					GetRefreshTokenSession(propagatedContext, gomock.Any(), nil).
+					GetRefreshTokenSession(transactionContext, gomock.Any(), nil).
 					Return(request, nil).
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					RevokeAccessToken(propagatedContext, gomock.Any()).
+					RevokeAccessToken(transactionContext, gomock.Any()).
 					Return(nil).
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					RevokeRefreshToken(propagatedContext, gomock.Any()).
+					RevokeRefreshToken(transactionContext, gomock.Any()).
 					Return(nil).
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					CreateAccessTokenSession(propagatedContext, gomock.Any(), gomock.Any()).
+					CreateAccessTokenSession(transactionContext, gomock.Any(), gomock.Any()).
 					Return(nil).
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					CreateRefreshTokenSession(propagatedContext, gomock.Any(), gomock.Any()).
+					CreateRefreshTokenSession(transactionContext, gomock.Any(), gomock.Any()).
 					Return(nil).
 					Times(1)
 				mockTransactional.
 					EXPECT().
-					Commit(propagatedContext).
+					Commit(transactionContext).
 					Return(nil).
 					Times(1)
 			},
@@ -530,12 +773,12 @@ func TestRefreshFlowTransactional_PopulateTokenEndpointResponse(t *testing.T) {
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					GetRefreshTokenSession(propagatedContext, gomock.Any(), nil).
+					GetRefreshTokenSession(transactionContext, gomock.Any(), nil).
 					Return(nil, errors.New("Whoops, a nasty database error occurred!")).
 					Times(1)
 				mockTransactional.
 					EXPECT().
-					Rollback(propagatedContext).
+					Rollback(transactionContext).
 					Return(nil).
 					Times(1)
 			},
@@ -553,12 +796,12 @@ func TestRefreshFlowTransactional_PopulateTokenEndpointResponse(t *testing.T) {
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					GetRefreshTokenSession(propagatedContext, gomock.Any(), nil).
+					GetRefreshTokenSession(transactionContext, gomock.Any(), nil).
 					Return(nil, fosite.ErrNotFound).
 					Times(1)
 				mockTransactional.
 					EXPECT().
-					Rollback(propagatedContext).
+					Rollback(transactionContext).
 					Return(nil).
 					Times(1)
 			},
@@ -575,17 +818,17 @@ func TestRefreshFlowTransactional_PopulateTokenEndpointResponse(t *testing.T) {
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					GetRefreshTokenSession(propagatedContext, gomock.Any(), nil).
+					GetRefreshTokenSession(transactionContext, gomock.Any(), nil).
 					Return(request, nil).
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					RevokeAccessToken(propagatedContext, gomock.Any()).
+					RevokeAccessToken(transactionContext, gomock.Any()).
 					Return(errors.New("Whoops, a nasty database error occurred!")).
 					Times(1)
 				mockTransactional.
 					EXPECT().
-					Rollback(propagatedContext).
+					Rollback(transactionContext).
 					Return(nil).
 					Times(1)
 			},
@@ -603,17 +846,17 @@ func TestRefreshFlowTransactional_PopulateTokenEndpointResponse(t *testing.T) {
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					GetRefreshTokenSession(propagatedContext, gomock.Any(), nil).
+					GetRefreshTokenSession(transactionContext, gomock.Any(), nil).
 					Return(request, nil).
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					RevokeAccessToken(propagatedContext, gomock.Any()).
+					RevokeAccessToken(transactionContext, gomock.Any()).
 					Return(fosite.ErrSerializationFailure).
 					Times(1)
 				mockTransactional.
 					EXPECT().
-					Rollback(propagatedContext).
+					Rollback(transactionContext).
 					Return(nil).
 					Times(1)
 			},
@@ -630,22 +873,22 @@ func TestRefreshFlowTransactional_PopulateTokenEndpointResponse(t *testing.T) {
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					GetRefreshTokenSession(propagatedContext, gomock.Any(), nil).
+					GetRefreshTokenSession(transactionContext, gomock.Any(), nil).
 					Return(request, nil).
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					RevokeAccessToken(propagatedContext, gomock.Any()).
+					RevokeAccessToken(transactionContext, gomock.Any()).
 					Return(nil).
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					RevokeRefreshToken(propagatedContext, gomock.Any()).
+					RevokeRefreshToken(transactionContext, gomock.Any()).
 					Return(errors.New("Whoops, a nasty database error occurred!")).
 					Times(1)
 				mockTransactional.
 					EXPECT().
-					Rollback(propagatedContext).
+					Rollback(transactionContext).
 					Return(nil).
 					Times(1)
 			},
@@ -663,22 +906,22 @@ func TestRefreshFlowTransactional_PopulateTokenEndpointResponse(t *testing.T) {
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					GetRefreshTokenSession(propagatedContext, gomock.Any(), nil).
+					GetRefreshTokenSession(transactionContext, gomock.Any(), nil).
 					Return(request, nil).
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					RevokeAccessToken(propagatedContext, gomock.Any()).
+					RevokeAccessToken(transactionContext, gomock.Any()).
 					Return(nil).
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					RevokeRefreshToken(propagatedContext, gomock.Any()).
+					RevokeRefreshToken(transactionContext, gomock.Any()).
 					Return(fosite.ErrSerializationFailure).
 					Times(1)
 				mockTransactional.
 					EXPECT().
-					Rollback(propagatedContext).
+					Rollback(transactionContext).
 					Return(nil).
 					Times(1)
 			},
@@ -695,27 +938,27 @@ func TestRefreshFlowTransactional_PopulateTokenEndpointResponse(t *testing.T) {
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					GetRefreshTokenSession(propagatedContext, gomock.Any(), nil).
+					GetRefreshTokenSession(transactionContext, gomock.Any(), nil).
 					Return(request, nil).
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					RevokeAccessToken(propagatedContext, gomock.Any()).
+					RevokeAccessToken(transactionContext, gomock.Any()).
 					Return(nil).
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					RevokeRefreshToken(propagatedContext, gomock.Any()).
+					RevokeRefreshToken(transactionContext, gomock.Any()).
 					Return(nil).
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					CreateAccessTokenSession(propagatedContext, gomock.Any(), gomock.Any()).
+					CreateAccessTokenSession(transactionContext, gomock.Any(), gomock.Any()).
 					Return(fosite.ErrSerializationFailure).
 					Times(1)
 				mockTransactional.
 					EXPECT().
-					Rollback(propagatedContext).
+					Rollback(transactionContext).
 					Return(nil).
 					Times(1)
 			},
@@ -731,27 +974,27 @@ func TestRefreshFlowTransactional_PopulateTokenEndpointResponse(t *testing.T) {
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					GetRefreshTokenSession(propagatedContext, gomock.Any(), nil).
+					GetRefreshTokenSession(transactionContext, gomock.Any(), nil).
 					Return(request, nil).
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					RevokeAccessToken(propagatedContext, gomock.Any()).
+					RevokeAccessToken(transactionContext, gomock.Any()).
 					Return(nil).
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					RevokeRefreshToken(propagatedContext, gomock.Any()).
+					RevokeRefreshToken(transactionContext, gomock.Any()).
 					Return(nil).
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					CreateAccessTokenSession(propagatedContext, gomock.Any(), gomock.Any()).
+					CreateAccessTokenSession(transactionContext, gomock.Any(), gomock.Any()).
 					Return(errors.New("Whoops, a nasty database error occurred!")).
 					Times(1)
 				mockTransactional.
 					EXPECT().
-					Rollback(propagatedContext).
+					Rollback(transactionContext).
 					Return(nil).
 					Times(1)
 			},
@@ -768,32 +1011,32 @@ func TestRefreshFlowTransactional_PopulateTokenEndpointResponse(t *testing.T) {
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					GetRefreshTokenSession(propagatedContext, gomock.Any(), nil).
+					GetRefreshTokenSession(transactionContext, gomock.Any(), nil).
 					Return(request, nil).
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					RevokeAccessToken(propagatedContext, gomock.Any()).
+					RevokeAccessToken(transactionContext, gomock.Any()).
 					Return(nil).
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					RevokeRefreshToken(propagatedContext, gomock.Any()).
+					RevokeRefreshToken(transactionContext, gomock.Any()).
 					Return(nil).
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					CreateAccessTokenSession(propagatedContext, gomock.Any(), gomock.Any()).
+					CreateAccessTokenSession(transactionContext, gomock.Any(), gomock.Any()).
 					Return(nil).
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					CreateRefreshTokenSession(propagatedContext, gomock.Any(), gomock.Any()).
+					CreateRefreshTokenSession(transactionContext, gomock.Any(), gomock.Any()).
 					Return(errors.New("Whoops, a nasty database error occurred!")).
 					Times(1)
 				mockTransactional.
 					EXPECT().
-					Rollback(propagatedContext).
+					Rollback(transactionContext).
 					Return(nil).
 					Times(1)
 			},
@@ -811,32 +1054,32 @@ func TestRefreshFlowTransactional_PopulateTokenEndpointResponse(t *testing.T) {
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					GetRefreshTokenSession(propagatedContext, gomock.Any(), nil).
+					GetRefreshTokenSession(transactionContext, gomock.Any(), nil).
 					Return(request, nil).
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					RevokeAccessToken(propagatedContext, gomock.Any()).
+					RevokeAccessToken(transactionContext, gomock.Any()).
 					Return(nil).
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					RevokeRefreshToken(propagatedContext, gomock.Any()).
+					RevokeRefreshToken(transactionContext, gomock.Any()).
 					Return(nil).
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					CreateAccessTokenSession(propagatedContext, gomock.Any(), gomock.Any()).
+					CreateAccessTokenSession(transactionContext, gomock.Any(), gomock.Any()).
 					Return(nil).
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					CreateRefreshTokenSession(propagatedContext, gomock.Any(), gomock.Any()).
+					CreateRefreshTokenSession(transactionContext, gomock.Any(), gomock.Any()).
 					Return(fosite.ErrSerializationFailure).
 					Times(1)
 				mockTransactional.
 					EXPECT().
-					Rollback(propagatedContext).
+					Rollback(transactionContext).
 					Return(nil).
 					Times(1)
 			},
@@ -865,12 +1108,12 @@ func TestRefreshFlowTransactional_PopulateTokenEndpointResponse(t *testing.T) {
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					GetRefreshTokenSession(propagatedContext, gomock.Any(), nil).
+					GetRefreshTokenSession(transactionContext, gomock.Any(), nil).
 					Return(nil, fosite.ErrNotFound).
 					Times(1)
 				mockTransactional.
 					EXPECT().
-					Rollback(propagatedContext).
+					Rollback(transactionContext).
 					Return(errors.New("Could not rollback transaction!")).
 					Times(1)
 			},
@@ -887,32 +1130,32 @@ func TestRefreshFlowTransactional_PopulateTokenEndpointResponse(t *testing.T) {
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					GetRefreshTokenSession(propagatedContext, gomock.Any(), nil).
+					GetRefreshTokenSession(transactionContext, gomock.Any(), nil).
 					Return(request, nil).
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					RevokeAccessToken(propagatedContext, gomock.Any()).
+					RevokeAccessToken(transactionContext, gomock.Any()).
 					Return(nil).
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					RevokeRefreshToken(propagatedContext, gomock.Any()).
+					RevokeRefreshToken(transactionContext, gomock.Any()).
 					Return(nil).
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					CreateAccessTokenSession(propagatedContext, gomock.Any(), gomock.Any()).
+					CreateAccessTokenSession(transactionContext, gomock.Any(), gomock.Any()).
 					Return(nil).
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					CreateRefreshTokenSession(propagatedContext, gomock.Any(), gomock.Any()).
+					CreateRefreshTokenSession(transactionContext, gomock.Any(), gomock.Any()).
 					Return(nil).
 					Times(1)
 				mockTransactional.
 					EXPECT().
-					Commit(propagatedContext).
+					Commit(transactionContext).
 					Return(errors.New("Could not commit transaction!")).
 					Times(1)
 			},
@@ -930,32 +1173,32 @@ func TestRefreshFlowTransactional_PopulateTokenEndpointResponse(t *testing.T) {
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					GetRefreshTokenSession(propagatedContext, gomock.Any(), nil).
+					GetRefreshTokenSession(transactionContext, gomock.Any(), nil).
 					Return(request, nil).
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					RevokeAccessToken(propagatedContext, gomock.Any()).
+					RevokeAccessToken(transactionContext, gomock.Any()).
 					Return(nil).
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					RevokeRefreshToken(propagatedContext, gomock.Any()).
+					RevokeRefreshToken(transactionContext, gomock.Any()).
 					Return(nil).
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					CreateAccessTokenSession(propagatedContext, gomock.Any(), gomock.Any()).
+					CreateAccessTokenSession(transactionContext, gomock.Any(), gomock.Any()).
 					Return(nil).
 					Times(1)
 				mockRevocationStore.
 					EXPECT().
-					CreateRefreshTokenSession(propagatedContext, gomock.Any(), gomock.Any()).
+					CreateRefreshTokenSession(transactionContext, gomock.Any(), gomock.Any()).
 					Return(nil).
 					Times(1)
 				mockTransactional.
 					EXPECT().
-					Commit(propagatedContext).
+					Commit(transactionContext).
 					Return(fosite.ErrSerializationFailure).
 					Times(1)
 			},
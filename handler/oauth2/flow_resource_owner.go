@@ -30,6 +30,7 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/ory/fosite"
+	"github.com/ory/fosite/storage"
 )
 
 type ResourceOwnerPasswordCredentialsGrantHandler struct {
@@ -41,6 +42,13 @@ type ResourceOwnerPasswordCredentialsGrantHandler struct {
 	AudienceMatchingStrategy fosite.AudienceMatchingStrategy
 	RefreshTokenScopes       []string
 
+	// FailedAuthenticationRecorder, if set, is informed about password attempts and may lock resource owners out.
+	FailedAuthenticationRecorder fosite.FailedAuthenticationRecorder
+
+	// ResourceOwnerAuthenticator, if set, authenticates resource owners instead of the storage and enables the
+	// MFAGrantType to complete multi-factor authentication challenges.
+	ResourceOwnerAuthenticator ResourceOwnerAuthenticator
+
 	*HandleHelper
 }
 
@@ -56,36 +64,79 @@ func (c *ResourceOwnerPasswordCredentialsGrantHandler) HandleTokenEndpointReques
 
 	client := request.GetClient()
 	for _, scope := range request.GetRequestedScopes() {
-		if !c.ScopeStrategy(client.GetScopes(), scope) {
+		if !fosite.ClientScopeStrategy(client, c.ScopeStrategy)(client.GetScopes(), scope) {
 			return errorsx.WithStack(fosite.ErrInvalidScope.WithHintf("The OAuth 2.0 Client is not allowed to request scope '%s'.", scope))
 		}
 	}
 
-	if err := c.AudienceMatchingStrategy(client.GetAudience(), request.GetRequestedAudience()); err != nil {
+	if err := fosite.GetAudienceMatchingStrategy(ctx, client, c.AudienceMatchingStrategy)(client.GetAudience(), request.GetRequestedAudience()); err != nil {
+		return err
+	}
+
+	if request.GetGrantTypes().ExactOne(MFAGrantType) {
+		if err := c.verifyMFAChallenge(ctx, request); err != nil {
+			return err
+		}
+	} else if err := c.authenticate(ctx, request); err != nil {
 		return err
 	}
 
+	// Credentials must not be passed around, potentially leaking to the database!
+	delete(request.GetRequestForm(), "password")
+
+	request.GetSession().SetExpiresAt(fosite.AccessToken, time.Now().UTC().Add(fosite.GetAccessTokenLifespan(ctx, c.AccessTokenLifespan)).Round(time.Second))
+	setRefreshTokenExpiry(request, fosite.GetRefreshTokenLifespan(ctx, c.RefreshTokenLifespan), c.RefreshTokenMaxLifespan, time.Now().UTC())
+
+	return nil
+}
+
+func (c *ResourceOwnerPasswordCredentialsGrantHandler) authenticate(ctx context.Context, request fosite.AccessRequester) error {
+	var mfaErr *MFARequiredError
 	username := request.GetRequestForm().Get("username")
 	password := request.GetRequestForm().Get("password")
 	if username == "" || password == "" {
 		return errorsx.WithStack(fosite.ErrInvalidRequest.WithHint("Username or password are missing from the POST body."))
-	} else if err := c.ResourceOwnerPasswordCredentialsGrantStorage.Authenticate(ctx, username, password); errors.Is(err, fosite.ErrNotFound) {
-		return errorsx.WithStack(fosite.ErrInvalidGrant.WithHint("Unable to authenticate the provided username and password credentials.").WithWrap(err).WithDebug(err.Error()))
+	} else if err := fosite.CheckAuthenticationAllowed(ctx, c.FailedAuthenticationRecorder, fosite.ResourceOwnerAuthentication, username); err != nil {
+		return err
+	} else if err := fosite.StorageOperation(ctx, "Authenticate", func(ctx context.Context) error {
+		if c.ResourceOwnerAuthenticator != nil {
+			return c.ResourceOwnerAuthenticator.AuthenticateResourceOwner(ctx, request, username, password)
+		}
+		return c.ResourceOwnerPasswordCredentialsGrantStorage.Authenticate(ctx, username, password)
+	}); errors.As(err, &mfaErr) {
+		// The password is correct, the resource owner is only missing the second factor.
+		fosite.RecordSuccessfulAuthentication(ctx, c.FailedAuthenticationRecorder, fosite.ResourceOwnerAuthentication, username)
+		return mfaRequired(mfaErr)
+	} else if errors.Is(err, fosite.ErrNotFound) {
+		return fosite.RecordFailedAuthentication(ctx, c.FailedAuthenticationRecorder, fosite.ResourceOwnerAuthentication, username, errorsx.WithStack(fosite.ErrInvalidGrant.WithHint("Unable to authenticate the provided username and password credentials.").WithWrap(err).WithDebug(err.Error())))
 	} else if err != nil {
 		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
 	}
+	fosite.RecordSuccessfulAuthentication(ctx, c.FailedAuthenticationRecorder, fosite.ResourceOwnerAuthentication, username)
+	return nil
+}
 
-	// Credentials must not be passed around, potentially leaking to the database!
-	delete(request.GetRequestForm(), "password")
-
-	request.GetSession().SetExpiresAt(fosite.AccessToken, time.Now().UTC().Add(c.AccessTokenLifespan).Round(time.Second))
-	if c.RefreshTokenLifespan > -1 {
-		request.GetSession().SetExpiresAt(fosite.RefreshToken, time.Now().UTC().Add(c.RefreshTokenLifespan).Round(time.Second))
+func (c *ResourceOwnerPasswordCredentialsGrantHandler) verifyMFAChallenge(ctx context.Context, request fosite.AccessRequester) error {
+	var mfaErr *MFARequiredError
+	token := request.GetRequestForm().Get(MFATokenParameter)
+	if token == "" {
+		return errorsx.WithStack(fosite.ErrInvalidRequest.WithHintf("The '%s' parameter is missing from the POST body.", MFATokenParameter))
+	} else if err := fosite.StorageOperation(ctx, "VerifyMFAChallenge", func(ctx context.Context) error {
+		return c.ResourceOwnerAuthenticator.VerifyMFAChallenge(ctx, request, token, request.GetRequestForm())
+	}); errors.As(err, &mfaErr) {
+		return mfaRequired(mfaErr)
+	} else if errors.Is(err, fosite.ErrNotFound) {
+		return errorsx.WithStack(fosite.ErrInvalidGrant.WithHint("Unable to verify the response to the multi-factor authentication challenge.").WithWrap(err).WithDebug(err.Error()))
+	} else if err != nil {
+		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
 	}
-
 	return nil
 }
 
+func mfaRequired(err *MFARequiredError) error {
+	return errorsx.WithStack(fosite.ErrMFARequired.WithExtra(MFATokenParameter, err.Token).WithWrap(err))
+}
+
 // PopulateTokenEndpointResponse implements https://tools.ietf.org/html/rfc6749#section-4.3.3
 func (c *ResourceOwnerPasswordCredentialsGrantHandler) PopulateTokenEndpointResponse(ctx context.Context, requester fosite.AccessRequester, responder fosite.AccessResponder) error {
 	if !c.CanHandleTokenEndpointRequest(requester) {
@@ -98,19 +149,32 @@ func (c *ResourceOwnerPasswordCredentialsGrantHandler) PopulateTokenEndpointResp
 		refresh, refreshSignature, err = c.RefreshTokenStrategy.GenerateRefreshToken(ctx, requester)
 		if err != nil {
 			return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
-		} else if err := c.ResourceOwnerPasswordCredentialsGrantStorage.CreateRefreshTokenSession(ctx, refreshSignature, requester.Sanitize([]string{})); err != nil {
-			return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+		}
+	}
+
+	ctx, err := storage.MaybeBeginTx(ctx, c.ResourceOwnerPasswordCredentialsGrantStorage)
+	if err != nil {
+		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	if refreshSignature != "" {
+		if err := CreateRefreshTokenSession(ctx, c.RefreshTokenStrategy, c.ResourceOwnerPasswordCredentialsGrantStorage, refreshSignature, requester.Sanitize([]string{})); err != nil {
+			return rollbackTx(ctx, c.ResourceOwnerPasswordCredentialsGrantStorage, errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error())))
 		}
 	}
 
 	if err := c.IssueAccessToken(ctx, requester, responder); err != nil {
-		return err
+		return rollbackTx(ctx, c.ResourceOwnerPasswordCredentialsGrantStorage, err)
 	}
 
 	if refresh != "" {
 		responder.SetExtra("refresh_token", refresh)
 	}
 
+	if err := storage.MaybeCommitTx(ctx, c.ResourceOwnerPasswordCredentialsGrantStorage); err != nil {
+		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
 	return nil
 }
 
@@ -121,5 +185,14 @@ func (c *ResourceOwnerPasswordCredentialsGrantHandler) CanSkipClientAuth(request
 func (c *ResourceOwnerPasswordCredentialsGrantHandler) CanHandleTokenEndpointRequest(requester fosite.AccessRequester) bool {
 	// grant_type REQUIRED.
 	// Value MUST be set to "password".
-	return requester.GetGrantTypes().ExactOne("password")
+	// The MFAGrantType continues a password grant which requires multi-factor authentication.
+	return requester.GetGrantTypes().ExactOne("password") ||
+		(c.ResourceOwnerAuthenticator != nil && requester.GetGrantTypes().ExactOne(MFAGrantType))
+}
+
+func (c *ResourceOwnerPasswordCredentialsGrantHandler) PopulateDiscoveryMetadata(ctx context.Context, metadata *fosite.DiscoveryMetadata) {
+	metadata.GrantTypesSupported = append(metadata.GrantTypesSupported, "password")
+	if c.ResourceOwnerAuthenticator != nil {
+		metadata.GrantTypesSupported = append(metadata.GrantTypesSupported, MFAGrantType)
+	}
 }
@@ -0,0 +1,66 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package oauth2
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/ory/fosite"
+)
+
+const (
+	// MFAGrantType is the grant type which completes a multi-factor authentication challenge of the resource owner
+	// password credentials grant.
+	MFAGrantType = "urn:ory:params:oauth:grant-type:mfa"
+
+	// MFATokenParameter is the parameter which carries the challenge token in the ErrMFARequired response and the
+	// MFAGrantType request.
+	MFATokenParameter = "mfa_token"
+)
+
+// ResourceOwnerAuthenticator authenticates the resource owners of the password grant and can require them to complete
+// a second factor, for example a one-time password, before tokens are issued. If the handler has no
+// ResourceOwnerAuthenticator, ResourceOwnerPasswordCredentialsGrantStorage.Authenticate is used instead.
+type ResourceOwnerAuthenticator interface {
+	// AuthenticateResourceOwner verifies the username and password. It returns an error wrapping fosite.ErrNotFound
+	// if they are invalid, or an *MFARequiredError if the resource owner has to complete a challenge.
+	AuthenticateResourceOwner(ctx context.Context, request fosite.AccessRequester, username, password string) error
+
+	// VerifyMFAChallenge verifies the response to a challenge issued by AuthenticateResourceOwner, which is sent in
+	// the form of the MFAGrantType request, for example as otp parameter. It returns an error wrapping
+	// fosite.ErrNotFound if the token or response are invalid, or another *MFARequiredError if a further challenge has
+	// to be completed. Implementations must make sure the challenge was issued to the client of the request, can
+	// only be completed once and limit the attempts.
+	VerifyMFAChallenge(ctx context.Context, request fosite.AccessRequester, token string, form url.Values) error
+}
+
+// MFARequiredError is returned by the ResourceOwnerAuthenticator if the resource owner has to complete a challenge.
+// The client receives the Token in the mfa_token field of a fosite.ErrMFARequired response, and sends it together
+// with the response to the challenge using the MFAGrantType.
+type MFARequiredError struct {
+	Token string
+}
+
+func (e *MFARequiredError) Error() string {
+	return "multi-factor authentication is required"
+}
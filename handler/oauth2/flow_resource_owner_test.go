@@ -22,6 +22,7 @@
 package oauth2
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"testing"
@@ -34,6 +35,7 @@ import (
 
 	"github.com/ory/fosite"
 	"github.com/ory/fosite/internal"
+	"github.com/ory/fosite/storage"
 )
 
 func TestResourceOwnerFlow_HandleTokenEndpointRequest(t *testing.T) {
@@ -138,6 +140,80 @@ func TestResourceOwnerFlow_HandleTokenEndpointRequest(t *testing.T) {
 	}
 }
 
+type otpAuthenticator struct {
+	challenges map[string]string
+}
+
+func (a *otpAuthenticator) AuthenticateResourceOwner(_ context.Context, request fosite.AccessRequester, username, password string) error {
+	if username != "peter" || password != "secret" {
+		return fosite.ErrNotFound
+	}
+	a.challenges["challenge-token"] = request.GetClient().GetID()
+	return &MFARequiredError{Token: "challenge-token"}
+}
+
+func (a *otpAuthenticator) VerifyMFAChallenge(_ context.Context, request fosite.AccessRequester, token string, form url.Values) error {
+	if clientID, ok := a.challenges[token]; !ok || clientID != request.GetClient().GetID() || form.Get("otp") != "123456" {
+		return fosite.ErrNotFound
+	}
+	delete(a.challenges, token)
+	return nil
+}
+
+func TestResourceOwnerFlow_HandleTokenEndpointRequest_MFA(t *testing.T) {
+	authenticator := &otpAuthenticator{challenges: map[string]string{}}
+	h := ResourceOwnerPasswordCredentialsGrantHandler{
+		ResourceOwnerAuthenticator: authenticator,
+		HandleHelper: &HandleHelper{
+			AccessTokenLifespan:  time.Hour,
+			RefreshTokenLifespan: time.Hour,
+		},
+		ScopeStrategy:            fosite.HierarchicScopeStrategy,
+		AudienceMatchingStrategy: fosite.DefaultAudienceMatchingStrategy,
+	}
+	client := &fosite.DefaultClient{ID: "foo", GrantTypes: fosite.Arguments{"password"}}
+
+	newRequest := func(grantType string, form url.Values) *fosite.AccessRequest {
+		areq := fosite.NewAccessRequest(new(fosite.DefaultSession))
+		areq.GrantTypes = fosite.Arguments{grantType}
+		areq.Client = client
+		areq.Form = form
+		return areq
+	}
+
+	t.Run("case=should not handle the mfa grant without authenticator", func(t *testing.T) {
+		assert.False(t, (&ResourceOwnerPasswordCredentialsGrantHandler{}).CanHandleTokenEndpointRequest(newRequest(MFAGrantType, url.Values{})))
+		assert.True(t, h.CanHandleTokenEndpointRequest(newRequest(MFAGrantType, url.Values{})))
+	})
+
+	t.Run("case=should fail because of invalid credentials", func(t *testing.T) {
+		err := h.HandleTokenEndpointRequest(context.Background(), newRequest("password", url.Values{"username": {"peter"}, "password": {"wrong"}}))
+		require.EqualError(t, err, fosite.ErrInvalidGrant.Error())
+	})
+
+	t.Run("case=should fail because the token is missing", func(t *testing.T) {
+		err := h.HandleTokenEndpointRequest(context.Background(), newRequest(MFAGrantType, url.Values{"otp": {"123456"}}))
+		require.EqualError(t, err, fosite.ErrInvalidRequest.Error())
+	})
+
+	t.Run("case=should complete the challenge", func(t *testing.T) {
+		err := h.HandleTokenEndpointRequest(context.Background(), newRequest("password", url.Values{"username": {"peter"}, "password": {"secret"}}))
+		require.EqualError(t, err, fosite.ErrMFARequired.Error())
+		token := fosite.ErrorToRFC6749Error(err).Extra(MFATokenParameter)
+		require.Equal(t, "challenge-token", token)
+
+		err = h.HandleTokenEndpointRequest(context.Background(), newRequest(MFAGrantType, url.Values{MFATokenParameter: {token}, "otp": {"654321"}}))
+		require.EqualError(t, err, fosite.ErrInvalidGrant.Error())
+
+		areq := newRequest(MFAGrantType, url.Values{MFATokenParameter: {token}, "otp": {"123456"}})
+		require.NoError(t, h.HandleTokenEndpointRequest(context.Background(), areq))
+		assert.NotEmpty(t, areq.GetSession().GetExpiresAt(fosite.AccessToken))
+
+		err = h.HandleTokenEndpointRequest(context.Background(), newRequest(MFAGrantType, url.Values{MFATokenParameter: {token}, "otp": {"123456"}}))
+		require.EqualError(t, err, fosite.ErrInvalidGrant.Error(), "the challenge can only be completed once")
+	})
+}
+
 func TestResourceOwnerFlow_PopulateTokenEndpointResponse(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	store := internal.NewMockResourceOwnerPasswordCredentialsGrantStorage(ctrl)
@@ -231,3 +307,93 @@ func TestResourceOwnerFlow_PopulateTokenEndpointResponse(t *testing.T) {
 		})
 	}
 }
+
+func TestResourceOwnerFlowTransactional_PopulateTokenEndpointResponse(t *testing.T) {
+	var mockTransactional *internal.MockTransactional
+	var mockStore *internal.MockResourceOwnerPasswordCredentialsGrantStorage
+	propagatedContext := context.Background()
+	transactionContext := context.WithValue(propagatedContext, fosite.StorageTransactionContextKey, true)
+
+	// some storage implementation that has support for transactions, notice the embedded type `storage.Transactional`
+	type transactionalStore struct {
+		storage.Transactional
+		ResourceOwnerPasswordCredentialsGrantStorage
+	}
+
+	for _, testCase := range []struct {
+		description string
+		setup       func()
+		expectError error
+	}{
+		{
+			description: "transaction should be committed successfully if no errors occur",
+			setup: func() {
+				mockTransactional.EXPECT().BeginTX(propagatedContext).Return(propagatedContext, nil)
+				mockStore.EXPECT().CreateRefreshTokenSession(transactionContext, gomock.Any(), gomock.Any()).Return(nil)
+				mockStore.EXPECT().CreateAccessTokenSession(transactionContext, gomock.Any(), gomock.Any()).Return(nil)
+				mockTransactional.EXPECT().Commit(transactionContext).Return(nil)
+			},
+		},
+		{
+			description: "transaction should be rolled back if `CreateAccessTokenSession` returns an error",
+			setup: func() {
+				mockTransactional.EXPECT().BeginTX(propagatedContext).Return(propagatedContext, nil)
+				mockStore.EXPECT().CreateRefreshTokenSession(transactionContext, gomock.Any(), gomock.Any()).Return(nil)
+				mockStore.EXPECT().CreateAccessTokenSession(transactionContext, gomock.Any(), gomock.Any()).Return(errors.New("Whoops, a nasty database error occurred!"))
+				mockTransactional.EXPECT().Rollback(transactionContext).Return(nil)
+			},
+			expectError: errors.New("Whoops, a nasty database error occurred!"),
+		},
+		{
+			description: "should result in a server error if transaction cannot be rolled back",
+			setup: func() {
+				mockTransactional.EXPECT().BeginTX(propagatedContext).Return(propagatedContext, nil)
+				mockStore.EXPECT().CreateRefreshTokenSession(transactionContext, gomock.Any(), gomock.Any()).Return(errors.New("Whoops, a nasty database error occurred!"))
+				mockTransactional.EXPECT().Rollback(transactionContext).Return(errors.New("Whoops, unable to rollback transaction!"))
+			},
+			expectError: fosite.ErrServerError,
+		},
+		{
+			description: "should result in a server error if transaction cannot be committed",
+			setup: func() {
+				mockTransactional.EXPECT().BeginTX(propagatedContext).Return(propagatedContext, nil)
+				mockStore.EXPECT().CreateRefreshTokenSession(transactionContext, gomock.Any(), gomock.Any()).Return(nil)
+				mockStore.EXPECT().CreateAccessTokenSession(transactionContext, gomock.Any(), gomock.Any()).Return(nil)
+				mockTransactional.EXPECT().Commit(transactionContext).Return(errors.New("Whoops, unable to commit transaction!"))
+			},
+			expectError: fosite.ErrServerError,
+		},
+	} {
+		t.Run(fmt.Sprintf("scenario=%s", testCase.description), func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockTransactional = internal.NewMockTransactional(ctrl)
+			mockStore = internal.NewMockResourceOwnerPasswordCredentialsGrantStorage(ctrl)
+			testCase.setup()
+
+			store := transactionalStore{mockTransactional, mockStore}
+			h := ResourceOwnerPasswordCredentialsGrantHandler{
+				ResourceOwnerPasswordCredentialsGrantStorage: store,
+				HandleHelper: &HandleHelper{
+					AccessTokenStorage:  store,
+					AccessTokenStrategy: hmacshaStrategy,
+					AccessTokenLifespan: time.Hour,
+				},
+				RefreshTokenStrategy: hmacshaStrategy,
+				RefreshTokenScopes:   []string{"offline"},
+			}
+
+			areq := fosite.NewAccessRequest(&fosite.DefaultSession{})
+			areq.GrantTypes = fosite.Arguments{"password"}
+			areq.GrantScope("offline")
+
+			err := h.PopulateTokenEndpointResponse(propagatedContext, areq, fosite.NewAccessResponse())
+			if testCase.expectError != nil {
+				assert.EqualError(t, err, testCase.expectError.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -25,7 +25,10 @@ import (
 	"context"
 	"time"
 
+	"github.com/ory/x/errorsx"
+
 	"github.com/ory/fosite"
+	"github.com/ory/fosite/storage"
 )
 
 type HandleHelper struct {
@@ -33,23 +36,87 @@ type HandleHelper struct {
 	AccessTokenStorage   AccessTokenStorage
 	AccessTokenLifespan  time.Duration
 	RefreshTokenLifespan time.Duration
+
+	// RefreshTokenMaxLifespan defines the maximum lifetime of the refresh tokens issued for a grant, which is not
+	// renewed when a refresh token is used. Leave to 0 for unlimited lifetime.
+	RefreshTokenMaxLifespan time.Duration
 }
 
 func (h *HandleHelper) IssueAccessToken(ctx context.Context, requester fosite.AccessRequester, responder fosite.AccessResponder) error {
 	token, signature, err := h.AccessTokenStrategy.GenerateAccessToken(ctx, requester)
 	if err != nil {
 		return err
-	} else if err := h.AccessTokenStorage.CreateAccessTokenSession(ctx, signature, requester.Sanitize([]string{})); err != nil {
+	} else if err := fosite.StorageOperation(ctx, "CreateAccessTokenSession", func(ctx context.Context) error {
+		return h.AccessTokenStorage.CreateAccessTokenSession(ctx, signature, requester.Sanitize([]string{}))
+	}); err != nil {
 		return err
 	}
 
 	responder.SetAccessToken(token)
 	responder.SetTokenType("bearer")
-	responder.SetExpiresIn(getExpiresIn(requester, fosite.AccessToken, h.AccessTokenLifespan, time.Now().UTC()))
+	responder.SetExpiresIn(getExpiresIn(requester, fosite.AccessToken, fosite.GetAccessTokenLifespan(ctx, h.AccessTokenLifespan), time.Now().UTC()))
 	responder.SetScopes(requester.GetGrantedScopes())
 	return nil
 }
 
+// rollbackTx rolls back the transaction of the context if the store supports transactions. It returns err unless the
+// rollback fails, in which case a server error describing both errors is returned.
+func rollbackTx(ctx context.Context, store interface{}, err error) error {
+	if rollBackTxnErr := storage.MaybeRollbackTx(ctx, store); rollBackTxnErr != nil {
+		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebugf("error: %s; rollback error: %s", err, rollBackTxnErr))
+	}
+	return err
+}
+
+// sanitizeRefreshTokenRequest sanitizes the request for storing it as refresh token session. The refresh token keeps the
+// audience of the original grant even if the access token has been restricted to some of the granted resources, see
+// https://tools.ietf.org/html/rfc8707#section-2.2
+func sanitizeRefreshTokenRequest(requester fosite.Requester, grantedAudience fosite.Arguments) fosite.Requester {
+	sanitized := requester.Sanitize([]string{})
+	for _, audience := range grantedAudience {
+		sanitized.GrantAudience(audience)
+	}
+	return sanitized
+}
+
+// getRefreshTokenLifespans returns the refresh token lifespan and maximum lifespan for the client, falling back to the
+// given defaults if the client does not override them.
+func getRefreshTokenLifespans(client fosite.Client, lifespan, maxLifespan time.Duration) (time.Duration, time.Duration) {
+	if c, ok := client.(fosite.RefreshTokenLifespanClient); ok {
+		if l := c.GetRefreshTokenLifespan(); l != 0 {
+			lifespan = l
+		}
+		if l := c.GetRefreshTokenMaxLifespan(); l != 0 {
+			maxLifespan = l
+		}
+	}
+	return lifespan, maxLifespan
+}
+
+// setRefreshTokenExpiry sets the expiry of the refresh token which is about to be issued. The expiry slides by lifespan
+// each time a refresh token is issued but never exceeds the absolute expiry of the grant, which is set once from
+// maxLifespan. A lifespan of -1 leaves the refresh token without an expiry other than the absolute one, a
+// maxLifespan of zero or less disables the absolute expiry.
+func setRefreshTokenExpiry(requester fosite.Requester, lifespan, maxLifespan time.Duration, now time.Time) {
+	lifespan, maxLifespan = getRefreshTokenLifespans(requester.GetClient(), lifespan, maxLifespan)
+
+	session := requester.GetSession()
+	if maxLifespan > 0 && session.GetExpiresAt(fosite.RefreshTokenSession).IsZero() {
+		session.SetExpiresAt(fosite.RefreshTokenSession, now.Add(maxLifespan).Round(time.Second))
+	}
+
+	absolute := session.GetExpiresAt(fosite.RefreshTokenSession)
+	if lifespan > -1 {
+		expiresAt := now.Add(lifespan).Round(time.Second)
+		if !absolute.IsZero() && absolute.Before(expiresAt) {
+			expiresAt = absolute
+		}
+		session.SetExpiresAt(fosite.RefreshToken, expiresAt)
+	} else if !absolute.IsZero() {
+		session.SetExpiresAt(fosite.RefreshToken, absolute)
+	}
+}
+
 func getExpiresIn(r fosite.Requester, key fosite.TokenType, defaultLifespan time.Duration, now time.Time) time.Duration {
 	if r.GetSession().GetExpiresAt(key).IsZero() {
 		return defaultLifespan
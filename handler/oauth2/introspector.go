@@ -44,6 +44,13 @@ func (c *CoreValidator) IntrospectToken(ctx context.Context, token string, token
 		return fosite.AccessToken, nil
 	}
 
+	if s, ok := c.CoreStrategy.(TokenTypeStrategy); ok {
+		// The token prefix is more reliable than the token type hint of the client.
+		if tokenType, ok := s.TokenType(token); ok {
+			tokenUse = tokenType
+		}
+	}
+
 	var err error
 	switch tokenUse {
 	case fosite.RefreshToken:
@@ -80,7 +87,11 @@ func matchScopes(ss fosite.ScopeStrategy, granted, scopes []string) error {
 
 func (c *CoreValidator) introspectAccessToken(ctx context.Context, token string, accessRequest fosite.AccessRequester, scopes []string) error {
 	sig := c.CoreStrategy.AccessTokenSignature(token)
-	or, err := c.CoreStorage.GetAccessTokenSession(ctx, sig, accessRequest.GetSession())
+	var or fosite.Requester
+	err := fosite.StorageOperation(ctx, "GetAccessTokenSession", func(ctx context.Context) (err error) {
+		or, err = c.CoreStorage.GetAccessTokenSession(ctx, sig, accessRequest.GetSession())
+		return err
+	})
 	if err != nil {
 		return errorsx.WithStack(fosite.ErrRequestUnauthorized.WithWrap(err).WithDebug(err.Error()))
 	} else if err := c.CoreStrategy.ValidateAccessToken(ctx, or, token); err != nil {
@@ -96,8 +107,7 @@ func (c *CoreValidator) introspectAccessToken(ctx context.Context, token string,
 }
 
 func (c *CoreValidator) introspectRefreshToken(ctx context.Context, token string, accessRequest fosite.AccessRequester, scopes []string) error {
-	sig := c.CoreStrategy.RefreshTokenSignature(token)
-	or, err := c.CoreStorage.GetRefreshTokenSession(ctx, sig, accessRequest.GetSession())
+	or, err := GetRefreshTokenSession(ctx, c.CoreStrategy, c.CoreStorage, token, accessRequest.GetSession())
 
 	if err != nil {
 		return errorsx.WithStack(fosite.ErrRequestUnauthorized.WithWrap(err).WithDebug(err.Error()))
@@ -117,3 +117,26 @@ func TestIntrospectToken(t *testing.T) {
 		})
 	}
 }
+
+func TestIntrospectToken_TokenPrefix(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := internal.NewMockCoreStorage(ctrl)
+	defer ctrl.Finish()
+
+	strategy := hmacshaStrategy
+	strategy.TokenPrefixes = fosite.DefaultTokenPrefixes
+	v := &CoreValidator{
+		CoreStrategy:  strategy,
+		CoreStorage:   store,
+		ScopeStrategy: fosite.HierarchicScopeStrategy,
+	}
+
+	token, signature, err := strategy.GenerateRefreshToken(nil, &hmacValidCase)
+	require.NoError(t, err)
+
+	// The access token storage must not be queried although the client hinted an access token.
+	store.EXPECT().GetRefreshTokenSession(nil, signature, nil).Return(&hmacValidCase, nil)
+	tu, err := v.IntrospectToken(nil, token, fosite.AccessToken, fosite.NewAccessRequest(nil), []string{})
+	require.NoError(t, err)
+	assert.Equal(t, fosite.RefreshToken, tu)
+}
@@ -0,0 +1,225 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package oauth2
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/square/go-jose.v2"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/token/jwt"
+	"github.com/ory/x/errorsx"
+)
+
+// ResourceServerValidator validates JWT access tokens as defined in https://tools.ietf.org/html/rfc9068#section-4
+// offline, without access to the storage of the authorization server. It is meant to be used by resource servers:
+//
+//	validator := &oauth2.ResourceServerValidator{
+//		Issuer:   "https://auth.example.com/",
+//		Audience: "https://api.example.com/",
+//		JWKSURI:  "https://auth.example.com/.well-known/jwks.json",
+//	}
+//	requester, err := validator.ValidateAccessToken(ctx, fosite.AccessTokenFromRequest(r), "photos.read")
+//
+// Revoked tokens remain valid until they expire, use token introspection if revocation must be respected.
+type ResourceServerValidator struct {
+	// Issuer is the required "iss" claim, the issuer identifier of the authorization server.
+	Issuer string
+
+	// Audience is the identifier of the resource server, which must be contained in the "aud" claim.
+	Audience string
+
+	// Keys are the keys the authorization server signs access tokens with. If nil, they are fetched from JWKSURI.
+	Keys *jose.JSONWebKeySet
+
+	// JWKSURI is the location of the JSON Web Key Set of the authorization server.
+	JWKSURI string
+
+	// JWKSFetcher fetches the JSON Web Key Set from JWKSURI. Defaults to fosite.NewDefaultJWKSFetcherStrategy().
+	JWKSFetcher fosite.JWKSFetcherStrategy
+
+	// SigningAlgorithms, if set, are the accepted "alg" headers. Tokens which are not signed with an asymmetric
+	// algorithm are always rejected.
+	SigningAlgorithms []string
+
+	// ScopeStrategy matches the scopes required by the resource server. Defaults to fosite.ExactScopeStrategy.
+	ScopeStrategy fosite.ScopeStrategy
+
+	// Leeway is the tolerated clock skew when checking the "exp", "nbf" and "iat" claims.
+	Leeway time.Duration
+
+	jwksFetcher     fosite.JWKSFetcherStrategy
+	jwksFetcherOnce sync.Once
+}
+
+// timeValidationErrors are the errors of the time based claims, which are checked by the ResourceServerValidator
+// itself to account for the Leeway.
+const timeValidationErrors = jwt.ValidationErrorExpired | jwt.ValidationErrorIssuedAt | jwt.ValidationErrorNotValidYet
+
+// ValidateAccessToken validates the signature, the "typ" header and the "iss", "aud", "exp", "nbf" and "iat" claims
+// of the token and checks that the scopes were granted. It returns the request the token was issued for.
+func (v *ResourceServerValidator) ValidateAccessToken(ctx context.Context, token string, scopes ...string) (fosite.Requester, error) {
+	if v.Issuer == "" || v.Audience == "" {
+		return nil, errorsx.WithStack(fosite.ErrMisconfiguration.WithDebug("The resource server validator requires an issuer and an audience."))
+	}
+
+	t, err := jwt.ParseWithClaims(token, jwt.MapClaims{}, v.keyfunc(ctx))
+	var ve *jwt.ValidationError
+	if errors.As(err, &ve) && ve.Errors&^timeValidationErrors == 0 {
+		// The time based claims are checked below.
+		err = nil
+	}
+	if err != nil {
+		var rfcerr *fosite.RFC6749Error
+		if errors.As(err, &ve) && errors.As(ve.Inner, &rfcerr) {
+			// The error of the keyfunc.
+			return nil, ve.Inner
+		} else if ve != nil {
+			return nil, errorsx.WithStack(toRFCErr(ve).WithWrap(err).WithDebug(err.Error()))
+		}
+		return nil, errorsx.WithStack(fosite.ErrRequestUnauthorized.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	if !isAccessTokenJWT(t) {
+		return nil, errorsx.WithStack(fosite.ErrInvalidTokenFormat.WithHintf("The access token must be of type '%s'.", AccessTokenJWTType))
+	}
+	if err := v.validateClaims(t.Claims); err != nil {
+		return nil, err
+	}
+
+	requester := AccessTokenJWTToRequest(t)
+	if err := matchScopes(v.scopeStrategy(), requester.GetGrantedScopes(), scopes); err != nil {
+		return nil, err
+	}
+	return requester, nil
+}
+
+func (v *ResourceServerValidator) validateClaims(claims jwt.MapClaims) error {
+	now := jwt.TimeFunc()
+	leeway := int64(v.Leeway / time.Second)
+
+	if !claims.VerifyIssuer(v.Issuer, true) {
+		return errorsx.WithStack(fosite.ErrTokenClaim.WithHintf("The access token was not issued by '%s'.", v.Issuer))
+	} else if !claims.VerifyAudience(v.Audience, true) {
+		return errorsx.WithStack(fosite.ErrTokenClaim.WithHintf("The access token is not intended for audience '%s'.", v.Audience))
+	} else if !claims.VerifyExpiresAt(now.Unix()-leeway, true) {
+		return errorsx.WithStack(fosite.ErrTokenExpired.WithHint("The access token expired or carries no expiry."))
+	} else if !claims.VerifyNotBefore(now.Unix()+leeway, false) {
+		return errorsx.WithStack(fosite.ErrTokenClaim.WithHint("The access token is not valid yet."))
+	} else if !claims.VerifyIssuedAt(now.Unix()+leeway, false) {
+		return errorsx.WithStack(fosite.ErrTokenClaim.WithHint("The access token was issued in the future."))
+	}
+	return nil
+}
+
+// keyfunc returns the key of the token's "kid" header. The key set is refreshed once if the key can not be found
+// to pick up rotated keys.
+func (v *ResourceServerValidator) keyfunc(ctx context.Context) jwt.Keyfunc {
+	return func(t *jwt.Token) (interface{}, error) {
+		alg := string(t.Method)
+		if !isAsymmetricAlgorithm(alg) {
+			return nil, errorsx.WithStack(fosite.ErrTokenSignatureMismatch.WithHintf("The access token is signed with unsupported algorithm '%s'.", alg))
+		} else if len(v.SigningAlgorithms) > 0 && !fosite.StringInSlice(alg, v.SigningAlgorithms) {
+			return nil, errorsx.WithStack(fosite.ErrTokenSignatureMismatch.WithHintf("The access token is signed with algorithm '%s', which is not accepted.", alg))
+		}
+
+		kid, _ := t.Header["kid"].(string)
+		set, err := v.keySet(false)
+		if err != nil {
+			return nil, err
+		}
+		key := findVerificationKey(set, kid, alg)
+		if key == nil && v.Keys == nil {
+			if set, err = v.keySet(true); err != nil {
+				return nil, err
+			}
+			key = findVerificationKey(set, kid, alg)
+		}
+		if key == nil {
+			return nil, errorsx.WithStack(fosite.ErrTokenSignatureMismatch.WithHintf("Unable to find a key with kid '%s' and algorithm '%s' to verify the access token.", kid, alg))
+		}
+		return key.Key, nil
+	}
+}
+
+func (v *ResourceServerValidator) keySet(forceRefresh bool) (*jose.JSONWebKeySet, error) {
+	if v.Keys != nil {
+		return v.Keys, nil
+	} else if v.JWKSURI == "" {
+		return nil, errorsx.WithStack(fosite.ErrMisconfiguration.WithDebug("The resource server validator requires keys or a JWKS URI."))
+	}
+
+	set, err := v.fetcher().Resolve(v.JWKSURI, forceRefresh)
+	if err != nil {
+		return nil, errorsx.WithStack(fosite.ErrServerError.WithHint("Unable to fetch the JSON Web Key Set of the authorization server.").WithWrap(err).WithDebug(err.Error()))
+	}
+	return set, nil
+}
+
+func (v *ResourceServerValidator) fetcher() fosite.JWKSFetcherStrategy {
+	if v.JWKSFetcher != nil {
+		return v.JWKSFetcher
+	}
+	v.jwksFetcherOnce.Do(func() {
+		v.jwksFetcher = fosite.NewDefaultJWKSFetcherStrategy()
+	})
+	return v.jwksFetcher
+}
+
+func (v *ResourceServerValidator) scopeStrategy() fosite.ScopeStrategy {
+	if v.ScopeStrategy == nil {
+		return fosite.ExactScopeStrategy
+	}
+	return v.ScopeStrategy
+}
+
+// findVerificationKey returns the public signing key of the set matching kid and alg. If kid is empty, the set
+// must contain exactly one matching key.
+func findVerificationKey(set *jose.JSONWebKeySet, kid, alg string) *jose.JSONWebKey {
+	keys := set.Keys
+	if kid != "" {
+		keys = set.Key(kid)
+	}
+
+	var found []jose.JSONWebKey
+	for _, key := range keys {
+		if (key.Use == "" || key.Use == "sig") && (key.Algorithm == "" || key.Algorithm == alg) {
+			found = append(found, key.Public())
+		}
+	}
+	if len(found) != 1 || !found[0].Valid() {
+		return nil
+	}
+	return &found[0]
+}
+
+func isAsymmetricAlgorithm(alg string) bool {
+	switch jose.SignatureAlgorithm(alg) {
+	case jose.RS256, jose.RS384, jose.RS512, jose.PS256, jose.PS384, jose.PS512, jose.ES256, jose.ES384, jose.ES512, jose.EdDSA:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,184 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package oauth2
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/token/jwt"
+)
+
+func TestResourceServerValidator(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	signer := &jwt.RS256JWTStrategy{PrivateKey: key}
+	keys := &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: &key.PublicKey, KeyID: "key-1", Algorithm: "RS256", Use: "sig"}}}
+
+	issue := func(t *testing.T, claims jwt.MapClaims, header map[string]interface{}) string {
+		c := jwt.MapClaims{
+			"iss":   "https://auth.example.com/",
+			"aud":   []string{"https://api.example.com/"},
+			"sub":   "peter",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"iat":   time.Now().Unix(),
+			"scope": "photos.read photos.write",
+		}
+		for k, v := range claims {
+			if v == nil {
+				delete(c, k)
+			} else {
+				c[k] = v
+			}
+		}
+		h := map[string]interface{}{"typ": "at+jwt", "kid": "key-1"}
+		for k, v := range header {
+			h[k] = v
+		}
+		token, _, err := signer.Generate(context.Background(), c, &jwt.Headers{Extra: h})
+		require.NoError(t, err)
+		return token
+	}
+
+	v := &ResourceServerValidator{
+		Issuer:   "https://auth.example.com/",
+		Audience: "https://api.example.com/",
+		Keys:     keys,
+		Leeway:   time.Minute,
+	}
+
+	for k, c := range []struct {
+		d         string
+		claims    jwt.MapClaims
+		header    map[string]interface{}
+		scopes    []string
+		expectErr error
+	}{
+		{
+			d:      "should pass",
+			scopes: []string{"photos.read"},
+		},
+		{
+			d:      "should pass with the media type of the typ header",
+			header: map[string]interface{}{"typ": "application/at+jwt"},
+		},
+		{
+			d:      "should pass within the leeway",
+			claims: jwt.MapClaims{"exp": time.Now().Add(-time.Second * 30).Unix()},
+		},
+		{
+			d:         "should fail because expired",
+			claims:    jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()},
+			expectErr: fosite.ErrTokenExpired,
+		},
+		{
+			d:         "should fail because exp is missing",
+			claims:    jwt.MapClaims{"exp": nil},
+			expectErr: fosite.ErrTokenExpired,
+		},
+		{
+			d:         "should fail because not valid yet",
+			claims:    jwt.MapClaims{"nbf": time.Now().Add(time.Hour).Unix()},
+			expectErr: fosite.ErrTokenClaim,
+		},
+		{
+			d:         "should fail because of the issuer",
+			claims:    jwt.MapClaims{"iss": "https://evil.example.com/"},
+			expectErr: fosite.ErrTokenClaim,
+		},
+		{
+			d:         "should fail because of the audience",
+			claims:    jwt.MapClaims{"aud": []string{"https://other.example.com/"}},
+			expectErr: fosite.ErrTokenClaim,
+		},
+		{
+			d:         "should fail because it is not an access token",
+			header:    map[string]interface{}{"typ": "JWT"},
+			expectErr: fosite.ErrInvalidTokenFormat,
+		},
+		{
+			d:         "should fail because the key is unknown",
+			header:    map[string]interface{}{"kid": "key-2"},
+			expectErr: fosite.ErrTokenSignatureMismatch,
+		},
+		{
+			d:         "should fail because the scope was not granted",
+			scopes:    []string{"photos.delete"},
+			expectErr: fosite.ErrInvalidScope,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			requester, err := v.ValidateAccessToken(context.Background(), issue(t, c.claims, c.header), c.scopes...)
+			if c.expectErr != nil {
+				require.Error(t, err)
+				assert.True(t, errors.Is(err, c.expectErr), "%+v", err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "peter", requester.GetSession().GetSubject())
+			assert.Equal(t, fosite.Arguments{"photos.read", "photos.write"}, requester.GetGrantedScopes())
+			assert.Equal(t, fosite.Arguments{"https://api.example.com/"}, requester.GetGrantedAudience())
+		})
+	}
+
+	t.Run("case=should reject tokens signed with a shared secret", func(t *testing.T) {
+		token := jwt.NewWithClaims(jose.HS256, jwt.MapClaims{"iss": v.Issuer, "aud": v.Audience, "exp": time.Now().Add(time.Hour).Unix()})
+		token.Header["typ"] = "at+jwt"
+		raw, err := token.SignedString([]byte("some-secret-thats-random-some-secret-thats-random-"))
+		require.NoError(t, err)
+
+		_, err = v.ValidateAccessToken(context.Background(), raw)
+		assert.True(t, errors.Is(err, fosite.ErrTokenSignatureMismatch), "%+v", err)
+	})
+
+	t.Run("case=should refresh the key set of the JWKS URI for unknown keys", func(t *testing.T) {
+		var requests int
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			set := &jose.JSONWebKeySet{}
+			if requests > 1 {
+				// The key was rotated after the first request.
+				set = keys
+			}
+			require.NoError(t, json.NewEncoder(w).Encode(set))
+		}))
+		defer ts.Close()
+
+		v := &ResourceServerValidator{Issuer: v.Issuer, Audience: v.Audience, JWKSURI: ts.URL}
+		_, err := v.ValidateAccessToken(context.Background(), issue(t, nil, nil))
+		require.NoError(t, err)
+		assert.Equal(t, 2, requests)
+	})
+}
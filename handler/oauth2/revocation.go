@@ -35,6 +35,10 @@ type TokenRevocationHandler struct {
 	TokenRevocationStorage TokenRevocationStorage
 	RefreshTokenStrategy   RefreshTokenStrategy
 	AccessTokenStrategy    AccessTokenStrategy
+
+	// RevocationPolicy decides whether the other tokens of the grant are revoked together with the revoked token.
+	// Defaults to fosite.CascadingRevocationPolicy.
+	RevocationPolicy fosite.RevocationPolicy
 }
 
 // RevokeToken implements https://tools.ietf.org/html/rfc7009#section-2.1
@@ -43,25 +47,32 @@ func (r *TokenRevocationHandler) RevokeToken(ctx context.Context, token string,
 	discoveryFuncs := []func() (request fosite.Requester, err error){
 		func() (request fosite.Requester, err error) {
 			// Refresh token
-			signature := r.RefreshTokenStrategy.RefreshTokenSignature(token)
-			return r.TokenRevocationStorage.GetRefreshTokenSession(ctx, signature, nil)
+			return GetRefreshTokenSession(ctx, r.RefreshTokenStrategy, r.TokenRevocationStorage, token, nil)
 		},
 		func() (request fosite.Requester, err error) {
 			// Access token
 			signature := r.AccessTokenStrategy.AccessTokenSignature(token)
-			return r.TokenRevocationStorage.GetAccessTokenSession(ctx, signature, nil)
+			err = fosite.StorageOperation(ctx, "GetAccessTokenSession", func(ctx context.Context) (err error) {
+				request, err = r.TokenRevocationStorage.GetAccessTokenSession(ctx, signature, nil)
+				return err
+			})
+			return request, err
 		},
 	}
+	discoveredTypes := []fosite.TokenType{fosite.RefreshToken, fosite.AccessToken}
 
 	// Token type hinting
 	if tokenType == fosite.AccessToken {
 		discoveryFuncs[0], discoveryFuncs[1] = discoveryFuncs[1], discoveryFuncs[0]
+		discoveredTypes[0], discoveredTypes[1] = discoveredTypes[1], discoveredTypes[0]
 	}
 
 	var ar fosite.Requester
 	var err1, err2 error
+	revoked := discoveredTypes[0]
 	if ar, err1 = discoveryFuncs[0](); err1 != nil {
 		ar, err2 = discoveryFuncs[1]()
+		revoked = discoveredTypes[1]
 	}
 	// err2 can only be not nil if first err1 was not nil
 	if err2 != nil {
@@ -73,12 +84,35 @@ func (r *TokenRevocationHandler) RevokeToken(ctx context.Context, token string,
 	}
 
 	requestID := ar.GetID()
-	err1 = r.TokenRevocationStorage.RevokeRefreshToken(ctx, requestID)
-	err2 = r.TokenRevocationStorage.RevokeAccessToken(ctx, requestID)
+	cascade := r.revocationPolicy().RevokeGrantTokens(ctx, revoked, ar)
+	err1, err2 = nil, nil
+	if revoked == fosite.RefreshToken || cascade {
+		err1 = fosite.StorageOperation(ctx, "RevokeRefreshToken", func(ctx context.Context) error {
+			return r.TokenRevocationStorage.RevokeRefreshToken(ctx, requestID)
+		})
+	}
+	if cascade {
+		err2 = fosite.StorageOperation(ctx, "RevokeAccessToken", func(ctx context.Context) error {
+			return r.TokenRevocationStorage.RevokeAccessToken(ctx, requestID)
+		})
+	} else if revoked == fosite.AccessToken {
+		// Only the presented access token is revoked, not the access token which was last issued for the grant.
+		signature := r.AccessTokenStrategy.AccessTokenSignature(token)
+		err2 = fosite.StorageOperation(ctx, "DeleteAccessTokenSession", func(ctx context.Context) error {
+			return r.TokenRevocationStorage.DeleteAccessTokenSession(ctx, signature)
+		})
+	}
 
 	return storeErrorsToRevocationError(err1, err2)
 }
 
+func (r *TokenRevocationHandler) revocationPolicy() fosite.RevocationPolicy {
+	if r.RevocationPolicy == nil {
+		return fosite.CascadingRevocationPolicy
+	}
+	return r.RevocationPolicy
+}
+
 func storeErrorsToRevocationError(err1, err2 error) error {
 	// both errors are 404 or nil <=> the token is revoked
 	if (errors.Is(err1, fosite.ErrNotFound) || err1 == nil) && (errors.Is(err2, fosite.ErrNotFound) || err2 == nil) {
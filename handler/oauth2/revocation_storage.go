@@ -23,6 +23,7 @@ package oauth2
 
 import (
 	"context"
+	"time"
 )
 
 // TokenRevocationStorage provides the storage implementation
@@ -47,3 +48,33 @@ type TokenRevocationStorage interface {
 	// token as well.
 	RevokeAccessToken(ctx context.Context, requestID string) error
 }
+
+// RefreshTokenRotationStorage is an optional extension of TokenRevocationStorage which allows refresh tokens to be used
+// again during a grace period after they have been exchanged for a new refresh token.
+type RefreshTokenRotationStorage interface {
+	// RotateRefreshToken records the time the refresh token has been exchanged for a new refresh token. The refresh
+	// token has already been revoked with RevokeRefreshToken at that point.
+	RotateRefreshToken(ctx context.Context, signature string, rotatedAt time.Time) error
+
+	// GetRefreshTokenRotatedAt returns the time the refresh token has been exchanged for a new refresh token, or
+	// fosite.ErrNotFound if it has not been rotated.
+	GetRefreshTokenRotatedAt(ctx context.Context, signature string) (time.Time, error)
+}
+
+// AuthorizeCodeRevocationStorage is an optional extension of TokenRevocationStorage which revokes the tokens of an
+// authorization code which is used more than once, see https://tools.ietf.org/html/rfc6749#section-4.1.2. Without
+// it, the tokens are revoked using RevokeAccessToken and RevokeRefreshToken.
+type AuthorizeCodeRevocationStorage interface {
+	// RevokeTokensByAuthorizeCode revokes all access and refresh tokens which were issued from the authorization
+	// code with the given signature, including those issued when refreshing them.
+	RevokeTokensByAuthorizeCode(ctx context.Context, signature string) error
+}
+
+// StatelessRefreshTokenStorage is an extension of TokenRevocationStorage which is required by
+// StatelessRefreshTokenStrategy. As stateless refresh tokens are not stored, RevokeRefreshToken must record when
+// it was called for a request ID, which revokes all stateless refresh tokens of the grant issued until then.
+type StatelessRefreshTokenStorage interface {
+	// GetRefreshTokenRevokedAt returns the time RevokeRefreshToken was last called for the request ID, or
+	// fosite.ErrNotFound if it never was.
+	GetRefreshTokenRevokedAt(ctx context.Context, requestID string) (time.Time, error)
+}
@@ -22,14 +22,17 @@
 package oauth2
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
 	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/ory/fosite"
 	"github.com/ory/fosite/internal"
+	"github.com/ory/fosite/storage"
 )
 
 func TestRevokeToken(t *testing.T) {
@@ -234,3 +237,50 @@ func TestRevokeToken(t *testing.T) {
 		})
 	}
 }
+
+func TestRevokeTokenWithRevocationPolicy(t *testing.T) {
+	client := &fosite.DefaultClient{ID: "foo"}
+	for k, c := range []struct {
+		policy              fosite.RevocationPolicy
+		revoke              fosite.TokenType
+		expectAccessActive  bool
+		expectRefreshActive bool
+	}{
+		{policy: nil, revoke: fosite.AccessToken},
+		{policy: nil, revoke: fosite.RefreshToken},
+		{policy: fosite.RefreshTokenCascadingRevocationPolicy, revoke: fosite.RefreshToken},
+		{policy: fosite.RefreshTokenCascadingRevocationPolicy, revoke: fosite.AccessToken, expectRefreshActive: true},
+		{policy: fosite.NonCascadingRevocationPolicy, revoke: fosite.AccessToken, expectRefreshActive: true},
+		{policy: fosite.NonCascadingRevocationPolicy, revoke: fosite.RefreshToken, expectAccessActive: true},
+	} {
+		t.Run(fmt.Sprintf("case=%d/revoke=%s", k, c.revoke), func(t *testing.T) {
+			ctx := context.Background()
+			store := storage.NewMemoryStore()
+			h := TokenRevocationHandler{
+				TokenRevocationStorage: store,
+				AccessTokenStrategy:    &hmacshaStrategy,
+				RefreshTokenStrategy:   &hmacshaStrategy,
+				RevocationPolicy:       c.policy,
+			}
+
+			req := &fosite.Request{ID: "request-id", Client: client, Session: new(fosite.DefaultSession)}
+			at, atSig, err := hmacshaStrategy.GenerateAccessToken(ctx, req)
+			require.NoError(t, err)
+			require.NoError(t, store.CreateAccessTokenSession(ctx, atSig, req))
+			rt, rtSig, err := hmacshaStrategy.GenerateRefreshToken(ctx, req)
+			require.NoError(t, err)
+			require.NoError(t, store.CreateRefreshTokenSession(ctx, rtSig, req))
+
+			token := at
+			if c.revoke == fosite.RefreshToken {
+				token = rt
+			}
+			require.NoError(t, h.RevokeToken(ctx, token, c.revoke, client))
+
+			_, err = store.GetAccessTokenSession(ctx, atSig, nil)
+			assert.Equal(t, c.expectAccessActive, err == nil, "%+v", err)
+			_, err = store.GetRefreshTokenSession(ctx, rtSig, nil)
+			assert.Equal(t, c.expectRefreshActive, err == nil, "%+v", err)
+		})
+	}
+}
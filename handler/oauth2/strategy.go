@@ -45,6 +45,12 @@ type RefreshTokenStrategy interface {
 	ValidateRefreshToken(ctx context.Context, requester fosite.Requester, token string) (err error)
 }
 
+// TokenTypeStrategy is implemented by strategies which can identify the type of the tokens they issue, for example
+// by the token prefix.
+type TokenTypeStrategy interface {
+	TokenType(token string) (fosite.TokenType, bool)
+}
+
 type AuthorizeCodeStrategy interface {
 	AuthorizeCodeSignature(token string) string
 	GenerateAuthorizeCode(ctx context.Context, requester fosite.Requester) (token string, signature string, err error)
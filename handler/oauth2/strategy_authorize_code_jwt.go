@@ -0,0 +1,199 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package oauth2
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"time"
+
+	"github.com/pborman/uuid"
+
+	"github.com/ory/x/errorsx"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/token/jwt"
+)
+
+// AuthorizeCodeJWTType is the "typ" header of self-encoded authorization codes.
+const AuthorizeCodeJWTType = "ac+jwt"
+
+// AuthorizeCodeClaims is the data a self-encoded authorization code is bound to.
+type AuthorizeCodeClaims struct {
+	ID                  string
+	ClientID            string
+	RedirectURI         string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	ExpiresAt           time.Time
+}
+
+// StatelessAuthorizeCodeStrategy is implemented by authorize code strategies which encode the data the code is bound
+// to into the code itself. The authorize code grant handler uses it to reject codes which are invalid, expired or
+// used by another client before the authorize code session is loaded from the storage.
+type StatelessAuthorizeCodeStrategy interface {
+	AuthorizeCodeStrategy
+
+	// DecodeAuthorizeCode verifies the integrity and the expiry of the code and returns the data it is bound to.
+	DecodeAuthorizeCode(ctx context.Context, code string) (*AuthorizeCodeClaims, error)
+}
+
+// SelfEncodedAuthorizeCodeStrategy issues authorization codes as signed JWTs carrying the client_id, the
+// redirect_uri and the PKCE code challenge of the authorize request. Access and refresh tokens are delegated to
+// the embedded CoreStrategy.
+type SelfEncodedAuthorizeCodeStrategy struct {
+	CoreStrategy
+
+	// JWTStrategy signs and verifies the authorization codes.
+	JWTStrategy jwt.JWTStrategy
+
+	// Issuer is the "iss" claim of the authorization codes. Codes of other issuers are rejected if set.
+	Issuer string
+
+	// IssuerProvider, if set, takes precedence over Issuer.
+	IssuerProvider fosite.IssuerProvider
+
+	AuthorizeCodeLifespan time.Duration
+}
+
+func (h *SelfEncodedAuthorizeCodeStrategy) AuthorizeCodeSignature(token string) string {
+	split := strings.Split(token, ".")
+	if len(split) != 3 {
+		return ""
+	}
+
+	return split[2]
+}
+
+func (h *SelfEncodedAuthorizeCodeStrategy) GenerateAuthorizeCode(ctx context.Context, requester fosite.Requester) (token string, signature string, err error) {
+	issuer, err := fosite.GetIssuer(ctx, h.IssuerProvider, h.Issuer)
+	if err != nil {
+		return "", "", err
+	}
+
+	now := time.Now().UTC()
+	form := requester.GetRequestForm()
+
+	claims := jwt.MapClaims{
+		"jti":       uuid.New(),
+		"client_id": requester.GetClient().GetID(),
+		"iat":       now.Unix(),
+		"exp":       now.Add(h.AuthorizeCodeLifespan).Unix(),
+	}
+	if issuer != "" {
+		claims["iss"] = issuer
+	}
+	if redirectURI := form.Get("redirect_uri"); redirectURI != "" {
+		claims["redirect_uri"] = redirectURI
+	}
+	if challenge := form.Get("code_challenge"); challenge != "" {
+		method := form.Get("code_challenge_method")
+		if method == "" {
+			method = "plain"
+		}
+		claims["code_challenge"] = challenge
+		claims["code_challenge_method"] = method
+	}
+
+	return h.JWTStrategy.Generate(ctx, claims, &jwt.Headers{Extra: map[string]interface{}{"typ": AuthorizeCodeJWTType}})
+}
+
+func (h *SelfEncodedAuthorizeCodeStrategy) ValidateAuthorizeCode(ctx context.Context, r fosite.Requester, token string) error {
+	if _, err := h.DecodeAuthorizeCode(ctx, token); err != nil {
+		return err
+	}
+
+	if exp := r.GetSession().GetExpiresAt(fosite.AuthorizeCode); !exp.IsZero() && exp.Before(time.Now().UTC()) {
+		return errorsx.WithStack(fosite.ErrTokenExpired.WithHintf("Authorize code expired at '%s'.", exp))
+	}
+
+	return nil
+}
+
+func (h *SelfEncodedAuthorizeCodeStrategy) DecodeAuthorizeCode(ctx context.Context, code string) (*AuthorizeCodeClaims, error) {
+	t, err := validate(ctx, h.JWTStrategy, code)
+	if err != nil {
+		return nil, err
+	}
+
+	if typ, _ := t.Header["typ"].(string); typ != AuthorizeCodeJWTType {
+		return nil, errorsx.WithStack(fosite.ErrInvalidTokenFormat.WithHintf("The authorization code must be of type '%s'.", AuthorizeCodeJWTType))
+	}
+
+	issuer, err := fosite.GetIssuer(ctx, h.IssuerProvider, h.Issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	exp, ok := claimToInt64(t.Claims["exp"])
+	if !ok {
+		return nil, errorsx.WithStack(fosite.ErrTokenClaim.WithHint("The authorization code does not expire."))
+	} else if issuer != "" && !t.Claims.VerifyIssuer(issuer, true) {
+		return nil, errorsx.WithStack(fosite.ErrTokenClaim.WithHint("The authorization code was issued by another issuer."))
+	}
+
+	claims := &AuthorizeCodeClaims{
+		ExpiresAt: time.Unix(exp, 0).UTC(),
+	}
+	claims.ID, _ = t.Claims["jti"].(string)
+	claims.ClientID, _ = t.Claims["client_id"].(string)
+	claims.RedirectURI, _ = t.Claims["redirect_uri"].(string)
+	claims.CodeChallenge, _ = t.Claims["code_challenge"].(string)
+	claims.CodeChallengeMethod, _ = t.Claims["code_challenge_method"].(string)
+	return claims, nil
+}
+
+// validateAuthorizeCodeClaims verifies that the token request matches the data the authorization code is bound to.
+func validateAuthorizeCodeClaims(request fosite.AccessRequester, claims *AuthorizeCodeClaims) error {
+	if claims.ClientID != request.GetClient().GetID() {
+		return errorsx.WithStack(fosite.ErrInvalidGrant.WithHint("The OAuth 2.0 Client ID from this request does not match the one from the authorize request."))
+	}
+
+	if claims.RedirectURI != "" && claims.RedirectURI != request.GetRequestForm().Get("redirect_uri") {
+		return errorsx.WithStack(fosite.ErrInvalidGrant.WithHint("The \"redirect_uri\" from this request does not match the one from the authorize request."))
+	}
+
+	if claims.CodeChallenge != "" {
+		verifier := request.GetRequestForm().Get("code_verifier")
+		if claims.CodeChallengeMethod == "S256" {
+			hash := sha256.Sum256([]byte(verifier))
+			verifier = base64.RawURLEncoding.EncodeToString(hash[:])
+		}
+		if verifier != claims.CodeChallenge {
+			return errorsx.WithStack(fosite.ErrInvalidGrant.WithHint("The PKCE code challenge did not match the code verifier."))
+		}
+	}
+
+	return nil
+}
+
+func claimToInt64(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return int64(n), true
+	case int64:
+		return n, true
+	}
+	return 0, false
+}
@@ -0,0 +1,146 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package oauth2
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/internal"
+	"github.com/ory/fosite/storage"
+	"github.com/ory/fosite/token/jwt"
+)
+
+var selfEncodedStrategy = &SelfEncodedAuthorizeCodeStrategy{
+	CoreStrategy: &hmacshaStrategy,
+	JWTStrategy: &jwt.RS256JWTStrategy{
+		PrivateKey: internal.MustRSAKey(),
+	},
+	Issuer:                "https://fosite.ory.sh",
+	AuthorizeCodeLifespan: time.Minute,
+}
+
+func TestSelfEncodedAuthorizeCode(t *testing.T) {
+	verifier := "cKe9sBuBW4wXaEVgcR3AdgVUo4NQ9IQOLuj5dTHmnj0nRxsTq4ctnwqD2utHretZ"
+	hash := sha256.Sum256([]byte(verifier))
+	challenge := base64.RawURLEncoding.EncodeToString(hash[:])
+
+	ar := fosite.NewAuthorizeRequest()
+	ar.Client = &fosite.DefaultClient{ID: "foo", GrantTypes: fosite.Arguments{"authorization_code"}}
+	ar.Session = new(fosite.DefaultSession)
+	ar.Form = url.Values{
+		"redirect_uri":          {"https://foo.bar/cb"},
+		"code_challenge":        {challenge},
+		"code_challenge_method": {"S256"},
+	}
+
+	code, signature, err := selfEncodedStrategy.GenerateAuthorizeCode(context.Background(), ar)
+	require.NoError(t, err)
+	assert.Equal(t, signature, selfEncodedStrategy.AuthorizeCodeSignature(code))
+
+	claims, err := selfEncodedStrategy.DecodeAuthorizeCode(context.Background(), code)
+	require.NoError(t, err)
+	assert.NotEmpty(t, claims.ID)
+	assert.Equal(t, "foo", claims.ClientID)
+	assert.Equal(t, "https://foo.bar/cb", claims.RedirectURI)
+	assert.Equal(t, challenge, claims.CodeChallenge)
+	assert.Equal(t, "S256", claims.CodeChallengeMethod)
+	assert.WithinDuration(t, time.Now().UTC().Add(time.Minute), claims.ExpiresAt, time.Second*5)
+
+	require.NoError(t, selfEncodedStrategy.ValidateAuthorizeCode(context.Background(), ar, code))
+
+	t.Run("case=rejects codes of other strategies", func(t *testing.T) {
+		accessToken, _, err := (&DefaultJWTStrategy{JWTStrategy: selfEncodedStrategy.JWTStrategy}).GenerateAccessToken(context.Background(), &fosite.Request{
+			Client:  &fosite.DefaultClient{ID: "foo"},
+			Session: &JWTSession{JWTClaims: &jwt.JWTClaims{ExpiresAt: time.Now().UTC().Add(time.Hour)}},
+		})
+		require.NoError(t, err)
+		_, err = selfEncodedStrategy.DecodeAuthorizeCode(context.Background(), accessToken)
+		require.ErrorIs(t, err, fosite.ErrInvalidTokenFormat)
+
+		_, err = selfEncodedStrategy.DecodeAuthorizeCode(context.Background(), code+"x")
+		require.Error(t, err)
+	})
+
+	t.Run("case=validates the token request before hitting the storage", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		h := AuthorizeExplicitGrantHandler{
+			CoreStorage:           internal.NewMockCoreStorage(ctrl),
+			AuthorizeCodeStrategy: selfEncodedStrategy,
+		}
+
+		for _, c := range []struct {
+			d    string
+			id   string
+			form url.Values
+		}{
+			{d: "invalid code", id: "foo", form: url.Values{"code": {"foo.bar.baz"}, "redirect_uri": {"https://foo.bar/cb"}, "code_verifier": {verifier}}},
+			{d: "other client", id: "bar", form: url.Values{"code": {code}, "redirect_uri": {"https://foo.bar/cb"}, "code_verifier": {verifier}}},
+			{d: "other redirect_uri", id: "foo", form: url.Values{"code": {code}, "redirect_uri": {"https://bar.foo/cb"}, "code_verifier": {verifier}}},
+			{d: "invalid code_verifier", id: "foo", form: url.Values{"code": {code}, "redirect_uri": {"https://foo.bar/cb"}, "code_verifier": {challenge}}},
+		} {
+			t.Run("case="+c.d, func(t *testing.T) {
+				areq := fosite.NewAccessRequest(new(fosite.DefaultSession))
+				areq.GrantTypes = fosite.Arguments{"authorization_code"}
+				areq.Client = &fosite.DefaultClient{ID: c.id, GrantTypes: fosite.Arguments{"authorization_code"}}
+				areq.Form = c.form
+
+				require.ErrorIs(t, h.HandleTokenEndpointRequest(context.Background(), areq), fosite.ErrInvalidGrant)
+			})
+		}
+	})
+
+	t.Run("case=exchanges the code", func(t *testing.T) {
+		store := storage.NewMemoryStore()
+		h := AuthorizeExplicitGrantHandler{
+			CoreStorage:              store,
+			AuthorizeCodeStrategy:    selfEncodedStrategy,
+			AccessTokenStrategy:      selfEncodedStrategy,
+			RefreshTokenStrategy:     selfEncodedStrategy,
+			ScopeStrategy:            fosite.HierarchicScopeStrategy,
+			AudienceMatchingStrategy: fosite.DefaultAudienceMatchingStrategy,
+			TokenRevocationStorage:   store,
+			AuthCodeLifespan:         time.Minute,
+		}
+
+		require.NoError(t, store.CreateAuthorizeCodeSession(context.Background(), signature, ar))
+
+		areq := fosite.NewAccessRequest(new(fosite.DefaultSession))
+		areq.GrantTypes = fosite.Arguments{"authorization_code"}
+		areq.Client = ar.Client
+		areq.Form = url.Values{"code": {code}, "redirect_uri": {"https://foo.bar/cb"}, "code_verifier": {verifier}}
+
+		require.NoError(t, h.HandleTokenEndpointRequest(context.Background(), areq))
+		require.NoError(t, h.PopulateTokenEndpointResponse(context.Background(), areq, fosite.NewAccessResponse()))
+	})
+}
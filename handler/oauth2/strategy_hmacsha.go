@@ -36,6 +36,31 @@ type HMACSHAStrategy struct {
 	AccessTokenLifespan   time.Duration
 	RefreshTokenLifespan  time.Duration
 	AuthorizeCodeLifespan time.Duration
+
+	// TokenPrefixes, if set, prefixes generated tokens with the prefix of their token type, for example
+	// fosite.DefaultTokenPrefixes. Tokens without a prefix are still accepted.
+	TokenPrefixes fosite.TokenPrefixes
+}
+
+// TokenType returns the type of the token identified by its prefix.
+func (h HMACSHAStrategy) TokenType(token string) (fosite.TokenType, bool) {
+	return h.TokenPrefixes.TokenType(token)
+}
+
+func (h HMACSHAStrategy) generate(tokenType fosite.TokenType) (string, string, error) {
+	token, signature, err := h.Enigma.Generate()
+	if err != nil {
+		return "", "", err
+	}
+	return h.TokenPrefixes[tokenType] + token, signature, nil
+}
+
+func (h HMACSHAStrategy) validate(tokenType fosite.TokenType, token string) error {
+	token, err := h.TokenPrefixes.Trim(tokenType, token)
+	if err != nil {
+		return err
+	}
+	return h.Enigma.Validate(token)
 }
 
 func (h HMACSHAStrategy) AccessTokenSignature(token string) string {
@@ -49,7 +74,7 @@ func (h HMACSHAStrategy) AuthorizeCodeSignature(token string) string {
 }
 
 func (h HMACSHAStrategy) GenerateAccessToken(_ context.Context, _ fosite.Requester) (token string, signature string, err error) {
-	return h.Enigma.Generate()
+	return h.generate(fosite.AccessToken)
 }
 
 func (h HMACSHAStrategy) ValidateAccessToken(_ context.Context, r fosite.Requester, token string) (err error) {
@@ -60,27 +85,34 @@ func (h HMACSHAStrategy) ValidateAccessToken(_ context.Context, r fosite.Request
 	if !exp.IsZero() && exp.Before(time.Now().UTC()) {
 		return errorsx.WithStack(fosite.ErrTokenExpired.WithHintf("Access token expired at '%s'.", exp))
 	}
-	return h.Enigma.Validate(token)
+	return h.validate(fosite.AccessToken, token)
 }
 
 func (h HMACSHAStrategy) GenerateRefreshToken(_ context.Context, _ fosite.Requester) (token string, signature string, err error) {
-	return h.Enigma.Generate()
+	return h.generate(fosite.RefreshToken)
 }
 
 func (h HMACSHAStrategy) ValidateRefreshToken(_ context.Context, r fosite.Requester, token string) (err error) {
+	if session := r.GetSession().GetExpiresAt(fosite.RefreshTokenSession); !session.IsZero() && session.Before(time.Now().UTC()) {
+		return errorsx.WithStack(fosite.ErrTokenExpired.WithHintf("Refresh token expired at '%s' because the maximum lifetime of the grant has been reached.", session))
+	}
+
 	var exp = r.GetSession().GetExpiresAt(fosite.RefreshToken)
 	if exp.IsZero() {
+		if h.RefreshTokenLifespan > 0 && r.GetRequestedAt().Add(h.RefreshTokenLifespan).Before(time.Now().UTC()) {
+			return errorsx.WithStack(fosite.ErrTokenExpired.WithHintf("Refresh token expired at '%s'.", r.GetRequestedAt().Add(h.RefreshTokenLifespan)))
+		}
 		// Unlimited lifetime
-		return h.Enigma.Validate(token)
+		return h.validate(fosite.RefreshToken, token)
 	}
 	if !exp.IsZero() && exp.Before(time.Now().UTC()) {
 		return errorsx.WithStack(fosite.ErrTokenExpired.WithHintf("Refresh token expired at '%s'.", exp))
 	}
-	return h.Enigma.Validate(token)
+	return h.validate(fosite.RefreshToken, token)
 }
 
 func (h HMACSHAStrategy) GenerateAuthorizeCode(_ context.Context, _ fosite.Requester) (token string, signature string, err error) {
-	return h.Enigma.Generate()
+	return h.generate(fosite.AuthorizeCode)
 }
 
 func (h HMACSHAStrategy) ValidateAuthorizeCode(_ context.Context, r fosite.Requester, token string) (err error) {
@@ -92,5 +124,5 @@ func (h HMACSHAStrategy) ValidateAuthorizeCode(_ context.Context, r fosite.Reque
 		return errorsx.WithStack(fosite.ErrTokenExpired.WithHintf("Authorize code expired at '%s'.", exp))
 	}
 
-	return h.Enigma.Validate(token)
+	return h.validate(fosite.AuthorizeCode, token)
 }
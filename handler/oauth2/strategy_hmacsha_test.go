@@ -27,7 +27,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/ory/fosite"
 	"github.com/ory/fosite/token/hmac"
@@ -122,6 +124,20 @@ func TestHMACRefreshToken(t *testing.T) {
 			r:    hmacExpiredCase,
 			pass: false,
 		},
+		{
+			r: fosite.Request{
+				Client: &fosite.DefaultClient{
+					Secret: []byte("foobarfoobarfoobarfoobar"),
+				},
+				Session: &fosite.DefaultSession{
+					ExpiresAt: map[fosite.TokenType]time.Time{
+						fosite.RefreshToken:        time.Now().UTC().Add(time.Hour),
+						fosite.RefreshTokenSession: time.Now().UTC().Add(-time.Hour),
+					},
+				},
+			},
+			pass: false,
+		},
 	} {
 		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
 			token, signature, err := hmacshaStrategy.GenerateRefreshToken(nil, &c.r)
@@ -140,6 +156,38 @@ func TestHMACRefreshToken(t *testing.T) {
 	}
 }
 
+func TestHMACRefreshTokenLifespan(t *testing.T) {
+	strategy := hmacshaStrategy
+	strategy.RefreshTokenLifespan = time.Hour
+
+	for k, c := range []struct {
+		requestedAt time.Time
+		pass        bool
+	}{
+		{
+			requestedAt: time.Now().UTC().Add(-time.Minute),
+			pass:        true,
+		},
+		{
+			requestedAt: time.Now().UTC().Add(-time.Hour * 2),
+			pass:        false,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			r := &fosite.Request{RequestedAt: c.requestedAt, Session: &fosite.DefaultSession{}}
+			token, _, err := strategy.GenerateRefreshToken(nil, r)
+			assert.NoError(t, err)
+
+			err = strategy.ValidateRefreshToken(nil, r, token)
+			if c.pass {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, fosite.ErrTokenExpired.Error())
+			}
+		})
+	}
+}
+
 func TestHMACAuthorizeCode(t *testing.T) {
 	for k, c := range []struct {
 		r    fosite.Request
@@ -170,3 +218,41 @@ func TestHMACAuthorizeCode(t *testing.T) {
 		})
 	}
 }
+
+func TestHMACTokenPrefixes(t *testing.T) {
+	strategy := hmacshaStrategy
+	strategy.Enigma = &hmac.HMACStrategy{GlobalSecret: []byte("foobarfoobarfoobarfoobarfoobarfoobarfoobarfoobar"), VersionedTokens: true}
+	strategy.TokenPrefixes = fosite.DefaultTokenPrefixes
+
+	accessToken, signature, err := strategy.GenerateAccessToken(nil, &hmacValidCase)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(accessToken, "ory_at_"), accessToken)
+	assert.Equal(t, signature, strategy.AccessTokenSignature(accessToken))
+	assert.NoError(t, strategy.ValidateAccessToken(nil, &hmacValidCase, accessToken))
+
+	refreshToken, _, err := strategy.GenerateRefreshToken(nil, &hmacValidCase)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(refreshToken, "ory_rt_"), refreshToken)
+	assert.NoError(t, strategy.ValidateRefreshToken(nil, &hmacValidCase, refreshToken))
+
+	code, _, err := strategy.GenerateAuthorizeCode(nil, &hmacValidCase)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(code, "ory_ac_"), code)
+	assert.NoError(t, strategy.ValidateAuthorizeCode(nil, &hmacValidCase, code))
+
+	tokenType, ok := strategy.TokenType(refreshToken)
+	assert.True(t, ok)
+	assert.Equal(t, fosite.RefreshToken, tokenType)
+
+	t.Run("case=rejects tokens of other types", func(t *testing.T) {
+		assert.True(t, errors.Is(strategy.ValidateAccessToken(nil, &hmacValidCase, refreshToken), fosite.ErrInvalidTokenFormat))
+		assert.True(t, errors.Is(strategy.ValidateRefreshToken(nil, &hmacValidCase, code), fosite.ErrInvalidTokenFormat))
+		assert.True(t, errors.Is(strategy.ValidateAuthorizeCode(nil, &hmacValidCase, accessToken), fosite.ErrInvalidTokenFormat))
+	})
+
+	t.Run("case=accepts tokens issued without prefix", func(t *testing.T) {
+		token, _, err := hmacshaStrategy.GenerateAccessToken(nil, &hmacValidCase)
+		require.NoError(t, err)
+		assert.NoError(t, strategy.ValidateAccessToken(nil, &hmacValidCase, token))
+	})
+}
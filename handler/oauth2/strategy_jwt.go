@@ -33,12 +33,34 @@ import (
 	"github.com/ory/x/errorsx"
 )
 
+// AccessTokenJWTType is the "typ" header of access tokens issued using the JWT profile for OAuth 2.0 access
+// tokens, see https://tools.ietf.org/html/rfc9068#section-2.1
+const AccessTokenJWTType = "at+jwt"
+
 // DefaultJWTStrategy is a JWT RS256 strategy.
 type DefaultJWTStrategy struct {
 	jwt.JWTStrategy
 	HMACSHAStrategy *HMACSHAStrategy
 	Issuer          string
 	ScopeField      jwt.JWTScopeFieldEnum
+
+	// ClaimsMapper, if set, modifies the access token claims before they are signed.
+	ClaimsMapper fosite.ClaimsMapper
+
+	// HeaderType, if set, is the "typ" header of access tokens. It defaults to AccessTokenJWTType if RFC9068 is
+	// enabled.
+	HeaderType string
+
+	// RFC9068, if enabled, issues access tokens conforming to https://tools.ietf.org/html/rfc9068: the "scope"
+	// claim is a string, the "client_id" claim is set, "sub" defaults to the client ID, and issuing tokens without
+	// "iss", "exp" or "aud" fails. Access tokens are only valid if they carry the AccessTokenJWTType header.
+	RFC9068 bool
+
+	// DefaultAudience is the audience of RFC 9068 access tokens for which no audience was granted.
+	DefaultAudience []string
+
+	// IssuerProvider, if set, takes precedence over Issuer.
+	IssuerProvider fosite.IssuerProvider
 }
 
 func (h *DefaultJWTStrategy) WithIssuer(issuer string) *DefaultJWTStrategy {
@@ -46,11 +68,41 @@ func (h *DefaultJWTStrategy) WithIssuer(issuer string) *DefaultJWTStrategy {
 	return h
 }
 
+func (h *DefaultJWTStrategy) WithClaimsMapper(mapper fosite.ClaimsMapper) *DefaultJWTStrategy {
+	h.ClaimsMapper = mapper
+	return h
+}
+
 func (h *DefaultJWTStrategy) WithScopeField(scopeField jwt.JWTScopeFieldEnum) *DefaultJWTStrategy {
 	h.ScopeField = scopeField
 	return h
 }
 
+func (h *DefaultJWTStrategy) WithIssuerProvider(provider fosite.IssuerProvider) *DefaultJWTStrategy {
+	h.IssuerProvider = provider
+	return h
+}
+
+func (h *DefaultJWTStrategy) WithHeaderType(typ string) *DefaultJWTStrategy {
+	h.HeaderType = typ
+	return h
+}
+
+// WithRFC9068 enables the JWT profile for OAuth 2.0 access tokens, using the default audience for tokens which were
+// granted none.
+func (h *DefaultJWTStrategy) WithRFC9068(defaultAudience ...string) *DefaultJWTStrategy {
+	h.RFC9068 = true
+	h.DefaultAudience = defaultAudience
+	return h
+}
+
+func (h *DefaultJWTStrategy) headerType() string {
+	if h.HeaderType == "" && h.RFC9068 {
+		return AccessTokenJWTType
+	}
+	return h.HeaderType
+}
+
 func (h DefaultJWTStrategy) signature(token string) string {
 	split := strings.Split(token, ".")
 	if len(split) != 3 {
@@ -69,8 +121,27 @@ func (h *DefaultJWTStrategy) GenerateAccessToken(ctx context.Context, requester
 }
 
 func (h *DefaultJWTStrategy) ValidateAccessToken(ctx context.Context, _ fosite.Requester, token string) error {
-	_, err := validate(ctx, h.JWTStrategy, token)
-	return err
+	t, err := validate(ctx, h.JWTStrategy, token)
+	if err != nil {
+		return err
+	}
+
+	if h.RFC9068 && !isAccessTokenJWT(t) {
+		return errorsx.WithStack(fosite.ErrInvalidTokenFormat.WithHintf("The access token must be of type '%s'.", AccessTokenJWTType))
+	}
+	return nil
+}
+
+// isAccessTokenJWT returns true if the token carries the AccessTokenJWTType header. The media type may also be
+// given with its "application/" prefix, see https://tools.ietf.org/html/rfc9068#section-4
+func isAccessTokenJWT(t *jwt.Token) bool {
+	typ, _ := t.Header["typ"].(string)
+	return strings.EqualFold(strings.TrimPrefix(typ, "application/"), AccessTokenJWTType)
+}
+
+// TokenType returns the type of HMAC tokens identified by their prefix. JWT access tokens are not prefixed.
+func (h DefaultJWTStrategy) TokenType(token string) (fosite.TokenType, bool) {
+	return h.HMACSHAStrategy.TokenType(token)
 }
 
 func (h DefaultJWTStrategy) RefreshTokenSignature(token string) string {
@@ -140,6 +211,11 @@ func (h *DefaultJWTStrategy) generate(ctx context.Context, tokenType fosite.Toke
 	} else if jwtSession.GetJWTClaims() == nil {
 		return "", "", errors.New("GetTokenClaims() must not be nil")
 	} else {
+		issuer, err := fosite.GetIssuer(ctx, h.IssuerProvider, h.Issuer)
+		if err != nil {
+			return "", "", err
+		}
+
 		claims := jwtSession.GetJWTClaims().
 			With(
 				jwtSession.GetExpiresAt(tokenType),
@@ -148,12 +224,66 @@ func (h *DefaultJWTStrategy) generate(ctx context.Context, tokenType fosite.Toke
 			).
 			WithDefaults(
 				time.Now().UTC(),
-				h.Issuer,
+				issuer,
 			).
 			WithScopeField(
 				h.ScopeField,
 			)
 
-		return h.JWTStrategy.Generate(ctx, claims.ToMapClaims(), jwtSession.GetJWTHeader())
+		if h.RFC9068 {
+			claims = claims.WithScopeField(jwt.JWTScopeFieldString)
+			if h.ScopeField == jwt.JWTScopeFieldBoth {
+				claims = claims.WithScopeField(jwt.JWTScopeFieldBoth)
+			}
+		}
+
+		mapClaims := claims.ToMapClaims()
+		if h.RFC9068 {
+			if err := h.withRFC9068Claims(requester, mapClaims); err != nil {
+				return "", "", err
+			}
+		}
+
+		if h.ClaimsMapper != nil {
+			if err := h.ClaimsMapper.MapClaims(ctx, tokenType, requester, mapClaims); err != nil {
+				return "", "", err
+			}
+		}
+
+		header := jwtSession.GetJWTHeader()
+		if typ := h.headerType(); typ != "" {
+			// Copy the header so the session is not modified.
+			header = &jwt.Headers{Extra: jwt.Copy(header.Extra)}
+			header.Add("typ", typ)
+		}
+
+		return h.JWTStrategy.Generate(ctx, mapClaims, header)
+	}
+}
+
+// withRFC9068Claims sets and checks the claims required by https://tools.ietf.org/html/rfc9068#section-2.2
+func (h *DefaultJWTStrategy) withRFC9068Claims(requester fosite.Requester, claims jwt.MapClaims) error {
+	clientID := requester.GetClient().GetID()
+	claims["client_id"] = clientID
+
+	// If the token was issued to the client itself, "sub" is the client identifier.
+	if sub, _ := claims["sub"].(string); sub == "" {
+		claims["sub"] = clientID
+	}
+
+	if aud, ok := claims["aud"].([]string); !ok || len(aud) == 0 {
+		if len(h.DefaultAudience) == 0 {
+			return errorsx.WithStack(fosite.ErrServerError.WithHint("RFC 9068 access tokens require an audience but none was granted and no default audience is configured."))
+		}
+		claims["aud"] = h.DefaultAudience
+	}
+
+	if iss, _ := claims["iss"].(string); iss == "" {
+		return errorsx.WithStack(fosite.ErrServerError.WithHint("RFC 9068 access tokens require an issuer but none is configured."))
+	}
+
+	if _, ok := claims["exp"]; !ok {
+		return errorsx.WithStack(fosite.ErrServerError.WithHint("RFC 9068 access tokens require an expiry but the session has none."))
 	}
+	return nil
 }
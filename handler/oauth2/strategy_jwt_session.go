@@ -47,6 +47,9 @@ type JWTSession struct {
 	ExpiresAt map[fosite.TokenType]time.Time
 	Username  string
 	Subject   string
+
+	// PhantomToken is the JWT referenced by an opaque access token, see PhantomTokenStrategy.
+	PhantomToken string
 }
 
 func (j *JWTSession) GetJWTClaims() jwt.JWTClaimsContainer {
@@ -100,6 +103,19 @@ func (j *JWTSession) GetSubject() string {
 	return j.Subject
 }
 
+// GetPhantomToken implements fosite.PhantomTokenSession.
+func (j *JWTSession) GetPhantomToken() string {
+	if j == nil {
+		return ""
+	}
+	return j.PhantomToken
+}
+
+// SetPhantomToken implements fosite.PhantomTokenSession.
+func (j *JWTSession) SetPhantomToken(token string) {
+	j.PhantomToken = token
+}
+
 func (j *JWTSession) Clone() fosite.Session {
 	if j == nil {
 		return nil
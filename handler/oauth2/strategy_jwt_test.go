@@ -22,6 +22,7 @@
 package oauth2
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -242,3 +243,139 @@ func TestAccessToken(t *testing.T) {
 		}
 	}
 }
+
+func TestAccessTokenWithClaimsMapper(t *testing.T) {
+	strategy := &DefaultJWTStrategy{
+		JWTStrategy: j.JWTStrategy,
+	}
+	strategy.WithClaimsMapper(fosite.ClaimsMappers{
+		fosite.ClaimsMapperFunc(func(ctx context.Context, tokenType fosite.TokenType, requester fosite.Requester, claims jwt.MapClaims) error {
+			assert.Equal(t, fosite.AccessToken, tokenType)
+			if requester.GetGrantedScopes().Has("email") {
+				claims["email_verified"] = true
+			}
+			delete(claims, "foo")
+			return nil
+		}),
+		fosite.ClaimsMapperFunc(func(ctx context.Context, tokenType fosite.TokenType, requester fosite.Requester, claims jwt.MapClaims) error {
+			claims["client"] = requester.GetClient().GetID()
+			return nil
+		}),
+	})
+
+	r := jwtValidCase(fosite.AccessToken)
+	r.Client.(*fosite.DefaultClient).ID = "foo-client"
+	token, _, err := strategy.GenerateAccessToken(context.Background(), r)
+	require.NoError(t, err)
+	require.NoError(t, strategy.ValidateAccessToken(context.Background(), r, token))
+
+	decoded, err := strategy.Decode(context.Background(), token)
+	require.NoError(t, err)
+	assert.Equal(t, true, decoded.Claims["email_verified"])
+	assert.Equal(t, "foo-client", decoded.Claims["client"])
+	assert.NotContains(t, decoded.Claims, "foo")
+	assert.Equal(t, "peter", decoded.Claims["sub"])
+
+	strategy.WithClaimsMapper(fosite.ClaimsMapperFunc(func(ctx context.Context, tokenType fosite.TokenType, requester fosite.Requester, claims jwt.MapClaims) error {
+		return fosite.ErrAccessDenied
+	}))
+	_, _, err = strategy.GenerateAccessToken(context.Background(), jwtValidCase(fosite.AccessToken))
+	assert.ErrorIs(t, err, fosite.ErrAccessDenied)
+}
+
+func TestAccessTokenRFC9068(t *testing.T) {
+	strategy := (&DefaultJWTStrategy{JWTStrategy: j.JWTStrategy, Issuer: "https://auth.example.com"}).WithRFC9068("https://api.example.com")
+
+	decode := func(t *testing.T, token string) (header, payload map[string]interface{}) {
+		parts := strings.Split(token, ".")
+		require.Len(t, parts, 3)
+		for k, v := range []*map[string]interface{}{&header, &payload} {
+			raw, err := base64.RawURLEncoding.DecodeString(parts[k])
+			require.NoError(t, err)
+			require.NoError(t, json.Unmarshal(raw, v))
+		}
+		return
+	}
+
+	t.Run("case=issues tokens conforming to the profile", func(t *testing.T) {
+		r := jwtValidCase(fosite.AccessToken)
+		r.Client = &fosite.DefaultClient{ID: "foo"}
+		token, _, err := strategy.GenerateAccessToken(nil, r)
+		require.NoError(t, err)
+
+		header, payload := decode(t, token)
+		assert.Equal(t, AccessTokenJWTType, header["typ"])
+		assert.Equal(t, "foo", payload["client_id"])
+		assert.Equal(t, "peter", payload["sub"])
+		assert.Equal(t, "email offline", payload["scope"])
+		assert.NotContains(t, payload, "scp")
+		assert.Equal(t, []interface{}{"group0"}, payload["aud"])
+		for _, claim := range []string{"iss", "exp", "iat", "jti"} {
+			assert.Contains(t, payload, claim)
+		}
+		assert.Nil(t, r.GetSession().(*JWTSession).JWTHeader.Extra["typ"], "the session must not be modified")
+
+		require.NoError(t, strategy.ValidateAccessToken(nil, r, token))
+	})
+
+	t.Run("case=defaults the subject to the client and the audience to the default audience", func(t *testing.T) {
+		r := jwtValidCase(fosite.AccessToken)
+		r.Client = &fosite.DefaultClient{ID: "foo"}
+		r.GrantedAudience = nil
+		r.GetSession().(*JWTSession).JWTClaims.Subject = ""
+		token, _, err := strategy.GenerateAccessToken(nil, r)
+		require.NoError(t, err)
+
+		_, payload := decode(t, token)
+		assert.Equal(t, "foo", payload["sub"])
+		assert.Equal(t, []interface{}{"https://api.example.com"}, payload["aud"])
+	})
+
+	t.Run("case=fails without an audience", func(t *testing.T) {
+		r := jwtValidCase(fosite.AccessToken)
+		r.GrantedAudience = nil
+		_, _, err := (&DefaultJWTStrategy{JWTStrategy: j.JWTStrategy, Issuer: "https://auth.example.com"}).WithRFC9068().GenerateAccessToken(nil, r)
+		require.ErrorIs(t, err, fosite.ErrServerError)
+	})
+
+	t.Run("case=fails without an issuer", func(t *testing.T) {
+		r := jwtValidCase(fosite.AccessToken)
+		r.GetSession().(*JWTSession).JWTClaims.Issuer = ""
+		_, _, err := (&DefaultJWTStrategy{JWTStrategy: j.JWTStrategy}).WithRFC9068().GenerateAccessToken(nil, r)
+		require.ErrorIs(t, err, fosite.ErrServerError)
+	})
+
+	t.Run("case=rejects tokens of other types", func(t *testing.T) {
+		r := jwtValidCase(fosite.AccessToken)
+		token, _, err := (&DefaultJWTStrategy{JWTStrategy: j.JWTStrategy}).GenerateAccessToken(nil, r)
+		require.NoError(t, err)
+		require.ErrorIs(t, strategy.ValidateAccessToken(nil, r, token), fosite.ErrInvalidTokenFormat)
+	})
+
+	t.Run("case=sets a custom type", func(t *testing.T) {
+		r := jwtValidCase(fosite.AccessToken)
+		token, _, err := (&DefaultJWTStrategy{JWTStrategy: j.JWTStrategy}).WithHeaderType("application/at+jwt").GenerateAccessToken(nil, r)
+		require.NoError(t, err)
+
+		header, _ := decode(t, token)
+		assert.Equal(t, "application/at+jwt", header["typ"])
+		require.NoError(t, strategy.ValidateAccessToken(nil, r, token))
+	})
+}
+
+func TestJWTSession_Clone(t *testing.T) {
+	session := &JWTSession{
+		JWTClaims: &jwt.JWTClaims{
+			Subject:   "peter",
+			Audience:  []string{"group0"},
+			Scope:     []string{"email"},
+			IssuedAt:  time.Now().UTC().Round(time.Second),
+			Extra:     map[string]interface{}{"foo": "bar", "groups": []interface{}{"admin"}},
+			ExpiresAt: time.Now().UTC().Add(time.Hour).Round(time.Second),
+		},
+		JWTHeader: &jwt.Headers{Extra: map[string]interface{}{"kid": "key"}},
+		ExpiresAt: map[fosite.TokenType]time.Time{fosite.AccessToken: time.Now().UTC()},
+		Subject:   "peter",
+	}
+	require.NoError(t, fosite.CheckSessionClone(session))
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package oauth2
+
+import (
+	"context"
+
+	"github.com/ory/x/errorsx"
+
+	"github.com/ory/fosite"
+)
+
+// PhantomTokenStrategy implements the phantom token pattern: clients receive the opaque access tokens issued by the
+// embedded CoreStrategy, while the JWT issued by JWTStrategy is kept in the session and is only returned by the
+// introspection endpoint to callers which ask for "application/jwt", typically an API gateway.
+//
+// The session must implement fosite.PhantomTokenSession, for example JWTSession.
+type PhantomTokenStrategy struct {
+	CoreStrategy
+
+	// JWTStrategy issues the JWTs referenced by the opaque access tokens.
+	JWTStrategy AccessTokenStrategy
+}
+
+func (h *PhantomTokenStrategy) GenerateAccessToken(ctx context.Context, requester fosite.Requester) (token string, signature string, err error) {
+	session, ok := requester.GetSession().(fosite.PhantomTokenSession)
+	if !ok {
+		return "", "", errorsx.WithStack(fosite.ErrServerError.WithHint("Unable to issue a phantom token because the session does not implement fosite.PhantomTokenSession."))
+	}
+
+	jwt, _, err := h.JWTStrategy.GenerateAccessToken(ctx, requester)
+	if err != nil {
+		return "", "", err
+	}
+
+	token, signature, err = h.CoreStrategy.GenerateAccessToken(ctx, requester)
+	if err != nil {
+		return "", "", err
+	}
+
+	session.SetPhantomToken(jwt)
+	return token, signature, nil
+}
@@ -0,0 +1,59 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package oauth2
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
+)
+
+func TestPhantomTokenStrategy(t *testing.T) {
+	strategy := &PhantomTokenStrategy{
+		CoreStrategy: &hmacshaStrategy,
+		JWTStrategy:  j,
+	}
+
+	t.Run("case=issues an opaque token which references a JWT", func(t *testing.T) {
+		request := jwtValidCase(fosite.AccessToken)
+		token, signature, err := strategy.GenerateAccessToken(context.Background(), request)
+		require.NoError(t, err)
+		assert.Equal(t, signature, hmacshaStrategy.AccessTokenSignature(token))
+		require.NoError(t, strategy.ValidateAccessToken(context.Background(), request, token))
+
+		phantom := request.GetSession().(*JWTSession).GetPhantomToken()
+		require.NotEmpty(t, phantom)
+		assert.NotEqual(t, token, phantom)
+		require.NoError(t, j.ValidateAccessToken(context.Background(), request, phantom))
+	})
+
+	t.Run("case=requires a phantom token session", func(t *testing.T) {
+		request := jwtValidCase(fosite.AccessToken)
+		request.Session = new(fosite.DefaultSession)
+		_, _, err := strategy.GenerateAccessToken(context.Background(), request)
+		require.ErrorIs(t, err, fosite.ErrServerError)
+	})
+}
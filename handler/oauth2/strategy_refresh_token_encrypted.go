@@ -0,0 +1,216 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package oauth2
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/x/errorsx"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/token/aead"
+)
+
+// StatelessRefreshTokenStrategy is implemented by refresh token strategies which encode the grant into the token,
+// so that refresh tokens can be used without loading them from the storage. Stateless refresh tokens are not stored;
+// the storage must implement StatelessRefreshTokenStorage instead, which is used to check if they were revoked.
+type StatelessRefreshTokenStrategy interface {
+	RefreshTokenStrategy
+
+	// DecodeRefreshToken verifies and decodes the refresh token, hydrates the session if it is not nil, and returns
+	// the request the refresh token was issued for along with the time it was issued at.
+	DecodeRefreshToken(ctx context.Context, token string, session fosite.Session) (request fosite.Requester, issuedAt time.Time, err error)
+}
+
+// EncryptedRefreshTokenStrategy issues stateless refresh tokens which carry the grant in an envelope encrypted with
+// aead.AEADStrategy. Access tokens and authorization codes are delegated to the embedded CoreStrategy.
+//
+// The session must be serializable to JSON. The client of the decoded request only carries its ID.
+type EncryptedRefreshTokenStrategy struct {
+	CoreStrategy
+
+	// GlobalSecret is used to encrypt new refresh tokens.
+	GlobalSecret []byte
+
+	// RotatedGlobalSecrets are used to decrypt refresh tokens issued before the GlobalSecret was rotated.
+	RotatedGlobalSecrets [][]byte
+
+	// Algorithm defaults to aead.XChaCha20Poly1305.
+	Algorithm aead.Algorithm
+
+	RefreshTokenLifespan time.Duration
+}
+
+type encryptedRefreshToken struct {
+	ID                   string                      `json:"id"`
+	ClientID             string                      `json:"client_id"`
+	RequestedAt          time.Time                   `json:"requested_at"`
+	IssuedAt             time.Time                   `json:"issued_at"`
+	RequestedScope       fosite.Arguments            `json:"scope,omitempty"`
+	GrantedScope         fosite.Arguments            `json:"granted_scope,omitempty"`
+	RequestedAudience    fosite.Arguments            `json:"audience,omitempty"`
+	GrantedAudience      fosite.Arguments            `json:"granted_audience,omitempty"`
+	AuthorizationDetails fosite.AuthorizationDetails `json:"authorization_details,omitempty"`
+	Session              json.RawMessage             `json:"session"`
+}
+
+func (h *EncryptedRefreshTokenStrategy) aead() *aead.AEADStrategy {
+	return &aead.AEADStrategy{
+		Algorithm:            h.Algorithm,
+		GlobalSecret:         h.GlobalSecret,
+		RotatedGlobalSecrets: h.RotatedGlobalSecrets,
+	}
+}
+
+func (h *EncryptedRefreshTokenStrategy) RefreshTokenSignature(token string) string {
+	return h.aead().Signature(token)
+}
+
+func (h *EncryptedRefreshTokenStrategy) GenerateRefreshToken(_ context.Context, requester fosite.Requester) (token string, signature string, err error) {
+	session, err := json.Marshal(requester.GetSession())
+	if err != nil {
+		return "", "", errorsx.WithStack(err)
+	}
+
+	plaintext, err := json.Marshal(&encryptedRefreshToken{
+		ID:                   requester.GetID(),
+		ClientID:             requester.GetClient().GetID(),
+		RequestedAt:          requester.GetRequestedAt(),
+		IssuedAt:             time.Now().UTC(),
+		RequestedScope:       requester.GetRequestedScopes(),
+		GrantedScope:         requester.GetGrantedScopes(),
+		RequestedAudience:    requester.GetRequestedAudience(),
+		GrantedAudience:      requester.GetGrantedAudience(),
+		AuthorizationDetails: requester.GetAuthorizationDetails(),
+		Session:              session,
+	})
+	if err != nil {
+		return "", "", errorsx.WithStack(err)
+	}
+
+	return h.aead().Encrypt(plaintext)
+}
+
+func (h *EncryptedRefreshTokenStrategy) ValidateRefreshToken(ctx context.Context, r fosite.Requester, token string) error {
+	if _, _, err := h.DecodeRefreshToken(ctx, token, nil); err != nil {
+		return err
+	}
+
+	if session := r.GetSession().GetExpiresAt(fosite.RefreshTokenSession); !session.IsZero() && session.Before(time.Now().UTC()) {
+		return errorsx.WithStack(fosite.ErrTokenExpired.WithHintf("Refresh token expired at '%s' because the maximum lifetime of the grant has been reached.", session))
+	}
+
+	var exp = r.GetSession().GetExpiresAt(fosite.RefreshToken)
+	if exp.IsZero() {
+		if h.RefreshTokenLifespan > 0 && r.GetRequestedAt().Add(h.RefreshTokenLifespan).Before(time.Now().UTC()) {
+			return errorsx.WithStack(fosite.ErrTokenExpired.WithHintf("Refresh token expired at '%s'.", r.GetRequestedAt().Add(h.RefreshTokenLifespan)))
+		}
+		return nil
+	}
+
+	if exp.Before(time.Now().UTC()) {
+		return errorsx.WithStack(fosite.ErrTokenExpired.WithHintf("Refresh token expired at '%s'.", exp))
+	}
+	return nil
+}
+
+func (h *EncryptedRefreshTokenStrategy) DecodeRefreshToken(_ context.Context, token string, session fosite.Session) (fosite.Requester, time.Time, error) {
+	plaintext, err := h.aead().Decrypt(token)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	var decoded encryptedRefreshToken
+	if err := json.Unmarshal(plaintext, &decoded); err != nil {
+		return nil, time.Time{}, errorsx.WithStack(fosite.ErrInvalidTokenFormat.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	if session != nil {
+		if err := json.Unmarshal(decoded.Session, session); err != nil {
+			return nil, time.Time{}, errorsx.WithStack(err)
+		}
+	}
+
+	return &fosite.Request{
+		ID:                          decoded.ID,
+		RequestedAt:                 decoded.RequestedAt,
+		Client:                      &fosite.DefaultClient{ID: decoded.ClientID},
+		RequestedScope:              decoded.RequestedScope,
+		GrantedScope:                decoded.GrantedScope,
+		RequestedAudience:           decoded.RequestedAudience,
+		GrantedAudience:             decoded.GrantedAudience,
+		GrantedAuthorizationDetails: decoded.AuthorizationDetails,
+		Session:                     session,
+	}, decoded.IssuedAt, nil
+}
+
+// GetRefreshTokenSession returns the request the refresh token was issued for. Stateless refresh tokens are decoded
+// instead of loaded from the storage, which is only asked if they were revoked.
+func GetRefreshTokenSession(ctx context.Context, strategy RefreshTokenStrategy, store RefreshTokenStorage, token string, session fosite.Session) (fosite.Requester, error) {
+	stateless, ok := strategy.(StatelessRefreshTokenStrategy)
+	if !ok {
+		var request fosite.Requester
+		err := fosite.StorageOperation(ctx, "GetRefreshTokenSession", func(ctx context.Context) (err error) {
+			request, err = store.GetRefreshTokenSession(ctx, strategy.RefreshTokenSignature(token), session)
+			return err
+		})
+		return request, err
+	}
+
+	rs, ok := store.(StatelessRefreshTokenStorage)
+	if !ok {
+		return nil, errors.Errorf("The storage must implement StatelessRefreshTokenStorage to support stateless refresh tokens but got type: %T", store)
+	}
+
+	request, issuedAt, err := stateless.DecodeRefreshToken(ctx, token, session)
+	if err != nil {
+		return nil, errorsx.WithStack(fosite.ErrNotFound.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	var revokedAt time.Time
+	err = fosite.StorageOperation(ctx, "GetRefreshTokenRevokedAt", func(ctx context.Context) (err error) {
+		revokedAt, err = rs.GetRefreshTokenRevokedAt(ctx, request.GetID())
+		return err
+	})
+	if errors.Is(err, fosite.ErrNotFound) {
+		return request, nil
+	} else if err != nil {
+		return nil, err
+	} else if !issuedAt.After(revokedAt) {
+		return request, errorsx.WithStack(fosite.ErrInactiveToken)
+	}
+	return request, nil
+}
+
+// CreateRefreshTokenSession stores the refresh token, unless it is stateless.
+func CreateRefreshTokenSession(ctx context.Context, strategy RefreshTokenStrategy, store RefreshTokenStorage, signature string, request fosite.Requester) error {
+	if _, ok := strategy.(StatelessRefreshTokenStrategy); ok {
+		return nil
+	}
+	return fosite.StorageOperation(ctx, "CreateRefreshTokenSession", func(ctx context.Context) error {
+		return store.CreateRefreshTokenSession(ctx, signature, request)
+	})
+}
@@ -0,0 +1,163 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package oauth2
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/storage"
+)
+
+var encryptedRefreshTokenStrategy = &EncryptedRefreshTokenStrategy{
+	CoreStrategy:         &hmacshaStrategy,
+	GlobalSecret:         []byte("some-super-cool-secret-that-nobody-knows"),
+	RefreshTokenLifespan: time.Hour,
+}
+
+func TestEncryptedRefreshToken(t *testing.T) {
+	request := &fosite.Request{
+		ID:              "request-id",
+		RequestedAt:     time.Now().UTC().Round(time.Second),
+		Client:          &fosite.DefaultClient{ID: "foo"},
+		RequestedScope:  fosite.Arguments{"offline", "photos"},
+		GrantedScope:    fosite.Arguments{"offline"},
+		GrantedAudience: fosite.Arguments{"https://api.example.com"},
+		Session:         &fosite.DefaultSession{Subject: "peter", ExpiresAt: map[fosite.TokenType]time.Time{fosite.RefreshToken: time.Now().UTC().Add(time.Hour).Round(time.Second)}},
+	}
+
+	token, signature, err := encryptedRefreshTokenStrategy.GenerateRefreshToken(context.Background(), request)
+	require.NoError(t, err)
+	assert.Equal(t, signature, encryptedRefreshTokenStrategy.RefreshTokenSignature(token))
+	assert.NotContains(t, token, "peter")
+
+	session := new(fosite.DefaultSession)
+	decoded, issuedAt, err := encryptedRefreshTokenStrategy.DecodeRefreshToken(context.Background(), token, session)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().UTC(), issuedAt, time.Second*5)
+	assert.Equal(t, "request-id", decoded.GetID())
+	assert.Equal(t, "foo", decoded.GetClient().GetID())
+	assert.Equal(t, request.RequestedAt, decoded.GetRequestedAt())
+	assert.Equal(t, request.RequestedScope, decoded.GetRequestedScopes())
+	assert.Equal(t, request.GrantedScope, decoded.GetGrantedScopes())
+	assert.Equal(t, request.GrantedAudience, decoded.GetGrantedAudience())
+	assert.Equal(t, "peter", session.Subject)
+	assert.Equal(t, request.Session.GetExpiresAt(fosite.RefreshToken), session.GetExpiresAt(fosite.RefreshToken))
+	require.NoError(t, encryptedRefreshTokenStrategy.ValidateRefreshToken(context.Background(), decoded, token))
+
+	t.Run("case=decodes tokens encrypted with rotated secrets", func(t *testing.T) {
+		rotated := *encryptedRefreshTokenStrategy
+		rotated.GlobalSecret = []byte("another-super-cool-secret-that-nobody-knows")
+		_, _, err := rotated.DecodeRefreshToken(context.Background(), token, nil)
+		require.ErrorIs(t, err, fosite.ErrTokenSignatureMismatch)
+
+		rotated.RotatedGlobalSecrets = [][]byte{encryptedRefreshTokenStrategy.GlobalSecret}
+		_, _, err = rotated.DecodeRefreshToken(context.Background(), token, nil)
+		require.NoError(t, err)
+	})
+
+	t.Run("case=rejects tampered tokens", func(t *testing.T) {
+		tampered := []byte(token)
+		tampered[len(tampered)/2] ^= 1
+		_, _, err := encryptedRefreshTokenStrategy.DecodeRefreshToken(context.Background(), string(tampered), nil)
+		require.Error(t, err)
+	})
+
+	t.Run("case=rejects expired tokens", func(t *testing.T) {
+		request.Session.SetExpiresAt(fosite.RefreshToken, time.Now().UTC().Add(-time.Minute))
+		defer request.Session.SetExpiresAt(fosite.RefreshToken, time.Now().UTC().Add(time.Hour))
+
+		expired, _, err := encryptedRefreshTokenStrategy.GenerateRefreshToken(context.Background(), request)
+		require.NoError(t, err)
+		decoded, _, err := encryptedRefreshTokenStrategy.DecodeRefreshToken(context.Background(), expired, new(fosite.DefaultSession))
+		require.NoError(t, err)
+		require.ErrorIs(t, encryptedRefreshTokenStrategy.ValidateRefreshToken(context.Background(), decoded, expired), fosite.ErrTokenExpired)
+	})
+}
+
+func TestRefreshFlow_EncryptedRefreshToken(t *testing.T) {
+	store := storage.NewMemoryStore()
+	h := &RefreshTokenGrantHandler{
+		TokenRevocationStorage:   store,
+		RefreshTokenStrategy:     encryptedRefreshTokenStrategy,
+		AccessTokenStrategy:      encryptedRefreshTokenStrategy,
+		ScopeStrategy:            fosite.HierarchicScopeStrategy,
+		AudienceMatchingStrategy: fosite.DefaultAudienceMatchingStrategy,
+		AccessTokenLifespan:      time.Hour,
+		RefreshTokenLifespan:     time.Hour,
+	}
+	client := &fosite.DefaultClient{ID: "foo", GrantTypes: fosite.Arguments{"refresh_token"}, Scopes: []string{"offline"}}
+
+	token, _, err := encryptedRefreshTokenStrategy.GenerateRefreshToken(context.Background(), &fosite.Request{
+		ID:           "request-id",
+		RequestedAt:  time.Now().UTC(),
+		Client:       client,
+		GrantedScope: fosite.Arguments{"offline"},
+		Session:      &fosite.DefaultSession{Subject: "peter"},
+	})
+	require.NoError(t, err)
+
+	refresh := func(t *testing.T, token string) (string, error) {
+		areq := fosite.NewAccessRequest(new(fosite.DefaultSession))
+		areq.GrantTypes = fosite.Arguments{"refresh_token"}
+		areq.Client = client
+		areq.Form = url.Values{"refresh_token": {token}}
+		if err := h.HandleTokenEndpointRequest(context.Background(), areq); err != nil {
+			return "", err
+		}
+
+		assert.Equal(t, "peter", areq.GetSession().GetSubject())
+		assert.Equal(t, fosite.Arguments{"offline"}, areq.GetGrantedScopes())
+
+		aresp := fosite.NewAccessResponse()
+		if err := h.PopulateTokenEndpointResponse(context.Background(), areq, aresp); err != nil {
+			return "", err
+		}
+		return aresp.GetExtra("refresh_token").(string), nil
+	}
+
+	refreshed, err := refresh(t, token)
+	require.NoError(t, err)
+	assert.NotEqual(t, token, refreshed)
+
+	refreshed, err = refresh(t, refreshed)
+	require.NoError(t, err)
+
+	t.Run("case=the grant of a rotated refresh token is revoked when it is used again", func(t *testing.T) {
+		_, err := refresh(t, token)
+		require.ErrorIs(t, err, fosite.ErrInactiveToken)
+
+		_, err = refresh(t, refreshed)
+		require.ErrorIs(t, err, fosite.ErrInactiveToken)
+	})
+
+	t.Run("case=rejects unknown refresh tokens", func(t *testing.T) {
+		_, err := refresh(t, "foo")
+		require.ErrorIs(t, err, fosite.ErrInvalidGrant)
+	})
+}
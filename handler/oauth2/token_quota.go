@@ -0,0 +1,130 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package oauth2
+
+import (
+	"context"
+
+	"github.com/ory/x/errorsx"
+
+	"github.com/ory/fosite"
+)
+
+// TokenQuotaStorage lists the active refresh tokens to enforce the DefaultTokenQuotaPolicy.
+type TokenQuotaStorage interface {
+	TokenRevocationStorage
+
+	// GetActiveRefreshTokenSessions returns the requests of the active refresh tokens issued by the client, ordered
+	// by the time they were requested, oldest first. If subject is not empty, only the refresh tokens of the subject
+	// are returned.
+	GetActiveRefreshTokenSessions(ctx context.Context, clientID string, subject string) ([]fosite.Requester, error)
+}
+
+// QuotaExceededAction is the action of the DefaultTokenQuotaPolicy once a quota is reached.
+type QuotaExceededAction int
+
+const (
+	// RejectWhenQuotaExceeded rejects the request with invalid_grant.
+	RejectWhenQuotaExceeded QuotaExceededAction = iota
+
+	// RevokeOldestWhenQuotaExceeded revokes the oldest refresh tokens, and their access tokens, to make room for the
+	// new one.
+	RevokeOldestWhenQuotaExceeded
+)
+
+// DefaultTokenQuotaPolicy limits the number of active refresh tokens per subject and per client. Only requests
+// which are issued a new refresh token are limited, requests using the refresh_token grant rotate an existing one.
+type DefaultTokenQuotaPolicy struct {
+	Storage TokenQuotaStorage
+
+	// MaxActiveRefreshTokensPerSubject limits the refresh tokens a client holds for one subject. Zero means no limit.
+	MaxActiveRefreshTokensPerSubject int
+
+	// MaxActiveRefreshTokensPerClient limits the refresh tokens a client holds for all subjects. Zero means no limit.
+	MaxActiveRefreshTokensPerClient int
+
+	// Action defaults to RejectWhenQuotaExceeded.
+	Action QuotaExceededAction
+
+	// RefreshTokenScopes must match the RefreshTokenScopes of the handlers, as refresh tokens are only issued if one
+	// of them is granted. When empty, all requests are issued refresh tokens.
+	RefreshTokenScopes []string
+}
+
+var _ fosite.TokenQuotaPolicy = (*DefaultTokenQuotaPolicy)(nil)
+
+func (p *DefaultTokenQuotaPolicy) EnforceTokenQuota(ctx context.Context, requester fosite.AccessRequester) error {
+	if !p.issuesRefreshToken(requester) {
+		return nil
+	}
+
+	if p.MaxActiveRefreshTokensPerSubject > 0 {
+		if err := p.enforce(ctx, requester.GetClient().GetID(), requester.GetSession().GetSubject(), p.MaxActiveRefreshTokensPerSubject); err != nil {
+			return err
+		}
+	}
+	if p.MaxActiveRefreshTokensPerClient > 0 {
+		if err := p.enforce(ctx, requester.GetClient().GetID(), "", p.MaxActiveRefreshTokensPerClient); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *DefaultTokenQuotaPolicy) issuesRefreshToken(requester fosite.AccessRequester) bool {
+	if requester.GetGrantTypes().ExactOne("refresh_token") || requester.GetClient() == nil {
+		return false
+	} else if len(p.RefreshTokenScopes) > 0 && !requester.GetGrantedScopes().HasOneOf(p.RefreshTokenScopes...) {
+		return false
+	}
+	return requester.GetClient().GetGrantTypes().Has("refresh_token")
+}
+
+func (p *DefaultTokenQuotaPolicy) enforce(ctx context.Context, clientID, subject string, max int) error {
+	var active []fosite.Requester
+	if err := fosite.StorageOperation(ctx, "GetActiveRefreshTokenSessions", func(ctx context.Context) (err error) {
+		active, err = p.Storage.GetActiveRefreshTokenSessions(ctx, clientID, subject)
+		return err
+	}); err != nil {
+		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	if len(active) < max {
+		return nil
+	} else if p.Action != RevokeOldestWhenQuotaExceeded {
+		return errorsx.WithStack(fosite.ErrInvalidGrant.WithHintf("The maximum of %d active refresh tokens has been reached.", max))
+	}
+
+	for _, request := range active[:len(active)-max+1] {
+		requestID := request.GetID()
+		if err := fosite.StorageOperation(ctx, "RevokeRefreshToken", func(ctx context.Context) error {
+			return p.Storage.RevokeRefreshToken(ctx, requestID)
+		}); err != nil {
+			return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+		} else if err := fosite.StorageOperation(ctx, "RevokeAccessToken", func(ctx context.Context) error {
+			return p.Storage.RevokeAccessToken(ctx, requestID)
+		}); err != nil {
+			return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package oauth2
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/storage"
+)
+
+func TestDefaultTokenQuotaPolicy(t *testing.T) {
+	client := &fosite.DefaultClient{ID: "foo", GrantTypes: fosite.Arguments{"authorization_code", "refresh_token"}}
+	requestFor := func(subject string, grantType string) *fosite.AccessRequest {
+		ar := fosite.NewAccessRequest(&fosite.DefaultSession{Subject: subject})
+		ar.Client = client
+		ar.GrantTypes = fosite.Arguments{grantType}
+		ar.GrantedScope = fosite.Arguments{"offline"}
+		return ar
+	}
+	newStore := func(t *testing.T) *storage.MemoryStore {
+		store := storage.NewMemoryStore()
+		for i, subject := range []string{"peter", "peter", "alice"} {
+			ar := requestFor(subject, "authorization_code")
+			ar.ID = fmt.Sprintf("request-%d", i)
+			ar.RequestedAt = time.Now().UTC().Add(time.Duration(i) * time.Minute)
+			require.NoError(t, store.CreateRefreshTokenSession(context.Background(), fmt.Sprintf("signature-%d", i), ar))
+		}
+		return store
+	}
+
+	for _, c := range []struct {
+		d         string
+		policy    *DefaultTokenQuotaPolicy
+		requester *fosite.AccessRequest
+		err       error
+		revoked   []string
+	}{
+		{
+			d:         "should allow requests below the quota",
+			policy:    &DefaultTokenQuotaPolicy{MaxActiveRefreshTokensPerSubject: 3},
+			requester: requestFor("peter", "authorization_code"),
+		},
+		{
+			d:         "should reject requests exceeding the quota of the subject",
+			policy:    &DefaultTokenQuotaPolicy{MaxActiveRefreshTokensPerSubject: 2},
+			requester: requestFor("peter", "authorization_code"),
+			err:       fosite.ErrInvalidGrant,
+		},
+		{
+			d:         "should reject requests exceeding the quota of the client",
+			policy:    &DefaultTokenQuotaPolicy{MaxActiveRefreshTokensPerClient: 3},
+			requester: requestFor("bob", "authorization_code"),
+			err:       fosite.ErrInvalidGrant,
+		},
+		{
+			d:         "should not limit the refresh token grant",
+			policy:    &DefaultTokenQuotaPolicy{MaxActiveRefreshTokensPerSubject: 1},
+			requester: requestFor("peter", "refresh_token"),
+		},
+		{
+			d:         "should not limit requests which are not issued a refresh token",
+			policy:    &DefaultTokenQuotaPolicy{MaxActiveRefreshTokensPerSubject: 1, RefreshTokenScopes: []string{"offline_access"}},
+			requester: requestFor("peter", "authorization_code"),
+		},
+		{
+			d:         "should revoke the oldest refresh tokens of the subject",
+			policy:    &DefaultTokenQuotaPolicy{MaxActiveRefreshTokensPerSubject: 2, Action: RevokeOldestWhenQuotaExceeded},
+			requester: requestFor("peter", "authorization_code"),
+			revoked:   []string{"signature-0"},
+		},
+	} {
+		t.Run("case="+c.d, func(t *testing.T) {
+			store := newStore(t)
+			c.policy.Storage = store
+
+			err := c.policy.EnforceTokenQuota(context.Background(), c.requester)
+			if c.err != nil {
+				require.Error(t, err)
+				assert.True(t, errors.Is(err, c.err), "%+v", err)
+				return
+			}
+			require.NoError(t, err)
+
+			active, err := store.GetActiveRefreshTokenSessions(context.Background(), "foo", "")
+			require.NoError(t, err)
+			assert.Len(t, active, 3-len(c.revoked))
+			for _, signature := range c.revoked {
+				_, err := store.GetRefreshTokenSession(context.Background(), signature, nil)
+				assert.True(t, errors.Is(err, fosite.ErrInactiveToken), "%+v", err)
+			}
+		})
+	}
+}
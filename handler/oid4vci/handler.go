@@ -0,0 +1,258 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package oid4vci
+
+import (
+	"context"
+	"crypto/subtle"
+	"time"
+
+	"github.com/ory/x/errorsx"
+	"github.com/pkg/errors"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/oauth2"
+	"github.com/ory/fosite/storage"
+)
+
+const grantTypePreAuthorizedCode = "urn:ietf:params:oauth:grant-type:pre-authorized_code"
+
+// Handler implements the pre-authorized code grant of OpenID for Verifiable Credential Issuance, see
+// https://openid.net/specs/openid-4-verifiable-credential-issuance-1_0.html#section-6.1
+//
+// The credential issuer creates a pre-authorized code for a credential offer using IssuePreAuthorizedCode after it
+// authenticated the end user. The wallet exchanges the code, and the transaction code if one is required, for an
+// access token which is restricted to the scopes and authorization details of the credential offer.
+type Handler struct {
+	Strategy            PreAuthorizedCodeStrategy
+	Storage             PreAuthorizedCodeStorage
+	AccessTokenStrategy oauth2.AccessTokenStrategy
+	AccessTokenStorage  oauth2.AccessTokenStorage
+
+	// AccessTokenLifespan defines the lifetime of an access token.
+	AccessTokenLifespan time.Duration
+
+	// PreAuthorizedCodeLifespan defines the lifetime of a pre-authorized code.
+	PreAuthorizedCodeLifespan time.Duration
+
+	// AllowAnonymousAccess indicates, if wallets can exchange pre-authorized codes without authenticating as a
+	// client.
+	AllowAnonymousAccess bool
+
+	// FailedAuthenticationRecorder, if set, is informed about invalid transaction codes, which enables limiting
+	// the attempts to guess them.
+	FailedAuthenticationRecorder fosite.FailedAuthenticationRecorder
+}
+
+// IssuePreAuthorizedCode creates a pre-authorized code for a credential offer. The request must contain the session
+// of the authenticated end user as well as the scopes and authorization details granted for the credentials to be
+// issued. If txCode is not empty, the wallet must send it as "tx_code" alongside the pre-authorized code.
+func (c *Handler) IssuePreAuthorizedCode(ctx context.Context, request fosite.Requester, txCode string) (string, error) {
+	code, signature, err := c.Strategy.GeneratePreAuthorizedCode(ctx, request)
+	if err != nil {
+		return "", errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	var txCodeSignature string
+	if txCode != "" {
+		txCodeSignature, err = c.Strategy.TransactionCodeSignature(txCode)
+		if err != nil {
+			return "", errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+		}
+	}
+
+	request.GetSession().SetExpiresAt(fosite.PreAuthorizedCode, time.Now().UTC().Add(c.PreAuthorizedCodeLifespan).Round(time.Second))
+	if err := fosite.StorageOperation(ctx, "CreatePreAuthorizedCodeSession", func(ctx context.Context) error {
+		return c.Storage.CreatePreAuthorizedCodeSession(ctx, signature, txCodeSignature, request.Sanitize(nil))
+	}); err != nil {
+		return "", errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	return code, nil
+}
+
+// HandleTokenEndpointRequest implements https://openid.net/specs/openid-4-verifiable-credential-issuance-1_0.html#section-6.1
+func (c *Handler) HandleTokenEndpointRequest(ctx context.Context, request fosite.AccessRequester) error {
+	if err := c.CheckRequest(request); err != nil {
+		return err
+	}
+
+	code := request.GetRequestForm().Get("pre-authorized_code")
+	if code == "" {
+		return errorsx.WithStack(fosite.ErrInvalidRequest.WithHintf("The pre-authorized_code request parameter must be set when using grant_type of '%s'.", grantTypePreAuthorizedCode))
+	}
+
+	signature := c.Strategy.PreAuthorizedCodeSignature(code)
+	var codeRequest fosite.Requester
+	var txCodeSignature string
+	err := fosite.StorageOperation(ctx, "GetPreAuthorizedCodeSession", func(ctx context.Context) (err error) {
+		codeRequest, txCodeSignature, err = c.Storage.GetPreAuthorizedCodeSession(ctx, signature, request.GetSession())
+		return err
+	})
+	if errors.Is(err, fosite.ErrNotFound) {
+		return errorsx.WithStack(fosite.ErrInvalidGrant.WithWrap(err).WithDebug(err.Error()))
+	} else if errors.Is(err, fosite.ErrInvalidatedPreAuthorizedCode) {
+		return errorsx.WithStack(fosite.ErrInvalidGrant.WithHint("The pre-authorized code has already been used."))
+	} else if err != nil {
+		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	// This needs to happen after store retrieval for the session to be hydrated properly
+	if err := c.Strategy.ValidatePreAuthorizedCode(ctx, codeRequest, code); err != nil {
+		return errorsx.WithStack(fosite.ErrInvalidGrant.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	if request.GetClient() == nil {
+		// The wallet did not authenticate, so the access token is issued to the client the pre-authorized code was
+		// issued to.
+		if ar, ok := request.(*fosite.AccessRequest); ok {
+			ar.Client = codeRequest.GetClient()
+		}
+	} else if codeClient := codeRequest.GetClient(); codeClient != nil && codeClient.GetID() != "" && codeClient.GetID() != request.GetClient().GetID() {
+		return errorsx.WithStack(fosite.ErrInvalidGrant.WithHint("The OAuth 2.0 Client ID from this request does not match the one the pre-authorized code was issued to."))
+	}
+
+	if err := c.checkTransactionCode(ctx, request, signature, txCodeSignature); err != nil {
+		return err
+	}
+
+	request.SetRequestedScopes(codeRequest.GetRequestedScopes())
+	request.SetRequestedAudience(codeRequest.GetRequestedAudience())
+	request.SetSession(codeRequest.GetSession().Clone())
+	request.SetID(codeRequest.GetID())
+	for _, scope := range codeRequest.GetGrantedScopes() {
+		request.GrantScope(scope)
+	}
+	for _, audience := range codeRequest.GetGrantedAudience() {
+		request.GrantAudience(audience)
+	}
+	request.GrantAuthorizationDetails(codeRequest.GetAuthorizationDetails())
+
+	request.GetSession().SetExpiresAt(fosite.AccessToken, time.Now().UTC().Add(fosite.GetAccessTokenLifespan(ctx, c.AccessTokenLifespan)).Round(time.Second))
+	return nil
+}
+
+func (c *Handler) checkTransactionCode(ctx context.Context, request fosite.AccessRequester, signature, txCodeSignature string) error {
+	if txCodeSignature == "" {
+		return nil
+	}
+
+	txCode := request.GetRequestForm().Get("tx_code")
+	if txCode == "" {
+		return errorsx.WithStack(fosite.ErrInvalidRequest.WithHint("The tx_code request parameter must be set because the pre-authorized code requires a transaction code."))
+	} else if err := fosite.CheckAuthenticationAllowed(ctx, c.FailedAuthenticationRecorder, fosite.TransactionCodeAuthentication, signature); err != nil {
+		return err
+	}
+
+	actual, err := c.Strategy.TransactionCodeSignature(txCode)
+	if err != nil {
+		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	} else if subtle.ConstantTimeCompare([]byte(actual), []byte(txCodeSignature)) != 1 {
+		return fosite.RecordFailedAuthentication(ctx, c.FailedAuthenticationRecorder, fosite.TransactionCodeAuthentication, signature, errorsx.WithStack(fosite.ErrInvalidGrant.WithHint("The transaction code is invalid.")))
+	}
+
+	fosite.RecordSuccessfulAuthentication(ctx, c.FailedAuthenticationRecorder, fosite.TransactionCodeAuthentication, signature)
+	return nil
+}
+
+func (c *Handler) PopulateTokenEndpointResponse(ctx context.Context, requester fosite.AccessRequester, responder fosite.AccessResponder) error {
+	if !c.CanHandleTokenEndpointRequest(requester) {
+		return errorsx.WithStack(fosite.ErrUnknownRequest)
+	}
+
+	signature := c.Strategy.PreAuthorizedCodeSignature(requester.GetRequestForm().Get("pre-authorized_code"))
+	access, accessSignature, err := c.AccessTokenStrategy.GenerateAccessToken(ctx, requester)
+	if err != nil {
+		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	ctx, err = storage.MaybeBeginTx(ctx, c.Storage)
+	if err != nil {
+		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	if err := fosite.StorageOperation(ctx, "InvalidatePreAuthorizedCodeSession", func(ctx context.Context) error {
+		return c.Storage.InvalidatePreAuthorizedCodeSession(ctx, signature)
+	}); err != nil {
+		return rollback(ctx, c.Storage, err)
+	} else if err := fosite.StorageOperation(ctx, "CreateAccessTokenSession", func(ctx context.Context) error {
+		return c.AccessTokenStorage.CreateAccessTokenSession(ctx, accessSignature, requester.Sanitize([]string{}))
+	}); err != nil {
+		return rollback(ctx, c.Storage, err)
+	}
+
+	responder.SetAccessToken(access)
+	responder.SetTokenType("bearer")
+	responder.SetExpiresIn(getExpiresIn(requester, fosite.AccessToken, fosite.GetAccessTokenLifespan(ctx, c.AccessTokenLifespan), time.Now().UTC()))
+	responder.SetScopes(requester.GetGrantedScopes())
+
+	if err := storage.MaybeCommitTx(ctx, c.Storage); err != nil {
+		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	return nil
+}
+
+func (c *Handler) CanSkipClientAuth(requester fosite.AccessRequester) bool {
+	return c.AllowAnonymousAccess
+}
+
+func (c *Handler) CanHandleTokenEndpointRequest(requester fosite.AccessRequester) bool {
+	// grant_type REQUIRED.
+	// Value MUST be set to "urn:ietf:params:oauth:grant-type:pre-authorized_code"
+	return requester.GetGrantTypes().ExactOne(grantTypePreAuthorizedCode)
+}
+
+func (c *Handler) CheckRequest(request fosite.AccessRequester) error {
+	if !c.CanHandleTokenEndpointRequest(request) {
+		return errorsx.WithStack(fosite.ErrUnknownRequest)
+	}
+
+	// Client authentication is optional if anonymous access is allowed. If the client is authenticated, it must be
+	// allowed to use the grant type.
+	client := request.GetClient()
+	if client == nil && c.CanSkipClientAuth(request) {
+		return nil
+	} else if client == nil || !client.GetGrantTypes().Has(grantTypePreAuthorizedCode) {
+		return errorsx.WithStack(fosite.ErrUnauthorizedClient.WithHintf("The OAuth 2.0 Client is not allowed to use authorization grant \"%s\".", grantTypePreAuthorizedCode))
+	}
+
+	return nil
+}
+
+func rollback(ctx context.Context, s interface{}, err error) error {
+	if rollBackTxnErr := storage.MaybeRollbackTx(ctx, s); rollBackTxnErr != nil {
+		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebugf("error: %s; rollback error: %s", err, rollBackTxnErr))
+	}
+	return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+}
+
+func getExpiresIn(r fosite.Requester, key fosite.TokenType, defaultLifespan time.Duration, now time.Time) time.Duration {
+	if r.GetSession().GetExpiresAt(key).IsZero() {
+		return defaultLifespan
+	}
+	return time.Duration(r.GetSession().GetExpiresAt(key).UnixNano() - now.UnixNano())
+}
+
+func (c *Handler) PopulateDiscoveryMetadata(ctx context.Context, metadata *fosite.DiscoveryMetadata) {
+	metadata.GrantTypesSupported = append(metadata.GrantTypesSupported, grantTypePreAuthorizedCode)
+}
@@ -0,0 +1,199 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package oid4vci
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/oauth2"
+	"github.com/ory/fosite/storage"
+	"github.com/ory/fosite/token/hmac"
+)
+
+var _ PreAuthorizedCodeStorage = storage.NewMemoryStore()
+
+func newHandler(store *storage.MemoryStore) *Handler {
+	secret := []byte("foobarfoobarfoobarfoobarfoobarfoobarfoobarfoobar")
+	return &Handler{
+		Strategy: &DefaultPreAuthorizedCodeStrategy{
+			Enigma:                    &hmac.HMACStrategy{GlobalSecret: secret},
+			PreAuthorizedCodeLifespan: time.Minute * 5,
+		},
+		Storage: store,
+		AccessTokenStrategy: &oauth2.HMACSHAStrategy{
+			Enigma:              &hmac.HMACStrategy{GlobalSecret: secret},
+			AccessTokenLifespan: time.Hour,
+		},
+		AccessTokenStorage:        store,
+		AccessTokenLifespan:       time.Hour,
+		PreAuthorizedCodeLifespan: time.Minute * 5,
+	}
+}
+
+func TestHandler(t *testing.T) {
+	issuer := &fosite.DefaultClient{ID: "issuer", GrantTypes: fosite.Arguments{grantTypePreAuthorizedCode}}
+	wallet := &fosite.DefaultClient{ID: "wallet", GrantTypes: fosite.Arguments{grantTypePreAuthorizedCode}}
+
+	for k, c := range []struct {
+		d           string
+		txCode      string
+		beforeIssue func(h *Handler)
+		setup       func(h *Handler, ar *fosite.AccessRequest)
+		expectErr   error
+	}{
+		{
+			d: "should pass",
+		},
+		{
+			d:      "should pass with the transaction code",
+			txCode: "493536",
+			setup: func(h *Handler, ar *fosite.AccessRequest) {
+				ar.Form.Set("tx_code", "493536")
+			},
+		},
+		{
+			d: "should pass without client authentication if allowed",
+			setup: func(h *Handler, ar *fosite.AccessRequest) {
+				ar.Client = nil
+				h.AllowAnonymousAccess = true
+			},
+		},
+		{
+			d: "should fail because the grant type is not supported",
+			setup: func(h *Handler, ar *fosite.AccessRequest) {
+				ar.GrantTypes = fosite.Arguments{"authorization_code"}
+			},
+			expectErr: fosite.ErrUnknownRequest,
+		},
+		{
+			d: "should fail because the client may not use the grant",
+			setup: func(h *Handler, ar *fosite.AccessRequest) {
+				ar.Client = &fosite.DefaultClient{ID: "issuer"}
+			},
+			expectErr: fosite.ErrUnauthorizedClient,
+		},
+		{
+			d: "should fail because the code was issued to another client",
+			setup: func(h *Handler, ar *fosite.AccessRequest) {
+				ar.Client = wallet
+			},
+			expectErr: fosite.ErrInvalidGrant,
+		},
+		{
+			d: "should fail because the code is missing",
+			setup: func(h *Handler, ar *fosite.AccessRequest) {
+				ar.Form.Del("pre-authorized_code")
+			},
+			expectErr: fosite.ErrInvalidRequest,
+		},
+		{
+			d: "should fail because the code is unknown",
+			setup: func(h *Handler, ar *fosite.AccessRequest) {
+				code, _, err := h.Strategy.GeneratePreAuthorizedCode(context.Background(), ar)
+				require.NoError(t, err)
+				ar.Form.Set("pre-authorized_code", code)
+			},
+			expectErr: fosite.ErrInvalidGrant,
+		},
+		{
+			d: "should fail because the code has been used",
+			setup: func(h *Handler, ar *fosite.AccessRequest) {
+				require.NoError(t, h.Storage.InvalidatePreAuthorizedCodeSession(context.Background(), h.Strategy.PreAuthorizedCodeSignature(ar.Form.Get("pre-authorized_code"))))
+			},
+			expectErr: fosite.ErrInvalidGrant,
+		},
+		{
+			d: "should fail because the code expired",
+			beforeIssue: func(h *Handler) {
+				h.PreAuthorizedCodeLifespan = -time.Minute
+			},
+			expectErr: fosite.ErrInvalidGrant,
+		},
+		{
+			d:         "should fail because the transaction code is missing",
+			txCode:    "493536",
+			expectErr: fosite.ErrInvalidRequest,
+		},
+		{
+			d:      "should fail because the transaction code is wrong",
+			txCode: "493536",
+			setup: func(h *Handler, ar *fosite.AccessRequest) {
+				ar.Form.Set("tx_code", "123456")
+			},
+			expectErr: fosite.ErrInvalidGrant,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			store := storage.NewMemoryStore()
+			h := newHandler(store)
+
+			ar := fosite.NewAccessRequest(new(fosite.DefaultSession))
+			ar.GrantTypes = fosite.Arguments{grantTypePreAuthorizedCode}
+			ar.Client = issuer
+			ar.Form = url.Values{}
+
+			if c.beforeIssue != nil {
+				c.beforeIssue(h)
+			}
+
+			offer := fosite.NewRequest()
+			offer.Client = issuer
+			offer.Session = &fosite.DefaultSession{Subject: "peter"}
+			offer.RequestedScope = fosite.Arguments{"UniversityDegree"}
+			offer.GrantScope("UniversityDegree")
+			code, err := h.IssuePreAuthorizedCode(context.Background(), offer, c.txCode)
+			require.NoError(t, err)
+			ar.Form.Set("pre-authorized_code", code)
+
+			if c.setup != nil {
+				c.setup(h, ar)
+			}
+
+			err = h.HandleTokenEndpointRequest(context.Background(), ar)
+			if c.expectErr != nil {
+				require.EqualError(t, err, c.expectErr.Error())
+				return
+			}
+			require.NoError(t, err)
+
+			resp := fosite.NewAccessResponse()
+			require.NoError(t, h.PopulateTokenEndpointResponse(context.Background(), ar, resp))
+			assert.NotEmpty(t, resp.GetAccessToken())
+			assert.Equal(t, "bearer", resp.GetTokenType())
+			assert.Equal(t, fosite.Arguments{"UniversityDegree"}, ar.GetGrantedScopes())
+			assert.Equal(t, "peter", ar.GetSession().GetSubject())
+			assert.Equal(t, "issuer", ar.GetClient().GetID())
+
+			// The pre-authorized code can only be used once.
+			ar.GrantedScope = fosite.Arguments{}
+			require.EqualError(t, h.HandleTokenEndpointRequest(context.Background(), ar), fosite.ErrInvalidGrant.Error())
+		})
+	}
+}
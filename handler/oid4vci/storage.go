@@ -0,0 +1,45 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package oid4vci
+
+import (
+	"context"
+
+	"github.com/ory/fosite"
+)
+
+// PreAuthorizedCodeStorage handles the storage of the requests pre-authorized codes were issued for.
+type PreAuthorizedCodeStorage interface {
+	// CreatePreAuthorizedCodeSession stores the request for a given pre-authorized code signature. The
+	// txCodeSignature is the signature of the transaction code which must be sent alongside the pre-authorized
+	// code, or empty if no transaction code is required.
+	CreatePreAuthorizedCodeSession(ctx context.Context, signature string, txCodeSignature string, request fosite.Requester) (err error)
+
+	// GetPreAuthorizedCodeSession hydrates the session based on the given pre-authorized code signature and returns
+	// the request and the signature of its transaction code. If the pre-authorized code has been invalidated with
+	// `InvalidatePreAuthorizedCodeSession`, this method should return fosite.ErrInvalidatedPreAuthorizedCode.
+	GetPreAuthorizedCodeSession(ctx context.Context, signature string, session fosite.Session) (request fosite.Requester, txCodeSignature string, err error)
+
+	// InvalidatePreAuthorizedCodeSession is called when a pre-authorized code is being exchanged for an access
+	// token. Consecutive requests to GetPreAuthorizedCodeSession should return fosite.ErrInvalidatedPreAuthorizedCode.
+	InvalidatePreAuthorizedCodeSession(ctx context.Context, signature string) (err error)
+}
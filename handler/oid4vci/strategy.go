@@ -0,0 +1,39 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package oid4vci
+
+import (
+	"context"
+
+	"github.com/ory/fosite"
+)
+
+// PreAuthorizedCodeStrategy handles the generation and validation of pre-authorized codes and of the transaction
+// codes protecting them, see https://openid.net/specs/openid-4-verifiable-credential-issuance-1_0.html#section-4.1.1
+type PreAuthorizedCodeStrategy interface {
+	PreAuthorizedCodeSignature(token string) string
+	GeneratePreAuthorizedCode(ctx context.Context, requester fosite.Requester) (token string, signature string, err error)
+	ValidatePreAuthorizedCode(ctx context.Context, requester fosite.Requester, token string) (err error)
+
+	// TransactionCodeSignature returns the signature under which a transaction code is stored.
+	TransactionCodeSignature(code string) (signature string, err error)
+}
@@ -0,0 +1,60 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package oid4vci
+
+import (
+	"context"
+	"time"
+
+	"github.com/ory/x/errorsx"
+
+	"github.com/ory/fosite"
+	enigma "github.com/ory/fosite/token/hmac"
+)
+
+type DefaultPreAuthorizedCodeStrategy struct {
+	Enigma                    *enigma.HMACStrategy
+	PreAuthorizedCodeLifespan time.Duration
+}
+
+func (h *DefaultPreAuthorizedCodeStrategy) PreAuthorizedCodeSignature(token string) string {
+	return h.Enigma.Signature(token)
+}
+
+func (h *DefaultPreAuthorizedCodeStrategy) GeneratePreAuthorizedCode(_ context.Context, _ fosite.Requester) (token string, signature string, err error) {
+	return h.Enigma.Generate()
+}
+
+func (h *DefaultPreAuthorizedCodeStrategy) ValidatePreAuthorizedCode(_ context.Context, r fosite.Requester, token string) (err error) {
+	var exp = r.GetSession().GetExpiresAt(fosite.PreAuthorizedCode)
+	if exp.IsZero() && r.GetRequestedAt().Add(h.PreAuthorizedCodeLifespan).Before(time.Now().UTC()) {
+		return errorsx.WithStack(fosite.ErrTokenExpired.WithHintf("Pre-authorized code expired at '%s'.", r.GetRequestedAt().Add(h.PreAuthorizedCodeLifespan)))
+	}
+	if !exp.IsZero() && exp.Before(time.Now().UTC()) {
+		return errorsx.WithStack(fosite.ErrTokenExpired.WithHintf("Pre-authorized code expired at '%s'.", exp))
+	}
+	return h.Enigma.Validate(token)
+}
+
+func (h *DefaultPreAuthorizedCodeStrategy) TransactionCodeSignature(code string) (string, error) {
+	return h.Enigma.GenerateHMACForString(code)
+}
@@ -0,0 +1,110 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package openid
+
+import (
+	"context"
+	"strings"
+
+	"github.com/ory/fosite"
+)
+
+// ACRRequest contains the authentication context class references requested by a client, in order of
+// preference.
+type ACRRequest struct {
+	Values []string
+
+	// Essential is true if the acr claim was requested as an essential claim using the claims parameter. Values
+	// requested using the acr_values parameter are voluntary.
+	Essential bool
+}
+
+// RequestedACR returns the authentication context class references requested for the ID token using either the
+// claims parameter or the acr_values parameter. The claims parameter takes precedence if both are set.
+func RequestedACR(requester fosite.Requester) ACRRequest {
+	if cr := fosite.ClaimsRequestFromRequester(requester); cr != nil && cr.IDToken.Has("acr") {
+		r := cr.IDToken["acr"]
+		request := ACRRequest{Essential: r.IsEssential()}
+		if r != nil {
+			if value, ok := r.Value.(string); ok && value != "" {
+				request.Values = append(request.Values, value)
+			}
+			for _, v := range r.Values {
+				if value, ok := v.(string); ok && value != "" {
+					request.Values = append(request.Values, value)
+				}
+			}
+		}
+		if len(request.Values) > 0 {
+			return request
+		}
+	}
+
+	return ACRRequest{Values: fosite.RemoveEmpty(strings.Split(requester.GetRequestForm().Get("acr_values"), " "))}
+}
+
+// AuthenticationContextPolicy decides whether the authentication context class reference achieved by the
+// authentication of the end-user satisfies the request of the client.
+type AuthenticationContextPolicy interface {
+	SatisfiesACR(ctx context.Context, requester fosite.AuthorizeRequester, requested ACRRequest, achieved string) bool
+}
+
+// DefaultAuthenticationContextPolicy requires the achieved authentication context class reference to match one of
+// the requested values. If Levels is set, stronger authentication contexts satisfy requests for weaker ones.
+type DefaultAuthenticationContextPolicy struct {
+	// Levels lists the authentication context class references from weakest to strongest.
+	Levels []string
+
+	// EnforceVoluntary requires values requested with the acr_values parameter to be satisfied as well. By
+	// default, only essential acr claims must be satisfied.
+	EnforceVoluntary bool
+}
+
+func (p *DefaultAuthenticationContextPolicy) SatisfiesACR(ctx context.Context, requester fosite.AuthorizeRequester, requested ACRRequest, achieved string) bool {
+	if len(requested.Values) == 0 || (!requested.Essential && !p.EnforceVoluntary) {
+		return true
+	} else if achieved == "" {
+		return false
+	} else if fosite.Arguments(requested.Values).Has(achieved) {
+		return true
+	}
+
+	level := p.level(achieved)
+	if level < 0 {
+		return false
+	}
+	for _, value := range requested.Values {
+		if l := p.level(value); l >= 0 && l <= level {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *DefaultAuthenticationContextPolicy) level(acr string) int {
+	for k, level := range p.Levels {
+		if level == acr {
+			return k
+		}
+	}
+	return -1
+}
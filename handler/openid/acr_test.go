@@ -0,0 +1,108 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package openid
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/token/jwt"
+)
+
+func TestRequestedACR(t *testing.T) {
+	for k, c := range []struct {
+		form   url.Values
+		expect ACRRequest
+	}{
+		{form: url.Values{}, expect: ACRRequest{}},
+		{form: url.Values{"acr_values": {"silver  gold"}}, expect: ACRRequest{Values: []string{"silver", "gold"}}},
+		{
+			form:   url.Values{"acr_values": {"silver"}, "claims": {`{"id_token":{"acr":{"essential":true,"values":["gold","platinum"]}}}`}},
+			expect: ACRRequest{Values: []string{"gold", "platinum"}, Essential: true},
+		},
+		{
+			form:   url.Values{"claims": {`{"id_token":{"acr":{"value":"gold"}}}`}},
+			expect: ACRRequest{Values: []string{"gold"}},
+		},
+		{
+			form:   url.Values{"acr_values": {"silver"}, "claims": {`{"id_token":{"acr":null}}`}},
+			expect: ACRRequest{Values: []string{"silver"}},
+		},
+	} {
+		r := fosite.NewAuthorizeRequest()
+		r.Form = c.form
+		r.RequestedClaims, _ = fosite.ParseClaimsRequest(c.form.Get("claims"))
+		assert.Equal(t, c.expect, RequestedACR(r), "case %d", k)
+
+		ar := fosite.NewAccessRequest(nil)
+		ar.Form = c.form
+		assert.Equal(t, c.expect, RequestedACR(ar), "case %d", k)
+	}
+}
+
+func TestDefaultAuthenticationContextPolicy(t *testing.T) {
+	ctx := context.Background()
+	ar := fosite.NewAuthorizeRequest()
+	essential := func(values ...string) ACRRequest { return ACRRequest{Values: values, Essential: true} }
+
+	p := &DefaultAuthenticationContextPolicy{}
+	assert.True(t, p.SatisfiesACR(ctx, ar, ACRRequest{}, ""))
+	assert.True(t, p.SatisfiesACR(ctx, ar, ACRRequest{Values: []string{"gold"}}, "silver"), "voluntary values are not enforced")
+	assert.True(t, p.SatisfiesACR(ctx, ar, essential("silver", "gold"), "gold"))
+	assert.False(t, p.SatisfiesACR(ctx, ar, essential("gold"), "silver"))
+	assert.False(t, p.SatisfiesACR(ctx, ar, essential("gold"), ""))
+
+	p = &DefaultAuthenticationContextPolicy{Levels: []string{"bronze", "silver", "gold"}, EnforceVoluntary: true}
+	assert.False(t, p.SatisfiesACR(ctx, ar, ACRRequest{Values: []string{"gold"}}, "silver"))
+	assert.True(t, p.SatisfiesACR(ctx, ar, ACRRequest{Values: []string{"silver"}}, "gold"), "stronger contexts satisfy weaker ones")
+	assert.True(t, p.SatisfiesACR(ctx, ar, essential("gold", "bronze"), "silver"))
+	assert.False(t, p.SatisfiesACR(ctx, ar, essential("silver"), "unknown"))
+}
+
+func TestValidatePromptAuthenticationContext(t *testing.T) {
+	v := NewOpenIDConnectRequestValidator(nil, nil)
+
+	newRequest := func(claims string, acr string) *fosite.AuthorizeRequest {
+		ar := fosite.NewAuthorizeRequest()
+		ar.Client = &fosite.DefaultClient{ID: "foo"}
+		ar.Form = url.Values{"claims": {claims}}
+		ar.RequestedClaims, _ = fosite.ParseClaimsRequest(claims)
+		ar.Session = &DefaultSession{Claims: &jwt.IDTokenClaims{
+			Subject:                             "peter",
+			AuthTime:                            time.Now().UTC(),
+			RequestedAt:                         time.Now().UTC(),
+			AuthenticationContextClassReference: acr,
+		}}
+		return ar
+	}
+
+	assert.NoError(t, v.ValidatePrompt(context.Background(), newRequest(`{"id_token":{"acr":{"essential":true,"values":["gold"]}}}`, "gold")))
+	assert.ErrorIs(t, v.ValidatePrompt(context.Background(), newRequest(`{"id_token":{"acr":{"essential":true,"values":["gold"]}}}`, "silver")), fosite.ErrUnmetAuthenticationRequirements)
+
+	v.WithAuthenticationContextPolicy(&DefaultAuthenticationContextPolicy{Levels: []string{"silver", "gold"}})
+	assert.NoError(t, v.ValidatePrompt(context.Background(), newRequest(`{"id_token":{"acr":{"essential":true,"values":["silver"]}}}`, "gold")))
+}
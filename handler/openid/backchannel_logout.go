@@ -0,0 +1,219 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package openid
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ory/x/errorsx"
+
+	"github.com/pborman/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/token/jwt"
+)
+
+// BackChannelLogoutEvent is the member of the "events" claim identifying logout tokens.
+const BackChannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+
+// LogoutTokenType is the "typ" header of logout tokens, see
+// https://openid.net/specs/openid-connect-backchannel-1_0.html#LogoutToken
+const LogoutTokenType = "logout+jwt"
+
+const defaultLogoutTokenLifespan = time.Minute * 2
+
+// LogoutTokenDeliverer sends logout tokens to the back-channel logout URI of clients.
+type LogoutTokenDeliverer interface {
+	// DeliverLogoutToken sends the logout token to the client's back-channel logout URI.
+	DeliverLogoutToken(ctx context.Context, client fosite.Client, logoutURI string, logoutToken string) error
+}
+
+// HTTPLogoutTokenDeliverer POSTs logout tokens to the back-channel logout URI as defined in
+// https://openid.net/specs/openid-connect-backchannel-1_0.html#BCRequest
+type HTTPLogoutTokenDeliverer struct {
+	// Client is the HTTP client used to send logout tokens. Defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+func (d *HTTPLogoutTokenDeliverer) DeliverLogoutToken(ctx context.Context, _ fosite.Client, logoutURI string, logoutToken string) error {
+	client := d.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequest("POST", logoutURI, strings.NewReader(url.Values{"logout_token": {logoutToken}}.Encode()))
+	if err != nil {
+		return errorsx.WithStack(err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return errorsx.WithStack(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNoContent {
+		return errorsx.WithStack(fmt.Errorf("back-channel logout URI '%s' responded with status code %d", logoutURI, res.StatusCode))
+	}
+	return nil
+}
+
+// BackChannelLogoutHandler mints logout tokens for the clients taking part in an end-user's session and delivers
+// them as defined in https://openid.net/specs/openid-connect-backchannel-1_0.html
+//
+// The clients of a session are recorded by the IDTokenHandleHelper if its LogoutStorage is set and the ID token
+// claims of the session contain a "sid".
+type BackChannelLogoutHandler struct {
+	// JWTStrategy signs the logout tokens, typically using the keys ID tokens are signed with.
+	JWTStrategy jwt.JWTStrategy
+
+	// Issuer is the "iss" claim of the logout tokens.
+	Issuer string
+
+	// IssuerProvider, if set, takes precedence over Issuer.
+	IssuerProvider fosite.IssuerProvider
+
+	// Lifespan is the lifespan of the logout tokens. Defaults to two minutes.
+	Lifespan time.Duration
+
+	LogoutStorage fosite.LogoutStorage
+	ClientManager fosite.ClientManager
+
+	// Deliverer sends the logout tokens. Defaults to a HTTPLogoutTokenDeliverer using http.DefaultClient.
+	Deliverer LogoutTokenDeliverer
+
+	// SubjectIdentifierAlgorithms computes the "sub" claim of logout tokens for the client they are sent to, which
+	// must match the "sub" claim of the ID tokens the client received.
+	SubjectIdentifierAlgorithms fosite.SubjectIdentifierAlgorithms
+}
+
+// GenerateLogoutToken returns a logout token for the client. At least one of subject and sessionID must be set.
+func (h *BackChannelLogoutHandler) GenerateLogoutToken(ctx context.Context, client fosite.Client, subject, sessionID string) (string, error) {
+	if subject == "" && sessionID == "" {
+		return "", errorsx.WithStack(fosite.ErrServerError.WithDebug("A logout token requires either a subject or a session ID."))
+	}
+
+	lifespan := h.Lifespan
+	if lifespan == 0 {
+		lifespan = defaultLogoutTokenLifespan
+	}
+
+	issuer, err := fosite.GetIssuer(ctx, h.IssuerProvider, h.Issuer)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+	claims := jwt.MapClaims{
+		"iss":    issuer,
+		"aud":    []string{client.GetID()},
+		"iat":    now.Unix(),
+		"exp":    now.Add(lifespan).Unix(),
+		"jti":    uuid.New(),
+		"events": map[string]interface{}{BackChannelLogoutEvent: map[string]interface{}{}},
+	}
+	if subject != "" {
+		if claims["sub"], err = h.SubjectIdentifierAlgorithms.Obfuscate(ctx, subject, client); err != nil {
+			return "", err
+		}
+	}
+	if sessionID != "" {
+		claims["sid"] = sessionID
+	}
+
+	token, _, err := h.JWTStrategy.Generate(ctx, claims, &jwt.Headers{Extra: map[string]interface{}{"typ": LogoutTokenType}})
+	if err != nil {
+		return "", errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+	return token, nil
+}
+
+// LogoutSession sends logout tokens to all clients of the session which registered a back-channel logout URI and
+// removes the session from the LogoutStorage. A session which does not exist is ignored. Delivery is attempted for
+// every client, a server error describing all failed deliveries is returned afterwards.
+func (h *BackChannelLogoutHandler) LogoutSession(ctx context.Context, sessionID string) error {
+	var session *fosite.LogoutSession
+	err := fosite.StorageOperation(ctx, "GetLogoutSession", func(ctx context.Context) (err error) {
+		session, err = h.LogoutStorage.GetLogoutSession(ctx, sessionID)
+		return err
+	})
+	if errors.Is(err, fosite.ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	var failed []string
+	for _, clientID := range session.ClientIDs {
+		if err := h.logoutClient(ctx, clientID, session); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", clientID, err))
+		}
+	}
+
+	if err := fosite.StorageOperation(ctx, "DeleteLogoutSession", func(ctx context.Context) error {
+		return h.LogoutStorage.DeleteLogoutSession(ctx, sessionID)
+	}); err != nil {
+		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	if len(failed) > 0 {
+		return errorsx.WithStack(fosite.ErrServerError.WithHint("Unable to deliver the logout token to all clients.").WithDebug(strings.Join(failed, "; ")))
+	}
+	return nil
+}
+
+func (h *BackChannelLogoutHandler) logoutClient(ctx context.Context, clientID string, session *fosite.LogoutSession) error {
+	client, err := h.ClientManager.GetClient(ctx, clientID)
+	if errors.Is(err, fosite.ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	c, ok := client.(fosite.BackChannelLogoutClient)
+	if !ok || c.GetBackChannelLogoutURI() == "" {
+		return nil
+	}
+
+	token, err := h.GenerateLogoutToken(ctx, client, session.Subject, session.ID)
+	if err != nil {
+		return err
+	}
+	return h.deliverer().DeliverLogoutToken(ctx, client, c.GetBackChannelLogoutURI(), token)
+}
+
+var defaultLogoutTokenDeliverer = &HTTPLogoutTokenDeliverer{}
+
+func (h *BackChannelLogoutHandler) deliverer() LogoutTokenDeliverer {
+	if h.Deliverer == nil {
+		return defaultLogoutTokenDeliverer
+	}
+	return h.Deliverer
+}
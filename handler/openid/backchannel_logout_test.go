@@ -0,0 +1,119 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package openid
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/internal"
+	"github.com/ory/fosite/storage"
+	"github.com/ory/fosite/token/jwt"
+)
+
+type recordingLogoutTokenDeliverer struct {
+	tokens map[string]string
+}
+
+func (d *recordingLogoutTokenDeliverer) DeliverLogoutToken(_ context.Context, client fosite.Client, _ string, logoutToken string) error {
+	d.tokens[client.GetID()] = logoutToken
+	return nil
+}
+
+func TestBackChannelLogoutHandler(t *testing.T) {
+	ctx := context.Background()
+	strategy := &jwt.RS256JWTStrategy{PrivateKey: internal.MustRSAKey()}
+	store := storage.NewMemoryStore()
+	store.Clients["rp-1"] = &fosite.DefaultOpenIDConnectClient{
+		DefaultClient:        &fosite.DefaultClient{ID: "rp-1"},
+		BackChannelLogoutURI: "https://rp-1.example.com/logout",
+	}
+	store.Clients["rp-2"] = &fosite.DefaultOpenIDConnectClient{
+		DefaultClient:        &fosite.DefaultClient{ID: "rp-2"},
+		BackChannelLogoutURI: "https://rp-2.example.com/logout",
+	}
+	store.Clients["rp-3"] = &fosite.DefaultClient{ID: "rp-3"}
+
+	helper := &IDTokenHandleHelper{IDTokenStrategy: &DefaultStrategy{JWTStrategy: strategy}, LogoutStorage: store}
+	for _, id := range []string{"rp-1", "rp-2", "rp-3"} {
+		session := NewDefaultSession()
+		session.Claims.Subject = "peter"
+		session.Claims.Extra = map[string]interface{}{"sid": "session-id"}
+		ar := fosite.NewAuthorizeRequest()
+		ar.Client = store.Clients[id]
+		ar.Session = session
+		require.NoError(t, helper.IssueImplicitIDToken(ctx, ar, fosite.NewAuthorizeResponse()))
+	}
+
+	ls, err := store.GetLogoutSession(ctx, "session-id")
+	require.NoError(t, err)
+	assert.Equal(t, "peter", ls.Subject)
+	assert.Equal(t, []string{"rp-1", "rp-2", "rp-3"}, ls.ClientIDs)
+
+	deliverer := &recordingLogoutTokenDeliverer{tokens: map[string]string{}}
+	h := &BackChannelLogoutHandler{
+		JWTStrategy:   strategy,
+		Issuer:        "https://op.example.com",
+		LogoutStorage: store,
+		ClientManager: store,
+		Deliverer:     deliverer,
+	}
+	require.NoError(t, h.LogoutSession(ctx, "session-id"))
+	require.Len(t, deliverer.tokens, 2)
+
+	token, err := strategy.Decode(ctx, deliverer.tokens["rp-1"])
+	require.NoError(t, err)
+	assert.Equal(t, LogoutTokenType, token.Header["typ"])
+	assert.Equal(t, "https://op.example.com", token.Claims["iss"])
+	assert.Equal(t, "peter", token.Claims["sub"])
+	assert.Equal(t, "session-id", token.Claims["sid"])
+	assert.Equal(t, []interface{}{"rp-1"}, token.Claims["aud"])
+	assert.NotEmpty(t, token.Claims["jti"])
+	assert.Nil(t, token.Claims["nonce"])
+	assert.Contains(t, token.Claims["events"], BackChannelLogoutEvent)
+
+	_, err = store.GetLogoutSession(ctx, "session-id")
+	assert.ErrorIs(t, err, fosite.ErrNotFound)
+	require.NoError(t, h.LogoutSession(ctx, "session-id"))
+}
+
+func TestHTTPLogoutTokenDeliverer(t *testing.T) {
+	var received string
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		received = r.PostFormValue("logout_token")
+		if received == "fail" {
+			rw.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer ts.Close()
+
+	d := &HTTPLogoutTokenDeliverer{}
+	require.NoError(t, d.DeliverLogoutToken(context.Background(), nil, ts.URL, "some-token"))
+	assert.Equal(t, "some-token", received)
+	assert.Error(t, d.DeliverLogoutToken(context.Background(), nil, ts.URL, "fail"))
+}
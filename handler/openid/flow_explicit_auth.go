@@ -43,6 +43,7 @@ var oidcParameters = []string{"grant_type",
 	"acr_values",
 	"id_token_hint",
 	"nonce",
+	"claims",
 }
 
 func (c *OpenIDConnectExplicitHandler) HandleAuthorizeEndpointRequest(ctx context.Context, ar fosite.AuthorizeRequester, resp fosite.AuthorizeResponder) error {
@@ -62,7 +63,13 @@ func (c *OpenIDConnectExplicitHandler) HandleAuthorizeEndpointRequest(ctx contex
 		return err
 	}
 
-	if err := c.OpenIDConnectRequestStorage.CreateOpenIDConnectSession(ctx, resp.GetCode(), ar.Sanitize(oidcParameters)); err != nil {
+	if err := c.OpenIDConnectRequestValidator.ValidateNonce(ctx, ar); err != nil {
+		return err
+	}
+
+	if err := fosite.StorageOperation(ctx, "CreateOpenIDConnectSession", func(ctx context.Context) error {
+		return c.OpenIDConnectRequestStorage.CreateOpenIDConnectSession(ctx, resp.GetCode(), ar.Sanitize(oidcParameters))
+	}); err != nil {
 		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
 	}
 
@@ -70,3 +77,14 @@ func (c *OpenIDConnectExplicitHandler) HandleAuthorizeEndpointRequest(ctx contex
 
 	return nil
 }
+
+func (c *OpenIDConnectExplicitHandler) PopulateDiscoveryMetadata(ctx context.Context, metadata *fosite.DiscoveryMetadata) {
+	metadata.ScopesSupported = append(metadata.ScopesSupported, "openid")
+	// Request objects are always processed for OpenID Connect requests.
+	metadata.RequestParameterSupported = true
+	metadata.RequestURIParameterSupported = true
+	if c.OpenIDConnectRequestValidator != nil {
+		// https://openid.net/specs/openid-connect-prompt-create-1_0.html#section-4
+		metadata.PromptValuesSupported = append(metadata.PromptValuesSupported, c.OpenIDConnectRequestValidator.AllowedPrompt...)
+	}
+}
@@ -23,7 +23,6 @@ package openid
 
 import (
 	"context"
-	"encoding/base64"
 	"time"
 
 	"github.com/ory/x/errorsx"
@@ -41,6 +40,8 @@ type OpenIDConnectHybridHandler struct {
 	OpenIDConnectRequestValidator     *OpenIDConnectRequestValidator
 	OpenIDConnectRequestStorage       OpenIDConnectRequestStorage
 
+	// Deprecated: c_hash and at_hash are computed by the IDTokenHandleHelper using the hash function of the ID token
+	// signing algorithm.
 	Enigma *jwt.RS256JWTStrategy
 
 	MinParameterEntropy int
@@ -90,11 +91,15 @@ func (c *OpenIDConnectHybridHandler) HandleAuthorizeEndpointRequest(ctx context.
 
 	client := ar.GetClient()
 	for _, scope := range ar.GetRequestedScopes() {
-		if !c.ScopeStrategy(client.GetScopes(), scope) {
+		if !fosite.ClientScopeStrategy(client, c.ScopeStrategy)(client.GetScopes(), scope) {
 			return errorsx.WithStack(fosite.ErrInvalidScope.WithHintf("The OAuth 2.0 Client is not allowed to request scope '%s'.", scope))
 		}
 	}
 
+	if err := c.OpenIDConnectRequestValidator.ValidateNonce(ctx, ar); err != nil {
+		return err
+	}
+
 	claims := sess.IDTokenClaims()
 	if ar.GetResponseTypes().Has("code") {
 		if !ar.GetClient().GetGrantTypes().Has("authorization_code") {
@@ -114,22 +119,26 @@ func (c *OpenIDConnectHybridHandler) HandleAuthorizeEndpointRequest(ctx context.
 		// }
 
 		// This is required because we must limit the authorize code lifespan.
-		ar.GetSession().SetExpiresAt(fosite.AuthorizeCode, time.Now().UTC().Add(c.AuthorizeExplicitGrantHandler.AuthCodeLifespan).Round(time.Second))
-		if err := c.AuthorizeExplicitGrantHandler.CoreStorage.CreateAuthorizeCodeSession(ctx, signature, ar.Sanitize(c.AuthorizeExplicitGrantHandler.GetSanitationWhiteList())); err != nil {
+		ar.GetSession().SetExpiresAt(fosite.AuthorizeCode, time.Now().UTC().Add(fosite.GetAuthorizeCodeLifespan(ctx, c.AuthorizeExplicitGrantHandler.AuthCodeLifespan)).Round(time.Second))
+		if err := fosite.StorageOperation(ctx, "CreateAuthorizeCodeSession", func(ctx context.Context) error {
+			return c.AuthorizeExplicitGrantHandler.CoreStorage.CreateAuthorizeCodeSession(ctx, signature, ar.Sanitize(c.AuthorizeExplicitGrantHandler.GetSanitationWhiteList()))
+		}); err != nil {
 			return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
 		}
 
 		resp.AddParameter("code", code)
 		ar.SetResponseTypeHandled("code")
 
-		hash, err := c.Enigma.Hash(ctx, []byte(resp.GetParameters().Get("code")))
+		hash, err := c.IDTokenHandleHelper.GetTokenHash(ctx, ar, resp.GetParameters().Get("code"))
 		if err != nil {
 			return err
 		}
-		claims.CodeHash = base64.RawURLEncoding.EncodeToString([]byte(hash[:c.Enigma.GetSigningMethodLength()/2]))
+		claims.CodeHash = hash
 
 		if ar.GetGrantedScopes().Has("openid") {
-			if err := c.OpenIDConnectRequestStorage.CreateOpenIDConnectSession(ctx, resp.GetCode(), ar.Sanitize(oidcParameters)); err != nil {
+			if err := fosite.StorageOperation(ctx, "CreateOpenIDConnectSession", func(ctx context.Context) error {
+				return c.OpenIDConnectRequestStorage.CreateOpenIDConnectSession(ctx, resp.GetCode(), ar.Sanitize(oidcParameters))
+			}); err != nil {
 				return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
 			}
 		}
@@ -143,11 +152,11 @@ func (c *OpenIDConnectHybridHandler) HandleAuthorizeEndpointRequest(ctx context.
 		}
 		ar.SetResponseTypeHandled("token")
 
-		hash, err := c.Enigma.Hash(ctx, []byte(resp.GetParameters().Get("access_token")))
+		hash, err := c.IDTokenHandleHelper.GetTokenHash(ctx, ar, resp.GetParameters().Get("access_token"))
 		if err != nil {
 			return err
 		}
-		claims.AccessTokenHash = base64.RawURLEncoding.EncodeToString([]byte(hash[:c.Enigma.GetSigningMethodLength()/2]))
+		claims.AccessTokenHash = hash
 	}
 
 	if resp.GetParameters().Get("state") == "" {
@@ -168,3 +177,13 @@ func (c *OpenIDConnectHybridHandler) HandleAuthorizeEndpointRequest(ctx context.
 	// there is no need to check for https, because implicit flow does not require https
 	// https://tools.ietf.org/html/rfc6819#section-4.4.2
 }
+
+func (c *OpenIDConnectHybridHandler) PopulateDiscoveryMetadata(ctx context.Context, metadata *fosite.DiscoveryMetadata) {
+	metadata.ScopesSupported = append(metadata.ScopesSupported, "openid")
+	metadata.ResponseTypesSupported = append(metadata.ResponseTypesSupported, "code id_token", "code token", "code id_token token")
+	metadata.GrantTypesSupported = append(metadata.GrantTypesSupported, "authorization_code", "implicit")
+	if c.OpenIDConnectRequestValidator != nil {
+		// https://openid.net/specs/openid-connect-prompt-create-1_0.html#section-4
+		metadata.PromptValuesSupported = append(metadata.PromptValuesSupported, c.OpenIDConnectRequestValidator.AllowedPrompt...)
+	}
+}
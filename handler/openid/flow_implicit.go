@@ -23,7 +23,6 @@ package openid
 
 import (
 	"context"
-	"encoding/base64"
 
 	"github.com/ory/x/errorsx"
 
@@ -38,6 +37,8 @@ type OpenIDConnectImplicitHandler struct {
 	ScopeStrategy                 fosite.ScopeStrategy
 	OpenIDConnectRequestValidator *OpenIDConnectRequestValidator
 
+	// Deprecated: at_hash is computed by the IDTokenHandleHelper using the hash function of the ID token signing
+	// algorithm.
 	RS256JWTStrategy *jwt.RS256JWTStrategy
 
 	MinParameterEntropy int
@@ -72,7 +73,7 @@ func (c *OpenIDConnectImplicitHandler) HandleAuthorizeEndpointRequest(ctx contex
 
 	client := ar.GetClient()
 	for _, scope := range ar.GetRequestedScopes() {
-		if !c.ScopeStrategy(client.GetScopes(), scope) {
+		if !fosite.ClientScopeStrategy(client, c.ScopeStrategy)(client.GetScopes(), scope) {
 			return errorsx.WithStack(fosite.ErrInvalidScope.WithHintf("The OAuth 2.0 Client is not allowed to request scope '%s'.", scope))
 		}
 	}
@@ -86,6 +87,10 @@ func (c *OpenIDConnectImplicitHandler) HandleAuthorizeEndpointRequest(ctx contex
 		return err
 	}
 
+	if err := c.OpenIDConnectRequestValidator.ValidateNonce(ctx, ar); err != nil {
+		return err
+	}
+
 	claims := sess.IDTokenClaims()
 	if ar.GetResponseTypes().Has("token") {
 		if err := c.AuthorizeImplicitGrantTypeHandler.IssueImplicitAccessToken(ctx, ar, resp); err != nil {
@@ -93,12 +98,12 @@ func (c *OpenIDConnectImplicitHandler) HandleAuthorizeEndpointRequest(ctx contex
 		}
 
 		ar.SetResponseTypeHandled("token")
-		hash, err := c.RS256JWTStrategy.Hash(ctx, []byte(resp.GetParameters().Get("access_token")))
+		hash, err := c.GetTokenHash(ctx, ar, resp.GetParameters().Get("access_token"))
 		if err != nil {
 			return err
 		}
 
-		claims.AccessTokenHash = base64.RawURLEncoding.EncodeToString([]byte(hash[:c.RS256JWTStrategy.GetSigningMethodLength()/2]))
+		claims.AccessTokenHash = hash
 	} else {
 		resp.AddParameter("state", ar.GetState())
 	}
@@ -113,3 +118,13 @@ func (c *OpenIDConnectImplicitHandler) HandleAuthorizeEndpointRequest(ctx contex
 	ar.SetResponseTypeHandled("id_token")
 	return nil
 }
+
+func (c *OpenIDConnectImplicitHandler) PopulateDiscoveryMetadata(ctx context.Context, metadata *fosite.DiscoveryMetadata) {
+	metadata.ScopesSupported = append(metadata.ScopesSupported, "openid")
+	metadata.ResponseTypesSupported = append(metadata.ResponseTypesSupported, "id_token", "id_token token")
+	metadata.GrantTypesSupported = append(metadata.GrantTypesSupported, "implicit")
+	if c.OpenIDConnectRequestValidator != nil {
+		// https://openid.net/specs/openid-connect-prompt-create-1_0.html#section-4
+		metadata.PromptValuesSupported = append(metadata.PromptValuesSupported, c.OpenIDConnectRequestValidator.AllowedPrompt...)
+	}
+}
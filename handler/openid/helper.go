@@ -22,31 +22,55 @@
 package openid
 
 import (
-	"bytes"
 	"context"
 	"crypto/sha256"
 	"encoding/base64"
 
+	"github.com/ory/x/errorsx"
+
 	"github.com/ory/fosite"
 )
 
 type IDTokenHandleHelper struct {
 	IDTokenStrategy OpenIDConnectTokenStrategy
+
+	// LogoutStorage, if set, records the clients ID tokens with a "sid" claim are issued to so that they can be
+	// notified by the BackChannelLogoutHandler.
+	LogoutStorage fosite.LogoutStorage
+}
+
+// IDTokenHasher is implemented by ID token strategies which know the algorithm the ID token of a request is signed
+// with. The at_hash and c_hash claims must be computed with the hash function of that algorithm, see
+// https://openid.net/specs/openid-connect-core-1_0.html#HybridIDToken
+type IDTokenHasher interface {
+	// HashForIDToken returns the base64url encoded left-most half of the hash of value, using the hash function
+	// of the algorithm the ID token of the requester is signed with.
+	HashForIDToken(ctx context.Context, requester fosite.Requester, value string) (string, error)
 }
 
+// GetTokenHash returns the at_hash or c_hash claim for the access token or authorization code. The hash function
+// is taken from the IDTokenStrategy if it implements IDTokenHasher and is SHA-256 otherwise.
+func (i *IDTokenHandleHelper) GetTokenHash(ctx context.Context, requester fosite.Requester, token string) (string, error) {
+	if hasher, ok := i.IDTokenStrategy.(IDTokenHasher); ok {
+		return hasher.HashForIDToken(ctx, requester, token)
+	}
+
+	hash := sha256.Sum256([]byte(token))
+	return base64.RawURLEncoding.EncodeToString(hash[:len(hash)/2]), nil
+}
+
+// GetAccessTokenHash returns the at_hash claim for the access token of the responder. It falls back to SHA-256 if
+// the hash can not be computed with the hash function of the ID token signing algorithm, issuing the ID token
+// reports that error.
 func (i *IDTokenHandleHelper) GetAccessTokenHash(ctx context.Context, requester fosite.AccessRequester, responder fosite.AccessResponder) string {
 	token := responder.GetAccessToken()
 
-	buffer := bytes.NewBufferString(token)
-	hash := sha256.New()
-	// sha256.digest.Write() always returns nil for err, the panic should never happen
-	_, err := hash.Write(buffer.Bytes())
+	hash, err := i.GetTokenHash(ctx, requester, token)
 	if err != nil {
-		panic(err)
+		sum := sha256.Sum256([]byte(token))
+		return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2])
 	}
-	hashBuf := bytes.NewBuffer(hash.Sum([]byte{}))
-
-	return base64.RawURLEncoding.EncodeToString(hashBuf.Bytes()[:hashBuf.Len()/2])
+	return hash
 }
 
 func (i *IDTokenHandleHelper) generateIDToken(ctx context.Context, fosr fosite.Requester) (token string, err error) {
@@ -55,6 +79,10 @@ func (i *IDTokenHandleHelper) generateIDToken(ctx context.Context, fosr fosite.R
 		return "", err
 	}
 
+	if err := i.addLogoutSessionClient(ctx, fosr); err != nil {
+		return "", err
+	}
+
 	return token, nil
 }
 
@@ -76,3 +104,27 @@ func (i *IDTokenHandleHelper) IssueExplicitIDToken(ctx context.Context, ar fosit
 	resp.SetExtra("id_token", token)
 	return nil
 }
+
+func (i *IDTokenHandleHelper) addLogoutSessionClient(ctx context.Context, requester fosite.Requester) error {
+	if i.LogoutStorage == nil {
+		return nil
+	}
+
+	session, ok := requester.GetSession().(Session)
+	if !ok {
+		return nil
+	}
+
+	claims := session.IDTokenClaims()
+	sid, _ := claims.Extra["sid"].(string)
+	if sid == "" {
+		return nil
+	}
+
+	if err := fosite.StorageOperation(ctx, "AddLogoutSessionClient", func(ctx context.Context) error {
+		return i.LogoutStorage.AddLogoutSessionClient(ctx, sid, claims.Subject, requester.GetClient().GetID())
+	}); err != nil {
+		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+	return nil
+}
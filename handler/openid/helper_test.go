@@ -22,6 +22,8 @@
 package openid
 
 import (
+	"crypto/sha512"
+	"encoding/base64"
 	"net/url"
 	"testing"
 
@@ -131,9 +133,55 @@ func TestGetAccessTokenHash(t *testing.T) {
 	defer ctrl.Finish()
 
 	resp.EXPECT().GetAccessToken().Return("7a35f818-9164-48cb-8c8f-e1217f44228431c41102-d410-4ed5-9276-07ba53dfdcd8")
+	req.EXPECT().GetClient().Return(&fosite.DefaultClient{})
 
 	h := &IDTokenHandleHelper{IDTokenStrategy: strat}
 
 	hash := h.GetAccessTokenHash(nil, req, resp)
 	assert.Equal(t, "Zfn_XBitThuDJiETU3OALQ", hash)
 }
+
+func TestGetTokenHash(t *testing.T) {
+	const token = "7a35f818-9164-48cb-8c8f-e1217f44228431c41102-d410-4ed5-9276-07ba53dfdcd8"
+	sha384Sum := sha512.Sum384([]byte(token))
+	sha512Sum := sha512.Sum512([]byte(token))
+
+	h := &IDTokenHandleHelper{IDTokenStrategy: &DefaultStrategy{
+		JWTStrategy: strat.JWTStrategy,
+		Signers: map[string]jwt.JWTStrategy{
+			"ES384": &jwt.ES384JWTStrategy{PrivateKey: internal.MustECDSAKey()},
+			"ES512": &jwt.ES512JWTStrategy{PrivateKey: internal.MustECDSAKey()},
+		},
+	}}
+
+	for _, c := range []struct {
+		d      string
+		client fosite.Client
+		expect string
+		err    bool
+	}{
+		{d: "should use SHA-256 for the default signer", client: &fosite.DefaultClient{}, expect: "Zfn_XBitThuDJiETU3OALQ"},
+		{d: "should use SHA-384 for ES384", client: &fosite.DefaultOpenIDConnectClient{IDTokenSignedResponseAlg: "ES384"}, expect: base64.RawURLEncoding.EncodeToString(sha384Sum[:24])},
+		{d: "should use SHA-512 for ES512", client: &fosite.DefaultOpenIDConnectClient{IDTokenSignedResponseAlg: "ES512"}, expect: base64.RawURLEncoding.EncodeToString(sha512Sum[:32])},
+		{d: "should fail for unknown algorithms", client: &fosite.DefaultOpenIDConnectClient{IDTokenSignedResponseAlg: "PS256"}, err: true},
+	} {
+		t.Run("case="+c.d, func(t *testing.T) {
+			hash, err := h.GetTokenHash(nil, &fosite.Request{Client: c.client}, token)
+			if c.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, c.expect, hash)
+		})
+	}
+
+	t.Run("case=should use SHA-256 if the strategy does not implement IDTokenHasher", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		hash, err := (&IDTokenHandleHelper{IDTokenStrategy: internal.NewMockOpenIDConnectTokenStrategy(ctrl)}).GetTokenHash(nil, &fosite.Request{}, token)
+		assert.NoError(t, err)
+		assert.Equal(t, "Zfn_XBitThuDJiETU3OALQ", hash)
+	})
+}
@@ -23,6 +23,7 @@ package openid
 
 import (
 	"context"
+	"time"
 
 	"github.com/ory/fosite"
 )
@@ -44,3 +45,13 @@ type OpenIDConnectRequestStorage interface {
 	// Originally, it should remove an open id connect session from the store.
 	DeleteOpenIDConnectSession(ctx context.Context, authorizeCode string) error
 }
+
+// NonceStorage tracks the nonces of OpenID Connect authorize requests so that they can not be replayed.
+type NonceStorage interface {
+	// IsNonceUsed returns true if the nonce was used by the client before and its validity window has not
+	// ended yet.
+	IsNonceUsed(ctx context.Context, clientID string, nonce string) (bool, error)
+
+	// MarkNonceUsedForTime marks the nonce as used by the client until exp.
+	MarkNonceUsedForTime(ctx context.Context, clientID string, nonce string, exp time.Time) error
+}
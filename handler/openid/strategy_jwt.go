@@ -23,6 +23,7 @@ package openid
 
 import (
 	"context"
+	"encoding/base64"
 	"strconv"
 	"time"
 
@@ -126,13 +127,49 @@ func (s *DefaultSession) IDTokenClaims() *jwt.IDTokenClaims {
 	return s.Claims
 }
 
+func (s *DefaultSession) GetAuthenticationContextClassReference() string {
+	if s == nil || s.Claims == nil {
+		return ""
+	}
+	return s.Claims.AuthenticationContextClassReference
+}
+
+func (s *DefaultSession) GetAuthTime() time.Time {
+	if s == nil || s.Claims == nil {
+		return time.Time{}
+	}
+	return s.Claims.AuthTime
+}
+
 type DefaultStrategy struct {
 	jwt.JWTStrategy
 
 	Expiry time.Duration
 	Issuer string
 
+	// IssuerProvider, if set, takes precedence over Issuer.
+	IssuerProvider fosite.IssuerProvider
+
 	MinParameterEntropy int
+
+	// Signers maps JWS algorithms to the strategies signing with them. Clients which registered an
+	// id_token_signed_response_alg receive ID tokens signed by the matching strategy. If nil, all ID tokens are
+	// signed using JWTStrategy.
+	Signers map[string]jwt.JWTStrategy
+
+	// Encrypter encrypts ID tokens for clients which registered an id_token_encrypted_response_alg. Defaults to
+	// jwt.DefaultEncrypter.
+	Encrypter jwt.Encrypter
+
+	// JWKSFetcherStrategy resolves the encryption keys of clients which registered a jwks_uri.
+	JWKSFetcherStrategy fosite.JWKSFetcherStrategy
+
+	// ClaimsMapper, if set, modifies the ID token claims before they are signed.
+	ClaimsMapper fosite.ClaimsMapper
+
+	// SubjectIdentifierAlgorithms computes the "sub" claim of ID tokens for the client they are issued to. The
+	// session's claims are not modified. If nil, the subject of the session is used.
+	SubjectIdentifierAlgorithms fosite.SubjectIdentifierAlgorithms
 }
 
 func (h DefaultStrategy) GenerateIDToken(ctx context.Context, requester fosite.Requester) (token string, err error) {
@@ -145,11 +182,21 @@ func (h DefaultStrategy) GenerateIDToken(ctx context.Context, requester fosite.R
 		return "", errorsx.WithStack(fosite.ErrServerError.WithDebug("Failed to generate id token because session must be of type fosite/handler/openid.Session."))
 	}
 
+	signer, err := h.signer(requester.GetClient())
+	if err != nil {
+		return "", err
+	}
+
 	claims := sess.IDTokenClaims()
 	if claims.Subject == "" {
 		return "", errorsx.WithStack(fosite.ErrServerError.WithDebug("Failed to generate id token because subject is an empty string."))
 	}
 
+	subject, err := h.SubjectIdentifierAlgorithms.Obfuscate(ctx, claims.Subject, requester.GetClient())
+	if err != nil {
+		return "", err
+	}
+
 	if requester.GetRequestForm().Get("grant_type") != "refresh_token" {
 		maxAge, err := strconv.ParseInt(requester.GetRequestForm().Get("max_age"), 10, 64)
 		if err != nil {
@@ -191,14 +238,14 @@ func (h DefaultStrategy) GenerateIDToken(ctx context.Context, requester fosite.R
 			}
 		}
 
-		// If acr_values was requested but no acr value was provided in the ID token, fall back to level 0 which means least
-		// confidence in authentication.
-		if requester.GetRequestForm().Get("acr_values") != "" && claims.AuthenticationContextClassReference == "" {
+		// If acr_values or the acr claim was requested but no acr value was provided in the ID token, fall back to level 0
+		// which means least confidence in authentication.
+		if len(RequestedACR(requester).Values) > 0 && claims.AuthenticationContextClassReference == "" {
 			claims.AuthenticationContextClassReference = "0"
 		}
 
 		if tokenHintString := requester.GetRequestForm().Get("id_token_hint"); tokenHintString != "" {
-			tokenHint, err := h.JWTStrategy.Decode(ctx, tokenHintString)
+			tokenHint, err := signer.Decode(ctx, tokenHintString)
 			var ve *jwt.ValidationError
 			if errors.As(err, &ve) && ve.Has(jwt.ValidationErrorExpired) {
 				// Expired ID Tokens are allowed as values to id_token_hint
@@ -208,14 +255,14 @@ func (h DefaultStrategy) GenerateIDToken(ctx context.Context, requester fosite.R
 
 			if hintSub, _ := tokenHint.Claims["sub"].(string); hintSub == "" {
 				return "", errorsx.WithStack(fosite.ErrServerError.WithDebug("Provided id token from 'id_token_hint' does not have a subject."))
-			} else if hintSub != claims.Subject {
+			} else if hintSub != subject {
 				return "", errorsx.WithStack(fosite.ErrServerError.WithDebug("Subject from authorization mismatches id token subject from 'id_token_hint'."))
 			}
 		}
 	}
 
 	if claims.ExpiresAt.IsZero() {
-		claims.ExpiresAt = time.Now().UTC().Add(h.Expiry)
+		claims.ExpiresAt = time.Now().UTC().Add(fosite.GetIDTokenLifespan(ctx, h.Expiry))
 	}
 
 	if claims.ExpiresAt.Before(time.Now().UTC()) {
@@ -227,7 +274,9 @@ func (h DefaultStrategy) GenerateIDToken(ctx context.Context, requester fosite.R
 	}
 
 	if claims.Issuer == "" {
-		claims.Issuer = h.Issuer
+		if claims.Issuer, err = fosite.GetIssuer(ctx, h.IssuerProvider, h.Issuer); err != nil {
+			return "", err
+		}
 	}
 
 	// OPTIONAL. String value used to associate a Client session with an ID Token, and to mitigate replay attacks.
@@ -239,9 +288,99 @@ func (h DefaultStrategy) GenerateIDToken(ctx context.Context, requester fosite.R
 		claims.Nonce = nonce
 	}
 
+	if err := claims.ValidateClaimSources(); err != nil {
+		return "", errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
 	claims.Audience = stringslice.Unique(append(claims.Audience, requester.GetClient().GetID()))
 	claims.IssuedAt = time.Now().UTC()
 
-	token, _, err = h.JWTStrategy.Generate(ctx, claims.ToMapClaims(), sess.IDTokenHeaders())
-	return token, err
+	mapClaims := claims.ToMapClaims()
+	mapClaims["sub"] = subject
+	if h.ClaimsMapper != nil {
+		if err := h.ClaimsMapper.MapClaims(ctx, fosite.IDToken, requester, mapClaims); err != nil {
+			return "", err
+		}
+	}
+
+	token, _, err = signer.Generate(ctx, mapClaims, sess.IDTokenHeaders())
+	if err != nil {
+		return "", err
+	}
+
+	return h.encrypt(ctx, requester.GetClient(), token)
+}
+
+// HashForIDToken implements IDTokenHasher using the hash function of the strategy which signs the ID tokens issued
+// to the client of the requester.
+func (h DefaultStrategy) HashForIDToken(ctx context.Context, requester fosite.Requester, value string) (string, error) {
+	signer, err := h.signer(requester.GetClient())
+	if err != nil {
+		return "", err
+	}
+
+	hash, err := signer.Hash(ctx, []byte(value))
+	if err != nil {
+		return "", errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+	return base64.RawURLEncoding.EncodeToString(hash[:signer.GetSigningMethodLength()/2]), nil
+}
+
+// signer returns the strategy used for signing ID tokens issued to the client.
+func (h DefaultStrategy) signer(client fosite.Client) (jwt.JWTStrategy, error) {
+	sc, ok := client.(fosite.IDTokenSigningClient)
+	if h.Signers == nil || !ok || sc.GetIDTokenSignedResponseAlg() == "" {
+		return h.JWTStrategy, nil
+	}
+
+	alg := sc.GetIDTokenSignedResponseAlg()
+	signer, found := h.Signers[alg]
+	if !found {
+		return nil, errorsx.WithStack(fosite.ErrServerError.WithHintf("The id token can not be signed using algorithm '%s'.", alg))
+	}
+	return signer, nil
+}
+
+// encrypt encrypts the signed ID token if the client registered an id_token_encrypted_response_alg, producing a
+// nested JWT as defined in https://openid.net/specs/openid-connect-core-1_0.html#Encryption
+func (h DefaultStrategy) encrypt(ctx context.Context, client fosite.Client, token string) (string, error) {
+	ec, ok := client.(fosite.IDTokenEncryptionClient)
+	if !ok || ec.GetIDTokenEncryptedResponseAlg() == "" {
+		return token, nil
+	}
+
+	oidcClient, ok := client.(fosite.OpenIDConnectClient)
+	if !ok {
+		return "", errorsx.WithStack(fosite.ErrServerError.WithDebug("Failed to encrypt id token because the client has no JSON Web Keys registered."))
+	}
+
+	set := oidcClient.GetJSONWebKeys()
+	if location := oidcClient.GetJSONWebKeysURI(); set == nil && location != "" {
+		if h.JWKSFetcherStrategy == nil {
+			return "", errorsx.WithStack(fosite.ErrMisconfiguration.WithDebug("A JWKSFetcherStrategy is required to encrypt id tokens for clients with a jwks_uri."))
+		}
+
+		keys, err := h.JWKSFetcherStrategy.Resolve(location, false)
+		if err != nil {
+			return "", errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebugf("Failed to encrypt id token because the client's JSON Web Keys could not be fetched: %s", err))
+		}
+		set = keys
+	}
+
+	alg := ec.GetIDTokenEncryptedResponseAlg()
+	key, err := jwt.FindEncryptionKey(set, alg)
+	if err != nil {
+		return "", errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebugf("Failed to encrypt id token because %s.", err))
+	}
+
+	encrypter := h.Encrypter
+	if encrypter == nil {
+		encrypter = new(jwt.DefaultEncrypter)
+	}
+
+	encrypted, err := encrypter.Encrypt(ctx, token, key, alg, ec.GetIDTokenEncryptedResponseEnc())
+	if err != nil {
+		return "", errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebugf("Failed to encrypt id token because %s.", err))
+	}
+	return encrypted, nil
 }
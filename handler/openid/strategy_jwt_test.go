@@ -24,12 +24,16 @@ package openid
 import (
 	"context"
 	"fmt"
+	"net/http/httptest"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
 
 	"github.com/ory/fosite"
+	"github.com/ory/fosite/internal"
 	"github.com/ory/fosite/token/jwt"
 )
 
@@ -298,3 +302,314 @@ func TestJWTStrategy_GenerateIDToken(t *testing.T) {
 		})
 	}
 }
+
+func TestJWTStrategy_GenerateEncryptedIDToken(t *testing.T) {
+	clientKey := internal.MustRSAKey()
+	var j = &DefaultStrategy{
+		JWTStrategy: &jwt.RS256JWTStrategy{
+			PrivateKey: key,
+		},
+		MinParameterEntropy: fosite.MinParameterEntropy,
+	}
+
+	newRequest := func(client fosite.Client) *fosite.AccessRequest {
+		req := fosite.NewAccessRequest(&DefaultSession{
+			Claims: &jwt.IDTokenClaims{
+				Subject: "peter",
+			},
+			Headers: &jwt.Headers{},
+		})
+		req.Client = client
+		return req
+	}
+
+	t.Run("case=encrypts the id token with the client's key", func(t *testing.T) {
+		token, err := j.GenerateIDToken(context.TODO(), newRequest(&fosite.DefaultOpenIDConnectClient{
+			DefaultClient:               &fosite.DefaultClient{ID: "foo"},
+			IDTokenEncryptedResponseAlg: string(jose.RSA_OAEP),
+			IDTokenEncryptedResponseEnc: string(jose.A256GCM),
+			JSONWebKeys: &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+				{Key: &clientKey.PublicKey, KeyID: "sig", Use: "sig"},
+				{Key: &clientKey.PublicKey, KeyID: "enc", Use: "enc"},
+			}},
+		}))
+		require.NoError(t, err)
+
+		encrypted, err := jose.ParseEncrypted(token)
+		require.NoError(t, err)
+		assert.Equal(t, "enc", encrypted.Header.KeyID)
+		assert.Equal(t, "JWT", encrypted.Header.ExtraHeaders[jose.HeaderContentType])
+		assert.Equal(t, string(jose.A256GCM), encrypted.Header.ExtraHeaders[jose.HeaderKey("enc")])
+
+		signed, err := encrypted.Decrypt(clientKey)
+		require.NoError(t, err)
+
+		decoded, err := j.Decode(context.TODO(), string(signed))
+		require.NoError(t, err)
+		assert.Equal(t, "peter", decoded.Claims["sub"])
+	})
+
+	t.Run("case=fails if the client has no encryption key", func(t *testing.T) {
+		_, err := j.GenerateIDToken(context.TODO(), newRequest(&fosite.DefaultOpenIDConnectClient{
+			DefaultClient:               &fosite.DefaultClient{ID: "foo"},
+			IDTokenEncryptedResponseAlg: string(jose.RSA_OAEP),
+			JSONWebKeys: &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+				{Key: &clientKey.PublicKey, KeyID: "sig", Use: "sig"},
+			}},
+		}))
+		require.Error(t, err)
+		assert.EqualError(t, err, fosite.ErrServerError.Error())
+	})
+
+	t.Run("case=does not encrypt if the client did not register an algorithm", func(t *testing.T) {
+		token, err := j.GenerateIDToken(context.TODO(), newRequest(&fosite.DefaultOpenIDConnectClient{
+			DefaultClient: &fosite.DefaultClient{ID: "foo"},
+		}))
+		require.NoError(t, err)
+
+		_, err = j.Decode(context.TODO(), token)
+		require.NoError(t, err)
+	})
+}
+
+func TestJWTStrategy_GenerateIDTokenWithClientSigningAlgorithm(t *testing.T) {
+	es256 := &jwt.ES256JWTStrategy{PrivateKey: internal.MustECDSAKey()}
+	var j = &DefaultStrategy{
+		JWTStrategy: &jwt.RS256JWTStrategy{
+			PrivateKey: key,
+		},
+		Signers: map[string]jwt.JWTStrategy{
+			"ES256": es256,
+		},
+		MinParameterEntropy: fosite.MinParameterEntropy,
+	}
+
+	newRequest := func(alg string) *fosite.AccessRequest {
+		req := fosite.NewAccessRequest(&DefaultSession{
+			Claims: &jwt.IDTokenClaims{
+				Subject: "peter",
+			},
+			Headers: &jwt.Headers{},
+		})
+		req.Client = &fosite.DefaultOpenIDConnectClient{
+			DefaultClient:            &fosite.DefaultClient{ID: "foo"},
+			IDTokenSignedResponseAlg: alg,
+		}
+		return req
+	}
+
+	t.Run("case=signs with the client's algorithm", func(t *testing.T) {
+		token, err := j.GenerateIDToken(context.TODO(), newRequest("ES256"))
+		require.NoError(t, err)
+
+		decoded, err := es256.Decode(context.TODO(), token)
+		require.NoError(t, err)
+		assert.Equal(t, "ES256", decoded.Header["alg"])
+	})
+
+	t.Run("case=falls back to the default strategy", func(t *testing.T) {
+		token, err := j.GenerateIDToken(context.TODO(), newRequest(""))
+		require.NoError(t, err)
+
+		decoded, err := j.Decode(context.TODO(), token)
+		require.NoError(t, err)
+		assert.Equal(t, "RS256", decoded.Header["alg"])
+	})
+
+	t.Run("case=fails for unsupported algorithms", func(t *testing.T) {
+		_, err := j.GenerateIDToken(context.TODO(), newRequest("ES512"))
+		require.Error(t, err)
+		assert.EqualError(t, err, fosite.ErrServerError.Error())
+	})
+}
+
+func TestJWTStrategy_GenerateIDTokenWithClaimsMapper(t *testing.T) {
+	var j = &DefaultStrategy{
+		JWTStrategy: &jwt.RS256JWTStrategy{
+			PrivateKey: key,
+		},
+		MinParameterEntropy: fosite.MinParameterEntropy,
+		ClaimsMapper: fosite.ClaimsMapperFunc(func(ctx context.Context, tokenType fosite.TokenType, requester fosite.Requester, claims jwt.MapClaims) error {
+			if requester.GetClient().GetID() == "denied" {
+				return fosite.ErrAccessDenied
+			}
+			assert.Equal(t, fosite.IDToken, tokenType)
+			claims["tenant"] = "acme"
+			delete(claims, "rat")
+			return nil
+		}),
+	}
+
+	newRequest := func(clientID string) *fosite.AccessRequest {
+		req := fosite.NewAccessRequest(&DefaultSession{
+			Claims: &jwt.IDTokenClaims{
+				Subject:     "peter",
+				RequestedAt: time.Now().UTC(),
+			},
+			Headers: &jwt.Headers{},
+		})
+		req.Client = &fosite.DefaultClient{ID: clientID}
+		return req
+	}
+
+	t.Run("case=modifies the claims before signing", func(t *testing.T) {
+		token, err := j.GenerateIDToken(context.TODO(), newRequest("foo"))
+		require.NoError(t, err)
+
+		decoded, err := j.Decode(context.TODO(), token)
+		require.NoError(t, err)
+		claims := decoded.Claims
+		assert.Equal(t, "acme", claims["tenant"])
+		assert.Equal(t, "peter", claims["sub"])
+		assert.NotContains(t, claims, "rat")
+	})
+
+	t.Run("case=aborts if the mapper fails", func(t *testing.T) {
+		_, err := j.GenerateIDToken(context.TODO(), newRequest("denied"))
+		assert.ErrorIs(t, err, fosite.ErrAccessDenied)
+	})
+}
+
+func TestJWTStrategy_GenerateIDTokenWithPairwiseSubject(t *testing.T) {
+	var j = &DefaultStrategy{
+		JWTStrategy: &jwt.RS256JWTStrategy{
+			PrivateKey: key,
+		},
+		MinParameterEntropy: fosite.MinParameterEntropy,
+		SubjectIdentifierAlgorithms: fosite.SubjectIdentifierAlgorithms{
+			fosite.SubjectTypePairwise: &fosite.PairwiseSubjectIdentifierAlgorithm{Salt: []byte("some-salt")},
+		},
+	}
+
+	session := &DefaultSession{
+		Claims: &jwt.IDTokenClaims{
+			Subject:     "peter",
+			RequestedAt: time.Now().UTC(),
+		},
+		Headers: &jwt.Headers{},
+	}
+	newRequest := func(subjectType string) *fosite.AccessRequest {
+		req := fosite.NewAccessRequest(session)
+		req.Client = &fosite.DefaultOpenIDConnectClient{
+			DefaultClient: &fosite.DefaultClient{ID: "foo", RedirectURIs: []string{"https://client.example.com/cb"}},
+			SubjectType:   subjectType,
+		}
+		return req
+	}
+
+	token, err := j.GenerateIDToken(context.TODO(), newRequest(fosite.SubjectTypePairwise))
+	require.NoError(t, err)
+	decoded, err := j.Decode(context.TODO(), token)
+	require.NoError(t, err)
+
+	sub := decoded.Claims["sub"]
+	assert.NotEqual(t, "peter", sub)
+	assert.Equal(t, "peter", session.Claims.Subject)
+
+	t.Run("case=accepts an id_token_hint with the pairwise subject", func(t *testing.T) {
+		req := newRequest(fosite.SubjectTypePairwise)
+		req.Form.Set("id_token_hint", token)
+		hinted, err := j.GenerateIDToken(context.TODO(), req)
+		require.NoError(t, err)
+		decoded, err := j.Decode(context.TODO(), hinted)
+		require.NoError(t, err)
+		assert.Equal(t, sub, decoded.Claims["sub"])
+	})
+
+	t.Run("case=public clients receive the local subject", func(t *testing.T) {
+		token, err := j.GenerateIDToken(context.TODO(), newRequest(""))
+		require.NoError(t, err)
+		decoded, err := j.Decode(context.TODO(), token)
+		require.NoError(t, err)
+		assert.Equal(t, "peter", decoded.Claims["sub"])
+	})
+}
+
+func TestJWTStrategy_GenerateIDTokenWithIssuerProvider(t *testing.T) {
+	var j = &DefaultStrategy{
+		JWTStrategy: &jwt.RS256JWTStrategy{
+			PrivateKey: key,
+		},
+		Issuer:              "https://static.example.com",
+		IssuerProvider:      &fosite.HostIssuerProvider{AllowedHosts: []string{"tenant.example.com"}},
+		MinParameterEntropy: fosite.MinParameterEntropy,
+	}
+
+	newRequest := func() *fosite.AccessRequest {
+		req := fosite.NewAccessRequest(&DefaultSession{
+			Claims: &jwt.IDTokenClaims{
+				Subject:     "peter",
+				RequestedAt: time.Now().UTC(),
+			},
+			Headers: &jwt.Headers{},
+		})
+		req.Client = &fosite.DefaultClient{ID: "foo"}
+		return req
+	}
+
+	t.Run("case=uses the issuer of the request", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "https://tenant.example.com/token", nil)
+		token, err := j.GenerateIDToken(context.WithValue(context.TODO(), fosite.RequestContextKey, r), newRequest())
+		require.NoError(t, err)
+
+		decoded, err := j.Decode(context.TODO(), token)
+		require.NoError(t, err)
+		assert.Equal(t, "https://tenant.example.com", decoded.Claims["iss"])
+	})
+
+	t.Run("case=fails if the issuer can not be determined", func(t *testing.T) {
+		_, err := j.GenerateIDToken(context.TODO(), newRequest())
+		assert.ErrorIs(t, err, fosite.ErrServerError)
+	})
+}
+
+func TestJWTStrategy_GenerateIDTokenWithClaimSources(t *testing.T) {
+	var j = &DefaultStrategy{
+		JWTStrategy: &jwt.RS256JWTStrategy{
+			PrivateKey: key,
+		},
+		MinParameterEntropy: fosite.MinParameterEntropy,
+	}
+
+	newRequest := func(claims *jwt.IDTokenClaims) *fosite.AccessRequest {
+		req := fosite.NewAccessRequest(&DefaultSession{Claims: claims, Headers: &jwt.Headers{}})
+		req.Client = &fosite.DefaultClient{ID: "foo"}
+		return req
+	}
+
+	claims := &jwt.IDTokenClaims{Subject: "peter"}
+	claims.AddDistributedClaims("src1", "https://bank.example.com/claim_source", "ksj3n283dke", "payment_info")
+	token, err := j.GenerateIDToken(context.TODO(), newRequest(claims))
+	require.NoError(t, err)
+
+	decoded, err := j.Decode(context.TODO(), token)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"payment_info": "src1"}, decoded.Claims["_claim_names"])
+	assert.Equal(t, map[string]interface{}{
+		"src1": map[string]interface{}{"endpoint": "https://bank.example.com/claim_source", "access_token": "ksj3n283dke"},
+	}, decoded.Claims["_claim_sources"])
+
+	_, err = j.GenerateIDToken(context.TODO(), newRequest(&jwt.IDTokenClaims{
+		Subject:    "peter",
+		ClaimNames: map[string]string{"payment_info": "unknown"},
+	}))
+	assert.ErrorIs(t, err, fosite.ErrServerError)
+}
+
+func TestDefaultSession_Clone(t *testing.T) {
+	session := &DefaultSession{
+		Claims: &jwt.IDTokenClaims{
+			Subject:                         "peter",
+			Audience:                        []string{"client"},
+			AuthTime:                        time.Now().UTC().Round(time.Second),
+			AuthenticationMethodsReferences: []string{"pwd"},
+			Extra:                           map[string]interface{}{"foo": "bar"},
+			ClaimNames:                      map[string]string{"address": "src1"},
+			ClaimSources:                    map[string]jwt.ClaimSource{"src1": {JWT: "jwt"}},
+		},
+		Headers:   &jwt.Headers{Extra: map[string]interface{}{"kid": "key"}},
+		ExpiresAt: map[fosite.TokenType]time.Time{fosite.IDToken: time.Now().UTC()},
+		Subject:   "peter",
+	}
+	require.NoError(t, fosite.CheckSessionClone(session))
+}
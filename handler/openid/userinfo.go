@@ -0,0 +1,138 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package openid
+
+import (
+	"context"
+
+	"github.com/ory/x/errorsx"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/token/jwt"
+)
+
+// UserInfoProvider returns the claims known about an end-user, keyed by their claim names, e.g. "email" or
+// "given_name". Claims which are not granted by the scopes of the request are filtered out by the caller, so
+// providers may return all claims they know about.
+type UserInfoProvider interface {
+	GetUserInfo(ctx context.Context, subject string, requester fosite.Requester) (map[string]interface{}, error)
+}
+
+// ScopeClaims maps scopes to the claims they grant access to.
+type ScopeClaims map[string][]string
+
+// DefaultScopeClaims maps the scopes defined by OpenID Connect Core 1.0 to the standard claims they request.
+//
+// See https://openid.net/specs/openid-connect-core-1_0.html#ScopeClaims
+var DefaultScopeClaims = ScopeClaims{
+	"profile": {
+		"name", "family_name", "given_name", "middle_name", "nickname", "preferred_username", "profile", "picture",
+		"website", "gender", "birthdate", "zoneinfo", "locale", "updated_at",
+	},
+	"email":   {"email", "email_verified"},
+	"address": {"address"},
+	"phone":   {"phone_number", "phone_number_verified"},
+}
+
+// ClaimsForScopes returns the names of the claims granted by the given scopes.
+func (s ScopeClaims) ClaimsForScopes(scopes fosite.Arguments) []string {
+	var claims []string
+	for _, scope := range scopes {
+		for _, claim := range s[scope] {
+			if !fosite.Arguments(claims).Has(claim) {
+				claims = append(claims, claim)
+			}
+		}
+	}
+	return claims
+}
+
+// Filter returns the claims granted by the given scopes. Claims without a value are omitted.
+func (s ScopeClaims) Filter(claims map[string]interface{}, scopes fosite.Arguments) map[string]interface{} {
+	return pickClaims(claims, s.ClaimsForScopes(scopes))
+}
+
+// requestedClaimNames returns the claims granted by the scopes and the claims requested individually using the
+// claims parameter.
+func requestedClaimNames(scopeClaims ScopeClaims, scopes fosite.Arguments, requested fosite.ClaimRequests) []string {
+	names := scopeClaims.ClaimsForScopes(scopes)
+	for _, name := range requested.Names() {
+		if !fosite.Arguments(names).Has(name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func pickClaims(claims map[string]interface{}, names []string) map[string]interface{} {
+	picked := map[string]interface{}{}
+	for _, name := range names {
+		if value, ok := claims[name]; ok && value != nil {
+			picked[name] = value
+		}
+	}
+	return picked
+}
+
+// ScopeClaimsMapper is a fosite.ClaimsMapper which adds the claims granted by the scopes of a request, and the
+// claims requested for the ID token using the claims parameter, to ID tokens. The claims are fetched from the UserInfoProvider using the subject of the ID token. Claims which are
+// already set, e.g. by the session, are not overwritten.
+type ScopeClaimsMapper struct {
+	UserInfoProvider UserInfoProvider
+
+	// ScopeClaims maps scopes to the claims they grant. Defaults to DefaultScopeClaims.
+	ScopeClaims ScopeClaims
+}
+
+func (m *ScopeClaimsMapper) MapClaims(ctx context.Context, tokenType fosite.TokenType, requester fosite.Requester, claims jwt.MapClaims) error {
+	if tokenType != fosite.IDToken {
+		return nil
+	}
+
+	scopeClaims := m.ScopeClaims
+	if scopeClaims == nil {
+		scopeClaims = DefaultScopeClaims
+	}
+
+	var requested fosite.ClaimRequests
+	if cr := fosite.ClaimsRequestFromRequester(requester); cr != nil {
+		requested = cr.IDToken
+	}
+
+	names := requestedClaimNames(scopeClaims, requester.GetGrantedScopes(), requested)
+	if len(names) == 0 {
+		return nil
+	}
+
+	subject, _ := claims["sub"].(string)
+	info, err := m.UserInfoProvider.GetUserInfo(ctx, subject, requester)
+	if err != nil {
+		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	for claim, value := range pickClaims(info, names) {
+		if _, ok := claims[claim]; !ok {
+			claims[claim] = value
+		}
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package openid
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/x/errorsx"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/token/jwt"
+)
+
+// AccessTokenIntrospector validates access tokens. It is implemented by fosite.OAuth2Provider.
+type AccessTokenIntrospector interface {
+	IntrospectToken(ctx context.Context, token string, tokenUse fosite.TokenUse, session fosite.Session, scope ...string) (fosite.TokenUse, fosite.AccessRequester, error)
+}
+
+// UserInfoHandler implements the UserInfo endpoint as defined in
+// https://openid.net/specs/openid-connect-core-1_0.html#UserInfo
+type UserInfoHandler struct {
+	// Introspector validates the access token presented by the client, e.g. the fosite.OAuth2Provider.
+	Introspector AccessTokenIntrospector
+
+	// UserInfoProvider returns the claims of the end-user. If nil, the claims are taken from the ID token claims
+	// of the session the access token was issued for.
+	UserInfoProvider UserInfoProvider
+
+	// ScopeClaims maps scopes to the claims they grant. Defaults to DefaultScopeClaims.
+	ScopeClaims ScopeClaims
+
+	// Issuer is the iss claim of signed UserInfo responses.
+	Issuer string
+
+	// IssuerProvider, if set, takes precedence over Issuer.
+	IssuerProvider fosite.IssuerProvider
+
+	// JWTStrategy signs UserInfo responses of clients which registered a userinfo_signed_response_alg.
+	JWTStrategy jwt.JWTStrategy
+
+	// Signers maps JWS algorithms to the strategies signing with them. If nil, all UserInfo responses are signed
+	// using JWTStrategy.
+	Signers map[string]jwt.JWTStrategy
+
+	// SubjectIdentifierAlgorithms computes the "sub" claim for the client the access token was issued to. If nil,
+	// the subject of the session is used.
+	SubjectIdentifierAlgorithms fosite.SubjectIdentifierAlgorithms
+}
+
+// WriteUserInfoResponse validates the access token of the request, which must have been granted the openid scope,
+// and writes the claims of the end-user. The response is a signed JWT if the client registered a
+// userinfo_signed_response_alg and JSON otherwise. The session is used as a prototype for decoding the access
+// token's session, e.g. openid.NewDefaultSession().
+func (h *UserInfoHandler) WriteUserInfoResponse(ctx context.Context, rw http.ResponseWriter, r *http.Request, session fosite.Session) {
+	rw.Header().Set("Cache-Control", "no-store")
+	rw.Header().Set("Pragma", "no-cache")
+
+	token := fosite.AccessTokenFromRequest(r)
+	if token == "" {
+		rw.Header().Set("WWW-Authenticate", "Bearer")
+		rw.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	ctx = context.WithValue(ctx, fosite.ResourceRequestContextKey, r)
+	_, ar, err := h.Introspector.IntrospectToken(ctx, token, fosite.AccessToken, session, "openid")
+	if err != nil {
+		h.writeError(rw, err)
+		return
+	}
+
+	claims, err := h.claims(ctx, ar)
+	if err != nil {
+		h.writeError(rw, err)
+		return
+	}
+
+	signer, err := h.signer(ar.GetClient())
+	if err != nil {
+		h.writeError(rw, err)
+		return
+	} else if signer == nil {
+		rw.Header().Set("Content-Type", "application/json;charset=UTF-8")
+		_ = json.NewEncoder(rw).Encode(claims)
+		return
+	}
+
+	issuer, err := fosite.GetIssuer(ctx, h.IssuerProvider, h.Issuer)
+	if err != nil {
+		h.writeError(rw, err)
+		return
+	}
+
+	claims["iss"] = issuer
+	claims["aud"] = ar.GetClient().GetID()
+	signed, _, err := signer.Generate(ctx, claims, &jwt.Headers{})
+	if err != nil {
+		h.writeError(rw, errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error())))
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/jwt;charset=UTF-8")
+	_, _ = rw.Write([]byte(signed))
+}
+
+// claims returns the claims of the end-user granted by the scopes of the access token.
+func (h *UserInfoHandler) claims(ctx context.Context, ar fosite.AccessRequester) (jwt.MapClaims, error) {
+	subject := ar.GetSession().GetSubject()
+	if subject == "" {
+		return nil, errorsx.WithStack(fosite.ErrRequestUnauthorized.WithHint("The access token was not issued on behalf of an end-user."))
+	}
+
+	scopeClaims := h.ScopeClaims
+	if scopeClaims == nil {
+		scopeClaims = DefaultScopeClaims
+	}
+
+	var info map[string]interface{}
+	if h.UserInfoProvider != nil {
+		var err error
+		if info, err = h.UserInfoProvider.GetUserInfo(ctx, subject, ar); err != nil {
+			return nil, errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+		}
+	} else if sess, ok := ar.GetSession().(Session); ok {
+		info = sess.IDTokenClaims().Extra
+	}
+
+	var requested fosite.ClaimRequests
+	if cr := fosite.ClaimsRequestFromRequester(ar); cr != nil {
+		requested = cr.UserInfo
+	}
+
+	obfuscated, err := h.SubjectIdentifierAlgorithms.Obfuscate(ctx, subject, ar.GetClient())
+	if err != nil {
+		return nil, err
+	}
+
+	claims := jwt.MapClaims(pickClaims(info, requestedClaimNames(scopeClaims, ar.GetGrantedScopes(), requested)))
+	claims["sub"] = obfuscated
+	return claims, nil
+}
+
+// signer returns the strategy signing the UserInfo responses of the client, or nil if the response is plain JSON.
+func (h *UserInfoHandler) signer(client fosite.Client) (jwt.JWTStrategy, error) {
+	sc, ok := client.(fosite.UserInfoSigningClient)
+	if !ok || sc.GetUserInfoSignedResponseAlg() == "" || sc.GetUserInfoSignedResponseAlg() == "none" {
+		return nil, nil
+	}
+
+	alg := sc.GetUserInfoSignedResponseAlg()
+	signer := h.JWTStrategy
+	if h.Signers != nil {
+		signer = h.Signers[alg]
+	}
+
+	if signer == nil {
+		return nil, errorsx.WithStack(fosite.ErrServerError.WithHintf("The UserInfo response can not be signed using algorithm '%s'.", alg))
+	}
+	return signer, nil
+}
+
+// writeError writes the error response defined in https://tools.ietf.org/html/rfc6750#section-3.1
+func (h *UserInfoHandler) writeError(rw http.ResponseWriter, err error) {
+	rfcerr := fosite.ErrorToRFC6749Error(err)
+
+	description := strings.Replace(rfcerr.GetDescription(), `"`, `'`, -1)
+	code, name := http.StatusUnauthorized, "invalid_token"
+	if rfcerr.StatusCode() >= http.StatusInternalServerError {
+		code, name = rfcerr.StatusCode(), rfcerr.ErrorField
+	} else {
+		if errors.Is(err, fosite.ErrInvalidScope) {
+			code, name = http.StatusForbidden, "insufficient_scope"
+		}
+		rw.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="%s", error_description="%s"`, name, description))
+	}
+
+	rw.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	rw.WriteHeader(code)
+	_ = json.NewEncoder(rw).Encode(map[string]string{
+		"error":             name,
+		"error_description": description,
+	})
+}
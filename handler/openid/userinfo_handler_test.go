@@ -0,0 +1,157 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package openid
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/token/jwt"
+)
+
+type introspectorFunc func(ctx context.Context, token string, tokenUse fosite.TokenUse, session fosite.Session, scope ...string) (fosite.TokenUse, fosite.AccessRequester, error)
+
+func (f introspectorFunc) IntrospectToken(ctx context.Context, token string, tokenUse fosite.TokenUse, session fosite.Session, scope ...string) (fosite.TokenUse, fosite.AccessRequester, error) {
+	return f(ctx, token, tokenUse, session, scope...)
+}
+
+func TestUserInfoHandler(t *testing.T) {
+	signer := &jwt.RS256JWTStrategy{PrivateKey: key}
+	tokens := map[string]*fosite.AccessRequest{}
+	newAccessRequest := func(subject string, client fosite.Client, scopes ...string) *fosite.AccessRequest {
+		ar := fosite.NewAccessRequest(&DefaultSession{
+			Subject: subject,
+			Claims: &jwt.IDTokenClaims{
+				Subject: subject,
+				Extra:   map[string]interface{}{"email": "peter@session.example.org"},
+			},
+		})
+		ar.Client = client
+		for _, scope := range scopes {
+			ar.GrantScope(scope)
+		}
+		return ar
+	}
+
+	client := &fosite.DefaultOpenIDConnectClient{DefaultClient: &fosite.DefaultClient{ID: "foo"}}
+	signingClient := &fosite.DefaultOpenIDConnectClient{DefaultClient: &fosite.DefaultClient{ID: "bar"}, UserInfoSignedResponseAlg: "RS256"}
+	tokens["valid"] = newAccessRequest("peter", client, "openid", "profile")
+	tokens["email"] = newAccessRequest("peter", client, "openid", "email")
+	tokens["signed"] = newAccessRequest("peter", signingClient, "openid", "profile")
+	tokens["no-openid"] = newAccessRequest("peter", client, "profile")
+	tokens["no-subject"] = newAccessRequest("", client, "openid")
+
+	h := &UserInfoHandler{
+		Introspector: introspectorFunc(func(ctx context.Context, token string, tokenUse fosite.TokenUse, session fosite.Session, scope ...string) (fosite.TokenUse, fosite.AccessRequester, error) {
+			assert.Equal(t, fosite.AccessToken, tokenUse)
+			assert.NotNil(t, ctx.Value(fosite.ResourceRequestContextKey))
+
+			ar, ok := tokens[token]
+			if !ok {
+				return "", nil, fosite.ErrTokenExpired
+			}
+			for _, s := range scope {
+				if !ar.GetGrantedScopes().Has(s) {
+					return "", nil, fosite.ErrInvalidScope
+				}
+			}
+			return fosite.AccessToken, ar, nil
+		}),
+		UserInfoProvider: staticUserInfoProvider{"peter": {"given_name": "Peter", "email": "peter@example.org"}},
+		Issuer:           "https://auth.example.org",
+		JWTStrategy:      signer,
+	}
+
+	request := func(t *testing.T, h *UserInfoHandler, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/userinfo", nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		rw := httptest.NewRecorder()
+		h.WriteUserInfoResponse(context.Background(), rw, r, NewDefaultSession())
+		return rw
+	}
+
+	t.Run("case=returns the claims granted by the scopes", func(t *testing.T) {
+		rw := request(t, h, "valid")
+		require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+		assert.Equal(t, "application/json;charset=UTF-8", rw.Header().Get("Content-Type"))
+		assert.Equal(t, "no-store", rw.Header().Get("Cache-Control"))
+
+		var claims map[string]interface{}
+		require.NoError(t, json.NewDecoder(rw.Body).Decode(&claims))
+		assert.Equal(t, map[string]interface{}{"sub": "peter", "given_name": "Peter"}, claims)
+	})
+
+	t.Run("case=falls back to the claims of the session", func(t *testing.T) {
+		rw := request(t, &UserInfoHandler{Introspector: h.Introspector}, "email")
+		require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+
+		var claims map[string]interface{}
+		require.NoError(t, json.NewDecoder(rw.Body).Decode(&claims))
+		assert.Equal(t, map[string]interface{}{"sub": "peter", "email": "peter@session.example.org"}, claims)
+	})
+
+	t.Run("case=signs the response if the client requests it", func(t *testing.T) {
+		rw := request(t, h, "signed")
+		require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+		assert.Equal(t, "application/jwt;charset=UTF-8", rw.Header().Get("Content-Type"))
+
+		decoded, err := signer.Decode(context.Background(), rw.Body.String())
+		require.NoError(t, err)
+		assert.Equal(t, "peter", decoded.Claims["sub"])
+		assert.Equal(t, "Peter", decoded.Claims["given_name"])
+		assert.Equal(t, "https://auth.example.org", decoded.Claims["iss"])
+		assert.Equal(t, "bar", decoded.Claims["aud"])
+	})
+
+	t.Run("case=fails if the response can not be signed", func(t *testing.T) {
+		rw := request(t, &UserInfoHandler{Introspector: h.Introspector, Signers: map[string]jwt.JWTStrategy{"ES256": signer}}, "signed")
+		assert.Equal(t, http.StatusInternalServerError, rw.Code)
+		assert.Empty(t, rw.Header().Get("WWW-Authenticate"))
+	})
+
+	for _, tc := range []struct {
+		d          string
+		token      string
+		expectCode int
+		expectAuth string
+	}{
+		{d: "no token", token: "", expectCode: http.StatusUnauthorized, expectAuth: "Bearer"},
+		{d: "invalid token", token: "invalid", expectCode: http.StatusUnauthorized, expectAuth: `Bearer error="invalid_token"`},
+		{d: "missing openid scope", token: "no-openid", expectCode: http.StatusForbidden, expectAuth: `Bearer error="insufficient_scope"`},
+		{d: "no end-user", token: "no-subject", expectCode: http.StatusUnauthorized, expectAuth: `Bearer error="invalid_token"`},
+	} {
+		t.Run("case=rejects "+tc.d, func(t *testing.T) {
+			rw := request(t, h, tc.token)
+			assert.Equal(t, tc.expectCode, rw.Code)
+			assert.Contains(t, rw.Header().Get("WWW-Authenticate"), tc.expectAuth)
+		})
+	}
+}
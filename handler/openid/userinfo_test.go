@@ -0,0 +1,134 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package openid
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/token/jwt"
+)
+
+type staticUserInfoProvider map[string]map[string]interface{}
+
+func (p staticUserInfoProvider) GetUserInfo(ctx context.Context, subject string, requester fosite.Requester) (map[string]interface{}, error) {
+	info, ok := p[subject]
+	if !ok {
+		return nil, errors.New("unknown subject")
+	}
+	return info, nil
+}
+
+func TestScopeClaims(t *testing.T) {
+	assert.Equal(t, []string{"email", "email_verified", "phone_number", "phone_number_verified"}, DefaultScopeClaims.ClaimsForScopes(fosite.Arguments{"openid", "email", "phone"}))
+	assert.Empty(t, DefaultScopeClaims.ClaimsForScopes(fosite.Arguments{"openid", "offline"}))
+
+	claims := map[string]interface{}{
+		"email":          "peter@example.org",
+		"email_verified": true,
+		"given_name":     "Peter",
+		"phone_number":   nil,
+	}
+	assert.Equal(t, map[string]interface{}{
+		"email":          "peter@example.org",
+		"email_verified": true,
+	}, DefaultScopeClaims.Filter(claims, fosite.Arguments{"openid", "email", "phone"}))
+
+	custom := ScopeClaims{"groups": {"groups"}}
+	assert.Equal(t, []string{"groups"}, custom.ClaimsForScopes(fosite.Arguments{"profile", "groups"}))
+}
+
+func TestScopeClaimsMapper(t *testing.T) {
+	var j = &DefaultStrategy{
+		JWTStrategy: &jwt.RS256JWTStrategy{
+			PrivateKey: key,
+		},
+		ClaimsMapper: &ScopeClaimsMapper{
+			UserInfoProvider: staticUserInfoProvider{
+				"peter": {
+					"email":       "peter@example.org",
+					"given_name":  "Peter",
+					"family_name": "Pan",
+					"website":     "https://example.org",
+				},
+			},
+		},
+	}
+
+	newRequest := func(subject string, scopes ...string) *fosite.AccessRequest {
+		req := fosite.NewAccessRequest(&DefaultSession{
+			Claims: &jwt.IDTokenClaims{
+				Subject: subject,
+				Extra:   map[string]interface{}{"website": "https://peter.example.org"},
+			},
+			Headers: &jwt.Headers{},
+		})
+		req.Client = &fosite.DefaultClient{ID: "foo"}
+		for _, scope := range scopes {
+			req.GrantScope(scope)
+		}
+		return req
+	}
+
+	t.Run("case=adds the claims granted by scopes", func(t *testing.T) {
+		token, err := j.GenerateIDToken(context.TODO(), newRequest("peter", "openid", "profile"))
+		require.NoError(t, err)
+
+		decoded, err := j.Decode(context.TODO(), token)
+		require.NoError(t, err)
+		assert.Equal(t, "Peter", decoded.Claims["given_name"])
+		assert.Equal(t, "Pan", decoded.Claims["family_name"])
+		assert.Equal(t, "https://peter.example.org", decoded.Claims["website"], "claims set by the session must not be overwritten")
+		assert.NotContains(t, decoded.Claims, "email")
+	})
+
+	t.Run("case=adds the claims requested by the claims parameter", func(t *testing.T) {
+		req := newRequest("peter", "openid")
+		req.Form.Set("claims", `{"id_token":{"email":{"essential":true}},"userinfo":{"given_name":null}}`)
+		token, err := j.GenerateIDToken(context.TODO(), req)
+		require.NoError(t, err)
+
+		decoded, err := j.Decode(context.TODO(), token)
+		require.NoError(t, err)
+		assert.Equal(t, "peter@example.org", decoded.Claims["email"])
+		assert.NotContains(t, decoded.Claims, "given_name")
+	})
+
+	t.Run("case=does not call the provider without claim scopes", func(t *testing.T) {
+		token, err := j.GenerateIDToken(context.TODO(), newRequest("unknown", "openid"))
+		require.NoError(t, err)
+
+		decoded, err := j.Decode(context.TODO(), token)
+		require.NoError(t, err)
+		assert.NotContains(t, decoded.Claims, "given_name")
+	})
+
+	t.Run("case=fails if the provider fails", func(t *testing.T) {
+		_, err := j.GenerateIDToken(context.TODO(), newRequest("unknown", "openid", "email"))
+		assert.ErrorIs(t, err, fosite.ErrServerError)
+	})
+}
@@ -41,11 +41,32 @@ type OpenIDConnectRequestValidator struct {
 	AllowedPrompt       []string
 	Strategy            jwt.JWTStrategy
 	IsRedirectURISecure func(*url.URL) bool
+
+	// AuthenticationContextPolicy decides whether the acr claim of the session satisfies the requested
+	// authentication context class references. Defaults to DefaultAuthenticationContextPolicy.
+	AuthenticationContextPolicy AuthenticationContextPolicy
+
+	// RequireNonce, if true, requires the nonce parameter in all OpenID Connect authorize requests, including
+	// those using the authorize code flow or the "code token" response type.
+	RequireNonce bool
+
+	// MinNonceEntropy, if set, is the minimum length of the nonce parameter in all OpenID Connect flows.
+	MinNonceEntropy int
+
+	// NonceStorage, if set, records the nonce of every authorize request and rejects requests reusing a nonce
+	// of the same client within NonceLifespan.
+	NonceStorage NonceStorage
+
+	// NonceLifespan is the time during which a nonce can not be used again. Defaults to DefaultNonceLifespan.
+	NonceLifespan time.Duration
 }
 
+// DefaultNonceLifespan is the default time during which NonceStorage prevents nonces from being replayed.
+const DefaultNonceLifespan = time.Hour
+
 func NewOpenIDConnectRequestValidator(prompt []string, strategy jwt.JWTStrategy) *OpenIDConnectRequestValidator {
 	if len(prompt) == 0 {
-		prompt = []string{"login", "none", "consent", "select_account"}
+		prompt = []string{"login", "none", "consent", "select_account", "create"}
 	}
 
 	return &OpenIDConnectRequestValidator{
@@ -59,6 +80,74 @@ func (v *OpenIDConnectRequestValidator) WithRedirectSecureChecker(checker func(*
 	return v
 }
 
+func (v *OpenIDConnectRequestValidator) WithAuthenticationContextPolicy(policy AuthenticationContextPolicy) *OpenIDConnectRequestValidator {
+	v.AuthenticationContextPolicy = policy
+	return v
+}
+
+// WithNoncePolicy configures the nonce requirements which ValidateNonce enforces.
+func (v *OpenIDConnectRequestValidator) WithNoncePolicy(required bool, minEntropy int, storage NonceStorage, lifespan time.Duration) *OpenIDConnectRequestValidator {
+	v.RequireNonce = required
+	v.MinNonceEntropy = minEntropy
+	v.NonceStorage = storage
+	v.NonceLifespan = lifespan
+	return v
+}
+
+// ValidateNonce enforces RequireNonce and MinNonceEntropy and, if NonceStorage is set, rejects nonces which the
+// client has already used within NonceLifespan. The nonce is recorded as used afterwards.
+func (v *OpenIDConnectRequestValidator) ValidateNonce(ctx context.Context, req fosite.AuthorizeRequester) error {
+	nonce := req.GetRequestForm().Get("nonce")
+	if len(nonce) == 0 {
+		if v.RequireNonce {
+			return errorsx.WithStack(fosite.ErrInvalidRequest.WithHint("Parameter 'nonce' must be set when using OpenID Connect."))
+		}
+		return nil
+	}
+
+	if len(nonce) < v.MinNonceEntropy {
+		return errorsx.WithStack(fosite.ErrInsufficientEntropy.WithHintf("Parameter 'nonce' is set but does not satisfy the minimum entropy of %d characters.", v.MinNonceEntropy))
+	}
+
+	if v.NonceStorage == nil {
+		return nil
+	}
+
+	clientID := req.GetClient().GetID()
+	nonceUsedErr := fosite.ErrInvalidRequest.WithHint("Parameter 'nonce' has already been used by a previous authorization request.")
+	var used bool
+	if err := fosite.StorageOperation(ctx, "IsNonceUsed", func(ctx context.Context) (err error) {
+		used, err = v.NonceStorage.IsNonceUsed(ctx, clientID, nonce)
+		return err
+	}); err != nil {
+		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	} else if used {
+		return errorsx.WithStack(nonceUsedErr)
+	}
+
+	lifespan := v.NonceLifespan
+	if lifespan == 0 {
+		lifespan = DefaultNonceLifespan
+	}
+
+	if err := fosite.StorageOperation(ctx, "MarkNonceUsedForTime", func(ctx context.Context) error {
+		return v.NonceStorage.MarkNonceUsedForTime(ctx, clientID, nonce, time.Now().UTC().Add(lifespan))
+	}); errors.Is(err, fosite.ErrInvalidRequest) {
+		// The nonce was used by a concurrent request.
+		return errorsx.WithStack(nonceUsedErr.WithWrap(err))
+	} else if err != nil {
+		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+	return nil
+}
+
+func (v *OpenIDConnectRequestValidator) authenticationContextPolicy() AuthenticationContextPolicy {
+	if v.AuthenticationContextPolicy == nil {
+		return new(DefaultAuthenticationContextPolicy)
+	}
+	return v.AuthenticationContextPolicy
+}
+
 func (v *OpenIDConnectRequestValidator) secureChecker() func(*url.URL) bool {
 	if v.IsRedirectURISecure == nil {
 		v.IsRedirectURISecure = fosite.IsRedirectURISecure
@@ -135,6 +224,10 @@ func (v *OpenIDConnectRequestValidator) ValidatePrompt(ctx context.Context, req
 		}
 	}
 
+	if requested := RequestedACR(req); !v.authenticationContextPolicy().SatisfiesACR(ctx, req, requested, claims.AuthenticationContextClassReference) {
+		return errorsx.WithStack(fosite.ErrUnmetAuthenticationRequirements.WithHintf("The authentication context class reference '%s' of the session does not satisfy the requested values '%s'.", claims.AuthenticationContextClassReference, strings.Join(requested.Values, " ")))
+	}
+
 	if stringslice.Has(prompt, "none") {
 		if claims.AuthTime.IsZero() {
 			return errorsx.WithStack(fosite.ErrServerError.WithDebug("Failed to validate OpenID Connect request because because auth_time is missing from session."))
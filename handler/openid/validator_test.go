@@ -32,6 +32,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/ory/fosite"
+	"github.com/ory/fosite/storage"
 	"github.com/ory/fosite/token/jwt"
 )
 
@@ -75,6 +76,33 @@ func TestValidatePrompt(t *testing.T) {
 				},
 			},
 		},
+		{
+			d:           "should pass because prompt=create is supported by default",
+			prompt:      "create",
+			redirectURL: "https://foo-bar/",
+			s: &DefaultSession{
+				Subject: "foo",
+				Claims: &jwt.IDTokenClaims{
+					Subject:     "foo",
+					RequestedAt: time.Now().UTC(),
+					AuthTime:    time.Now().UTC(),
+				},
+			},
+		},
+		{
+			d:           "should fail because prompt=create can not be combined with prompt=none",
+			prompt:      "create none",
+			expectErr:   true,
+			redirectURL: "https://foo-bar/",
+			s: &DefaultSession{
+				Subject: "foo",
+				Claims: &jwt.IDTokenClaims{
+					Subject:     "foo",
+					RequestedAt: time.Now().UTC(),
+					AuthTime:    time.Now().UTC(),
+				},
+			},
+		},
 		{
 			d:           "should pass because prompt=none works for public clients and http localhost",
 			prompt:      "none",
@@ -284,3 +312,71 @@ func parse(u string) *url.URL {
 	o, _ := url.Parse(u)
 	return o
 }
+
+func TestValidateNonce(t *testing.T) {
+	newRequest := func(clientID, nonce string) *fosite.AuthorizeRequest {
+		ar := fosite.NewAuthorizeRequest()
+		ar.Client = &fosite.DefaultClient{ID: clientID}
+		if nonce != "" {
+			ar.Form.Set("nonce", nonce)
+		}
+		return ar
+	}
+
+	for k, tc := range []struct {
+		d         string
+		v         *OpenIDConnectRequestValidator
+		nonce     string
+		expectErr error
+	}{
+		{
+			d: "should pass without nonce by default",
+			v: new(OpenIDConnectRequestValidator),
+		},
+		{
+			d:         "should fail without nonce if it is required",
+			v:         new(OpenIDConnectRequestValidator).WithNoncePolicy(true, 0, nil, 0),
+			expectErr: fosite.ErrInvalidRequest,
+		},
+		{
+			d:         "should fail if the nonce is too short",
+			v:         new(OpenIDConnectRequestValidator).WithNoncePolicy(false, 8, nil, 0),
+			nonce:     "1234567",
+			expectErr: fosite.ErrInsufficientEntropy,
+		},
+		{
+			d:     "should pass if the nonce is long enough",
+			v:     new(OpenIDConnectRequestValidator).WithNoncePolicy(true, 8, nil, 0),
+			nonce: "12345678",
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, tc.d), func(t *testing.T) {
+			err := tc.v.ValidateNonce(context.Background(), newRequest("foo", tc.nonce))
+			if tc.expectErr != nil {
+				assert.EqualError(t, err, tc.expectErr.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	t.Run("case=nonces can not be replayed", func(t *testing.T) {
+		v := new(OpenIDConnectRequestValidator).WithNoncePolicy(false, 0, storage.NewMemoryStore(), time.Minute)
+
+		require.NoError(t, v.ValidateNonce(context.Background(), newRequest("foo", "12345678")))
+		err := v.ValidateNonce(context.Background(), newRequest("foo", "12345678"))
+		require.EqualError(t, err, fosite.ErrInvalidRequest.Error())
+		assert.Equal(t, "Parameter 'nonce' has already been used by a previous authorization request.", fosite.ErrorToRFC6749Error(err).HintField)
+
+		assert.NoError(t, v.ValidateNonce(context.Background(), newRequest("bar", "12345678")), "nonces are tracked per client")
+		assert.NoError(t, v.ValidateNonce(context.Background(), newRequest("foo", "87654321")))
+	})
+
+	t.Run("case=nonces can be used again after their lifespan", func(t *testing.T) {
+		v := new(OpenIDConnectRequestValidator).WithNoncePolicy(false, 0, storage.NewMemoryStore(), time.Millisecond*10)
+
+		require.NoError(t, v.ValidateNonce(context.Background(), newRequest("foo", "12345678")))
+		time.Sleep(time.Millisecond * 20)
+		assert.NoError(t, v.ValidateNonce(context.Background(), newRequest("foo", "12345678")))
+	})
+}
@@ -0,0 +1,107 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package par
+
+import (
+	"context"
+	"encoding/base64"
+	"net/url"
+	"time"
+
+	"github.com/ory/x/errorsx"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/token/hmac"
+)
+
+const defaultPARKeyLength = 32
+
+var b64 = base64.URLEncoding.WithPadding(base64.NoPadding)
+
+// clientCredentialParameters are the form parameters used for client authentication at the pushed authorization
+// request endpoint. They are never persisted because the stored form is later merged into the authorization request.
+var clientCredentialParameters = []string{"client_secret", "client_assertion", "client_assertion_type"}
+
+// PushedAuthorizeHandler handles the PAR request
+type PushedAuthorizeHandler struct {
+	Storage fosite.PARStorage
+
+	// RequestURIPrefix is the prefix of issued request_uri values. Defaults to fosite.PushedAuthorizeRequestURIPrefix.
+	RequestURIPrefix string
+
+	// PushedAuthorizeContextLifespan defines how long the request_uri is valid.
+	PushedAuthorizeContextLifespan time.Duration
+}
+
+// HandlePushedAuthorizeEndpointRequest handles a pushed authorize endpoint request. To extend the handler's capabilities, the http request
+// is passed along, if further information retrieval is required. If the handler feels that he is not responsible for
+// the pushed authorize request, he must return nil and NOT modify session nor responder neither requester.
+func (c *PushedAuthorizeHandler) HandlePushedAuthorizeEndpointRequest(ctx context.Context, ar fosite.AuthorizeRequester, resp fosite.PushedAuthorizeResponder) error {
+	prefix := c.RequestURIPrefix
+	if prefix == "" {
+		prefix = fosite.PushedAuthorizeRequestURIPrefix
+	}
+
+	key, err := hmac.RandomBytes(defaultPARKeyLength)
+	if err != nil {
+		return errorsx.WithStack(fosite.ErrServerError.WithHint("Unable to generate the random part of the request_uri.").WithWrap(err).WithDebug(err.Error()))
+	}
+	requestURI := prefix + b64.EncodeToString(key)
+
+	expiresAt := time.Now().UTC().Add(c.PushedAuthorizeContextLifespan).Round(time.Second)
+	ar.GetSession().SetExpiresAt(fosite.PushedAuthorizeRequestContext, expiresAt)
+
+	if err := fosite.StorageOperation(ctx, "CreatePARSession", func(ctx context.Context) error {
+		return c.Storage.CreatePARSession(ctx, requestURI, withoutClientCredentials(ar))
+	}); err != nil {
+		return errorsx.WithStack(fosite.ErrServerError.WithHint("Unable to store the pushed authorization request.").WithWrap(err).WithDebug(err.Error()))
+	}
+
+	resp.SetRequestURI(requestURI)
+	resp.SetExpiresIn(time.Duration(expiresAt.UnixNano() - time.Now().UTC().UnixNano()))
+	return nil
+}
+
+// withoutClientCredentials returns a copy of the request whose form does not contain the client credentials.
+func withoutClientCredentials(ar fosite.AuthorizeRequester) fosite.AuthorizeRequester {
+	form := url.Values{}
+	for k, v := range ar.GetRequestForm() {
+		form[k] = append([]string{}, v...)
+	}
+	for _, k := range clientCredentialParameters {
+		form.Del(k)
+	}
+
+	if r, ok := ar.(*fosite.AuthorizeRequest); ok {
+		// Make sure the copy shares the request ID, which is otherwise generated lazily.
+		_ = r.GetID()
+		stored := *r
+		stored.Form = form
+		return &stored
+	}
+
+	// Unknown implementations do not allow replacing the form, so the credentials are removed from the request itself.
+	for _, k := range clientCredentialParameters {
+		ar.GetRequestForm().Del(k)
+	}
+	return ar
+}
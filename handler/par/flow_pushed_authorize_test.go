@@ -0,0 +1,66 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package par
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/storage"
+)
+
+func TestHandlePushedAuthorizeEndpointRequest(t *testing.T) {
+	store := storage.NewMemoryStore()
+	h := &PushedAuthorizeHandler{
+		Storage:                        store,
+		RequestURIPrefix:               "urn:example:",
+		PushedAuthorizeContextLifespan: time.Minute,
+	}
+
+	ar := fosite.NewAuthorizeRequest()
+	ar.Session = new(fosite.DefaultSession)
+	ar.Form = url.Values{"client_id": {"foo"}, "client_secret": {"bar"}, "client_assertion": {"baz"}, "client_assertion_type": {"qux"}, "scope": {"openid"}}
+	resp := fosite.NewPushedAuthorizeResponse()
+
+	require.NoError(t, h.HandlePushedAuthorizeEndpointRequest(context.Background(), ar, resp))
+	assert.True(t, strings.HasPrefix(resp.GetRequestURI(), "urn:example:"))
+	assert.InDelta(t, time.Minute.Seconds(), resp.GetExpiresIn().Seconds(), 2)
+	assert.WithinDuration(t, time.Now().UTC().Add(time.Minute), ar.GetSession().GetExpiresAt(fosite.PushedAuthorizeRequestContext), 2*time.Second)
+
+	stored, err := store.GetPARSession(context.Background(), resp.GetRequestURI())
+	require.NoError(t, err)
+	assert.Equal(t, ar.GetID(), stored.GetID())
+	assert.Equal(t, url.Values{"client_id": {"foo"}, "scope": {"openid"}}, stored.GetRequestForm())
+	assert.Equal(t, "bar", ar.GetRequestForm().Get("client_secret"))
+
+	other := fosite.NewPushedAuthorizeResponse()
+	require.NoError(t, (&PushedAuthorizeHandler{Storage: store}).HandlePushedAuthorizeEndpointRequest(context.Background(), ar, other))
+	assert.True(t, strings.HasPrefix(other.GetRequestURI(), fosite.PushedAuthorizeRequestURIPrefix))
+	assert.NotEqual(t, resp.GetRequestURI(), other.GetRequestURI())
+}
@@ -42,7 +42,11 @@ type Handler struct {
 	// If set to true, public clients must use PKCE.
 	ForceForPublicClients bool
 
+	// If set to true, confidential clients must use PKCE.
+	ForceForConfidentialClients bool
+
 	// Whether or not to allow the plain challenge method (S256 should be used whenever possible, plain is really discouraged).
+	// Clients implementing fosite.PKCEClient may reject the plain challenge method even if it is enabled.
 	EnablePlainChallengeMethod bool
 
 	AuthorizeCodeStrategy oauth2.AuthorizeCodeStrategy
@@ -71,10 +75,12 @@ func (c *Handler) HandleAuthorizeEndpointRequest(ctx context.Context, ar fosite.
 	}
 
 	signature := c.AuthorizeCodeStrategy.AuthorizeCodeSignature(code)
-	if err := c.Storage.CreatePKCERequestSession(ctx, signature, ar.Sanitize([]string{
-		"code_challenge",
-		"code_challenge_method",
-	})); err != nil {
+	if err := fosite.StorageOperation(ctx, "CreatePKCERequestSession", func(ctx context.Context) error {
+		return c.Storage.CreatePKCERequestSession(ctx, signature, ar.Sanitize([]string{
+			"code_challenge",
+			"code_challenge_method",
+		}))
+	}); err != nil {
 		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
 	}
 
@@ -94,7 +100,7 @@ func (c *Handler) validate(challenge, method string, client fosite.Client) error
 				WithHint("Clients must include a code_challenge when performing the authorize code flow, but it is missing.").
 				WithDebug("The server is configured in a way that enforces PKCE for clients."))
 		}
-		if c.ForceForPublicClients && client.IsPublic() {
+		if c.isRequiredForClient(client) {
 			return errorsx.WithStack(fosite.ErrInvalidRequest.
 				WithHint("This client must include a code_challenge when performing the authorize code flow, but it is missing.").
 				WithDebug("The server is configured in a way that enforces PKCE for this client."))
@@ -119,6 +125,11 @@ func (c *Handler) validate(challenge, method string, client fosite.Client) error
 				WithHint("Clients must use code_challenge_method=S256, plain is not allowed.").
 				WithDebug("The server is configured in a way that enforces PKCE S256 as challenge method for clients."))
 		}
+		if pc, ok := client.(fosite.PKCEClient); ok && pc.RejectPlainPKCEChallengeMethod() {
+			return errorsx.WithStack(fosite.ErrInvalidRequest.
+				WithHint("This client must use code_challenge_method=S256, plain is not allowed.").
+				WithDebug("The client is configured in a way that enforces PKCE S256 as challenge method."))
+		}
 	default:
 		return errorsx.WithStack(fosite.ErrInvalidRequest.
 			WithHint("The code_challenge_method is not supported, use S256 instead."))
@@ -126,6 +137,17 @@ func (c *Handler) validate(challenge, method string, client fosite.Client) error
 	return nil
 }
 
+// isRequiredForClient returns true if PKCE is required for the client because of its type or its own policy.
+func (c *Handler) isRequiredForClient(client fosite.Client) bool {
+	if c.ForceForPublicClients && client.IsPublic() {
+		return true
+	} else if c.ForceForConfidentialClients && !client.IsPublic() {
+		return true
+	}
+	pc, ok := client.(fosite.PKCEClient)
+	return ok && pc.RequirePKCE()
+}
+
 func (c *Handler) HandleTokenEndpointRequest(ctx context.Context, request fosite.AccessRequester) error {
 	if !c.CanHandleTokenEndpointRequest(request) {
 		return errorsx.WithStack(fosite.ErrUnknownRequest)
@@ -141,14 +163,20 @@ func (c *Handler) HandleTokenEndpointRequest(ctx context.Context, request fosite
 
 	code := request.GetRequestForm().Get("code")
 	signature := c.AuthorizeCodeStrategy.AuthorizeCodeSignature(code)
-	authorizeRequest, err := c.Storage.GetPKCERequestSession(ctx, signature, request.GetSession())
+	var authorizeRequest fosite.Requester
+	err := fosite.StorageOperation(ctx, "GetPKCERequestSession", func(ctx context.Context) (err error) {
+		authorizeRequest, err = c.Storage.GetPKCERequestSession(ctx, signature, request.GetSession())
+		return err
+	})
 	if errors.Is(err, fosite.ErrNotFound) {
 		return errorsx.WithStack(fosite.ErrInvalidGrant.WithHint("Unable to find initial PKCE data tied to this request").WithWrap(err).WithDebug(err.Error()))
 	} else if err != nil {
 		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
 	}
 
-	if err := c.Storage.DeletePKCERequestSession(ctx, signature); err != nil {
+	if err := fosite.StorageOperation(ctx, "DeletePKCERequestSession", func(ctx context.Context) error {
+		return c.Storage.DeletePKCERequestSession(ctx, signature)
+	}); err != nil {
 		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
 	}
 
@@ -239,3 +267,10 @@ func (c *Handler) CanHandleTokenEndpointRequest(requester fosite.AccessRequester
 	// Value MUST be set to "authorization_code"
 	return requester.GetGrantTypes().ExactOne("authorization_code")
 }
+
+func (c *Handler) PopulateDiscoveryMetadata(ctx context.Context, metadata *fosite.DiscoveryMetadata) {
+	metadata.CodeChallengeMethodsSupported = append(metadata.CodeChallengeMethodsSupported, "S256")
+	if c.EnablePlainChallengeMethod {
+		metadata.CodeChallengeMethodsSupported = append(metadata.CodeChallengeMethodsSupported, "plain")
+	}
+}
@@ -283,11 +283,12 @@ func TestPKCEHandleTokenEndpointRequest(t *testing.T) {
 		d           string
 		force       bool
 		forcePublic bool
+		forcePriv   bool
 		enablePlain bool
 		challenge   string
 		method      string
 		expectErr   bool
-		client      *fosite.DefaultClient
+		client      fosite.Client
 	}{
 		{
 			d: "should pass because pkce is not enforced",
@@ -344,12 +345,50 @@ func TestPKCEHandleTokenEndpointRequest(t *testing.T) {
 			method:      "S256",
 			challenge:   "challenge",
 		},
+		{
+			d:         "should fail because forceConfidential is enabled, the client is confidential, and no challenge was given",
+			forcePriv: true,
+			client:    &fosite.DefaultClient{Public: false},
+			expectErr: true,
+		},
+		{
+			d:         "should pass because forceConfidential is enabled but the client is public",
+			forcePriv: true,
+			client:    &fosite.DefaultClient{Public: true},
+		},
+		{
+			d:         "should fail because the client requires PKCE and no challenge was given",
+			client:    &fosite.DefaultOpenIDConnectClient{DefaultClient: &fosite.DefaultClient{}, PKCERequired: true},
+			expectErr: true,
+		},
+		{
+			d:         "should pass because the client requires PKCE and a challenge was given",
+			client:    &fosite.DefaultOpenIDConnectClient{DefaultClient: &fosite.DefaultClient{}, PKCERequired: true},
+			method:    "S256",
+			challenge: "challenge",
+		},
+		{
+			d:           "should fail because the client rejects plain although it is enabled",
+			enablePlain: true,
+			client:      &fosite.DefaultOpenIDConnectClient{DefaultClient: &fosite.DefaultClient{}, PKCEPlainChallengeMethodRejected: true},
+			expectErr:   true,
+			method:      "plain",
+			challenge:   "challenge",
+		},
+		{
+			d:           "should pass because plain is enabled and the client does not reject it",
+			enablePlain: true,
+			client:      &fosite.DefaultOpenIDConnectClient{DefaultClient: &fosite.DefaultClient{}},
+			method:      "plain",
+			challenge:   "challenge",
+		},
 	} {
 		t.Run(fmt.Sprintf("case=%d/description=%s", k, tc.d), func(t *testing.T) {
 			h := &Handler{
-				Force:                      tc.force,
-				ForceForPublicClients:      tc.forcePublic,
-				EnablePlainChallengeMethod: tc.enablePlain,
+				Force:                       tc.force,
+				ForceForPublicClients:       tc.forcePublic,
+				ForceForConfidentialClients: tc.forcePriv,
+				EnablePlainChallengeMethod:  tc.enablePlain,
 			}
 
 			if tc.expectErr {
@@ -0,0 +1,188 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package rfc7522
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"time"
+
+	"github.com/ory/x/errorsx"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/oauth2"
+)
+
+const grantTypeSAML2Bearer = "urn:ietf:params:oauth:grant-type:saml2-bearer"
+
+// Handler implements SAML 2.0 bearer assertion grants as defined in https://tools.ietf.org/html/rfc7522#section-2.1.
+// Access tokens are issued for the subject of the assertion.
+type Handler struct {
+	// Validator verifies the signature of the assertions and parses their statements.
+	Validator AssertionValidator
+	// Storage, if set, is used to reject assertions which have already been used.
+	Storage AssertionStorage
+	// SessionMapper, if set, maps the statements of the assertion to the session.
+	SessionMapper SessionMapper
+
+	ScopeStrategy fosite.ScopeStrategy
+
+	// TokenURL is the the URL of the Authorization Server's Token Endpoint, which must be an audience of the assertion.
+	TokenURL string
+	// SkipClientAuth indicates, if client authentication can be skipped.
+	SkipClientAuth bool
+	// ClockSkew is the leeway allowed when checking the validity period of the assertion against the current time.
+	ClockSkew time.Duration
+
+	*oauth2.HandleHelper
+}
+
+// HandleTokenEndpointRequest implements https://tools.ietf.org/html/rfc7522#section-2.1 and
+// https://tools.ietf.org/html/rfc7522#section-3
+func (c *Handler) HandleTokenEndpointRequest(ctx context.Context, request fosite.AccessRequester) error {
+	if err := c.CheckRequest(request); err != nil {
+		return err
+	}
+
+	encoded := request.GetRequestForm().Get("assertion")
+	if encoded == "" {
+		return errorsx.WithStack(fosite.ErrInvalidRequest.WithHintf("The assertion request parameter must be set when using grant_type of '%s'.", grantTypeSAML2Bearer))
+	}
+
+	// The assertion is base64url encoded, but some identity providers add padding anyway.
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(encoded, "="))
+	if err != nil {
+		return errorsx.WithStack(fosite.ErrInvalidGrant.WithHint("Unable to decode the SAML 2.0 assertion passed in \"assertion\" request parameter, it must be base64url encoded.").WithWrap(err).WithDebug(err.Error()))
+	}
+
+	assertion, err := c.Validator.ValidateAssertion(ctx, raw, request)
+	if err != nil {
+		return errorsx.WithStack(fosite.ErrInvalidGrant.WithHint("Unable to validate the SAML 2.0 assertion passed in \"assertion\" request parameter.").WithWrap(err).WithDebug(err.Error()))
+	} else if err := c.validateAssertion(ctx, assertion); err != nil {
+		return err
+	}
+
+	client := request.GetClient()
+	for _, scope := range request.GetRequestedScopes() {
+		if assertion.Scopes != nil && !c.ScopeStrategy(assertion.Scopes, scope) {
+			return errorsx.WithStack(fosite.ErrInvalidScope.WithHintf("The SAML 2.0 assertion of issuer \"%s\" and subject \"%s\" is not allowed to request scope \"%s\".", assertion.Issuer, assertion.Subject, scope))
+		} else if !c.CanSkipClientAuth(request) && !fosite.ClientScopeStrategy(client, c.ScopeStrategy)(client.GetScopes(), scope) {
+			return errorsx.WithStack(fosite.ErrInvalidScope.WithHintf("The OAuth 2.0 Client is not allowed to request scope '%s'.", scope))
+		}
+	}
+
+	if c.Storage != nil && assertion.ID != "" {
+		if err := fosite.StorageOperation(ctx, "MarkAssertionUsedForTime", func(ctx context.Context) error {
+			return c.Storage.MarkAssertionUsedForTime(ctx, assertion.ID, assertion.NotOnOrAfter.Add(c.ClockSkew))
+		}); err != nil {
+			return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+		}
+	}
+
+	for _, scope := range request.GetRequestedScopes() {
+		request.GrantScope(scope)
+	}
+
+	session, ok := request.GetSession().(Session)
+	if !ok {
+		return errorsx.WithStack(fosite.ErrServerError.WithHintf("Session must implement rfc7522.Session but got type: %T", request.GetSession()))
+	}
+	session.SetSubject(assertion.Subject)
+	request.GetSession().SetExpiresAt(fosite.AccessToken, time.Now().UTC().Add(fosite.GetAccessTokenLifespan(ctx, c.HandleHelper.AccessTokenLifespan)).Round(time.Second))
+
+	if c.SessionMapper != nil {
+		if err := c.SessionMapper.MapAssertion(ctx, assertion, request); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Handler) validateAssertion(ctx context.Context, assertion *Assertion) error {
+	now := time.Now()
+	if assertion.Issuer == "" {
+		return errorsx.WithStack(fosite.ErrInvalidGrant.WithHint("The SAML 2.0 assertion in \"assertion\" request parameter MUST contain an <Issuer> element."))
+	} else if assertion.Subject == "" {
+		return errorsx.WithStack(fosite.ErrInvalidGrant.WithHint("The SAML 2.0 assertion in \"assertion\" request parameter MUST contain a <Subject> element identifying the principal."))
+	} else if !fosite.Arguments(assertion.Audience).Has(c.TokenURL) {
+		return errorsx.WithStack(fosite.ErrInvalidGrant.WithHintf("The SAML 2.0 assertion in \"assertion\" request parameter MUST contain an <AudienceRestriction> element with an <Audience> \"%s\" that identifies the authorization server as an intended audience.", c.TokenURL))
+	} else if assertion.NotOnOrAfter.IsZero() {
+		return errorsx.WithStack(fosite.ErrInvalidGrant.WithHint("The SAML 2.0 assertion in \"assertion\" request parameter MUST contain a NotOnOrAfter attribute limiting its validity."))
+	} else if !assertion.NotOnOrAfter.Add(c.ClockSkew).After(now) {
+		return errorsx.WithStack(fosite.ErrInvalidGrant.WithHint("The SAML 2.0 assertion in \"assertion\" request parameter expired."))
+	} else if !assertion.NotBefore.IsZero() && assertion.NotBefore.Add(-c.ClockSkew).After(now) {
+		return errorsx.WithStack(fosite.ErrInvalidGrant.WithHintf("The SAML 2.0 assertion in \"assertion\" request parameter MUST NOT be accepted before '%s'.", assertion.NotBefore.Format(time.RFC3339)))
+	}
+
+	if c.Storage != nil && assertion.ID != "" {
+		var used bool
+		err := fosite.StorageOperation(ctx, "IsAssertionUsed", func(ctx context.Context) (err error) {
+			used, err = c.Storage.IsAssertionUsed(ctx, assertion.ID)
+			return err
+		})
+		if err != nil {
+			return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+		} else if used {
+			return errorsx.WithStack(fosite.ErrInvalidGrant.WithHint("The SAML 2.0 assertion in \"assertion\" request parameter has already been used."))
+		}
+	}
+
+	return nil
+}
+
+func (c *Handler) PopulateTokenEndpointResponse(ctx context.Context, request fosite.AccessRequester, response fosite.AccessResponder) error {
+	if err := c.CheckRequest(request); err != nil {
+		return err
+	}
+
+	return c.IssueAccessToken(ctx, request, response)
+}
+
+func (c *Handler) CanSkipClientAuth(requester fosite.AccessRequester) bool {
+	return c.SkipClientAuth
+}
+
+func (c *Handler) CanHandleTokenEndpointRequest(requester fosite.AccessRequester) bool {
+	// grant_type REQUIRED.
+	// Value MUST be set to "urn:ietf:params:oauth:grant-type:saml2-bearer"
+	return requester.GetGrantTypes().ExactOne(grantTypeSAML2Bearer)
+}
+
+func (c *Handler) CheckRequest(request fosite.AccessRequester) error {
+	if !c.CanHandleTokenEndpointRequest(request) {
+		return errorsx.WithStack(fosite.ErrUnknownRequest)
+	}
+
+	// Client authentication is optional, see https://tools.ietf.org/html/rfc7522#section-2.1. If the client is
+	// authenticated, it must be allowed to use the grant type.
+	if !c.CanSkipClientAuth(request) && !request.GetClient().GetGrantTypes().Has(grantTypeSAML2Bearer) {
+		return errorsx.WithStack(fosite.ErrUnauthorizedClient.WithHintf("The OAuth 2.0 Client is not allowed to use authorization grant \"%s\".", grantTypeSAML2Bearer))
+	}
+
+	return nil
+}
+
+func (c *Handler) PopulateDiscoveryMetadata(ctx context.Context, metadata *fosite.DiscoveryMetadata) {
+	metadata.GrantTypesSupported = append(metadata.GrantTypesSupported, grantTypeSAML2Bearer)
+}
@@ -0,0 +1,207 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package rfc7522
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/oauth2"
+	"github.com/ory/fosite/storage"
+)
+
+func TestHandleTokenEndpointRequest(t *testing.T) {
+	const tokenURL = "https://www.example.com/token"
+	newAssertion := func() *Assertion {
+		return &Assertion{
+			ID:           "_a75adf55-01d7-40cc-929f-dbd8372ebdfc",
+			Issuer:       "https://idp.example.com",
+			Subject:      "brian@example.com",
+			Audience:     []string{tokenURL},
+			NotOnOrAfter: time.Now().Add(time.Minute),
+			Attributes:   map[string]interface{}{"department": "sales"},
+		}
+	}
+
+	for k, c := range []struct {
+		d         string
+		setup     func(h *Handler, ar *fosite.AccessRequest, assertion *Assertion)
+		expectErr error
+	}{
+		{
+			d: "should pass",
+		},
+		{
+			d: "should fail because the grant type is not supported",
+			setup: func(h *Handler, ar *fosite.AccessRequest, assertion *Assertion) {
+				ar.GrantTypes = fosite.Arguments{"password"}
+			},
+			expectErr: fosite.ErrUnknownRequest,
+		},
+		{
+			d: "should fail because the client may not use the grant",
+			setup: func(h *Handler, ar *fosite.AccessRequest, assertion *Assertion) {
+				ar.Client = &fosite.DefaultClient{}
+			},
+			expectErr: fosite.ErrUnauthorizedClient,
+		},
+		{
+			d: "should pass without client authentication if allowed",
+			setup: func(h *Handler, ar *fosite.AccessRequest, assertion *Assertion) {
+				ar.Client = &fosite.DefaultClient{}
+				h.SkipClientAuth = true
+			},
+		},
+		{
+			d: "should fail because the assertion is missing",
+			setup: func(h *Handler, ar *fosite.AccessRequest, assertion *Assertion) {
+				ar.Form.Del("assertion")
+			},
+			expectErr: fosite.ErrInvalidRequest,
+		},
+		{
+			d: "should fail because the assertion is not base64url encoded",
+			setup: func(h *Handler, ar *fosite.AccessRequest, assertion *Assertion) {
+				ar.Form.Set("assertion", "<saml:Assertion/>")
+			},
+			expectErr: fosite.ErrInvalidGrant,
+		},
+		{
+			d: "should fail because the validator rejected the assertion",
+			setup: func(h *Handler, ar *fosite.AccessRequest, assertion *Assertion) {
+				h.Validator = AssertionValidatorFunc(func(context.Context, []byte, fosite.AccessRequester) (*Assertion, error) {
+					return nil, errors.New("invalid signature")
+				})
+			},
+			expectErr: fosite.ErrInvalidGrant,
+		},
+		{
+			d: "should fail because the audience does not match",
+			setup: func(h *Handler, ar *fosite.AccessRequest, assertion *Assertion) {
+				assertion.Audience = []string{"https://www.example.com/other"}
+			},
+			expectErr: fosite.ErrInvalidGrant,
+		},
+		{
+			d: "should fail because the assertion expired",
+			setup: func(h *Handler, ar *fosite.AccessRequest, assertion *Assertion) {
+				assertion.NotOnOrAfter = time.Now().Add(-time.Minute)
+			},
+			expectErr: fosite.ErrInvalidGrant,
+		},
+		{
+			d: "should pass because the assertion expired within the clock skew",
+			setup: func(h *Handler, ar *fosite.AccessRequest, assertion *Assertion) {
+				assertion.NotOnOrAfter = time.Now().Add(-time.Minute)
+				h.ClockSkew = time.Minute * 5
+			},
+		},
+		{
+			d: "should fail because the assertion is not valid yet",
+			setup: func(h *Handler, ar *fosite.AccessRequest, assertion *Assertion) {
+				assertion.NotBefore = time.Now().Add(time.Minute)
+			},
+			expectErr: fosite.ErrInvalidGrant,
+		},
+		{
+			d: "should fail because the subject is missing",
+			setup: func(h *Handler, ar *fosite.AccessRequest, assertion *Assertion) {
+				assertion.Subject = ""
+			},
+			expectErr: fosite.ErrInvalidGrant,
+		},
+		{
+			d: "should fail because the assertion has already been used",
+			setup: func(h *Handler, ar *fosite.AccessRequest, assertion *Assertion) {
+				require.NoError(t, h.Storage.MarkAssertionUsedForTime(context.Background(), assertion.ID, assertion.NotOnOrAfter))
+			},
+			expectErr: fosite.ErrInvalidGrant,
+		},
+		{
+			d: "should fail because the assertion does not allow the scope",
+			setup: func(h *Handler, ar *fosite.AccessRequest, assertion *Assertion) {
+				assertion.Scopes = []string{"bar"}
+			},
+			expectErr: fosite.ErrInvalidScope,
+		},
+		{
+			d: "should fail because the client does not allow the scope",
+			setup: func(h *Handler, ar *fosite.AccessRequest, assertion *Assertion) {
+				ar.Client.(*fosite.DefaultClient).Scopes = []string{"bar"}
+			},
+			expectErr: fosite.ErrInvalidScope,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			assertion := newAssertion()
+			h := &Handler{
+				Storage:       storage.NewMemoryStore(),
+				ScopeStrategy: fosite.HierarchicScopeStrategy,
+				TokenURL:      tokenURL,
+				SessionMapper: SessionMapperFunc(func(_ context.Context, assertion *Assertion, requester fosite.AccessRequester) error {
+					requester.GetSession().(*fosite.DefaultSession).Extra = assertion.Attributes
+					return nil
+				}),
+				HandleHelper: &oauth2.HandleHelper{AccessTokenLifespan: time.Hour},
+			}
+			h.Validator = AssertionValidatorFunc(func(_ context.Context, raw []byte, _ fosite.AccessRequester) (*Assertion, error) {
+				assert.Equal(t, "<saml:Assertion/>", string(raw))
+				return assertion, nil
+			})
+
+			ar := fosite.NewAccessRequest(&fosite.DefaultSession{})
+			ar.GrantTypes = fosite.Arguments{grantTypeSAML2Bearer}
+			ar.Client = &fosite.DefaultClient{GrantTypes: fosite.Arguments{grantTypeSAML2Bearer}, Scopes: fosite.Arguments{"foo"}}
+			ar.RequestedScope = fosite.Arguments{"foo"}
+			ar.Form = url.Values{"assertion": {base64.RawURLEncoding.EncodeToString([]byte("<saml:Assertion/>"))}}
+			if c.setup != nil {
+				c.setup(h, ar, assertion)
+			}
+
+			err := h.HandleTokenEndpointRequest(context.Background(), ar)
+			if c.expectErr != nil {
+				require.Error(t, err)
+				assert.True(t, errors.Is(err, c.expectErr), "%+v", err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, "brian@example.com", ar.GetSession().GetSubject())
+			assert.Equal(t, fosite.Arguments{"foo"}, ar.GetGrantedScopes())
+			assert.Equal(t, "sales", ar.GetSession().(*fosite.DefaultSession).Extra["department"])
+			assert.False(t, ar.GetSession().GetExpiresAt(fosite.AccessToken).IsZero())
+
+			used, err := h.Storage.IsAssertionUsed(context.Background(), assertion.ID)
+			require.NoError(t, err)
+			assert.True(t, used, "the assertion must not be usable twice")
+		})
+	}
+}
@@ -0,0 +1,37 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package rfc7522
+
+import (
+	"context"
+	"time"
+)
+
+// AssertionStorage keeps track of the SAML 2.0 assertions which have been used, so that they can not be replayed.
+type AssertionStorage interface {
+	// IsAssertionUsed returns true, if the assertion with the given ID has already been used.
+	IsAssertionUsed(ctx context.Context, id string) (bool, error)
+
+	// MarkAssertionUsedForTime marks the assertion with the given ID as used until exp, after which the assertion
+	// is rejected anyway because it expired.
+	MarkAssertionUsedForTime(ctx context.Context, id string, exp time.Time) error
+}
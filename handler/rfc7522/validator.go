@@ -0,0 +1,88 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package rfc7522
+
+import (
+	"context"
+	"time"
+
+	"github.com/ory/fosite"
+)
+
+// Assertion holds the statements of a validated SAML 2.0 assertion, see
+// https://tools.ietf.org/html/rfc7522#section-3.
+type Assertion struct {
+	// ID is the ID attribute of the assertion, which is used to prevent replays.
+	ID string
+
+	// Issuer is the <Issuer> of the assertion, identifying the identity provider.
+	Issuer string
+
+	// Subject is the <NameID> of the assertion's <Subject>, which becomes the subject of the issued access token.
+	Subject string
+
+	// Audience contains the values of the <AudienceRestriction> conditions.
+	Audience []string
+
+	// NotBefore is the NotBefore attribute of the <Conditions>, if any.
+	NotBefore time.Time
+
+	// NotOnOrAfter is the NotOnOrAfter attribute of the <SubjectConfirmationData> with Method
+	// "urn:oasis:names:tc:SAML:2.0:cm:bearer", or of the <Conditions> if earlier.
+	NotOnOrAfter time.Time
+
+	// Scopes, if not nil, restricts the scopes which can be requested using the assertion.
+	Scopes []string
+
+	// Attributes holds the values of the assertion's <AttributeStatement>, if any.
+	Attributes map[string]interface{}
+}
+
+// AssertionValidator verifies and parses SAML 2.0 assertions, typically using a SAML library and the metadata of the
+// trusted identity providers.
+type AssertionValidator interface {
+	// ValidateAssertion verifies the signature of the decoded assertion, checks it was issued by a trusted identity
+	// provider and evaluates any conditions the handler does not know about. The issuer, subject, audience, validity
+	// period and replay of the returned assertion are checked by the handler.
+	ValidateAssertion(ctx context.Context, assertion []byte, requester fosite.AccessRequester) (*Assertion, error)
+}
+
+// AssertionValidatorFunc is a function implementing AssertionValidator.
+type AssertionValidatorFunc func(ctx context.Context, assertion []byte, requester fosite.AccessRequester) (*Assertion, error)
+
+func (f AssertionValidatorFunc) ValidateAssertion(ctx context.Context, assertion []byte, requester fosite.AccessRequester) (*Assertion, error) {
+	return f(ctx, assertion, requester)
+}
+
+// SessionMapper maps the statements of a validated assertion, for example its attributes, to the session of the
+// access request.
+type SessionMapper interface {
+	// MapAssertion is called after the session's subject and expiry were set.
+	MapAssertion(ctx context.Context, assertion *Assertion, requester fosite.AccessRequester) error
+}
+
+// SessionMapperFunc is a function implementing SessionMapper.
+type SessionMapperFunc func(ctx context.Context, assertion *Assertion, requester fosite.AccessRequester) error
+
+func (f SessionMapperFunc) MapAssertion(ctx context.Context, assertion *Assertion, requester fosite.AccessRequester) error {
+	return f(ctx, assertion, requester)
+}
@@ -0,0 +1,172 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package rfc7523
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ory/x/errorsx"
+	"github.com/pkg/errors"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"github.com/ory/fosite"
+)
+
+const clientAssertionTypeJWTBearer = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+// ClientAuthenticationHandler authenticates clients using JWT bearer client assertions as defined in
+// https://tools.ietf.org/html/rfc7523#section-2.2, whereas Handler implements JWT bearer authorization grants as
+// defined in https://tools.ietf.org/html/rfc7523#section-2.1.
+//
+// The assertions are verified using the keys of ClientAssertionKeyStorage, so clients do not need to be OpenID
+// Connect clients with registered JSON Web Keys, which suits service-to-service authentication. Requests of clients
+// without keys in the storage are left to the next handler, for example fosite.PrivateKeyJWTClientAuthenticationHandler.
+type ClientAuthenticationHandler struct {
+	Storage ClientAssertionKeyStorage
+
+	// TokenURL is the URL of the Authorization Server's Token Endpoint, which must be an audience of the assertion.
+	// Defaults to the TokenURL of the provider.
+	TokenURL string
+	// JWTIDOptional indicates, if jti (JWT ID) claim required or not.
+	JWTIDOptional bool
+	// JWTMaxDuration, if set, sets the maximum time between the "iat" (issued at) and the "exp" (expiration time)
+	// claims of the assertion.
+	JWTMaxDuration time.Duration
+	// JWTClockSkew is the leeway allowed when checking the "exp" and "nbf" claims against the current time.
+	JWTClockSkew time.Duration
+}
+
+func (h *ClientAuthenticationHandler) AuthenticateClient(ctx context.Context, f *fosite.Fosite, r *http.Request, form url.Values) (fosite.Client, error) {
+	if form.Get("client_assertion_type") != clientAssertionTypeJWTBearer || form.Get("client_assertion") == "" {
+		return nil, errorsx.WithStack(fosite.ErrUnknownRequest)
+	}
+
+	token, err := jwt.ParseSigned(form.Get("client_assertion"))
+	if err != nil {
+		return nil, errorsx.WithStack(fosite.ErrInvalidClient.WithHint("Unable to parse the JSON Web Token passed in 'client_assertion' request parameter.").WithWrap(err).WithDebug(err.Error()))
+	}
+
+	unverifiedClaims := jwt.Claims{}
+	if err := token.UnsafeClaimsWithoutVerification(&unverifiedClaims); err != nil {
+		return nil, errorsx.WithStack(fosite.ErrInvalidClient.WithHint("Unable to read the claims of the 'client_assertion' JSON Web Token.").WithWrap(err).WithDebug(err.Error()))
+	}
+
+	clientID := form.Get("client_id")
+	if clientID == "" {
+		clientID = unverifiedClaims.Subject
+	}
+	if clientID == "" {
+		return nil, errorsx.WithStack(fosite.ErrInvalidClient.WithHint("The claim 'sub' from the client_assertion JSON Web Token is undefined."))
+	}
+
+	var keys *jose.JSONWebKeySet
+	err = fosite.StorageOperation(ctx, "GetClientAssertionKeys", func(ctx context.Context) (err error) {
+		keys, err = h.Storage.GetClientAssertionKeys(ctx, clientID)
+		return err
+	})
+	if errors.Is(err, fosite.ErrNotFound) || (err == nil && (keys == nil || len(keys.Keys) == 0)) {
+		return nil, errorsx.WithStack(fosite.ErrUnknownRequest)
+	} else if err != nil {
+		return nil, errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	key := findVerifyingKey(token, keys)
+	if key == nil {
+		return nil, errorsx.WithStack(fosite.ErrInvalidClient.WithHint("Unable to verify the integrity of the 'client_assertion' value."))
+	}
+
+	claims := jwt.Claims{}
+	if err := token.Claims(key, &claims); err != nil {
+		return nil, errorsx.WithStack(fosite.ErrInvalidClient.WithHint("Unable to verify the integrity of the 'client_assertion' value.").WithWrap(err).WithDebug(err.Error()))
+	} else if err := h.validateClaims(ctx, f, clientID, claims); err != nil {
+		return nil, err
+	}
+
+	var client fosite.Client
+	err = fosite.StorageOperation(ctx, "GetClient", func(ctx context.Context) (err error) {
+		client, err = f.Store.GetClient(ctx, clientID)
+		return err
+	})
+	if errors.Is(err, context.DeadlineExceeded) {
+		return nil, errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	} else if err != nil {
+		return nil, errorsx.WithStack(fosite.ErrInvalidClient.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	if client.IsPublic() {
+		return nil, errorsx.WithStack(fosite.ErrInvalidClient.WithHint("The OAuth 2.0 Client is public and can not authenticate using a 'client_assertion'."))
+	} else if mc, ok := client.(fosite.TokenEndpointAuthMethodClient); ok && mc.GetTokenEndpointAuthMethod() != "private_key_jwt" {
+		return nil, errorsx.WithStack(fosite.ErrInvalidClient.WithHintf("The OAuth 2.0 Client supports client authentication method '%s', but method 'private_key_jwt' was requested.", mc.GetTokenEndpointAuthMethod()))
+	}
+
+	if claims.ID != "" {
+		if err := fosite.StorageOperation(ctx, "SetClientAssertionJWT", func(ctx context.Context) error {
+			return f.Store.SetClientAssertionJWT(ctx, claims.ID, claims.Expiry.Time())
+		}); err != nil {
+			return nil, errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+		}
+	}
+
+	return client, nil
+}
+
+func (h *ClientAuthenticationHandler) validateClaims(ctx context.Context, f *fosite.Fosite, clientID string, claims jwt.Claims) error {
+	tokenURL := h.TokenURL
+	if tokenURL == "" {
+		tokenURL = f.TokenURL
+	}
+
+	now := time.Now()
+	if claims.Issuer != clientID {
+		return errorsx.WithStack(fosite.ErrInvalidClient.WithHint("Claim 'iss' from 'client_assertion' must match the 'client_id' of the OAuth 2.0 Client."))
+	} else if claims.Subject != clientID {
+		return errorsx.WithStack(fosite.ErrInvalidClient.WithHint("Claim 'sub' from 'client_assertion' must match the 'client_id' of the OAuth 2.0 Client."))
+	} else if tokenURL == "" {
+		return errorsx.WithStack(fosite.ErrMisconfiguration.WithHint("The authorization server's token endpoint URL has not been set."))
+	} else if !claims.Audience.Contains(tokenURL) {
+		return errorsx.WithStack(fosite.ErrInvalidClient.WithHintf("Claim 'audience' from 'client_assertion' must match the authorization server's token endpoint '%s'.", tokenURL))
+	} else if claims.Expiry == nil {
+		return errorsx.WithStack(fosite.ErrInvalidClient.WithHint("Claim 'exp' from 'client_assertion' must be set but is not."))
+	} else if claims.Expiry.Time().Add(h.JWTClockSkew).Before(now) {
+		return errorsx.WithStack(fosite.ErrInvalidClient.WithHint("The 'client_assertion' expired."))
+	} else if claims.NotBefore != nil && claims.NotBefore.Time().Add(-h.JWTClockSkew).After(now) {
+		return errorsx.WithStack(fosite.ErrInvalidClient.WithHint("The 'client_assertion' is not valid yet."))
+	} else if h.JWTMaxDuration > 0 && claims.IssuedAt != nil && claims.Expiry.Time().Sub(claims.IssuedAt.Time()) > h.JWTMaxDuration {
+		return errorsx.WithStack(fosite.ErrInvalidClient.WithHint("Claim 'exp' from 'client_assertion' is unreasonably far in the future."))
+	} else if !h.JWTIDOptional && claims.ID == "" {
+		return errorsx.WithStack(fosite.ErrInvalidClient.WithHint("Claim 'jti' from 'client_assertion' must be set but is not."))
+	}
+
+	if claims.ID != "" {
+		if err := fosite.StorageOperation(ctx, "ClientAssertionJWTValid", func(ctx context.Context) error {
+			return f.Store.ClientAssertionJWTValid(ctx, claims.ID)
+		}); err != nil {
+			return errorsx.WithStack(fosite.ErrJTIKnown.WithHint("Claim 'jti' from 'client_assertion' MUST only be used once."))
+		}
+	}
+
+	return nil
+}
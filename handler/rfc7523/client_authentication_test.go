@@ -0,0 +1,148 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package rfc7523
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/storage"
+)
+
+func TestClientAuthenticationHandler(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+
+	store := storage.NewMemoryStore()
+	store.Clients["service"] = &fosite.DefaultClient{ID: "service"}
+	store.Clients["public"] = &fosite.DefaultClient{ID: "public", Public: true}
+	store.Clients["basic"] = &fosite.DefaultOpenIDConnectClient{DefaultClient: &fosite.DefaultClient{ID: "basic"}, TokenEndpointAuthMethod: "client_secret_basic"}
+	for _, id := range []string{"service", "public", "basic"} {
+		store.ClientAssertionKeys[id] = &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{KeyID: "kid", Key: &key.PublicKey}}}
+	}
+
+	f := &fosite.Fosite{Store: store, TokenURL: "https://www.example.com/token"}
+	h := &ClientAuthenticationHandler{Storage: store}
+
+	newForm := func(k *rsa.PrivateKey, claims jwt.Claims) url.Values {
+		sig, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: jose.JSONWebKey{Key: k, KeyID: "kid"}}, (&jose.SignerOptions{}).WithType("JWT"))
+		require.NoError(t, err)
+		raw, err := jwt.Signed(sig).Claims(claims).CompactSerialize()
+		require.NoError(t, err)
+		return url.Values{"client_assertion_type": {clientAssertionTypeJWTBearer}, "client_assertion": {raw}}
+	}
+	newClaims := func(clientID, jti string) jwt.Claims {
+		return jwt.Claims{
+			Issuer:   clientID,
+			Subject:  clientID,
+			Audience: jwt.Audience{"https://www.example.com/token"},
+			Expiry:   jwt.NewNumericDate(time.Now().Add(time.Minute)),
+			ID:       jti,
+		}
+	}
+
+	for k, c := range []struct {
+		d         string
+		form      url.Values
+		expectErr error
+	}{
+		{d: "should authenticate the client", form: newForm(key, newClaims("service", "1"))},
+		{d: "should not allow to replay the assertion", form: newForm(key, newClaims("service", "1")), expectErr: fosite.ErrJTIKnown},
+		{d: "should skip requests without client assertion", form: url.Values{"client_id": {"service"}}, expectErr: fosite.ErrUnknownRequest},
+		{d: "should skip clients without client assertion keys", form: newForm(key, newClaims("unknown", "2")), expectErr: fosite.ErrUnknownRequest},
+		{d: "should fail if the assertion was signed with another key", form: newForm(otherKey, newClaims("service", "3")), expectErr: fosite.ErrInvalidClient},
+		{d: "should fail without jti", form: newForm(key, newClaims("service", "")), expectErr: fosite.ErrInvalidClient},
+		{
+			d: "should fail if the audience does not match",
+			form: func() url.Values {
+				claims := newClaims("service", "4")
+				claims.Audience = jwt.Audience{"https://www.example.com/other"}
+				return newForm(key, claims)
+			}(),
+			expectErr: fosite.ErrInvalidClient,
+		},
+		{
+			d: "should fail if the assertion expired",
+			form: func() url.Values {
+				claims := newClaims("service", "5")
+				claims.Expiry = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+				return newForm(key, claims)
+			}(),
+			expectErr: fosite.ErrInvalidClient,
+		},
+		{d: "should fail for public clients", form: newForm(key, newClaims("public", "6")), expectErr: fosite.ErrInvalidClient},
+		{d: "should fail if the client registered another authentication method", form: newForm(key, newClaims("basic", "7")), expectErr: fosite.ErrInvalidClient},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			client, err := h.AuthenticateClient(context.Background(), f, new(http.Request), c.form)
+			if c.expectErr != nil {
+				require.Error(t, err)
+				assert.True(t, errors.Is(err, c.expectErr), "%+v", err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "service", client.GetID())
+		})
+	}
+}
+
+func TestClientAuthenticationHandlerIsTriedBeforeDefaultHandlers(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+
+	store := storage.NewMemoryStore()
+	store.Clients["service"] = &fosite.DefaultClient{ID: "service"}
+	store.ClientAssertionKeys["service"] = &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{KeyID: "kid", Key: &key.PublicKey}}}
+
+	f := &fosite.Fosite{Store: store, TokenURL: "https://www.example.com/token"}
+	f.ClientAuthenticationHandlers = append(fosite.ClientAuthenticationHandlers{&ClientAuthenticationHandler{Storage: store}}, fosite.DefaultClientAuthenticationHandlers()...)
+
+	sig, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: jose.JSONWebKey{Key: key, KeyID: "kid"}}, (&jose.SignerOptions{}).WithType("JWT"))
+	require.NoError(t, err)
+	raw, err := jwt.Signed(sig).Claims(jwt.Claims{
+		Issuer:   "service",
+		Subject:  "service",
+		Audience: jwt.Audience{"https://www.example.com/token"},
+		Expiry:   jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		ID:       "jti",
+	}).CompactSerialize()
+	require.NoError(t, err)
+
+	client, err := f.AuthenticateClient(context.Background(), new(http.Request), url.Values{"client_assertion_type": {clientAssertionTypeJWTBearer}, "client_assertion": {raw}})
+	require.NoError(t, err)
+	assert.Equal(t, "service", client.GetID())
+}
@@ -36,6 +36,9 @@ import (
 
 const grantTypeJWTBearer = "urn:ietf:params:oauth:grant-type:jwt-bearer"
 
+// Handler implements JWT bearer authorization grants as defined in https://tools.ietf.org/html/rfc7523#section-2.1.
+// Access tokens are issued for the subject of the assertion rather than for the client, see
+// ClientAuthenticationHandler for authenticating clients using JWT bearer assertions instead.
 type Handler struct {
 	Storage                  RFC7523KeyStorage
 	ScopeStrategy            fosite.ScopeStrategy
@@ -52,6 +55,18 @@ type Handler struct {
 	// JWTMaxDuration sets the maximum time after token issued date (if present), during which the token is
 	// considered valid. If "iat" claim is not present, then current time will be used as issued date.
 	JWTMaxDuration time.Duration
+	// JWTMaxIssuedAge, if set, is the maximum age of the "iat" (issued at) claim, if present, at the time the
+	// assertion is used.
+	JWTMaxIssuedAge time.Duration
+	// JWTClockSkew is the leeway allowed when checking the "exp", "nbf" and "iat" claims against the current time.
+	JWTClockSkew time.Duration
+	// JWTSingleAudience indicates, if the "aud" (audience) claim must contain the token URL as its only value.
+	JWTSingleAudience bool
+	// AllowUnregisteredScopes indicates, if scopes can be requested which are not registered for the public key
+	// which signed the assertion.
+	AllowUnregisteredScopes bool
+	// KeyCache, if set, caches the public keys fetched from Storage for an issuer and subject.
+	KeyCache fosite.JSONWebKeyCache
 
 	*oauth2.HandleHelper
 }
@@ -98,19 +113,35 @@ func (c *Handler) HandleTokenEndpointRequest(ctx context.Context, request fosite
 		return err
 	}
 
-	scopes, err := c.Storage.GetPublicKeyScopes(ctx, claims.Issuer, claims.Subject, key.KeyID)
+	var scopes []string
+	err = fosite.StorageOperation(ctx, "GetPublicKeyScopes", func(ctx context.Context) (err error) {
+		scopes, err = c.Storage.GetPublicKeyScopes(ctx, claims.Issuer, claims.Subject, key.KeyID)
+		return err
+	})
 	if err != nil {
 		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
 	}
 
 	for _, scope := range request.GetRequestedScopes() {
-		if !c.ScopeStrategy(scopes, scope) {
+		if !c.AllowUnregisteredScopes && !c.ScopeStrategy(scopes, scope) {
 			return errorsx.WithStack(fosite.ErrInvalidScope.WithHintf("The public key registered for issuer \"%s\" and subject \"%s\" is not allowed to request scope \"%s\".", claims.Issuer, claims.Subject, scope))
 		}
 	}
 
+	if len(request.GetRequestedAudience()) > 0 {
+		client := request.GetClient()
+		if client == nil {
+			return errorsx.WithStack(fosite.ErrInvalidRequest.WithHint("An audience can only be requested by authenticated clients."))
+		}
+		if err := fosite.GetAudienceMatchingStrategy(ctx, client, c.AudienceMatchingStrategy)(client.GetAudience(), request.GetRequestedAudience()); err != nil {
+			return err
+		}
+	}
+
 	if claims.ID != "" {
-		if err := c.Storage.MarkJWTUsedForTime(ctx, claims.ID, claims.Expiry.Time()); err != nil {
+		if err := fosite.StorageOperation(ctx, "MarkJWTUsedForTime", func(ctx context.Context) error {
+			return c.Storage.MarkJWTUsedForTime(ctx, claims.ID, claims.Expiry.Time())
+		}); err != nil {
 			return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
 		}
 	}
@@ -122,12 +153,15 @@ func (c *Handler) HandleTokenEndpointRequest(ctx context.Context, request fosite
 	for _, audience := range claims.Audience {
 		request.GrantAudience(audience)
 	}
+	for _, audience := range request.GetRequestedAudience() {
+		request.GrantAudience(audience)
+	}
 
 	session, err := c.getSessionFromRequest(request)
 	if err != nil {
 		return err
 	}
-	session.SetExpiresAt(fosite.AccessToken, time.Now().UTC().Add(c.HandleHelper.AccessTokenLifespan).Round(time.Second))
+	session.SetExpiresAt(fosite.AccessToken, time.Now().UTC().Add(fosite.GetAccessTokenLifespan(ctx, c.HandleHelper.AccessTokenLifespan)).Round(time.Second))
 	session.SetSubject(claims.Subject)
 
 	return nil
@@ -213,27 +247,73 @@ func (c *Handler) findPublicKeyForToken(ctx context.Context, token *jwt.JSONWebT
 		unverifiedClaims.Subject,
 	)
 	if keyID != "" {
-		key, err := c.Storage.GetPublicKey(ctx, unverifiedClaims.Issuer, unverifiedClaims.Subject, keyID)
+		keys, err := c.resolvePublicKeys(ctx, unverifiedClaims.Issuer+"|"+unverifiedClaims.Subject+"|"+keyID, func(ctx context.Context) (*jose.JSONWebKeySet, error) {
+			var key *jose.JSONWebKey
+			err := fosite.StorageOperation(ctx, "GetPublicKey", func(ctx context.Context) (err error) {
+				key, err = c.Storage.GetPublicKey(ctx, unverifiedClaims.Issuer, unverifiedClaims.Subject, keyID)
+				return err
+			})
+			if err != nil {
+				return nil, err
+			} else if key == nil {
+				return nil, errorsx.WithStack(fosite.ErrNotFound)
+			}
+			return &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{*key}}, nil
+		})
 		if err != nil {
 			return nil, errorsx.WithStack(keyNotFoundErr.WithWrap(err).WithDebug(err.Error()))
 		}
+		return &keys.Keys[0], nil
+	}
+
+	cacheKey := unverifiedClaims.Issuer + "|" + unverifiedClaims.Subject
+	fetch := func(ctx context.Context) (keys *jose.JSONWebKeySet, err error) {
+		err = fosite.StorageOperation(ctx, "GetPublicKeys", func(ctx context.Context) (err error) {
+			keys, err = c.Storage.GetPublicKeys(ctx, unverifiedClaims.Issuer, unverifiedClaims.Subject)
+			return err
+		})
+		return keys, err
+	}
+
+	keys, err := c.resolvePublicKeys(ctx, cacheKey, fetch)
+	if err != nil {
+		return nil, errorsx.WithStack(keyNotFoundErr.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	if key := findVerifyingKey(token, keys); key != nil {
 		return key, nil
+	} else if c.KeyCache == nil {
+		return nil, errorsx.WithStack(keyNotFoundErr)
 	}
 
-	keys, err := c.Storage.GetPublicKeys(ctx, unverifiedClaims.Issuer, unverifiedClaims.Subject)
+	// The cached keys might be outdated, so we try again with the keys from the storage.
+	c.KeyCache.Invalidate(ctx, cacheKey)
+	keys, err = c.resolvePublicKeys(ctx, cacheKey, fetch)
 	if err != nil {
 		return nil, errorsx.WithStack(keyNotFoundErr.WithWrap(err).WithDebug(err.Error()))
 	}
 
+	if key := findVerifyingKey(token, keys); key != nil {
+		return key, nil
+	}
+	return nil, errorsx.WithStack(keyNotFoundErr)
+}
+
+func (c *Handler) resolvePublicKeys(ctx context.Context, cacheKey string, fetch func(ctx context.Context) (*jose.JSONWebKeySet, error)) (*jose.JSONWebKeySet, error) {
+	if c.KeyCache == nil {
+		return fetch(ctx)
+	}
+	return c.KeyCache.Resolve(ctx, cacheKey, fetch)
+}
+
+func findVerifyingKey(token *jwt.JSONWebToken, keys *jose.JSONWebKeySet) *jose.JSONWebKey {
 	claims := jwt.Claims{}
 	for _, key := range keys.Keys {
-		err := token.Claims(key, &claims)
-		if err == nil {
-			return &key, nil
+		if err := token.Claims(key, &claims); err == nil {
+			return &key
 		}
 	}
-
-	return nil, errorsx.WithStack(keyNotFoundErr)
+	return nil
 }
 
 func (c *Handler) validateTokenClaims(ctx context.Context, claims jwt.Claims, key *jose.JSONWebKey) error {
@@ -243,6 +323,15 @@ func (c *Handler) validateTokenClaims(ctx context.Context, claims jwt.Claims, ke
 		)
 	}
 
+	if c.JWTSingleAudience && len(claims.Audience) > 1 {
+		return errorsx.WithStack(fosite.ErrInvalidGrant.
+			WithHintf(
+				"The JWT in \"assertion\" request parameter MUST contain a single \"aud\" (audience) claim value \"%s\" that identifies the authorization server.",
+				c.TokenURL,
+			),
+		)
+	}
+
 	if !claims.Audience.Contains(c.TokenURL) {
 		return errorsx.WithStack(fosite.ErrInvalidGrant.
 			WithHintf(
@@ -258,13 +347,14 @@ func (c *Handler) validateTokenClaims(ctx context.Context, claims jwt.Claims, ke
 		)
 	}
 
-	if claims.Expiry.Time().Before(time.Now()) {
+	now := time.Now()
+	if claims.Expiry.Time().Add(c.JWTClockSkew).Before(now) {
 		return errorsx.WithStack(fosite.ErrInvalidGrant.
 			WithHint("The JWT in \"assertion\" request parameter expired."),
 		)
 	}
 
-	if claims.NotBefore != nil && !claims.NotBefore.Time().Before(time.Now()) {
+	if claims.NotBefore != nil && !claims.NotBefore.Time().Add(-c.JWTClockSkew).Before(now) {
 		return errorsx.WithStack(fosite.ErrInvalidGrant.
 			WithHintf(
 				"The JWT in \"assertion\" request parameter contains an \"nbf\" (not before) claim, that identifies the time '%s' before which the token MUST NOT be accepted.",
@@ -283,7 +373,15 @@ func (c *Handler) validateTokenClaims(ctx context.Context, claims jwt.Claims, ke
 	if claims.IssuedAt != nil {
 		issuedDate = claims.IssuedAt.Time()
 	} else {
-		issuedDate = time.Now()
+		issuedDate = now
+	}
+	if c.JWTMaxIssuedAge > 0 && now.Sub(issuedDate) > c.JWTMaxIssuedAge+c.JWTClockSkew {
+		return errorsx.WithStack(fosite.ErrInvalidGrant.
+			WithHintf(
+				"The JWT in \"assertion\" request parameter contains an \"iat\" (issued at) claim with value \"%s\" that is too old.",
+				issuedDate.Format(time.RFC3339),
+			),
+		)
 	}
 	if claims.Expiry.Time().Sub(issuedDate) > c.JWTMaxDuration {
 		return errorsx.WithStack(fosite.ErrInvalidGrant.
@@ -302,7 +400,11 @@ func (c *Handler) validateTokenClaims(ctx context.Context, claims jwt.Claims, ke
 	}
 
 	if claims.ID != "" {
-		used, err := c.Storage.IsJWTUsed(ctx, claims.ID)
+		var used bool
+		err := fosite.StorageOperation(ctx, "IsJWTUsed", func(ctx context.Context) (err error) {
+			used, err = c.Storage.IsJWTUsed(ctx, claims.ID)
+			return err
+		})
 		if err != nil {
 			return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
 		}
@@ -329,3 +431,7 @@ func (c *Handler) getSessionFromRequest(requester fosite.AccessRequester) (exten
 		return jwtSession, nil
 	}
 }
+
+func (c *Handler) PopulateDiscoveryMetadata(ctx context.Context, metadata *fosite.DiscoveryMetadata) {
+	metadata.GrantTypesSupported = append(metadata.GrantTypesSupported, grantTypeJWTBearer)
+}
@@ -661,6 +661,98 @@ func (s *AuthorizeJWTGrantRequestHandlerTestSuite) TestValidAssertion() {
 	s.NoError(err, "no error expected, because assertion must be valid")
 }
 
+func (s *AuthorizeJWTGrantRequestHandlerTestSuite) TestValidAssertionWithRequestedAudience() {
+	// arrange
+	ctx := context.Background()
+	s.accessRequest.GrantTypes = []string{grantTypeJWTBearer}
+	s.accessRequest.Client = &fosite.DefaultOpenIDConnectClient{
+		DefaultClient:            &fosite.DefaultClient{GrantTypes: []string{grantTypeJWTBearer}, Audience: []string{"https://*.example.com/api"}},
+		AudienceMatchingStrategy: fosite.AudienceMatchingStrategyWildcard,
+	}
+	keyID := "my_key"
+	pubKey := s.createJWK(s.privateKey.Public(), keyID)
+	cl := s.createStandardClaim()
+
+	s.accessRequest.Form.Add("assertion", s.createTestAssertion(cl, keyID))
+	s.accessRequest.RequestedAudience = []string{"https://eu.example.com/api"}
+	s.mockStore.EXPECT().GetPublicKey(ctx, cl.Issuer, cl.Subject, keyID).Return(&pubKey, nil)
+	s.mockStore.EXPECT().GetPublicKeyScopes(ctx, cl.Issuer, cl.Subject, keyID).Return([]string{}, nil)
+	s.mockStore.EXPECT().IsJWTUsed(ctx, cl.ID).Return(false, nil)
+	s.mockStore.EXPECT().MarkJWTUsedForTime(ctx, cl.ID, cl.Expiry.Time()).Return(nil)
+
+	// act
+	err := s.handler.HandleTokenEndpointRequest(ctx, s.accessRequest)
+
+	// assert
+	s.NoError(err, "no error expected, because the requested audience matches the client's audience")
+	s.True(s.accessRequest.GetGrantedAudience().Has("https://eu.example.com/api"))
+}
+
+func (s *AuthorizeJWTGrantRequestHandlerTestSuite) TestAssertionWithNotAllowedAudience() {
+	// arrange
+	ctx := context.Background()
+	s.accessRequest.GrantTypes = []string{grantTypeJWTBearer}
+	s.accessRequest.Client = &fosite.DefaultClient{GrantTypes: []string{grantTypeJWTBearer}, Audience: []string{"https://api.example.com"}}
+	keyID := "my_key"
+	pubKey := s.createJWK(s.privateKey.Public(), keyID)
+	cl := s.createStandardClaim()
+
+	s.accessRequest.Form.Add("assertion", s.createTestAssertion(cl, keyID))
+	s.accessRequest.RequestedAudience = []string{"https://other.example.com"}
+	s.mockStore.EXPECT().GetPublicKey(ctx, cl.Issuer, cl.Subject, keyID).Return(&pubKey, nil)
+	s.mockStore.EXPECT().GetPublicKeyScopes(ctx, cl.Issuer, cl.Subject, keyID).Return([]string{}, nil)
+	s.mockStore.EXPECT().IsJWTUsed(ctx, cl.ID).Return(false, nil)
+
+	// act
+	err := s.handler.HandleTokenEndpointRequest(ctx, s.accessRequest)
+
+	// assert
+	s.True(errors.Is(err, fosite.ErrInvalidRequest))
+}
+
+func (s *AuthorizeJWTGrantRequestHandlerTestSuite) TestPublicKeysAreCached() {
+	// arrange
+	ctx := context.Background()
+	s.accessRequest.GrantTypes = []string{grantTypeJWTBearer}
+	s.handler.KeyCache = fosite.NewMemoryJSONWebKeyCache(time.Minute, 0)
+	s.handler.JWTIDOptional = true
+	keyID := "my_key"
+	pubKey := s.createJWK(s.privateKey.Public(), keyID)
+	cl := s.createStandardClaim()
+	cl.ID = ""
+	s.accessRequest.Form.Add("assertion", s.createTestAssertion(cl, ""))
+	s.mockStore.EXPECT().GetPublicKeys(ctx, cl.Issuer, cl.Subject).Return(s.createJWS(pubKey), nil).Times(1)
+	s.mockStore.EXPECT().GetPublicKeyScopes(ctx, cl.Issuer, cl.Subject, keyID).Return([]string{"valid_scope"}, nil).Times(2)
+
+	// act & assert
+	s.NoError(s.handler.HandleTokenEndpointRequest(ctx, s.accessRequest))
+	s.NoError(s.handler.HandleTokenEndpointRequest(ctx, s.accessRequest), "keys must be served from the cache")
+}
+
+func (s *AuthorizeJWTGrantRequestHandlerTestSuite) TestCachedPublicKeysAreRefreshedWhenNoKeyMatches() {
+	// arrange
+	ctx := context.Background()
+	s.accessRequest.GrantTypes = []string{grantTypeJWTBearer}
+	s.handler.KeyCache = fosite.NewMemoryJSONWebKeyCache(time.Minute, 0)
+	s.handler.JWTIDOptional = true
+	keyID := "my_key"
+	pubKey := s.createJWK(s.privateKey.Public(), keyID)
+	cl := s.createStandardClaim()
+	cl.ID = ""
+	s.accessRequest.Form.Add("assertion", s.createTestAssertion(cl, ""))
+	gomock.InOrder(
+		s.mockStore.EXPECT().GetPublicKeys(ctx, cl.Issuer, cl.Subject).Return(s.createJWS(s.createRandomTestJWK()), nil),
+		s.mockStore.EXPECT().GetPublicKeys(ctx, cl.Issuer, cl.Subject).Return(s.createJWS(pubKey), nil),
+	)
+	s.mockStore.EXPECT().GetPublicKeyScopes(ctx, cl.Issuer, cl.Subject, keyID).Return([]string{"valid_scope"}, nil)
+
+	// act
+	err := s.handler.HandleTokenEndpointRequest(ctx, s.accessRequest)
+
+	// assert
+	s.NoError(err, "no error expected, because the rotated key must be fetched from the storage")
+}
+
 func (s *AuthorizeJWTGrantRequestHandlerTestSuite) TestAssertionIsValidWhenNoScopesPassed() {
 	// arrange
 	ctx := context.Background()
@@ -723,6 +815,99 @@ func (s *AuthorizeJWTGrantRequestHandlerTestSuite) TestAssertionIsValidWhenJWTIs
 	s.NoError(err, "no error expected, because assertion must be valid, when no iss claim and it is allowed by option")
 }
 
+func (s *AuthorizeJWTGrantRequestHandlerTestSuite) TestAssertionWithMultipleAudiencesWhenSingleAudienceRequired() {
+	// arrange
+	ctx := context.Background()
+	s.accessRequest.GrantTypes = []string{grantTypeJWTBearer}
+	keyID := "my_key"
+	pubKey := s.createJWK(s.privateKey.Public(), keyID)
+	cl := s.createStandardClaim()
+	s.handler.JWTSingleAudience = true
+	s.accessRequest.Form.Add("assertion", s.createTestAssertion(cl, keyID))
+	s.mockStore.EXPECT().GetPublicKey(ctx, cl.Issuer, cl.Subject, keyID).Return(&pubKey, nil)
+
+	// act
+	err := s.handler.HandleTokenEndpointRequest(ctx, s.accessRequest)
+
+	// assert
+	s.True(errors.Is(err, fosite.ErrInvalidGrant))
+	s.Equal(
+		"The JWT in \"assertion\" request parameter MUST contain a single \"aud\" (audience) claim value \"https://www.example.com/token\" that identifies the authorization server.",
+		err.(*fosite.RFC6749Error).HintField,
+	)
+}
+
+func (s *AuthorizeJWTGrantRequestHandlerTestSuite) TestAssertionWithIssueDateOlderThanMaxIssuedAge() {
+	// arrange
+	ctx := context.Background()
+	s.accessRequest.GrantTypes = []string{grantTypeJWTBearer}
+	keyID := "my_key"
+	pubKey := s.createJWK(s.privateKey.Public(), keyID)
+	cl := s.createStandardClaim()
+	s.handler.JWTMaxIssuedAge = time.Hour
+	s.accessRequest.Form.Add("assertion", s.createTestAssertion(cl, keyID))
+	s.mockStore.EXPECT().GetPublicKey(ctx, cl.Issuer, cl.Subject, keyID).Return(&pubKey, nil)
+
+	// act
+	err := s.handler.HandleTokenEndpointRequest(ctx, s.accessRequest)
+
+	// assert
+	s.True(errors.Is(err, fosite.ErrInvalidGrant))
+	s.Equal(
+		fmt.Sprintf(
+			"The JWT in \"assertion\" request parameter contains an \"iat\" (issued at) claim with value \"%s\" that is too old.",
+			cl.IssuedAt.Time().Format(time.RFC3339),
+		),
+		err.(*fosite.RFC6749Error).HintField,
+	)
+}
+
+func (s *AuthorizeJWTGrantRequestHandlerTestSuite) TestAssertionIsValidWhenExpiredWithinClockSkew() {
+	// arrange
+	ctx := context.Background()
+	s.accessRequest.GrantTypes = []string{grantTypeJWTBearer}
+	keyID := "my_key"
+	pubKey := s.createJWK(s.privateKey.Public(), keyID)
+	cl := s.createStandardClaim()
+	cl.Expiry = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	cl.NotBefore = jwt.NewNumericDate(time.Now().Add(time.Minute))
+	s.handler.JWTClockSkew = time.Minute * 5
+	s.accessRequest.Form.Add("assertion", s.createTestAssertion(cl, keyID))
+	s.mockStore.EXPECT().GetPublicKey(ctx, cl.Issuer, cl.Subject, keyID).Return(&pubKey, nil)
+	s.mockStore.EXPECT().GetPublicKeyScopes(ctx, cl.Issuer, cl.Subject, keyID).Return([]string{"valid_scope"}, nil)
+	s.mockStore.EXPECT().IsJWTUsed(ctx, cl.ID).Return(false, nil)
+	s.mockStore.EXPECT().MarkJWTUsedForTime(ctx, cl.ID, cl.Expiry.Time()).Return(nil)
+
+	// act
+	err := s.handler.HandleTokenEndpointRequest(ctx, s.accessRequest)
+
+	// assert
+	s.NoError(err, "no error expected, because exp and nbf claims are within the allowed clock skew")
+}
+
+func (s *AuthorizeJWTGrantRequestHandlerTestSuite) TestAssertionIsValidWithUnregisteredScopesWhenAllowed() {
+	// arrange
+	ctx := context.Background()
+	s.accessRequest.GrantTypes = []string{grantTypeJWTBearer}
+	keyID := "my_key"
+	pubKey := s.createJWK(s.privateKey.Public(), keyID)
+	cl := s.createStandardClaim()
+	s.handler.AllowUnregisteredScopes = true
+	s.accessRequest.Form.Add("assertion", s.createTestAssertion(cl, keyID))
+	s.accessRequest.RequestedScope = []string{"some_scope"}
+	s.mockStore.EXPECT().GetPublicKey(ctx, cl.Issuer, cl.Subject, keyID).Return(&pubKey, nil)
+	s.mockStore.EXPECT().GetPublicKeyScopes(ctx, cl.Issuer, cl.Subject, keyID).Return([]string{"valid_scope"}, nil)
+	s.mockStore.EXPECT().IsJWTUsed(ctx, cl.ID).Return(false, nil)
+	s.mockStore.EXPECT().MarkJWTUsedForTime(ctx, cl.ID, cl.Expiry.Time()).Return(nil)
+
+	// act
+	err := s.handler.HandleTokenEndpointRequest(ctx, s.accessRequest)
+
+	// assert
+	s.NoError(err, "no error expected, because unregistered scopes are allowed by option")
+	s.Equal(fosite.Arguments{"some_scope"}, s.accessRequest.GetGrantedScopes())
+}
+
 func (s *AuthorizeJWTGrantRequestHandlerTestSuite) TestRequestIsValidWhenClientAuthOptional() {
 	// arrange
 	ctx := context.Background()
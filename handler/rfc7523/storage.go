@@ -49,3 +49,12 @@ type RFC7523KeyStorage interface {
 	// considered valid based on the applicable "exp" instant. (https://tools.ietf.org/html/rfc7523#section-3)
 	MarkJWTUsedForTime(ctx context.Context, jti string, exp time.Time) error
 }
+
+// ClientAssertionKeyStorage holds the public keys used to verify JWT client assertions of clients authenticating as
+// defined in https://tools.ietf.org/html/rfc7523#section-2.2. Unlike the keys of RFC7523KeyStorage, which are
+// trusted to sign authorization grants for a subject, these keys only authenticate the client they belong to.
+type ClientAssertionKeyStorage interface {
+	// GetClientAssertionKeys returns the public keys of the client with the given ID. It returns fosite.ErrNotFound
+	// if no keys are registered for the client.
+	GetClientAssertionKeys(ctx context.Context, clientID string) (*jose.JSONWebKeySet, error)
+}
@@ -0,0 +1,110 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package rfc7591
+
+import (
+	"strings"
+	"time"
+
+	"gopkg.in/square/go-jose.v2"
+
+	"github.com/ory/fosite"
+)
+
+// ClientMetadata contains the client metadata defined in https://tools.ietf.org/html/rfc7591#section-2 and the
+// subset of https://openid.net/specs/openid-connect-registration-1_0.html#ClientMetadata supported by fosite.
+type ClientMetadata struct {
+	RedirectURIs                      []string            `json:"redirect_uris,omitempty"`
+	TokenEndpointAuthMethod           string              `json:"token_endpoint_auth_method,omitempty"`
+	GrantTypes                        []string            `json:"grant_types,omitempty"`
+	ResponseTypes                     []string            `json:"response_types,omitempty"`
+	ClientName                        string              `json:"client_name,omitempty"`
+	ClientURI                         string              `json:"client_uri,omitempty"`
+	LogoURI                           string              `json:"logo_uri,omitempty"`
+	Scope                             string              `json:"scope,omitempty"`
+	Contacts                          []string            `json:"contacts,omitempty"`
+	TOSURI                            string              `json:"tos_uri,omitempty"`
+	PolicyURI                         string              `json:"policy_uri,omitempty"`
+	JSONWebKeysURI                    string              `json:"jwks_uri,omitempty"`
+	JSONWebKeys                       *jose.JSONWebKeySet `json:"jwks,omitempty"`
+	SoftwareID                        string              `json:"software_id,omitempty"`
+	SoftwareVersion                   string              `json:"software_version,omitempty"`
+	SoftwareStatement                 string              `json:"software_statement,omitempty"`
+	RequestURIs                       []string            `json:"request_uris,omitempty"`
+	RequestObjectSigningAlgorithm     string              `json:"request_object_signing_alg,omitempty"`
+	TokenEndpointAuthSigningAlgorithm string              `json:"token_endpoint_auth_signing_alg,omitempty"`
+	IDTokenSignedResponseAlg          string              `json:"id_token_signed_response_alg,omitempty"`
+	IDTokenEncryptedResponseAlg       string              `json:"id_token_encrypted_response_alg,omitempty"`
+	IDTokenEncryptedResponseEnc       string              `json:"id_token_encrypted_response_enc,omitempty"`
+	AuthorizationDetailsTypes         []string            `json:"authorization_details_types,omitempty"`
+	SubjectType                       string              `json:"subject_type,omitempty"`
+	SectorIdentifierURI               string              `json:"sector_identifier_uri,omitempty"`
+}
+
+// Client is a dynamically registered client.
+type Client struct {
+	*fosite.DefaultOpenIDConnectClient
+
+	// Metadata is the metadata the client was registered with.
+	Metadata ClientMetadata `json:"metadata"`
+
+	// RegistrationAccessTokenSignature is the signature of the registration access token authorizing requests to
+	// the client configuration endpoint.
+	RegistrationAccessTokenSignature string `json:"registration_access_token_signature"`
+
+	// ClientIDIssuedAt is the time at which the client was registered.
+	ClientIDIssuedAt time.Time `json:"client_id_issued_at"`
+}
+
+// GetClientMetadata returns the metadata the client was registered with.
+func (c *Client) GetClientMetadata() ClientMetadata {
+	return c.Metadata
+}
+
+func newClient(id string, hashedSecret []byte, metadata ClientMetadata) *Client {
+	return &Client{
+		DefaultOpenIDConnectClient: &fosite.DefaultOpenIDConnectClient{
+			DefaultClient: &fosite.DefaultClient{
+				ID:            id,
+				Secret:        hashedSecret,
+				RedirectURIs:  metadata.RedirectURIs,
+				GrantTypes:    metadata.GrantTypes,
+				ResponseTypes: metadata.ResponseTypes,
+				Scopes:        strings.Fields(metadata.Scope),
+				Public:        metadata.TokenEndpointAuthMethod == "none",
+			},
+			JSONWebKeysURI:                    metadata.JSONWebKeysURI,
+			JSONWebKeys:                       metadata.JSONWebKeys,
+			TokenEndpointAuthMethod:           metadata.TokenEndpointAuthMethod,
+			RequestURIs:                       metadata.RequestURIs,
+			RequestObjectSigningAlgorithm:     metadata.RequestObjectSigningAlgorithm,
+			TokenEndpointAuthSigningAlgorithm: metadata.TokenEndpointAuthSigningAlgorithm,
+			IDTokenSignedResponseAlg:          metadata.IDTokenSignedResponseAlg,
+			IDTokenEncryptedResponseAlg:       metadata.IDTokenEncryptedResponseAlg,
+			IDTokenEncryptedResponseEnc:       metadata.IDTokenEncryptedResponseEnc,
+			AuthorizationDetailsTypes:         metadata.AuthorizationDetailsTypes,
+			SubjectType:                       metadata.SubjectType,
+			SectorIdentifierURI:               metadata.SectorIdentifierURI,
+		},
+		Metadata: metadata,
+	}
+}
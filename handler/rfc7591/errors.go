@@ -0,0 +1,60 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package rfc7591
+
+import (
+	"net/http"
+
+	"github.com/ory/fosite"
+)
+
+// Errors defined in https://tools.ietf.org/html/rfc7591#section-3.2.2
+var (
+	ErrInvalidRedirectURI = &fosite.RFC6749Error{
+		ErrorField:       "invalid_redirect_uri",
+		DescriptionField: "The value of one or more redirection URIs is invalid.",
+		CodeField:        http.StatusBadRequest,
+	}
+	ErrInvalidClientMetadata = &fosite.RFC6749Error{
+		ErrorField:       "invalid_client_metadata",
+		DescriptionField: "The value of one of the client metadata fields is invalid and the server has rejected this request.",
+		CodeField:        http.StatusBadRequest,
+	}
+	ErrInvalidSoftwareStatement = &fosite.RFC6749Error{
+		ErrorField:       "invalid_software_statement",
+		DescriptionField: "The software statement presented is invalid.",
+		CodeField:        http.StatusBadRequest,
+	}
+	ErrUnapprovedSoftwareStatement = &fosite.RFC6749Error{
+		ErrorField:       "unapproved_software_statement",
+		DescriptionField: "The software statement presented is not approved for use by this authorization server.",
+		CodeField:        http.StatusBadRequest,
+	}
+
+	// ErrInvalidRegistrationAccessToken is returned if the registration access token of a client configuration
+	// request is missing, invalid, or does not belong to the client, see https://tools.ietf.org/html/rfc7592#section-2
+	ErrInvalidRegistrationAccessToken = &fosite.RFC6749Error{
+		ErrorField:       "invalid_token",
+		DescriptionField: "The registration access token provided is expired, revoked, malformed, or invalid for other reasons.",
+		CodeField:        http.StatusUnauthorized,
+	}
+)
@@ -0,0 +1,468 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package rfc7591
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ory/go-convenience/stringslice"
+	"github.com/ory/x/errorsx"
+	"github.com/pborman/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/token/hmac"
+)
+
+const registrationAccessTokenLength = 32
+
+// Handler implements the dynamic client registration endpoint as defined in https://tools.ietf.org/html/rfc7591
+// and the client configuration endpoint as defined in https://tools.ietf.org/html/rfc7592.
+type Handler struct {
+	Storage ClientManager
+	Hasher  fosite.Hasher
+
+	// RegistrationEndpoint is the URL of the registration endpoint. The client configuration endpoint of each client
+	// is served at RegistrationEndpoint + "/" + client_id. If empty, registration_client_uri is not returned.
+	RegistrationEndpoint string
+
+	// SoftwareStatementValidator validates software statements. If nil, software statements are rejected.
+	SoftwareStatementValidator SoftwareStatementValidator
+
+	// RequireSoftwareStatement, if set to true, rejects registration requests without a software statement.
+	RequireSoftwareStatement bool
+
+	// TokenEndpointAuthMethods restricts the client authentication methods clients can register. Defaults to
+	// client_secret_basic, client_secret_post, private_key_jwt, and none.
+	TokenEndpointAuthMethods []string
+
+	// AllowedScopes restricts the scopes clients can register. Clients are registered with all allowed scopes if
+	// they do not request any. If empty, any scope can be registered.
+	AllowedScopes []string
+
+	// ScopeStrategy matches the requested scopes against AllowedScopes. Defaults to fosite.ExactScopeStrategy.
+	ScopeStrategy fosite.ScopeStrategy
+
+	// SubjectTypes restricts the subject identifier types clients can register. Defaults to public only.
+	SubjectTypes []string
+
+	// HTTPClient fetches the sector_identifier_uri of clients. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// ClientRegistrationRequest is a validated client registration request.
+type ClientRegistrationRequest struct {
+	Metadata ClientMetadata
+}
+
+// ClientConfigurationRequest is an authenticated and validated client configuration request.
+type ClientConfigurationRequest struct {
+	// Method is one of http.MethodGet, http.MethodPut, or http.MethodDelete.
+	Method string
+
+	// Client is the client the registration access token belongs to.
+	Client *Client
+
+	// Metadata is the new client metadata of update requests.
+	Metadata ClientMetadata
+}
+
+// ClientRegistrationResponse is the client information response, see
+// https://tools.ietf.org/html/rfc7591#section-3.2.1
+type ClientRegistrationResponse struct {
+	ClientMetadata
+
+	ClientID                string `json:"client_id"`
+	ClientSecret            string `json:"client_secret,omitempty"`
+	ClientIDIssuedAt        int64  `json:"client_id_issued_at,omitempty"`
+	ClientSecretExpiresAt   int64  `json:"client_secret_expires_at"`
+	RegistrationAccessToken string `json:"registration_access_token,omitempty"`
+	RegistrationClientURI   string `json:"registration_client_uri,omitempty"`
+}
+
+// NewClientRegistrationRequest parses and validates a client registration request.
+func (h *Handler) NewClientRegistrationRequest(ctx context.Context, r *http.Request) (*ClientRegistrationRequest, error) {
+	if r.Method != http.MethodPost {
+		return nil, errorsx.WithStack(fosite.ErrInvalidRequest.WithHintf("HTTP method is '%s', expected 'POST'.", r.Method))
+	}
+
+	var metadata ClientMetadata
+	if err := json.NewDecoder(r.Body).Decode(&metadata); err != nil {
+		return nil, errorsx.WithStack(ErrInvalidClientMetadata.WithHint("Unable to decode the client metadata from the request body.").WithWrap(err).WithDebug(err.Error()))
+	}
+
+	if err := h.validateClientMetadata(ctx, &metadata); err != nil {
+		return nil, err
+	}
+
+	return &ClientRegistrationRequest{Metadata: metadata}, nil
+}
+
+// HandleClientRegistrationRequest registers the client. The returned response contains the client secret and the
+// registration access token, neither of which can be retrieved again later on.
+func (h *Handler) HandleClientRegistrationRequest(ctx context.Context, request *ClientRegistrationRequest) (*ClientRegistrationResponse, error) {
+	secret, hashedSecret, err := h.newClientSecret(ctx, request.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	client := newClient(uuid.New(), hashedSecret, request.Metadata)
+	client.ClientIDIssuedAt = time.Now().UTC()
+
+	token, signature, err := newRegistrationAccessToken()
+	if err != nil {
+		return nil, err
+	}
+	client.RegistrationAccessTokenSignature = signature
+
+	if err := h.Storage.CreateClient(ctx, client); err != nil {
+		return nil, errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	return h.newResponse(client, secret, token), nil
+}
+
+// NewClientConfigurationRequest authenticates a client configuration request using the registration access token in
+// the Authorization header. For update requests, the new client metadata is parsed and validated as well.
+func (h *Handler) NewClientConfigurationRequest(ctx context.Context, r *http.Request, clientID string) (*ClientConfigurationRequest, error) {
+	split := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
+	if len(split) != 2 || !strings.EqualFold(split[0], "bearer") || split[1] == "" {
+		return nil, errorsx.WithStack(ErrInvalidRegistrationAccessToken.WithHint("The request does not contain a registration access token in the Authorization header."))
+	}
+
+	found, err := h.Storage.GetClient(ctx, clientID)
+	if errors.Is(err, fosite.ErrNotFound) {
+		// Do not reveal whether or not the client exists.
+		return nil, errorsx.WithStack(ErrInvalidRegistrationAccessToken)
+	} else if err != nil {
+		return nil, errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	client, ok := found.(*Client)
+	if !ok || subtle.ConstantTimeCompare([]byte(registrationAccessTokenSignature(split[1])), []byte(client.RegistrationAccessTokenSignature)) != 1 {
+		return nil, errorsx.WithStack(ErrInvalidRegistrationAccessToken)
+	}
+
+	request := &ClientConfigurationRequest{Method: r.Method, Client: client}
+	switch r.Method {
+	case http.MethodGet, http.MethodDelete:
+		return request, nil
+	case http.MethodPut:
+		// https://tools.ietf.org/html/rfc7592#section-2.2
+		var body struct {
+			ClientMetadata
+			ClientID     string `json:"client_id"`
+			ClientSecret string `json:"client_secret"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			return nil, errorsx.WithStack(ErrInvalidClientMetadata.WithHint("Unable to decode the client metadata from the request body.").WithWrap(err).WithDebug(err.Error()))
+		}
+
+		if body.ClientID != client.GetID() {
+			return nil, errorsx.WithStack(fosite.ErrInvalidRequest.WithHint("The 'client_id' of the request body must match the client being updated."))
+		}
+
+		if body.ClientSecret != "" {
+			if err := h.Hasher.Compare(ctx, client.GetHashedSecret(), []byte(body.ClientSecret)); err != nil {
+				return nil, errorsx.WithStack(fosite.ErrInvalidRequest.WithHint("The 'client_secret' of the request body does not match the client's secret.").WithWrap(err).WithDebug(err.Error()))
+			}
+		}
+
+		if err := h.validateClientMetadata(ctx, &body.ClientMetadata); err != nil {
+			return nil, err
+		}
+
+		request.Metadata = body.ClientMetadata
+		return request, nil
+	default:
+		return nil, errorsx.WithStack(fosite.ErrInvalidRequest.WithHintf("HTTP method is '%s', expected 'GET', 'PUT', or 'DELETE'.", r.Method))
+	}
+}
+
+// HandleClientConfigurationRequest reads, updates, or deletes the client. A new registration access token is issued
+// for read and update requests as only its signature is stored. The response is nil for delete requests.
+func (h *Handler) HandleClientConfigurationRequest(ctx context.Context, request *ClientConfigurationRequest) (*ClientRegistrationResponse, error) {
+	client := request.Client
+	if request.Method == http.MethodDelete {
+		if err := h.Storage.DeleteClient(ctx, client.GetID()); err != nil {
+			return nil, errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+		}
+		return nil, nil
+	}
+
+	var secret string
+	if request.Method == http.MethodPut {
+		updated := newClient(client.GetID(), client.GetHashedSecret(), request.Metadata)
+		updated.ClientIDIssuedAt = client.ClientIDIssuedAt
+
+		if !requiresClientSecret(request.Metadata) {
+			updated.Secret = nil
+		} else if len(updated.Secret) == 0 {
+			var err error
+			secret, updated.Secret, err = h.newClientSecret(ctx, request.Metadata)
+			if err != nil {
+				return nil, err
+			}
+		}
+		client = updated
+	}
+
+	token, signature, err := newRegistrationAccessToken()
+	if err != nil {
+		return nil, err
+	}
+	client.RegistrationAccessTokenSignature = signature
+
+	if err := h.Storage.UpdateClient(ctx, client); err != nil {
+		return nil, errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	return h.newResponse(client, secret, token), nil
+}
+
+// WriteClientRegistrationResponse writes the response of a successful client registration request.
+func (h *Handler) WriteClientRegistrationResponse(ctx context.Context, rw http.ResponseWriter, response *ClientRegistrationResponse) {
+	writeJSON(rw, http.StatusCreated, response)
+}
+
+// WriteClientConfigurationResponse writes the response of a successful client configuration request.
+func (h *Handler) WriteClientConfigurationResponse(ctx context.Context, rw http.ResponseWriter, response *ClientRegistrationResponse) {
+	if response == nil {
+		rw.Header().Set("Cache-Control", "no-store")
+		rw.Header().Set("Pragma", "no-cache")
+		rw.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeJSON(rw, http.StatusOK, response)
+}
+
+// WriteClientRegistrationError writes an error response of the registration or client configuration endpoint, see
+// https://tools.ietf.org/html/rfc7591#section-3.2.2
+func (h *Handler) WriteClientRegistrationError(ctx context.Context, rw http.ResponseWriter, err error) {
+	rfcErr := fosite.ErrorToRFC6749Error(err)
+	if rfcErr.CodeField == http.StatusUnauthorized {
+		rw.Header().Set("WWW-Authenticate", `Bearer error="`+rfcErr.ErrorField+`"`)
+	}
+	writeJSON(rw, rfcErr.CodeField, rfcErr)
+}
+
+func writeJSON(rw http.ResponseWriter, status int, value interface{}) {
+	js, err := json.Marshal(value)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	rw.Header().Set("Cache-Control", "no-store")
+	rw.Header().Set("Pragma", "no-cache")
+	rw.WriteHeader(status)
+	_, _ = rw.Write(js)
+}
+
+func (h *Handler) newResponse(client *Client, secret, token string) *ClientRegistrationResponse {
+	response := &ClientRegistrationResponse{
+		ClientMetadata:          client.Metadata,
+		ClientID:                client.GetID(),
+		ClientSecret:            secret,
+		ClientIDIssuedAt:        client.ClientIDIssuedAt.Unix(),
+		RegistrationAccessToken: token,
+	}
+	if h.RegistrationEndpoint != "" {
+		response.RegistrationClientURI = strings.TrimRight(h.RegistrationEndpoint, "/") + "/" + url.PathEscape(client.GetID())
+	}
+	return response
+}
+
+func (h *Handler) newClientSecret(ctx context.Context, metadata ClientMetadata) (secret string, hashed []byte, err error) {
+	if !requiresClientSecret(metadata) {
+		return "", nil, nil
+	}
+
+	raw, err := hmac.RandomBytes(registrationAccessTokenLength)
+	if err != nil {
+		return "", nil, errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	secret = base64.RawURLEncoding.EncodeToString(raw)
+	hashed, err = h.Hasher.Hash(ctx, []byte(secret))
+	if err != nil {
+		return "", nil, errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+	return secret, hashed, nil
+}
+
+func requiresClientSecret(metadata ClientMetadata) bool {
+	switch metadata.TokenEndpointAuthMethod {
+	case "client_secret_basic", "client_secret_post", "client_secret_jwt":
+		return true
+	default:
+		return false
+	}
+}
+
+func newRegistrationAccessToken() (token, signature string, err error) {
+	raw, err := hmac.RandomBytes(registrationAccessTokenLength)
+	if err != nil {
+		return "", "", errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	token = base64.RawURLEncoding.EncodeToString(raw)
+	return token, registrationAccessTokenSignature(token), nil
+}
+
+func registrationAccessTokenSignature(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+func (h *Handler) validateClientMetadata(ctx context.Context, metadata *ClientMetadata) error {
+	if metadata.SoftwareStatement == "" {
+		if h.RequireSoftwareStatement {
+			return errorsx.WithStack(ErrInvalidSoftwareStatement.WithHint("A software statement is required to register clients."))
+		}
+	} else if h.SoftwareStatementValidator == nil {
+		return errorsx.WithStack(ErrUnapprovedSoftwareStatement.WithHint("This authorization server does not accept software statements."))
+	} else {
+		claims, err := h.SoftwareStatementValidator.ValidateSoftwareStatement(ctx, metadata.SoftwareStatement)
+		if err != nil {
+			return err
+		}
+
+		// The claims of the software statement take precedence over the metadata of the request.
+		raw, err := json.Marshal(claims)
+		if err != nil {
+			return errorsx.WithStack(ErrInvalidSoftwareStatement.WithWrap(err).WithDebug(err.Error()))
+		}
+		if err := json.Unmarshal(raw, metadata); err != nil {
+			return errorsx.WithStack(ErrInvalidSoftwareStatement.WithHint("The software statement contains invalid client metadata.").WithWrap(err).WithDebug(err.Error()))
+		}
+	}
+
+	if len(metadata.GrantTypes) == 0 {
+		metadata.GrantTypes = []string{"authorization_code"}
+	}
+	if len(metadata.ResponseTypes) == 0 {
+		metadata.ResponseTypes = []string{"code"}
+	}
+	if metadata.TokenEndpointAuthMethod == "" {
+		metadata.TokenEndpointAuthMethod = "client_secret_basic"
+	}
+
+	methods := h.TokenEndpointAuthMethods
+	if len(methods) == 0 {
+		methods = []string{"client_secret_basic", "client_secret_post", "private_key_jwt", "none"}
+	}
+	if !stringslice.Has(methods, metadata.TokenEndpointAuthMethod) {
+		return errorsx.WithStack(ErrInvalidClientMetadata.WithHintf("The token endpoint authentication method '%s' is not supported.", metadata.TokenEndpointAuthMethod))
+	}
+
+	if metadata.JSONWebKeys != nil && metadata.JSONWebKeysURI != "" {
+		return errorsx.WithStack(ErrInvalidClientMetadata.WithHint("The 'jwks' and 'jwks_uri' parameters must not be used together."))
+	}
+	switch metadata.TokenEndpointAuthMethod {
+	case "private_key_jwt", fosite.SelfSignedTLSClientAuthMethod:
+		if metadata.JSONWebKeys == nil && metadata.JSONWebKeysURI == "" {
+			return errorsx.WithStack(ErrInvalidClientMetadata.WithHintf("The token endpoint authentication method '%s' requires either 'jwks' or 'jwks_uri' to be set.", metadata.TokenEndpointAuthMethod))
+		}
+	}
+
+	// https://tools.ietf.org/html/rfc7591#section-2.1
+	grantTypes := fosite.Arguments(metadata.GrantTypes)
+	for _, responseType := range metadata.ResponseTypes {
+		parts := fosite.Arguments(strings.Fields(responseType))
+		if parts.Has("code") && !grantTypes.Has("authorization_code") {
+			return errorsx.WithStack(ErrInvalidClientMetadata.WithHintf("The response type '%s' requires the grant type 'authorization_code'.", responseType))
+		}
+		if parts.HasOneOf("token", "id_token") && !grantTypes.Has("implicit") {
+			return errorsx.WithStack(ErrInvalidClientMetadata.WithHintf("The response type '%s' requires the grant type 'implicit'.", responseType))
+		}
+	}
+
+	if grantTypes.HasOneOf("authorization_code", "implicit") && len(metadata.RedirectURIs) == 0 {
+		return errorsx.WithStack(ErrInvalidRedirectURI.WithHint("At least one redirect URI must be registered for redirect-based flows."))
+	}
+	for _, raw := range metadata.RedirectURIs {
+		redirectURI, err := url.Parse(raw)
+		if err != nil || !fosite.IsValidRedirectURI(redirectURI) {
+			return errorsx.WithStack(ErrInvalidRedirectURI.WithHintf("The redirect URI '%s' must be an absolute URI without a fragment.", raw))
+		} else if !fosite.IsRedirectURISecure(redirectURI) {
+			return errorsx.WithStack(ErrInvalidRedirectURI.WithHintf("The redirect URI '%s' must use HTTPS unless it points to localhost.", raw))
+		}
+	}
+
+	for name, raw := range map[string]string{
+		"client_uri": metadata.ClientURI,
+		"logo_uri":   metadata.LogoURI,
+		"tos_uri":    metadata.TOSURI,
+		"policy_uri": metadata.PolicyURI,
+		"jwks_uri":   metadata.JSONWebKeysURI,
+	} {
+		if raw == "" {
+			continue
+		}
+		if u, err := url.Parse(raw); err != nil || !u.IsAbs() {
+			return errorsx.WithStack(ErrInvalidClientMetadata.WithHintf("The '%s' parameter must be an absolute URI.", name))
+		}
+	}
+
+	subjectTypes := h.SubjectTypes
+	if len(subjectTypes) == 0 {
+		subjectTypes = []string{fosite.SubjectTypePublic}
+	}
+	if metadata.SubjectType != "" && !stringslice.Has(subjectTypes, metadata.SubjectType) {
+		return errorsx.WithStack(ErrInvalidClientMetadata.WithHintf("The subject type '%s' is not supported.", metadata.SubjectType))
+	}
+
+	// https://openid.net/specs/openid-connect-registration-1_0.html#SectorIdentifierValidation
+	if metadata.SubjectType == fosite.SubjectTypePairwise || metadata.SectorIdentifierURI != "" {
+		if err := fosite.ValidateSectorIdentifierURI(ctx, h.HTTPClient, metadata.SectorIdentifierURI, metadata.RedirectURIs); err != nil {
+			return errorsx.WithStack(ErrInvalidClientMetadata.WithHint(err.Error()).WithWrap(err))
+		}
+	}
+
+	if len(h.AllowedScopes) > 0 {
+		if metadata.Scope == "" {
+			metadata.Scope = strings.Join(h.AllowedScopes, " ")
+		}
+
+		scopeStrategy := h.ScopeStrategy
+		if scopeStrategy == nil {
+			scopeStrategy = fosite.ExactScopeStrategy
+		}
+		for _, scope := range strings.Fields(metadata.Scope) {
+			if !scopeStrategy(h.AllowedScopes, scope) {
+				return errorsx.WithStack(ErrInvalidClientMetadata.WithHintf("The scope '%s' can not be registered.", scope))
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,391 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package rfc7591
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/internal"
+	"github.com/ory/fosite/storage"
+	"github.com/ory/fosite/token/jwt"
+)
+
+var _ ClientManager = storage.NewMemoryStore()
+
+func newRegistrationHandler(store *storage.MemoryStore) *Handler {
+	return &Handler{
+		Storage:              store,
+		Hasher:               &fosite.BCrypt{WorkFactor: 4},
+		RegistrationEndpoint: "https://auth.example.com/register",
+	}
+}
+
+func newRequest(method, body, token string) *http.Request {
+	r := httptest.NewRequest(method, "https://auth.example.com/register", strings.NewReader(body))
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	return r
+}
+
+func register(t *testing.T, h *Handler, body string) *ClientRegistrationResponse {
+	req, err := h.NewClientRegistrationRequest(context.Background(), newRequest(http.MethodPost, body, ""))
+	require.NoError(t, err)
+	resp, err := h.HandleClientRegistrationRequest(context.Background(), req)
+	require.NoError(t, err)
+	return resp
+}
+
+func TestClientRegistration(t *testing.T) {
+	jwks := `{"keys":[{"kty":"oct","k":"Zm9v"}]}`
+	sector := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		_, _ = rw.Write([]byte(`["https://a.example.com/cb","https://b.example.com/cb"]`))
+	}))
+	defer sector.Close()
+	pairwise := func(h *Handler) {
+		h.SubjectTypes = []string{fosite.SubjectTypePublic, fosite.SubjectTypePairwise}
+		h.HTTPClient = sector.Client()
+	}
+	for k, c := range []struct {
+		d      string
+		body   string
+		method string
+		h      func(h *Handler)
+		expect error
+		check  func(t *testing.T, resp *ClientRegistrationResponse)
+	}{
+		{
+			d:    "should register a confidential client with defaults",
+			body: `{"redirect_uris":["https://client.example.com/cb"],"client_name":"foo"}`,
+			check: func(t *testing.T, resp *ClientRegistrationResponse) {
+				assert.NotEmpty(t, resp.ClientSecret)
+				assert.Equal(t, "client_secret_basic", resp.TokenEndpointAuthMethod)
+				assert.Equal(t, []string{"authorization_code"}, resp.GrantTypes)
+				assert.Equal(t, []string{"code"}, resp.ResponseTypes)
+				assert.Equal(t, "foo", resp.ClientName)
+			},
+		},
+		{
+			d:    "should register a public client without a secret",
+			body: `{"redirect_uris":["http://localhost/cb"],"token_endpoint_auth_method":"none"}`,
+			check: func(t *testing.T, resp *ClientRegistrationResponse) {
+				assert.Empty(t, resp.ClientSecret)
+			},
+		},
+		{
+			d:    "should register a client with all allowed scopes by default",
+			body: `{"redirect_uris":["https://client.example.com/cb"]}`,
+			h: func(h *Handler) {
+				h.AllowedScopes = []string{"openid", "offline"}
+			},
+			check: func(t *testing.T, resp *ClientRegistrationResponse) {
+				assert.Equal(t, "openid offline", resp.Scope)
+			},
+		},
+		{
+			d:    "should register a pairwise client with a sector identifier uri",
+			body: `{"redirect_uris":["https://a.example.com/cb","https://b.example.com/cb"],"subject_type":"pairwise","sector_identifier_uri":"` + sector.URL + `"}`,
+			h:    pairwise,
+			check: func(t *testing.T, resp *ClientRegistrationResponse) {
+				assert.Equal(t, fosite.SubjectTypePairwise, resp.SubjectType)
+				assert.Equal(t, sector.URL, resp.SectorIdentifierURI)
+			},
+		},
+		{
+			d:    "should register a pairwise client whose redirect uris share a host",
+			body: `{"redirect_uris":["https://a.example.com/cb","https://a.example.com/other"],"subject_type":"pairwise"}`,
+			h:    pairwise,
+			check: func(t *testing.T, resp *ClientRegistrationResponse) {
+				assert.Equal(t, fosite.SubjectTypePairwise, resp.SubjectType)
+			},
+		},
+		{
+			d:      "should fail because the subject type is not supported",
+			body:   `{"redirect_uris":["https://client.example.com/cb"],"subject_type":"pairwise"}`,
+			expect: ErrInvalidClientMetadata,
+		},
+		{
+			d:      "should fail because the sector identifier uri does not contain the redirect uri",
+			body:   `{"redirect_uris":["https://c.example.com/cb"],"subject_type":"pairwise","sector_identifier_uri":"` + sector.URL + `"}`,
+			h:      pairwise,
+			expect: ErrInvalidClientMetadata,
+		},
+		{
+			d:      "should fail because pairwise redirect uris with different hosts require a sector identifier uri",
+			body:   `{"redirect_uris":["https://a.example.com/cb","https://b.example.com/cb"],"subject_type":"pairwise"}`,
+			h:      pairwise,
+			expect: ErrInvalidClientMetadata,
+		},
+		{
+			d:      "should fail because the method is not POST",
+			method: http.MethodGet,
+			expect: fosite.ErrInvalidRequest,
+		},
+		{
+			d:      "should fail because the body is not JSON",
+			body:   `foo`,
+			expect: ErrInvalidClientMetadata,
+		},
+		{
+			d:      "should fail because redirect uris are missing",
+			body:   `{}`,
+			expect: ErrInvalidRedirectURI,
+		},
+		{
+			d:      "should fail because the redirect uri is not secure",
+			body:   `{"redirect_uris":["http://client.example.com/cb"]}`,
+			expect: ErrInvalidRedirectURI,
+		},
+		{
+			d:      "should fail because the redirect uri contains a fragment",
+			body:   `{"redirect_uris":["https://client.example.com/cb#foo"]}`,
+			expect: ErrInvalidRedirectURI,
+		},
+		{
+			d:      "should fail because the implicit response type requires the implicit grant",
+			body:   `{"redirect_uris":["https://client.example.com/cb"],"response_types":["token"]}`,
+			expect: ErrInvalidClientMetadata,
+		},
+		{
+			d:      "should fail because private_key_jwt requires keys",
+			body:   `{"redirect_uris":["https://client.example.com/cb"],"token_endpoint_auth_method":"private_key_jwt"}`,
+			expect: ErrInvalidClientMetadata,
+		},
+		{
+			d:      "should fail because jwks and jwks_uri are mutually exclusive",
+			body:   `{"redirect_uris":["https://client.example.com/cb"],"token_endpoint_auth_method":"private_key_jwt","jwks_uri":"https://client.example.com/jwks","jwks":` + jwks + `}`,
+			expect: ErrInvalidClientMetadata,
+		},
+		{
+			d:      "should fail because the auth method is not allowed",
+			body:   `{"redirect_uris":["https://client.example.com/cb"],"token_endpoint_auth_method":"none"}`,
+			h:      func(h *Handler) { h.TokenEndpointAuthMethods = []string{"client_secret_basic"} },
+			expect: ErrInvalidClientMetadata,
+		},
+		{
+			d:      "should fail because the scope is not allowed",
+			body:   `{"redirect_uris":["https://client.example.com/cb"],"scope":"openid admin"}`,
+			h:      func(h *Handler) { h.AllowedScopes = []string{"openid"} },
+			expect: ErrInvalidClientMetadata,
+		},
+		{
+			d:      "should fail because the logo uri is relative",
+			body:   `{"redirect_uris":["https://client.example.com/cb"],"logo_uri":"/logo.png"}`,
+			expect: ErrInvalidClientMetadata,
+		},
+		{
+			d:      "should fail because software statements are not accepted",
+			body:   `{"redirect_uris":["https://client.example.com/cb"],"software_statement":"foo"}`,
+			expect: ErrUnapprovedSoftwareStatement,
+		},
+		{
+			d:      "should fail because a software statement is required",
+			body:   `{"redirect_uris":["https://client.example.com/cb"]}`,
+			h:      func(h *Handler) { h.RequireSoftwareStatement = true },
+			expect: ErrInvalidSoftwareStatement,
+		},
+	} {
+		t.Run(c.d, func(t *testing.T) {
+			store := storage.NewMemoryStore()
+			h := newRegistrationHandler(store)
+			if c.h != nil {
+				c.h(h)
+			}
+
+			method := c.method
+			if method == "" {
+				method = http.MethodPost
+			}
+
+			req, err := h.NewClientRegistrationRequest(context.Background(), newRequest(method, c.body, ""))
+			if c.expect != nil {
+				require.Error(t, err)
+				assert.True(t, errors.Is(err, c.expect), "%d: %+v", k, err)
+				return
+			}
+			require.NoError(t, err)
+
+			resp, err := h.HandleClientRegistrationRequest(context.Background(), req)
+			require.NoError(t, err)
+			assert.NotEmpty(t, resp.ClientID)
+			assert.NotEmpty(t, resp.RegistrationAccessToken)
+			assert.Equal(t, "https://auth.example.com/register/"+resp.ClientID, resp.RegistrationClientURI)
+			assert.EqualValues(t, 0, resp.ClientSecretExpiresAt)
+
+			stored, err := store.GetClient(context.Background(), resp.ClientID)
+			require.NoError(t, err)
+			assert.Equal(t, resp.RedirectURIs, stored.GetRedirectURIs())
+			if resp.ClientSecret != "" {
+				require.NoError(t, h.Hasher.Compare(context.Background(), stored.GetHashedSecret(), []byte(resp.ClientSecret)))
+			}
+			assert.Equal(t, resp.TokenEndpointAuthMethod == "none", stored.IsPublic())
+
+			c.check(t, resp)
+		})
+	}
+}
+
+func TestClientRegistrationWithSoftwareStatement(t *testing.T) {
+	key := internal.MustRSAKey()
+	h := newRegistrationHandler(storage.NewMemoryStore())
+	h.SoftwareStatementValidator = &JWKSSoftwareStatementValidator{
+		TrustedIssuers: map[string]*jose.JSONWebKeySet{
+			"https://issuer.example.com": {Keys: []jose.JSONWebKey{{Key: &key.PublicKey, KeyID: "foo"}}},
+		},
+	}
+
+	sign := func(t *testing.T, method jose.SignatureAlgorithm, claims jwt.MapClaims, k interface{}) string {
+		token := jwt.NewWithClaims(method, claims)
+		token.Header["kid"] = "foo"
+		statement, err := token.SignedString(k)
+		require.NoError(t, err)
+		return statement
+	}
+
+	t.Run("case=claims override the client metadata", func(t *testing.T) {
+		statement := sign(t, jose.RS256, jwt.MapClaims{
+			"iss":         "https://issuer.example.com",
+			"software_id": "software",
+			"client_name": "trusted",
+		}, key)
+		resp := register(t, h, `{"redirect_uris":["https://client.example.com/cb"],"client_name":"untrusted","software_statement":"`+statement+`"}`)
+		assert.Equal(t, "trusted", resp.ClientName)
+		assert.Equal(t, "software", resp.SoftwareID)
+	})
+
+	t.Run("case=the issuer is not trusted", func(t *testing.T) {
+		statement := sign(t, jose.RS256, jwt.MapClaims{"iss": "https://evil.example.com"}, key)
+		_, err := h.NewClientRegistrationRequest(context.Background(), newRequest(http.MethodPost, `{"redirect_uris":["https://client.example.com/cb"],"software_statement":"`+statement+`"}`, ""))
+		assert.True(t, errors.Is(err, ErrUnapprovedSoftwareStatement), "%+v", err)
+	})
+
+	t.Run("case=the signature is invalid", func(t *testing.T) {
+		statement := sign(t, jose.RS256, jwt.MapClaims{"iss": "https://issuer.example.com"}, internal.MustRSAKey())
+		_, err := h.NewClientRegistrationRequest(context.Background(), newRequest(http.MethodPost, `{"redirect_uris":["https://client.example.com/cb"],"software_statement":"`+statement+`"}`, ""))
+		assert.True(t, errors.Is(err, ErrInvalidSoftwareStatement), "%+v", err)
+	})
+}
+
+func TestClientConfiguration(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	h := newRegistrationHandler(store)
+	registered := register(t, h, `{"redirect_uris":["https://client.example.com/cb"],"client_name":"foo"}`)
+
+	configure := func(t *testing.T, method, body, token string) (*ClientRegistrationResponse, error) {
+		req, err := h.NewClientConfigurationRequest(ctx, newRequest(method, body, token), registered.ClientID)
+		if err != nil {
+			return nil, err
+		}
+		return h.HandleClientConfigurationRequest(ctx, req)
+	}
+
+	t.Run("case=fails without a registration access token", func(t *testing.T) {
+		_, err := configure(t, http.MethodGet, "", "")
+		assert.True(t, errors.Is(err, ErrInvalidRegistrationAccessToken), "%+v", err)
+	})
+
+	t.Run("case=fails with an invalid registration access token", func(t *testing.T) {
+		_, err := configure(t, http.MethodGet, "", "foo")
+		assert.True(t, errors.Is(err, ErrInvalidRegistrationAccessToken), "%+v", err)
+
+		rw := httptest.NewRecorder()
+		h.WriteClientRegistrationError(ctx, rw, err)
+		assert.Equal(t, http.StatusUnauthorized, rw.Code)
+		assert.Equal(t, `Bearer error="invalid_token"`, rw.Header().Get("WWW-Authenticate"))
+	})
+
+	token := registered.RegistrationAccessToken
+	t.Run("case=reads the client and rotates the registration access token", func(t *testing.T) {
+		resp, err := configure(t, http.MethodGet, "", token)
+		require.NoError(t, err)
+		assert.Equal(t, registered.ClientID, resp.ClientID)
+		assert.Equal(t, "foo", resp.ClientName)
+		assert.Empty(t, resp.ClientSecret)
+		assert.NotEqual(t, token, resp.RegistrationAccessToken)
+
+		_, err = configure(t, http.MethodGet, "", token)
+		assert.True(t, errors.Is(err, ErrInvalidRegistrationAccessToken), "%+v", err)
+		token = resp.RegistrationAccessToken
+	})
+
+	t.Run("case=fails to update because the client id does not match", func(t *testing.T) {
+		_, err := configure(t, http.MethodPut, `{"client_id":"foo","redirect_uris":["https://client.example.com/cb"]}`, token)
+		assert.True(t, errors.Is(err, fosite.ErrInvalidRequest), "%+v", err)
+	})
+
+	t.Run("case=fails to update because the client secret does not match", func(t *testing.T) {
+		_, err := configure(t, http.MethodPut, `{"client_id":"`+registered.ClientID+`","client_secret":"foo","redirect_uris":["https://client.example.com/cb"]}`, token)
+		assert.True(t, errors.Is(err, fosite.ErrInvalidRequest), "%+v", err)
+	})
+
+	t.Run("case=updates the client", func(t *testing.T) {
+		resp, err := configure(t, http.MethodPut, `{"client_id":"`+registered.ClientID+`","client_secret":"`+registered.ClientSecret+`","redirect_uris":["https://client.example.com/other"],"client_name":"bar"}`, token)
+		require.NoError(t, err)
+		assert.Equal(t, "bar", resp.ClientName)
+		assert.Empty(t, resp.ClientSecret)
+		token = resp.RegistrationAccessToken
+
+		stored, err := store.GetClient(ctx, registered.ClientID)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"https://client.example.com/other"}, stored.GetRedirectURIs())
+		require.NoError(t, h.Hasher.Compare(ctx, stored.GetHashedSecret(), []byte(registered.ClientSecret)))
+	})
+
+	t.Run("case=deletes the client", func(t *testing.T) {
+		resp, err := configure(t, http.MethodDelete, "", token)
+		require.NoError(t, err)
+		assert.Nil(t, resp)
+
+		rw := httptest.NewRecorder()
+		h.WriteClientConfigurationResponse(ctx, rw, resp)
+		assert.Equal(t, http.StatusNoContent, rw.Code)
+
+		_, err = store.GetClient(ctx, registered.ClientID)
+		assert.True(t, errors.Is(err, fosite.ErrNotFound))
+	})
+}
+
+func TestWriteClientRegistrationResponse(t *testing.T) {
+	rw := httptest.NewRecorder()
+	h := newRegistrationHandler(storage.NewMemoryStore())
+	h.WriteClientRegistrationResponse(context.Background(), rw, &ClientRegistrationResponse{ClientID: "foo"})
+
+	assert.Equal(t, http.StatusCreated, rw.Code)
+	assert.Equal(t, "no-store", rw.Header().Get("Cache-Control"))
+
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &body))
+	assert.Equal(t, "foo", body["client_id"])
+	assert.EqualValues(t, 0, body["client_secret_expires_at"])
+}
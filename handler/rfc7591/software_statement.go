@@ -0,0 +1,81 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package rfc7591
+
+import (
+	"context"
+
+	"github.com/ory/x/errorsx"
+	"github.com/pkg/errors"
+	"gopkg.in/square/go-jose.v2"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/token/jwt"
+)
+
+// SoftwareStatementValidator validates software statements, see https://tools.ietf.org/html/rfc7591#section-2.3
+type SoftwareStatementValidator interface {
+	// ValidateSoftwareStatement verifies the software statement and returns its claims. The claims take precedence
+	// over the client metadata sent alongside the statement. ErrInvalidSoftwareStatement should be returned if the
+	// statement can not be verified, and ErrUnapprovedSoftwareStatement if it is not trusted.
+	ValidateSoftwareStatement(ctx context.Context, statement string) (jwt.MapClaims, error)
+}
+
+// JWKSSoftwareStatementValidator accepts software statements signed by a trusted issuer.
+type JWKSSoftwareStatementValidator struct {
+	// TrustedIssuers maps the "iss" claim of accepted software statements to the keys they are signed with.
+	TrustedIssuers map[string]*jose.JSONWebKeySet
+}
+
+func (v *JWKSSoftwareStatementValidator) ValidateSoftwareStatement(ctx context.Context, statement string) (jwt.MapClaims, error) {
+	token, err := jwt.ParseWithClaims(statement, jwt.MapClaims{}, func(t *jwt.Token) (interface{}, error) {
+		if t.Method == jwt.SigningMethodNone {
+			return nil, errorsx.WithStack(ErrInvalidSoftwareStatement.WithHint("The software statement must be signed."))
+		}
+
+		iss, _ := t.Claims["iss"].(string)
+		set, ok := v.TrustedIssuers[iss]
+		if !ok || set == nil {
+			return nil, errorsx.WithStack(ErrUnapprovedSoftwareStatement.WithHintf("The issuer '%s' of the software statement is not trusted.", iss))
+		}
+
+		keys := set.Keys
+		if kid, ok := t.Header["kid"].(string); ok {
+			keys = set.Key(kid)
+		}
+		if len(keys) == 0 {
+			return nil, errorsx.WithStack(ErrInvalidSoftwareStatement.WithHint("The key the software statement is signed with is unknown."))
+		}
+		return &keys[0], nil
+	})
+	if err != nil {
+		// Do not re-process already enhanced errors
+		var ve *jwt.ValidationError
+		var rfcErr *fosite.RFC6749Error
+		if errors.As(err, &ve) && ve.Inner != nil && errors.As(ve.Inner, &rfcErr) {
+			return nil, ve.Inner
+		}
+		return nil, errorsx.WithStack(ErrInvalidSoftwareStatement.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	return token.Claims, nil
+}
@@ -0,0 +1,43 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package rfc7591
+
+import (
+	"context"
+
+	"github.com/ory/fosite"
+)
+
+// ClientManager handles the storage of dynamically registered clients. GetClient must return the *Client values
+// stored using CreateClient and UpdateClient, and fosite.ErrNotFound if the client does not exist.
+type ClientManager interface {
+	fosite.ClientManager
+
+	// CreateClient stores a newly registered client.
+	CreateClient(ctx context.Context, client fosite.Client) (err error)
+
+	// UpdateClient replaces the stored client with the same ID.
+	UpdateClient(ctx context.Context, client fosite.Client) (err error)
+
+	// DeleteClient removes the client. Subsequent calls to GetClient must return fosite.ErrNotFound.
+	DeleteClient(ctx context.Context, id string) (err error)
+}
@@ -0,0 +1,416 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package rfc8628
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"github.com/ory/x/errorsx"
+	"github.com/pkg/errors"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/oauth2"
+	"github.com/ory/fosite/storage"
+)
+
+const grantTypeDeviceCode = "urn:ietf:params:oauth:grant-type:device_code"
+
+// DefaultPollingInterval is the minimum amount of time the client should wait between polling requests if no
+// interval is configured. See https://tools.ietf.org/html/rfc8628#section-3.2
+const DefaultPollingInterval = time.Second * 5
+
+// SlowDownIntervalIncrement is added to the polling interval each time the device is told to slow down.
+const SlowDownIntervalIncrement = time.Second * 5
+
+// DeviceHandler implements the device authorization grant as defined in https://tools.ietf.org/html/rfc8628.
+// It handles the device authorization endpoint as well as the device code grant at the token endpoint and offers
+// helpers for implementing the end user verification page.
+type DeviceHandler struct {
+	DeviceStrategy       RFC8628CodeStrategy
+	DeviceStorage        RFC8628CodeStorage
+	AccessTokenStrategy  oauth2.AccessTokenStrategy
+	RefreshTokenStrategy oauth2.RefreshTokenStrategy
+	AccessTokenStorage   oauth2.AccessTokenStorage
+	RefreshTokenStorage  oauth2.RefreshTokenStorage
+
+	// AccessTokenLifespan defines the lifetime of an access token.
+	AccessTokenLifespan time.Duration
+
+	// RefreshTokenLifespan defines the lifetime of a refresh token.
+	RefreshTokenLifespan time.Duration
+
+	// DeviceAndUserCodeLifespan defines the lifetime of the device and user code.
+	DeviceAndUserCodeLifespan time.Duration
+
+	// VerificationURI is the end-user verification URI on the authorization server.
+	VerificationURI string
+
+	// PollingInterval is the minimum amount of time that the client should wait between polling requests to the
+	// token endpoint. Defaults to DefaultPollingInterval.
+	PollingInterval time.Duration
+
+	RefreshTokenScopes []string
+}
+
+func (c *DeviceHandler) pollingInterval() time.Duration {
+	if c.PollingInterval <= 0 {
+		return DefaultPollingInterval
+	}
+	return c.PollingInterval
+}
+
+// HandleDeviceEndpointRequest implements https://tools.ietf.org/html/rfc8628#section-3.2
+func (c *DeviceHandler) HandleDeviceEndpointRequest(ctx context.Context, requester fosite.DeviceRequester, responder fosite.DeviceResponder) error {
+	if !requester.GetClient().GetGrantTypes().Has(grantTypeDeviceCode) {
+		return errorsx.WithStack(fosite.ErrUnauthorizedClient.WithHintf("The OAuth 2.0 Client is not allowed to use authorization grant \"%s\".", grantTypeDeviceCode))
+	}
+
+	deviceCode, deviceCodeSignature, err := c.DeviceStrategy.GenerateDeviceCode(ctx, requester)
+	if err != nil {
+		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	userCode, userCodeSignature, err := c.DeviceStrategy.GenerateUserCode(ctx, requester)
+	if err != nil {
+		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	expiresAt := time.Now().UTC().Add(c.DeviceAndUserCodeLifespan).Round(time.Second)
+	requester.GetSession().SetExpiresAt(fosite.DeviceCode, expiresAt)
+	requester.GetSession().SetExpiresAt(fosite.UserCode, expiresAt)
+
+	ctx, err = storage.MaybeBeginTx(ctx, c.DeviceStorage)
+	if err != nil {
+		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	if err := fosite.StorageOperation(ctx, "CreateDeviceCodeSession", func(ctx context.Context) error {
+		return c.DeviceStorage.CreateDeviceCodeSession(ctx, deviceCodeSignature, requester.Sanitize(nil))
+	}); err != nil {
+		return rollback(ctx, c.DeviceStorage, err)
+	} else if err := fosite.StorageOperation(ctx, "CreateUserCodeSession", func(ctx context.Context) error {
+		return c.DeviceStorage.CreateUserCodeSession(ctx, userCodeSignature, requester.Sanitize(nil))
+	}); err != nil {
+		return rollback(ctx, c.DeviceStorage, err)
+	}
+
+	if err := storage.MaybeCommitTx(ctx, c.DeviceStorage); err != nil {
+		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	responder.SetDeviceCode(deviceCode)
+	responder.SetUserCode(userCode)
+	responder.SetVerificationURI(c.VerificationURI)
+	if c.VerificationURI != "" {
+		responder.SetVerificationURIComplete(c.VerificationURI + "?" + url.Values{"user_code": {userCode}}.Encode())
+	}
+	responder.SetExpiresIn(time.Duration(expiresAt.UnixNano() - time.Now().UTC().UnixNano()))
+	responder.SetInterval(c.pollingInterval())
+	return nil
+}
+
+// HandleTokenEndpointRequest implements https://tools.ietf.org/html/rfc8628#section-3.4 and
+// https://tools.ietf.org/html/rfc8628#section-3.5
+func (c *DeviceHandler) HandleTokenEndpointRequest(ctx context.Context, request fosite.AccessRequester) error {
+	if !c.CanHandleTokenEndpointRequest(request) {
+		return errorsx.WithStack(fosite.ErrUnknownRequest)
+	}
+
+	if !request.GetClient().GetGrantTypes().Has(grantTypeDeviceCode) {
+		return errorsx.WithStack(fosite.ErrUnauthorizedClient.WithHintf("The OAuth 2.0 Client is not allowed to use authorization grant \"%s\".", grantTypeDeviceCode))
+	}
+
+	code := request.GetRequestForm().Get("device_code")
+	if code == "" {
+		return errorsx.WithStack(fosite.ErrInvalidRequest.WithHintf("The device_code request parameter must be set when using grant_type of '%s'.", grantTypeDeviceCode))
+	}
+
+	signature := c.DeviceStrategy.DeviceCodeSignature(code)
+	var deviceRequest fosite.Requester
+	err := fosite.StorageOperation(ctx, "GetDeviceCodeSession", func(ctx context.Context) (err error) {
+		deviceRequest, err = c.DeviceStorage.GetDeviceCodeSession(ctx, signature, request.GetSession())
+		return err
+	})
+	if errors.Is(err, fosite.ErrNotFound) {
+		return errorsx.WithStack(fosite.ErrInvalidGrant.WithWrap(err).WithDebug(err.Error()))
+	} else if errors.Is(err, fosite.ErrInvalidatedDeviceCode) {
+		return errorsx.WithStack(fosite.ErrInvalidGrant.WithHint("The device code has already been used."))
+	} else if err != nil && !errors.Is(err, fosite.ErrAuthorizationPending) && !errors.Is(err, fosite.ErrAccessDenied) {
+		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	} else if deviceRequest == nil {
+		return errorsx.WithStack(fosite.ErrServerError.
+			WithHint("Misconfigured code lead to an error that prohibited the OAuth 2.0 Framework from processing this request.").
+			WithDebug("GetDeviceCodeSession must return a value for \"fosite.Requester\"."))
+	}
+	state := err
+
+	// This needs to happen after store retrieval for the session to be hydrated properly
+	if err := c.DeviceStrategy.ValidateDeviceCode(ctx, deviceRequest, code); errors.Is(err, fosite.ErrTokenExpired) {
+		return errorsx.WithStack(fosite.ErrDeviceExpiredToken.WithWrap(err).WithDebug(err.Error()))
+	} else if err != nil {
+		return errorsx.WithStack(fosite.ErrInvalidGrant.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	if deviceRequest.GetClient().GetID() != request.GetClient().GetID() {
+		return errorsx.WithStack(fosite.ErrInvalidGrant.WithHint("The OAuth 2.0 Client ID from this request does not match the one from the device authorization request."))
+	}
+
+	now := time.Now().UTC()
+	var lastPolledAt time.Time
+	var interval time.Duration
+	err = fosite.StorageOperation(ctx, "TouchDeviceCodeSession", func(ctx context.Context) (err error) {
+		lastPolledAt, interval, err = c.DeviceStorage.TouchDeviceCodeSession(ctx, signature, now)
+		return err
+	})
+	if err != nil {
+		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	} else if interval <= 0 {
+		interval = c.pollingInterval()
+	}
+
+	if !lastPolledAt.IsZero() && now.Sub(lastPolledAt) < interval {
+		// The interval MUST be increased by 5 seconds for this and all subsequent requests, see
+		// https://tools.ietf.org/html/rfc8628#section-3.5
+		interval += SlowDownIntervalIncrement
+		if err := fosite.StorageOperation(ctx, "SlowDownDeviceCodeSession", func(ctx context.Context) error {
+			return c.DeviceStorage.SlowDownDeviceCodeSession(ctx, signature, interval)
+		}); err != nil {
+			return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+		}
+		return errorsx.WithStack(fosite.ErrSlowDown.WithHintf("The polling interval has been increased to %d seconds.", int64(interval/time.Second)))
+	}
+
+	if errors.Is(state, fosite.ErrAuthorizationPending) {
+		return errorsx.WithStack(fosite.ErrAuthorizationPending)
+	} else if errors.Is(state, fosite.ErrAccessDenied) {
+		return errorsx.WithStack(fosite.ErrAccessDenied.WithHint("The end user denied the device authorization request."))
+	}
+
+	request.SetRequestedScopes(deviceRequest.GetRequestedScopes())
+	request.SetRequestedAudience(deviceRequest.GetRequestedAudience())
+	request.SetSession(deviceRequest.GetSession().Clone())
+	request.SetID(deviceRequest.GetID())
+	fosite.CarryOverPersistedParameters(request, deviceRequest)
+
+	request.GetSession().SetExpiresAt(fosite.AccessToken, time.Now().UTC().Add(fosite.GetAccessTokenLifespan(ctx, c.AccessTokenLifespan)).Round(time.Second))
+	if lifespan := fosite.GetRefreshTokenLifespan(ctx, c.RefreshTokenLifespan); lifespan > -1 {
+		request.GetSession().SetExpiresAt(fosite.RefreshToken, time.Now().UTC().Add(lifespan).Round(time.Second))
+	}
+
+	return nil
+}
+
+func (c *DeviceHandler) canIssueRefreshToken(request fosite.Requester) bool {
+	// Require one of the refresh token scopes, if set.
+	if len(c.RefreshTokenScopes) > 0 && !request.GetGrantedScopes().HasOneOf(c.RefreshTokenScopes...) {
+		return false
+	}
+	// Do not issue a refresh token to clients that cannot use the refresh token grant type.
+	if !request.GetClient().GetGrantTypes().Has("refresh_token") {
+		return false
+	}
+	return true
+}
+
+func (c *DeviceHandler) PopulateTokenEndpointResponse(ctx context.Context, requester fosite.AccessRequester, responder fosite.AccessResponder) error {
+	if !c.CanHandleTokenEndpointRequest(requester) {
+		return errorsx.WithStack(fosite.ErrUnknownRequest)
+	}
+
+	code := requester.GetRequestForm().Get("device_code")
+	signature := c.DeviceStrategy.DeviceCodeSignature(code)
+	var deviceRequest fosite.Requester
+	err := fosite.StorageOperation(ctx, "GetDeviceCodeSession", func(ctx context.Context) (err error) {
+		deviceRequest, err = c.DeviceStorage.GetDeviceCodeSession(ctx, signature, requester.GetSession())
+		return err
+	})
+	if err != nil {
+		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	} else if err := c.DeviceStrategy.ValidateDeviceCode(ctx, requester, code); err != nil {
+		return errorsx.WithStack(fosite.ErrInvalidRequest.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	for _, scope := range deviceRequest.GetGrantedScopes() {
+		requester.GrantScope(scope)
+	}
+
+	for _, audience := range deviceRequest.GetGrantedAudience() {
+		requester.GrantAudience(audience)
+	}
+	requester.GrantAuthorizationDetails(deviceRequest.GetAuthorizationDetails())
+
+	access, accessSignature, err := c.AccessTokenStrategy.GenerateAccessToken(ctx, requester)
+	if err != nil {
+		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	var refresh, refreshSignature string
+	if c.canIssueRefreshToken(deviceRequest) {
+		refresh, refreshSignature, err = c.RefreshTokenStrategy.GenerateRefreshToken(ctx, requester)
+		if err != nil {
+			return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+		}
+	}
+
+	ctx, err = storage.MaybeBeginTx(ctx, c.DeviceStorage)
+	if err != nil {
+		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	if err := fosite.StorageOperation(ctx, "InvalidateDeviceCodeSession", func(ctx context.Context) error {
+		return c.DeviceStorage.InvalidateDeviceCodeSession(ctx, signature)
+	}); err != nil {
+		return rollback(ctx, c.DeviceStorage, err)
+	} else if err := fosite.StorageOperation(ctx, "CreateAccessTokenSession", func(ctx context.Context) error {
+		return c.AccessTokenStorage.CreateAccessTokenSession(ctx, accessSignature, requester.Sanitize([]string{}))
+	}); err != nil {
+		return rollback(ctx, c.DeviceStorage, err)
+	} else if refreshSignature != "" {
+		if err := oauth2.CreateRefreshTokenSession(ctx, c.RefreshTokenStrategy, c.RefreshTokenStorage, refreshSignature, requester.Sanitize([]string{})); err != nil {
+			return rollback(ctx, c.DeviceStorage, err)
+		}
+	}
+
+	responder.SetAccessToken(access)
+	responder.SetTokenType("bearer")
+	responder.SetExpiresIn(getExpiresIn(requester, fosite.AccessToken, fosite.GetAccessTokenLifespan(ctx, c.AccessTokenLifespan), time.Now().UTC()))
+	responder.SetScopes(requester.GetGrantedScopes())
+	if refresh != "" {
+		responder.SetExtra("refresh_token", refresh)
+	}
+
+	if err := storage.MaybeCommitTx(ctx, c.DeviceStorage); err != nil {
+		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	return nil
+}
+
+func (c *DeviceHandler) CanSkipClientAuth(requester fosite.AccessRequester) bool {
+	return false
+}
+
+func (c *DeviceHandler) CanHandleTokenEndpointRequest(requester fosite.AccessRequester) bool {
+	// grant_type REQUIRED.
+	// Value MUST be set to "urn:ietf:params:oauth:grant-type:device_code"
+	return requester.GetGrantTypes().ExactOne(grantTypeDeviceCode)
+}
+
+// GetUserCodeRequest returns the device authorization request belonging to the user code entered by the end user
+// on the verification page. See https://tools.ietf.org/html/rfc8628#section-3.3
+func (c *DeviceHandler) GetUserCodeRequest(ctx context.Context, userCode string, session fosite.Session) (fosite.Requester, error) {
+	var request fosite.Requester
+	err := c.withUserCodeSignatures(userCode, func(signature string) error {
+		return fosite.StorageOperation(ctx, "GetUserCodeSession", func(ctx context.Context) (err error) {
+			request, err = c.DeviceStorage.GetUserCodeSession(ctx, signature, session)
+			return err
+		})
+	})
+	if errors.Is(err, fosite.ErrNotFound) {
+		return nil, errorsx.WithStack(fosite.ErrInvalidGrant.WithHint("The user code is unknown.").WithWrap(err).WithDebug(err.Error()))
+	} else if errors.Is(err, fosite.ErrInvalidatedUserCode) {
+		return nil, errorsx.WithStack(fosite.ErrInvalidGrant.WithHint("The user code has already been used."))
+	} else if err != nil {
+		return nil, errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	if err := c.DeviceStrategy.ValidateUserCode(ctx, request, userCode); errors.Is(err, fosite.ErrTokenExpired) {
+		return nil, errorsx.WithStack(fosite.ErrDeviceExpiredToken.WithWrap(err).WithDebug(err.Error()))
+	} else if err != nil {
+		return nil, errorsx.WithStack(fosite.ErrInvalidGrant.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	return request, nil
+}
+
+// ApproveUserCode marks the device authorization request belonging to the user code as approved by the end user.
+// The request should be the one returned by GetUserCodeRequest, with the end user's session set and the consented
+// scopes and audience granted.
+func (c *DeviceHandler) ApproveUserCode(ctx context.Context, userCode string, request fosite.Requester) error {
+	// The end user's session usually replaces the one created at the device authorization endpoint, so make sure
+	// the device code does not outlive its original lifespan.
+	if request.GetSession().GetExpiresAt(fosite.DeviceCode).IsZero() {
+		request.GetSession().SetExpiresAt(fosite.DeviceCode, request.GetRequestedAt().Add(c.DeviceAndUserCodeLifespan).Round(time.Second))
+	}
+
+	if err := c.withUserCodeSignatures(userCode, func(signature string) error {
+		return fosite.StorageOperation(ctx, "ApproveUserCodeSession", func(ctx context.Context) error {
+			return c.DeviceStorage.ApproveUserCodeSession(ctx, signature, request.Sanitize(nil))
+		})
+	}); errors.Is(err, fosite.ErrInvalidatedUserCode) {
+		return errorsx.WithStack(fosite.ErrInvalidGrant.WithHint("The user code has already been used."))
+	} else if err != nil {
+		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+	return nil
+}
+
+// DenyUserCode marks the device authorization request belonging to the user code as denied by the end user.
+func (c *DeviceHandler) DenyUserCode(ctx context.Context, userCode string) error {
+	if err := c.withUserCodeSignatures(userCode, func(signature string) error {
+		return fosite.StorageOperation(ctx, "DenyUserCodeSession", func(ctx context.Context) error {
+			return c.DeviceStorage.DenyUserCodeSession(ctx, signature)
+		})
+	}); errors.Is(err, fosite.ErrInvalidatedUserCode) {
+		return errorsx.WithStack(fosite.ErrInvalidGrant.WithHint("The user code has already been used."))
+	} else if err != nil {
+		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+	return nil
+}
+
+// withUserCodeSignatures calls fn with the signatures the user code may be stored under until fn returns an error
+// other than fosite.ErrNotFound, so that user codes issued before the global secret was rotated are still found.
+func (c *DeviceHandler) withUserCodeSignatures(userCode string, fn func(signature string) error) error {
+	signatures, err := c.DeviceStrategy.UserCodeSignatures(userCode)
+	if err != nil {
+		return err
+	}
+
+	err = errorsx.WithStack(fosite.ErrNotFound)
+	for _, signature := range signatures {
+		if err = fn(signature); !errors.Is(err, fosite.ErrNotFound) {
+			return err
+		}
+	}
+	return err
+}
+
+func rollback(ctx context.Context, s interface{}, err error) error {
+	if rollBackTxnErr := storage.MaybeRollbackTx(ctx, s); rollBackTxnErr != nil {
+		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebugf("error: %s; rollback error: %s", err, rollBackTxnErr))
+	}
+	return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+}
+
+func getExpiresIn(r fosite.Requester, key fosite.TokenType, defaultLifespan time.Duration, now time.Time) time.Duration {
+	if r.GetSession().GetExpiresAt(key).IsZero() {
+		return defaultLifespan
+	}
+	return time.Duration(r.GetSession().GetExpiresAt(key).UnixNano() - now.UnixNano())
+}
+
+func (c *DeviceHandler) PopulateDiscoveryMetadata(ctx context.Context, metadata *fosite.DiscoveryMetadata) {
+	metadata.GrantTypesSupported = append(metadata.GrantTypesSupported, grantTypeDeviceCode)
+}
@@ -0,0 +1,260 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package rfc8628
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/oauth2"
+	"github.com/ory/fosite/storage"
+	"github.com/ory/fosite/token/hmac"
+)
+
+var _ RFC8628CodeStorage = storage.NewMemoryStore()
+
+func newDeviceHandler(store *storage.MemoryStore) *DeviceHandler {
+	tokens := &oauth2.HMACSHAStrategy{
+		Enigma:               &hmac.HMACStrategy{GlobalSecret: []byte("foobarfoobarfoobarfoobarfoobarfoobarfoobarfoobar")},
+		AccessTokenLifespan:  time.Hour,
+		RefreshTokenLifespan: time.Hour,
+	}
+	return &DeviceHandler{
+		DeviceStrategy:            deviceStrategy,
+		DeviceStorage:             store,
+		AccessTokenStrategy:       tokens,
+		RefreshTokenStrategy:      tokens,
+		AccessTokenStorage:        store,
+		RefreshTokenStorage:       store,
+		AccessTokenLifespan:       time.Hour,
+		RefreshTokenLifespan:      time.Hour,
+		DeviceAndUserCodeLifespan: time.Minute * 10,
+		VerificationURI:           "https://www.example.com/device",
+		PollingInterval:           time.Millisecond * 50,
+	}
+}
+
+func newDeviceAuthorization(t *testing.T, h *DeviceHandler, client fosite.Client) fosite.DeviceResponder {
+	req := fosite.NewDeviceRequest()
+	req.Client = client
+	req.RequestedScope = fosite.Arguments{"foo", "offline"}
+	req.Session = new(fosite.DefaultSession)
+	resp := fosite.NewDeviceResponse()
+	require.NoError(t, h.HandleDeviceEndpointRequest(context.Background(), req, resp))
+	return resp
+}
+
+func newDeviceTokenRequest(client fosite.Client, deviceCode string) *fosite.AccessRequest {
+	req := fosite.NewAccessRequest(new(fosite.DefaultSession))
+	req.GrantTypes = fosite.Arguments{grantTypeDeviceCode}
+	req.Client = client
+	req.Form = url.Values{"device_code": {deviceCode}}
+	return req
+}
+
+func TestDeviceHandler_HandleDeviceEndpointRequest(t *testing.T) {
+	h := newDeviceHandler(storage.NewMemoryStore())
+
+	t.Run("case=fails when the client is not allowed to use the grant", func(t *testing.T) {
+		req := fosite.NewDeviceRequest()
+		req.Client = &fosite.DefaultClient{GrantTypes: fosite.Arguments{"authorization_code"}}
+		req.Session = new(fosite.DefaultSession)
+		err := h.HandleDeviceEndpointRequest(context.Background(), req, fosite.NewDeviceResponse())
+		assert.EqualError(t, err, fosite.ErrUnauthorizedClient.Error())
+	})
+
+	t.Run("case=issues device and user code", func(t *testing.T) {
+		resp := newDeviceAuthorization(t, h, &fosite.DefaultClient{ID: "foo", GrantTypes: fosite.Arguments{grantTypeDeviceCode}})
+		assert.NotEmpty(t, resp.GetDeviceCode())
+		assert.Len(t, resp.GetUserCode(), UserCodeLength)
+		assert.Equal(t, "https://www.example.com/device", resp.GetVerificationURI())
+		assert.Equal(t, "https://www.example.com/device?user_code="+resp.GetUserCode(), resp.GetVerificationURIComplete())
+		assert.InDelta(t, (time.Minute * 10).Seconds(), resp.GetExpiresIn().Seconds(), 2)
+		assert.Equal(t, time.Millisecond*50, resp.GetInterval())
+	})
+}
+
+func TestDeviceHandler_DeviceCodeGrant(t *testing.T) {
+	client := &fosite.DefaultClient{ID: "foo", GrantTypes: fosite.Arguments{grantTypeDeviceCode, "refresh_token"}}
+
+	for _, c := range []struct {
+		d         string
+		setup     func(t *testing.T, h *DeviceHandler, resp fosite.DeviceResponder) *fosite.AccessRequest
+		expectErr error
+	}{
+		{
+			d: "fails with missing device code",
+			setup: func(t *testing.T, h *DeviceHandler, resp fosite.DeviceResponder) *fosite.AccessRequest {
+				return newDeviceTokenRequest(client, "")
+			},
+			expectErr: fosite.ErrInvalidRequest,
+		},
+		{
+			d: "fails with unknown device code",
+			setup: func(t *testing.T, h *DeviceHandler, resp fosite.DeviceResponder) *fosite.AccessRequest {
+				code, _, err := deviceStrategy.GenerateDeviceCode(context.Background(), nil)
+				require.NoError(t, err)
+				return newDeviceTokenRequest(client, code)
+			},
+			expectErr: fosite.ErrInvalidGrant,
+		},
+		{
+			d: "fails while the authorization is pending",
+			setup: func(t *testing.T, h *DeviceHandler, resp fosite.DeviceResponder) *fosite.AccessRequest {
+				return newDeviceTokenRequest(client, resp.GetDeviceCode())
+			},
+			expectErr: fosite.ErrAuthorizationPending,
+		},
+		{
+			d: "fails when polling too fast",
+			setup: func(t *testing.T, h *DeviceHandler, resp fosite.DeviceResponder) *fosite.AccessRequest {
+				err := h.HandleTokenEndpointRequest(context.Background(), newDeviceTokenRequest(client, resp.GetDeviceCode()))
+				require.ErrorIs(t, err, fosite.ErrAuthorizationPending)
+				return newDeviceTokenRequest(client, resp.GetDeviceCode())
+			},
+			expectErr: fosite.ErrSlowDown,
+		},
+		{
+			d: "fails when the end user denied the request",
+			setup: func(t *testing.T, h *DeviceHandler, resp fosite.DeviceResponder) *fosite.AccessRequest {
+				require.NoError(t, h.DenyUserCode(context.Background(), resp.GetUserCode()))
+				return newDeviceTokenRequest(client, resp.GetDeviceCode())
+			},
+			expectErr: fosite.ErrAccessDenied,
+		},
+		{
+			d: "fails when the device code was issued to another client",
+			setup: func(t *testing.T, h *DeviceHandler, resp fosite.DeviceResponder) *fosite.AccessRequest {
+				approve(t, h, resp.GetUserCode())
+				return newDeviceTokenRequest(&fosite.DefaultClient{ID: "bar", GrantTypes: client.GrantTypes}, resp.GetDeviceCode())
+			},
+			expectErr: fosite.ErrInvalidGrant,
+		},
+		{
+			d: "passes when the end user approved the request",
+			setup: func(t *testing.T, h *DeviceHandler, resp fosite.DeviceResponder) *fosite.AccessRequest {
+				approve(t, h, resp.GetUserCode())
+				return newDeviceTokenRequest(client, resp.GetDeviceCode())
+			},
+		},
+	} {
+		t.Run("case="+c.d, func(t *testing.T) {
+			store := storage.NewMemoryStore()
+			h := newDeviceHandler(store)
+			resp := newDeviceAuthorization(t, h, client)
+
+			req := c.setup(t, h, resp)
+			err := h.HandleTokenEndpointRequest(context.Background(), req)
+			if c.expectErr != nil {
+				require.ErrorIs(t, err, c.expectErr)
+				return
+			}
+			require.NoError(t, err)
+
+			token := fosite.NewAccessResponse()
+			require.NoError(t, h.PopulateTokenEndpointResponse(context.Background(), req, token))
+			assert.NotEmpty(t, token.GetAccessToken())
+			assert.NotEmpty(t, token.GetExtra("refresh_token"))
+			assert.Equal(t, fosite.Arguments{"foo", "offline"}, req.GetGrantedScopes())
+			assert.Equal(t, "peter", req.GetSession().GetSubject())
+
+			// The device code can only be exchanged once.
+			time.Sleep(h.PollingInterval)
+			err = h.HandleTokenEndpointRequest(context.Background(), newDeviceTokenRequest(client, resp.GetDeviceCode()))
+			require.ErrorIs(t, err, fosite.ErrInvalidGrant)
+		})
+	}
+}
+
+func TestDeviceHandler_SlowDown(t *testing.T) {
+	client := &fosite.DefaultClient{ID: "foo", GrantTypes: fosite.Arguments{grantTypeDeviceCode}}
+	store := storage.NewMemoryStore()
+	h := newDeviceHandler(store)
+	resp := newDeviceAuthorization(t, h, client)
+
+	require.ErrorIs(t, h.HandleTokenEndpointRequest(context.Background(), newDeviceTokenRequest(client, resp.GetDeviceCode())), fosite.ErrAuthorizationPending)
+	require.ErrorIs(t, h.HandleTokenEndpointRequest(context.Background(), newDeviceTokenRequest(client, resp.GetDeviceCode())), fosite.ErrSlowDown)
+
+	_, interval, err := store.TouchDeviceCodeSession(context.Background(), deviceStrategy.DeviceCodeSignature(resp.GetDeviceCode()), time.Now().UTC())
+	require.NoError(t, err)
+	assert.Equal(t, h.PollingInterval+SlowDownIntervalIncrement, interval)
+
+	// The increased interval applies to all subsequent requests.
+	time.Sleep(h.PollingInterval)
+	require.ErrorIs(t, h.HandleTokenEndpointRequest(context.Background(), newDeviceTokenRequest(client, resp.GetDeviceCode())), fosite.ErrSlowDown)
+}
+
+func TestDeviceHandler_UserCode(t *testing.T) {
+	h := newDeviceHandler(storage.NewMemoryStore())
+	resp := newDeviceAuthorization(t, h, &fosite.DefaultClient{ID: "foo", GrantTypes: fosite.Arguments{grantTypeDeviceCode}})
+
+	_, err := h.GetUserCodeRequest(context.Background(), "BCDFGHJK", new(fosite.DefaultSession))
+	require.ErrorIs(t, err, fosite.ErrInvalidGrant)
+
+	req, err := h.GetUserCodeRequest(context.Background(), resp.GetUserCode()[:4]+"-"+resp.GetUserCode()[4:], new(fosite.DefaultSession))
+	require.NoError(t, err)
+	assert.Equal(t, "foo", req.GetClient().GetID())
+	assert.Equal(t, fosite.Arguments{"foo", "offline"}, req.GetRequestedScopes())
+
+	require.NoError(t, h.DenyUserCode(context.Background(), resp.GetUserCode()))
+	_, err = h.GetUserCodeRequest(context.Background(), resp.GetUserCode(), new(fosite.DefaultSession))
+	require.ErrorIs(t, err, fosite.ErrInvalidGrant)
+	require.ErrorIs(t, h.DenyUserCode(context.Background(), resp.GetUserCode()), fosite.ErrInvalidGrant)
+}
+
+func TestDeviceHandler_UserCodeWithRotatedSecret(t *testing.T) {
+	client := &fosite.DefaultClient{ID: "foo", GrantTypes: fosite.Arguments{grantTypeDeviceCode}}
+	h := newDeviceHandler(storage.NewMemoryStore())
+	resp := newDeviceAuthorization(t, h, client)
+	denied := newDeviceAuthorization(t, h, client)
+
+	h.DeviceStrategy = &DefaultDeviceStrategy{
+		Enigma: &hmac.HMACStrategy{
+			GlobalSecret:         []byte("bazbarbazbarbazbarbazbarbazbarbazbarbazbarbazbar"),
+			RotatedGlobalSecrets: [][]byte{deviceStrategy.Enigma.GlobalSecret},
+		},
+		DeviceAndUserCodeLifespan: time.Minute * 10,
+	}
+
+	approve(t, h, resp.GetUserCode())
+	require.NoError(t, h.DenyUserCode(context.Background(), denied.GetUserCode()))
+
+	_, err := h.GetUserCodeRequest(context.Background(), resp.GetUserCode(), new(fosite.DefaultSession))
+	require.ErrorIs(t, err, fosite.ErrInvalidGrant)
+	require.ErrorIs(t, h.HandleTokenEndpointRequest(context.Background(), newDeviceTokenRequest(client, denied.GetDeviceCode())), fosite.ErrAccessDenied)
+}
+
+func approve(t *testing.T, h *DeviceHandler, userCode string) {
+	req, err := h.GetUserCodeRequest(context.Background(), userCode, new(fosite.DefaultSession))
+	require.NoError(t, err)
+	req.SetSession(&fosite.DefaultSession{Subject: "peter"})
+	for _, scope := range req.GetRequestedScopes() {
+		req.GrantScope(scope)
+	}
+	require.NoError(t, h.ApproveUserCode(context.Background(), userCode, req))
+}
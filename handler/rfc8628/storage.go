@@ -0,0 +1,83 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package rfc8628
+
+import (
+	"context"
+	"time"
+
+	"github.com/ory/fosite"
+)
+
+// RFC8628CodeStorage handles the storage of device and user code sessions.
+type RFC8628CodeStorage interface {
+	DeviceCodeStorage
+	UserCodeStorage
+}
+
+// DeviceCodeStorage handles storage requests related to device codes.
+type DeviceCodeStorage interface {
+	// CreateDeviceCodeSession stores the device authorization request for a given device code signature.
+	CreateDeviceCodeSession(ctx context.Context, signature string, request fosite.Requester) (err error)
+
+	// GetDeviceCodeSession hydrates the session based on the given device code signature and returns the device
+	// authorization request. As long as the end user has not approved or denied the request, this method should
+	// return fosite.ErrAuthorizationPending. If the end user denied the request, it should return
+	// fosite.ErrAccessDenied. If the device code has been invalidated with `InvalidateDeviceCodeSession`, this
+	// method should return fosite.ErrInvalidatedDeviceCode.
+	//
+	// Make sure to also return the fosite.Requester value when returning any of the errors above!
+	GetDeviceCodeSession(ctx context.Context, signature string, session fosite.Session) (request fosite.Requester, err error)
+
+	// InvalidateDeviceCodeSession is called when a device code is being exchanged for tokens. Consecutive requests
+	// to GetDeviceCodeSession should return fosite.ErrInvalidatedDeviceCode.
+	InvalidateDeviceCodeSession(ctx context.Context, signature string) (err error)
+
+	// TouchDeviceCodeSession records that the device polled the token endpoint at the given time and returns the
+	// time of the previous poll, or the zero time if this is the first poll, as well as the polling interval stored
+	// using SlowDownDeviceCodeSession, or zero if none was stored.
+	TouchDeviceCodeSession(ctx context.Context, signature string, now time.Time) (lastPolledAt time.Time, interval time.Duration, err error)
+
+	// SlowDownDeviceCodeSession stores the increased polling interval the device must wait between polling requests
+	// after it was told to slow down.
+	SlowDownDeviceCodeSession(ctx context.Context, signature string, interval time.Duration) (err error)
+}
+
+// UserCodeStorage handles storage requests related to user codes.
+type UserCodeStorage interface {
+	// CreateUserCodeSession stores the device authorization request for a given user code signature.
+	CreateUserCodeSession(ctx context.Context, signature string, request fosite.Requester) (err error)
+
+	// GetUserCodeSession hydrates the session based on the given user code signature and returns the device
+	// authorization request. If the user code has already been approved or denied, this method should return
+	// fosite.ErrInvalidatedUserCode alongside the fosite.Requester value.
+	GetUserCodeSession(ctx context.Context, signature string, session fosite.Session) (request fosite.Requester, err error)
+
+	// ApproveUserCodeSession invalidates the user code and marks the device code session sharing the same request
+	// ID as approved. The stored device code session must be replaced with the given request, as it contains the
+	// session of the authenticated end user and the granted scopes and audience.
+	ApproveUserCodeSession(ctx context.Context, signature string, request fosite.Requester) (err error)
+
+	// DenyUserCodeSession invalidates the user code and marks the device code session sharing the same request ID
+	// as denied.
+	DenyUserCodeSession(ctx context.Context, signature string) (err error)
+}
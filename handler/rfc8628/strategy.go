@@ -0,0 +1,53 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package rfc8628
+
+import (
+	"context"
+
+	"github.com/ory/fosite"
+)
+
+// RFC8628CodeStrategy is the strategy for generating and validating device and user codes.
+type RFC8628CodeStrategy interface {
+	DeviceCodeStrategy
+	UserCodeStrategy
+}
+
+// DeviceCodeStrategy handles the generation and validation of device codes, which are used by the device to poll
+// the token endpoint. See https://tools.ietf.org/html/rfc8628#section-3.2
+type DeviceCodeStrategy interface {
+	DeviceCodeSignature(token string) string
+	GenerateDeviceCode(ctx context.Context, requester fosite.Requester) (token string, signature string, err error)
+	ValidateDeviceCode(ctx context.Context, requester fosite.Requester, token string) (err error)
+}
+
+// UserCodeStrategy handles the generation and validation of user codes, which are entered by the end user on the
+// verification page. See https://tools.ietf.org/html/rfc8628#section-6.1
+type UserCodeStrategy interface {
+	UserCodeSignature(token string) (signature string, err error)
+	// UserCodeSignatures returns the signatures the user code may be stored under, starting with the one returned by
+	// UserCodeSignature. This allows looking up user codes issued before a secret was rotated.
+	UserCodeSignatures(token string) (signatures []string, err error)
+	GenerateUserCode(ctx context.Context, requester fosite.Requester) (token string, signature string, err error)
+	ValidateUserCode(ctx context.Context, requester fosite.Requester, token string) (err error)
+}
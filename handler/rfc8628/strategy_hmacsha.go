@@ -0,0 +1,119 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package rfc8628
+
+import (
+	"context"
+	"crypto/rand"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ory/x/errorsx"
+
+	"github.com/ory/fosite"
+	enigma "github.com/ory/fosite/token/hmac"
+)
+
+// UserCodeCharset is the set of characters user codes are made of. It omits vowels to avoid accidentally
+// generating words and is case insensitive, see https://tools.ietf.org/html/rfc8628#section-6.1
+const UserCodeCharset = "BCDFGHJKLMNPQRSTVWXZ"
+
+// UserCodeLength is the number of characters of a generated user code, resulting in about 34.5 bits of entropy.
+const UserCodeLength = 8
+
+type DefaultDeviceStrategy struct {
+	Enigma                    *enigma.HMACStrategy
+	DeviceAndUserCodeLifespan time.Duration
+}
+
+func (h *DefaultDeviceStrategy) DeviceCodeSignature(token string) string {
+	return h.Enigma.Signature(token)
+}
+
+func (h *DefaultDeviceStrategy) GenerateDeviceCode(_ context.Context, _ fosite.Requester) (token string, signature string, err error) {
+	return h.Enigma.Generate()
+}
+
+func (h *DefaultDeviceStrategy) ValidateDeviceCode(_ context.Context, r fosite.Requester, token string) (err error) {
+	if err := h.validateExpiry(r, fosite.DeviceCode, "Device code"); err != nil {
+		return err
+	}
+	return h.Enigma.Validate(token)
+}
+
+// UserCodeSignature normalizes the user code, so that it is case insensitive and ignores dashes and whitespace
+// which might be added for readability, and returns its HMAC.
+func (h *DefaultDeviceStrategy) UserCodeSignature(token string) (string, error) {
+	return h.Enigma.GenerateHMACForString(NormalizeUserCode(token))
+}
+
+// UserCodeSignatures returns the signatures of the normalized user code for the global secret and the rotated
+// global secrets.
+func (h *DefaultDeviceStrategy) UserCodeSignatures(token string) ([]string, error) {
+	return h.Enigma.GenerateHMACsForString(NormalizeUserCode(token))
+}
+
+func (h *DefaultDeviceStrategy) GenerateUserCode(_ context.Context, _ fosite.Requester) (token string, signature string, err error) {
+	max := big.NewInt(int64(len(UserCodeCharset)))
+	code := make([]byte, UserCodeLength)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", "", errorsx.WithStack(err)
+		}
+		code[i] = UserCodeCharset[n.Int64()]
+	}
+
+	token = string(code)
+	signature, err = h.UserCodeSignature(token)
+	if err != nil {
+		return "", "", err
+	}
+	return token, signature, nil
+}
+
+func (h *DefaultDeviceStrategy) ValidateUserCode(_ context.Context, r fosite.Requester, _ string) (err error) {
+	return h.validateExpiry(r, fosite.UserCode, "User code")
+}
+
+func (h *DefaultDeviceStrategy) validateExpiry(r fosite.Requester, key fosite.TokenType, name string) error {
+	var exp = r.GetSession().GetExpiresAt(key)
+	if exp.IsZero() && r.GetRequestedAt().Add(h.DeviceAndUserCodeLifespan).Before(time.Now().UTC()) {
+		return errorsx.WithStack(fosite.ErrTokenExpired.WithHintf("%s expired at '%s'.", name, r.GetRequestedAt().Add(h.DeviceAndUserCodeLifespan)))
+	}
+	if !exp.IsZero() && exp.Before(time.Now().UTC()) {
+		return errorsx.WithStack(fosite.ErrTokenExpired.WithHintf("%s expired at '%s'.", name, exp))
+	}
+	return nil
+}
+
+// NormalizeUserCode upper-cases the user code and removes dashes and whitespace.
+func NormalizeUserCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '-', ' ', '\t':
+			return -1
+		}
+		return r
+	}, strings.ToUpper(code))
+}
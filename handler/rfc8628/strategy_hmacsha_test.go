@@ -0,0 +1,110 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package rfc8628
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/token/hmac"
+)
+
+var deviceStrategy = &DefaultDeviceStrategy{
+	Enigma:                    &hmac.HMACStrategy{GlobalSecret: []byte("foobarfoobarfoobarfoobarfoobarfoobarfoobarfoobar")},
+	DeviceAndUserCodeLifespan: time.Minute * 10,
+}
+
+func TestDefaultDeviceStrategy_UserCode(t *testing.T) {
+	code, signature, err := deviceStrategy.GenerateUserCode(context.Background(), nil)
+	require.NoError(t, err)
+	require.Len(t, code, UserCodeLength)
+	for _, c := range code {
+		assert.Contains(t, UserCodeCharset, string(c))
+	}
+
+	for _, variant := range []string{code, code[:4] + "-" + code[4:], " " + code[:4] + " " + code[4:]} {
+		actual, err := deviceStrategy.UserCodeSignature(variant)
+		require.NoError(t, err)
+		assert.Equal(t, signature, actual, "%s", variant)
+	}
+
+	rotated := &DefaultDeviceStrategy{Enigma: &hmac.HMACStrategy{
+		GlobalSecret:         []byte("bazbarbazbarbazbarbazbarbazbarbazbarbazbarbazbar"),
+		RotatedGlobalSecrets: [][]byte{deviceStrategy.Enigma.GlobalSecret},
+	}}
+	signatures, err := rotated.UserCodeSignatures(code)
+	require.NoError(t, err)
+	require.Len(t, signatures, 2)
+	assert.NotEqual(t, signature, signatures[0])
+	assert.Equal(t, signature, signatures[1])
+
+	other, _, err := deviceStrategy.GenerateUserCode(context.Background(), nil)
+	require.NoError(t, err)
+	assert.NotEqual(t, code, other)
+}
+
+func TestDefaultDeviceStrategy_Validate(t *testing.T) {
+	code, signature, err := deviceStrategy.GenerateDeviceCode(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, signature, deviceStrategy.DeviceCodeSignature(code))
+
+	for k, c := range []struct {
+		d         string
+		expiresAt time.Time
+		expectErr bool
+	}{
+		{d: "valid", expiresAt: time.Now().UTC().Add(time.Hour)},
+		{d: "expired", expiresAt: time.Now().UTC().Add(-time.Hour), expectErr: true},
+	} {
+		t.Run(c.d, func(t *testing.T) {
+			r := &fosite.Request{
+				RequestedAt: time.Now().UTC(),
+				Session: &fosite.DefaultSession{ExpiresAt: map[fosite.TokenType]time.Time{
+					fosite.DeviceCode: c.expiresAt,
+					fosite.UserCode:   c.expiresAt,
+				}},
+			}
+
+			err := deviceStrategy.ValidateDeviceCode(context.Background(), r, code)
+			if c.expectErr {
+				assert.ErrorIs(t, err, fosite.ErrTokenExpired, "%d", k)
+			} else {
+				assert.NoError(t, err, "%d", k)
+			}
+
+			err = deviceStrategy.ValidateUserCode(context.Background(), r, "BCDFGHJK")
+			if c.expectErr {
+				assert.ErrorIs(t, err, fosite.ErrTokenExpired, "%d", k)
+			} else {
+				assert.NoError(t, err, "%d", k)
+			}
+		})
+	}
+
+	r := &fosite.Request{RequestedAt: time.Now().UTC(), Session: new(fosite.DefaultSession)}
+	assert.Error(t, deviceStrategy.ValidateDeviceCode(context.Background(), r, code+"x"))
+}
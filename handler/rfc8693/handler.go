@@ -0,0 +1,253 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package rfc8693
+
+import (
+	"context"
+	"time"
+
+	"github.com/ory/x/errorsx"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/oauth2"
+	"github.com/ory/fosite/storage"
+)
+
+const grantTypeTokenExchange = "urn:ietf:params:oauth:grant-type:token-exchange"
+
+// Handler implements the token exchange grant as defined in https://tools.ietf.org/html/rfc8693.
+//
+// If the request contains an "actor_token", the issued token represents delegation: its subject is the subject of
+// the "subject_token" and it carries an "act" claim identifying the actor. Otherwise, the issued token represents
+// impersonation and is indistinguishable from a token issued to the subject directly, which is only allowed if
+// AllowImpersonation is set.
+type Handler struct {
+	// TokenValidators validate the "subject_token" and "actor_token" parameters. The first validator which is able
+	// to handle the given token type is used.
+	TokenValidators []TokenValidator
+
+	ScopeStrategy            fosite.ScopeStrategy
+	AudienceMatchingStrategy fosite.AudienceMatchingStrategy
+
+	// AllowImpersonation, if set to true, allows token exchange requests without an "actor_token". By default, an
+	// "actor_token" is required in every token exchange request, so that only delegation is possible.
+	AllowImpersonation bool
+
+	*oauth2.HandleHelper
+}
+
+// HandleTokenEndpointRequest implements https://tools.ietf.org/html/rfc8693#section-2.1
+func (c *Handler) HandleTokenEndpointRequest(ctx context.Context, request fosite.AccessRequester) error {
+	if err := c.CheckRequest(request); err != nil {
+		return err
+	}
+
+	session, ok := request.GetSession().(Session)
+	if !ok {
+		return errorsx.WithStack(fosite.ErrServerError.WithHintf("Session must be of type *rfc8693.Session but got type: %T", request.GetSession()))
+	}
+
+	form := request.GetRequestForm()
+	if requested := form.Get("requested_token_type"); requested != "" && requested != AccessTokenType {
+		return errorsx.WithStack(fosite.ErrInvalidRequest.WithHintf("The requested_token_type '%s' is not supported, only '%s' can be issued.", requested, AccessTokenType))
+	}
+
+	subject, err := c.validateToken(ctx, request, "subject_token", "subject_token_type")
+	if err != nil {
+		return err
+	} else if subject == nil {
+		return errorsx.WithStack(fosite.ErrInvalidRequest.WithHintf("The subject_token request parameter must be set when using grant_type of '%s'.", grantTypeTokenExchange))
+	}
+
+	actor, err := c.validateToken(ctx, request, "actor_token", "actor_token_type")
+	if err != nil {
+		return err
+	} else if actor == nil && !c.AllowImpersonation {
+		return errorsx.WithStack(fosite.ErrInvalidRequest.WithHint("The actor_token request parameter must be set because impersonation is not allowed."))
+	}
+
+	// The issued token must not carry more privileges than the subject token, so by default the scopes of the subject
+	// token are carried over. Requesting scopes allows the client to narrow them down.
+	client := request.GetClient()
+	scopes := request.GetRequestedScopes()
+	if len(scopes) == 0 {
+		scopes = subject.GetGrantedScopes()
+	}
+	for _, scope := range scopes {
+		if !c.ScopeStrategy(subject.GetGrantedScopes(), scope) {
+			return errorsx.WithStack(fosite.ErrInvalidScope.WithHintf("The subject_token has not been granted scope '%s'.", scope))
+		} else if !fosite.ClientScopeStrategy(client, c.ScopeStrategy)(client.GetScopes(), scope) {
+			return errorsx.WithStack(fosite.ErrInvalidScope.WithHintf("The OAuth 2.0 Client is not allowed to request scope '%s'.", scope))
+		}
+	}
+
+	if err := fosite.GetAudienceMatchingStrategy(ctx, client, c.AudienceMatchingStrategy)(client.GetAudience(), request.GetRequestedAudience()); err != nil {
+		return errorsx.WithStack(fosite.ErrInvalidTarget.WithHint("The OAuth 2.0 Client is not allowed to request the given audience.").WithWrap(err).WithDebug(err.Error()))
+	}
+
+	act := getClaim(subject.GetSession(), "act")
+	if actor != nil {
+		if err := checkMayAct(subject, actor); err != nil {
+			return err
+		}
+
+		delegation := map[string]interface{}{"client_id": actor.GetClient().GetID()}
+		if sub := actor.GetSession().GetSubject(); sub != "" {
+			delegation["sub"] = sub
+		}
+		// A chain of delegation is expressed by nesting the prior actor.
+		if act != nil {
+			delegation["act"] = act
+		}
+		act = delegation
+	}
+	if act != nil && !setClaim(request.GetSession(), "act", act) {
+		return errorsx.WithStack(fosite.ErrServerError.WithHintf("Session of type %T is unable to store the \"act\" claim.", request.GetSession()))
+	}
+
+	for _, scope := range scopes {
+		request.GrantScope(scope)
+	}
+
+	for _, audience := range request.GetRequestedAudience() {
+		request.GrantAudience(audience)
+	}
+
+	session.SetSubject(subject.GetSession().GetSubject())
+
+	// The issued token must not outlive the subject token.
+	expiresAt := time.Now().UTC().Add(fosite.GetAccessTokenLifespan(ctx, c.HandleHelper.AccessTokenLifespan)).Round(time.Second)
+	if subjectExp := subject.GetSession().GetExpiresAt(expiryTokenType(form.Get("subject_token_type"))); !subjectExp.IsZero() && subjectExp.Before(expiresAt) {
+		expiresAt = subjectExp
+	}
+	request.GetSession().SetExpiresAt(fosite.AccessToken, expiresAt)
+
+	return nil
+}
+
+// PopulateTokenEndpointResponse implements https://tools.ietf.org/html/rfc8693#section-2.2
+func (c *Handler) PopulateTokenEndpointResponse(ctx context.Context, request fosite.AccessRequester, response fosite.AccessResponder) error {
+	if err := c.CheckRequest(request); err != nil {
+		return err
+	}
+
+	ctx, err := storage.MaybeBeginTx(ctx, c.AccessTokenStorage)
+	if err != nil {
+		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	if err := c.IssueAccessToken(ctx, request, response); err != nil {
+		if rollBackTxnErr := storage.MaybeRollbackTx(ctx, c.AccessTokenStorage); rollBackTxnErr != nil {
+			return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebugf("error: %s; rollback error: %s", err, rollBackTxnErr))
+		}
+		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	if err := storage.MaybeCommitTx(ctx, c.AccessTokenStorage); err != nil {
+		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	response.SetExtra("issued_token_type", AccessTokenType)
+	return nil
+}
+
+func (c *Handler) CanSkipClientAuth(requester fosite.AccessRequester) bool {
+	return false
+}
+
+func (c *Handler) CanHandleTokenEndpointRequest(requester fosite.AccessRequester) bool {
+	// grant_type REQUIRED.
+	// Value MUST be set to "urn:ietf:params:oauth:grant-type:token-exchange"
+	return requester.GetGrantTypes().ExactOne(grantTypeTokenExchange)
+}
+
+func (c *Handler) CheckRequest(request fosite.AccessRequester) error {
+	if !c.CanHandleTokenEndpointRequest(request) {
+		return errorsx.WithStack(fosite.ErrUnknownRequest)
+	}
+
+	if !request.GetClient().GetGrantTypes().Has(grantTypeTokenExchange) {
+		return errorsx.WithStack(fosite.ErrUnauthorizedClient.WithHintf("The OAuth 2.0 Client is not allowed to use authorization grant \"%s\".", grantTypeTokenExchange))
+	}
+
+	return nil
+}
+
+// validateToken validates the token found in the given form parameter and returns nil if the parameter is not set.
+func (c *Handler) validateToken(ctx context.Context, request fosite.AccessRequester, tokenParam, typeParam string) (fosite.Requester, error) {
+	form := request.GetRequestForm()
+	token, tokenType := form.Get(tokenParam), form.Get(typeParam)
+	if token == "" {
+		if tokenType != "" {
+			return nil, errorsx.WithStack(fosite.ErrInvalidRequest.WithHintf("The %s request parameter must not be set without the %s request parameter.", typeParam, tokenParam))
+		}
+		return nil, nil
+	} else if tokenType == "" {
+		return nil, errorsx.WithStack(fosite.ErrInvalidRequest.WithHintf("The %s request parameter must be set when using the %s request parameter.", typeParam, tokenParam))
+	}
+
+	for _, validator := range c.TokenValidators {
+		if !validator.CanValidate(tokenType) {
+			continue
+		}
+
+		or, err := validator.ValidateToken(ctx, token, tokenType, request.GetSession().Clone())
+		if err != nil {
+			return nil, err
+		}
+		return or, nil
+	}
+
+	return nil, errorsx.WithStack(fosite.ErrInvalidRequest.WithHintf("The %s '%s' is not supported.", typeParam, tokenType))
+}
+
+// expiryTokenType returns the fosite.TokenType the expiry of a token of the given token type identifier is stored as.
+func expiryTokenType(tokenType string) fosite.TokenType {
+	switch tokenType {
+	case RefreshTokenType:
+		return fosite.RefreshToken
+	case IDTokenType:
+		return fosite.IDToken
+	}
+	return fosite.AccessToken
+}
+
+// checkMayAct enforces the "may_act" claim of the subject token, if present, as described in
+// https://tools.ietf.org/html/rfc8693#section-4.4
+func checkMayAct(subject, actor fosite.Requester) error {
+	mayAct, ok := getClaim(subject.GetSession(), "may_act").(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	if sub, ok := mayAct["sub"].(string); ok && sub != actor.GetSession().GetSubject() {
+		return errorsx.WithStack(fosite.ErrInvalidRequest.WithHint("The actor_token is not authorized to act on behalf of the subject_token's subject."))
+	}
+	if clientID, ok := mayAct["client_id"].(string); ok && clientID != actor.GetClient().GetID() {
+		return errorsx.WithStack(fosite.ErrInvalidRequest.WithHint("The actor_token is not authorized to act on behalf of the subject_token's subject."))
+	}
+	return nil
+}
+
+func (c *Handler) PopulateDiscoveryMetadata(ctx context.Context, metadata *fosite.DiscoveryMetadata) {
+	metadata.GrantTypesSupported = append(metadata.GrantTypesSupported, grantTypeTokenExchange)
+}
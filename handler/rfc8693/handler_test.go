@@ -0,0 +1,310 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package rfc8693
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/oauth2"
+	"github.com/ory/fosite/storage"
+	"github.com/ory/fosite/token/hmac"
+)
+
+var hmacStrategy = &oauth2.HMACSHAStrategy{
+	Enigma:              &hmac.HMACStrategy{GlobalSecret: []byte("foobarfoobarfoobarfoobarfoobarfoobarfoobarfoobar")},
+	AccessTokenLifespan: time.Hour,
+}
+
+func newTokenExchangeHandler(store *storage.MemoryStore) *Handler {
+	return &Handler{
+		TokenValidators: []TokenValidator{&DefaultTokenValidator{
+			AccessTokenStrategy:  hmacStrategy,
+			RefreshTokenStrategy: hmacStrategy,
+			AccessTokenStorage:   store,
+			RefreshTokenStorage:  store,
+		}},
+		ScopeStrategy:            fosite.ExactScopeStrategy,
+		AudienceMatchingStrategy: fosite.DefaultAudienceMatchingStrategy,
+		HandleHelper: &oauth2.HandleHelper{
+			AccessTokenStrategy: hmacStrategy,
+			AccessTokenStorage:  store,
+			AccessTokenLifespan: time.Hour,
+		},
+	}
+}
+
+func issueToken(t *testing.T, store *storage.MemoryStore, client fosite.Client, session *fosite.DefaultSession, scopes ...string) string {
+	req := fosite.NewAccessRequest(session)
+	req.Client = client
+	req.GrantedScope = scopes
+	session.SetExpiresAt(fosite.AccessToken, time.Now().UTC().Add(time.Minute*10).Round(time.Second))
+
+	token, signature, err := hmacStrategy.GenerateAccessToken(context.Background(), req)
+	require.NoError(t, err)
+	require.NoError(t, store.CreateAccessTokenSession(context.Background(), signature, req))
+	return token
+}
+
+func TestTokenExchange(t *testing.T) {
+	client := &fosite.DefaultClient{ID: "service-a", GrantTypes: fosite.Arguments{grantTypeTokenExchange}, Scopes: []string{"read", "write"}, Audience: []string{"https://service-b"}}
+	actorClient := &fosite.DefaultClient{ID: "service-a"}
+	userClient := &fosite.DefaultClient{ID: "web"}
+
+	for _, c := range []struct {
+		d            string
+		handler      func(h *Handler)
+		form         func(t *testing.T, store *storage.MemoryStore) url.Values
+		scopes       []string
+		audience     []string
+		expectErr    error
+		expectAct    interface{}
+		expectScopes fosite.Arguments
+	}{
+		{
+			d: "fails without subject token",
+			form: func(t *testing.T, store *storage.MemoryStore) url.Values {
+				return url.Values{}
+			},
+			expectErr: fosite.ErrInvalidRequest,
+		},
+		{
+			d: "fails without subject token type",
+			form: func(t *testing.T, store *storage.MemoryStore) url.Values {
+				return url.Values{"subject_token": {issueToken(t, store, userClient, &fosite.DefaultSession{Subject: "peter"}, "read")}}
+			},
+			expectErr: fosite.ErrInvalidRequest,
+		},
+		{
+			d: "fails with unsupported subject token type",
+			form: func(t *testing.T, store *storage.MemoryStore) url.Values {
+				return url.Values{"subject_token": {"foo"}, "subject_token_type": {IDTokenType}}
+			},
+			expectErr: fosite.ErrInvalidRequest,
+		},
+		{
+			d: "fails with unknown subject token",
+			form: func(t *testing.T, store *storage.MemoryStore) url.Values {
+				token, _, err := hmacStrategy.GenerateAccessToken(context.Background(), nil)
+				require.NoError(t, err)
+				return url.Values{"subject_token": {token}, "subject_token_type": {AccessTokenType}}
+			},
+			expectErr: fosite.ErrInvalidRequest,
+		},
+		{
+			d: "fails with unsupported requested token type",
+			form: func(t *testing.T, store *storage.MemoryStore) url.Values {
+				return url.Values{
+					"subject_token":        {issueToken(t, store, userClient, &fosite.DefaultSession{Subject: "peter"}, "read")},
+					"subject_token_type":   {AccessTokenType},
+					"requested_token_type": {IDTokenType},
+				}
+			},
+			expectErr: fosite.ErrInvalidRequest,
+		},
+		{
+			d:       "fails when requesting a scope not granted to the subject token",
+			handler: func(h *Handler) { h.AllowImpersonation = true },
+			form: func(t *testing.T, store *storage.MemoryStore) url.Values {
+				return url.Values{"subject_token": {issueToken(t, store, userClient, &fosite.DefaultSession{Subject: "peter"}, "read")}, "subject_token_type": {AccessTokenType}}
+			},
+			scopes:    []string{"write"},
+			expectErr: fosite.ErrInvalidScope,
+		},
+		{
+			d:       "fails when requesting an audience not allowed for the client",
+			handler: func(h *Handler) { h.AllowImpersonation = true },
+			form: func(t *testing.T, store *storage.MemoryStore) url.Values {
+				return url.Values{"subject_token": {issueToken(t, store, userClient, &fosite.DefaultSession{Subject: "peter"}, "read")}, "subject_token_type": {AccessTokenType}}
+			},
+			audience:  []string{"https://service-c"},
+			expectErr: fosite.ErrInvalidTarget,
+		},
+		{
+			d: "fails impersonation by default",
+			form: func(t *testing.T, store *storage.MemoryStore) url.Values {
+				return url.Values{"subject_token": {issueToken(t, store, userClient, &fosite.DefaultSession{Subject: "peter"}, "read")}, "subject_token_type": {AccessTokenType}}
+			},
+			expectErr: fosite.ErrInvalidRequest,
+		},
+		{
+			d: "fails when the actor is not allowed by may_act",
+			form: func(t *testing.T, store *storage.MemoryStore) url.Values {
+				return url.Values{
+					"subject_token":      {issueToken(t, store, userClient, &fosite.DefaultSession{Subject: "peter", Extra: map[string]interface{}{"may_act": map[string]interface{}{"client_id": "service-x"}}}, "read")},
+					"subject_token_type": {AccessTokenType},
+					"actor_token":        {issueToken(t, store, actorClient, &fosite.DefaultSession{})},
+					"actor_token_type":   {AccessTokenType},
+				}
+			},
+			expectErr: fosite.ErrInvalidRequest,
+		},
+		{
+			d:       "passes with impersonation when allowed",
+			handler: func(h *Handler) { h.AllowImpersonation = true },
+			form: func(t *testing.T, store *storage.MemoryStore) url.Values {
+				return url.Values{"subject_token": {issueToken(t, store, userClient, &fosite.DefaultSession{Subject: "peter"}, "read", "write")}, "subject_token_type": {AccessTokenType}}
+			},
+			scopes:       []string{"read"},
+			audience:     []string{"https://service-b"},
+			expectScopes: fosite.Arguments{"read"},
+		},
+		{
+			d: "passes with delegation",
+			form: func(t *testing.T, store *storage.MemoryStore) url.Values {
+				return url.Values{
+					"subject_token":      {issueToken(t, store, userClient, &fosite.DefaultSession{Subject: "peter"}, "read")},
+					"subject_token_type": {AccessTokenType},
+					"actor_token":        {issueToken(t, store, actorClient, &fosite.DefaultSession{Subject: "service-a-sub"})},
+					"actor_token_type":   {AccessTokenType},
+				}
+			},
+			expectAct:    map[string]interface{}{"client_id": "service-a", "sub": "service-a-sub"},
+			expectScopes: fosite.Arguments{"read"},
+		},
+		{
+			d: "passes with a chain of delegation",
+			form: func(t *testing.T, store *storage.MemoryStore) url.Values {
+				prior := map[string]interface{}{"client_id": "service-z"}
+				return url.Values{
+					"subject_token":      {issueToken(t, store, userClient, &fosite.DefaultSession{Subject: "peter", Extra: map[string]interface{}{"act": prior, "may_act": map[string]interface{}{"client_id": "service-a"}}}, "read")},
+					"subject_token_type": {AccessTokenType},
+					"actor_token":        {issueToken(t, store, actorClient, &fosite.DefaultSession{})},
+					"actor_token_type":   {AccessTokenType},
+				}
+			},
+			expectAct:    map[string]interface{}{"client_id": "service-a", "act": map[string]interface{}{"client_id": "service-z"}},
+			expectScopes: fosite.Arguments{"read"},
+		},
+	} {
+		t.Run("case="+c.d, func(t *testing.T) {
+			store := storage.NewMemoryStore()
+			h := newTokenExchangeHandler(store)
+			if c.handler != nil {
+				c.handler(h)
+			}
+
+			req := fosite.NewAccessRequest(new(fosite.DefaultSession))
+			req.GrantTypes = fosite.Arguments{grantTypeTokenExchange}
+			req.Client = client
+			req.Form = c.form(t, store)
+			req.RequestedScope = c.scopes
+			req.RequestedAudience = c.audience
+
+			err := h.HandleTokenEndpointRequest(context.Background(), req)
+			if c.expectErr != nil {
+				require.ErrorIs(t, err, c.expectErr)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, "peter", req.GetSession().GetSubject())
+			assert.Equal(t, c.expectScopes, req.GetGrantedScopes())
+			assert.Equal(t, c.expectAct, req.GetSession().(*fosite.DefaultSession).Extra["act"])
+			assert.True(t, req.GetSession().GetExpiresAt(fosite.AccessToken).Before(time.Now().UTC().Add(time.Minute*11)))
+
+			resp := fosite.NewAccessResponse()
+			require.NoError(t, h.PopulateTokenEndpointResponse(context.Background(), req, resp))
+			assert.NotEmpty(t, resp.GetAccessToken())
+			assert.Equal(t, AccessTokenType, resp.GetExtra("issued_token_type"))
+		})
+	}
+}
+
+func TestTokenExchange_RefreshTokenLifetime(t *testing.T) {
+	store := storage.NewMemoryStore()
+	h := newTokenExchangeHandler(store)
+	h.AllowImpersonation = true
+	client := &fosite.DefaultClient{ID: "service-a", GrantTypes: fosite.Arguments{grantTypeTokenExchange}, Scopes: []string{"read"}}
+
+	// The access token issued alongside the refresh token expired long ago, the refresh token is still valid.
+	refreshExp := time.Now().UTC().Add(time.Minute * 5).Round(time.Second)
+	session := &fosite.DefaultSession{Subject: "peter", ExpiresAt: map[fosite.TokenType]time.Time{
+		fosite.AccessToken:  time.Now().UTC().Add(-time.Hour),
+		fosite.RefreshToken: refreshExp,
+	}}
+	subject := fosite.NewAccessRequest(session)
+	subject.Client = &fosite.DefaultClient{ID: "web"}
+	subject.GrantedScope = fosite.Arguments{"read"}
+	token, signature, err := hmacStrategy.GenerateRefreshToken(context.Background(), subject)
+	require.NoError(t, err)
+	require.NoError(t, store.CreateRefreshTokenSession(context.Background(), signature, subject))
+
+	req := fosite.NewAccessRequest(new(fosite.DefaultSession))
+	req.GrantTypes = fosite.Arguments{grantTypeTokenExchange}
+	req.Client = client
+	req.Form = url.Values{"subject_token": {token}, "subject_token_type": {RefreshTokenType}}
+
+	require.NoError(t, h.HandleTokenEndpointRequest(context.Background(), req))
+	assert.Equal(t, refreshExp, req.GetSession().GetExpiresAt(fosite.AccessToken))
+}
+
+func TestTokenExchange_CheckRequest(t *testing.T) {
+	h := newTokenExchangeHandler(storage.NewMemoryStore())
+
+	req := fosite.NewAccessRequest(new(fosite.DefaultSession))
+	req.GrantTypes = fosite.Arguments{"client_credentials"}
+	require.ErrorIs(t, h.HandleTokenEndpointRequest(context.Background(), req), fosite.ErrUnknownRequest)
+
+	req.GrantTypes = fosite.Arguments{grantTypeTokenExchange}
+	req.Client = &fosite.DefaultClient{GrantTypes: fosite.Arguments{"client_credentials"}}
+	require.ErrorIs(t, h.HandleTokenEndpointRequest(context.Background(), req), fosite.ErrUnauthorizedClient)
+}
+
+type transactionalStore struct {
+	*storage.MemoryStore
+	committed, rolledBack bool
+}
+
+func (s *transactionalStore) BeginTX(ctx context.Context) (context.Context, error) {
+	return ctx, nil
+}
+
+func (s *transactionalStore) Commit(context.Context) error {
+	s.committed = true
+	return nil
+}
+
+func (s *transactionalStore) Rollback(context.Context) error {
+	s.rolledBack = true
+	return nil
+}
+
+func TestTokenExchange_Transactional(t *testing.T) {
+	store := &transactionalStore{MemoryStore: storage.NewMemoryStore()}
+	h := newTokenExchangeHandler(store.MemoryStore)
+	h.AccessTokenStorage = store
+
+	req := fosite.NewAccessRequest(new(fosite.DefaultSession))
+	req.GrantTypes = fosite.Arguments{grantTypeTokenExchange}
+	req.Client = &fosite.DefaultClient{ID: "service-a", GrantTypes: fosite.Arguments{grantTypeTokenExchange}}
+
+	require.NoError(t, h.PopulateTokenEndpointResponse(context.Background(), req, fosite.NewAccessResponse()))
+	assert.True(t, store.committed)
+	assert.False(t, store.rolledBack)
+}
@@ -0,0 +1,64 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package rfc8693
+
+import (
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/oauth2"
+)
+
+// Session must be implemented by the session if RFC8693 is to be supported.
+type Session interface {
+	// SetSubject sets the session's subject.
+	SetSubject(subject string)
+}
+
+// claimsAdder is implemented by *jwt.JWTClaims.
+type claimsAdder interface {
+	Add(key string, value interface{})
+}
+
+// getClaim returns a claim stored in the session. JWT sessions keep their claims in the JWT claims container, all
+// other sessions are expected to implement fosite.ExtraClaimsSession.
+func getClaim(session fosite.Session, key string) interface{} {
+	if s, ok := session.(oauth2.JWTSessionContainer); ok {
+		return s.GetJWTClaims().ToMapClaims()[key]
+	} else if s, ok := session.(fosite.ExtraClaimsSession); ok {
+		return s.GetExtraClaims()[key]
+	}
+	return nil
+}
+
+// setClaim stores a claim in the session, so that it ends up in JWT access tokens and introspection responses.
+func setClaim(session fosite.Session, key string, value interface{}) bool {
+	if s, ok := session.(oauth2.JWTSessionContainer); ok {
+		if claims, ok := s.GetJWTClaims().(claimsAdder); ok {
+			claims.Add(key, value)
+			return true
+		}
+		return false
+	} else if s, ok := session.(fosite.ExtraClaimsSession); ok {
+		s.GetExtraClaims()[key] = value
+		return true
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package rfc8693
+
+import (
+	"context"
+
+	"github.com/ory/x/errorsx"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/oauth2"
+)
+
+// Token type identifiers as defined in https://tools.ietf.org/html/rfc8693#section-3
+const (
+	AccessTokenType  = "urn:ietf:params:oauth:token-type:access_token"
+	RefreshTokenType = "urn:ietf:params:oauth:token-type:refresh_token"
+	IDTokenType      = "urn:ietf:params:oauth:token-type:id_token"
+	JWTTokenType     = "urn:ietf:params:oauth:token-type:jwt"
+)
+
+// TokenValidator validates tokens passed as "subject_token" or "actor_token" in a token exchange request.
+type TokenValidator interface {
+	// CanValidate returns true if the validator is able to validate tokens of the given token type identifier.
+	CanValidate(tokenType string) bool
+
+	// ValidateToken validates the token and returns the request the token was originally issued for.
+	ValidateToken(ctx context.Context, token string, tokenType string, session fosite.Session) (fosite.Requester, error)
+}
+
+// DefaultTokenValidator validates access and refresh tokens issued by this authorization server.
+type DefaultTokenValidator struct {
+	AccessTokenStrategy  oauth2.AccessTokenStrategy
+	RefreshTokenStrategy oauth2.RefreshTokenStrategy
+	AccessTokenStorage   oauth2.AccessTokenStorage
+	RefreshTokenStorage  oauth2.RefreshTokenStorage
+}
+
+func (v *DefaultTokenValidator) CanValidate(tokenType string) bool {
+	switch tokenType {
+	case AccessTokenType:
+		return v.AccessTokenStrategy != nil && v.AccessTokenStorage != nil
+	case RefreshTokenType:
+		return v.RefreshTokenStrategy != nil && v.RefreshTokenStorage != nil
+	}
+	return false
+}
+
+func (v *DefaultTokenValidator) ValidateToken(ctx context.Context, token string, tokenType string, session fosite.Session) (fosite.Requester, error) {
+	switch tokenType {
+	case AccessTokenType:
+		sig := v.AccessTokenStrategy.AccessTokenSignature(token)
+		var or fosite.Requester
+		err := fosite.StorageOperation(ctx, "GetAccessTokenSession", func(ctx context.Context) (err error) {
+			or, err = v.AccessTokenStorage.GetAccessTokenSession(ctx, sig, session)
+			return err
+		})
+		if err != nil {
+			return nil, errorsx.WithStack(fosite.ErrInvalidRequest.WithHint("The access token is unknown or has been revoked.").WithWrap(err).WithDebug(err.Error()))
+		} else if err := v.AccessTokenStrategy.ValidateAccessToken(ctx, or, token); err != nil {
+			return nil, errorsx.WithStack(fosite.ErrInvalidRequest.WithHint("The access token is invalid or has expired.").WithWrap(err).WithDebug(err.Error()))
+		}
+		return or, nil
+	case RefreshTokenType:
+		or, err := oauth2.GetRefreshTokenSession(ctx, v.RefreshTokenStrategy, v.RefreshTokenStorage, token, session)
+		if err != nil {
+			return nil, errorsx.WithStack(fosite.ErrInvalidRequest.WithHint("The refresh token is unknown or has been revoked.").WithWrap(err).WithDebug(err.Error()))
+		} else if err := v.RefreshTokenStrategy.ValidateRefreshToken(ctx, or, token); err != nil {
+			return nil, errorsx.WithStack(fosite.ErrInvalidRequest.WithHint("The refresh token is invalid or has expired.").WithWrap(err).WithDebug(err.Error()))
+		}
+		return or, nil
+	}
+	return nil, errorsx.WithStack(fosite.ErrInvalidRequest.WithHintf("Token type '%s' is not supported.", tokenType))
+}
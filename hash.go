@@ -32,3 +32,80 @@ type Hasher interface {
 	// Hash creates a hash from data or returns an error.
 	Hash(ctx context.Context, data []byte) ([]byte, error)
 }
+
+// ClientSecretRehasher is called with a new hash of the client's secret after the client authenticated using a
+// hash which needs to be rehashed. Implementations usually persist the new hash. Errors are ignored because the
+// client was authenticated successfully.
+type ClientSecretRehasher func(ctx context.Context, client Client, hash []byte) error
+
+// DetectableHasher is a Hasher which is able to tell whether a hash was created by it, usually by looking at the
+// prefix of the hash.
+type DetectableHasher interface {
+	Hasher
+
+	// Detect returns true if the hash was created by this hasher.
+	Detect(hash []byte) bool
+}
+
+// RehashChecker is an optional interface for hashers which are able to tell whether a hash should be replaced,
+// for example because it was created with a weaker work factor or a different algorithm.
+type RehashChecker interface {
+	// NeedsRehash returns true if the hash should be recomputed from the plain text.
+	NeedsRehash(hash []byte) bool
+}
+
+// NeedsRehash returns true if the hasher implements RehashChecker and reports that the hash needs to be
+// recomputed.
+func NeedsRehash(hasher Hasher, hash []byte) bool {
+	if checker, ok := hasher.(RehashChecker); ok {
+		return checker.NeedsRehash(hash)
+	}
+	return false
+}
+
+// HasherChain is a Hasher supporting several algorithms. New hashes are created with Default while hashes are
+// compared using the first of Hashers detecting the hash (by its prefix), falling back to Default. This allows
+// migrating stored client secrets from one algorithm to another.
+type HasherChain struct {
+	// Default is used to create new hashes.
+	Default Hasher
+
+	// Hashers are used to compare hashes created by other algorithms.
+	Hashers []DetectableHasher
+}
+
+var _ RehashChecker = (*HasherChain)(nil)
+
+// NewHasherChain returns a HasherChain hashing with def and accepting hashes of def, BCrypt, PBKDF2 and Argon2id.
+func NewHasherChain(def DetectableHasher) *HasherChain {
+	return &HasherChain{
+		Default: def,
+		Hashers: []DetectableHasher{def, new(BCrypt), new(PBKDF2), new(Argon2id)},
+	}
+}
+
+func (h *HasherChain) Hash(ctx context.Context, data []byte) ([]byte, error) {
+	return h.Default.Hash(ctx, data)
+}
+
+func (h *HasherChain) Compare(ctx context.Context, hash, data []byte) error {
+	return h.hasherFor(hash).Compare(ctx, hash, data)
+}
+
+// NeedsRehash returns true if the hash was not created by the default hasher or if the default hasher reports
+// that the hash needs to be recomputed.
+func (h *HasherChain) NeedsRehash(hash []byte) bool {
+	if d, ok := h.Default.(DetectableHasher); ok && !d.Detect(hash) {
+		return true
+	}
+	return NeedsRehash(h.Default, hash)
+}
+
+func (h *HasherChain) hasherFor(hash []byte) Hasher {
+	for _, hasher := range h.Hashers {
+		if hasher.Detect(hash) {
+			return hasher
+		}
+	}
+	return h.Default
+}
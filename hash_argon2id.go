@@ -0,0 +1,136 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/ory/x/errorsx"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	DefaultArgon2idMemory      uint32 = 64 * 1024
+	DefaultArgon2idIterations  uint32 = 1
+	DefaultArgon2idParallelism uint8  = 2
+	DefaultArgon2idKeyLength   uint32 = 32
+
+	argon2idPrefix = "$argon2id$"
+)
+
+// Argon2id implements the Hasher interface using Argon2id. Hashes use the PHC string format
+// "$argon2id$v=19$m=<memory>,t=<iterations>,p=<parallelism>$<salt>$<key>".
+type Argon2id struct {
+	// Memory is the memory used in KiB.
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	KeyLength   uint32
+	SaltLength  int
+}
+
+var _ DetectableHasher = (*Argon2id)(nil)
+
+func (a *Argon2id) Hash(ctx context.Context, data []byte) ([]byte, error) {
+	p := a.params()
+	salt, err := newSalt(a.SaltLength)
+	if err != nil {
+		return nil, err
+	}
+
+	key := argon2.IDKey(data, salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	return []byte(fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2idPrefix, argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))), nil
+}
+
+func (a *Argon2id) Compare(ctx context.Context, hash, data []byte) error {
+	p, salt, key, err := decodeArgon2idHash(hash)
+	if err != nil {
+		return err
+	}
+
+	if subtle.ConstantTimeCompare(key, argon2.IDKey(data, salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)) != 1 {
+		return errorsx.WithStack(ErrHashMismatch)
+	}
+	return nil
+}
+
+func (a *Argon2id) Detect(hash []byte) bool {
+	return strings.HasPrefix(string(hash), argon2idPrefix)
+}
+
+// NeedsRehash returns true if the hash uses weaker parameters than configured.
+func (a *Argon2id) NeedsRehash(hash []byte) bool {
+	p, _, _, err := decodeArgon2idHash(hash)
+	if err != nil {
+		return true
+	}
+	want := a.params()
+	return p.Memory < want.Memory || p.Iterations < want.Iterations || p.KeyLength < want.KeyLength
+}
+
+func (a *Argon2id) params() Argon2id {
+	p := *a
+	if p.Memory == 0 {
+		p.Memory = DefaultArgon2idMemory
+	}
+	if p.Iterations == 0 {
+		p.Iterations = DefaultArgon2idIterations
+	}
+	if p.Parallelism == 0 {
+		p.Parallelism = DefaultArgon2idParallelism
+	}
+	if p.KeyLength == 0 {
+		p.KeyLength = DefaultArgon2idKeyLength
+	}
+	return p
+}
+
+func decodeArgon2idHash(hash []byte) (p Argon2id, salt, key []byte, err error) {
+	parts := strings.Split(strings.TrimPrefix(string(hash), argon2idPrefix), "$")
+	if !strings.HasPrefix(string(hash), argon2idPrefix) || len(parts) != 4 {
+		return p, nil, nil, errorsx.WithStack(errors.New("the hash is not an argon2id hash"))
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[0], "v=%d", &version); err != nil {
+		return p, nil, nil, errorsx.WithStack(err)
+	} else if version != argon2.Version {
+		return p, nil, nil, errorsx.WithStack(errors.Errorf("the argon2id version %d is not supported", version))
+	} else if _, err := fmt.Sscanf(parts[1], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return p, nil, nil, errorsx.WithStack(err)
+	} else if salt, err = base64.RawStdEncoding.DecodeString(parts[2]); err != nil {
+		return p, nil, nil, errorsx.WithStack(err)
+	} else if key, err = base64.RawStdEncoding.DecodeString(parts[3]); err != nil {
+		return p, nil, nil, errorsx.WithStack(err)
+	} else if p.Iterations == 0 || p.Parallelism == 0 || len(key) == 0 {
+		return p, nil, nil, errorsx.WithStack(errors.New("the parameters of the argon2id hash are invalid"))
+	}
+
+	p.KeyLength = uint32(len(key))
+	return p, salt, key, nil
+}
@@ -23,6 +23,7 @@ package fosite
 
 import (
 	"context"
+	"strings"
 
 	"github.com/ory/x/errorsx"
 
@@ -36,6 +37,8 @@ type BCrypt struct {
 	WorkFactor int
 }
 
+var _ DetectableHasher = (*BCrypt)(nil)
+
 func (b *BCrypt) Hash(ctx context.Context, data []byte) ([]byte, error) {
 	if b.WorkFactor == 0 {
 		b.WorkFactor = DefaultBCryptWorkFactor
@@ -53,3 +56,26 @@ func (b *BCrypt) Compare(ctx context.Context, hash, data []byte) error {
 	}
 	return nil
 }
+
+func (b *BCrypt) Detect(hash []byte) bool {
+	for _, prefix := range []string{"$2a$", "$2b$", "$2y$"} {
+		if strings.HasPrefix(string(hash), prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// NeedsRehash returns true if the hash was created with a lower work factor than configured.
+func (b *BCrypt) NeedsRehash(hash []byte) bool {
+	cost, err := bcrypt.Cost(hash)
+	if err != nil {
+		return true
+	}
+
+	workFactor := b.WorkFactor
+	if workFactor == 0 {
+		workFactor = DefaultBCryptWorkFactor
+	}
+	return cost < workFactor
+}
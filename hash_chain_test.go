@@ -0,0 +1,146 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectableHashers(t *testing.T) {
+	for k, hasher := range map[string]DetectableHasher{
+		"bcrypt":   &BCrypt{WorkFactor: 4},
+		"pbkdf2":   &PBKDF2{Iterations: 1000},
+		"argon2id": &Argon2id{Memory: 1024},
+	} {
+		t.Run("hasher="+k, func(t *testing.T) {
+			hash, err := hasher.Hash(context.Background(), []byte("foobar"))
+			require.NoError(t, err)
+			assert.True(t, hasher.Detect(hash))
+
+			assert.NoError(t, hasher.Compare(context.Background(), hash, []byte("foobar")))
+			assert.Error(t, hasher.Compare(context.Background(), hash, []byte("foobaz")))
+			assert.Error(t, hasher.Compare(context.Background(), []byte("not-a-hash"), []byte("foobar")))
+
+			assert.False(t, NeedsRehash(hasher, hash))
+			assert.True(t, NeedsRehash(hasher, []byte("not-a-hash")))
+		})
+	}
+}
+
+func TestHashersRejectInvalidParameters(t *testing.T) {
+	for k, c := range []struct {
+		d      string
+		hasher DetectableHasher
+		hash   string
+	}{
+		{d: "pbkdf2 with empty key", hasher: new(PBKDF2), hash: "$pbkdf2-sha256$i=1000,l=0$c2FsdA$"},
+		{d: "pbkdf2 with negative key length", hasher: new(PBKDF2), hash: "$pbkdf2-sha256$i=1000,l=-1$c2FsdA$"},
+		{d: "pbkdf2 without iterations", hasher: new(PBKDF2), hash: "$pbkdf2-sha256$i=0,l=3$c2FsdA$a2V5"},
+		{d: "pbkdf2 with negative iterations", hasher: new(PBKDF2), hash: "$pbkdf2-sha256$i=-1,l=3$c2FsdA$a2V5"},
+		{d: "argon2id with empty key", hasher: new(Argon2id), hash: "$argon2id$v=19$m=1024,t=1,p=2$c2FsdA$"},
+		{d: "argon2id without iterations", hasher: new(Argon2id), hash: "$argon2id$v=19$m=1024,t=0,p=2$c2FsdA$a2V5"},
+		{d: "argon2id without parallelism", hasher: new(Argon2id), hash: "$argon2id$v=19$m=1024,t=1,p=0$c2FsdA$a2V5"},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			require.True(t, c.hasher.Detect([]byte(c.hash)))
+			assert.Error(t, c.hasher.Compare(context.Background(), []byte(c.hash), []byte("foobar")))
+			assert.True(t, NeedsRehash(c.hasher, []byte(c.hash)))
+		})
+	}
+}
+
+func TestHashersNeedRehashWithWeakerParameters(t *testing.T) {
+	hash, err := (&PBKDF2{Iterations: 1000}).Hash(context.Background(), []byte("foobar"))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(hash), "$pbkdf2-sha256$i=1000,l=32$"))
+	assert.True(t, (&PBKDF2{Iterations: 2000}).NeedsRehash(hash))
+
+	hash, err = (&Argon2id{Memory: 1024}).Hash(context.Background(), []byte("foobar"))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(hash), "$argon2id$v=19$m=1024,t=1,p=2$"))
+	assert.True(t, (&Argon2id{Memory: 2048}).NeedsRehash(hash))
+
+	hash, err = (&BCrypt{WorkFactor: 4}).Hash(context.Background(), []byte("foobar"))
+	require.NoError(t, err)
+	assert.True(t, (&BCrypt{WorkFactor: 5}).NeedsRehash(hash))
+}
+
+func TestHasherChain(t *testing.T) {
+	ctx := context.Background()
+	legacy, err := (&BCrypt{WorkFactor: 4}).Hash(ctx, []byte("foobar"))
+	require.NoError(t, err)
+
+	chain := &HasherChain{
+		Default: &PBKDF2{Iterations: 1000},
+		Hashers: []DetectableHasher{&PBKDF2{Iterations: 1000}, &BCrypt{WorkFactor: 4}},
+	}
+
+	hash, err := chain.Hash(ctx, []byte("foobar"))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(hash), pbkdf2Prefix))
+
+	assert.NoError(t, chain.Compare(ctx, hash, []byte("foobar")))
+	assert.NoError(t, chain.Compare(ctx, legacy, []byte("foobar")))
+	assert.Error(t, chain.Compare(ctx, legacy, []byte("foobaz")))
+
+	assert.False(t, chain.NeedsRehash(hash))
+	assert.True(t, chain.NeedsRehash(legacy))
+}
+
+func TestClientSecretRehashOnLogin(t *testing.T) {
+	ctx := context.Background()
+	legacy, err := (&BCrypt{WorkFactor: 4}).Hash(ctx, []byte("foobar"))
+	require.NoError(t, err)
+	rotated, err := (&BCrypt{WorkFactor: 4}).Hash(ctx, []byte("rotated"))
+	require.NoError(t, err)
+
+	var rehashed []byte
+	f := &Fosite{
+		Hasher: NewHasherChain(&PBKDF2{Iterations: 1000}),
+		ClientSecretRehasher: func(ctx context.Context, client Client, hash []byte) error {
+			rehashed = hash
+			return nil
+		},
+	}
+	client := &DefaultClient{ID: "foo", Secret: legacy}
+
+	require.Error(t, f.checkClientSecret(ctx, client, []byte("foobaz")))
+	assert.Nil(t, rehashed)
+
+	require.NoError(t, f.checkClientSecret(ctx, client, []byte("foobar")))
+	require.NotNil(t, rehashed)
+	assert.NoError(t, f.Hasher.Compare(ctx, rehashed, []byte("foobar")))
+
+	client.Secret, rehashed = rehashed, nil
+	require.NoError(t, f.checkClientSecret(ctx, client, []byte("foobar")))
+	assert.Nil(t, rehashed, "hashes of the default hasher are not rehashed")
+
+	client.RotatedSecrets = [][]byte{rotated}
+	require.NoError(t, f.checkClientSecret(ctx, client, []byte("rotated")))
+	assert.Nil(t, rehashed, "rotated secrets are not rehashed")
+}
@@ -0,0 +1,136 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/ory/x/errorsx"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	DefaultPBKDF2Iterations = 310000
+	DefaultPBKDF2KeyLength  = 32
+	DefaultHashSaltLength   = 16
+
+	pbkdf2Prefix = "$pbkdf2-sha256$"
+)
+
+// ErrHashMismatch is returned by PBKDF2 and Argon2id if the data does not match the hash.
+var ErrHashMismatch = errors.New("hash and data do not match")
+
+// PBKDF2 implements the Hasher interface using PBKDF2 with HMAC-SHA256. Hashes have the format
+// "$pbkdf2-sha256$i=<iterations>,l=<key length>$<salt>$<key>" with base64 (no padding) encoded salt and key.
+type PBKDF2 struct {
+	Iterations int
+	KeyLength  int
+	SaltLength int
+}
+
+var _ DetectableHasher = (*PBKDF2)(nil)
+
+func (p *PBKDF2) Hash(ctx context.Context, data []byte) ([]byte, error) {
+	iterations, keyLength := p.params()
+	salt, err := newSalt(p.SaltLength)
+	if err != nil {
+		return nil, err
+	}
+
+	key := pbkdf2.Key(data, salt, iterations, keyLength, sha256.New)
+	return []byte(fmt.Sprintf("%si=%d,l=%d$%s$%s", pbkdf2Prefix, iterations, keyLength,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))), nil
+}
+
+func (p *PBKDF2) Compare(ctx context.Context, hash, data []byte) error {
+	iterations, keyLength, salt, key, err := decodePBKDF2Hash(hash)
+	if err != nil {
+		return err
+	}
+
+	if subtle.ConstantTimeCompare(key, pbkdf2.Key(data, salt, iterations, keyLength, sha256.New)) != 1 {
+		return errorsx.WithStack(ErrHashMismatch)
+	}
+	return nil
+}
+
+func (p *PBKDF2) Detect(hash []byte) bool {
+	return strings.HasPrefix(string(hash), pbkdf2Prefix)
+}
+
+// NeedsRehash returns true if the hash uses fewer iterations or a shorter key than configured.
+func (p *PBKDF2) NeedsRehash(hash []byte) bool {
+	iterations, keyLength, _, _, err := decodePBKDF2Hash(hash)
+	if err != nil {
+		return true
+	}
+	wantIterations, wantKeyLength := p.params()
+	return iterations < wantIterations || keyLength < wantKeyLength
+}
+
+func (p *PBKDF2) params() (iterations, keyLength int) {
+	iterations, keyLength = p.Iterations, p.KeyLength
+	if iterations == 0 {
+		iterations = DefaultPBKDF2Iterations
+	}
+	if keyLength == 0 {
+		keyLength = DefaultPBKDF2KeyLength
+	}
+	return iterations, keyLength
+}
+
+func decodePBKDF2Hash(hash []byte) (iterations, keyLength int, salt, key []byte, err error) {
+	parts := strings.Split(strings.TrimPrefix(string(hash), pbkdf2Prefix), "$")
+	if !strings.HasPrefix(string(hash), pbkdf2Prefix) || len(parts) != 3 {
+		return 0, 0, nil, nil, errorsx.WithStack(errors.New("the hash is not a pbkdf2-sha256 hash"))
+	} else if _, err := fmt.Sscanf(parts[0], "i=%d,l=%d", &iterations, &keyLength); err != nil {
+		return 0, 0, nil, nil, errorsx.WithStack(err)
+	} else if iterations <= 0 || keyLength <= 0 {
+		return 0, 0, nil, nil, errorsx.WithStack(errors.New("the parameters of the pbkdf2-sha256 hash are invalid"))
+	} else if salt, err = base64.RawStdEncoding.DecodeString(parts[1]); err != nil {
+		return 0, 0, nil, nil, errorsx.WithStack(err)
+	} else if key, err = base64.RawStdEncoding.DecodeString(parts[2]); err != nil {
+		return 0, 0, nil, nil, errorsx.WithStack(err)
+	} else if len(key) != keyLength {
+		return 0, 0, nil, nil, errorsx.WithStack(errors.New("the key length of the pbkdf2-sha256 hash is invalid"))
+	}
+	return iterations, keyLength, salt, key, nil
+}
+
+func newSalt(length int) ([]byte, error) {
+	if length == 0 {
+		length = DefaultHashSaltLength
+	}
+
+	salt := make([]byte, length)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, errorsx.WithStack(err)
+	}
+	return salt, nil
+}
@@ -78,6 +78,21 @@ func (c *defaultMessageCatalog) GetLangFromRequest(r *http.Request) language.Tag
 	return tag
 }
 
+func (c *defaultMessageCatalog) MatchLocales(locales ...string) (language.Tag, bool) {
+	var tags []language.Tag
+	for _, locale := range locales {
+		if tag, err := language.Parse(locale); err == nil {
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) == 0 {
+		return language.Und, false
+	}
+
+	tag, _, confidence := c.matcher.Match(tags...)
+	return tag, confidence != language.No
+}
+
 func (c *defaultMessageCatalog) makeMatcher() {
 	result := []language.Tag{language.English}
 	defLangs := message.DefaultCatalog.Languages()
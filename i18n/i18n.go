@@ -2,6 +2,7 @@ package i18n
 
 import (
 	"net/http"
+	"strings"
 
 	"golang.org/x/text/language"
 )
@@ -12,6 +13,49 @@ type MessageCatalog interface {
 	GetLangFromRequest(r *http.Request) language.Tag
 }
 
+// LocaleMatcher is implemented by catalogs which match a list of preferred locales, for example the ui_locales
+// parameter, to the languages they support.
+type LocaleMatcher interface {
+	// MatchLocales returns the supported language which matches the locales best, and false if none is supported.
+	MatchLocales(locales ...string) (language.Tag, bool)
+}
+
+// MessageCatalogs combines catalogs, for example the bundles of an application with translations of fosite's
+// hints. Messages are looked up in order and the language of the request is determined by the first catalog.
+type MessageCatalogs []MessageCatalog
+
+var (
+	_ MessageCatalog = MessageCatalogs{}
+	_ LocaleMatcher  = MessageCatalogs{}
+)
+
+func (c MessageCatalogs) GetMessage(ID string, tag language.Tag, v ...interface{}) string {
+	for _, catalog := range c {
+		if s := catalog.GetMessage(ID, tag, v...); s != ID {
+			return s
+		}
+	}
+	return ID
+}
+
+func (c MessageCatalogs) GetLangFromRequest(r *http.Request) language.Tag {
+	if len(c) == 0 {
+		return language.English
+	}
+	return c[0].GetLangFromRequest(r)
+}
+
+func (c MessageCatalogs) MatchLocales(locales ...string) (language.Tag, bool) {
+	for _, catalog := range c {
+		if m, ok := catalog.(LocaleMatcher); ok {
+			if tag, ok := m.MatchLocales(locales...); ok {
+				return tag, true
+			}
+		}
+	}
+	return language.Und, false
+}
+
 // GetMessage is a helper func to get the translated message based on
 // the message ID and lang. If no matching message is found, it uses
 // ID as the message itself.
@@ -32,6 +76,22 @@ func GetMessageOrDefault(c MessageCatalog, ID string, tag language.Tag, def stri
 	return def
 }
 
+// GetLangFromLocales returns the language of the catalog which matches the space-separated list of locales best,
+// for example the ui_locales parameter defined in
+// https://openid.net/specs/openid-connect-core-1_0.html#AuthRequest. If the catalog supports none of the locales,
+// def is returned.
+func GetLangFromLocales(c MessageCatalog, locales string, def language.Tag) language.Tag {
+	m, ok := c.(LocaleMatcher)
+	if !ok || len(strings.Fields(locales)) == 0 {
+		return def
+	}
+
+	if tag, ok := m.MatchLocales(strings.Fields(locales)...); ok {
+		return tag
+	}
+	return def
+}
+
 // GetLangFromRequest is a helper func to get the language tag based on the
 // HTTP request and the constructed message catalog.
 func GetLangFromRequest(c MessageCatalog, r *http.Request) language.Tag {
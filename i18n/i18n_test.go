@@ -43,3 +43,43 @@ func TestSimpleTranslation(t *testing.T) {
 	msg = GetMessage(catalog, "badRequestBody", language.English, "GET")
 	assert.Equal(t, msg, "Unable to parse HTTP body, make sure to send a properly formatted form request body.")
 }
+
+func TestGetLangFromLocales(t *testing.T) {
+	catalog := NewDefaultMessageCatalog([]*DefaultLocaleBundle{
+		{LangTag: "en", Messages: []*DefaultMessage{{ID: "greeting", FormattedMessage: "Hello"}}},
+		{LangTag: "de", Messages: []*DefaultMessage{{ID: "greeting", FormattedMessage: "Hallo"}}},
+	})
+
+	for _, c := range []struct {
+		d       string
+		locales string
+		expect  string
+	}{
+		{d: "should use the default without locales", locales: "", expect: "Hello"},
+		{d: "should match the first supported locale", locales: "fr-CA de-DE en", expect: "Hallo"},
+		{d: "should ignore invalid locales", locales: "not_a-locale!! de", expect: "Hallo"},
+	} {
+		t.Run("case="+c.d, func(t *testing.T) {
+			assert.Equal(t, c.expect, GetMessage(catalog, "greeting", GetLangFromLocales(catalog, c.locales, language.English)))
+		})
+	}
+
+	assert.Equal(t, language.English, GetLangFromLocales(nil, "de", language.English))
+}
+
+func TestMessageCatalogs(t *testing.T) {
+	app := NewDefaultMessageCatalog([]*DefaultLocaleBundle{
+		{LangTag: "fr", Messages: []*DefaultMessage{{ID: "appMessage", FormattedMessage: "Bonjour"}}},
+	})
+	catalogs := MessageCatalogs{app, NewDefaultMessageCatalog([]*DefaultLocaleBundle{
+		{LangTag: "fr", Messages: []*DefaultMessage{{ID: "fositeMessage", FormattedMessage: "Au revoir"}}},
+	})}
+
+	assert.Equal(t, "Bonjour", GetMessage(catalogs, "appMessage", language.French))
+	assert.Equal(t, "Au revoir", GetMessage(catalogs, "fositeMessage", language.French))
+	assert.Equal(t, "unknownMessage", GetMessage(catalogs, "unknownMessage", language.French))
+
+	tag, ok := catalogs.MatchLocales("fr-CA")
+	assert.True(t, ok)
+	assert.Equal(t, "Bonjour", GetMessage(catalogs, "appMessage", tag))
+}
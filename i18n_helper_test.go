@@ -1,10 +1,14 @@
 package fosite
 
 import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/ory/fosite/i18n"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"golang.org/x/text/language"
 )
 
@@ -46,3 +50,39 @@ func TestErrorTranslation(t *testing.T) {
 	assert.Equal(t, "A la solicitud le falta un parámetro obligatorio, incluye un valor de parámetro no válido, incluye un parámetro más de una vez o tiene un formato incorrecto. El método HTTP es 'GET', esperado 'POST'.",
 		errWithCatalog.GetDescription(), "Message does not match when catalog is specified")
 }
+
+func TestLegacyErrorTranslation(t *testing.T) {
+	catalog := i18n.NewDefaultMessageCatalog([]*i18n.DefaultLocaleBundle{
+		{
+			LangTag: "es",
+			Messages: []*i18n.DefaultMessage{
+				{ID: "invalid_client", FormattedMessage: "La autenticación del cliente falló."},
+				{ID: "The client is unknown.", FormattedMessage: "El cliente es desconocido."},
+			},
+		},
+	})
+
+	err := ErrInvalidClient.WithHint("The client is unknown.").WithLegacyFormat(true).WithLocalizer(catalog, language.Spanish)
+
+	js, jsonErr := json.Marshal(err)
+	require.NoError(t, jsonErr)
+	assert.JSONEq(t, `{"error":"invalid_client","error_description":"La autenticación del cliente falló.","error_hint":"El cliente es desconocido.","status_code":401}`, string(js))
+
+	values := err.ToValues()
+	assert.Equal(t, "La autenticación del cliente falló.", values.Get("error_description"))
+	assert.Equal(t, "El cliente es desconocido.", values.Get("error_hint"))
+}
+
+func TestLangFromUILocales(t *testing.T) {
+	catalog := i18n.NewDefaultMessageCatalog([]*i18n.DefaultLocaleBundle{
+		{LangTag: "es", Messages: []*i18n.DefaultMessage{{ID: "invalid_request", FormattedMessage: "Solicitud no válida."}}},
+	})
+	f := &Fosite{MessageCatalog: catalog}
+
+	r := httptest.NewRequest("GET", "/logout?ui_locales=es-MX+en", nil)
+	request, _ := f.NewLogoutRequest(context.Background(), r)
+	require.NotNil(t, request)
+
+	base, _ := request.GetLang().Base()
+	assert.Equal(t, "es", base.String())
+}
@@ -0,0 +1,163 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package integration_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+)
+
+type clientOnlyStore struct {
+	fosite.ClientManager
+}
+
+func TestComposeOptions(t *testing.T) {
+	secret := []byte("some-secret-thats-random-some-secret-thats-random-")
+
+	for k, c := range []struct {
+		d          string
+		storage    interface{}
+		opts       []compose.Option
+		expectErrs []string
+	}{
+		{
+			d:       "should pass",
+			storage: fositeStore,
+			opts: []compose.Option{
+				compose.WithConfig(&compose.Config{RequireOpenIDConnectNonce: true}),
+				compose.WithSecret(secret),
+				compose.WithPrivateKey(firstPrivateKey),
+				compose.WithFeatures(compose.AllFeatures...),
+			},
+		},
+		{
+			d:       "should pass with a strategy",
+			storage: fositeStore,
+			opts: []compose.Option{
+				compose.WithStrategy(hmacStrategy),
+				compose.WithFeatures(compose.FeatureClientCredentials, compose.FeatureIntrospection),
+			},
+		},
+		{
+			d:          "should fail because nothing is enabled",
+			storage:    fositeStore,
+			opts:       []compose.Option{compose.WithSecret(secret)},
+			expectErrs: []string{"no feature is enabled"},
+		},
+		{
+			d:          "should fail because the secret is too short",
+			storage:    fositeStore,
+			opts:       []compose.Option{compose.WithSecret([]byte("foo")), compose.WithFeatures(compose.FeatureClientCredentials)},
+			expectErrs: []string{"a strategy or a secret of at least 32 bytes is required, got a secret of 3 bytes"},
+		},
+		{
+			d:       "should fail because OpenID Connect has no strategy and implicit has no nonce enforcement",
+			storage: fositeStore,
+			opts: []compose.Option{
+				compose.WithSecret(secret),
+				compose.WithFeatures(compose.FeatureAuthorizeCode, compose.FeatureImplicit, compose.FeatureOpenIDConnect),
+			},
+			expectErrs: []string{
+				`the feature "openid_connect" requires an OpenID Connect token strategy`,
+				`the feature "implicit" together with "openid_connect" requires RequireOpenIDConnectNonce`,
+			},
+		},
+		{
+			d:       "should fail because of missing dependencies",
+			storage: fositeStore,
+			opts: []compose.Option{
+				compose.WithConfig(&compose.Config{EnforceOAuth21: true}),
+				compose.WithSecret(secret),
+				compose.WithFeatures(compose.FeaturePKCE, compose.FeatureResourceOwnerPassword, "foo"),
+			},
+			expectErrs: []string{
+				`the feature "foo" is unknown`,
+				`the feature "pkce" requires the feature "authorize_code"`,
+				`the feature "password" is disabled by EnforceOAuth21`,
+			},
+		},
+		{
+			d:          "should fail because no feature issues refresh tokens",
+			storage:    fositeStore,
+			opts:       []compose.Option{compose.WithSecret(secret), compose.WithFeatures(compose.FeatureClientCredentials, compose.FeatureRefreshToken)},
+			expectErrs: []string{`the feature "refresh_token" requires a feature issuing refresh tokens`},
+		},
+		{
+			d:          "should fail because the strategy does not issue OAuth2 tokens",
+			storage:    fositeStore,
+			opts:       []compose.Option{compose.WithStrategy(&compose.CommonStrategy{}), compose.WithFeatures(compose.FeatureClientCredentials)},
+			expectErrs: []string{`the feature "client_credentials" requires an OAuth2 core strategy`},
+		},
+		{
+			d:          "should fail because the storage does not implement the storage of the feature",
+			storage:    new(clientOnlyStore),
+			opts:       []compose.Option{compose.WithSecret(secret), compose.WithFeatures(compose.FeatureClientCredentials)},
+			expectErrs: []string{"OAuth2ClientCredentialsGrantFactory: interface conversion"},
+		},
+		{
+			d:          "should fail because there is no storage",
+			opts:       []compose.Option{compose.WithSecret(secret), compose.WithFeatures(compose.FeatureClientCredentials)},
+			expectErrs: []string{"a storage is required"},
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			provider, err := compose.New(c.storage, c.opts...)
+			if len(c.expectErrs) == 0 {
+				require.NoError(t, err)
+				require.NotNil(t, provider)
+				return
+			}
+
+			require.Error(t, err)
+			var ve *compose.ValidationError
+			require.True(t, errors.As(err, &ve))
+			for _, expected := range c.expectErrs {
+				assert.Contains(t, err.Error(), expected)
+			}
+		})
+	}
+}
+
+func TestComposeOptionsClientCredentials(t *testing.T) {
+	f, err := compose.New(fositeStore,
+		compose.WithStrategy(hmacStrategy),
+		compose.WithFeatures(compose.FeatureClientCredentials, compose.FeatureIntrospection),
+	)
+	require.NoError(t, err)
+
+	ts := mockServer(t, f, &fosite.DefaultSession{})
+	defer ts.Close()
+
+	oauthClient := newOAuth2AppClient(ts)
+	oauthClient.Scopes = []string{"fosite"}
+	token, err := oauthClient.Token(context.Background())
+	require.NoError(t, err)
+	assert.NotEmpty(t, token.AccessToken)
+}
@@ -0,0 +1,140 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package integration_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/storage"
+)
+
+func TestMultiTenantProvider(t *testing.T) {
+	newTenant := func(issuer string, clients ...string) *compose.Tenant {
+		store := storage.NewMemoryStore()
+		for _, id := range clients {
+			store.Clients[id] = &fosite.DefaultClient{
+				ID:         id,
+				Secret:     []byte(`$2a$10$IxMdI6d.LIRZPpSfEwNoeu4rY3FhDREsxFJXikcgdRRAStxUlsuEO`), // = "foobar"
+				GrantTypes: []string{"client_credentials"},
+				Scopes:     []string{"fosite"},
+			}
+		}
+		config := &compose.Config{IDTokenIssuer: issuer}
+		return &compose.Tenant{
+			Config:   config,
+			Storage:  store,
+			Strategy: compose.NewOAuth2HMACStrategy(config, []byte("some-secret-thats-random-some-secret-thats-random-"), nil),
+		}
+	}
+
+	tenants := compose.StaticTenants{
+		"tenant-a": newTenant("https://tenant-a.example.com", "client-a"),
+		"tenant-b": newTenant("https://tenant-b.example.com", "client-b"),
+	}
+	resolver := compose.TenantResolverFunc(func(_ context.Context, r *http.Request) (string, error) {
+		return strings.Split(r.Host, ".")[0], nil
+	})
+	provider := compose.NewMultiTenantProvider(resolver, tenants, compose.OAuth2ClientCredentialsGrantFactory, compose.OAuth2TokenIntrospectionFactory)
+
+	token := func(t *testing.T, host, clientID string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "https://"+host+"/token", strings.NewReader(url.Values{"grant_type": {"client_credentials"}}.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.SetBasicAuth(clientID, "foobar")
+		rw := httptest.NewRecorder()
+
+		ctx, oauth2, err := provider.ProviderForRequest(context.Background(), r)
+		require.NoError(t, err)
+		assert.Equal(t, strings.Split(host, ".")[0], ctx.Value(compose.TenantContextKey))
+
+		ar, err := oauth2.NewAccessRequest(ctx, r, new(fosite.DefaultSession))
+		if err != nil {
+			oauth2.WriteAccessError(rw, ar, err)
+			return rw
+		}
+		response, err := oauth2.NewAccessResponse(ctx, ar)
+		require.NoError(t, err)
+		oauth2.WriteAccessResponse(rw, ar, response)
+		return rw
+	}
+
+	t.Run("case=routes requests to the tenant", func(t *testing.T) {
+		rw := token(t, "tenant-a.example.com", "client-a")
+		require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(rw.Body).Decode(&body))
+		accessToken, _ := body["access_token"].(string)
+
+		oauth2, err := provider.Provider(context.Background(), "tenant-a")
+		require.NoError(t, err)
+		_, _, err = oauth2.IntrospectToken(context.Background(), accessToken, fosite.AccessToken, new(fosite.DefaultSession))
+		require.NoError(t, err)
+
+		oauth2, err = provider.Provider(context.Background(), "tenant-b")
+		require.NoError(t, err)
+		_, _, err = oauth2.IntrospectToken(context.Background(), accessToken, fosite.AccessToken, new(fosite.DefaultSession))
+		require.Error(t, err, "tokens of other tenants must be inactive")
+	})
+
+	t.Run("case=rejects clients of other tenants", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, token(t, "tenant-b.example.com", "client-a").Code)
+		assert.Equal(t, http.StatusOK, token(t, "tenant-b.example.com", "client-b").Code)
+	})
+
+	t.Run("case=rejects unknown tenants", func(t *testing.T) {
+		_, _, err := provider.ProviderForRequest(context.Background(), httptest.NewRequest("POST", "https://tenant-c.example.com/token", nil))
+		require.ErrorIs(t, err, fosite.ErrInvalidRequest)
+	})
+
+	t.Run("case=reuses and evicts providers", func(t *testing.T) {
+		bounded := compose.NewMultiTenantProvider(resolver, tenants, compose.OAuth2ClientCredentialsGrantFactory)
+		bounded.MaxTenants = 1
+
+		a, err := bounded.Provider(context.Background(), "tenant-a")
+		require.NoError(t, err)
+		again, err := bounded.Provider(context.Background(), "tenant-a")
+		require.NoError(t, err)
+		assert.Same(t, a, again)
+
+		_, err = bounded.Provider(context.Background(), "tenant-b")
+		require.NoError(t, err)
+		again, err = bounded.Provider(context.Background(), "tenant-a")
+		require.NoError(t, err)
+		assert.NotSame(t, a, again, "the provider of tenant-a must have been dropped")
+
+		bounded.Evict("tenant-a")
+		evicted, err := bounded.Provider(context.Background(), "tenant-a")
+		require.NoError(t, err)
+		assert.NotSame(t, again, evicted)
+	})
+}
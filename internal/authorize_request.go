@@ -12,6 +12,7 @@ import (
 	gomock "github.com/golang/mock/gomock"
 
 	fosite "github.com/ory/fosite"
+	jwt "github.com/ory/fosite/token/jwt"
 )
 
 // MockAuthorizeRequester is a mock of AuthorizeRequester interface
@@ -63,6 +64,20 @@ func (mr *MockAuthorizeRequesterMockRecorder) DidHandleAllResponseTypes() *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DidHandleAllResponseTypes", reflect.TypeOf((*MockAuthorizeRequester)(nil).DidHandleAllResponseTypes))
 }
 
+// GetAuthorizationDetails mocks base method
+func (m *MockAuthorizeRequester) GetAuthorizationDetails() fosite.AuthorizationDetails {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAuthorizationDetails")
+	ret0, _ := ret[0].(fosite.AuthorizationDetails)
+	return ret0
+}
+
+// GetAuthorizationDetails indicates an expected call of GetAuthorizationDetails
+func (mr *MockAuthorizeRequesterMockRecorder) GetAuthorizationDetails() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAuthorizationDetails", reflect.TypeOf((*MockAuthorizeRequester)(nil).GetAuthorizationDetails))
+}
+
 // GetClient mocks base method
 func (m *MockAuthorizeRequester) GetClient() fosite.Client {
 	m.ctrl.T.Helper()
@@ -161,6 +176,20 @@ func (mr *MockAuthorizeRequesterMockRecorder) GetRequestForm() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRequestForm", reflect.TypeOf((*MockAuthorizeRequester)(nil).GetRequestForm))
 }
 
+// GetRequestObjectClaims mocks base method
+func (m *MockAuthorizeRequester) GetRequestObjectClaims() jwt.MapClaims {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRequestObjectClaims")
+	ret0, _ := ret[0].(jwt.MapClaims)
+	return ret0
+}
+
+// GetRequestObjectClaims indicates an expected call of GetRequestObjectClaims
+func (mr *MockAuthorizeRequesterMockRecorder) GetRequestObjectClaims() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRequestObjectClaims", reflect.TypeOf((*MockAuthorizeRequester)(nil).GetRequestObjectClaims))
+}
+
 // GetRequestedAt mocks base method
 func (m *MockAuthorizeRequester) GetRequestedAt() time.Time {
 	m.ctrl.T.Helper()
@@ -189,6 +218,34 @@ func (mr *MockAuthorizeRequesterMockRecorder) GetRequestedAudience() *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRequestedAudience", reflect.TypeOf((*MockAuthorizeRequester)(nil).GetRequestedAudience))
 }
 
+// GetRequestedAuthorizationDetails mocks base method
+func (m *MockAuthorizeRequester) GetRequestedAuthorizationDetails() fosite.AuthorizationDetails {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRequestedAuthorizationDetails")
+	ret0, _ := ret[0].(fosite.AuthorizationDetails)
+	return ret0
+}
+
+// GetRequestedAuthorizationDetails indicates an expected call of GetRequestedAuthorizationDetails
+func (mr *MockAuthorizeRequesterMockRecorder) GetRequestedAuthorizationDetails() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRequestedAuthorizationDetails", reflect.TypeOf((*MockAuthorizeRequester)(nil).GetRequestedAuthorizationDetails))
+}
+
+// GetRequestedClaims mocks base method
+func (m *MockAuthorizeRequester) GetRequestedClaims() *fosite.ClaimsRequest {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRequestedClaims")
+	ret0, _ := ret[0].(*fosite.ClaimsRequest)
+	return ret0
+}
+
+// GetRequestedClaims indicates an expected call of GetRequestedClaims
+func (mr *MockAuthorizeRequesterMockRecorder) GetRequestedClaims() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRequestedClaims", reflect.TypeOf((*MockAuthorizeRequester)(nil).GetRequestedClaims))
+}
+
 // GetRequestedScopes mocks base method
 func (m *MockAuthorizeRequester) GetRequestedScopes() fosite.Arguments {
 	m.ctrl.T.Helper()
@@ -271,6 +328,18 @@ func (mr *MockAuthorizeRequesterMockRecorder) GrantAudience(arg0 interface{}) *g
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GrantAudience", reflect.TypeOf((*MockAuthorizeRequester)(nil).GrantAudience), arg0)
 }
 
+// GrantAuthorizationDetails mocks base method
+func (m *MockAuthorizeRequester) GrantAuthorizationDetails(arg0 fosite.AuthorizationDetails) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "GrantAuthorizationDetails", arg0)
+}
+
+// GrantAuthorizationDetails indicates an expected call of GrantAuthorizationDetails
+func (mr *MockAuthorizeRequesterMockRecorder) GrantAuthorizationDetails(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GrantAuthorizationDetails", reflect.TypeOf((*MockAuthorizeRequester)(nil).GrantAuthorizationDetails), arg0)
+}
+
 // GrantScope mocks base method
 func (m *MockAuthorizeRequester) GrantScope(arg0 string) {
 	m.ctrl.T.Helper()
@@ -359,6 +428,18 @@ func (mr *MockAuthorizeRequesterMockRecorder) SetRequestedAudience(arg0 interfac
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetRequestedAudience", reflect.TypeOf((*MockAuthorizeRequester)(nil).SetRequestedAudience), arg0)
 }
 
+// SetRequestedAuthorizationDetails mocks base method
+func (m *MockAuthorizeRequester) SetRequestedAuthorizationDetails(arg0 fosite.AuthorizationDetails) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetRequestedAuthorizationDetails", arg0)
+}
+
+// SetRequestedAuthorizationDetails indicates an expected call of SetRequestedAuthorizationDetails
+func (mr *MockAuthorizeRequesterMockRecorder) SetRequestedAuthorizationDetails(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetRequestedAuthorizationDetails", reflect.TypeOf((*MockAuthorizeRequester)(nil).SetRequestedAuthorizationDetails), arg0)
+}
+
 // SetRequestedScopes mocks base method
 func (m *MockAuthorizeRequester) SetRequestedScopes(arg0 fosite.Arguments) {
 	m.ctrl.T.Helper()
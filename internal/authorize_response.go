@@ -35,6 +35,18 @@ func (m *MockAuthorizeResponder) EXPECT() *MockAuthorizeResponderMockRecorder {
 	return m.recorder
 }
 
+// AddCookie mocks base method
+func (m *MockAuthorizeResponder) AddCookie(arg0 *http.Cookie) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "AddCookie", arg0)
+}
+
+// AddCookie indicates an expected call of AddCookie
+func (mr *MockAuthorizeResponderMockRecorder) AddCookie(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddCookie", reflect.TypeOf((*MockAuthorizeResponder)(nil).AddCookie), arg0)
+}
+
 // AddHeader mocks base method
 func (m *MockAuthorizeResponder) AddHeader(arg0, arg1 string) {
 	m.ctrl.T.Helper()
@@ -49,6 +49,20 @@ func (mr *MockRequesterMockRecorder) AppendRequestedScope(arg0 interface{}) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AppendRequestedScope", reflect.TypeOf((*MockRequester)(nil).AppendRequestedScope), arg0)
 }
 
+// GetAuthorizationDetails mocks base method
+func (m *MockRequester) GetAuthorizationDetails() fosite.AuthorizationDetails {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAuthorizationDetails")
+	ret0, _ := ret[0].(fosite.AuthorizationDetails)
+	return ret0
+}
+
+// GetAuthorizationDetails indicates an expected call of GetAuthorizationDetails
+func (mr *MockRequesterMockRecorder) GetAuthorizationDetails() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAuthorizationDetails", reflect.TypeOf((*MockRequester)(nil).GetAuthorizationDetails))
+}
+
 // GetClient mocks base method
 func (m *MockRequester) GetClient() fosite.Client {
 	m.ctrl.T.Helper()
@@ -147,6 +161,20 @@ func (mr *MockRequesterMockRecorder) GetRequestedAudience() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRequestedAudience", reflect.TypeOf((*MockRequester)(nil).GetRequestedAudience))
 }
 
+// GetRequestedAuthorizationDetails mocks base method
+func (m *MockRequester) GetRequestedAuthorizationDetails() fosite.AuthorizationDetails {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRequestedAuthorizationDetails")
+	ret0, _ := ret[0].(fosite.AuthorizationDetails)
+	return ret0
+}
+
+// GetRequestedAuthorizationDetails indicates an expected call of GetRequestedAuthorizationDetails
+func (mr *MockRequesterMockRecorder) GetRequestedAuthorizationDetails() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRequestedAuthorizationDetails", reflect.TypeOf((*MockRequester)(nil).GetRequestedAuthorizationDetails))
+}
+
 // GetRequestedScopes mocks base method
 func (m *MockRequester) GetRequestedScopes() fosite.Arguments {
 	m.ctrl.T.Helper()
@@ -187,6 +215,18 @@ func (mr *MockRequesterMockRecorder) GrantAudience(arg0 interface{}) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GrantAudience", reflect.TypeOf((*MockRequester)(nil).GrantAudience), arg0)
 }
 
+// GrantAuthorizationDetails mocks base method
+func (m *MockRequester) GrantAuthorizationDetails(arg0 fosite.AuthorizationDetails) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "GrantAuthorizationDetails", arg0)
+}
+
+// GrantAuthorizationDetails indicates an expected call of GrantAuthorizationDetails
+func (mr *MockRequesterMockRecorder) GrantAuthorizationDetails(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GrantAuthorizationDetails", reflect.TypeOf((*MockRequester)(nil).GrantAuthorizationDetails), arg0)
+}
+
 // GrantScope mocks base method
 func (m *MockRequester) GrantScope(arg0 string) {
 	m.ctrl.T.Helper()
@@ -249,6 +289,18 @@ func (mr *MockRequesterMockRecorder) SetRequestedAudience(arg0 interface{}) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetRequestedAudience", reflect.TypeOf((*MockRequester)(nil).SetRequestedAudience), arg0)
 }
 
+// SetRequestedAuthorizationDetails mocks base method
+func (m *MockRequester) SetRequestedAuthorizationDetails(arg0 fosite.AuthorizationDetails) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetRequestedAuthorizationDetails", arg0)
+}
+
+// SetRequestedAuthorizationDetails indicates an expected call of SetRequestedAuthorizationDetails
+func (mr *MockRequesterMockRecorder) SetRequestedAuthorizationDetails(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetRequestedAuthorizationDetails", reflect.TypeOf((*MockRequester)(nil).SetRequestedAuthorizationDetails), arg0)
+}
+
 // SetRequestedScopes mocks base method
 func (m *MockRequester) SetRequestedScopes(arg0 fosite.Arguments) {
 	m.ctrl.T.Helper()
@@ -42,7 +42,7 @@ func AccessTokenFromRequest(req *http.Request) string {
 
 	auth := req.Header.Get("Authorization")
 	split := strings.SplitN(auth, " ", 2)
-	if len(split) != 2 || !strings.EqualFold(split[0], "bearer") {
+	if len(split) != 2 || !(strings.EqualFold(split[0], "bearer") || strings.EqualFold(split[0], DPoPTokenType)) {
 		// Nothing in Authorization header, try access_token
 		// Empty string returned if there's no such parameter
 		if err := req.ParseMultipartForm(1 << 20); err != nil && err != http.ErrNotMultipart {
@@ -54,7 +54,23 @@ func AccessTokenFromRequest(req *http.Request) string {
 	return split[1]
 }
 
+// IntrospectToken validates the token using the configured TokenIntrospectionHandlers. If the protected resource
+// request is stored in the context using ResourceRequestContextKey, the DPoP proof and the TLS client certificate of
+// that request are verified against DPoP-bound and certificate-bound access tokens. Otherwise, DPoP-bound and
+// certificate-bound access tokens are rejected. If AuthenticationRequirements are stored in the context using
+// AuthenticationRequirementsContextKey, access tokens must satisfy them.
 func (f *Fosite) IntrospectToken(ctx context.Context, token string, tokenUse TokenUse, session Session, scopes ...string) (TokenUse, AccessRequester, error) {
+	ctx = f.withStoragePolicy(ctx)
+	ctx = ensureRequestID(ctx, nil)
+	use, request, err := f.introspectToken(ctx, token, tokenUse, session, scopes...)
+	return use, request, attachRequestID(ctx, err)
+}
+
+// skipTokenBindingContextKey disables the verification of DPoP-bound and certificate-bound access tokens. It is only
+// set by the introspection endpoint, which returns the "cnf" claim to the resource server instead.
+type skipTokenBindingContextKey struct{}
+
+func (f *Fosite) introspectToken(ctx context.Context, token string, tokenUse TokenUse, session Session, scopes ...string) (TokenUse, AccessRequester, error) {
 	var found = false
 	var foundTokenUse TokenUse = ""
 
@@ -76,5 +92,21 @@ func (f *Fosite) IntrospectToken(ctx context.Context, token string, tokenUse Tok
 		return "", nil, errorsx.WithStack(ErrRequestUnauthorized.WithHint("Unable to find a suitable validation strategy for the token, thus it is invalid."))
 	}
 
+	if foundTokenUse == AccessToken {
+		if skipTokenBinding, _ := ctx.Value(skipTokenBindingContextKey{}).(bool); skipTokenBinding {
+			// The token is not presented to a protected resource, its confirmation is returned to the caller instead.
+		} else if err := f.verifyDPoPBinding(ctx, token, ar); err != nil {
+			return "", nil, errorsx.WithStack(ErrorToRFC6749Error(err))
+		} else if err := f.verifyCertificateBinding(ctx, ar); err != nil {
+			return "", nil, errorsx.WithStack(ErrorToRFC6749Error(err))
+		}
+
+		if requirements, ok := ctx.Value(AuthenticationRequirementsContextKey).(AuthenticationRequirements); ok {
+			if err := ValidateAuthenticationRequirements(ar, requirements); err != nil {
+				return "", nil, err
+			}
+		}
+	}
+
 	return foundTokenUse, ar, nil
 }
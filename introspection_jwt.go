@@ -0,0 +1,191 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ory/x/errorsx"
+	"gopkg.in/square/go-jose.v2"
+
+	"github.com/ory/fosite/token/jwt"
+)
+
+// IntrospectionJWTContentType is the media type of JWT introspection responses, see
+// https://tools.ietf.org/html/rfc9701#section-4
+const IntrospectionJWTContentType = "application/token-introspection+jwt"
+
+// IntrospectionJWTStrategy creates JWT introspection responses (https://tools.ietf.org/html/rfc9701).
+type IntrospectionJWTStrategy interface {
+	// GenerateIntrospectionJWT returns the signed, and if required by the client encrypted, JWT carrying the
+	// introspection response for the client which performed the introspection request.
+	GenerateIntrospectionJWT(ctx context.Context, client Client, response map[string]interface{}) (string, error)
+}
+
+// IntrospectionJWTClient represents a client which registered how JWT introspection responses are signed and
+// encrypted, see https://tools.ietf.org/html/rfc9701#section-6
+type IntrospectionJWTClient interface {
+	// GetIntrospectionSignedResponseAlg returns the JWS alg used for signing introspection responses.
+	GetIntrospectionSignedResponseAlg() string
+
+	// GetIntrospectionEncryptedResponseAlg returns the JWE alg used for encrypting introspection responses. The
+	// responses are not encrypted if empty.
+	GetIntrospectionEncryptedResponseAlg() string
+
+	// GetIntrospectionEncryptedResponseEnc returns the JWE enc used for encrypting introspection responses.
+	GetIntrospectionEncryptedResponseEnc() string
+}
+
+// JWTIntrospectionResponder is implemented by introspection responses which can be returned as a JWT.
+type JWTIntrospectionResponder interface {
+	IntrospectionResponder
+
+	// IsJWTResponseRequested returns true if the client which performed the introspection request asked for a JWT
+	// introspection response.
+	IsJWTResponseRequested() bool
+
+	// GetClient returns the client which performed the introspection request.
+	GetClient() Client
+}
+
+// DefaultIntrospectionJWTStrategy signs responses using the signer registered for the algorithm requested by the
+// client and encrypts them using the client's JSON Web Keys, if the client requested encryption.
+type DefaultIntrospectionJWTStrategy struct {
+	// Signers maps JWS algorithms (e.g. "RS256") to the strategy used for signing responses with that algorithm.
+	Signers map[string]jwt.JWTStrategy
+
+	// DefaultSigningAlgorithm is used for clients which did not register a signing algorithm. Defaults to RS256.
+	DefaultSigningAlgorithm string
+
+	// Issuer is the value of the "iss" claim.
+	Issuer string
+
+	// IssuerProvider, if set, takes precedence over Issuer.
+	IssuerProvider IssuerProvider
+
+	// JWKSFetcherStrategy resolves the keys of clients with a jwks_uri for encrypting responses.
+	JWKSFetcherStrategy JWKSFetcherStrategy
+
+	// Encrypter encrypts responses for clients which requested encryption. Defaults to jwt.DefaultEncrypter.
+	Encrypter jwt.Encrypter
+}
+
+func (s *DefaultIntrospectionJWTStrategy) GenerateIntrospectionJWT(ctx context.Context, client Client, response map[string]interface{}) (string, error) {
+	alg := s.DefaultSigningAlgorithm
+	if alg == "" {
+		alg = string(jose.RS256)
+	}
+
+	ic, ok := client.(IntrospectionJWTClient)
+	if ok && ic.GetIntrospectionSignedResponseAlg() != "" {
+		alg = ic.GetIntrospectionSignedResponseAlg()
+	}
+
+	signer, found := s.Signers[alg]
+	if !found {
+		return "", errorsx.WithStack(ErrServerError.WithHintf("The introspection response can not be signed using algorithm '%s'.", alg))
+	}
+
+	issuer, err := GetIssuer(ctx, s.IssuerProvider, s.Issuer)
+	if err != nil {
+		return "", err
+	}
+
+	claims := jwt.MapClaims{
+		"iss":                 issuer,
+		"aud":                 client.GetID(),
+		"iat":                 time.Now().UTC().Unix(),
+		"token_introspection": response,
+	}
+
+	token, _, err := signer.Generate(ctx, claims, &jwt.Headers{Extra: map[string]interface{}{
+		"typ": "token-introspection+jwt",
+	}})
+	if err != nil {
+		return "", errorsx.WithStack(ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	if !ok || ic.GetIntrospectionEncryptedResponseAlg() == "" {
+		return token, nil
+	}
+
+	alg = ic.GetIntrospectionEncryptedResponseAlg()
+	set, err := clientEncryptionKeys(client, s.JWKSFetcherStrategy)
+	if err != nil {
+		return "", err
+	}
+
+	key, err := jwt.FindEncryptionKey(set, alg)
+	if err != nil {
+		return "", errorsx.WithStack(ErrServerError.WithHintf("The OAuth 2.0 Client has no JSON Web Key registered which is suitable for encrypting introspection responses using algorithm '%s'.", alg).WithWrap(err).WithDebug(err.Error()))
+	}
+
+	encrypter := s.Encrypter
+	if encrypter == nil {
+		encrypter = new(jwt.DefaultEncrypter)
+	}
+
+	encrypted, err := encrypter.Encrypt(ctx, token, key, alg, ic.GetIntrospectionEncryptedResponseEnc())
+	if err != nil {
+		return "", errorsx.WithStack(ErrServerError.WithHint("Unable to encrypt the introspection response.").WithWrap(err).WithDebug(err.Error()))
+	}
+	return encrypted, nil
+}
+
+// acceptsIntrospectionJWT returns true if the request's Accept header asks for a JWT introspection response.
+func acceptsIntrospectionJWT(r *http.Request) bool {
+	return acceptsMediaType(r, IntrospectionJWTContentType)
+}
+
+// acceptsMediaType returns true if the request's Accept header lists the given media type.
+func acceptsMediaType(r *http.Request, mediaType string) bool {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		if accepted, _, err := mime.ParseMediaType(strings.TrimSpace(accept)); err == nil && accepted == mediaType {
+			return true
+		}
+	}
+	return false
+}
+
+// writeIntrospectionJWT delivers the introspection response as a JWT, as defined in
+// https://tools.ietf.org/html/rfc9701#section-5
+func (f *Fosite) writeIntrospectionJWT(rw http.ResponseWriter, client Client, issuer string, response map[string]interface{}) {
+	ctx := context.Background()
+	if issuer != "" {
+		ctx = context.WithValue(ctx, IssuerContextKey, issuer)
+	}
+
+	token, err := f.IntrospectionJWTStrategy.GenerateIntrospectionJWT(ctx, client, response)
+	if err != nil {
+		f.writeError(rw, IntrospectionEndpoint, nil, err)
+		return
+	}
+
+	rw.Header().Set("Content-Type", IntrospectionJWTContentType)
+	rw.Header().Set("Cache-Control", "no-store")
+	rw.Header().Set("Pragma", "no-cache")
+	_, _ = rw.Write([]byte(token))
+}
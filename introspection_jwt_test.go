@@ -0,0 +1,140 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	josejwt "gopkg.in/square/go-jose.v2/jwt"
+
+	. "github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/internal"
+	"github.com/ory/fosite/storage"
+	"github.com/ory/fosite/token/jwt"
+)
+
+func TestIntrospectionJWT(t *testing.T) {
+	serverKey := internal.MustRSAKey()
+	strategy := &DefaultIntrospectionJWTStrategy{
+		Signers: map[string]jwt.JWTStrategy{"RS256": &jwt.RS256JWTStrategy{PrivateKey: serverKey}},
+		Issuer:  "https://auth.example.com",
+	}
+	client := &DefaultOpenIDConnectClient{DefaultClient: &DefaultClient{ID: "resource-server"}}
+
+	parse := func(t *testing.T, rw *httptest.ResponseRecorder) (map[string]interface{}, string) {
+		require.Equal(t, http.StatusOK, rw.Code)
+		assert.Equal(t, IntrospectionJWTContentType, rw.Header().Get("Content-Type"))
+
+		parsed, err := josejwt.ParseSigned(rw.Body.String())
+		require.NoError(t, err)
+		claims := map[string]interface{}{}
+		require.NoError(t, parsed.Claims(&serverKey.PublicKey, &claims))
+		return claims, parsed.Headers[0].ExtraHeaders["typ"].(string)
+	}
+
+	t.Run("case=active token", func(t *testing.T) {
+		f := &Fosite{IntrospectionJWTStrategy: strategy}
+		ar := NewAccessRequest(&DefaultSession{Subject: "peter"})
+		ar.Client = &DefaultClient{ID: "foo"}
+		ar.GrantedScope = Arguments{"foo", "bar"}
+
+		rw := httptest.NewRecorder()
+		f.WriteIntrospectionResponse(rw, &IntrospectionResponse{Active: true, AccessRequester: ar, Client: client, JWTResponse: true})
+
+		claims, typ := parse(t, rw)
+		assert.Equal(t, "token-introspection+jwt", typ)
+		assert.Equal(t, "https://auth.example.com", claims["iss"])
+		assert.Equal(t, "resource-server", claims["aud"])
+		assert.NotEmpty(t, claims["iat"])
+
+		response := claims["token_introspection"].(map[string]interface{})
+		assert.Equal(t, true, response["active"])
+		assert.Equal(t, "foo", response["client_id"])
+		assert.Equal(t, "foo bar", response["scope"])
+		assert.Equal(t, "peter", response["sub"])
+	})
+
+	t.Run("case=inactive token", func(t *testing.T) {
+		f := &Fosite{IntrospectionJWTStrategy: strategy}
+		rw := httptest.NewRecorder()
+		f.WriteIntrospectionResponse(rw, &IntrospectionResponse{Active: false, Client: client, JWTResponse: true})
+
+		claims, _ := parse(t, rw)
+		assert.Equal(t, map[string]interface{}{"active": false}, claims["token_introspection"])
+	})
+
+	t.Run("case=unsupported signing algorithm", func(t *testing.T) {
+		f := &Fosite{IntrospectionJWTStrategy: strategy}
+		rw := httptest.NewRecorder()
+		f.WriteIntrospectionResponse(rw, &IntrospectionResponse{Active: false, JWTResponse: true, Client: &DefaultOpenIDConnectClient{
+			DefaultClient:                  &DefaultClient{ID: "resource-server"},
+			IntrospectionSignedResponseAlg: "ES256",
+		}})
+		assert.Equal(t, http.StatusInternalServerError, rw.Code)
+	})
+
+	t.Run("case=json response if not requested or not enabled", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		(&Fosite{IntrospectionJWTStrategy: strategy}).WriteIntrospectionResponse(rw, &IntrospectionResponse{Active: false, Client: client})
+		assert.JSONEq(t, `{"active":false}`, rw.Body.String())
+
+		rw = httptest.NewRecorder()
+		new(Fosite).WriteIntrospectionResponse(rw, &IntrospectionResponse{Active: false, Client: client, JWTResponse: true})
+		assert.JSONEq(t, `{"active":false}`, rw.Body.String())
+	})
+
+	t.Run("case=introspection request with accept header", func(t *testing.T) {
+		store := storage.NewMemoryStore()
+		store.Clients["resource-server"] = &DefaultClient{ID: "resource-server", Secret: []byte(`$2a$10$IxMdI6d.LIRZPpSfEwNoeu4rY3FhDREsxFJXikcgdRRAStxUlsuEO`)}
+		config := &compose.Config{IntrospectionJWTStrategy: strategy}
+		hmacStrategy := compose.NewOAuth2HMACStrategy(config, []byte("some-secret-thats-random-some-secret-thats-random-"), nil)
+		f := compose.Compose(config, store, hmacStrategy, nil, compose.OAuth2TokenIntrospectionFactory)
+
+		token, signature, err := hmacStrategy.GenerateAccessToken(nil, nil)
+		require.NoError(t, err)
+		session := &DefaultSession{ExpiresAt: map[TokenType]time.Time{AccessToken: time.Now().UTC().Add(time.Hour)}}
+		require.NoError(t, store.CreateAccessTokenSession(nil, signature, &Request{Client: &DefaultClient{ID: "foo"}, RequestedAt: time.Now().UTC(), Session: session}))
+
+		r := httptest.NewRequest("POST", "/introspect", strings.NewReader(url.Values{"token": {token}}.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.Header.Set("Accept", IntrospectionJWTContentType+", application/json;q=0.5")
+		r.SetBasicAuth("resource-server", "foobar")
+
+		ir, err := f.NewIntrospectionRequest(context.Background(), r, &DefaultSession{})
+		require.NoError(t, err)
+
+		rw := httptest.NewRecorder()
+		f.WriteIntrospectionResponse(rw, ir)
+		claims, _ := parse(t, rw)
+		assert.Equal(t, "resource-server", claims["aud"])
+		assert.Equal(t, "foo", claims["token_introspection"].(map[string]interface{})["client_id"])
+	})
+}
@@ -0,0 +1,78 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import "context"
+
+// IntrospectionClient is a client, typically a protected resource, which introspects tokens issued for the
+// given audiences.
+type IntrospectionClient interface {
+	// GetIntrospectionAudience returns the audiences of the tokens the client is allowed to introspect.
+	GetIntrospectionAudience() Arguments
+}
+
+// IntrospectionPolicy decides which tokens the authenticated caller of the introspection endpoint is allowed to
+// introspect. Tokens the caller is not allowed to introspect are reported as inactive, see
+// https://tools.ietf.org/html/rfc7662#section-4.
+type IntrospectionPolicy interface {
+	// CanIntrospect returns true if the caller may learn about the token issued in the requester.
+	CanIntrospect(ctx context.Context, caller Client, requester AccessRequester) bool
+}
+
+// IntrospectionPolicyFunc is a function implementing IntrospectionPolicy.
+type IntrospectionPolicyFunc func(ctx context.Context, caller Client, requester AccessRequester) bool
+
+func (f IntrospectionPolicyFunc) CanIntrospect(ctx context.Context, caller Client, requester AccessRequester) bool {
+	return f(ctx, caller, requester)
+}
+
+var (
+	// AllowAllIntrospectionPolicy allows every authenticated caller to introspect all tokens. This is the default.
+	AllowAllIntrospectionPolicy IntrospectionPolicy = IntrospectionPolicyFunc(func(context.Context, Client, AccessRequester) bool { return true })
+
+	// AudienceRestrictedIntrospectionPolicy only allows the caller to introspect tokens which were issued to it or
+	// whose granted audience contains one of the audiences returned by IntrospectionClient, or the caller's client
+	// ID if the caller does not implement IntrospectionClient.
+	AudienceRestrictedIntrospectionPolicy IntrospectionPolicy = IntrospectionPolicyFunc(audienceRestrictedIntrospection)
+)
+
+func audienceRestrictedIntrospection(_ context.Context, caller Client, requester AccessRequester) bool {
+	if caller == nil {
+		return false
+	}
+
+	if client := requester.GetClient(); client != nil && client.GetID() != "" && client.GetID() == caller.GetID() {
+		return true
+	}
+
+	allowed := Arguments{caller.GetID()}
+	if ic, ok := caller.(IntrospectionClient); ok {
+		allowed = ic.GetIntrospectionAudience()
+	}
+
+	for _, audience := range requester.GetGrantedAudience() {
+		if allowed.Has(audience) {
+			return true
+		}
+	}
+	return false
+}
@@ -28,6 +28,7 @@ import (
 	"strings"
 
 	"github.com/ory/x/errorsx"
+	"github.com/pkg/errors"
 	"golang.org/x/text/language"
 )
 
@@ -111,6 +112,27 @@ import (
 //
 //	token=mF_9.B5f-4.1JqM&token_type_hint=access_token
 func (f *Fosite) NewIntrospectionRequest(ctx context.Context, r *http.Request, session Session) (IntrospectionResponder, error) {
+	ctx = f.withStoragePolicy(ctx)
+	ctx = ensureRequestID(ctx, r)
+	ctx, span := f.startSpan(ctx, "fosite.NewIntrospectionRequest")
+	response, err := f.newIntrospectionRequest(ctx, r, session)
+	err = attachRequestID(ctx, err)
+	if err == nil || errors.Is(err, ErrInactiveToken) {
+		f.GetMetricsReporter().TokenIntrospected(ctx, err == nil)
+	} else {
+		f.reportRequestFailed(ctx, IntrospectionEndpoint, err)
+	}
+
+	if err == nil {
+		span.SetAttributes(AttributeActive.Bool(response.IsActive()))
+		endSpan(span, response.GetAccessRequester(), nil)
+	} else {
+		endSpan(span, nil, err)
+	}
+	return response, err
+}
+
+func (f *Fosite) newIntrospectionRequest(ctx context.Context, r *http.Request, session Session) (IntrospectionResponder, error) {
 	ctx = context.WithValue(ctx, RequestContextKey, r)
 
 	if r.Method != "POST" {
@@ -124,16 +146,21 @@ func (f *Fosite) NewIntrospectionRequest(ctx context.Context, r *http.Request, s
 	token := r.PostForm.Get("token")
 	tokenTypeHint := r.PostForm.Get("token_type_hint")
 	scope := r.PostForm.Get("scope")
+
+	var client Client
 	if clientToken := AccessTokenFromRequest(r); clientToken != "" {
 		if token == clientToken {
 			return &IntrospectionResponse{Active: false}, errorsx.WithStack(ErrRequestUnauthorized.WithHint("Bearer and introspection token are identical."))
 		}
 
-		if tu, _, err := f.IntrospectToken(ctx, clientToken, AccessToken, session.Clone()); err != nil {
+		// The introspection request is the protected resource request of the bearer token.
+		tu, car, err := f.IntrospectToken(context.WithValue(ctx, ResourceRequestContextKey, r), clientToken, AccessToken, session.Clone())
+		if err != nil {
 			return &IntrospectionResponse{Active: false}, errorsx.WithStack(ErrRequestUnauthorized.WithHint("HTTP Authorization header missing, malformed, or credentials used are invalid."))
 		} else if tu != "" && tu != AccessToken {
 			return &IntrospectionResponse{Active: false}, errorsx.WithStack(ErrRequestUnauthorized.WithHintf("HTTP Authorization header did not provide a token of type 'access_token', got type '%s'.", tu))
 		}
+		client = car.GetClient()
 	} else {
 		id, secret, ok := r.BasicAuth()
 		if !ok {
@@ -150,32 +177,57 @@ func (f *Fosite) NewIntrospectionRequest(ctx context.Context, r *http.Request, s
 			return &IntrospectionResponse{Active: false}, errorsx.WithStack(ErrRequestUnauthorized.WithHint("Unable to decode OAuth 2.0 Client Secret from HTTP basic authorization header, make sure it is properly encoded.").WithWrap(err).WithDebug(err.Error()))
 		}
 
-		client, err := f.Store.GetClient(ctx, clientID)
+		client, err = f.getClient(ctx, clientID)
 		if err != nil {
-			return &IntrospectionResponse{Active: false}, errorsx.WithStack(ErrRequestUnauthorized.WithHint("Unable to find OAuth 2.0 Client from HTTP basic authorization header.").WithWrap(err).WithDebug(err.Error()))
+			err = errorsx.WithStack(ErrRequestUnauthorized.WithHint("Unable to find OAuth 2.0 Client from HTTP basic authorization header.").WithWrap(err).WithDebug(err.Error()))
+			f.GetEventListener().OnClientAuthenticationFailed(ctx, r, err)
+			return &IntrospectionResponse{Active: false}, err
 		}
 
 		// Enforce client authentication
-		if err := f.checkClientSecret(ctx, client, []byte(clientSecret)); err != nil {
-			return &IntrospectionResponse{Active: false}, errorsx.WithStack(ErrRequestUnauthorized.WithHint("OAuth 2.0 Client credentials are invalid."))
+		if err := checkTokenEndpointAuthMethod(client, "client_secret_basic"); err != nil {
+			err = errorsx.WithStack(ErrRequestUnauthorized.WithHint("The OAuth 2.0 Client did not register client authentication method 'client_secret_basic'.").WithWrap(err).WithDebug(err.Error()))
+			f.GetEventListener().OnClientAuthenticationFailed(ctx, r, err)
+			return &IntrospectionResponse{Active: false}, err
+		} else if err := CheckAuthenticationAllowed(ctx, f.FailedAuthenticationRecorder, ClientAuthentication, client.GetID()); err != nil {
+			f.GetEventListener().OnClientAuthenticationFailed(ctx, r, err)
+			return &IntrospectionResponse{Active: false}, err
+		} else if err := f.checkClientSecret(ctx, client, []byte(clientSecret)); err != nil {
+			err = RecordFailedAuthentication(ctx, f.FailedAuthenticationRecorder, ClientAuthentication, client.GetID(), errorsx.WithStack(ErrRequestUnauthorized.WithHint("OAuth 2.0 Client credentials are invalid.")))
+			f.GetEventListener().OnClientAuthenticationFailed(ctx, r, err)
+			return &IntrospectionResponse{Active: false}, err
 		}
+		RecordSuccessfulAuthentication(ctx, f.FailedAuthenticationRecorder, ClientAuthentication, client.GetID())
 	}
 
-	tu, ar, err := f.IntrospectToken(ctx, token, TokenUse(tokenTypeHint), session, RemoveEmpty(strings.Split(scope, " "))...)
+	tu, ar, err := f.IntrospectToken(context.WithValue(ctx, skipTokenBindingContextKey{}, true), token, TokenUse(tokenTypeHint), session, RemoveEmpty(strings.Split(scope, " "))...)
 	if err != nil {
 		return &IntrospectionResponse{Active: false}, errorsx.WithStack(ErrInactiveToken.WithHint("An introspection strategy indicated that the token is inactive.").WithWrap(err).WithDebug(err.Error()))
 	}
+
+	if f.IntrospectionPolicy != nil && !f.IntrospectionPolicy.CanIntrospect(ctx, client, ar) {
+		return &IntrospectionResponse{Active: false}, errorsx.WithStack(ErrInactiveToken.WithHint("The client is not allowed to introspect the token."))
+	}
 	accessTokenType := ""
 
 	if tu == AccessToken {
 		accessTokenType = BearerAccessToken
 	}
 
+	issuer, err := GetIssuer(ctx, f.IssuerProvider, "")
+	if err != nil {
+		return &IntrospectionResponse{Active: false}, err
+	}
+
 	return &IntrospectionResponse{
 		Active:          true,
 		AccessRequester: ar,
 		TokenUse:        tu,
 		AccessTokenType: accessTokenType,
+		Client:          client,
+		JWTResponse:     acceptsIntrospectionJWT(r),
+		PhantomToken:    acceptsPhantomToken(r),
+		Issuer:          issuer,
 	}, nil
 }
 
@@ -185,6 +237,18 @@ type IntrospectionResponse struct {
 	TokenUse        TokenUse        `json:"token_use,omitempty"`
 	AccessTokenType string          `json:"token_type,omitempty"`
 	Lang            language.Tag    `json:"-"`
+
+	// Client is the client which performed the introspection request.
+	Client Client `json:"-"`
+
+	// JWTResponse is true if the client asked for a JWT introspection response (https://tools.ietf.org/html/rfc9701).
+	JWTResponse bool `json:"-"`
+
+	// PhantomToken is true if the client asked to exchange the introspected access token for the JWT it references.
+	PhantomToken bool `json:"-"`
+
+	// Issuer is the issuer of the authorization server which performed the introspection, if known.
+	Issuer string `json:"-"`
 }
 
 func (r *IntrospectionResponse) IsActive() bool {
@@ -202,3 +266,19 @@ func (r *IntrospectionResponse) GetTokenUse() TokenUse {
 func (r *IntrospectionResponse) GetAccessTokenType() string {
 	return r.AccessTokenType
 }
+
+func (r *IntrospectionResponse) IsJWTResponseRequested() bool {
+	return r.JWTResponse
+}
+
+func (r *IntrospectionResponse) GetClient() Client {
+	return r.Client
+}
+
+func (r *IntrospectionResponse) IsPhantomTokenRequested() bool {
+	return r.PhantomToken
+}
+
+func (r *IntrospectionResponse) GetIssuer() string {
+	return r.Issuer
+}
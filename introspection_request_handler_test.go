@@ -221,6 +221,26 @@ func TestNewIntrospectionRequest(t *testing.T) {
 			},
 			isActive: true,
 		},
+		{
+			description: "should fail with basic auth if the client registered another authentication method",
+			setup: func() {
+				f.Store.(*storage.MemoryStore).Clients["private-key-jwt-client"] = &DefaultOpenIDConnectClient{
+					DefaultClient:           &DefaultClient{ID: "private-key-jwt-client"},
+					TokenEndpointAuthMethod: "private_key_jwt",
+				}
+				httpreq = &http.Request{
+					Method: "POST",
+					Header: http.Header{
+						//Basic Authorization with username=private-key-jwt-client and password=foobar
+						"Authorization": []string{"Basic cHJpdmF0ZS1rZXktand0LWNsaWVudDpmb29iYXI="},
+					},
+					PostForm: url.Values{
+						"token": []string{"introspect-token"},
+					},
+				}
+			},
+			expectErr: ErrRequestUnauthorized,
+		},
 	} {
 		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
 			c.setup()
@@ -235,3 +255,86 @@ func TestNewIntrospectionRequest(t *testing.T) {
 		})
 	}
 }
+
+type introspectionClient struct {
+	*DefaultClient
+	audience Arguments
+}
+
+func (c *introspectionClient) GetIntrospectionAudience() Arguments {
+	return c.audience
+}
+
+func TestAudienceRestrictedIntrospectionPolicy(t *testing.T) {
+	newRequester := func(clientID string, audience ...string) AccessRequester {
+		ar := NewAccessRequest(&DefaultSession{})
+		ar.Client = &DefaultClient{ID: clientID}
+		for _, a := range audience {
+			ar.GrantAudience(a)
+		}
+		return ar
+	}
+
+	for k, c := range []struct {
+		caller    Client
+		requester AccessRequester
+		expect    bool
+	}{
+		{caller: &DefaultClient{ID: "rs"}, requester: newRequester("app", "rs"), expect: true},
+		{caller: &DefaultClient{ID: "rs"}, requester: newRequester("app", "other-rs"), expect: false},
+		{caller: &DefaultClient{ID: "app"}, requester: newRequester("app"), expect: true},
+		{caller: &introspectionClient{DefaultClient: &DefaultClient{ID: "rs"}, audience: Arguments{"https://api"}}, requester: newRequester("app", "https://api"), expect: true},
+		{caller: &introspectionClient{DefaultClient: &DefaultClient{ID: "rs"}, audience: Arguments{"https://api"}}, requester: newRequester("app", "rs"), expect: false},
+		{caller: nil, requester: newRequester("app", "rs"), expect: false},
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			assert.Equal(t, c.expect, AudienceRestrictedIntrospectionPolicy.CanIntrospect(context.Background(), c.caller, c.requester))
+		})
+	}
+}
+
+func TestNewIntrospectionRequestWithIntrospectionPolicy(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	validator := internal.NewMockTokenIntrospector(ctrl)
+	defer ctrl.Finish()
+
+	f := compose.ComposeAllEnabled(&compose.Config{IntrospectionPolicy: AudienceRestrictedIntrospectionPolicy}, storage.NewExampleStore(), []byte{}, nil).(*Fosite)
+	f.TokenIntrospectionHandlers = TokenIntrospectionHandlers{validator}
+
+	for k, c := range []struct {
+		audience  string
+		expectErr error
+	}{
+		{audience: "my-client"},
+		{audience: "another-client", expectErr: ErrInactiveToken},
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			validator.EXPECT().IntrospectToken(gomock.Any(), "introspect-token", gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+				func(_ context.Context, _ string, _ TokenUse, ar AccessRequester, _ []string) (TokenUse, error) {
+					ar.(*AccessRequest).Client = &DefaultClient{ID: "app"}
+					ar.(*AccessRequest).GrantAudience(c.audience)
+					return AccessToken, nil
+				})
+
+			httpreq := &http.Request{
+				Method: "POST",
+				Header: http.Header{
+					//Basic Authorization with username=my-client and password=foobar
+					"Authorization": []string{"Basic bXktY2xpZW50OmZvb2Jhcg=="},
+				},
+				PostForm: url.Values{
+					"token": []string{"introspect-token"},
+				},
+			}
+			res, err := f.NewIntrospectionRequest(context.TODO(), httpreq, &DefaultSession{})
+			if c.expectErr != nil {
+				require.Error(t, err)
+				assert.True(t, errors.Is(err, c.expectErr))
+				assert.False(t, res.IsActive())
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, res.IsActive())
+		})
+	}
+}
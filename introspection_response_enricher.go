@@ -0,0 +1,41 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import "context"
+
+// IntrospectionResponseEnricher adds custom claims, for example a tenant, permissions or a confirmation ("cnf")
+// claim, to the response of an active introspection, see https://tools.ietf.org/html/rfc7662#section-2.2. It is
+// applied to JSON and JWT introspection responses alike.
+type IntrospectionResponseEnricher interface {
+	// EnrichIntrospectionResponse adds claims to response based on the validated introspection responder. The
+	// "active" claim can not be changed. If an error is returned, it is written using WriteIntrospectionError
+	// instead, which reports the token as inactive unless the error is ErrInvalidRequest or ErrRequestUnauthorized.
+	EnrichIntrospectionResponse(ctx context.Context, responder IntrospectionResponder, response map[string]interface{}) error
+}
+
+// IntrospectionResponseEnricherFunc is a function implementing IntrospectionResponseEnricher.
+type IntrospectionResponseEnricherFunc func(ctx context.Context, responder IntrospectionResponder, response map[string]interface{}) error
+
+func (f IntrospectionResponseEnricherFunc) EnrichIntrospectionResponse(ctx context.Context, responder IntrospectionResponder, response map[string]interface{}) error {
+	return f(ctx, responder, response)
+}
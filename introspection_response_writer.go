@@ -22,6 +22,7 @@
 package fosite
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"strings"
@@ -57,7 +58,7 @@ func (f *Fosite) WriteIntrospectionError(rw http.ResponseWriter, err error) {
 
 	// Inactive token errors should never written out as an error.
 	if !errors.Is(err, ErrInactiveToken) && (errors.Is(err, ErrInvalidRequest) || errors.Is(err, ErrRequestUnauthorized)) {
-		f.writeJsonError(rw, nil, err)
+		f.writeError(rw, IntrospectionEndpoint, nil, err)
 		return
 	}
 
@@ -196,14 +197,40 @@ func (f *Fosite) WriteIntrospectionError(rw http.ResponseWriter, err error) {
 //	 {
 //	   "active": false
 //	 }
+//
+// If the client which performed the introspection request asked for a JWT introspection response and an
+// IntrospectionJWTStrategy is set, the response is returned as a signed JWT as defined in
+// https://tools.ietf.org/html/rfc9701
+//
+// If the client asked for an "application/jwt" response and the introspected access token is an opaque reference
+// to a JWT (see PhantomTokenSession), the JWT itself is returned instead.
 func (f *Fosite) WriteIntrospectionResponse(rw http.ResponseWriter, r IntrospectionResponder) {
+	jr, isJWT := r.(JWTIntrospectionResponder)
+	isJWT = isJWT && f.IntrospectionJWTStrategy != nil && jr.IsJWTResponseRequested()
+
+	var issuer string
+	if ir, ok := r.(IssuerIntrospectionResponder); ok {
+		issuer = ir.GetIssuer()
+	}
+
 	if !r.IsActive() {
+		if isJWT {
+			f.writeIntrospectionJWT(rw, jr.GetClient(), issuer, map[string]interface{}{"active": false})
+			return
+		}
 		_ = json.NewEncoder(rw).Encode(&struct {
 			Active bool `json:"active"`
 		}{Active: false})
 		return
 	}
 
+	if pr, ok := r.(PhantomTokenIntrospectionResponder); ok && pr.IsPhantomTokenRequested() && r.GetTokenUse() == AccessToken {
+		if session, ok := r.GetAccessRequester().GetSession().(PhantomTokenSession); ok && session.GetPhantomToken() != "" {
+			writePhantomToken(rw, session.GetPhantomToken())
+			return
+		}
+	}
+
 	response := map[string]interface{}{
 		"active": true,
 	}
@@ -224,6 +251,9 @@ func (f *Fosite) WriteIntrospectionResponse(rw http.ResponseWriter, r Introspect
 		}
 	}
 
+	if _, ok := response["iss"]; !ok && issuer != "" {
+		response["iss"] = issuer
+	}
 	if !r.GetAccessRequester().GetSession().GetExpiresAt(AccessToken).IsZero() {
 		response["exp"] = r.GetAccessRequester().GetSession().GetExpiresAt(AccessToken).Unix()
 	}
@@ -237,14 +267,44 @@ func (f *Fosite) WriteIntrospectionResponse(rw http.ResponseWriter, r Introspect
 		response["iat"] = r.GetAccessRequester().GetRequestedAt().Unix()
 	}
 	if r.GetAccessRequester().GetSession().GetSubject() != "" {
-		response["sub"] = r.GetAccessRequester().GetSession().GetSubject()
+		subject, err := f.SubjectIdentifierAlgorithms.Obfuscate(context.Background(), r.GetAccessRequester().GetSession().GetSubject(), r.GetAccessRequester().GetClient())
+		if err != nil {
+			f.WriteIntrospectionError(rw, err)
+			return
+		}
+		response["sub"] = subject
 	}
 	if len(r.GetAccessRequester().GetGrantedAudience()) > 0 {
 		response["aud"] = r.GetAccessRequester().GetGrantedAudience()
 	}
+	if len(r.GetAccessRequester().GetAuthorizationDetails()) > 0 {
+		response["authorization_details"] = r.GetAccessRequester().GetAuthorizationDetails()
+	}
 	if r.GetAccessRequester().GetSession().GetUsername() != "" {
 		response["username"] = r.GetAccessRequester().GetSession().GetUsername()
 	}
+	if session, ok := r.GetAccessRequester().GetSession().(AuthenticationContextSession); ok {
+		// https://tools.ietf.org/html/rfc9470#section-6.2
+		if acr := session.GetAuthenticationContextClassReference(); acr != "" {
+			response["acr"] = acr
+		}
+		if authTime := session.GetAuthTime(); !authTime.IsZero() {
+			response["auth_time"] = authTime.Unix()
+		}
+	}
+
+	if f.IntrospectionResponseEnricher != nil {
+		if err := f.IntrospectionResponseEnricher.EnrichIntrospectionResponse(context.Background(), r, response); err != nil {
+			f.WriteIntrospectionError(rw, err)
+			return
+		}
+		response["active"] = true
+	}
+
+	if isJWT {
+		f.writeIntrospectionJWT(rw, jr.GetClient(), issuer, response)
+		return
+	}
 
 	rw.Header().Set("Content-Type", "application/json;charset=UTF-8")
 	rw.Header().Set("Cache-Control", "no-store")
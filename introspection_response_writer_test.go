@@ -22,7 +22,9 @@
 package fosite_test
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -186,3 +188,57 @@ func TestWriteIntrospectionResponseBody(t *testing.T) {
 		})
 	}
 }
+
+func TestWriteIntrospectionResponseWithEnricher(t *testing.T) {
+	sess := &DefaultSession{Subject: "peter"}
+	ires := &IntrospectionResponse{
+		Active:          true,
+		TokenUse:        AccessToken,
+		AccessRequester: NewAccessRequest(sess),
+	}
+
+	for k, c := range []struct {
+		enricher   IntrospectionResponseEnricherFunc
+		expectCode int
+		expect     map[string]interface{}
+	}{
+		{
+			enricher: func(_ context.Context, r IntrospectionResponder, response map[string]interface{}) error {
+				assert.Equal(t, ires, r)
+				response["tenant"] = "acme"
+				response["active"] = false
+				return nil
+			},
+			expectCode: http.StatusOK,
+			expect:     map[string]interface{}{"active": true, "sub": "peter", "tenant": "acme"},
+		},
+		{
+			enricher: func(context.Context, IntrospectionResponder, map[string]interface{}) error {
+				return errors.New("unable to look up tenant")
+			},
+			expectCode: http.StatusOK,
+			expect:     map[string]interface{}{"active": false},
+		},
+		{
+			enricher: func(context.Context, IntrospectionResponder, map[string]interface{}) error {
+				return errorsx.WithStack(ErrRequestUnauthorized)
+			},
+			expectCode: http.StatusUnauthorized,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			f := &Fosite{IntrospectionResponseEnricher: c.enricher}
+			rw := httptest.NewRecorder()
+			f.WriteIntrospectionResponse(rw, ires)
+			assert.Equal(t, c.expectCode, rw.Code)
+			if c.expect == nil {
+				return
+			}
+
+			var params map[string]interface{}
+			require.NoError(t, json.NewDecoder(rw.Body).Decode(&params))
+			delete(params, "iat")
+			assert.Equal(t, c.expect, params)
+		})
+	}
+}
@@ -0,0 +1,148 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/ory/x/errorsx"
+)
+
+// IssuerProvider returns the issuer identifier of the authorization server. Multi-tenant deployments can derive it
+// from the request, see HostIssuerProvider, instead of maintaining one configuration per tenant.
+type IssuerProvider interface {
+	// GetIssuer returns the issuer for the request in the context.
+	GetIssuer(ctx context.Context) (string, error)
+}
+
+// StaticIssuerProvider is an IssuerProvider which always returns the same issuer.
+type StaticIssuerProvider string
+
+func (p StaticIssuerProvider) GetIssuer(_ context.Context) (string, error) {
+	return string(p), nil
+}
+
+// HostIssuerProvider derives the issuer from the host of the HTTP request stored in the context under
+// RequestContextKey or ResourceRequestContextKey, for example "https://tenant.example.com/oauth2". Contexts passed
+// to methods such as NewAccessResponse or WriteAuthorizeResponse must carry the request, or the issuer must be
+// stored under IssuerContextKey.
+type HostIssuerProvider struct {
+	// Scheme of the issuer, defaults to "https".
+	Scheme string
+
+	// Path is appended to the host, for example "/oauth2".
+	Path string
+
+	// AllowedHosts are the hosts issuers are derived for. Requests for other hosts are rejected, because the host
+	// is chosen by the client.
+	AllowedHosts []string
+}
+
+func (p *HostIssuerProvider) GetIssuer(ctx context.Context) (string, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	r, ok := ctx.Value(RequestContextKey).(*http.Request)
+	if !ok {
+		r, ok = ctx.Value(ResourceRequestContextKey).(*http.Request)
+	}
+	if !ok {
+		return "", errorsx.WithStack(ErrServerError.WithHint("Unable to derive the issuer because the context does not contain the HTTP request."))
+	}
+
+	host := ""
+	for _, allowed := range p.AllowedHosts {
+		if strings.EqualFold(allowed, r.Host) {
+			host = allowed
+			break
+		}
+	}
+	if host == "" {
+		return "", errorsx.WithStack(ErrInvalidRequest.WithHintf("The host '%s' is not served by this authorization server.", r.Host))
+	}
+
+	scheme := p.Scheme
+	if scheme == "" {
+		scheme = "https"
+	}
+	return scheme + "://" + host + p.Path, nil
+}
+
+// IssuerIntrospectionResponder is implemented by introspection responses which know the issuer of the authorization
+// server, which is returned in the "iss" claim.
+type IssuerIntrospectionResponder interface {
+	IntrospectionResponder
+
+	// GetIssuer returns the issuer or an empty string.
+	GetIssuer() string
+}
+
+// IssuerAuthorizeRequester is implemented by authorize requests which know the issuer the authorization response is
+// sent from, which is returned in the "iss" parameter, see https://tools.ietf.org/html/rfc9207
+type IssuerAuthorizeRequester interface {
+	AuthorizeRequester
+
+	// GetIssuer returns the issuer or an empty string.
+	GetIssuer() string
+}
+
+// GetIssuer returns the issuer stored in the context under IssuerContextKey, the issuer returned by provider if it is
+// not nil, and issuer otherwise. Strategies call it with their static Issuer configuration.
+func GetIssuer(ctx context.Context, provider IssuerProvider, issuer string) (string, error) {
+	if ctx != nil {
+		if fromContext, ok := ctx.Value(IssuerContextKey).(string); ok && fromContext != "" {
+			return fromContext, nil
+		}
+	}
+
+	if provider == nil {
+		return issuer, nil
+	}
+	return provider.GetIssuer(ctx)
+}
+
+// setAuthorizeRequestIssuer resolves the issuer of the authorization response while the request is available in the
+// context, so that HostIssuerProvider can be used.
+func (f *Fosite) setAuthorizeRequestIssuer(ctx context.Context, request *AuthorizeRequest) error {
+	issuer, err := GetIssuer(ctx, f.IssuerProvider, "")
+	if err != nil && f.RequireAuthorizationResponseIssuer {
+		return errorsx.WithStack(ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	} else if issuer == "" && f.RequireAuthorizationResponseIssuer {
+		return errorsx.WithStack(ErrMisconfiguration.WithDebug("An IssuerProvider is required to add the iss parameter to authorization responses."))
+	}
+
+	request.Issuer = issuer
+	return nil
+}
+
+// authorizeResponseIssuer returns the value of the "iss" parameter of the authorization response or an empty string.
+func (f *Fosite) authorizeResponseIssuer(ar AuthorizeRequester) string {
+	if f.OmitAuthorizationResponseIssuer {
+		return ""
+	} else if r, ok := ar.(IssuerAuthorizeRequester); ok {
+		return r.GetIssuer()
+	}
+	return ""
+}
@@ -0,0 +1,172 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/storage"
+)
+
+func TestGetIssuer(t *testing.T) {
+	provider := &HostIssuerProvider{Path: "/oauth2", AllowedHosts: []string{"tenant-a.example.com", "tenant-b.example.com"}}
+	withHost := func(host string) context.Context {
+		r := httptest.NewRequest("POST", "/oauth2/token", nil)
+		r.Host = host
+		return context.WithValue(context.Background(), RequestContextKey, r)
+	}
+
+	for _, c := range []struct {
+		d        string
+		ctx      context.Context
+		provider IssuerProvider
+		expect   string
+		err      error
+	}{
+		{d: "should use the static issuer without provider", ctx: context.Background(), expect: "https://static.example.com"},
+		{d: "should use the static issuer provider", ctx: context.Background(), provider: StaticIssuerProvider("https://provider.example.com"), expect: "https://provider.example.com"},
+		{d: "should derive the issuer from the host", ctx: withHost("tenant-a.example.com"), provider: provider, expect: "https://tenant-a.example.com/oauth2"},
+		{d: "should match hosts case-insensitively", ctx: withHost("Tenant-B.example.com"), provider: provider, expect: "https://tenant-b.example.com/oauth2"},
+		{d: "should reject unknown hosts", ctx: withHost("attacker.example.com"), provider: provider, err: ErrInvalidRequest},
+		{d: "should fail without a request", ctx: context.Background(), provider: provider, err: ErrServerError},
+		{d: "should prefer the issuer of the context", ctx: context.WithValue(withHost("attacker.example.com"), IssuerContextKey, "https://context.example.com"), provider: provider, expect: "https://context.example.com"},
+	} {
+		t.Run("case="+c.d, func(t *testing.T) {
+			issuer, err := GetIssuer(c.ctx, c.provider, "https://static.example.com")
+			if c.err != nil {
+				require.ErrorIs(t, err, c.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.expect, issuer)
+		})
+	}
+}
+
+func TestIssuerProviderIntrospectionAndDiscovery(t *testing.T) {
+	f := &Fosite{IssuerProvider: StaticIssuerProvider("https://auth.example.com")}
+
+	t.Run("case=discovery publishes the issuer", func(t *testing.T) {
+		assert.Equal(t, "https://auth.example.com", f.NewDiscoveryMetadata(context.Background(), DiscoveryConfiguration{}).Issuer)
+		assert.Equal(t, "https://configured.example.com", f.NewDiscoveryMetadata(context.Background(), DiscoveryConfiguration{Issuer: "https://configured.example.com"}).Issuer)
+	})
+
+	t.Run("case=introspection responses carry the issuer", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		f.WriteIntrospectionResponse(rw, &IntrospectionResponse{
+			Active:          true,
+			AccessRequester: NewAccessRequest(new(DefaultSession)),
+			Issuer:          "https://auth.example.com",
+		})
+
+		var params map[string]interface{}
+		require.NoError(t, json.NewDecoder(rw.Body).Decode(&params))
+		assert.Equal(t, "https://auth.example.com", params["iss"])
+	})
+}
+
+func TestAuthorizationResponseIssuer(t *testing.T) {
+	store := storage.NewExampleStore()
+	config := &compose.Config{IssuerProvider: &HostIssuerProvider{AllowedHosts: []string{"tenant.example.com"}}}
+	f := compose.Compose(
+		config,
+		store,
+		compose.NewOAuth2HMACStrategy(config, []byte("some-secret-thats-random-some-secret-thats-random-"), nil),
+		nil,
+		compose.OAuth2AuthorizeExplicitFactory,
+	).(*Fosite)
+	ctx := context.Background()
+
+	authorize := func(t *testing.T, state string) (AuthorizeRequester, error) {
+		r := httptest.NewRequest("GET", "/auth?"+url.Values{
+			"client_id":     {"my-client"},
+			"response_type": {"code"},
+			"redirect_uri":  {"http://localhost:3846/callback"},
+			"state":         {state},
+		}.Encode(), nil)
+		r.Host = "tenant.example.com"
+		return f.NewAuthorizeRequest(ctx, r)
+	}
+
+	location := func(t *testing.T, rw *httptest.ResponseRecorder) url.Values {
+		u, err := url.Parse(rw.Header().Get("Location"))
+		require.NoError(t, err)
+		return u.Query()
+	}
+
+	t.Run("case=should add the issuer to authorization responses", func(t *testing.T) {
+		ar, err := authorize(t, "some-random-state")
+		require.NoError(t, err)
+		resp, err := f.NewAuthorizeResponse(ctx, ar, new(DefaultSession))
+		require.NoError(t, err)
+
+		rw := httptest.NewRecorder()
+		f.WriteAuthorizeResponse(rw, ar, resp)
+		assert.Equal(t, "https://tenant.example.com", location(t, rw).Get("iss"))
+		assert.NotEmpty(t, location(t, rw).Get("code"))
+	})
+
+	t.Run("case=should add the issuer to authorization error responses", func(t *testing.T) {
+		ar, err := authorize(t, "short")
+		require.Error(t, err)
+
+		rw := httptest.NewRecorder()
+		f.WriteAuthorizeError(rw, ar, err)
+		assert.Equal(t, "https://tenant.example.com", location(t, rw).Get("iss"))
+		assert.Equal(t, "invalid_state", location(t, rw).Get("error"))
+	})
+
+	t.Run("case=should omit the issuer", func(t *testing.T) {
+		f.OmitAuthorizationResponseIssuer = true
+		defer func() { f.OmitAuthorizationResponseIssuer = false }()
+
+		ar, err := authorize(t, "some-random-state")
+		require.NoError(t, err)
+		resp, err := f.NewAuthorizeResponse(ctx, ar, new(DefaultSession))
+		require.NoError(t, err)
+
+		rw := httptest.NewRecorder()
+		f.WriteAuthorizeResponse(rw, ar, resp)
+		assert.NotContains(t, location(t, rw), "iss")
+		assert.False(t, f.NewDiscoveryMetadata(ctx, DiscoveryConfiguration{}).AuthorizationResponseIssParameterSupported)
+	})
+
+	t.Run("case=should require the issuer", func(t *testing.T) {
+		f.RequireAuthorizationResponseIssuer = true
+		defer func() { f.RequireAuthorizationResponseIssuer = false }()
+		assert.True(t, f.NewDiscoveryMetadata(ctx, DiscoveryConfiguration{}).AuthorizationResponseIssParameterSupported)
+
+		f.IssuerProvider = nil
+		defer func() { f.IssuerProvider = config.IssuerProvider }()
+		_, err := authorize(t, "some-random-state")
+		require.ErrorIs(t, err, ErrMisconfiguration)
+	})
+}
@@ -0,0 +1,248 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ory/x/errorsx"
+	"gopkg.in/square/go-jose.v2"
+
+	"github.com/ory/fosite/token/jwt"
+)
+
+// Response modes defined by JWT Secured Authorization Response Mode for OAuth 2.0 (JARM), see
+// https://openid.net/specs/oauth-v2-jarm.html#section-2.3
+const (
+	ResponseModeJWT         = ResponseModeType("jwt")
+	ResponseModeQueryJWT    = ResponseModeType("query.jwt")
+	ResponseModeFragmentJWT = ResponseModeType("fragment.jwt")
+	ResponseModeFormPostJWT = ResponseModeType("form_post.jwt")
+)
+
+// JARMResponseLifespan is the default lifetime of JWT secured authorization responses. The specification
+// recommends a short lifetime, as the response is consumed right away.
+const JARMResponseLifespan = time.Minute * 10
+
+// JARMStrategy creates JWT secured authorization responses.
+type JARMStrategy interface {
+	// GenerateJARMResponse returns the signed, and if required by the client encrypted, JWT carrying the
+	// authorization response parameters.
+	GenerateJARMResponse(ctx context.Context, client Client, parameters url.Values) (string, error)
+}
+
+// JARMClient represents a client which registered how JWT secured authorization responses are signed and
+// encrypted, see https://openid.net/specs/oauth-v2-jarm.html#section-3
+type JARMClient interface {
+	// GetAuthorizationSignedResponseAlg returns the JWS alg used for signing authorization responses.
+	GetAuthorizationSignedResponseAlg() string
+
+	// GetAuthorizationEncryptedResponseAlg returns the JWE alg used for encrypting authorization responses. The
+	// responses are not encrypted if empty.
+	GetAuthorizationEncryptedResponseAlg() string
+
+	// GetAuthorizationEncryptedResponseEnc returns the JWE enc used for encrypting authorization responses.
+	GetAuthorizationEncryptedResponseEnc() string
+}
+
+// DefaultJARMStrategy signs responses using the signer registered for the algorithm requested by the client and
+// encrypts them using the client's JSON Web Keys, if the client requested encryption.
+type DefaultJARMStrategy struct {
+	// Signers maps JWS algorithms (e.g. "RS256") to the strategy used for signing responses with that algorithm.
+	Signers map[string]jwt.JWTStrategy
+
+	// DefaultSigningAlgorithm is used for clients which did not register a signing algorithm. Defaults to RS256.
+	DefaultSigningAlgorithm string
+
+	// Issuer is the value of the "iss" claim.
+	Issuer string
+
+	// IssuerProvider, if set, takes precedence over Issuer.
+	IssuerProvider IssuerProvider
+
+	// ResponseLifespan sets the lifetime of responses. Defaults to fosite.JARMResponseLifespan.
+	ResponseLifespan time.Duration
+
+	// JWKSFetcherStrategy resolves the keys of clients with a jwks_uri for encrypting responses.
+	JWKSFetcherStrategy JWKSFetcherStrategy
+
+	// Encrypter encrypts responses for clients which requested encryption. Defaults to jwt.DefaultEncrypter.
+	Encrypter jwt.Encrypter
+}
+
+func (s *DefaultJARMStrategy) GenerateJARMResponse(ctx context.Context, client Client, parameters url.Values) (string, error) {
+	alg := s.DefaultSigningAlgorithm
+	if alg == "" {
+		alg = string(jose.RS256)
+	}
+
+	jc, ok := client.(JARMClient)
+	if ok && jc.GetAuthorizationSignedResponseAlg() != "" {
+		alg = jc.GetAuthorizationSignedResponseAlg()
+	}
+
+	signer, found := s.Signers[alg]
+	if !found {
+		return "", errorsx.WithStack(ErrServerError.WithHintf("The authorization response can not be signed using algorithm '%s'.", alg))
+	}
+
+	lifespan := s.ResponseLifespan
+	if lifespan == 0 {
+		lifespan = JARMResponseLifespan
+	}
+
+	issuer, err := GetIssuer(ctx, s.IssuerProvider, s.Issuer)
+	if err != nil {
+		return "", err
+	}
+
+	claims := jwt.MapClaims{
+		"iss": issuer,
+		"aud": client.GetID(),
+		"exp": time.Now().UTC().Add(lifespan).Unix(),
+	}
+	for k := range parameters {
+		claims[k] = parameters.Get(k)
+	}
+
+	token, _, err := signer.Generate(ctx, claims, &jwt.Headers{})
+	if err != nil {
+		return "", errorsx.WithStack(ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	if !ok || jc.GetAuthorizationEncryptedResponseAlg() == "" {
+		return token, nil
+	}
+
+	return s.encrypt(ctx, client, jc, token)
+}
+
+func (s *DefaultJARMStrategy) encrypt(ctx context.Context, client Client, jc JARMClient, token string) (string, error) {
+	alg := jc.GetAuthorizationEncryptedResponseAlg()
+	set, err := clientEncryptionKeys(client, s.JWKSFetcherStrategy)
+	if err != nil {
+		return "", err
+	}
+
+	key, err := jwt.FindEncryptionKey(set, alg)
+	if err != nil {
+		return "", errorsx.WithStack(ErrServerError.WithHintf("The OAuth 2.0 Client has no JSON Web Key registered which is suitable for encrypting authorization responses using algorithm '%s'.", alg).WithWrap(err).WithDebug(err.Error()))
+	}
+
+	encrypter := s.Encrypter
+	if encrypter == nil {
+		encrypter = new(jwt.DefaultEncrypter)
+	}
+
+	encrypted, err := encrypter.Encrypt(ctx, token, key, alg, jc.GetAuthorizationEncryptedResponseEnc())
+	if err != nil {
+		return "", errorsx.WithStack(ErrServerError.WithHint("Unable to encrypt the authorization response.").WithWrap(err).WithDebug(err.Error()))
+	}
+	return encrypted, nil
+}
+
+// clientEncryptionKeys returns the JSON Web Keys registered by the client, either by value or by reference, for
+// encrypting responses sent to the client.
+func clientEncryptionKeys(client Client, fetcher JWKSFetcherStrategy) (*jose.JSONWebKeySet, error) {
+	oidcClient, ok := client.(OpenIDConnectClient)
+	if !ok {
+		return nil, errorsx.WithStack(ErrServerError.WithHint("The OAuth 2.0 Client requested encrypted responses but has no JSON Web Keys registered."))
+	}
+
+	if set := oidcClient.GetJSONWebKeys(); set != nil {
+		return set, nil
+	} else if location := oidcClient.GetJSONWebKeysURI(); location != "" {
+		if fetcher == nil {
+			return nil, errorsx.WithStack(ErrMisconfiguration.WithDebug("A JWKSFetcherStrategy is required to encrypt responses for clients with a jwks_uri."))
+		}
+		return fetcher.Resolve(location, false)
+	}
+	return nil, nil
+}
+
+// IsJARMResponseMode returns true if the response mode delivers the authorization response as a JWT.
+func IsJARMResponseMode(rm ResponseModeType) bool {
+	switch rm {
+	case ResponseModeJWT, ResponseModeQueryJWT, ResponseModeFragmentJWT, ResponseModeFormPostJWT:
+		return true
+	}
+	return false
+}
+
+// isJARMResponseEncrypted returns true if the client requested encrypted authorization responses.
+func isJARMResponseEncrypted(client Client) bool {
+	jc, ok := client.(JARMClient)
+	return ok && jc.GetAuthorizationEncryptedResponseAlg() != ""
+}
+
+// jarmResponseMode resolves the generic "jwt" response mode to the response mode matching the response type.
+func jarmResponseMode(ar AuthorizeRequester) ResponseModeType {
+	if ar.GetResponseMode() != ResponseModeJWT {
+		return ar.GetResponseMode()
+	} else if ar.GetDefaultResponseMode() == ResponseModeFragment {
+		return ResponseModeFragmentJWT
+	}
+	return ResponseModeQueryJWT
+}
+
+// writeJARMResponse delivers the parameters as a JWT in the "response" parameter, as defined in
+// https://openid.net/specs/oauth-v2-jarm.html#section-2.3
+func (f *Fosite) writeJARMResponse(rw http.ResponseWriter, ar AuthorizeRequester, parameters url.Values) {
+	if f.JARMStrategy == nil {
+		f.writeError(rw, AuthorizeEndpoint, ar, errorsx.WithStack(ErrMisconfiguration.WithDebug("A JARMStrategy is required for JWT secured authorization responses.")))
+		return
+	}
+
+	// The "iss" claim of the response is the issuer the authorize request was sent to, see
+	// https://tools.ietf.org/html/rfc9207#section-2.2
+	ctx := context.Background()
+	if r, ok := ar.(IssuerAuthorizeRequester); ok && r.GetIssuer() != "" {
+		ctx = context.WithValue(ctx, IssuerContextKey, r.GetIssuer())
+	}
+
+	token, err := f.JARMStrategy.GenerateJARMResponse(ctx, ar.GetClient(), parameters)
+	if err != nil {
+		f.writeError(rw, AuthorizeEndpoint, ar, err)
+		return
+	}
+
+	response := url.Values{"response": {token}}
+	redir := ar.GetRedirectURI()
+	switch jarmResponseMode(ar) {
+	case ResponseModeFormPostJWT:
+		rw.Header().Add("Content-Type", "text/html;charset=UTF-8")
+		WriteAuthorizeFormPostResponse(redir.String(), response, GetPostFormHTMLTemplate(*f), rw)
+	case ResponseModeFragmentJWT:
+		// The endpoint URI MUST NOT include a fragment component.
+		redir.Fragment = ""
+		URLSetFragment(redir, response)
+		sendRedirect(redir.String(), rw)
+	default:
+		q := redir.Query()
+		q.Set("response", token)
+		redir.RawQuery = q.Encode()
+		sendRedirect(redir.String(), rw)
+	}
+}
@@ -0,0 +1,171 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+	josejwt "gopkg.in/square/go-jose.v2/jwt"
+
+	. "github.com/ory/fosite"
+	"github.com/ory/fosite/internal"
+	"github.com/ory/fosite/token/jwt"
+)
+
+func TestJARM(t *testing.T) {
+	serverKey := internal.MustRSAKey()
+	clientKey := internal.MustRSAKey()
+
+	f := &Fosite{JARMStrategy: &DefaultJARMStrategy{
+		Signers: map[string]jwt.JWTStrategy{"RS256": &jwt.RS256JWTStrategy{PrivateKey: serverKey}},
+		Issuer:  "https://auth.example.com",
+	}}
+
+	newRequest := func(client Client, mode ResponseModeType, defaultMode ResponseModeType) *AuthorizeRequest {
+		ar := NewAuthorizeRequest()
+		ar.Client = client
+		ar.RedirectURI, _ = url.Parse("https://client.example.com/cb?foo=bar")
+		ar.ResponseMode = mode
+		ar.DefaultResponseMode = defaultMode
+		ar.State = "some-state"
+		return ar
+	}
+
+	parse := func(t *testing.T, token string) map[string]interface{} {
+		parsed, err := josejwt.ParseSigned(token)
+		require.NoError(t, err)
+		claims := map[string]interface{}{}
+		require.NoError(t, parsed.Claims(&serverKey.PublicKey, &claims))
+		return claims
+	}
+
+	write := func(ar AuthorizeRequester) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		f.WriteAuthorizeResponse(rw, ar, &AuthorizeResponse{
+			Header:     http.Header{},
+			Parameters: url.Values{"code": {"some-code"}, "state": {"some-state"}},
+		})
+		return rw
+	}
+
+	client := &DefaultOpenIDConnectClient{DefaultClient: &DefaultClient{ID: "foo", RedirectURIs: []string{"https://client.example.com/cb?foo=bar"}}}
+
+	t.Run("case=query.jwt", func(t *testing.T) {
+		rw := write(newRequest(client, ResponseModeQueryJWT, ResponseModeQuery))
+		require.Equal(t, http.StatusSeeOther, rw.Code)
+
+		location, err := url.Parse(rw.Header().Get("Location"))
+		require.NoError(t, err)
+		assert.Equal(t, "bar", location.Query().Get("foo"))
+		assert.Empty(t, location.Query().Get("code"))
+
+		claims := parse(t, location.Query().Get("response"))
+		assert.Equal(t, "https://auth.example.com", claims["iss"])
+		assert.Equal(t, "foo", claims["aud"])
+		assert.Equal(t, "some-code", claims["code"])
+		assert.Equal(t, "some-state", claims["state"])
+		assert.NotEmpty(t, claims["exp"])
+	})
+
+	t.Run("case=jwt resolves to fragment.jwt for implicit responses", func(t *testing.T) {
+		rw := write(newRequest(client, ResponseModeJWT, ResponseModeFragment))
+		location, err := url.Parse(rw.Header().Get("Location"))
+		require.NoError(t, err)
+
+		fragment, err := url.ParseQuery(location.Fragment)
+		require.NoError(t, err)
+		assert.Equal(t, "some-code", parse(t, fragment.Get("response"))["code"])
+	})
+
+	t.Run("case=form_post.jwt", func(t *testing.T) {
+		rw := write(newRequest(client, ResponseModeFormPostJWT, ResponseModeQuery))
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Contains(t, rw.Header().Get("Content-Type"), "text/html")
+		assert.Contains(t, rw.Body.String(), `name="response"`)
+	})
+
+	t.Run("case=writes errors as JWT", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		f.WriteAuthorizeError(rw, newRequest(client, ResponseModeQueryJWT, ResponseModeQuery), ErrAccessDenied)
+		location, err := url.Parse(rw.Header().Get("Location"))
+		require.NoError(t, err)
+
+		claims := parse(t, location.Query().Get("response"))
+		assert.Equal(t, "access_denied", claims["error"])
+		assert.Equal(t, "some-state", claims["state"])
+	})
+
+	t.Run("case=fails with unsupported signing algorithm", func(t *testing.T) {
+		c := &DefaultOpenIDConnectClient{DefaultClient: &DefaultClient{ID: "foo"}, AuthorizationSignedResponseAlg: "ES256"}
+		rw := write(newRequest(c, ResponseModeQueryJWT, ResponseModeQuery))
+		assert.Equal(t, http.StatusInternalServerError, rw.Code)
+		assert.Empty(t, rw.Header().Get("Location"))
+	})
+
+	t.Run("case=encrypts the response", func(t *testing.T) {
+		c := &DefaultOpenIDConnectClient{
+			DefaultClient:                     &DefaultClient{ID: "foo"},
+			AuthorizationEncryptedResponseAlg: string(jose.RSA_OAEP_256),
+			JSONWebKeys: &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+				{Key: &clientKey.PublicKey, KeyID: "sig", Use: "sig"},
+				{Key: &clientKey.PublicKey, KeyID: "enc", Use: "enc"},
+			}},
+		}
+		rw := write(newRequest(c, ResponseModeQueryJWT, ResponseModeQuery))
+		location, err := url.Parse(rw.Header().Get("Location"))
+		require.NoError(t, err)
+
+		encrypted, err := jose.ParseEncrypted(location.Query().Get("response"))
+		require.NoError(t, err)
+		assert.Equal(t, "enc", encrypted.Header.KeyID)
+		assert.Equal(t, "JWT", encrypted.Header.ExtraHeaders[jose.HeaderContentType])
+
+		signed, err := encrypted.Decrypt(clientKey)
+		require.NoError(t, err)
+		assert.Equal(t, "some-code", parse(t, string(signed))["code"])
+	})
+
+	t.Run("case=rejects JARM response modes if not enabled", func(t *testing.T) {
+		r, err := http.NewRequest("GET", "https://auth.example.com/auth?response_mode=query.jwt", nil)
+		require.NoError(t, err)
+		require.NoError(t, r.ParseForm())
+
+		err = (&Fosite{}).ParseResponseMode(r, NewAuthorizeRequest())
+		assert.EqualError(t, err, ErrUnsupportedResponseMode.Error())
+		require.NoError(t, f.ParseResponseMode(r, NewAuthorizeRequest()))
+	})
+
+	t.Run("case=rejects query.jwt for implicit responses without encryption", func(t *testing.T) {
+		ar := newRequest(client, ResponseModeQueryJWT, ResponseModeFragment)
+		ar.ResponseTypes = Arguments{"token"}
+		ar.SetResponseTypeHandled("token")
+		_, err := f.NewAuthorizeResponse(context.Background(), ar, new(DefaultSession))
+		assert.EqualError(t, err, ErrUnsupportedResponseMode.Error())
+	})
+}
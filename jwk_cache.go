@@ -0,0 +1,130 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+// DefaultJSONWebKeyCacheTTL is the time for which MemoryJSONWebKeyCache considers key sets fresh by default.
+const DefaultJSONWebKeyCacheTTL = time.Minute * 5
+
+// JSONWebKeyCache caches public keys and JSON Web Key Sets which are used to verify JWT assertions, such as
+// private_key_jwt client assertions and RFC7523 authorization grants.
+type JSONWebKeyCache interface {
+	// Resolve returns the key set cached under key. If the key set is missing or expired, fetch is called and
+	// its result is cached. Errors returned by fetch are not cached.
+	Resolve(ctx context.Context, key string, fetch func(ctx context.Context) (*jose.JSONWebKeySet, error)) (*jose.JSONWebKeySet, error)
+
+	// Invalidate removes the key set cached under key, for example because none of its keys verified an
+	// assertion and the keys might have been rotated.
+	Invalidate(ctx context.Context, key string)
+}
+
+// MemoryJSONWebKeyCache is a thread safe, in-memory JSONWebKeyCache. Key sets are fresh for TTL. Within
+// StaleWhileRevalidate after expiry, the stale key set is returned while it is refreshed in the background.
+type MemoryJSONWebKeyCache struct {
+	TTL                  time.Duration
+	StaleWhileRevalidate time.Duration
+
+	sync.Mutex
+	entries map[string]*jsonWebKeyCacheEntry
+}
+
+type jsonWebKeyCacheEntry struct {
+	set        *jose.JSONWebKeySet
+	expiresAt  time.Time
+	refreshing bool
+}
+
+// NewMemoryJSONWebKeyCache returns a MemoryJSONWebKeyCache with the given TTL and stale-while-revalidate window.
+func NewMemoryJSONWebKeyCache(ttl, staleWhileRevalidate time.Duration) *MemoryJSONWebKeyCache {
+	return &MemoryJSONWebKeyCache{
+		TTL:                  ttl,
+		StaleWhileRevalidate: staleWhileRevalidate,
+		entries:              make(map[string]*jsonWebKeyCacheEntry),
+	}
+}
+
+func (c *MemoryJSONWebKeyCache) Resolve(ctx context.Context, key string, fetch func(ctx context.Context) (*jose.JSONWebKeySet, error)) (*jose.JSONWebKeySet, error) {
+	c.Lock()
+	now := time.Now().UTC()
+	if e, ok := c.entries[key]; ok {
+		if now.Before(e.expiresAt) {
+			c.Unlock()
+			return e.set, nil
+		} else if now.Before(e.expiresAt.Add(c.StaleWhileRevalidate)) {
+			if !e.refreshing {
+				e.refreshing = true
+				go c.revalidate(key, e, fetch)
+			}
+			c.Unlock()
+			return e.set, nil
+		}
+	}
+	c.Unlock()
+
+	set, err := fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.store(key, set)
+	return set, nil
+}
+
+func (c *MemoryJSONWebKeyCache) Invalidate(ctx context.Context, key string) {
+	c.Lock()
+	defer c.Unlock()
+
+	delete(c.entries, key)
+}
+
+func (c *MemoryJSONWebKeyCache) revalidate(key string, e *jsonWebKeyCacheEntry, fetch func(ctx context.Context) (*jose.JSONWebKeySet, error)) {
+	set, err := fetch(context.Background())
+	if err != nil {
+		c.Lock()
+		e.refreshing = false
+		c.Unlock()
+		return
+	}
+
+	c.store(key, set)
+}
+
+func (c *MemoryJSONWebKeyCache) store(key string, set *jose.JSONWebKeySet) {
+	c.Lock()
+	defer c.Unlock()
+
+	ttl := c.TTL
+	if ttl == 0 {
+		ttl = DefaultJSONWebKeyCacheTTL
+	}
+	if c.entries == nil {
+		c.entries = make(map[string]*jsonWebKeyCacheEntry)
+	}
+	c.entries[key] = &jsonWebKeyCacheEntry{set: set, expiresAt: time.Now().UTC().Add(ttl)}
+}
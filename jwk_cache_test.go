@@ -0,0 +1,108 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	jose "gopkg.in/square/go-jose.v2"
+
+	. "github.com/ory/fosite"
+)
+
+func TestMemoryJSONWebKeyCache(t *testing.T) {
+	ctx := context.Background()
+	var fetched int32
+	fetch := func(ctx context.Context) (*jose.JSONWebKeySet, error) {
+		n := atomic.AddInt32(&fetched, 1)
+		return &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{KeyID: string(rune('a' + n - 1))}}}, nil
+	}
+
+	t.Run("case=fresh key sets are served from the cache", func(t *testing.T) {
+		atomic.StoreInt32(&fetched, 0)
+		c := NewMemoryJSONWebKeyCache(time.Minute, 0)
+
+		for i := 0; i < 3; i++ {
+			set, err := c.Resolve(ctx, "foo", fetch)
+			require.NoError(t, err)
+			assert.Equal(t, "a", set.Keys[0].KeyID)
+		}
+		assert.EqualValues(t, 1, atomic.LoadInt32(&fetched))
+
+		c.Invalidate(ctx, "foo")
+		set, err := c.Resolve(ctx, "foo", fetch)
+		require.NoError(t, err)
+		assert.Equal(t, "b", set.Keys[0].KeyID)
+	})
+
+	t.Run("case=expired key sets are fetched again", func(t *testing.T) {
+		atomic.StoreInt32(&fetched, 0)
+		c := NewMemoryJSONWebKeyCache(time.Millisecond*10, 0)
+
+		_, err := c.Resolve(ctx, "foo", fetch)
+		require.NoError(t, err)
+		time.Sleep(time.Millisecond * 20)
+
+		set, err := c.Resolve(ctx, "foo", fetch)
+		require.NoError(t, err)
+		assert.Equal(t, "b", set.Keys[0].KeyID)
+	})
+
+	t.Run("case=stale key sets are served while they are revalidated", func(t *testing.T) {
+		atomic.StoreInt32(&fetched, 0)
+		c := NewMemoryJSONWebKeyCache(time.Millisecond*10, time.Minute)
+
+		_, err := c.Resolve(ctx, "foo", fetch)
+		require.NoError(t, err)
+		time.Sleep(time.Millisecond * 20)
+
+		set, err := c.Resolve(ctx, "foo", fetch)
+		require.NoError(t, err)
+		assert.Equal(t, "a", set.Keys[0].KeyID)
+
+		assert.Eventually(t, func() bool {
+			set, err := c.Resolve(ctx, "foo", fetch)
+			return err == nil && set.Keys[0].KeyID == "b"
+		}, time.Second, time.Millisecond*5)
+		assert.EqualValues(t, 2, atomic.LoadInt32(&fetched))
+	})
+
+	t.Run("case=errors are not cached", func(t *testing.T) {
+		c := NewMemoryJSONWebKeyCache(time.Minute, 0)
+
+		_, err := c.Resolve(ctx, "foo", func(ctx context.Context) (*jose.JSONWebKeySet, error) {
+			return nil, errors.New("storage is down")
+		})
+		require.Error(t, err)
+
+		atomic.StoreInt32(&fetched, 0)
+		set, err := c.Resolve(ctx, "foo", fetch)
+		require.NoError(t, err)
+		assert.Equal(t, "a", set.Keys[0].KeyID)
+	})
+}
@@ -0,0 +1,134 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"golang.org/x/text/language"
+
+	"github.com/ory/fosite/token/jwt"
+)
+
+// LogoutRequester is a RP-initiated logout request as defined in
+// https://openid.net/specs/openid-connect-rpinitiated-1_0.html#RPLogout
+type LogoutRequester interface {
+	// GetClient returns the client which initiated the logout. It is nil if the request contained neither an
+	// id_token_hint nor a client_id.
+	GetClient() Client
+
+	// GetIDTokenHintClaims returns the claims of the verified id_token_hint or nil if none was sent.
+	GetIDTokenHintClaims() jwt.MapClaims
+
+	// GetSubject returns the subject of the id_token_hint.
+	GetSubject() string
+
+	// GetSessionID returns the "sid" claim of the id_token_hint.
+	GetSessionID() string
+
+	// GetPostLogoutRedirectURI returns the validated post_logout_redirect_uri or nil if none was sent.
+	GetPostLogoutRedirectURI() *url.URL
+
+	// GetState returns the state which is passed back to the post logout redirect URI.
+	GetState() string
+
+	// GetRequestForm returns the raw request parameters, including logout_hint and ui_locales.
+	GetRequestForm() url.Values
+
+	// GetLang returns the language of the request.
+	GetLang() language.Tag
+}
+
+// LogoutRequest is an implementation of LogoutRequester.
+type LogoutRequest struct {
+	Client                Client        `json:"client"`
+	IDTokenHintClaims     jwt.MapClaims `json:"id_token_hint_claims"`
+	Subject               string        `json:"subject"`
+	SessionID             string        `json:"session_id"`
+	PostLogoutRedirectURI *url.URL      `json:"post_logout_redirect_uri"`
+	State                 string        `json:"state"`
+	Form                  url.Values    `json:"form"`
+	Lang                  language.Tag  `json:"-"`
+}
+
+func NewLogoutRequest() *LogoutRequest {
+	return &LogoutRequest{
+		Form: url.Values{},
+	}
+}
+
+func (l *LogoutRequest) GetClient() Client {
+	return l.Client
+}
+
+func (l *LogoutRequest) GetIDTokenHintClaims() jwt.MapClaims {
+	return l.IDTokenHintClaims
+}
+
+func (l *LogoutRequest) GetSubject() string {
+	return l.Subject
+}
+
+func (l *LogoutRequest) GetSessionID() string {
+	return l.SessionID
+}
+
+func (l *LogoutRequest) GetPostLogoutRedirectURI() *url.URL {
+	return l.PostLogoutRedirectURI
+}
+
+func (l *LogoutRequest) GetState() string {
+	return l.State
+}
+
+func (l *LogoutRequest) GetRequestForm() url.Values {
+	return l.Form
+}
+
+func (l *LogoutRequest) GetLang() language.Tag {
+	return l.Lang
+}
+
+// LogoutSession records the clients which took part in an end-user's session at the OpenID Provider, identified by
+// the "sid" claim, so that they can be sent logout tokens once the session ends.
+type LogoutSession struct {
+	ID        string    `json:"id"`
+	Subject   string    `json:"subject"`
+	ClientIDs []string  `json:"client_ids"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// LogoutStorage keeps track of the sessions logout tokens are sent for, see
+// https://openid.net/specs/openid-connect-backchannel-1_0.html
+type LogoutStorage interface {
+	// AddLogoutSessionClient adds the client to the session with the given ID, creating the session if it does
+	// not exist yet.
+	AddLogoutSessionClient(ctx context.Context, sessionID, subject, clientID string) error
+
+	// GetLogoutSession returns the session with the given ID or ErrNotFound.
+	GetLogoutSession(ctx context.Context, sessionID string) (*LogoutSession, error)
+
+	// DeleteLogoutSession removes the session with the given ID.
+	DeleteLogoutSession(ctx context.Context, sessionID string) error
+}
@@ -0,0 +1,173 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/ory/x/errorsx"
+	"github.com/pkg/errors"
+
+	"github.com/ory/fosite/i18n"
+	"github.com/ory/fosite/token/jwt"
+)
+
+// NewLogoutRequest validates a RP-initiated logout request as defined in
+// https://openid.net/specs/openid-connect-rpinitiated-1_0.html#RPLogout
+//
+// The request may be sent using GET or POST. The id_token_hint, if given, must have been issued by this OpenID
+// Provider and is verified using IDTokenHintStrategy. Expired ID tokens are accepted. The post_logout_redirect_uri
+// must exactly match one of the client's registered post logout redirect URIs, which requires the client to be
+// identified using the id_token_hint or client_id.
+//
+// Ending the end-user's session and notifying other clients is up to the application.
+func (f *Fosite) NewLogoutRequest(ctx context.Context, r *http.Request) (LogoutRequester, error) {
+	ctx = f.withStoragePolicy(ctx)
+	ctx = ensureRequestID(ctx, r)
+	ctx, span := f.startSpan(ctx, "fosite.NewLogoutRequest")
+	request, err := f.newLogoutRequest(ctx, r)
+	err = attachRequestID(ctx, err)
+	f.reportRequestFailed(ctx, LogoutEndpoint, err)
+	endSpan(span, nil, err)
+	return request, err
+}
+
+func (f *Fosite) newLogoutRequest(ctx context.Context, r *http.Request) (*LogoutRequest, error) {
+	request := NewLogoutRequest()
+	request.Lang = i18n.GetLangFromRequest(f.MessageCatalog, r)
+
+	ctx = context.WithValue(ctx, RequestContextKey, r)
+
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		return request, errorsx.WithStack(ErrInvalidRequest.WithHintf("HTTP method is '%s', expected 'GET' or 'POST'.", r.Method))
+	} else if err := r.ParseForm(); err != nil {
+		return request, errorsx.WithStack(ErrInvalidRequest.WithHint("Unable to parse HTTP body, make sure to send a properly formatted form request body.").WithWrap(err).WithDebug(err.Error()))
+	}
+	request.Form = r.Form
+	request.Lang = i18n.GetLangFromLocales(f.MessageCatalog, request.Form.Get("ui_locales"), request.Lang)
+	request.State = request.Form.Get("state")
+
+	clientID := request.Form.Get("client_id")
+	if idTokenHint := request.Form.Get("id_token_hint"); idTokenHint != "" {
+		claims, err := f.decodeIDTokenHint(ctx, idTokenHint)
+		if err != nil {
+			return request, err
+		}
+		request.IDTokenHintClaims = claims
+		request.Subject, _ = claims["sub"].(string)
+		request.SessionID, _ = claims["sid"].(string)
+
+		audience := claimAudience(claims)
+		if clientID == "" {
+			if azp, _ := claims["azp"].(string); azp != "" {
+				clientID = azp
+			} else if len(audience) == 1 {
+				clientID = audience[0]
+			}
+		}
+		if clientID != "" && !Arguments(audience).Has(clientID) {
+			return request, errorsx.WithStack(ErrInvalidRequest.WithHintf("The OAuth 2.0 Client '%s' is not an audience of the id_token_hint.", clientID))
+		}
+	}
+
+	if clientID != "" {
+		client, err := f.getClient(ctx, clientID)
+		if err != nil {
+			return request, errorsx.WithStack(ErrInvalidClient.WithHint("The requested OAuth 2.0 Client does not exist.").WithWrap(err).WithDebug(err.Error()))
+		}
+		request.Client = client
+	}
+
+	if rawRedirectURI := request.Form.Get("post_logout_redirect_uri"); rawRedirectURI != "" {
+		redirectURI, err := f.validatePostLogoutRedirectURI(request.Client, rawRedirectURI)
+		if err != nil {
+			return request, err
+		}
+		request.PostLogoutRedirectURI = redirectURI
+	}
+
+	return request, nil
+}
+
+func (f *Fosite) decodeIDTokenHint(ctx context.Context, idTokenHint string) (jwt.MapClaims, error) {
+	if f.IDTokenHintStrategy == nil {
+		return nil, errorsx.WithStack(ErrServerError.WithHint("The id_token_hint can not be verified because no IDTokenHintStrategy is configured."))
+	}
+
+	token, err := f.IDTokenHintStrategy.Decode(ctx, idTokenHint)
+	var ve *jwt.ValidationError
+	if errors.As(err, &ve) && ve.Has(jwt.ValidationErrorExpired) {
+		// Expired tokens are ok
+	} else if err != nil {
+		return nil, errorsx.WithStack(ErrInvalidRequest.WithHint("The id_token_hint could not be verified.").WithWrap(err).WithDebug(err.Error()))
+	}
+
+	if sub, _ := token.Claims["sub"].(string); sub == "" {
+		return nil, errorsx.WithStack(ErrInvalidRequest.WithHint("The id_token_hint does not have a subject."))
+	}
+	return token.Claims, nil
+}
+
+func (f *Fosite) validatePostLogoutRedirectURI(client Client, rawRedirectURI string) (*url.URL, error) {
+	if client == nil {
+		return nil, errorsx.WithStack(ErrInvalidRequest.WithHint("The post_logout_redirect_uri parameter requires either the id_token_hint or the client_id parameter to be set."))
+	}
+
+	var registered []string
+	if c, ok := client.(PostLogoutRedirectURIClient); ok {
+		registered = c.GetPostLogoutRedirectURIs()
+	}
+
+	for _, uri := range registered {
+		if uri != rawRedirectURI {
+			continue
+		}
+
+		redirectURI, err := url.Parse(rawRedirectURI)
+		if err != nil {
+			return nil, errorsx.WithStack(ErrInvalidRequest.WithHint("The post_logout_redirect_uri is malformed.").WithWrap(err).WithDebug(err.Error()))
+		}
+		return redirectURI, nil
+	}
+
+	return nil, errorsx.WithStack(ErrInvalidRequest.WithHintf("The post_logout_redirect_uri '%s' does not match any of the OAuth 2.0 Client's registered post_logout_redirect_uris.", rawRedirectURI))
+}
+
+func claimAudience(claims jwt.MapClaims) []string {
+	switch aud := claims["aud"].(type) {
+	case string:
+		return []string{aud}
+	case []string:
+		return aud
+	case []interface{}:
+		audience := make([]string, 0, len(aud))
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				audience = append(audience, s)
+			}
+		}
+		return audience
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/internal"
+	"github.com/ory/fosite/storage"
+	"github.com/ory/fosite/token/jwt"
+)
+
+func TestNewLogoutRequest(t *testing.T) {
+	key := internal.MustRSAKey()
+	store := storage.NewExampleStore()
+	store.Clients["logout-client"] = &DefaultOpenIDConnectClient{
+		DefaultClient:          &DefaultClient{ID: "logout-client"},
+		PostLogoutRedirectURIs: []string{"https://example.com/logged-out"},
+	}
+	f := compose.ComposeAllEnabled(&compose.Config{}, store, []byte("some-secret-thats-random-some-secret-thats-random-"), key)
+	ctx := context.Background()
+
+	idToken := func(t *testing.T, key interface{}, claims jwt.MapClaims) string {
+		token, _, err := (&jwt.RS256JWTStrategy{PrivateKey: key}).Generate(ctx, claims, &jwt.Headers{})
+		require.NoError(t, err)
+		return token
+	}
+	valid := idToken(t, key, jwt.MapClaims{"sub": "peter", "sid": "session-id", "aud": []string{"logout-client"}, "exp": time.Now().Add(time.Hour).Unix()})
+	expired := idToken(t, key, jwt.MapClaims{"sub": "peter", "aud": []string{"logout-client"}, "exp": time.Now().Add(-time.Hour).Unix()})
+	multipleAudiences := idToken(t, key, jwt.MapClaims{"sub": "peter", "aud": []string{"logout-client", "my-client"}})
+	noSubject := idToken(t, key, jwt.MapClaims{"aud": []string{"logout-client"}})
+	foreign := idToken(t, internal.MustRSAKey(), jwt.MapClaims{"sub": "peter", "aud": []string{"logout-client"}})
+
+	for _, tc := range []struct {
+		d           string
+		method      string
+		form        url.Values
+		expectErr   error
+		expectSub   string
+		expectSID   string
+		expectURI   string
+		expectState string
+	}{
+		{d: "accepts requests without parameters", method: "GET", form: url.Values{}},
+		{d: "rejects unsupported methods", method: "PUT", form: url.Values{}, expectErr: ErrInvalidRequest},
+		{
+			d:      "accepts a valid id_token_hint and post_logout_redirect_uri",
+			method: "GET",
+			form: url.Values{
+				"id_token_hint":            {valid},
+				"post_logout_redirect_uri": {"https://example.com/logged-out"},
+				"state":                    {"some-state"},
+			},
+			expectSub:   "peter",
+			expectSID:   "session-id",
+			expectURI:   "https://example.com/logged-out",
+			expectState: "some-state",
+		},
+		{
+			d:         "accepts expired tokens using POST",
+			method:    "POST",
+			form:      url.Values{"id_token_hint": {expired}},
+			expectSub: "peter",
+		},
+		{
+			d:         "rejects tokens which were not signed by the provider",
+			method:    "GET",
+			form:      url.Values{"id_token_hint": {foreign}},
+			expectErr: ErrInvalidRequest,
+		},
+		{
+			d:         "rejects tokens without a subject",
+			method:    "GET",
+			form:      url.Values{"id_token_hint": {noSubject}},
+			expectErr: ErrInvalidRequest,
+		},
+		{
+			d:         "rejects a client_id which is not an audience of the token",
+			method:    "GET",
+			form:      url.Values{"id_token_hint": {valid}, "client_id": {"my-client"}},
+			expectErr: ErrInvalidRequest,
+		},
+		{
+			d:      "uses the client_id if the token has multiple audiences",
+			method: "GET",
+			form: url.Values{
+				"id_token_hint":            {multipleAudiences},
+				"client_id":                {"logout-client"},
+				"post_logout_redirect_uri": {"https://example.com/logged-out"},
+			},
+			expectSub: "peter",
+			expectURI: "https://example.com/logged-out",
+		},
+		{
+			d:         "rejects post_logout_redirect_uri without a client",
+			method:    "GET",
+			form:      url.Values{"post_logout_redirect_uri": {"https://example.com/logged-out"}},
+			expectErr: ErrInvalidRequest,
+		},
+		{
+			d:         "rejects unregistered post_logout_redirect_uri",
+			method:    "GET",
+			form:      url.Values{"client_id": {"logout-client"}, "post_logout_redirect_uri": {"https://example.com/logged-out/other"}},
+			expectErr: ErrInvalidRequest,
+		},
+		{
+			d:         "rejects post_logout_redirect_uri of clients without registered URIs",
+			method:    "GET",
+			form:      url.Values{"client_id": {"my-client"}, "post_logout_redirect_uri": {"http://localhost:3846/callback"}},
+			expectErr: ErrInvalidRequest,
+		},
+		{
+			d:         "rejects unknown clients",
+			method:    "GET",
+			form:      url.Values{"client_id": {"unknown-client"}},
+			expectErr: ErrInvalidClient,
+		},
+	} {
+		t.Run("case="+tc.d, func(t *testing.T) {
+			var r *http.Request
+			if tc.method == "POST" {
+				r = httptest.NewRequest(tc.method, "/logout", strings.NewReader(tc.form.Encode()))
+				r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			} else {
+				r = httptest.NewRequest(tc.method, "/logout?"+tc.form.Encode(), nil)
+			}
+
+			lr, err := f.NewLogoutRequest(ctx, r)
+			if tc.expectErr != nil {
+				require.Error(t, err)
+				assert.True(t, errors.Is(err, tc.expectErr), "%+v", err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectSub, lr.GetSubject())
+			assert.Equal(t, tc.expectSID, lr.GetSessionID())
+			assert.Equal(t, tc.expectState, lr.GetState())
+			if tc.expectURI == "" {
+				assert.Nil(t, lr.GetPostLogoutRedirectURI())
+			} else {
+				require.NotNil(t, lr.GetPostLogoutRedirectURI())
+				assert.Equal(t, tc.expectURI, lr.GetPostLogoutRedirectURI().String())
+				assert.Equal(t, "logout-client", lr.GetClient().GetID())
+			}
+		})
+	}
+}
+
+func TestWriteLogoutResponse(t *testing.T) {
+	f := &Fosite{}
+
+	rw := httptest.NewRecorder()
+	f.WriteLogoutResponse(rw, &LogoutRequest{})
+	assert.Equal(t, http.StatusNoContent, rw.Code)
+
+	redirectURI, _ := url.Parse("https://example.com/logged-out?foo=bar")
+	rw = httptest.NewRecorder()
+	f.WriteLogoutResponse(rw, &LogoutRequest{PostLogoutRedirectURI: redirectURI, State: "some-state"})
+	assert.Equal(t, http.StatusSeeOther, rw.Code)
+	assert.Equal(t, "https://example.com/logged-out?foo=bar&state=some-state", rw.Header().Get("Location"))
+
+	rw = httptest.NewRecorder()
+	f.WriteLogoutError(rw, &LogoutRequest{PostLogoutRedirectURI: redirectURI}, errors.WithStack(ErrInvalidRequest))
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.Empty(t, rw.Header().Get("Location"))
+}
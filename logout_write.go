@@ -0,0 +1,55 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"net/http"
+)
+
+// WriteLogoutError writes an error response as defined in https://tools.ietf.org/html/rfc6749#section-5.2. Errors
+// are never sent to the post_logout_redirect_uri as it may not have been validated.
+func (f *Fosite) WriteLogoutError(rw http.ResponseWriter, requester LogoutRequester, err error) {
+	f.writeError(rw, LogoutEndpoint, nil, err)
+}
+
+// WriteLogoutResponse redirects the end-user to the post_logout_redirect_uri as defined in
+// https://openid.net/specs/openid-connect-rpinitiated-1_0.html#RedirectionAfterLogout
+// and appends the state if one was sent. If no post_logout_redirect_uri was sent, 204 No Content is returned and the
+// application may render its own logged out page instead.
+func (f *Fosite) WriteLogoutResponse(rw http.ResponseWriter, requester LogoutRequester) {
+	rw.Header().Set("Cache-Control", "no-store")
+	rw.Header().Set("Pragma", "no-cache")
+
+	redirectURI := requester.GetPostLogoutRedirectURI()
+	if redirectURI == nil {
+		rw.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	u := *redirectURI
+	if state := requester.GetState(); state != "" {
+		q := u.Query()
+		q.Set("state", state)
+		u.RawQuery = q.Encode()
+	}
+	sendRedirect(u.String(), rw)
+}
@@ -0,0 +1,84 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"time"
+)
+
+// Endpoints reported to MetricsReporter and ErrorWriter.
+const (
+	AuthorizeEndpoint                 = "authorize"
+	TokenEndpoint                     = "token"
+	IntrospectionEndpoint             = "introspection"
+	RevocationEndpoint                = "revocation"
+	LogoutEndpoint                    = "logout"
+	PushedAuthorizationEndpoint       = "pushed_authorization"
+	DeviceAuthorizationEndpoint       = "device_authorization"
+	BackchannelAuthenticationEndpoint = "backchannel_authentication"
+)
+
+// MetricsReporter receives the metrics of the provider, for example to expose them to Prometheus using
+// github.com/ory/fosite/metrics. Implementations are called synchronously and must be safe for concurrent use.
+type MetricsReporter interface {
+	// TokenIssued is called once for each token response, with the grant type of the request.
+	TokenIssued(ctx context.Context, grantType string)
+
+	// TokenIntrospected is called for each introspection request which was authenticated successfully.
+	TokenIntrospected(ctx context.Context, active bool)
+
+	// RequestFailed is called when a request to one of the endpoints is rejected, with the error code of the
+	// response, for example "invalid_request".
+	RequestFailed(ctx context.Context, endpoint string, errorCode string)
+
+	// StorageOperationCompleted is called after a storage operation, for example "GetClient", with its duration.
+	StorageOperationCompleted(ctx context.Context, operation string, duration time.Duration, err error)
+}
+
+// NoopMetricsReporter is a MetricsReporter which discards all metrics.
+type NoopMetricsReporter struct{}
+
+var _ MetricsReporter = NoopMetricsReporter{}
+
+func (NoopMetricsReporter) TokenIssued(context.Context, string) {}
+
+func (NoopMetricsReporter) TokenIntrospected(context.Context, bool) {}
+
+func (NoopMetricsReporter) RequestFailed(context.Context, string, string) {}
+
+func (NoopMetricsReporter) StorageOperationCompleted(context.Context, string, time.Duration, error) {}
+
+// GetMetricsReporter returns MetricsReporter if set. Defaults to a NoopMetricsReporter.
+func (f *Fosite) GetMetricsReporter() MetricsReporter {
+	if f.MetricsReporter == nil {
+		return NoopMetricsReporter{}
+	}
+	return f.MetricsReporter
+}
+
+// reportRequestFailed reports err, if any, as a failed request to the endpoint.
+func (f *Fosite) reportRequestFailed(ctx context.Context, endpoint string, err error) {
+	if err != nil {
+		f.GetMetricsReporter().RequestFailed(ctx, endpoint, ErrorToRFC6749Error(err).ErrorField)
+	}
+}
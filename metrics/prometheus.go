@@ -0,0 +1,104 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+// Package metrics exposes the metrics of fosite to Prometheus.
+package metrics
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/ory/fosite"
+)
+
+// DefaultNamespace is the default namespace of the metrics.
+const DefaultNamespace = "fosite"
+
+// PrometheusReporter is a fosite.MetricsReporter which records the metrics as Prometheus counters and histograms.
+type PrometheusReporter struct {
+	tokensIssued     *prometheus.CounterVec
+	introspections   *prometheus.CounterVec
+	requestsFailed   *prometheus.CounterVec
+	storageDurations *prometheus.HistogramVec
+}
+
+var _ fosite.MetricsReporter = new(PrometheusReporter)
+
+// NewPrometheusReporter creates the metrics and registers them with registerer, which defaults to
+// prometheus.DefaultRegisterer. The namespace defaults to DefaultNamespace.
+func NewPrometheusReporter(namespace string, registerer prometheus.Registerer) (*PrometheusReporter, error) {
+	if namespace == "" {
+		namespace = DefaultNamespace
+	}
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+
+	r := &PrometheusReporter{
+		tokensIssued: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "tokens_issued_total",
+			Help:      "Number of token responses, partitioned by grant type.",
+		}, []string{"grant_type"}),
+		introspections: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "introspections_total",
+			Help:      "Number of token introspections, partitioned by whether the token was active.",
+		}, []string{"active"}),
+		requestsFailed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "requests_failed_total",
+			Help:      "Number of rejected requests, partitioned by endpoint and error code.",
+		}, []string{"endpoint", "error"}),
+		storageDurations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "storage_operation_duration_seconds",
+			Help:      "Duration of storage operations, partitioned by operation and whether the operation failed.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation", "failed"}),
+	}
+
+	for _, c := range []prometheus.Collector{r.tokensIssued, r.introspections, r.requestsFailed, r.storageDurations} {
+		if err := registerer.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+func (r *PrometheusReporter) TokenIssued(_ context.Context, grantType string) {
+	r.tokensIssued.WithLabelValues(grantType).Inc()
+}
+
+func (r *PrometheusReporter) TokenIntrospected(_ context.Context, active bool) {
+	r.introspections.WithLabelValues(strconv.FormatBool(active)).Inc()
+}
+
+func (r *PrometheusReporter) RequestFailed(_ context.Context, endpoint string, errorCode string) {
+	r.requestsFailed.WithLabelValues(endpoint, errorCode).Inc()
+}
+
+func (r *PrometheusReporter) StorageOperationCompleted(_ context.Context, operation string, duration time.Duration, err error) {
+	r.storageDurations.WithLabelValues(operation, strconv.FormatBool(err != nil)).Observe(duration.Seconds())
+}
@@ -0,0 +1,63 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/metrics"
+)
+
+func TestPrometheusReporter(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	r, err := metrics.NewPrometheusReporter("", registry)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	r.TokenIssued(ctx, "client_credentials")
+	r.TokenIssued(ctx, "client_credentials")
+	r.TokenIntrospected(ctx, false)
+	r.RequestFailed(ctx, fosite.TokenEndpoint, "invalid_client")
+	r.StorageOperationCompleted(ctx, "GetClient", time.Millisecond, nil)
+	r.StorageOperationCompleted(ctx, "GetClient", time.Millisecond, errors.New("connection refused"))
+
+	count, err := testutil.GatherAndCount(registry,
+		"fosite_tokens_issued_total",
+		"fosite_introspections_total",
+		"fosite_requests_failed_total",
+		"fosite_storage_operation_duration_seconds",
+	)
+	require.NoError(t, err)
+	assert.Equal(t, 5, count)
+
+	_, err = metrics.NewPrometheusReporter("", registry)
+	assert.Error(t, err, "registering the metrics twice must fail")
+}
@@ -0,0 +1,101 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/storage"
+)
+
+type recordingMetricsReporter struct {
+	sync.Mutex
+	metrics []string
+}
+
+func (r *recordingMetricsReporter) record(metric string) {
+	r.Lock()
+	defer r.Unlock()
+	r.metrics = append(r.metrics, metric)
+}
+
+func (r *recordingMetricsReporter) TokenIssued(_ context.Context, grantType string) {
+	r.record("issued:" + grantType)
+}
+
+func (r *recordingMetricsReporter) TokenIntrospected(context.Context, bool) {
+	r.record("introspected")
+}
+
+func (r *recordingMetricsReporter) RequestFailed(_ context.Context, endpoint string, errorCode string) {
+	r.record("failed:" + endpoint + ":" + errorCode)
+}
+
+func (r *recordingMetricsReporter) StorageOperationCompleted(_ context.Context, operation string, _ time.Duration, _ error) {
+	r.record("storage:" + operation)
+}
+
+func TestMetricsReporter(t *testing.T) {
+	reporter := new(recordingMetricsReporter)
+	config := &compose.Config{MetricsReporter: reporter}
+	f := compose.Compose(
+		config,
+		storage.NewExampleStore(),
+		compose.NewOAuth2HMACStrategy(config, []byte("some-secret-thats-random-some-secret-thats-random-"), nil),
+		nil,
+		compose.OAuth2ClientCredentialsGrantFactory,
+	)
+
+	newRequest := func(secret string) *http.Request {
+		r := httptest.NewRequest("POST", "/token", strings.NewReader(url.Values{"grant_type": {"client_credentials"}}.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.SetBasicAuth("my-client", secret)
+		return r
+	}
+
+	ar, err := f.NewAccessRequest(context.Background(), newRequest("foobar"), &DefaultSession{})
+	require.NoError(t, err)
+	_, err = f.NewAccessResponse(context.Background(), ar)
+	require.NoError(t, err)
+
+	_, err = f.NewAccessRequest(context.Background(), newRequest("invalid"), &DefaultSession{})
+	require.Error(t, err)
+
+	assert.Equal(t, []string{
+		"storage:GetClient",
+		"issued:client_credentials",
+		"storage:GetClient",
+		"failed:" + TokenEndpoint + ":invalid_client",
+	}, reporter.metrics)
+}
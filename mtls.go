@@ -0,0 +1,217 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	"github.com/ory/x/errorsx"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+const (
+	// TLSClientAuthMethod is the PKI mutual TLS client authentication method, see
+	// https://tools.ietf.org/html/rfc8705#section-2.1
+	TLSClientAuthMethod = "tls_client_auth"
+
+	// SelfSignedTLSClientAuthMethod is the self-signed certificate mutual TLS client authentication method, see
+	// https://tools.ietf.org/html/rfc8705#section-2.2
+	SelfSignedTLSClientAuthMethod = "self_signed_tls_client_auth"
+
+	// CertificateConfirmationMethod is the confirmation method of the "cnf" claim carrying the SHA-256 thumbprint of
+	// the client certificate, see https://tools.ietf.org/html/rfc8705#section-3.1
+	CertificateConfirmationMethod = "x5t#S256"
+)
+
+// clientCertificateFromRequest returns the leaf certificate presented by the client during the TLS handshake.
+func clientCertificateFromRequest(r *http.Request) *x509.Certificate {
+	if r == nil || r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return nil
+	}
+	return r.TLS.PeerCertificates[0]
+}
+
+// CertificateThumbprint returns the base64url encoded SHA-256 thumbprint of the certificate.
+func CertificateThumbprint(cert *x509.Certificate) string {
+	hash := sha256.Sum256(cert.Raw)
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+func (f *Fosite) authenticateClientWithCertificate(ctx context.Context, r *http.Request, client OpenIDConnectClient, method string) error {
+	cert := clientCertificateFromRequest(r)
+	if cert == nil {
+		return errorsx.WithStack(ErrInvalidClient.WithHintf("The OAuth 2.0 Client supports client authentication method '%s', but no TLS client certificate was presented.", method))
+	}
+
+	if method == SelfSignedTLSClientAuthMethod {
+		return f.checkSelfSignedClientCertificate(client, cert)
+	}
+
+	if len(r.TLS.VerifiedChains) == 0 {
+		return errorsx.WithStack(ErrInvalidClient.WithHint("The TLS client certificate could not be validated against a trusted certificate authority."))
+	}
+
+	tlsClient, ok := client.(TLSClientAuthClient)
+	if !ok {
+		return errorsx.WithStack(ErrInvalidRequest.WithHintf("The server configuration does not support client authentication method '%s'.", method))
+	}
+
+	if dn := tlsClient.GetTLSClientAuthSubjectDN(); dn != "" {
+		if !strings.EqualFold(strings.ReplaceAll(dn, ", ", ","), cert.Subject.String()) {
+			return errorsx.WithStack(ErrInvalidClient.WithHint("The subject distinguished name of the TLS client certificate does not match the registered value."))
+		}
+	} else if name := tlsClient.GetTLSClientAuthSANDNS(); name != "" {
+		if !StringInSlice(name, cert.DNSNames) {
+			return errorsx.WithStack(ErrInvalidClient.WithHint("The dNSName SAN entries of the TLS client certificate do not match the registered value."))
+		}
+	} else if uri := tlsClient.GetTLSClientAuthSANURI(); uri != "" {
+		var uris []string
+		for _, u := range cert.URIs {
+			uris = append(uris, u.String())
+		}
+		if !StringInSlice(uri, uris) {
+			return errorsx.WithStack(ErrInvalidClient.WithHint("The uniformResourceIdentifier SAN entries of the TLS client certificate do not match the registered value."))
+		}
+	} else if ip := tlsClient.GetTLSClientAuthSANIP(); ip != "" {
+		var ips []string
+		for _, i := range cert.IPAddresses {
+			ips = append(ips, i.String())
+		}
+		if !StringInSlice(ip, ips) {
+			return errorsx.WithStack(ErrInvalidClient.WithHint("The iPAddress SAN entries of the TLS client certificate do not match the registered value."))
+		}
+	} else if email := tlsClient.GetTLSClientAuthSANEmail(); email != "" {
+		if !StringInSlice(email, cert.EmailAddresses) {
+			return errorsx.WithStack(ErrInvalidClient.WithHint("The rfc822Name SAN entries of the TLS client certificate do not match the registered value."))
+		}
+	} else {
+		return errorsx.WithStack(ErrInvalidClient.WithHintf("The OAuth 2.0 Client supports client authentication method '%s', but has no expected certificate subject registered.", method))
+	}
+
+	return nil
+}
+
+func (f *Fosite) checkSelfSignedClientCertificate(client OpenIDConnectClient, cert *x509.Certificate) error {
+	set := client.GetJSONWebKeys()
+	if set == nil {
+		if location := client.GetJSONWebKeysURI(); len(location) > 0 {
+			keys, err := f.JWKSFetcherStrategy.Resolve(location, false)
+			if err != nil {
+				return err
+			}
+
+			if matchesCertificate(keys, cert) {
+				return nil
+			}
+
+			if keys, err = f.JWKSFetcherStrategy.Resolve(location, true); err != nil {
+				return err
+			}
+			set = keys
+		}
+	}
+
+	if set == nil {
+		return errorsx.WithStack(ErrInvalidClient.WithHint("The OAuth 2.0 Client has no JSON Web Keys set registered, but they are needed to complete the request."))
+	} else if !matchesCertificate(set, cert) {
+		return errorsx.WithStack(ErrInvalidClient.WithHint("The TLS client certificate does not match any of the OAuth 2.0 Client's registered JSON Web Keys."))
+	}
+	return nil
+}
+
+// matchesCertificate returns true if the set contains the certificate, either as the first entry of a key's
+// certificate chain or as the key itself.
+func matchesCertificate(set *jose.JSONWebKeySet, cert *x509.Certificate) bool {
+	for _, key := range set.Keys {
+		if len(key.Certificates) > 0 {
+			if key.Certificates[0].Equal(cert) {
+				return true
+			}
+			continue
+		}
+
+		public := key.Public()
+		if !public.Valid() {
+			continue
+		}
+		if der, err := x509.MarshalPKIXPublicKey(public.Key); err == nil && bytes.Equal(der, cert.RawSubjectPublicKeyInfo) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleCertificateBoundAccessRequest binds the requester's session to the TLS client certificate if
+// TLSClientCertificateBoundAccessTokens is enabled.
+func (f *Fosite) handleCertificateBoundAccessRequest(ctx context.Context, r *http.Request, requester AccessRequester) error {
+	if !f.TLSClientCertificateBoundAccessTokens {
+		return nil
+	}
+
+	bound := GetConfirmation(requester.GetSession(), CertificateConfirmationMethod)
+	cert := clientCertificateFromRequest(r)
+	if cert == nil {
+		if bound != "" {
+			return errorsx.WithStack(ErrInvalidGrant.WithHint("The grant is bound to a TLS client certificate, but no certificate was presented."))
+		}
+		return nil
+	}
+
+	thumbprint := CertificateThumbprint(cert)
+	if bound != "" && bound != thumbprint {
+		return errorsx.WithStack(ErrInvalidGrant.WithHint("The TLS client certificate does not match the certificate the grant is bound to."))
+	}
+
+	if !SetConfirmation(requester.GetSession(), CertificateConfirmationMethod, thumbprint) {
+		return errorsx.WithStack(ErrServerError.WithHint("Unable to bind the access token to the TLS client certificate.").WithDebug("The session does not support extra claims."))
+	}
+	return nil
+}
+
+// verifyCertificateBinding verifies that the TLS client certificate of the protected resource request stored in the
+// context under ResourceRequestContextKey matches the certificate the access token is bound to. Certificate-bound
+// access tokens are rejected if the protected resource request is not available.
+func (f *Fosite) verifyCertificateBinding(ctx context.Context, requester AccessRequester) error {
+	bound := GetConfirmation(requester.GetSession(), CertificateConfirmationMethod)
+	if bound == "" {
+		return nil
+	}
+
+	r, ok := ctx.Value(ResourceRequestContextKey).(*http.Request)
+	if !ok {
+		return errorsx.WithStack(ErrRequestUnauthorized.WithHint("The access token is bound to a TLS client certificate, but the protected resource request is not available to verify the certificate."))
+	}
+
+	cert := clientCertificateFromRequest(r)
+	if cert == nil {
+		return errorsx.WithStack(ErrRequestUnauthorized.WithHint("The access token is bound to a TLS client certificate, but no certificate was presented."))
+	} else if CertificateThumbprint(cert) != bound {
+		return errorsx.WithStack(ErrRequestUnauthorized.WithHint("The TLS client certificate does not match the certificate the access token is bound to."))
+	}
+	return nil
+}
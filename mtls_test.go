@@ -0,0 +1,261 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	jose "gopkg.in/square/go-jose.v2"
+
+	. "github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/storage"
+)
+
+func newClientCertificate(t *testing.T, commonName string, dnsNames ...string) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName, Organization: []string{"ORY"}},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert, key
+}
+
+func withClientCertificate(r *http.Request, cert *x509.Certificate, verified bool) *http.Request {
+	r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+	if verified {
+		r.TLS.VerifiedChains = [][]*x509.Certificate{{cert}}
+	}
+	return r
+}
+
+func TestAuthenticateClientWithCertificate(t *testing.T) {
+	cert, key := newClientCertificate(t, "client", "client.ory.sh")
+	otherCert, _ := newClientCertificate(t, "other")
+
+	newClient := func(id, method string) *DefaultOpenIDConnectClient {
+		return &DefaultOpenIDConnectClient{
+			DefaultClient:           &DefaultClient{ID: id, GrantTypes: []string{"client_credentials"}},
+			TokenEndpointAuthMethod: method,
+		}
+	}
+
+	store := storage.NewMemoryStore()
+	dnClient := newClient("dn", TLSClientAuthMethod)
+	dnClient.TLSClientAuthSubjectDN = "CN=client, O=ORY"
+	store.Clients["dn"] = dnClient
+
+	dnsClient := newClient("dns", TLSClientAuthMethod)
+	dnsClient.TLSClientAuthSANDNS = "client.ory.sh"
+	store.Clients["dns"] = dnsClient
+
+	store.Clients["unconfigured"] = newClient("unconfigured", TLSClientAuthMethod)
+
+	certClient := newClient("self-signed-cert", SelfSignedTLSClientAuthMethod)
+	certClient.JSONWebKeys = &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: &key.PublicKey, Certificates: []*x509.Certificate{cert}}}}
+	store.Clients["self-signed-cert"] = certClient
+
+	keyClient := newClient("self-signed-key", SelfSignedTLSClientAuthMethod)
+	keyClient.JSONWebKeys = &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: &key.PublicKey}}}
+	store.Clients["self-signed-key"] = keyClient
+
+	f := &Fosite{Store: store, JWKSFetcherStrategy: NewDefaultJWKSFetcherStrategy()}
+
+	for _, c := range []struct {
+		d         string
+		clientID  string
+		cert      *x509.Certificate
+		verified  bool
+		expectErr error
+	}{
+		{d: "passes with matching subject dn", clientID: "dn", cert: cert, verified: true},
+		{d: "fails with another subject dn", clientID: "dn", cert: otherCert, verified: true, expectErr: ErrInvalidClient},
+		{d: "fails with unverified certificate", clientID: "dn", cert: cert, expectErr: ErrInvalidClient},
+		{d: "fails without certificate", clientID: "dn", expectErr: ErrInvalidClient},
+		{d: "passes with matching dns name", clientID: "dns", cert: cert, verified: true},
+		{d: "fails with missing dns name", clientID: "dns", cert: otherCert, verified: true, expectErr: ErrInvalidClient},
+		{d: "fails without expected subject", clientID: "unconfigured", cert: cert, verified: true, expectErr: ErrInvalidClient},
+		{d: "passes with self-signed certificate in jwks", clientID: "self-signed-cert", cert: cert},
+		{d: "fails with another self-signed certificate", clientID: "self-signed-cert", cert: otherCert, expectErr: ErrInvalidClient},
+		{d: "passes with self-signed certificate key in jwks", clientID: "self-signed-key", cert: cert},
+		{d: "fails with another self-signed certificate key", clientID: "self-signed-key", cert: otherCert, expectErr: ErrInvalidClient},
+	} {
+		t.Run("case="+c.d, func(t *testing.T) {
+			form := url.Values{"client_id": {c.clientID}}
+			r := httptest.NewRequest("POST", "https://www.ory.sh/token", nil)
+			if c.cert != nil {
+				r = withClientCertificate(r, c.cert, c.verified)
+			}
+
+			client, err := f.AuthenticateClient(context.Background(), r, form)
+			if c.expectErr != nil {
+				require.ErrorIs(t, err, c.expectErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.clientID, client.GetID())
+		})
+	}
+}
+
+func TestCertificateBoundAccessToken(t *testing.T) {
+	cert, _ := newClientCertificate(t, "client")
+	otherCert, _ := newClientCertificate(t, "other")
+
+	f := compose.ComposeAllEnabled(&compose.Config{
+		TLSClientCertificateBoundAccessTokens: true,
+	}, storage.NewExampleStore(), []byte("some-secret-thats-random-some-secret-thats-random-"), nil).(*Fosite)
+
+	r, err := http.NewRequest("POST", "https://www.ory.sh/token", strings.NewReader(url.Values{"grant_type": {"client_credentials"}}.Encode()))
+	require.NoError(t, err)
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.SetBasicAuth("my-client", "foobar")
+	r = withClientCertificate(r, cert, true)
+
+	ar, err := f.NewAccessRequest(context.Background(), r, new(DefaultSession))
+	require.NoError(t, err)
+	resp, err := f.NewAccessResponse(context.Background(), ar)
+	require.NoError(t, err)
+	assert.Equal(t, "bearer", resp.GetTokenType())
+
+	ir := httptest.NewRequest("POST", "https://www.ory.sh/introspect", strings.NewReader(url.Values{"token": {resp.GetAccessToken()}}.Encode()))
+	ir.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	ir.SetBasicAuth("my-client", "foobar")
+	introspected, err := f.NewIntrospectionRequest(context.Background(), ir, new(DefaultSession))
+	require.NoError(t, err)
+	assert.Equal(t, CertificateThumbprint(cert), GetConfirmation(introspected.GetAccessRequester().GetSession(), CertificateConfirmationMethod))
+
+	_, _, err = f.IntrospectToken(context.Background(), resp.GetAccessToken(), AccessToken, new(DefaultSession))
+	require.ErrorIs(t, err, ErrRequestUnauthorized)
+
+	for _, c := range []struct {
+		d         string
+		cert      *x509.Certificate
+		expectErr bool
+	}{
+		{d: "passes with the bound certificate", cert: cert},
+		{d: "fails with another certificate", cert: otherCert, expectErr: true},
+		{d: "fails without certificate", expectErr: true},
+	} {
+		t.Run("case=resource request "+c.d, func(t *testing.T) {
+			rr := httptest.NewRequest("GET", "https://www.ory.sh/api", nil)
+			if c.cert != nil {
+				rr = withClientCertificate(rr, c.cert, true)
+			}
+
+			ctx := context.WithValue(context.Background(), ResourceRequestContextKey, rr)
+			_, _, err := f.IntrospectToken(ctx, resp.GetAccessToken(), AccessToken, new(DefaultSession))
+			if c.expectErr {
+				require.ErrorIs(t, err, ErrRequestUnauthorized)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+// certificateBoundGrantHandler simulates a grant, e.g. a refresh token, that is bound to a TLS client certificate.
+type certificateBoundGrantHandler struct {
+	thumbprint string
+}
+
+func (h *certificateBoundGrantHandler) PopulateTokenEndpointResponse(ctx context.Context, requester AccessRequester, responder AccessResponder) error {
+	return nil
+}
+
+func (h *certificateBoundGrantHandler) HandleTokenEndpointRequest(ctx context.Context, requester AccessRequester) error {
+	SetConfirmation(requester.GetSession(), CertificateConfirmationMethod, h.thumbprint)
+	return nil
+}
+
+func (h *certificateBoundGrantHandler) CanSkipClientAuth(requester AccessRequester) bool {
+	return false
+}
+
+func (h *certificateBoundGrantHandler) CanHandleTokenEndpointRequest(requester AccessRequester) bool {
+	return true
+}
+
+func TestCertificateBoundGrant(t *testing.T) {
+	cert, _ := newClientCertificate(t, "client")
+	otherCert, _ := newClientCertificate(t, "other")
+
+	f := compose.ComposeAllEnabled(&compose.Config{
+		TLSClientCertificateBoundAccessTokens: true,
+	}, storage.NewExampleStore(), []byte("some-secret-thats-random-some-secret-thats-random-"), nil).(*Fosite)
+	f.TokenEndpointHandlers = TokenEndpointHandlers{&certificateBoundGrantHandler{thumbprint: CertificateThumbprint(cert)}}
+
+	for k, c := range []struct {
+		d         string
+		cert      *x509.Certificate
+		expectErr error
+	}{
+		{d: "passes with the bound certificate", cert: cert},
+		{d: "fails with another certificate", cert: otherCert, expectErr: ErrInvalidGrant},
+		{d: "fails without certificate", expectErr: ErrInvalidGrant},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			r := httptest.NewRequest("POST", "https://www.ory.sh/token", strings.NewReader(url.Values{"grant_type": {"refresh_token"}}.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			r.SetBasicAuth("my-client", "foobar")
+			if c.cert != nil {
+				r = withClientCertificate(r, c.cert, true)
+			}
+
+			ar, err := f.NewAccessRequest(context.Background(), r, new(DefaultSession))
+			if c.expectErr != nil {
+				require.ErrorIs(t, err, c.expectErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, CertificateThumbprint(cert), GetConfirmation(ar.GetSession(), CertificateConfirmationMethod))
+		})
+	}
+}
@@ -28,6 +28,8 @@ import (
 	"time"
 
 	"golang.org/x/text/language"
+
+	"github.com/ory/fosite/token/jwt"
 )
 
 type TokenUse = TokenType
@@ -39,6 +41,20 @@ const (
 	RefreshToken  TokenType = "refresh_token"
 	AuthorizeCode TokenType = "authorize_code"
 	IDToken       TokenType = "id_token"
+	DeviceCode    TokenType = "device_code"
+	UserCode      TokenType = "user_code"
+	AuthRequestID TokenType = "auth_req_id"
+
+	// PreAuthorizedCode is the lifespan of a pre-authorized code of an OpenID for Verifiable Credential Issuance
+	// credential offer.
+	PreAuthorizedCode TokenType = "pre-authorized_code"
+
+	// PushedAuthorizeRequestContext is the lifespan of a pushed authorization request's request_uri.
+	PushedAuthorizeRequestContext TokenType = "par_context"
+
+	// RefreshTokenSession is the absolute lifespan of the refresh tokens issued for a grant. Unlike the refresh
+	// token expiry, it is not renewed when the refresh token is exchanged.
+	RefreshTokenSession TokenType = "refresh_token_session"
 
 	BearerAccessToken string = "bearer"
 )
@@ -78,6 +94,10 @@ type OAuth2Provider interface {
 	//	 MUST return an error response as described in Section 4.1.2.1.
 	NewAuthorizeResponse(ctx context.Context, requester AuthorizeRequester, session Session) (AuthorizeResponder, error)
 
+	// IsConsentRequired returns true if the resource owner has to be asked for consent. It always returns false if
+	// no ConsentStrategy is configured. See ConsentStrategy for details.
+	IsConsentRequired(ctx context.Context, requester AuthorizeRequester, session Session) (bool, error)
+
 	// WriteAuthorizeError returns the error codes to the redirection endpoint or shows the error to the user, if no valid
 	// redirect uri was given. Implements rfc6749#section-4.1.2.1
 	//
@@ -162,6 +182,136 @@ type OAuth2Provider interface {
 	// WriteIntrospectionResponse responds with token metadata discovered by token introspection as defined in
 	// https://tools.ietf.org/search/rfc7662#section-2.2
 	WriteIntrospectionResponse(rw http.ResponseWriter, r IntrospectionResponder)
+
+	// NewLogoutRequest validates a RP-initiated logout request, including the id_token_hint and the
+	// post_logout_redirect_uri.
+	//
+	// The following specs must be considered in any implementation of this method:
+	// * https://openid.net/specs/openid-connect-rpinitiated-1_0.html#RPLogout (everything)
+	NewLogoutRequest(ctx context.Context, r *http.Request) (LogoutRequester, error)
+
+	// WriteLogoutError writes a logout error response. The end-user is never redirected in case of an error.
+	WriteLogoutError(rw http.ResponseWriter, requester LogoutRequester, err error)
+
+	// WriteLogoutResponse redirects the end-user to the validated post_logout_redirect_uri, if any, once the
+	// application ended the end-user's session.
+	//
+	// The following specs must be considered in any implementation of this method:
+	// * https://openid.net/specs/openid-connect-rpinitiated-1_0.html#RedirectionAfterLogout
+	WriteLogoutResponse(rw http.ResponseWriter, requester LogoutRequester)
+
+	// NewDeviceAuthorizeRequest creates a new device authorization request and validates the client, the
+	// requested scopes and the requested audience.
+	//
+	// The following specs must be considered in any implementation of this method:
+	// * https://tools.ietf.org/html/rfc8628#section-3.1 (everything)
+	NewDeviceAuthorizeRequest(ctx context.Context, req *http.Request) (DeviceRequester, error)
+
+	// NewDeviceAuthorizeResponse iterates through all device endpoint handlers and returns their result or
+	// ErrUnknownRequest if none of the handlers issued a device and user code.
+	//
+	// The following specs must be considered in any implementation of this method:
+	// * https://tools.ietf.org/html/rfc8628#section-3.2 (everything)
+	NewDeviceAuthorizeResponse(ctx context.Context, requester DeviceRequester, session Session) (DeviceResponder, error)
+
+	// WriteDeviceAuthorizeError writes a device authorization error response.
+	//
+	// The following specs must be considered in any implementation of this method:
+	// * https://tools.ietf.org/html/rfc8628#section-3.2
+	//   In the event of an error, the authorization server returns an error response as
+	//   described in Section 5.2 of [RFC6749].
+	WriteDeviceAuthorizeError(rw http.ResponseWriter, requester DeviceRequester, err error)
+
+	// WriteDeviceAuthorizeResponse writes the device authorization response.
+	//
+	// The following specs must be considered in any implementation of this method:
+	// * https://tools.ietf.org/html/rfc8628#section-3.2
+	WriteDeviceAuthorizeResponse(rw http.ResponseWriter, requester DeviceRequester, responder DeviceResponder)
+
+	// NewPushedAuthorizeRequest validates the request parameters pushed by the client and returns an AuthorizeRequest.
+	//
+	// The following specs must be considered in any implementation of this method:
+	// * https://tools.ietf.org/html/rfc9126#section-2.1
+	//   The client authentication requirements of Section 3.2.1 of [RFC6749] apply to requests on this endpoint and
+	//   the authorization server MUST validate the request in the same way as at the authorization endpoint.
+	NewPushedAuthorizeRequest(ctx context.Context, req *http.Request) (AuthorizeRequester, error)
+
+	// NewPushedAuthorizeResponse iterates through all PushedAuthorizeEndpointHandlers which store the pushed
+	// request and issue the request_uri referencing it.
+	//
+	// The following specs must be considered in any implementation of this method:
+	// * https://tools.ietf.org/html/rfc9126#section-2.2
+	NewPushedAuthorizeResponse(ctx context.Context, requester AuthorizeRequester, session Session) (PushedAuthorizeResponder, error)
+
+	// WritePushedAuthorizeError returns an error response as defined in https://tools.ietf.org/html/rfc9126#section-2.3
+	WritePushedAuthorizeError(rw http.ResponseWriter, requester AuthorizeRequester, err error)
+
+	// WritePushedAuthorizeResponse writes the pushed authorization response.
+	//
+	// The following specs must be considered in any implementation of this method:
+	// * https://tools.ietf.org/html/rfc9126#section-2.2
+	WritePushedAuthorizeResponse(rw http.ResponseWriter, requester AuthorizeRequester, responder PushedAuthorizeResponder)
+
+	// NewBackchannelAuthenticationRequest creates a new backchannel authentication request and validates the client,
+	// the requested scopes and the requested audience.
+	//
+	// The following specs must be considered in any implementation of this method:
+	// * https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#auth_request
+	NewBackchannelAuthenticationRequest(ctx context.Context, req *http.Request) (BackchannelAuthenticationRequester, error)
+
+	// NewBackchannelAuthenticationResponse iterates through all backchannel authentication endpoint handlers and
+	// returns their result or ErrServerError if none of the handlers issued an auth_req_id.
+	//
+	// The following specs must be considered in any implementation of this method:
+	// * https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#auth_ack
+	NewBackchannelAuthenticationResponse(ctx context.Context, requester BackchannelAuthenticationRequester, session Session) (BackchannelAuthenticationResponder, error)
+
+	// WriteBackchannelAuthenticationError writes a backchannel authentication error response as defined in
+	// https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#auth_error_response
+	WriteBackchannelAuthenticationError(rw http.ResponseWriter, requester BackchannelAuthenticationRequester, err error)
+
+	// WriteBackchannelAuthenticationResponse writes the backchannel authentication response.
+	//
+	// The following specs must be considered in any implementation of this method:
+	// * https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#auth_ack
+	WriteBackchannelAuthenticationResponse(rw http.ResponseWriter, requester BackchannelAuthenticationRequester, responder BackchannelAuthenticationResponder)
+
+	// NewDiscoveryMetadata creates the authorization server metadata from the registered handlers and the given
+	// configuration.
+	//
+	// The following specs must be considered in any implementation of this method:
+	// * https://tools.ietf.org/html/rfc8414#section-2
+	// * https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderMetadata
+	NewDiscoveryMetadata(ctx context.Context, config DiscoveryConfiguration) *DiscoveryMetadata
+
+	// WriteDiscoveryResponse writes the authorization server metadata.
+	//
+	// The following specs must be considered in any implementation of this method:
+	// * https://tools.ietf.org/html/rfc8414#section-3.2
+	WriteDiscoveryResponse(ctx context.Context, rw http.ResponseWriter, metadata *DiscoveryMetadata)
+
+	// GetGrant returns the grant with the given ID if it belongs to the client.
+	//
+	// The following specs must be considered in any implementation of this method:
+	// * https://openid.net/specs/fapi-grant-management.html#section-6.3
+	GetGrant(ctx context.Context, client Client, grantID string) (*Grant, error)
+
+	// RevokeGrant revokes the grant with the given ID and the tokens issued for it if the grant belongs to the
+	// client.
+	//
+	// The following specs must be considered in any implementation of this method:
+	// * https://openid.net/specs/fapi-grant-management.html#section-6.4
+	RevokeGrant(ctx context.Context, client Client, grantID string) error
+
+	// WriteGrant writes the grant to the response of the grant management query endpoint.
+	//
+	// The following specs must be considered in any implementation of this method:
+	// * https://openid.net/specs/fapi-grant-management.html#section-6.3
+	WriteGrant(ctx context.Context, rw http.ResponseWriter, grant *Grant)
+
+	// Describe lists the registered handlers, the grant and response types they are responsible for, and detected
+	// gaps and overlaps.
+	Describe() *ProviderDescription
 }
 
 // IntrospectionResponder is the response object that will be returned when token introspection was successful,
@@ -224,6 +374,20 @@ type Requester interface {
 	// GrantAudience marks a request's audience as granted.
 	GrantAudience(audience string)
 
+	// GetRequestedAuthorizationDetails returns the authorization details (https://tools.ietf.org/html/rfc9396)
+	// requested with the authorization_details parameter.
+	GetRequestedAuthorizationDetails() (details AuthorizationDetails)
+
+	// SetRequestedAuthorizationDetails sets the requested authorization details.
+	SetRequestedAuthorizationDetails(details AuthorizationDetails)
+
+	// GetAuthorizationDetails returns the granted authorization details.
+	GetAuthorizationDetails() (details AuthorizationDetails)
+
+	// GrantAuthorizationDetails marks the authorization details as granted. They may be enriched by the
+	// authorization server, see https://tools.ietf.org/html/rfc9396#section-7
+	GrantAuthorizationDetails(details AuthorizationDetails)
+
 	// GetSession returns a pointer to the request's session or nil if none is set.
 	GetSession() (session Session)
 
@@ -279,6 +443,24 @@ type AuthorizeRequester interface {
 	// GetDefaultResponseMode gets default response mode for a response type in a flow
 	GetDefaultResponseMode() ResponseModeType
 
+	// GetRequestObjectClaims returns the verified claims of the request object, if the authorization request was
+	// sent using the request or request_uri parameter.
+	GetRequestObjectClaims() jwt.MapClaims
+
+	// GetRequestedClaims returns the individual claims requested using the claims parameter, or nil if the
+	// parameter was not set.
+	GetRequestedClaims() *ClaimsRequest
+
+	Requester
+}
+
+// DeviceRequester is a device authorization endpoint's request context.
+type DeviceRequester interface {
+	Requester
+}
+
+// BackchannelAuthenticationRequester is a backchannel authentication endpoint's request context.
+type BackchannelAuthenticationRequester interface {
 	Requester
 }
 
@@ -321,6 +503,9 @@ type AuthorizeResponder interface {
 	// AddHeader adds an header key value pair to the response
 	AddHeader(key, value string)
 
+	// AddCookie adds a Set-Cookie header for the given cookie to the response. Invalid cookies are silently dropped.
+	AddCookie(cookie *http.Cookie)
+
 	// GetParameters returns the response's parameters
 	GetParameters() (query url.Values)
 
@@ -328,6 +513,108 @@ type AuthorizeResponder interface {
 	AddParameter(key, value string)
 }
 
+// DeviceResponder is a device authorization endpoint's response.
+type DeviceResponder interface {
+	// GetDeviceCode returns the response's device code.
+	GetDeviceCode() string
+
+	// SetDeviceCode sets the response's device code.
+	SetDeviceCode(code string)
+
+	// GetUserCode returns the response's user code.
+	GetUserCode() string
+
+	// SetUserCode sets the response's user code.
+	SetUserCode(code string)
+
+	// GetVerificationURI returns the end-user verification URI on the authorization server.
+	GetVerificationURI() string
+
+	// SetVerificationURI sets the end-user verification URI on the authorization server.
+	SetVerificationURI(uri string)
+
+	// GetVerificationURIComplete returns the verification URI which includes the user code.
+	GetVerificationURIComplete() string
+
+	// SetVerificationURIComplete sets the verification URI which includes the user code.
+	SetVerificationURIComplete(uri string)
+
+	// GetExpiresIn returns the lifetime of the device code and the user code.
+	GetExpiresIn() time.Duration
+
+	// SetExpiresIn sets the lifetime of the device code and the user code.
+	SetExpiresIn(expiresIn time.Duration)
+
+	// GetInterval returns the minimum amount of time the client should wait between polling requests.
+	GetInterval() time.Duration
+
+	// SetInterval sets the minimum amount of time the client should wait between polling requests.
+	SetInterval(interval time.Duration)
+
+	// GetHeader returns the response's header
+	GetHeader() (header http.Header)
+
+	// AddHeader adds an header key value pair to the response
+	AddHeader(key, value string)
+
+	// ToMap converts the response to a map.
+	ToMap() map[string]interface{}
+}
+
+// BackchannelAuthenticationResponder is a backchannel authentication endpoint's response.
+type BackchannelAuthenticationResponder interface {
+	// GetAuthRequestID returns the identifier of the backchannel authentication request.
+	GetAuthRequestID() string
+
+	// SetAuthRequestID sets the identifier of the backchannel authentication request.
+	SetAuthRequestID(id string)
+
+	// GetExpiresIn returns the lifetime of the auth_req_id.
+	GetExpiresIn() time.Duration
+
+	// SetExpiresIn sets the lifetime of the auth_req_id.
+	SetExpiresIn(expiresIn time.Duration)
+
+	// GetInterval returns the minimum amount of time the client should wait between polling requests.
+	GetInterval() time.Duration
+
+	// SetInterval sets the minimum amount of time the client should wait between polling requests.
+	SetInterval(interval time.Duration)
+
+	// GetHeader returns the response's header
+	GetHeader() (header http.Header)
+
+	// AddHeader adds an header key value pair to the response
+	AddHeader(key, value string)
+
+	// ToMap converts the response to a map.
+	ToMap() map[string]interface{}
+}
+
+// PushedAuthorizeResponder is the response object of a pushed authorization request.
+type PushedAuthorizeResponder interface {
+	// GetRequestURI returns the request_uri referencing the pushed authorization request.
+	GetRequestURI() string
+
+	// SetRequestURI sets the request_uri referencing the pushed authorization request.
+	SetRequestURI(requestURI string)
+
+	// GetExpiresIn returns the lifetime of the request_uri.
+	GetExpiresIn() time.Duration
+
+	// SetExpiresIn sets the lifetime of the request_uri.
+	SetExpiresIn(expiresIn time.Duration)
+
+	// GetHeader returns the response's header
+	GetHeader() (header http.Header)
+
+	// AddHeader adds an header key value pair to the response
+	AddHeader(key, value string)
+
+	// ToMap converts the response to a map.
+	ToMap() map[string]interface{}
+}
+
 // G11NContext is the globalization context
 type G11NContext interface {
 	// GetLang returns the current language in the context
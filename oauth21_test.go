@@ -0,0 +1,102 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/storage"
+)
+
+func TestComposeEnforceOAuth21(t *testing.T) {
+	store := storage.NewExampleStore()
+	config := &compose.Config{EnforceOAuth21: true}
+	f := compose.Compose(
+		config,
+		store,
+		compose.NewOAuth2HMACStrategy(config, []byte("some-secret-thats-random-some-secret-thats-random-"), nil),
+		nil,
+		compose.OAuth2AuthorizeExplicitFactory,
+		compose.OAuth2AuthorizeImplicitFactory,
+		compose.OAuth2RefreshTokenGrantFactory,
+		compose.OAuth2ResourceOwnerPasswordCredentialsFactory,
+	)
+	ctx := context.Background()
+	assert.False(t, config.EnforcePKCE, "the config passed to Compose must not be modified")
+
+	authorize := func(responseType string, form url.Values) error {
+		form.Set("client_id", "my-client")
+		form.Set("response_type", responseType)
+		form.Set("redirect_uri", "http://localhost:3846/callback")
+		form.Set("state", "some-random-state")
+		ar, err := f.NewAuthorizeRequest(ctx, httptest.NewRequest("GET", "/auth?"+form.Encode(), nil))
+		if err != nil {
+			return err
+		}
+		_, err = f.NewAuthorizeResponse(ctx, ar, &DefaultSession{Subject: "peter"})
+		return err
+	}
+
+	t.Run("case=rejects the implicit grant", func(t *testing.T) {
+		err := authorize("token", url.Values{})
+		assert.True(t, errors.Is(err, ErrUnsupportedResponseType), "%+v", err)
+	})
+
+	t.Run("case=requires PKCE", func(t *testing.T) {
+		err := authorize("code", url.Values{})
+		assert.True(t, errors.Is(err, ErrInvalidRequest), "%+v", err)
+
+		err = authorize("code", url.Values{"code_challenge": {"challenge"}, "code_challenge_method": {"plain"}})
+		assert.True(t, errors.Is(err, ErrInvalidRequest), "%+v", err)
+
+		assert.NoError(t, authorize("code", url.Values{"code_challenge": {"challenge"}, "code_challenge_method": {"S256"}}))
+	})
+
+	t.Run("case=rejects the password grant", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "/token", strings.NewReader(url.Values{
+			"grant_type": {"password"},
+			"username":   {"peter"},
+			"password":   {"secret"},
+		}.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.SetBasicAuth("my-client", "foobar")
+		_, err := f.NewAccessRequest(ctx, r, &DefaultSession{})
+		assert.Error(t, err)
+	})
+
+	t.Run("case=publishes the supported grants", func(t *testing.T) {
+		metadata := f.(*Fosite).NewDiscoveryMetadata(ctx, DiscoveryConfiguration{})
+		assert.Equal(t, []string{"code"}, metadata.ResponseTypesSupported)
+		assert.NotContains(t, metadata.GrantTypesSupported, "password")
+		assert.NotContains(t, metadata.GrantTypesSupported, "implicit")
+		assert.Equal(t, []string{"S256"}, metadata.CodeChallengeMethodsSupported)
+	})
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+
+	"github.com/ory/x/errorsx"
+)
+
+// OfflineAccessScope is the scope OpenID Connect clients request refresh tokens with, see
+// https://openid.net/specs/openid-connect-core-1_0.html#OfflineAccess
+const OfflineAccessScope = "offline_access"
+
+// OfflineAccessPolicy decides whether the offline_access scope of an OpenID Connect authorization request is
+// honored. If not, the scope is removed from the requested and granted scopes, so that no refresh token is issued
+// for it.
+type OfflineAccessPolicy interface {
+	// AllowOfflineAccess returns true if offline_access may be granted for the request.
+	AllowOfflineAccess(ctx context.Context, requester AuthorizeRequester) bool
+}
+
+// OfflineAccessPolicyFunc is an adapter to allow the use of ordinary functions as OfflineAccessPolicy.
+type OfflineAccessPolicyFunc func(ctx context.Context, requester AuthorizeRequester) bool
+
+func (f OfflineAccessPolicyFunc) AllowOfflineAccess(ctx context.Context, requester AuthorizeRequester) bool {
+	return f(ctx, requester)
+}
+
+var (
+	// ConsentRequiredOfflineAccessPolicy honors offline_access only if the response type returns an authorization
+	// code and the prompt parameter contains consent, as required by
+	// https://openid.net/specs/openid-connect-core-1_0.html#OfflineAccess
+	ConsentRequiredOfflineAccessPolicy OfflineAccessPolicy = OfflineAccessPolicyFunc(func(_ context.Context, requester AuthorizeRequester) bool {
+		return requester.GetResponseTypes().Has("code") && isPromptRequested(requester, "consent")
+	})
+
+	// CodeResponseOfflineAccessPolicy honors offline_access if the response type returns an authorization code. Use
+	// it if the consent to offline access is ensured by other means than prompt=consent.
+	CodeResponseOfflineAccessPolicy OfflineAccessPolicy = OfflineAccessPolicyFunc(func(_ context.Context, requester AuthorizeRequester) bool {
+		return requester.GetResponseTypes().Has("code")
+	})
+)
+
+// isOfflineAccessDenied returns true if the request is an OpenID Connect request whose offline_access scope must not
+// be honored.
+func (f *Fosite) isOfflineAccessDenied(ctx context.Context, requester AuthorizeRequester) bool {
+	if f.OfflineAccessPolicy == nil || !requester.GetRequestedScopes().Has("openid") {
+		return false
+	}
+	return !f.OfflineAccessPolicy.AllowOfflineAccess(ctx, requester)
+}
+
+// validateAuthorizeOfflineAccess removes offline_access from the requested scopes if it must not be honored.
+func (f *Fosite) validateAuthorizeOfflineAccess(ctx context.Context, request *AuthorizeRequest) {
+	if !request.GetRequestedScopes().Has(OfflineAccessScope) || !f.isOfflineAccessDenied(ctx, request) {
+		return
+	}
+	request.SetRequestedScopes(removeScope(request.GetRequestedScopes(), OfflineAccessScope))
+}
+
+// revokeOfflineAccess removes offline_access from the granted scopes if it must not be honored, regardless of
+// whether it was granted by the application or by a previous consent.
+func (f *Fosite) revokeOfflineAccess(ctx context.Context, ar AuthorizeRequester) error {
+	if f.OfflineAccessPolicy == nil || !ar.GetGrantedScopes().Has(OfflineAccessScope) || !f.isOfflineAccessDenied(ctx, ar) {
+		return nil
+	}
+
+	r, ok := ar.(*AuthorizeRequest)
+	if !ok {
+		return errorsx.WithStack(ErrServerError.WithDebug("The offline_access scope can not be revoked because the authorize request is not of type *fosite.AuthorizeRequest."))
+	}
+	r.GrantedScope = removeScope(r.GrantedScope, OfflineAccessScope)
+	return nil
+}
+
+func removeScope(scopes Arguments, scope string) Arguments {
+	filtered := Arguments{}
+	for _, s := range scopes {
+		if s != scope {
+			filtered = append(filtered, s)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,163 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/storage"
+)
+
+func TestOfflineAccess(t *testing.T) {
+	store := storage.NewExampleStore()
+	store.Clients["offline-client"] = &DefaultClient{
+		ID:            "offline-client",
+		Secret:        []byte(`$2a$10$IxMdI6d.LIRZPpSfEwNoeu4rY3FhDREsxFJXikcgdRRAStxUlsuEO`), // = "foobar"
+		RedirectURIs:  []string{"http://localhost:3846/callback"},
+		ResponseTypes: []string{"code", "token"},
+		GrantTypes:    []string{"implicit", "refresh_token", "authorization_code"},
+		Scopes:        []string{"openid", "offline", "offline_access", "photos"},
+	}
+	config := &compose.Config{OfflineAccessPolicy: ConsentRequiredOfflineAccessPolicy}
+	f := compose.Compose(
+		config,
+		store,
+		compose.NewOAuth2HMACStrategy(config, []byte("some-secret-thats-random-some-secret-thats-random-"), nil),
+		nil,
+		compose.OAuth2AuthorizeExplicitFactory,
+		compose.OAuth2AuthorizeImplicitFactory,
+		compose.OAuth2RefreshTokenGrantFactory,
+	)
+	ctx := context.Background()
+
+	authorize := func(t *testing.T, responseType, scope, prompt string, grant ...string) AuthorizeResponder {
+		query := url.Values{
+			"client_id":     {"offline-client"},
+			"response_type": {responseType},
+			"redirect_uri":  {"http://localhost:3846/callback"},
+			"state":         {"some-random-state"},
+			"nonce":         {"some-random-nonce"},
+			"scope":         {scope},
+		}
+		if prompt != "" {
+			query.Set("prompt", prompt)
+		}
+		ar, err := f.NewAuthorizeRequest(ctx, httptest.NewRequest("GET", "/auth?"+query.Encode(), nil))
+		require.NoError(t, err)
+		for _, s := range ar.GetRequestedScopes() {
+			ar.GrantScope(s)
+		}
+		for _, s := range grant {
+			ar.GrantScope(s)
+		}
+		resp, err := f.NewAuthorizeResponse(ctx, ar, &DefaultSession{Subject: "peter"})
+		require.NoError(t, err)
+		return resp
+	}
+
+	token := func(t *testing.T, code string) AccessResponder {
+		r := httptest.NewRequest("POST", "/token", strings.NewReader(url.Values{
+			"grant_type":   {"authorization_code"},
+			"code":         {code},
+			"redirect_uri": {"http://localhost:3846/callback"},
+		}.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.SetBasicAuth("offline-client", "foobar")
+		ar, err := f.NewAccessRequest(ctx, r, new(DefaultSession))
+		require.NoError(t, err)
+		resp, err := f.NewAccessResponse(ctx, ar)
+		require.NoError(t, err)
+		return resp
+	}
+
+	for k, c := range []struct {
+		d             string
+		scope         string
+		prompt        string
+		grant         []string
+		expectRefresh bool
+		expectScope   string
+	}{
+		{
+			d:             "should issue a refresh token because consent was prompted for",
+			scope:         "openid offline_access",
+			prompt:        "login consent",
+			expectRefresh: true,
+			expectScope:   "openid offline_access",
+		},
+		{
+			d:           "should strip offline_access because consent was not prompted for",
+			scope:       "openid offline_access",
+			expectScope: "openid",
+		},
+		{
+			d:           "should strip offline_access even if the application granted it",
+			scope:       "openid",
+			grant:       []string{"offline_access"},
+			expectScope: "openid",
+		},
+		{
+			d:           "should not issue a refresh token for the offline scope without offline_access",
+			scope:       "openid offline",
+			expectScope: "openid offline",
+		},
+		{
+			d:             "should not enforce offline_access for plain OAuth 2.0 requests",
+			scope:         "photos offline_access",
+			expectRefresh: true,
+			expectScope:   "photos offline_access",
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			resp := token(t, authorize(t, "code", c.scope, c.prompt, c.grant...).GetParameters().Get("code"))
+			assert.Equal(t, c.expectScope, resp.GetExtra("scope"))
+			if c.expectRefresh {
+				assert.NotEmpty(t, resp.GetExtra("refresh_token"))
+			} else {
+				assert.Empty(t, resp.GetExtra("refresh_token"))
+			}
+		})
+	}
+
+	t.Run("case=should strip offline_access from implicit requests", func(t *testing.T) {
+		resp := authorize(t, "token", "openid offline_access", "consent")
+		assert.Equal(t, "openid", resp.GetParameters().Get("scope"))
+	})
+
+	t.Run("case=should not require consent with the code response policy", func(t *testing.T) {
+		f.(*Fosite).OfflineAccessPolicy = CodeResponseOfflineAccessPolicy
+		defer func() { f.(*Fosite).OfflineAccessPolicy = ConsentRequiredOfflineAccessPolicy }()
+
+		resp := token(t, authorize(t, "code", "openid offline_access", "").GetParameters().Get("code"))
+		assert.NotEmpty(t, resp.GetExtra("refresh_token"))
+	})
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"net/http"
+)
+
+// PhantomTokenContentType is the media type an API gateway asks the introspection endpoint for to exchange an opaque
+// access token for the JWT it references (phantom token pattern).
+const PhantomTokenContentType = "application/jwt"
+
+// PhantomTokenSession is implemented by sessions which keep the JWT referenced by an opaque access token. The JWT is
+// never handed to the client, it is only returned by the introspection endpoint.
+type PhantomTokenSession interface {
+	// GetPhantomToken returns the JWT referenced by the access token or an empty string.
+	GetPhantomToken() string
+
+	// SetPhantomToken sets the JWT referenced by the access token.
+	SetPhantomToken(token string)
+}
+
+// PhantomTokenIntrospectionResponder is implemented by introspection responses which know whether the caller
+// asked for the JWT referenced by the introspected access token.
+type PhantomTokenIntrospectionResponder interface {
+	IntrospectionResponder
+
+	// IsPhantomTokenRequested returns true if the caller asked for the JWT referenced by the access token.
+	IsPhantomTokenRequested() bool
+}
+
+// acceptsPhantomToken returns true if the request's Accept header asks for the JWT referenced by the access token.
+func acceptsPhantomToken(r *http.Request) bool {
+	return acceptsMediaType(r, PhantomTokenContentType)
+}
+
+// writePhantomToken responds with the JWT referenced by an introspected access token.
+func writePhantomToken(rw http.ResponseWriter, token string) {
+	rw.Header().Set("Content-Type", PhantomTokenContentType)
+	rw.Header().Set("Cache-Control", "no-store")
+	rw.Header().Set("Pragma", "no-cache")
+	_, _ = rw.Write([]byte(token))
+}
@@ -0,0 +1,170 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/handler/oauth2"
+	"github.com/ory/fosite/internal"
+	"github.com/ory/fosite/storage"
+	"github.com/ory/fosite/token/jwt"
+)
+
+type phantomTokenSession struct {
+	DefaultSession
+	PhantomToken string
+}
+
+func (s *phantomTokenSession) GetPhantomToken() string {
+	return s.PhantomToken
+}
+
+func (s *phantomTokenSession) SetPhantomToken(token string) {
+	s.PhantomToken = token
+}
+
+func TestWriteIntrospectionResponsePhantomToken(t *testing.T) {
+	f := new(Fosite)
+
+	for _, c := range []struct {
+		description string
+		response    *IntrospectionResponse
+		expectJWT   bool
+	}{
+		{
+			description: "should return the JWT referenced by the access token",
+			response: &IntrospectionResponse{
+				Active:          true,
+				TokenUse:        AccessToken,
+				AccessRequester: NewAccessRequest(&phantomTokenSession{PhantomToken: "header.payload.signature"}),
+				PhantomToken:    true,
+			},
+			expectJWT: true,
+		},
+		{
+			description: "should return JSON if the JWT was not requested",
+			response: &IntrospectionResponse{
+				Active:          true,
+				TokenUse:        AccessToken,
+				AccessRequester: NewAccessRequest(&phantomTokenSession{PhantomToken: "header.payload.signature"}),
+			},
+		},
+		{
+			description: "should return JSON if the session has no phantom token",
+			response: &IntrospectionResponse{
+				Active:          true,
+				TokenUse:        AccessToken,
+				AccessRequester: NewAccessRequest(new(phantomTokenSession)),
+				PhantomToken:    true,
+			},
+		},
+		{
+			description: "should return JSON for refresh tokens",
+			response: &IntrospectionResponse{
+				Active:          true,
+				TokenUse:        RefreshToken,
+				AccessRequester: NewAccessRequest(&phantomTokenSession{PhantomToken: "header.payload.signature"}),
+				PhantomToken:    true,
+			},
+		},
+	} {
+		t.Run("case="+c.description, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			f.WriteIntrospectionResponse(rw, c.response)
+			assert.Equal(t, http.StatusOK, rw.Code)
+
+			if c.expectJWT {
+				assert.Equal(t, PhantomTokenContentType, rw.Header().Get("Content-Type"))
+				assert.Equal(t, "no-store", rw.Header().Get("Cache-Control"))
+				assert.Equal(t, "header.payload.signature", rw.Body.String())
+				return
+			}
+
+			var params map[string]interface{}
+			require.NoError(t, json.NewDecoder(rw.Body).Decode(&params))
+			assert.Equal(t, true, params["active"])
+		})
+	}
+}
+
+func TestIntrospectPhantomToken(t *testing.T) {
+	store := storage.NewMemoryStore()
+	store.Clients["api-gateway"] = &DefaultClient{ID: "api-gateway", Secret: []byte(`$2a$10$IxMdI6d.LIRZPpSfEwNoeu4rY3FhDREsxFJXikcgdRRAStxUlsuEO`)}
+	config := new(compose.Config)
+	hmacStrategy := compose.NewOAuth2HMACStrategy(config, []byte("some-secret-thats-random-some-secret-thats-random-"), nil)
+	strategy := compose.NewOAuth2PhantomTokenStrategy(hmacStrategy, compose.NewOAuth2JWTStrategy(internal.MustRSAKey(), hmacStrategy))
+	f := compose.Compose(config, store, strategy, nil, compose.OAuth2TokenIntrospectionFactory)
+
+	request := &Request{
+		Client:      &DefaultClient{ID: "foo"},
+		RequestedAt: time.Now().UTC(),
+		Session: &oauth2.JWTSession{
+			JWTClaims: &jwt.JWTClaims{Subject: "peter"},
+			Subject:   "peter",
+			ExpiresAt: map[TokenType]time.Time{AccessToken: time.Now().UTC().Add(time.Hour)},
+		},
+	}
+	token, signature, err := strategy.GenerateAccessToken(context.Background(), request)
+	require.NoError(t, err)
+	require.NoError(t, store.CreateAccessTokenSession(context.Background(), signature, request))
+
+	introspect := func(t *testing.T, accept string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/introspect", strings.NewReader(url.Values{"token": {token}}.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.Header.Set("Accept", accept)
+		r.SetBasicAuth("api-gateway", "foobar")
+
+		ir, err := f.NewIntrospectionRequest(context.Background(), r, new(oauth2.JWTSession))
+		require.NoError(t, err)
+
+		rw := httptest.NewRecorder()
+		f.WriteIntrospectionResponse(rw, ir)
+		return rw
+	}
+
+	t.Run("case=exchanges the opaque token for the JWT", func(t *testing.T) {
+		rw := introspect(t, PhantomTokenContentType)
+		assert.Equal(t, PhantomTokenContentType, rw.Header().Get("Content-Type"))
+		assert.Equal(t, request.Session.(*oauth2.JWTSession).PhantomToken, rw.Body.String())
+		assert.Len(t, strings.Split(rw.Body.String(), "."), 3)
+	})
+
+	t.Run("case=returns the introspection response otherwise", func(t *testing.T) {
+		var params map[string]interface{}
+		require.NoError(t, json.NewDecoder(introspect(t, "application/json").Body).Decode(&params))
+		assert.Equal(t, true, params["active"])
+		assert.Equal(t, "peter", params["sub"])
+	})
+}
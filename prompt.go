@@ -0,0 +1,63 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"strings"
+
+	"github.com/ory/x/errorsx"
+)
+
+// The values of the prompt parameter defined in https://openid.net/specs/openid-connect-core-1_0.html#AuthRequest
+// and https://openid.net/specs/openid-connect-prompt-create-1_0.html
+const (
+	PromptNone          = "none"
+	PromptLogin         = "login"
+	PromptConsent       = "consent"
+	PromptSelectAccount = "select_account"
+	PromptCreate        = "create"
+)
+
+// RequestedPrompt returns the values of the prompt parameter of the request.
+func RequestedPrompt(requester Requester) Arguments {
+	return RemoveEmpty(strings.Split(requester.GetRequestForm().Get("prompt"), " "))
+}
+
+// IsPromptCreateRequested returns true if the client asked for the end-user to be taken to a registration flow
+// instead of a login flow using prompt=create, see https://openid.net/specs/openid-connect-prompt-create-1_0.html
+func IsPromptCreateRequested(requester Requester) bool {
+	return RequestedPrompt(requester).Has(PromptCreate)
+}
+
+func isPromptRequested(requester Requester, prompt string) bool {
+	return RequestedPrompt(requester).Has(prompt)
+}
+
+// validateAuthorizePrompt rejects prompt=create combined with prompt=none, as an account can not be created
+// without displaying a user interface.
+func (f *Fosite) validateAuthorizePrompt(request *AuthorizeRequest) error {
+	prompt := RequestedPrompt(request)
+	if prompt.Has(PromptCreate) && prompt.Has(PromptNone) {
+		return errorsx.WithStack(ErrInvalidRequest.WithHint("Parameter 'prompt' must not contain both 'create' and 'none'."))
+	}
+	return nil
+}
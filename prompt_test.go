@@ -0,0 +1,79 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/storage"
+)
+
+func TestPromptCreate(t *testing.T) {
+	config := new(compose.Config)
+	f := compose.Compose(
+		config,
+		storage.NewExampleStore(),
+		compose.NewOAuth2HMACStrategy(config, []byte("some-secret-thats-random-some-secret-thats-random-"), nil),
+		nil,
+		compose.OAuth2AuthorizeExplicitFactory,
+	)
+
+	for k, c := range []struct {
+		prompt       string
+		expectCreate bool
+		expectErr    error
+	}{
+		{prompt: "create", expectCreate: true},
+		{prompt: "create consent", expectCreate: true},
+		{prompt: "login"},
+		{prompt: ""},
+		{prompt: "create none", expectErr: ErrInvalidRequest},
+	} {
+		t.Run(fmt.Sprintf("case=%d/prompt=%s", k, c.prompt), func(t *testing.T) {
+			query := url.Values{
+				"client_id":     {"my-client"},
+				"response_type": {"code"},
+				"redirect_uri":  {"http://localhost:3846/callback"},
+				"state":         {"some-random-state"},
+				"prompt":        {c.prompt},
+			}
+			ar, err := f.NewAuthorizeRequest(context.Background(), httptest.NewRequest("GET", "/auth?"+query.Encode(), nil))
+			if c.expectErr != nil {
+				require.Error(t, err)
+				assert.True(t, errors.Is(err, c.expectErr), "%+v", err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.expectCreate, IsPromptCreateRequested(ar))
+		})
+	}
+}
@@ -0,0 +1,137 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ory/x/errorsx"
+	"github.com/pkg/errors"
+
+	"github.com/ory/fosite/i18n"
+)
+
+// NewPushedAuthorizeRequest implements
+// * https://tools.ietf.org/html/rfc9126#section-2.1
+//   The client sends the parameters that comprise an authorization request directly to the PAR endpoint. A typical
+//   parameter set might include: "client_id", "response_type", "redirect_uri", "scope", "state",
+//   "code_challenge", and "code_challenge_method". The request also includes, as appropriate for the given client,
+//   any additional parameters necessary for client authentication.
+func (f *Fosite) NewPushedAuthorizeRequest(ctx context.Context, r *http.Request) (AuthorizeRequester, error) {
+	ctx = f.withStoragePolicy(ctx)
+	ctx = ensureRequestID(ctx, r)
+	request, err := f.newPushedAuthorizeRequest(ctx, r)
+	return request, attachRequestID(ctx, err)
+}
+
+func (f *Fosite) newPushedAuthorizeRequest(ctx context.Context, r *http.Request) (AuthorizeRequester, error) {
+	request := NewAuthorizeRequest()
+	request.PersistedParameters = f.SanitationWhiteList
+	request.redirectURIMatcher = f.GetRedirectURIMatcher()
+	request.Request.Lang = i18n.GetLangFromRequest(f.MessageCatalog, r)
+
+	ctx = context.WithValue(ctx, RequestContextKey, r)
+	ctx = context.WithValue(ctx, AuthorizeRequestContextKey, request)
+
+	if r.Method != "POST" {
+		return request, errorsx.WithStack(ErrInvalidRequest.WithHintf("HTTP method is '%s', expected 'POST'.", r.Method))
+	} else if err := r.ParseMultipartForm(1 << 20); err != nil && err != http.ErrNotMultipart {
+		return request, errorsx.WithStack(ErrInvalidRequest.WithHint("Unable to parse HTTP body, make sure to send a properly formatted form request body.").WithWrap(err).WithDebug(err.Error()))
+	} else if len(r.PostForm) == 0 {
+		return request, errorsx.WithStack(ErrInvalidRequest.WithHint("The POST body can not be empty."))
+	}
+	request.Form = r.PostForm
+	request.Request.Lang = i18n.GetLangFromLocales(f.MessageCatalog, request.Form.Get("ui_locales"), request.Request.Lang)
+
+	// Save state to the request to be returned in error conditions (https://github.com/ory/hydra/issues/1642)
+	request.State = request.Form.Get("state")
+
+	client, err := f.AuthenticateClient(ctx, r, r.PostForm)
+	if err != nil {
+		return request, err
+	}
+	request.Client = client
+
+	// The "request_uri" authorization request parameter is one exception, and it MUST NOT be provided.
+	if request.Form.Get("request_uri") != "" {
+		return request, errorsx.WithStack(ErrInvalidRequest.WithHint("The request must not contain 'request_uri'."))
+	}
+
+	if err := f.validateAuthorizeRequest(ctx, r, request); err != nil {
+		return request, err
+	}
+
+	return request, nil
+}
+
+// authorizeRequestFromPAR replaces the parameters of the authorization request with the ones of the pushed
+// authorization request referenced by "request_uri", see https://tools.ietf.org/html/rfc9126#section-4
+func (f *Fosite) authorizeRequestFromPAR(ctx context.Context, r *http.Request, request *AuthorizeRequest) error {
+	requestURI := request.Form.Get("request_uri")
+	if requestURI == "" || !strings.HasPrefix(requestURI, f.GetPushedAuthorizeRequestURIPrefix()) {
+		if f.EnforcePushedAuthorize {
+			return errorsx.WithStack(ErrInvalidRequest.WithHint("Pushed Authorization Requests are enforced but no such request was sent."))
+		}
+		return nil
+	}
+
+	storage, ok := f.Store.(PARStorage)
+	if !ok {
+		return errorsx.WithStack(ErrServerError.WithHint("Pushed Authorization Requests are not supported by the storage."))
+	}
+
+	var pushed AuthorizeRequester
+	err := StorageOperation(ctx, "GetPARSession", func(ctx context.Context) (err error) {
+		pushed, err = storage.GetPARSession(ctx, requestURI)
+		return err
+	})
+	if errors.Is(err, ErrNotFound) {
+		return errorsx.WithStack(ErrInvalidRequestURI.WithHint("The request_uri is unknown or has already been used.").WithWrap(err).WithDebug(err.Error()))
+	} else if err != nil {
+		return errorsx.WithStack(ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	if pushed.GetClient().GetID() != request.GetClient().GetID() {
+		return errorsx.WithStack(ErrInvalidRequestURI.WithHint("The request_uri was issued to another OAuth 2.0 Client."))
+	}
+
+	if exp := pushed.GetSession().GetExpiresAt(PushedAuthorizeRequestContext); !exp.IsZero() && exp.Before(time.Now().UTC()) {
+		return errorsx.WithStack(ErrInvalidRequestURI.WithHintf("The request_uri expired at '%s'.", exp))
+	}
+
+	// The request_uri is intended for a single authorization request and must not be reused. It is only consumed
+	// once the client and the expiry were checked, so that other clients can not invalidate it.
+	if err := StorageOperation(ctx, "DeletePARSession", func(ctx context.Context) error {
+		return storage.DeletePARSession(ctx, requestURI)
+	}); err != nil {
+		return errorsx.WithStack(ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	// The form of the HTTP request is kept in sync, as the validators read some parameters from it.
+	request.Form = pushed.GetRequestForm()
+	r.Form = request.Form
+	request.State = request.Form.Get("state")
+	return nil
+}
@@ -0,0 +1,196 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/fosite"
+	"github.com/ory/fosite/handler/par"
+	"github.com/ory/fosite/storage"
+)
+
+func newPARProvider(store *storage.MemoryStore) *Fosite {
+	store.Clients["public"] = &DefaultClient{
+		ID:            "public",
+		Public:        true,
+		RedirectURIs:  []string{"https://foo.bar/cb"},
+		ResponseTypes: []string{"code"},
+		Scopes:        []string{"foo", "bar"},
+	}
+	store.Clients["other"] = &DefaultClient{ID: "other", Public: true, RedirectURIs: []string{"https://foo.bar/cb"}, ResponseTypes: []string{"code"}}
+	return &Fosite{
+		Store:                    store,
+		ScopeStrategy:            ExactScopeStrategy,
+		AudienceMatchingStrategy: DefaultAudienceMatchingStrategy,
+		PushedAuthorizeEndpointHandlers: PushedAuthorizeEndpointHandlers{&par.PushedAuthorizeHandler{
+			Storage:                        store,
+			PushedAuthorizeContextLifespan: time.Minute,
+		}},
+	}
+}
+
+func pushAuthorizeRequest(t *testing.T, f *Fosite, form url.Values) (AuthorizeRequester, PushedAuthorizeResponder, error) {
+	r, err := http.NewRequest("POST", "https://www.ory.sh/par", strings.NewReader(form.Encode()))
+	require.NoError(t, err)
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	ar, err := f.NewPushedAuthorizeRequest(context.Background(), r)
+	if err != nil {
+		return ar, nil, err
+	}
+	resp, err := f.NewPushedAuthorizeResponse(context.Background(), ar, new(DefaultSession))
+	return ar, resp, err
+}
+
+func TestNewPushedAuthorizeRequest(t *testing.T) {
+	valid := url.Values{
+		"client_id":     {"public"},
+		"redirect_uri":  {"https://foo.bar/cb"},
+		"response_type": {"code"},
+		"scope":         {"foo bar"},
+		"state":         {"strong-state"},
+	}
+	with := func(k, v string) url.Values {
+		form := url.Values{}
+		for key, values := range valid {
+			form[key] = values
+		}
+		form.Set(k, v)
+		return form
+	}
+
+	for _, c := range []struct {
+		d         string
+		form      url.Values
+		expectErr error
+	}{
+		{d: "fails with unknown client", form: with("client_id", "unknown"), expectErr: ErrInvalidClient},
+		{d: "fails with request_uri", form: with("request_uri", PushedAuthorizeRequestURIPrefix+"foo"), expectErr: ErrInvalidRequest},
+		{d: "fails with invalid redirect_uri", form: with("redirect_uri", "https://bar.foo/cb"), expectErr: ErrInvalidRequest},
+		{d: "fails with invalid scope", form: with("scope", "baz"), expectErr: ErrInvalidScope},
+		{d: "fails with invalid response_type", form: with("response_type", "token"), expectErr: ErrUnsupportedResponseType},
+		{d: "fails with weak state", form: with("state", "weak"), expectErr: ErrInvalidState},
+		{d: "passes", form: valid},
+	} {
+		t.Run("case="+c.d, func(t *testing.T) {
+			_, resp, err := pushAuthorizeRequest(t, newPARProvider(storage.NewMemoryStore()), c.form)
+			if c.expectErr != nil {
+				require.ErrorIs(t, err, c.expectErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, strings.HasPrefix(resp.GetRequestURI(), PushedAuthorizeRequestURIPrefix))
+			assert.InDelta(t, time.Minute.Seconds(), resp.GetExpiresIn().Seconds(), 2)
+		})
+	}
+
+	t.Run("case=fails with GET", func(t *testing.T) {
+		r, err := http.NewRequest("GET", "https://www.ory.sh/par?"+valid.Encode(), nil)
+		require.NoError(t, err)
+		_, err = newPARProvider(storage.NewMemoryStore()).NewPushedAuthorizeRequest(context.Background(), r)
+		require.ErrorIs(t, err, ErrInvalidRequest)
+	})
+}
+
+func TestNewAuthorizeRequestFromPAR(t *testing.T) {
+	store := storage.NewMemoryStore()
+	f := newPARProvider(store)
+
+	push := func(t *testing.T) string {
+		_, resp, err := pushAuthorizeRequest(t, f, url.Values{
+			"client_id":     {"public"},
+			"redirect_uri":  {"https://foo.bar/cb"},
+			"response_type": {"code"},
+			"scope":         {"foo"},
+			"state":         {"strong-state"},
+		})
+		require.NoError(t, err)
+		return resp.GetRequestURI()
+	}
+	authorize := func(t *testing.T, query url.Values) (AuthorizeRequester, error) {
+		r, err := http.NewRequest("GET", "https://www.ory.sh/auth?"+query.Encode(), nil)
+		require.NoError(t, err)
+		return f.NewAuthorizeRequest(context.Background(), r)
+	}
+
+	t.Run("case=uses the pushed parameters once", func(t *testing.T) {
+		requestURI := push(t)
+
+		ar, err := authorize(t, url.Values{"client_id": {"public"}, "request_uri": {requestURI}, "scope": {"bar"}})
+		require.NoError(t, err)
+		assert.Equal(t, Arguments{"foo"}, ar.GetRequestedScopes())
+		assert.Equal(t, "strong-state", ar.(*AuthorizeRequest).State)
+		assert.Equal(t, "https://foo.bar/cb", ar.GetRedirectURI().String())
+
+		_, err = authorize(t, url.Values{"client_id": {"public"}, "request_uri": {requestURI}})
+		require.ErrorIs(t, err, ErrInvalidRequestURI)
+	})
+
+	t.Run("case=fails for another client without consuming the request_uri", func(t *testing.T) {
+		requestURI := push(t)
+		_, err := authorize(t, url.Values{"client_id": {"other"}, "request_uri": {requestURI}})
+		require.ErrorIs(t, err, ErrInvalidRequestURI)
+
+		_, err = authorize(t, url.Values{"client_id": {"public"}, "request_uri": {requestURI}})
+		require.NoError(t, err)
+	})
+
+	t.Run("case=fails when expired", func(t *testing.T) {
+		requestURI := push(t)
+		store.PARSessions[requestURI].GetSession().SetExpiresAt(PushedAuthorizeRequestContext, time.Now().UTC().Add(-time.Minute))
+		_, err := authorize(t, url.Values{"client_id": {"public"}, "request_uri": {requestURI}})
+		require.ErrorIs(t, err, ErrInvalidRequestURI)
+	})
+
+	t.Run("case=fails without PAR when enforced", func(t *testing.T) {
+		f.EnforcePushedAuthorize = true
+		defer func() { f.EnforcePushedAuthorize = false }()
+
+		_, err := authorize(t, url.Values{"client_id": {"public"}, "redirect_uri": {"https://foo.bar/cb"}, "response_type": {"code"}, "state": {"strong-state"}})
+		require.ErrorIs(t, err, ErrInvalidRequest)
+
+		_, err = authorize(t, url.Values{"client_id": {"public"}, "request_uri": {push(t)}})
+		require.NoError(t, err)
+	})
+}
+
+func TestWritePushedAuthorizeResponse(t *testing.T) {
+	rw := httptest.NewRecorder()
+	resp := NewPushedAuthorizeResponse()
+	resp.SetRequestURI(PushedAuthorizeRequestURIPrefix + "foo")
+	resp.SetExpiresIn(time.Minute)
+
+	(&Fosite{}).WritePushedAuthorizeResponse(rw, NewAuthorizeRequest(), resp)
+	assert.Equal(t, http.StatusCreated, rw.Code)
+	assert.Equal(t, "no-store", rw.Header().Get("Cache-Control"))
+	assert.JSONEq(t, `{"request_uri":"urn:ietf:params:oauth:request_uri:foo","expires_in":60}`, rw.Body.String())
+}
@@ -0,0 +1,71 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"net/http"
+	"time"
+)
+
+// PushedAuthorizeResponse is an implementation of PushedAuthorizeResponder
+type PushedAuthorizeResponse struct {
+	Header     http.Header
+	RequestURI string
+	ExpiresIn  time.Duration
+}
+
+func NewPushedAuthorizeResponse() *PushedAuthorizeResponse {
+	return &PushedAuthorizeResponse{
+		Header: http.Header{},
+	}
+}
+
+func (p *PushedAuthorizeResponse) GetRequestURI() string {
+	return p.RequestURI
+}
+
+func (p *PushedAuthorizeResponse) SetRequestURI(requestURI string) {
+	p.RequestURI = requestURI
+}
+
+func (p *PushedAuthorizeResponse) GetExpiresIn() time.Duration {
+	return p.ExpiresIn
+}
+
+func (p *PushedAuthorizeResponse) SetExpiresIn(expiresIn time.Duration) {
+	p.ExpiresIn = expiresIn
+}
+
+func (p *PushedAuthorizeResponse) GetHeader() http.Header {
+	return p.Header
+}
+
+func (p *PushedAuthorizeResponse) AddHeader(key, value string) {
+	p.Header.Add(key, value)
+}
+
+func (p *PushedAuthorizeResponse) ToMap() map[string]interface{} {
+	return map[string]interface{}{
+		"request_uri": p.RequestURI,
+		"expires_in":  int64(p.ExpiresIn / time.Second),
+	}
+}
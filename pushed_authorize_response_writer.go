@@ -0,0 +1,57 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+
+	"github.com/ory/x/errorsx"
+)
+
+// NewPushedAuthorizeResponse iterates through all PushedAuthorizeEndpointHandlers and returns the pushed
+// authorization response.
+func (f *Fosite) NewPushedAuthorizeResponse(ctx context.Context, ar AuthorizeRequester, session Session) (PushedAuthorizeResponder, error) {
+	ctx = f.withStoragePolicy(ctx)
+	ctx = ensureRequestID(ctx, nil)
+	response, err := f.newPushedAuthorizeResponse(ctx, ar, session)
+	return response, attachRequestID(ctx, err)
+}
+
+func (f *Fosite) newPushedAuthorizeResponse(ctx context.Context, ar AuthorizeRequester, session Session) (PushedAuthorizeResponder, error) {
+	resp := NewPushedAuthorizeResponse()
+
+	ctx = context.WithValue(ctx, AuthorizeRequestContextKey, ar)
+	ctx = context.WithValue(ctx, PushedAuthorizeResponseContextKey, resp)
+
+	ar.SetSession(session)
+	for _, h := range f.PushedAuthorizeEndpointHandlers {
+		if err := h.HandlePushedAuthorizeEndpointRequest(ctx, ar, resp); err != nil {
+			return nil, err
+		}
+	}
+
+	if resp.GetRequestURI() == "" {
+		return nil, errorsx.WithStack(ErrServerError.WithHint("An internal server occurred while trying to complete the request.").WithDebug("The request_uri was not set by PushedAuthorizeEndpointHandlers.").WithLocalizer(f.MessageCatalog, getLangFromRequester(ar)))
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,66 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+// RedirectURIMatcher matches the redirect_uri parameter of authorization requests against the redirect URIs
+// registered by the client, see https://tools.ietf.org/html/rfc6749#section-3.1.2.3
+type RedirectURIMatcher interface {
+	// MatchRedirectURI returns the redirect URI the response is sent to and true, if requested matches one of the
+	// redirect URIs registered by the client.
+	MatchRedirectURI(requested string, client Client) (string, bool)
+}
+
+// RedirectURIMatcherFunc is an adapter to allow the use of ordinary functions as RedirectURIMatcher.
+type RedirectURIMatcherFunc func(requested string, client Client) (string, bool)
+
+func (f RedirectURIMatcherFunc) MatchRedirectURI(requested string, client Client) (string, bool) {
+	return f(requested, client)
+}
+
+var (
+	// ExactRedirectURIMatcher only accepts redirect URIs which are identical to a registered one, using simple string
+	// comparison as defined in https://tools.ietf.org/html/rfc3986#section-6.2.1
+	ExactRedirectURIMatcher RedirectURIMatcher = RedirectURIMatcherFunc(func(requested string, client Client) (string, bool) {
+		for _, registered := range client.GetRedirectURIs() {
+			if registered == requested {
+				return registered, true
+			}
+		}
+		return "", false
+	})
+
+	// LoopbackRedirectURIMatcher accepts redirect URIs which are identical to a registered one, as well as redirect
+	// URIs using any port with a registered loopback IP redirect URI such as http://127.0.0.1/callback or
+	// http://[::1]/callback, which native apps listening on an ephemeral port require, see
+	// https://tools.ietf.org/html/rfc8252#section-7.3
+	LoopbackRedirectURIMatcher RedirectURIMatcher = RedirectURIMatcherFunc(func(requested string, client Client) (string, bool) {
+		return isMatchingRedirectURI(requested, client.GetRedirectURIs())
+	})
+)
+
+// GetRedirectURIMatcher returns RedirectURIMatcher if set. Defaults to ExactRedirectURIMatcher.
+func (f *Fosite) GetRedirectURIMatcher() RedirectURIMatcher {
+	if f.RedirectURIMatcher == nil {
+		return ExactRedirectURIMatcher
+	}
+	return f.RedirectURIMatcher
+}
@@ -0,0 +1,109 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/fosite"
+	"github.com/ory/fosite/storage"
+)
+
+func TestRedirectURIMatcher(t *testing.T) {
+	client := &DefaultClient{RedirectURIs: []string{"https://www.example.com/callback", "http://127.0.0.1/callback"}}
+
+	for k, c := range []struct {
+		matcher   RedirectURIMatcher
+		requested string
+		expect    string
+	}{
+		{matcher: ExactRedirectURIMatcher, requested: "https://www.example.com/callback", expect: "https://www.example.com/callback"},
+		{matcher: ExactRedirectURIMatcher, requested: "https://www.example.com/callback?foo=bar"},
+		{matcher: ExactRedirectURIMatcher, requested: "http://127.0.0.1/callback", expect: "http://127.0.0.1/callback"},
+		{matcher: ExactRedirectURIMatcher, requested: "http://127.0.0.1:51234/callback"},
+		{matcher: LoopbackRedirectURIMatcher, requested: "http://127.0.0.1:51234/callback", expect: "http://127.0.0.1:51234/callback"},
+		{matcher: LoopbackRedirectURIMatcher, requested: "http://127.0.0.1:51234/other"},
+		{matcher: LoopbackRedirectURIMatcher, requested: "https://www.example.com:8443/callback"},
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			redirectURI, ok := c.matcher.MatchRedirectURI(c.requested, client)
+			assert.Equal(t, c.expect != "", ok)
+			assert.Equal(t, c.expect, redirectURI)
+		})
+	}
+}
+
+func TestNewAuthorizeRequestWithRedirectURIMatcher(t *testing.T) {
+	client := &DefaultClient{
+		ID:            "foo",
+		RedirectURIs:  []string{"http://127.0.0.1/callback", "https://www.example.com/"},
+		ResponseTypes: []string{"code"},
+	}
+	store := storage.NewMemoryStore()
+	store.Clients["foo"] = client
+
+	for k, c := range []struct {
+		d           string
+		matcher     RedirectURIMatcher
+		redirectURI string
+		expectErr   bool
+	}{
+		{d: "should reject a loopback port by default", redirectURI: "http://127.0.0.1:51234/callback", expectErr: true},
+		{d: "should accept a loopback port", matcher: LoopbackRedirectURIMatcher, redirectURI: "http://127.0.0.1:51234/callback"},
+		{d: "should use a custom matcher", redirectURI: "https://www.example.com/tenant-a", matcher: RedirectURIMatcherFunc(func(requested string, client Client) (string, bool) {
+			return requested, strings.HasPrefix(requested, "https://www.example.com/")
+		})},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			f := &Fosite{
+				Store:                    store,
+				RedirectURIMatcher:       c.matcher,
+				ScopeStrategy:            HierarchicScopeStrategy,
+				AudienceMatchingStrategy: DefaultAudienceMatchingStrategy,
+			}
+			r := httptest.NewRequest("GET", "/auth?"+url.Values{
+				"client_id":     {"foo"},
+				"response_type": {"code"},
+				"redirect_uri":  {c.redirectURI},
+				"state":         {"some-random-state"},
+			}.Encode(), nil)
+
+			ar, err := f.NewAuthorizeRequest(context.Background(), r)
+			if c.expectErr {
+				require.Error(t, err)
+				assert.False(t, ar.IsRedirectURIValid())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.redirectURI, ar.GetRedirectURI().String())
+			assert.True(t, ar.IsRedirectURIValid())
+		})
+	}
+}
@@ -41,6 +41,63 @@ type Request struct {
 	RequestedAudience Arguments    `json:"requestedAudience"`
 	GrantedAudience   Arguments    `json:"grantedAudience"`
 	Lang              language.Tag `json:"-"`
+
+	// RequestedAuthorizationDetails and GrantedAuthorizationDetails hold the authorization_details parameter, see
+	// https://tools.ietf.org/html/rfc9396
+	RequestedAuthorizationDetails AuthorizationDetails `json:"requestedAuthorizationDetails,omitempty"`
+	GrantedAuthorizationDetails   AuthorizationDetails `json:"grantedAuthorizationDetails,omitempty"`
+
+	// PersistedParameters are the form parameters Sanitize keeps in addition to the allowed parameters, see
+	// Fosite.SanitationWhiteList.
+	PersistedParameters []string `json:"-"`
+
+	// rawForm is the form before the request was sanitized.
+	rawForm url.Values
+}
+
+// RawFormRequester is implemented by requests which give access to the form before it was sanitized.
+type RawFormRequester interface {
+	// GetRawRequestForm returns a copy of the form before the request was sanitized.
+	GetRawRequestForm() url.Values
+}
+
+// PersistedParametersRequester is implemented by requests which keep additional form parameters when sanitized.
+type PersistedParametersRequester interface {
+	// GetPersistedParameters returns the form parameters which are kept when the request is sanitized.
+	GetPersistedParameters() []string
+}
+
+// GetRawRequestForm returns a copy of the form of the request before it was sanitized, or of its form if the
+// request does not keep the original one.
+func GetRawRequestForm(requester Requester) url.Values {
+	if r, ok := requester.(RawFormRequester); ok {
+		return r.GetRawRequestForm()
+	}
+	return copyForm(requester.GetRequestForm())
+}
+
+// CarryOverPersistedParameters copies the persisted parameters of to, which are missing from its form, from
+// the form of the stored request, for example the authorize request of an authorization code.
+func CarryOverPersistedParameters(to, from Requester) {
+	r, ok := to.(PersistedParametersRequester)
+	if !ok {
+		return
+	}
+
+	form := to.GetRequestForm()
+	for _, k := range r.GetPersistedParameters() {
+		if _, ok := form[k]; !ok && len(from.GetRequestForm()[k]) > 0 {
+			form[k] = from.GetRequestForm()[k]
+		}
+	}
+}
+
+func copyForm(form url.Values) url.Values {
+	c := make(url.Values, len(form))
+	for k, v := range form {
+		c[k] = append([]string(nil), v...)
+	}
+	return c
 }
 
 func NewRequest() *Request {
@@ -144,6 +201,22 @@ func (a *Request) GrantScope(scope string) {
 	a.GrantedScope = append(a.GrantedScope, scope)
 }
 
+func (a *Request) GetRequestedAuthorizationDetails() AuthorizationDetails {
+	return a.RequestedAuthorizationDetails
+}
+
+func (a *Request) SetRequestedAuthorizationDetails(details AuthorizationDetails) {
+	a.RequestedAuthorizationDetails = details
+}
+
+func (a *Request) GetAuthorizationDetails() AuthorizationDetails {
+	return a.GrantedAuthorizationDetails
+}
+
+func (a *Request) GrantAuthorizationDetails(details AuthorizationDetails) {
+	a.GrantedAuthorizationDetails = details
+}
+
 func (a *Request) SetSession(session Session) {
 	a.Session = session
 }
@@ -167,6 +240,13 @@ func (a *Request) Merge(request Requester) {
 		a.GrantAudience(aud)
 	}
 
+	if details := request.GetRequestedAuthorizationDetails(); len(details) > 0 {
+		a.RequestedAuthorizationDetails = details
+	}
+	if details := request.GetAuthorizationDetails(); len(details) > 0 {
+		a.GrantedAuthorizationDetails = details
+	}
+
 	a.ID = request.GetID()
 	a.RequestedAt = request.GetRequestedAt()
 	a.Client = request.GetClient()
@@ -183,9 +263,23 @@ func (a *Request) Sanitize(allowedParameters []string) Requester {
 	for _, v := range allowedParameters {
 		allowed[v] = true
 	}
+	for _, v := range a.PersistedParameters {
+		allowed[v] = true
+	}
 
 	*b = *a
 	b.ID = a.GetID()
+	if a.Session != nil {
+		// The sanitized request is persisted, it must not alias the session of the request which may still change.
+		b.Session = a.Session.Clone()
+	}
+	if a.GrantedAudience != nil {
+		// The clone's granted audience may be extended, see GrantAudience.
+		b.GrantedAudience = append(Arguments{}, a.GrantedAudience...)
+	}
+	if a.rawForm == nil {
+		b.rawForm = a.Form
+	}
 	b.Form = url.Values{}
 	for k := range a.Form {
 		if allowed[k] {
@@ -196,6 +290,17 @@ func (a *Request) Sanitize(allowedParameters []string) Requester {
 	return b
 }
 
+func (a *Request) GetRawRequestForm() url.Values {
+	if a.rawForm != nil {
+		return copyForm(a.rawForm)
+	}
+	return copyForm(a.Form)
+}
+
+func (a *Request) GetPersistedParameters() []string {
+	return a.PersistedParameters
+}
+
 func (a *Request) GetLang() language.Tag {
 	return a.Lang
 }
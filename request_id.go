@@ -0,0 +1,123 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader is the header carrying the request ID of error responses. It is also read from incoming requests.
+const RequestIDHeader = "X-Request-Id"
+
+// traceparentPattern matches the https://www.w3.org/TR/trace-context/#traceparent-header and captures the trace ID.
+var traceparentPattern = regexp.MustCompile(`^[0-9a-f]{2}-([0-9a-f]{32})-[0-9a-f]{16}-[0-9a-f]{2}$`)
+
+// WithRequestID returns a copy of ctx carrying the correlation ID of the request. It is attached to the errors of
+// fosite's endpoints, written to the RequestIDHeader of error responses and can be retrieved by the EventListener
+// using RequestIDFromContext. Set it, for example in a middleware, to share the ID between all calls made for a
+// request, otherwise each endpoint uses the ID of the request's trace or generates one.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, RequestIDContextKey, id)
+}
+
+// RequestIDFromContext returns the request ID set by WithRequestID or the trace ID of the span in ctx, if any.
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if id, _ := ctx.Value(RequestIDContextKey).(string); id != "" {
+		return id
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		return sc.TraceID().String()
+	}
+	return ""
+}
+
+// RequestIDFromError returns the ID of the request which caused err, if any.
+func RequestIDFromError(err error) string {
+	var e *RFC6749Error
+	if errors.As(err, &e) {
+		return e.RequestID()
+	}
+	return ""
+}
+
+// ensureRequestID returns a copy of ctx carrying a request ID. The ID is taken from ctx, the RequestIDHeader or the
+// traceparent header of r, in this order, and generated if none is set.
+func ensureRequestID(ctx context.Context, r *http.Request) context.Context {
+	if ctx == nil {
+		// Not all callers pass a context.
+		return nil
+	} else if id := RequestIDFromContext(ctx); id != "" {
+		return WithRequestID(ctx, id)
+	}
+
+	if r != nil {
+		if id := r.Header.Get(RequestIDHeader); id != "" {
+			return WithRequestID(ctx, id)
+		} else if m := traceparentPattern.FindStringSubmatch(r.Header.Get("traceparent")); m != nil {
+			return WithRequestID(ctx, m[1])
+		}
+	}
+
+	id, err := randomHex(16)
+	if err != nil {
+		return ctx
+	}
+	return WithRequestID(ctx, id)
+}
+
+// attachRequestID returns a copy of err carrying the request ID of ctx, see RequestIDFromError. Only errors of type
+// *RFC6749Error carry a request ID, all other errors as well as errors already carrying a request ID are returned
+// unchanged, so that they can still be compared and type asserted by the caller.
+func attachRequestID(ctx context.Context, err error) error {
+	e, ok := err.(*RFC6749Error)
+	if !ok || e.RequestID() != "" {
+		return err
+	}
+	if id := RequestIDFromContext(ctx); id != "" {
+		return e.WithRequestID(id)
+	}
+	return err
+}
+
+// requestContext returns a context carrying the request ID of err, which is passed to the EventListener by the
+// functions writing errors.
+func requestContext(err error) context.Context {
+	if id := RequestIDFromError(err); id != "" {
+		return WithRequestID(context.Background(), id)
+	}
+	return context.Background()
+}
+
+// setRequestIDHeader sets the RequestIDHeader of the response to the request ID of err, if any.
+func setRequestIDHeader(rw http.ResponseWriter, err error) {
+	if id := RequestIDFromError(err); id != "" {
+		rw.Header().Set(RequestIDHeader, id)
+	}
+}
@@ -0,0 +1,113 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnsureRequestID(t *testing.T) {
+	for k, c := range []struct {
+		d      string
+		ctx    context.Context
+		header http.Header
+		expect string
+	}{
+		{
+			d:      "should prefer the request ID of the context",
+			ctx:    WithRequestID(context.Background(), "from-context"),
+			header: http.Header{RequestIDHeader: {"from-header"}},
+			expect: "from-context",
+		},
+		{
+			d:      "should use the request ID header",
+			ctx:    context.Background(),
+			header: http.Header{RequestIDHeader: {"from-header"}, "Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}},
+			expect: "from-header",
+		},
+		{
+			d:      "should use the trace ID of the traceparent header",
+			ctx:    context.Background(),
+			header: http.Header{"Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}},
+			expect: "4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		{
+			d:      "should ignore invalid traceparent headers",
+			ctx:    context.Background(),
+			header: http.Header{"Traceparent": {"foo"}},
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			ctx := ensureRequestID(c.ctx, &http.Request{Header: c.header})
+			if c.expect == "" {
+				assert.Len(t, RequestIDFromContext(ctx), 32, "should generate a request ID")
+			} else {
+				assert.Equal(t, c.expect, RequestIDFromContext(ctx))
+			}
+			assert.Equal(t, RequestIDFromContext(ctx), RequestIDFromContext(ensureRequestID(ctx, nil)), "should be stable")
+		})
+	}
+}
+
+func TestRequestIDPropagation(t *testing.T) {
+	f := &Fosite{SendDebugMessagesToClients: true, UseLegacyErrorFormat: true}
+
+	r := httptest.NewRequest("GET", "/token", nil)
+	r.Header.Set(RequestIDHeader, "request-id")
+	_, err := f.NewAccessRequest(context.Background(), r, new(DefaultSession))
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrInvalidRequest))
+	assert.Equal(t, "request-id", RequestIDFromError(err))
+	assert.Equal(t, "request-id", ErrorToRFC6749Error(err).RequestID())
+
+	rw := httptest.NewRecorder()
+	f.WriteAccessError(rw, nil, err)
+	assert.Equal(t, "request-id", rw.Header().Get(RequestIDHeader))
+
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &body))
+	assert.Contains(t, body["error_debug"], "Request ID: request-id")
+}
+
+func TestAttachRequestID(t *testing.T) {
+	ctx := WithRequestID(context.Background(), "request-id")
+	fooErr := errors.New("foo")
+
+	err := attachRequestID(ctx, ErrInvalidRequest.WithHint("foo"))
+	rfcErr, ok := err.(*RFC6749Error)
+	require.True(t, ok, "%T", err)
+	assert.Equal(t, "request-id", rfcErr.RequestID())
+	assert.Empty(t, ErrInvalidRequest.RequestID(), "should not modify the original error")
+
+	assert.Equal(t, rfcErr, attachRequestID(WithRequestID(ctx, "other"), rfcErr), "should keep the request ID")
+	assert.Equal(t, fooErr, attachRequestID(ctx, fooErr))
+	assert.Nil(t, attachRequestID(ctx, nil))
+}
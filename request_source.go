@@ -0,0 +1,155 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/url"
+)
+
+// RequestSource describes an incoming request independently of its transport. It allows gRPC gateways and
+// serverless runtimes, for example AWS Lambda, to drive fosite without constructing HTTP requests themselves.
+type RequestSource interface {
+	// GetMethod returns the request method, for example "POST". An empty method defaults to the method which is
+	// expected by the endpoint.
+	GetMethod() string
+
+	// GetURL returns the URL the request was sent to. It is used, for example, to validate DPoP proofs and may be nil.
+	GetURL() *url.URL
+
+	// GetForm returns the parameters of the request.
+	GetForm() url.Values
+
+	// GetHeader returns the headers of the request, for example Authorization or DPoP.
+	GetHeader() http.Header
+
+	// GetTLS returns the state of the TLS connection, which is used for mutual TLS client authentication. It is nil
+	// if the request was not received over TLS.
+	GetTLS() *tls.ConnectionState
+}
+
+// AuthorizeRequestSource is the source of an authorize request, see NewAuthorizeRequestFromSource.
+type AuthorizeRequestSource interface {
+	RequestSource
+}
+
+// AccessRequestSource is the source of an access request, see NewAccessRequestFromSource.
+type AccessRequestSource interface {
+	RequestSource
+}
+
+// DefaultRequestSource is a RequestSource which is an AuthorizeRequestSource and an AccessRequestSource.
+type DefaultRequestSource struct {
+	Method string
+	URL    *url.URL
+	Form   url.Values
+	Header http.Header
+	TLS    *tls.ConnectionState
+}
+
+var (
+	_ AuthorizeRequestSource = (*DefaultRequestSource)(nil)
+	_ AccessRequestSource    = (*DefaultRequestSource)(nil)
+)
+
+func (s *DefaultRequestSource) GetMethod() string {
+	return s.Method
+}
+
+func (s *DefaultRequestSource) GetURL() *url.URL {
+	return s.URL
+}
+
+func (s *DefaultRequestSource) GetForm() url.Values {
+	return s.Form
+}
+
+func (s *DefaultRequestSource) GetHeader() http.Header {
+	return s.Header
+}
+
+func (s *DefaultRequestSource) GetTLS() *tls.ConnectionState {
+	return s.TLS
+}
+
+// NewAuthorizeRequestFromSource is NewAuthorizeRequest for requests which were not received as *http.Request.
+func (f *Fosite) NewAuthorizeRequestFromSource(ctx context.Context, source AuthorizeRequestSource) (AuthorizeRequester, error) {
+	return f.NewAuthorizeRequest(ctx, newHTTPRequestFromSource(ctx, source, http.MethodGet))
+}
+
+// NewAccessRequestFromSource is NewAccessRequest for requests which were not received as *http.Request.
+func (f *Fosite) NewAccessRequestFromSource(ctx context.Context, source AccessRequestSource, session Session) (AccessRequester, error) {
+	return f.NewAccessRequest(ctx, newHTTPRequestFromSource(ctx, source, http.MethodPost), session)
+}
+
+// newHTTPRequestFromSource returns an *http.Request whose form is already parsed, so that the request handlers
+// never read a body.
+func newHTTPRequestFromSource(ctx context.Context, source RequestSource, method string) *http.Request {
+	if m := source.GetMethod(); m != "" {
+		method = m
+	}
+
+	u := &url.URL{Path: "/"}
+	if source.GetURL() != nil {
+		c := *source.GetURL()
+		u = &c
+	}
+
+	header := http.Header{}
+	for k, v := range source.GetHeader() {
+		header[http.CanonicalHeaderKey(k)] = append([]string(nil), v...)
+	}
+	header.Del("Content-Length")
+
+	form := url.Values{}
+	for k, v := range source.GetForm() {
+		form[k] = append([]string(nil), v...)
+	}
+
+	postForm := url.Values{}
+	if method == http.MethodPost || method == http.MethodPut || method == http.MethodPatch {
+		postForm = form
+		header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		header.Del("Content-Type")
+	}
+
+	r := &http.Request{
+		Method:     method,
+		URL:        u,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     header,
+		Body:       http.NoBody,
+		Host:       u.Host,
+		Form:       form,
+		PostForm:   postForm,
+		TLS:        source.GetTLS(),
+	}
+	if ctx != nil {
+		r = r.WithContext(ctx)
+	}
+	return r
+}
@@ -27,6 +27,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	. "github.com/ory/fosite"
 )
@@ -112,6 +113,49 @@ func TestSanitizeRequest(t *testing.T) {
 	assert.Equal(t, "fasdf", a.GetRequestForm().Get("baz"))
 }
 
+func TestSanitizeRequestClonesSession(t *testing.T) {
+	a := &Request{
+		Client:  &DefaultClient{ID: "123"},
+		Session: &DefaultSession{Subject: "peter", Extra: map[string]interface{}{"tenant": "acme"}},
+	}
+
+	b := a.Sanitize(nil)
+	require.Equal(t, a.GetSession(), b.GetSession())
+
+	a.GetSession().(*DefaultSession).Extra["tenant"] = "other"
+	a.GetSession().SetExpiresAt(AccessToken, time.Now())
+	assert.Equal(t, "acme", b.GetSession().(*DefaultSession).Extra["tenant"])
+	assert.True(t, b.GetSession().GetExpiresAt(AccessToken).IsZero())
+}
+
+func TestSanitizeRequestWithPersistedParameters(t *testing.T) {
+	a := &Request{
+		Form: url.Values{
+			"code":   {"foo"},
+			"tenant": {"tenant-a"},
+			"secret": {"bar"},
+		},
+		PersistedParameters: []string{"tenant"},
+	}
+
+	b := a.Sanitize([]string{"code"})
+	assert.Equal(t, url.Values{"code": {"foo"}, "tenant": {"tenant-a"}}, b.GetRequestForm())
+
+	raw := GetRawRequestForm(b)
+	assert.Equal(t, a.Form, raw)
+	raw.Set("secret", "baz")
+	assert.Equal(t, "bar", GetRawRequestForm(b).Get("secret"))
+	assert.Equal(t, a.Form, GetRawRequestForm(b.Sanitize(nil)))
+
+	to := &Request{Form: url.Values{"code": {"bar"}}, PersistedParameters: []string{"tenant", "device_id"}}
+	CarryOverPersistedParameters(to, b)
+	assert.Equal(t, url.Values{"code": {"bar"}, "tenant": {"tenant-a"}}, to.Form)
+
+	to = &Request{Form: url.Values{"tenant": {"tenant-b"}}, PersistedParameters: []string{"tenant"}}
+	CarryOverPersistedParameters(to, b)
+	assert.Equal(t, "tenant-b", to.Form.Get("tenant"))
+}
+
 func TestIdentifyRequest(t *testing.T) {
 	a := &Request{
 		RequestedAt:    time.Now().UTC(),
@@ -38,6 +38,44 @@ func (rs ResponseModeTypes) Has(item ResponseModeType) bool {
 	return false
 }
 
+// ResponseModeHandlers combines several response mode handlers into one. The first handler supporting the
+// request's response mode writes the response, which allows registering custom response modes from
+// independent packages using ResponseModeHandlerExtension.
+type ResponseModeHandlers []ResponseModeHandler
+
+func (hs ResponseModeHandlers) ResponseModes() ResponseModeTypes {
+	var modes ResponseModeTypes
+	for _, h := range hs {
+		for _, mode := range h.ResponseModes() {
+			if !modes.Has(mode) {
+				modes = append(modes, mode)
+			}
+		}
+	}
+	return modes
+}
+
+func (hs ResponseModeHandlers) WriteAuthorizeResponse(rw http.ResponseWriter, ar AuthorizeRequester, resp AuthorizeResponder) {
+	if h := hs.handlerFor(ar.GetResponseMode()); h != nil {
+		h.WriteAuthorizeResponse(rw, ar, resp)
+	}
+}
+
+func (hs ResponseModeHandlers) WriteAuthorizeError(rw http.ResponseWriter, ar AuthorizeRequester, err error) {
+	if h := hs.handlerFor(ar.GetResponseMode()); h != nil {
+		h.WriteAuthorizeError(rw, ar, err)
+	}
+}
+
+func (hs ResponseModeHandlers) handlerFor(rm ResponseModeType) ResponseModeHandler {
+	for _, h := range hs {
+		if h.ResponseModes().Has(rm) {
+			return h
+		}
+	}
+	return nil
+}
+
 type DefaultResponseModeHandler struct{}
 
 func (d *DefaultResponseModeHandler) ResponseModes() ResponseModeTypes { return nil }
@@ -0,0 +1,120 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/ory/x/errorsx"
+	"github.com/pkg/errors"
+)
+
+// ResponseTypeRegistry maps response_type values to the AuthorizeEndpointHandlers responsible for them. If Fosite
+// has a ResponseTypeRegistry, only registered response types are accepted by the authorization endpoint, and only
+// the handlers registered for the requested response type are called, in the order they were registered, instead
+// of all AuthorizeEndpointHandlers:
+//
+//	f := compose.Compose(config, store, strategy, nil, factories...).(*fosite.Fosite)
+//	registry := new(fosite.ResponseTypeRegistry)
+//	if err := registry.Register("code", f.AuthorizeEndpointHandlers...); err != nil {
+//		// ...
+//	}
+//	if err := registry.Register("urn:mycompany:response-type:x", customHandler); err != nil {
+//		// ...
+//	}
+//	f.ResponseTypeRegistry = registry
+//
+// The zero value is an empty registry. It is not safe to register response types while requests are handled.
+type ResponseTypeRegistry struct {
+	handlers map[string]AuthorizeEndpointHandlers
+}
+
+// Register registers the handlers for the response type. The order of the space-delimited values does not matter,
+// "code id_token" and "id_token code" are the same response type, see
+// https://tools.ietf.org/html/rfc6749#section-3.1.1. It returns an error if the response type is empty or already
+// registered, or if no handlers are given.
+func (r *ResponseTypeRegistry) Register(responseType string, handlers ...AuthorizeEndpointHandler) error {
+	key := responseTypeKey(strings.Split(responseType, " "))
+	if key == "" {
+		return errors.New("the response type must not be empty")
+	} else if _, ok := r.handlers[key]; ok {
+		return errors.Errorf("the response type '%s' is already registered", key)
+	} else if len(handlers) == 0 {
+		return errors.Errorf("no handlers are given for the response type '%s'", key)
+	}
+	for _, h := range handlers {
+		if h == nil {
+			return errors.Errorf("a handler of the response type '%s' is nil", key)
+		}
+	}
+
+	if r.handlers == nil {
+		r.handlers = map[string]AuthorizeEndpointHandlers{}
+	}
+	r.handlers[key] = append(AuthorizeEndpointHandlers{}, handlers...)
+	return nil
+}
+
+// Handlers returns the handlers registered for the response types, and false if they are not registered.
+func (r *ResponseTypeRegistry) Handlers(responseTypes Arguments) (AuthorizeEndpointHandlers, bool) {
+	handlers, ok := r.handlers[responseTypeKey(responseTypes)]
+	return handlers, ok
+}
+
+// ResponseTypes returns the registered response types in alphabetical order.
+func (r *ResponseTypeRegistry) ResponseTypes() []string {
+	responseTypes := make([]string, 0, len(r.handlers))
+	for responseType := range r.handlers {
+		responseTypes = append(responseTypes, responseType)
+	}
+	sort.Strings(responseTypes)
+	return responseTypes
+}
+
+// responseTypeKey returns the sorted, space-delimited and deduplicated values of the response type.
+func responseTypeKey(responseTypes []string) string {
+	values := RemoveEmpty(responseTypes)
+	sort.Strings(values)
+
+	unique := values[:0]
+	for i, value := range values {
+		if i == 0 || value != values[i-1] {
+			unique = append(unique, value)
+		}
+	}
+	return strings.Join(unique, " ")
+}
+
+// authorizeEndpointHandlers returns the handlers responsible for the response types of the request.
+func (f *Fosite) authorizeEndpointHandlers(_ context.Context, ar AuthorizeRequester) (AuthorizeEndpointHandlers, error) {
+	if f.ResponseTypeRegistry == nil {
+		return f.AuthorizeEndpointHandlers, nil
+	}
+
+	handlers, ok := f.ResponseTypeRegistry.Handlers(ar.GetResponseTypes())
+	if !ok {
+		return nil, errorsx.WithStack(ErrUnsupportedResponseType.WithHintf("The response_type '%s' is not supported by the authorization server.", strings.Join(ar.GetResponseTypes(), " ")))
+	}
+	return handlers, nil
+}
@@ -0,0 +1,144 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/storage"
+)
+
+type ticketResponseTypeHandler struct{}
+
+func (h *ticketResponseTypeHandler) HandleAuthorizeEndpointRequest(_ context.Context, ar AuthorizeRequester, resp AuthorizeResponder) error {
+	if ar.GetResponseTypes().ExactOne("ticket") {
+		ar.SetResponseTypeHandled("ticket")
+		resp.AddParameter("ticket", "some-ticket")
+	}
+	return nil
+}
+
+func TestResponseTypeRegistry_Register(t *testing.T) {
+	h := new(ticketResponseTypeHandler)
+	registry := new(ResponseTypeRegistry)
+
+	_, ok := registry.Handlers(Arguments{"code"})
+	assert.False(t, ok)
+
+	require.NoError(t, registry.Register("code id_token", h))
+	require.NoError(t, registry.Register("ticket", h, h))
+	require.Error(t, registry.Register("id_token  code", h), "the order of the values must not matter")
+	require.Error(t, registry.Register(" ", h))
+	require.Error(t, registry.Register("token"))
+	require.Error(t, registry.Register("token", nil))
+
+	handlers, ok := registry.Handlers(Arguments{"id_token", "code"})
+	require.True(t, ok)
+	assert.Len(t, handlers, 1)
+	handlers, ok = registry.Handlers(Arguments{"ticket"})
+	require.True(t, ok)
+	assert.Len(t, handlers, 2)
+	assert.Equal(t, []string{"code id_token", "ticket"}, registry.ResponseTypes())
+}
+
+func TestResponseTypeRegistry(t *testing.T) {
+	store := storage.NewExampleStore()
+	store.Clients["ticket-client"] = &DefaultClient{
+		ID:            "ticket-client",
+		RedirectURIs:  []string{"http://localhost:3846/callback"},
+		ResponseTypes: []string{"code", "token", "ticket"},
+		GrantTypes:    []string{"implicit", "authorization_code"},
+		Scopes:        []string{"photos"},
+	}
+	config := new(compose.Config)
+	f := compose.Compose(
+		config,
+		store,
+		compose.NewOAuth2HMACStrategy(config, []byte("some-secret-thats-random-some-secret-thats-random-"), nil),
+		nil,
+		compose.OAuth2AuthorizeExplicitFactory,
+		compose.OAuth2AuthorizeImplicitFactory,
+	).(*Fosite)
+
+	registry := new(ResponseTypeRegistry)
+	require.NoError(t, registry.Register("code", f.AuthorizeEndpointHandlers...))
+	require.NoError(t, registry.Register("ticket", new(ticketResponseTypeHandler)))
+	f.ResponseTypeRegistry = registry
+	ctx := context.Background()
+
+	for k, c := range []struct {
+		d            string
+		responseType string
+		expectErr    error
+		expectParam  string
+	}{
+		{
+			d:            "should issue an authorization code",
+			responseType: "code",
+			expectParam:  "code",
+		},
+		{
+			d:            "should call the handler of the custom response type",
+			responseType: "ticket",
+			expectParam:  "ticket",
+		},
+		{
+			d:            "should reject the response type which is allowed for the client but not registered",
+			responseType: "token",
+			expectErr:    ErrUnsupportedResponseType,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			query := url.Values{
+				"client_id":     {"ticket-client"},
+				"response_type": {c.responseType},
+				"redirect_uri":  {"http://localhost:3846/callback"},
+				"state":         {"some-random-state"},
+				"scope":         {"photos"},
+			}
+			ar, err := f.NewAuthorizeRequest(ctx, httptest.NewRequest("GET", "/auth?"+query.Encode(), nil))
+			if c.expectErr != nil {
+				require.EqualError(t, err, c.expectErr.Error())
+				return
+			}
+			require.NoError(t, err)
+
+			resp, err := f.NewAuthorizeResponse(ctx, ar, &DefaultSession{Subject: "peter"})
+			require.NoError(t, err)
+			assert.NotEmpty(t, resp.GetParameters().Get(c.expectParam))
+			assert.Empty(t, resp.GetParameters().Get("access_token"))
+		})
+	}
+
+	t.Run("case=should publish the registered response types", func(t *testing.T) {
+		assert.Equal(t, []string{"code", "ticket"}, f.NewDiscoveryMetadata(ctx, DiscoveryConfiguration{}).ResponseTypesSupported)
+	})
+}
@@ -0,0 +1,55 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import "context"
+
+// RevocationPolicy decides which tokens of a grant are revoked when a client revokes one of its tokens at the
+// revocation endpoint, see https://tools.ietf.org/html/rfc7009#section-2.1. The revoked token itself is always
+// revoked.
+type RevocationPolicy interface {
+	// RevokeGrantTokens returns true if the other tokens of the grant are revoked as well when a token of the
+	// given type is revoked. The requester is the request the revoked token was issued for.
+	RevokeGrantTokens(ctx context.Context, revoked TokenType, requester Requester) bool
+}
+
+// RevocationPolicyFunc is a function implementing RevocationPolicy.
+type RevocationPolicyFunc func(ctx context.Context, revoked TokenType, requester Requester) bool
+
+func (f RevocationPolicyFunc) RevokeGrantTokens(ctx context.Context, revoked TokenType, requester Requester) bool {
+	return f(ctx, revoked, requester)
+}
+
+var (
+	// CascadingRevocationPolicy revokes the access and refresh tokens of the grant whichever token is revoked.
+	// This is the default.
+	CascadingRevocationPolicy RevocationPolicy = RevocationPolicyFunc(func(context.Context, TokenType, Requester) bool { return true })
+
+	// RefreshTokenCascadingRevocationPolicy revokes the access tokens of the grant when its refresh token is
+	// revoked, which RFC 7009 recommends, but keeps the refresh token when an access token is revoked.
+	RefreshTokenCascadingRevocationPolicy RevocationPolicy = RevocationPolicyFunc(func(_ context.Context, revoked TokenType, _ Requester) bool {
+		return revoked == RefreshToken
+	})
+
+	// NonCascadingRevocationPolicy only revokes the token which is presented to the revocation endpoint.
+	NonCascadingRevocationPolicy RevocationPolicy = RevocationPolicyFunc(func(context.Context, TokenType, Requester) bool { return false })
+)
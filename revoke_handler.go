@@ -23,8 +23,6 @@ package fosite
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
 	"net/http"
 
 	"github.com/ory/x/errorsx"
@@ -50,6 +48,16 @@ import (
 // An invalid token type hint value is ignored by the authorization
 // server and does not influence the revocation response.
 func (f *Fosite) NewRevocationRequest(ctx context.Context, r *http.Request) error {
+	ctx = f.withStoragePolicy(ctx)
+	ctx = ensureRequestID(ctx, r)
+	ctx, span := f.startSpan(ctx, "fosite.NewRevocationRequest")
+	err := attachRequestID(ctx, f.newRevocationRequest(ctx, r))
+	f.reportRequestFailed(ctx, RevocationEndpoint, err)
+	endSpan(span, nil, err)
+	return err
+}
+
+func (f *Fosite) newRevocationRequest(ctx context.Context, r *http.Request) error {
 	ctx = context.WithValue(ctx, RequestContextKey, r)
 
 	if r.Method != "POST" {
@@ -83,6 +91,7 @@ func (f *Fosite) NewRevocationRequest(ctx context.Context, r *http.Request) erro
 		return errorsx.WithStack(ErrInvalidRequest)
 	}
 
+	f.GetEventListener().OnTokenRevoked(ctx, client, tokenTypeHint)
 	return nil
 }
 
@@ -106,28 +115,11 @@ func (f *Fosite) WriteRevocationResponse(rw http.ResponseWriter, err error) {
 		return
 	}
 
+	setRequestIDHeader(rw, err)
 	if errors.Is(err, ErrInvalidRequest) {
-		rw.Header().Set("Content-Type", "application/json;charset=UTF-8")
-
-		js, err := json.Marshal(ErrInvalidRequest)
-		if err != nil {
-			http.Error(rw, fmt.Sprintf(`{"error": "%s"}`, err.Error()), http.StatusInternalServerError)
-			return
-		}
-
-		rw.WriteHeader(ErrInvalidRequest.CodeField)
-		_, _ = rw.Write(js)
+		f.GetErrorWriter().WriteError(rw, RevocationEndpoint, nil, ErrInvalidRequest.WithRequestID(RequestIDFromError(err)))
 	} else if errors.Is(err, ErrInvalidClient) {
-		rw.Header().Set("Content-Type", "application/json;charset=UTF-8")
-
-		js, err := json.Marshal(ErrInvalidClient)
-		if err != nil {
-			http.Error(rw, fmt.Sprintf(`{"error": "%s"}`, err.Error()), http.StatusInternalServerError)
-			return
-		}
-
-		rw.WriteHeader(ErrInvalidClient.CodeField)
-		_, _ = rw.Write(js)
+		f.GetErrorWriter().WriteError(rw, RevocationEndpoint, nil, ErrInvalidClient.WithRequestID(RequestIDFromError(err)))
 	} else {
 		// 200 OK
 		rw.WriteHeader(http.StatusOK)
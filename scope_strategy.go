@@ -101,3 +101,68 @@ func WildcardScopeStrategy(matchers []string, needle string) bool {
 
 	return false
 }
+
+// GlobScopeStrategy matches scopes consisting of segments separated by ":", such as "read:users", against patterns
+// in which a "*" segment matches any single segment, e.g. "read:*:profile" matches "read:users:profile". A trailing
+// "*" segment matches one or more segments, so "read:*" matches "read:users" and "read:users:all" but not "read".
+// Scopes which are identical to a pattern always match.
+func GlobScopeStrategy(patterns []string, needle string) bool {
+	needleParts := strings.Split(needle, ":")
+	for _, pattern := range patterns {
+		if pattern == needle {
+			return true
+		}
+
+		patternParts := strings.Split(pattern, ":")
+		if len(patternParts) > len(needleParts) {
+			continue
+		}
+
+		matches := true
+		for k, part := range patternParts {
+			if part == "*" && len(needleParts[k]) > 0 {
+				continue
+			} else if part != needleParts[k] {
+				matches = false
+				break
+			}
+		}
+
+		// Only a trailing wildcard matches the remaining segments of longer scopes.
+		if matches && (len(patternParts) == len(needleParts) || patternParts[len(patternParts)-1] == "*") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// The names of the built-in scope strategies clients can select using ScopeStrategyClient.
+const (
+	ScopeStrategyHierarchic = "hierarchic"
+	ScopeStrategyExact      = "exact"
+	ScopeStrategyWildcard   = "wildcard"
+	ScopeStrategyGlob       = "glob"
+)
+
+// ScopeStrategies maps the names clients select scope strategies with to the strategies. Custom strategies can be
+// added before the provider starts serving requests.
+var ScopeStrategies = map[string]ScopeStrategy{
+	ScopeStrategyHierarchic: HierarchicScopeStrategy,
+	ScopeStrategyExact:      ExactScopeStrategy,
+	ScopeStrategyWildcard:   WildcardScopeStrategy,
+	ScopeStrategyGlob:       GlobScopeStrategy,
+}
+
+// ClientScopeStrategy returns the scope strategy selected by the client if it implements ScopeStrategyClient, and
+// fallback if the client did not select one or selected an unknown one.
+func ClientScopeStrategy(client Client, fallback ScopeStrategy) ScopeStrategy {
+	sc, ok := client.(ScopeStrategyClient)
+	if !ok || sc.GetScopeStrategy() == "" {
+		return fallback
+	}
+	if strategy, found := ScopeStrategies[sc.GetScopeStrategy()]; found {
+		return strategy
+	}
+	return fallback
+}
@@ -150,3 +150,40 @@ func TestExactScopeStrategy2ScopeStrategy(t *testing.T) {
 
 	assert.False(t, strategy([]string{}, "foo"))
 }
+
+func TestGlobScopeStrategy(t *testing.T) {
+	var strategy ScopeStrategy = GlobScopeStrategy
+
+	assert.False(t, strategy([]string{}, "read:users"))
+
+	scopes := []string{"read:*"}
+	assert.True(t, strategy(scopes, "read:users"))
+	assert.True(t, strategy(scopes, "read:users:all"))
+	assert.False(t, strategy(scopes, "read"))
+	assert.False(t, strategy(scopes, "read:"))
+	assert.False(t, strategy(scopes, "write:users"))
+
+	scopes = []string{"read:*:profile"}
+	assert.True(t, strategy(scopes, "read:users:profile"))
+	assert.False(t, strategy(scopes, "read:users:profile:photo"))
+	assert.False(t, strategy(scopes, "read:users:email"))
+	assert.False(t, strategy(scopes, "read:users"))
+
+	scopes = []string{"openid", "read:users"}
+	assert.True(t, strategy(scopes, "openid"))
+	assert.True(t, strategy(scopes, "read:users"))
+	assert.False(t, strategy(scopes, "read:users:all"))
+	assert.False(t, strategy(scopes, "read:*"))
+}
+
+func TestClientScopeStrategy(t *testing.T) {
+	newClient := func(strategy string) Client {
+		return &DefaultOpenIDConnectClient{DefaultClient: &DefaultClient{Scopes: []string{"foo"}}, ScopeStrategy: strategy}
+	}
+
+	assert.True(t, ClientScopeStrategy(&DefaultClient{}, HierarchicScopeStrategy)([]string{"foo"}, "foo.bar"))
+	assert.True(t, ClientScopeStrategy(newClient(""), HierarchicScopeStrategy)([]string{"foo"}, "foo.bar"))
+	assert.True(t, ClientScopeStrategy(newClient("unknown"), HierarchicScopeStrategy)([]string{"foo"}, "foo.bar"))
+	assert.False(t, ClientScopeStrategy(newClient(ScopeStrategyExact), HierarchicScopeStrategy)([]string{"foo"}, "foo.bar"))
+	assert.True(t, ClientScopeStrategy(newClient(ScopeStrategyGlob), ExactScopeStrategy)([]string{"read:*"}, "read:users"))
+}
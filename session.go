@@ -46,7 +46,9 @@ type Session interface {
 	// GetSubject returns the subject, if set. This is optional and only used during token introspection.
 	GetSubject() string
 
-	// Clone clones the session.
+	// Clone returns a deep copy of the session. Changing the copy, including the maps, slices and pointers it
+	// contains, must not change the session, as fosite clones sessions to store them for several tokens, for
+	// example the access and refresh token of a grant. Use CheckSessionClone to test implementations.
 	Clone() Session
 }
 
@@ -110,6 +112,15 @@ type ExtraClaimsSession interface {
 	GetExtraClaims() map[string]interface{}
 }
 
+// GetExtraClaims returns the extra claims of the session with typed accessors, or nil if the session does not
+// implement ExtraClaimsSession.
+func GetExtraClaims(session Session) ExtraClaims {
+	if s, ok := session.(ExtraClaimsSession); ok {
+		return s.GetExtraClaims()
+	}
+	return nil
+}
+
 // GetExtraClaims implements ExtraClaimsSession for DefaultSession.
 // The returned value can be modified in-place.
 func (s *DefaultSession) GetExtraClaims() map[string]interface{} {
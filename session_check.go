@@ -0,0 +1,128 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// CheckSessionClone returns an error if Clone does not return a deep copy of the session, that is if the copy
+// shares a map, slice or pointer with the session, or if the session changes in a JSON round-trip, as done by
+// storage implementations which serialize sessions. Use it in the tests of Session implementations, with all maps and
+// slices of the session populated.
+func CheckSessionClone(session Session) error {
+	clone := session.Clone()
+	if clone == nil {
+		return errors.New("the clone of the session is nil")
+	} else if reflect.TypeOf(clone) != reflect.TypeOf(session) {
+		return errors.Errorf("the clone of the session is a %T instead of a %T", clone, session)
+	} else if path, ok := sharedReference(reflect.ValueOf(session), reflect.ValueOf(clone), "session", map[uintptr]bool{}); ok {
+		return errors.Errorf("the clone of the session shares %s with the session", path)
+	}
+
+	original, err := json.Marshal(session)
+	if err != nil {
+		return errors.Wrap(err, "unable to encode the session")
+	}
+	if cloned, err := json.Marshal(clone); err != nil {
+		return errors.Wrap(err, "unable to encode the clone of the session")
+	} else if !bytes.Equal(original, cloned) {
+		return errors.Errorf("the clone of the session differs from the session: %s != %s", cloned, original)
+	}
+
+	decoded := reflect.New(reflect.TypeOf(session))
+	if err := json.Unmarshal(original, decoded.Interface()); err != nil {
+		return errors.Wrap(err, "unable to decode the session")
+	}
+	if roundTrip, err := json.Marshal(decoded.Elem().Interface()); err != nil {
+		return errors.Wrap(err, "unable to encode the decoded session")
+	} else if !bytes.Equal(original, roundTrip) {
+		return errors.Errorf("the session changes in a JSON round-trip: %s != %s", roundTrip, original)
+	}
+	return nil
+}
+
+// sharedReference returns the path to the first map, slice or pointer a and b share.
+func sharedReference(a, b reflect.Value, path string, seen map[uintptr]bool) (string, bool) {
+	if !a.IsValid() || !b.IsValid() || a.Type() != b.Type() {
+		return "", false
+	}
+
+	switch a.Kind() {
+	case reflect.Ptr:
+		if a.IsNil() || b.IsNil() {
+			return "", false
+		} else if a.Pointer() == b.Pointer() {
+			return path, true
+		} else if seen[a.Pointer()] {
+			return "", false
+		}
+		seen[a.Pointer()] = true
+		return sharedReference(a.Elem(), b.Elem(), path, seen)
+	case reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			return "", false
+		}
+		return sharedReference(a.Elem(), b.Elem(), path, seen)
+	case reflect.Map:
+		if a.IsNil() || b.IsNil() {
+			return "", false
+		} else if a.Pointer() == b.Pointer() {
+			return path, true
+		}
+		for _, key := range a.MapKeys() {
+			if p, ok := sharedReference(a.MapIndex(key), b.MapIndex(key), fmt.Sprintf("%s[%v]", path, key), seen); ok {
+				return p, true
+			}
+		}
+	case reflect.Slice:
+		if a.Len() == 0 || b.Len() == 0 {
+			return "", false
+		} else if a.Pointer() == b.Pointer() {
+			return path, true
+		}
+		fallthrough
+	case reflect.Array:
+		for i := 0; i < a.Len() && i < b.Len(); i++ {
+			if p, ok := sharedReference(a.Index(i), b.Index(i), fmt.Sprintf("%s[%d]", path, i), seen); ok {
+				return p, true
+			}
+		}
+	case reflect.Struct:
+		if a.Type() == reflect.TypeOf(time.Time{}) {
+			// The location of a time is shared on purpose.
+			return "", false
+		}
+		for i := 0; i < a.NumField(); i++ {
+			if p, ok := sharedReference(a.Field(i), b.Field(i), path+"."+a.Type().Field(i).Name, seen); ok {
+				return p, true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,150 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// SessionCodec serializes sessions for storage implementations which persist them.
+type SessionCodec interface {
+	// Encode serializes the session.
+	Encode(session Session) ([]byte, error)
+
+	// Decode deserializes data created by Encode into the session, typically a new session of the type the caller
+	// expects.
+	Decode(data []byte, session Session) error
+}
+
+// JSONSessionCodec serializes sessions as JSON, the format used by storage implementations before codecs were
+// introduced.
+type JSONSessionCodec struct{}
+
+var _ SessionCodec = JSONSessionCodec{}
+
+func (JSONSessionCodec) Encode(session Session) ([]byte, error) {
+	return json.Marshal(session)
+}
+
+func (JSONSessionCodec) Decode(data []byte, session Session) error {
+	return json.Unmarshal(data, session)
+}
+
+// GobSessionCodec serializes sessions using encoding/gob. Types stored in interface values, for example in the extra
+// claims, have to be registered with gob.Register.
+type GobSessionCodec struct{}
+
+var _ SessionCodec = GobSessionCodec{}
+
+func (GobSessionCodec) Encode(session Session) ([]byte, error) {
+	var b bytes.Buffer
+	if err := gob.NewEncoder(&b).Encode(session); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func (GobSessionCodec) Decode(data []byte, session Session) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(session)
+}
+
+// SessionMigration upgrades the encoded session of a version to the next version, for example by renaming a field.
+type SessionMigration func(data []byte) ([]byte, error)
+
+// versionedSessionPrefix precedes the version of sessions encoded by a VersionedSessionCodec.
+const versionedSessionPrefix = "fosite.session/v"
+
+// VersionedSessionCodec prefixes the sessions encoded by Codec with Version, and upgrades sessions of older versions
+// using the Migrations before decoding them, so that stored sessions survive changes of the session struct. Data
+// without version, for example written by a JSONSessionCodec or by storage before codecs were introduced, has
+// version 0.
+type VersionedSessionCodec struct {
+	// Codec defaults to JSONSessionCodec.
+	Codec SessionCodec
+
+	// Version is the version of the encoded sessions.
+	Version int
+
+	// Migrations upgrade the encoded sessions of the version of the key to the next version. Versions without
+	// migration are decoded unchanged.
+	Migrations map[int]SessionMigration
+}
+
+var _ SessionCodec = (*VersionedSessionCodec)(nil)
+
+func (c *VersionedSessionCodec) Encode(session Session) ([]byte, error) {
+	data, err := c.codec().Encode(session)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(versionedSessionPrefix+strconv.Itoa(c.Version)+";"), data...), nil
+}
+
+func (c *VersionedSessionCodec) Decode(data []byte, session Session) error {
+	version, data, err := splitSessionVersion(data)
+	if err != nil {
+		return err
+	} else if version > c.Version {
+		return errors.Errorf("the session has version %d, which is newer than the supported version %d", version, c.Version)
+	}
+
+	for ; version < c.Version; version++ {
+		migrate, ok := c.Migrations[version]
+		if !ok {
+			continue
+		}
+		if data, err = migrate(data); err != nil {
+			return errors.Wrapf(err, "unable to migrate the session from version %d", version)
+		}
+	}
+	return c.codec().Decode(data, session)
+}
+
+func (c *VersionedSessionCodec) codec() SessionCodec {
+	if c.Codec == nil {
+		return JSONSessionCodec{}
+	}
+	return c.Codec
+}
+
+// splitSessionVersion returns the version and the encoded session of data created by a VersionedSessionCodec.
+func splitSessionVersion(data []byte) (int, []byte, error) {
+	if !bytes.HasPrefix(data, []byte(versionedSessionPrefix)) {
+		return 0, data, nil
+	}
+
+	rest := data[len(versionedSessionPrefix):]
+	end := bytes.IndexByte(rest, ';')
+	if end < 0 {
+		return 0, nil, errors.New("the version of the session is not terminated")
+	}
+	version, err := strconv.Atoi(string(rest[:end]))
+	if err != nil || version < 0 {
+		return 0, nil, errors.Errorf("the version '%s' of the session is invalid", rest[:end])
+	}
+	return version, rest[end+1:], nil
+}
@@ -0,0 +1,113 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/fosite"
+)
+
+func TestSessionCodecs(t *testing.T) {
+	session := &DefaultSession{Subject: "peter", Username: "peter", Extra: map[string]interface{}{"tenant": "acme"}}
+	for k, codec := range []SessionCodec{
+		JSONSessionCodec{},
+		GobSessionCodec{},
+		&VersionedSessionCodec{Version: 2},
+		&VersionedSessionCodec{Codec: GobSessionCodec{}, Version: 1},
+	} {
+		t.Run(fmt.Sprintf("case=%d/codec=%T", k, codec), func(t *testing.T) {
+			data, err := codec.Encode(session)
+			require.NoError(t, err)
+
+			decoded := new(DefaultSession)
+			require.NoError(t, codec.Decode(data, decoded))
+			assert.Equal(t, session, decoded)
+		})
+	}
+}
+
+func TestVersionedSessionCodec(t *testing.T) {
+	codec := &VersionedSessionCodec{
+		Version: 2,
+		Migrations: map[int]SessionMigration{
+			// Version 1 renamed the "user" field to "Username".
+			0: func(data []byte) ([]byte, error) {
+				return bytes.Replace(data, []byte(`"user":`), []byte(`"Username":`), 1), nil
+			},
+		},
+	}
+
+	data, err := codec.Encode(&DefaultSession{Subject: "peter"})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(data), "fosite.session/v2;{"), "%s", data)
+
+	for k, c := range []struct {
+		d         string
+		data      string
+		expect    *DefaultSession
+		expectErr bool
+	}{
+		{
+			d:      "should migrate sessions stored without version",
+			data:   `{"Subject":"peter","user":"peter@example.com"}`,
+			expect: &DefaultSession{Subject: "peter", Username: "peter@example.com"},
+		},
+		{
+			d:      "should decode sessions of versions without migration",
+			data:   `fosite.session/v1;{"Subject":"peter","Username":"peter@example.com"}`,
+			expect: &DefaultSession{Subject: "peter", Username: "peter@example.com"},
+		},
+		{
+			d:         "should reject sessions of newer versions",
+			data:      `fosite.session/v3;{"Subject":"peter"}`,
+			expectErr: true,
+		},
+		{
+			d:         "should reject invalid versions",
+			data:      `fosite.session/vx;{"Subject":"peter"}`,
+			expectErr: true,
+		},
+		{
+			d:         "should reject unterminated versions",
+			data:      `fosite.session/v1`,
+			expectErr: true,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			decoded := new(DefaultSession)
+			err := codec.Decode([]byte(c.data), decoded)
+			if c.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.expect, decoded)
+		})
+	}
+}
@@ -0,0 +1,220 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ory/x/errorsx"
+)
+
+// SessionStateStrategy provides the OP browser state used to compute the session_state parameter of authorize
+// responses as defined in https://openid.net/specs/openid-connect-session-1_0.html#CreatingUpdatingSessions
+//
+// The OP browser state is a value identifying the end-user's login status at the OpenID Provider, typically a random
+// value generated using NewBrowserState at login, stored in a cookie which is readable by the check_session_iframe,
+// and replaced or removed on logout.
+type SessionStateStrategy interface {
+	// GetBrowserState returns the OP browser state of the end-user's user agent or an empty string if the end-user
+	// has no session, in which case no session_state is returned.
+	GetBrowserState(ctx context.Context, requester AuthorizeRequester, session Session) (string, error)
+}
+
+// NewBrowserState returns a random OP browser state.
+func NewBrowserState() (string, error) {
+	return randomHex(32)
+}
+
+// NewSessionState computes a session_state for the client and user agent using a random salt.
+func NewSessionState(clientID string, redirectURI *url.URL, browserState string) (string, error) {
+	salt, err := randomHex(16)
+	if err != nil {
+		return "", err
+	}
+	return ComputeSessionState(clientID, GetOrigin(redirectURI), browserState, salt), nil
+}
+
+// ComputeSessionState computes the session_state as recommended by
+// https://openid.net/specs/openid-connect-session-1_0.html#CreatingUpdatingSessions
+// which is the hex encoded SHA-256 hash of the client ID, origin, browser state and salt, followed by a dot and the
+// salt. The check_session_iframe computes the same value using JavaScript.
+func ComputeSessionState(clientID, origin, browserState, salt string) string {
+	hash := sha256.Sum256([]byte(clientID + " " + origin + " " + browserState + " " + salt))
+	return hex.EncodeToString(hash[:]) + "." + salt
+}
+
+// VerifySessionState returns true if the session_state was computed for the client, origin and browser state.
+func VerifySessionState(clientID, origin, browserState, sessionState string) bool {
+	i := strings.LastIndex(sessionState, ".")
+	if i < 0 {
+		return false
+	}
+
+	expected := ComputeSessionState(clientID, origin, browserState, sessionState[i+1:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(sessionState)) == 1
+}
+
+// GetOrigin returns the origin (scheme, host and port) of the URL.
+func GetOrigin(u *url.URL) string {
+	return u.Scheme + "://" + u.Host
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", errorsx.WithStack(err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// addSessionState adds the session_state parameter to the authorize responses of OpenID Connect requests if a
+// SessionStateStrategy is configured and the end-user has a session.
+func (f *Fosite) addSessionState(ctx context.Context, ar AuthorizeRequester, session Session, resp *AuthorizeResponse) error {
+	if f.SessionStateStrategy == nil || !ar.GetGrantedScopes().Has("openid") || ar.GetRedirectURI() == nil {
+		return nil
+	}
+
+	browserState, err := f.SessionStateStrategy.GetBrowserState(ctx, ar, session)
+	if err != nil {
+		return errorsx.WithStack(ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	} else if browserState == "" {
+		return nil
+	}
+
+	sessionState, err := NewSessionState(ar.GetClient().GetID(), ar.GetRedirectURI(), browserState)
+	if err != nil {
+		return errorsx.WithStack(ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+	resp.AddParameter("session_state", sessionState)
+	return nil
+}
+
+// CheckSessionIframeTemplate renders the check_session_iframe as defined in
+// https://openid.net/specs/openid-connect-session-1_0.html#OPiframe
+//
+// The iframe answers messages of the form "client_id session_state" posted by RP iframes with "unchanged",
+// "changed", or "error" by recomputing the session_state from the OP browser state stored in the cookie named
+// .CookieName. The cookie must not be HttpOnly.
+var CheckSessionIframeTemplate = template.Must(template.New("check_session_iframe").Parse(`<!DOCTYPE html>
+<html>
+   <head>
+      <title>check_session_iframe</title>
+      <script>
+         function getBrowserState() {
+            var name = {{ .CookieName }} + "=";
+            var cookies = document.cookie.split(";");
+            for (var i = 0; i < cookies.length; i++) {
+               var c = cookies[i].trim();
+               if (c.indexOf(name) === 0) {
+                  return decodeURIComponent(c.substring(name.length));
+               }
+            }
+            return "";
+         }
+
+         function toHex(buffer) {
+            return Array.prototype.map.call(new Uint8Array(buffer), function (b) {
+               return ("0" + b.toString(16)).slice(-2);
+            }).join("");
+         }
+
+         window.addEventListener("message", function (e) {
+            var respond = function (status) {
+               e.source.postMessage(status, e.origin);
+            };
+
+            if (typeof e.data !== "string") {
+               return respond("error");
+            }
+
+            var parts = e.data.split(" ");
+            var dot = parts.length === 2 ? parts[1].lastIndexOf(".") : -1;
+            if (dot < 0) {
+               return respond("error");
+            }
+
+            var salt = parts[1].substring(dot + 1);
+            var input = parts[0] + " " + e.origin + " " + getBrowserState() + " " + salt;
+            window.crypto.subtle.digest("SHA-256", new TextEncoder().encode(input)).then(function (hash) {
+               respond(toHex(hash) + "." + salt === parts[1] ? "unchanged" : "changed");
+            }, function () {
+               respond("error");
+            });
+         }, false);
+      </script>
+   </head>
+   <body></body>
+</html>`))
+
+// WriteCheckSessionIframe writes the check_session_iframe which reads the OP browser state from the cookie with the
+// given name.
+func WriteCheckSessionIframe(rw http.ResponseWriter, cookieName string) {
+	rw.Header().Set("Content-Type", "text/html;charset=UTF-8")
+	_ = CheckSessionIframeTemplate.Execute(rw, struct {
+		CookieName string
+	}{
+		CookieName: cookieName,
+	})
+}
+
+// FrontChannelLogoutClient is a client which registered a front-channel logout URI, see
+// https://openid.net/specs/openid-connect-frontchannel-1_0.html#RPLogout
+type FrontChannelLogoutClient interface {
+	// GetFrontChannelLogoutURI returns the URI rendered in an iframe by the OpenID Provider on logout.
+	GetFrontChannelLogoutURI() string
+
+	// GetFrontChannelLogoutSessionRequired returns true if the client requires the iss and sid query parameters.
+	GetFrontChannelLogoutSessionRequired() bool
+}
+
+// BuildFrontChannelLogoutURI returns the front-channel logout URI of the client with the iss and sid query parameters
+// appended if the session ID is known. It returns false if the client did not register a front-channel logout URI or
+// requires a session ID which is not known.
+func BuildFrontChannelLogoutURI(client Client, issuer, sessionID string) (string, bool) {
+	c, ok := client.(FrontChannelLogoutClient)
+	if !ok || c.GetFrontChannelLogoutURI() == "" {
+		return "", false
+	}
+
+	u, err := url.Parse(c.GetFrontChannelLogoutURI())
+	if err != nil {
+		return "", false
+	}
+
+	if sessionID == "" && c.GetFrontChannelLogoutSessionRequired() {
+		return "", false
+	} else if sessionID != "" {
+		q := u.Query()
+		q.Set("iss", issuer)
+		q.Set("sid", sessionID)
+		u.RawQuery = q.Encode()
+	}
+	return u.String(), true
+}
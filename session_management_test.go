@@ -0,0 +1,138 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/storage"
+)
+
+type staticSessionStateStrategy string
+
+func (s staticSessionStateStrategy) GetBrowserState(context.Context, AuthorizeRequester, Session) (string, error) {
+	return string(s), nil
+}
+
+func TestSessionState(t *testing.T) {
+	sessionState := ComputeSessionState("my-client", "https://rp.example.com", "browser-state", "salt")
+	assert.True(t, strings.HasSuffix(sessionState, ".salt"))
+	assert.True(t, VerifySessionState("my-client", "https://rp.example.com", "browser-state", sessionState))
+	assert.False(t, VerifySessionState("my-client", "https://rp.example.com", "other-browser-state", sessionState))
+	assert.False(t, VerifySessionState("my-client", "https://other.example.com", "browser-state", sessionState))
+	assert.False(t, VerifySessionState("my-client", "https://rp.example.com", "browser-state", "invalid"))
+
+	u, _ := url.Parse("https://rp.example.com:8443/callback?foo=bar")
+	assert.Equal(t, "https://rp.example.com:8443", GetOrigin(u))
+
+	s1, err := NewSessionState("my-client", u, "browser-state")
+	require.NoError(t, err)
+	s2, err := NewSessionState("my-client", u, "browser-state")
+	require.NoError(t, err)
+	assert.NotEqual(t, s1, s2)
+	assert.True(t, VerifySessionState("my-client", "https://rp.example.com:8443", "browser-state", s1))
+}
+
+func TestNewAuthorizeResponse_SessionState(t *testing.T) {
+	for _, tc := range []struct {
+		d            string
+		browserState string
+		scope        string
+		expect       bool
+	}{
+		{d: "adds session_state to OpenID Connect responses", browserState: "browser-state", scope: "openid", expect: true},
+		{d: "skips OAuth 2.0 responses", browserState: "browser-state", scope: "fosite"},
+		{d: "skips end-users without a session", scope: "openid"},
+	} {
+		t.Run("case="+tc.d, func(t *testing.T) {
+			store := storage.NewExampleStore()
+			config := &compose.Config{SessionStateStrategy: staticSessionStateStrategy(tc.browserState)}
+			f := compose.Compose(
+				config,
+				store,
+				compose.NewOAuth2HMACStrategy(config, []byte("some-secret-thats-random-some-secret-thats-random-"), nil),
+				nil,
+				compose.OAuth2AuthorizeImplicitFactory,
+			)
+			ctx := context.Background()
+
+			r := httptest.NewRequest("GET", "/auth?"+url.Values{
+				"client_id":     {"my-client"},
+				"response_type": {"token"},
+				"redirect_uri":  {"http://localhost:3846/callback"},
+				"state":         {"some-random-state"},
+				"scope":         {tc.scope},
+			}.Encode(), nil)
+			ar, err := f.NewAuthorizeRequest(ctx, r)
+			require.NoError(t, err)
+			ar.GrantScope(tc.scope)
+
+			resp, err := f.NewAuthorizeResponse(ctx, ar, &DefaultSession{Subject: "peter"})
+			require.NoError(t, err)
+
+			sessionState := resp.GetParameters().Get("session_state")
+			if !tc.expect {
+				assert.Empty(t, sessionState)
+				return
+			}
+			assert.True(t, VerifySessionState("my-client", "http://localhost:3846", tc.browserState, sessionState))
+		})
+	}
+}
+
+func TestWriteCheckSessionIframe(t *testing.T) {
+	rw := httptest.NewRecorder()
+	WriteCheckSessionIframe(rw, "op_browser_state")
+	assert.Contains(t, rw.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, rw.Body.String(), `var name = "op_browser_state" + "=";`)
+}
+
+func TestBuildFrontChannelLogoutURI(t *testing.T) {
+	client := &DefaultOpenIDConnectClient{
+		DefaultClient:         &DefaultClient{ID: "rp"},
+		FrontChannelLogoutURI: "https://rp.example.com/logout?foo=bar",
+	}
+
+	uri, ok := BuildFrontChannelLogoutURI(client, "https://op.example.com", "session-id")
+	require.True(t, ok)
+	assert.Equal(t, "https://rp.example.com/logout?foo=bar&iss=https%3A%2F%2Fop.example.com&sid=session-id", uri)
+
+	uri, ok = BuildFrontChannelLogoutURI(client, "https://op.example.com", "")
+	require.True(t, ok)
+	assert.Equal(t, "https://rp.example.com/logout?foo=bar", uri)
+
+	client.FrontChannelLogoutSessionRequired = true
+	_, ok = BuildFrontChannelLogoutURI(client, "https://op.example.com", "")
+	assert.False(t, ok)
+
+	_, ok = BuildFrontChannelLogoutURI(&DefaultClient{ID: "rp"}, "https://op.example.com", "session-id")
+	assert.False(t, ok)
+}
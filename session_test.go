@@ -22,9 +22,13 @@
 package fosite
 
 import (
+	"encoding/json"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestSession(t *testing.T) {
@@ -33,3 +37,100 @@ func TestSession(t *testing.T) {
 	assert.Empty(t, s.GetUsername())
 	assert.Nil(t, s.Clone())
 }
+
+type shallowSession struct {
+	DefaultSession
+}
+
+func (s *shallowSession) Clone() Session {
+	clone := *s
+	return &clone
+}
+
+type lossySession struct {
+	DefaultSession
+	Count int64
+}
+
+func (s *lossySession) Clone() Session {
+	return &lossySession{DefaultSession: *s.DefaultSession.Clone().(*DefaultSession), Count: s.Count}
+}
+
+func (s *lossySession) UnmarshalJSON(b []byte) error {
+	// Loses the count, like a storage which does not persist it.
+	return json.Unmarshal(b, &s.DefaultSession)
+}
+
+func TestCheckSessionClone(t *testing.T) {
+	populated := DefaultSession{
+		ExpiresAt: map[TokenType]time.Time{AccessToken: time.Now().UTC()},
+		Username:  "peter",
+		Subject:   "peter",
+		Extra:     map[string]interface{}{"tenant": "acme", "groups": []interface{}{"admin"}},
+	}
+
+	for k, c := range []struct {
+		d         string
+		session   Session
+		expectErr bool
+	}{
+		{d: "should pass for the default session", session: &populated},
+		{d: "should pass for an empty default session", session: new(DefaultSession)},
+		{d: "should detect the shared maps of a shallow copy", session: &shallowSession{DefaultSession: populated}, expectErr: true},
+		{d: "should detect the field lost in a JSON round-trip", session: &lossySession{DefaultSession: populated, Count: 1}, expectErr: true},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			err := CheckSessionClone(c.session)
+			if c.expectErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestExtraClaims(t *testing.T) {
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{"name":"peter","admin":true,"age":42,"ratio":0.5,"groups":["a","b"],"mixed":["a",1],"address":{"city":"Munich"}}`), &decoded))
+
+	for _, claims := range []ExtraClaims{
+		decoded,
+		GetExtraClaims(&DefaultSession{Extra: map[string]interface{}{"name": "peter", "admin": true, "age": 42, "ratio": 0.5, "groups": []string{"a", "b"}, "mixed": []interface{}{"a", 1}, "address": map[string]interface{}{"city": "Munich"}}}),
+	} {
+		name, ok := claims.String("name")
+		assert.True(t, ok)
+		assert.Equal(t, "peter", name)
+		_, ok = claims.String("age")
+		assert.False(t, ok)
+
+		admin, ok := claims.Bool("admin")
+		assert.True(t, ok)
+		assert.True(t, admin)
+
+		age, ok := claims.Int64("age")
+		assert.True(t, ok)
+		assert.EqualValues(t, 42, age)
+		_, ok = claims.Int64("ratio")
+		assert.False(t, ok)
+
+		ratio, ok := claims.Float64("ratio")
+		assert.True(t, ok)
+		assert.Equal(t, 0.5, ratio)
+
+		groups, ok := claims.Strings("groups")
+		assert.True(t, ok)
+		assert.Equal(t, []string{"a", "b"}, groups)
+		_, ok = claims.Strings("mixed")
+		assert.False(t, ok)
+
+		address, ok := claims.Map("address")
+		assert.True(t, ok)
+		assert.Equal(t, "Munich", address["city"])
+
+		_, ok = claims.String("unknown")
+		assert.False(t, ok)
+	}
+
+	assert.Nil(t, GetExtraClaims(nil))
+}
@@ -0,0 +1,136 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/x/errorsx"
+)
+
+// AuthenticationContextSession is a session which exposes how and when the end-user authenticated. It is
+// implemented by openid.DefaultSession.
+type AuthenticationContextSession interface {
+	// GetAuthenticationContextClassReference returns the acr achieved by the authentication of the end-user.
+	GetAuthenticationContextClassReference() string
+
+	// GetAuthTime returns the time the end-user authenticated.
+	GetAuthTime() time.Time
+}
+
+// AuthenticationRequirements are the authentication requirements of a protected resource as defined in
+// https://tools.ietf.org/html/rfc9470. Store them in the context using AuthenticationRequirementsContextKey to
+// have IntrospectToken enforce them.
+type AuthenticationRequirements struct {
+	// ACRValues lists the acceptable authentication context class references in order of preference. Any acr is
+	// accepted if empty.
+	ACRValues []string
+
+	// MaxAge is the maximum time that may have elapsed since the end-user authenticated. Not enforced if zero.
+	MaxAge time.Duration
+}
+
+// InsufficientUserAuthenticationError is returned if the authentication of the end-user does not satisfy the
+// AuthenticationRequirements of a protected resource. It carries the requirements, so that they can be returned
+// to the client using WWWAuthenticateChallenge.
+type InsufficientUserAuthenticationError struct {
+	*RFC6749Error
+	Requirements AuthenticationRequirements
+}
+
+func (e *InsufficientUserAuthenticationError) Unwrap() error {
+	return e.RFC6749Error
+}
+
+// ValidateAuthenticationRequirements returns an InsufficientUserAuthenticationError if the authentication of the
+// end-user of the request does not satisfy the requirements.
+func ValidateAuthenticationRequirements(requester Requester, requirements AuthenticationRequirements) error {
+	if len(requirements.ACRValues) == 0 && requirements.MaxAge == 0 {
+		return nil
+	}
+
+	session, ok := requester.GetSession().(AuthenticationContextSession)
+	if !ok {
+		return insufficientUserAuthentication(requirements, "The authentication context of the access token is unknown.")
+	}
+
+	if acr := session.GetAuthenticationContextClassReference(); len(requirements.ACRValues) > 0 && !Arguments(requirements.ACRValues).Has(acr) {
+		return insufficientUserAuthentication(requirements, fmt.Sprintf("The authentication context class reference '%s' does not satisfy any of the required values '%s'.", acr, strings.Join(requirements.ACRValues, " ")))
+	}
+
+	if authTime := session.GetAuthTime(); requirements.MaxAge > 0 {
+		if authTime.IsZero() {
+			return insufficientUserAuthentication(requirements, "The time of the end-user authentication is unknown.")
+		} else if time.Now().UTC().After(authTime.Add(requirements.MaxAge)) {
+			return insufficientUserAuthentication(requirements, "The end-user authentication is too old.")
+		}
+	}
+
+	return nil
+}
+
+func insufficientUserAuthentication(requirements AuthenticationRequirements, hint string) error {
+	return errorsx.WithStack(&InsufficientUserAuthenticationError{
+		RFC6749Error: ErrInsufficientUserAuthentication.WithHint(hint),
+		Requirements: requirements,
+	})
+}
+
+// WWWAuthenticateChallenge returns the value of the WWW-Authenticate header a protected resource responds with if
+// the access token of a request is rejected with the error, see https://tools.ietf.org/html/rfc6750#section-3.
+// The acr_values and max_age parameters of https://tools.ietf.org/html/rfc9470#section-3 are added for
+// InsufficientUserAuthenticationError. The scheme is either "Bearer" or "DPoP". If the error is nil, i.e. the
+// request did not contain an access token, the challenge does not contain an error code.
+func WWWAuthenticateChallenge(scheme string, err error) string {
+	if err == nil {
+		return scheme
+	}
+
+	rfcerr := ErrorToRFC6749Error(err)
+	params := []string{
+		challengeParam("error", rfcerr.ErrorField),
+	}
+	if description := rfcerr.GetDescription(); description != "" {
+		params = append(params, challengeParam("error_description", description))
+	}
+
+	var requirementsErr *InsufficientUserAuthenticationError
+	if errors.As(err, &requirementsErr) {
+		if len(requirementsErr.Requirements.ACRValues) > 0 {
+			params = append(params, challengeParam("acr_values", strings.Join(requirementsErr.Requirements.ACRValues, " ")))
+		}
+		if maxAge := requirementsErr.Requirements.MaxAge; maxAge > 0 {
+			params = append(params, challengeParam("max_age", strconv.FormatInt(int64(maxAge/time.Second), 10)))
+		}
+	}
+
+	return scheme + " " + strings.Join(params, ", ")
+}
+
+func challengeParam(name, value string) string {
+	return name + `="` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
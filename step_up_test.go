@@ -0,0 +1,107 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/fosite"
+	"github.com/ory/fosite/handler/openid"
+	"github.com/ory/fosite/internal"
+	"github.com/ory/fosite/token/jwt"
+)
+
+func TestValidateAuthenticationRequirements(t *testing.T) {
+	session := func(acr string, authTime time.Time) Session {
+		return &openid.DefaultSession{Claims: &jwt.IDTokenClaims{AuthenticationContextClassReference: acr, AuthTime: authTime}}
+	}
+
+	for _, c := range []struct {
+		d            string
+		session      Session
+		requirements AuthenticationRequirements
+		expectErr    bool
+	}{
+		{d: "passes without requirements", session: new(DefaultSession)},
+		{d: "fails if the session has no authentication context", session: new(DefaultSession), requirements: AuthenticationRequirements{ACRValues: []string{"urn:mace:incommon:iap:silver"}}, expectErr: true},
+		{d: "passes with a matching acr", session: session("urn:mace:incommon:iap:silver", time.Time{}), requirements: AuthenticationRequirements{ACRValues: []string{"urn:mace:incommon:iap:gold", "urn:mace:incommon:iap:silver"}}},
+		{d: "fails with another acr", session: session("urn:mace:incommon:iap:bronze", time.Time{}), requirements: AuthenticationRequirements{ACRValues: []string{"urn:mace:incommon:iap:silver"}}, expectErr: true},
+		{d: "passes with a recent authentication", session: session("", time.Now().UTC().Add(-time.Minute)), requirements: AuthenticationRequirements{MaxAge: time.Hour}},
+		{d: "fails with an old authentication", session: session("", time.Now().UTC().Add(-time.Hour*2)), requirements: AuthenticationRequirements{MaxAge: time.Hour}, expectErr: true},
+		{d: "fails without auth_time", session: session("", time.Time{}), requirements: AuthenticationRequirements{MaxAge: time.Hour}, expectErr: true},
+	} {
+		t.Run("case="+c.d, func(t *testing.T) {
+			err := ValidateAuthenticationRequirements(NewAccessRequest(c.session), c.requirements)
+			if !c.expectErr {
+				require.NoError(t, err)
+				return
+			}
+
+			require.ErrorIs(t, err, ErrInsufficientUserAuthentication)
+			assert.Equal(t, http.StatusUnauthorized, ErrorToRFC6749Error(err).StatusCode())
+		})
+	}
+}
+
+func TestWWWAuthenticateChallenge(t *testing.T) {
+	assert.Equal(t, "Bearer", WWWAuthenticateChallenge("Bearer", nil))
+	assert.Equal(t, `Bearer error="invalid_token", error_description="Token expired. The token is no longer valid."`, WWWAuthenticateChallenge("Bearer", ErrTokenExpired.WithHint("The token is no longer valid.")))
+
+	err := ValidateAuthenticationRequirements(NewAccessRequest(new(DefaultSession)), AuthenticationRequirements{
+		ACRValues: []string{"myACR", "yourACR"},
+		MaxAge:    5 * time.Minute,
+	})
+	require.Error(t, err)
+	challenge := WWWAuthenticateChallenge("DPoP", err)
+	assert.Contains(t, challenge, `DPoP error="insufficient_user_authentication", error_description="`)
+	assert.Contains(t, challenge, `, acr_values="myACR yourACR", max_age="300"`)
+}
+
+func TestIntrospectTokenWithAuthenticationRequirements(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	validator := internal.NewMockTokenIntrospector(ctrl)
+	validator.EXPECT().IntrospectToken(gomock.Any(), "some-token", gomock.Any(), gomock.Any(), gomock.Any()).Return(AccessToken, nil).AnyTimes()
+	f := &Fosite{TokenIntrospectionHandlers: TokenIntrospectionHandlers{validator}}
+
+	session := &openid.DefaultSession{Claims: &jwt.IDTokenClaims{AuthenticationContextClassReference: "pwd", AuthTime: time.Now().UTC()}}
+
+	_, _, err := f.IntrospectToken(context.Background(), "some-token", AccessToken, session)
+	require.NoError(t, err)
+
+	ctx := context.WithValue(context.Background(), AuthenticationRequirementsContextKey, AuthenticationRequirements{ACRValues: []string{"pwd"}, MaxAge: time.Minute})
+	_, _, err = f.IntrospectToken(ctx, "some-token", AccessToken, session)
+	require.NoError(t, err)
+
+	ctx = context.WithValue(context.Background(), AuthenticationRequirementsContextKey, AuthenticationRequirements{ACRValues: []string{"mfa"}})
+	_, _, err = f.IntrospectToken(ctx, "some-token", AccessToken, session)
+	require.ErrorIs(t, err, ErrInsufficientUserAuthentication)
+	assert.Contains(t, WWWAuthenticateChallenge("Bearer", err), `acr_values="mfa"`)
+}
@@ -21,7 +21,21 @@
 
 package fosite
 
+import "context"
+
 // Storage defines fosite's minimal storage interface.
 type Storage interface {
 	ClientManager
 }
+
+// PARStorage holds pushed authorization requests, see https://tools.ietf.org/html/rfc9126
+type PARStorage interface {
+	// CreatePARSession stores the pushed authorization request context. The requestURI is used to derive the key.
+	CreatePARSession(ctx context.Context, requestURI string, request AuthorizeRequester) error
+
+	// GetPARSession returns the pushed authorization request context or fosite.ErrNotFound if it does not exist.
+	GetPARSession(ctx context.Context, requestURI string) (AuthorizeRequester, error)
+
+	// DeletePARSession deletes the context.
+	DeletePARSession(ctx context.Context, requestURI string) (err error)
+}
@@ -0,0 +1,102 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// Expirable is implemented by storage providers which are able to purge tokens that can no longer be used. A limit
+// of zero or less removes all matching entries.
+type Expirable interface {
+	// FlushInactiveAccessTokens removes access tokens which expired before the given time.
+	FlushInactiveAccessTokens(ctx context.Context, before time.Time, limit int) error
+
+	// FlushInactiveRefreshTokens removes refresh tokens which expired before the given time as well as revoked or
+	// rotated refresh tokens which were issued before the given time. Inactive refresh tokens are kept until then
+	// so that their reuse can still be detected.
+	FlushInactiveRefreshTokens(ctx context.Context, before time.Time, limit int) error
+
+	// FlushExpiredAuthorizeCodes removes authorize codes which expired before the given time.
+	FlushExpiredAuthorizeCodes(ctx context.Context, before time.Time, limit int) error
+}
+
+// Janitor periodically flushes an Expirable storage.
+type Janitor struct {
+	Storage Expirable
+
+	// Interval is the time between two runs.
+	Interval time.Duration
+
+	// Limit is passed to the flush methods and limits the number of entries removed per run and collection.
+	Limit int
+
+	// Retention keeps expired entries for the given period, which can be helpful for auditing or to detect token
+	// reuse. Entries are removed once they expired longer than Retention ago.
+	Retention time.Duration
+
+	// OnError is called with errors returned by the storage. Errors do not stop the janitor.
+	OnError func(err error)
+}
+
+// NewJanitor returns a Janitor flushing the storage every interval.
+func NewJanitor(storage Expirable, interval time.Duration) *Janitor {
+	return &Janitor{Storage: storage, Interval: interval}
+}
+
+// Flush runs all flush methods once. It returns the first error but always attempts all of them.
+func (j *Janitor) Flush(ctx context.Context) error {
+	before := time.Now().UTC().Add(-j.Retention)
+
+	var first error
+	for _, flush := range []func(context.Context, time.Time, int) error{
+		j.Storage.FlushInactiveAccessTokens,
+		j.Storage.FlushInactiveRefreshTokens,
+		j.Storage.FlushExpiredAuthorizeCodes,
+	} {
+		if err := flush(ctx, before, j.Limit); err != nil {
+			if j.OnError != nil {
+				j.OnError(err)
+			}
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+
+// Run flushes the storage every interval until the context is canceled.
+func (j *Janitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_ = j.Flush(ctx)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
+)
+
+var _ Expirable = (*MemoryStore)(nil)
+
+func newExpiringRequest(id string, tokenType fosite.TokenType, expiresAt time.Time) *fosite.Request {
+	r := fosite.NewRequest()
+	r.ID = id
+	r.RequestedAt = time.Now().UTC().Add(-time.Hour)
+	r.Session = &fosite.DefaultSession{ExpiresAt: map[fosite.TokenType]time.Time{tokenType: expiresAt}}
+	return r
+}
+
+func TestMemoryStore_Flush(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+	s := NewMemoryStore()
+
+	require.NoError(t, s.CreateAccessTokenSession(ctx, "expired-at", newExpiringRequest("1", fosite.AccessToken, now.Add(-time.Minute))))
+	require.NoError(t, s.CreateAccessTokenSession(ctx, "valid-at", newExpiringRequest("2", fosite.AccessToken, now.Add(time.Minute))))
+	require.NoError(t, s.CreateRefreshTokenSession(ctx, "expired-rt", newExpiringRequest("3", fosite.RefreshToken, now.Add(-time.Minute))))
+	require.NoError(t, s.CreateRefreshTokenSession(ctx, "revoked-rt", newExpiringRequest("4", fosite.RefreshToken, now.Add(time.Minute))))
+	require.NoError(t, s.RevokeRefreshToken(ctx, "4"))
+	require.NoError(t, s.CreateRefreshTokenSession(ctx, "valid-rt", newExpiringRequest("5", fosite.RefreshToken, time.Time{})))
+	require.NoError(t, s.CreateAuthorizeCodeSession(ctx, "expired-code", newExpiringRequest("6", fosite.AuthorizeCode, now.Add(-time.Minute))))
+	require.NoError(t, s.CreateAuthorizeCodeSession(ctx, "valid-code", newExpiringRequest("7", fosite.AuthorizeCode, now.Add(time.Minute))))
+
+	require.NoError(t, NewJanitor(s, time.Hour).Flush(ctx))
+
+	assert.Len(t, s.AccessTokens, 1)
+	assert.Contains(t, s.AccessTokens, "valid-at")
+	assert.NotContains(t, s.AccessTokenRequestIDs, "1")
+	assert.Len(t, s.RefreshTokens, 1)
+	assert.Contains(t, s.RefreshTokens, "valid-rt")
+	assert.Len(t, s.AuthorizeCodes, 1)
+	assert.Contains(t, s.AuthorizeCodes, "valid-code")
+}
+
+func TestMemoryStore_FlushLimit(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	for _, signature := range []string{"a", "b", "c"} {
+		require.NoError(t, s.CreateAccessTokenSession(ctx, signature, newExpiringRequest(signature, fosite.AccessToken, time.Now().Add(-time.Minute))))
+	}
+
+	require.NoError(t, s.FlushInactiveAccessTokens(ctx, time.Now(), 2))
+	assert.Len(t, s.AccessTokens, 1)
+}
+
+type failingExpirable struct {
+	calls int
+}
+
+func (f *failingExpirable) FlushInactiveAccessTokens(context.Context, time.Time, int) error {
+	f.calls++
+	return errors.New("access")
+}
+
+func (f *failingExpirable) FlushInactiveRefreshTokens(context.Context, time.Time, int) error {
+	f.calls++
+	return nil
+}
+
+func (f *failingExpirable) FlushExpiredAuthorizeCodes(context.Context, time.Time, int) error {
+	f.calls++
+	return errors.New("codes")
+}
+
+func TestJanitor(t *testing.T) {
+	f := new(failingExpirable)
+	var errs []error
+	j := &Janitor{Storage: f, Interval: time.Millisecond, OnError: func(err error) { errs = append(errs, err) }}
+
+	assert.EqualError(t, j.Flush(context.Background()), "access")
+	assert.Equal(t, 3, f.calls)
+	assert.Len(t, errs, 2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	j.OnError = nil
+	j.Run(ctx)
+	assert.True(t, f.calls > 3)
+}
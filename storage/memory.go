@@ -23,6 +23,7 @@ package storage
 
 import (
 	"context"
+	"sort"
 	"sync"
 	"time"
 
@@ -51,6 +52,12 @@ type PublicKeyScopes struct {
 	Scopes []string
 }
 
+// MemoryStore is an in-memory implementation of the fosite storage interfaces.
+//
+// The stores returned by NewMemoryStore and NewExampleStore keep authorize codes, tokens, PKCE and OpenID Connect
+// sessions as well as used JWT IDs in the exported maps, each guarded by a single lock. The store returned by
+// NewShardedMemoryStore keeps them in sharded maps instead, so that concurrent requests for different tokens do not
+// contend for a single lock.
 type MemoryStore struct {
 	Clients         map[string]fosite.Client
 	AuthorizeCodes  map[string]StoreAuthorizeCode
@@ -60,23 +67,40 @@ type MemoryStore struct {
 	PKCES           map[string]fosite.Requester
 	Users           map[string]MemoryUserRelation
 	BlacklistedJTIs map[string]time.Time
+	DeviceCodes     map[string]StoreDeviceCode
+	UserCodes       map[string]StoreUserCode
+	PARSessions     map[string]fosite.AuthorizeRequester
+	CIBASessions    map[string]StoreBackchannelAuthenticationRequest
 	// In-memory request ID to token signatures
 	AccessTokenRequestIDs  map[string]string
 	RefreshTokenRequestIDs map[string]string
+	DeviceCodeRequestIDs   map[string]string
 	// Public keys to check signature in auth grant jwt assertion.
 	IssuerPublicKeys map[string]IssuerPublicKeys
-
-	clientsMutex                sync.RWMutex
-	authorizeCodesMutex         sync.RWMutex
-	idSessionsMutex             sync.RWMutex
-	accessTokensMutex           sync.RWMutex
-	refreshTokensMutex          sync.RWMutex
-	pkcesMutex                  sync.RWMutex
-	usersMutex                  sync.RWMutex
-	blacklistedJTIsMutex        sync.RWMutex
-	accessTokenRequestIDsMutex  sync.RWMutex
-	refreshTokenRequestIDsMutex sync.RWMutex
-	issuerPublicKeysMutex       sync.RWMutex
+	// Public keys to check signature in client authentication jwt assertion, by client ID.
+	ClientAssertionKeys map[string]*jose.JSONWebKeySet
+
+	// sharded is true if the token collections are sharded instead of being backed by the exported maps.
+	sharded         bool
+	collectionsOnce sync.Once
+	collections     tokenCollections
+
+	usedNonces shardedMap // time.Time
+	// In-memory request ID to the time its refresh tokens were last revoked
+	refreshTokenRevocations shardedMap // time.Time
+	consents                shardedMap // fosite.Consent
+	logoutSessions          shardedMap // fosite.LogoutSession
+	preAuthorizedCodes      shardedMap // StorePreAuthorizedCode
+	grants                  shardedMap // fosite.Grant
+
+	clientsMutex              sync.RWMutex
+	usersMutex                sync.RWMutex
+	issuerPublicKeysMutex     sync.RWMutex
+	deviceCodesMutex          sync.RWMutex
+	userCodesMutex            sync.RWMutex
+	deviceCodeRequestIDsMutex sync.RWMutex
+	parSessionsMutex          sync.RWMutex
+	cibaSessionsMutex         sync.RWMutex
 }
 
 func NewMemoryStore() *MemoryStore {
@@ -92,22 +116,119 @@ func NewMemoryStore() *MemoryStore {
 		RefreshTokenRequestIDs: make(map[string]string),
 		BlacklistedJTIs:        make(map[string]time.Time),
 		IssuerPublicKeys:       make(map[string]IssuerPublicKeys),
+		ClientAssertionKeys:    make(map[string]*jose.JSONWebKeySet),
+		DeviceCodes:            make(map[string]StoreDeviceCode),
+		UserCodes:              make(map[string]StoreUserCode),
+		DeviceCodeRequestIDs:   make(map[string]string),
+		PARSessions:            make(map[string]fosite.AuthorizeRequester),
+		CIBASessions:           make(map[string]StoreBackchannelAuthenticationRequest),
+	}
+}
+
+// NewShardedMemoryStore returns a MemoryStore which keeps authorize codes, tokens, PKCE and OpenID Connect sessions
+// as well as used JWT IDs in sharded maps. Use it if the store is under high concurrent load. The exported maps of
+// these collections, e.g. AccessTokens, are not used by the returned store.
+func NewShardedMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		Clients:              make(map[string]fosite.Client),
+		Users:                make(map[string]MemoryUserRelation),
+		IssuerPublicKeys:     make(map[string]IssuerPublicKeys),
+		ClientAssertionKeys:  make(map[string]*jose.JSONWebKeySet),
+		DeviceCodes:          make(map[string]StoreDeviceCode),
+		UserCodes:            make(map[string]StoreUserCode),
+		DeviceCodeRequestIDs: make(map[string]string),
+		PARSessions:          make(map[string]fosite.AuthorizeRequester),
+		CIBASessions:         make(map[string]StoreBackchannelAuthenticationRequest),
+		sharded:              true,
 	}
 }
 
+// tokenCollections are the collections of the MemoryStore which are either sharded or backed by the exported maps.
+type tokenCollections struct {
+	authorizeCodes  memoryCollection // StoreAuthorizeCode
+	idSessions      memoryCollection // fosite.Requester
+	accessTokens    memoryCollection // fosite.Requester
+	refreshTokens   memoryCollection // StoreRefreshToken
+	pkces           memoryCollection // fosite.Requester
+	blacklistedJTIs memoryCollection // time.Time
+	// In-memory request ID to token signatures
+	accessTokenRequestIDs  memoryCollection // string
+	refreshTokenRequestIDs memoryCollection // string
+}
+
+// tokens returns the token collections, which are initialized on first use so that MemoryStore literals keep working.
+func (s *MemoryStore) tokens() *tokenCollections {
+	s.collectionsOnce.Do(func() {
+		if s.sharded {
+			s.collections = tokenCollections{
+				authorizeCodes:         new(shardedMap),
+				idSessions:             new(shardedMap),
+				accessTokens:           new(shardedMap),
+				refreshTokens:          new(shardedMap),
+				pkces:                  new(shardedMap),
+				blacklistedJTIs:        new(shardedMap),
+				accessTokenRequestIDs:  new(shardedMap),
+				refreshTokenRequestIDs: new(shardedMap),
+			}
+			return
+		}
+
+		s.collections = tokenCollections{
+			authorizeCodes:         newLockedMap(&s.AuthorizeCodes),
+			idSessions:             newLockedMap(&s.IDSessions),
+			accessTokens:           newLockedMap(&s.AccessTokens),
+			refreshTokens:          newLockedMap(&s.RefreshTokens),
+			pkces:                  newLockedMap(&s.PKCES),
+			blacklistedJTIs:        newLockedMap(&s.BlacklistedJTIs),
+			accessTokenRequestIDs:  newLockedMap(&s.AccessTokenRequestIDs),
+			refreshTokenRequestIDs: newLockedMap(&s.RefreshTokenRequestIDs),
+		}
+	})
+	return &s.collections
+}
+
 type StoreAuthorizeCode struct {
 	active bool
 	fosite.Requester
 }
 
 type StoreRefreshToken struct {
+	active    bool
+	rotatedAt time.Time
+	fosite.Requester
+}
+
+type StoreDeviceCode struct {
+	active       bool
+	approved     bool
+	denied       bool
+	lastPolledAt time.Time
+	interval     time.Duration
+	fosite.Requester
+}
+
+type StoreBackchannelAuthenticationRequest struct {
+	active       bool
+	approved     bool
+	denied       bool
+	lastPolledAt time.Time
+	interval     time.Duration
+	fosite.Requester
+}
+
+type StorePreAuthorizedCode struct {
+	active          bool
+	txCodeSignature string
+	fosite.Requester
+}
+
+type StoreUserCode struct {
 	active bool
 	fosite.Requester
 }
 
 func NewExampleStore() *MemoryStore {
 	return &MemoryStore{
-		IDSessions: make(map[string]fosite.Requester),
 		Clients: map[string]fosite.Client{
 			"my-client": &fosite.DefaultClient{
 				ID:             "my-client",
@@ -136,41 +257,40 @@ func NewExampleStore() *MemoryStore {
 				Password: "secret",
 			},
 		},
+		IssuerPublicKeys:       map[string]IssuerPublicKeys{},
+		ClientAssertionKeys:    map[string]*jose.JSONWebKeySet{},
 		AuthorizeCodes:         map[string]StoreAuthorizeCode{},
+		PKCES:                  map[string]fosite.Requester{},
 		AccessTokens:           map[string]fosite.Requester{},
 		RefreshTokens:          map[string]StoreRefreshToken{},
-		PKCES:                  map[string]fosite.Requester{},
+		IDSessions:             map[string]fosite.Requester{},
 		AccessTokenRequestIDs:  map[string]string{},
 		RefreshTokenRequestIDs: map[string]string{},
-		IssuerPublicKeys:       map[string]IssuerPublicKeys{},
+		BlacklistedJTIs:        map[string]time.Time{},
+		DeviceCodes:            map[string]StoreDeviceCode{},
+		UserCodes:              map[string]StoreUserCode{},
+		DeviceCodeRequestIDs:   map[string]string{},
+		PARSessions:            map[string]fosite.AuthorizeRequester{},
+		CIBASessions:           map[string]StoreBackchannelAuthenticationRequest{},
 	}
 }
 
 func (s *MemoryStore) CreateOpenIDConnectSession(_ context.Context, authorizeCode string, requester fosite.Requester) error {
-	s.idSessionsMutex.Lock()
-	defer s.idSessionsMutex.Unlock()
-
-	s.IDSessions[authorizeCode] = requester
+	s.tokens().idSessions.store(authorizeCode, requester)
 	return nil
 }
 
 func (s *MemoryStore) GetOpenIDConnectSession(_ context.Context, authorizeCode string, requester fosite.Requester) (fosite.Requester, error) {
-	s.idSessionsMutex.RLock()
-	defer s.idSessionsMutex.RUnlock()
-
-	cl, ok := s.IDSessions[authorizeCode]
+	cl, ok := s.tokens().idSessions.load(authorizeCode)
 	if !ok {
 		return nil, fosite.ErrNotFound
 	}
-	return cl, nil
+	return cl.(fosite.Requester), nil
 }
 
 // DeleteOpenIDConnectSession is not really called from anywhere and it is deprecated.
 func (s *MemoryStore) DeleteOpenIDConnectSession(_ context.Context, authorizeCode string) error {
-	s.idSessionsMutex.Lock()
-	defer s.idSessionsMutex.Unlock()
-
-	delete(s.IDSessions, authorizeCode)
+	s.tokens().idSessions.delete(authorizeCode)
 	return nil
 }
 
@@ -185,52 +305,64 @@ func (s *MemoryStore) GetClient(_ context.Context, id string) (fosite.Client, er
 	return cl, nil
 }
 
-func (s *MemoryStore) ClientAssertionJWTValid(_ context.Context, jti string) error {
-	s.blacklistedJTIsMutex.RLock()
-	defer s.blacklistedJTIsMutex.RUnlock()
+func (s *MemoryStore) CreateClient(_ context.Context, client fosite.Client) error {
+	s.clientsMutex.Lock()
+	defer s.clientsMutex.Unlock()
 
-	if exp, exists := s.BlacklistedJTIs[jti]; exists && exp.After(time.Now()) {
-		return fosite.ErrJTIKnown
-	}
+	s.Clients[client.GetID()] = client
+	return nil
+}
 
+func (s *MemoryStore) UpdateClient(_ context.Context, client fosite.Client) error {
+	s.clientsMutex.Lock()
+	defer s.clientsMutex.Unlock()
+
+	if _, ok := s.Clients[client.GetID()]; !ok {
+		return fosite.ErrNotFound
+	}
+	s.Clients[client.GetID()] = client
 	return nil
 }
 
-func (s *MemoryStore) SetClientAssertionJWT(_ context.Context, jti string, exp time.Time) error {
-	s.blacklistedJTIsMutex.Lock()
-	defer s.blacklistedJTIsMutex.Unlock()
+func (s *MemoryStore) DeleteClient(_ context.Context, id string) error {
+	s.clientsMutex.Lock()
+	defer s.clientsMutex.Unlock()
 
-	// delete expired jtis
-	for j, e := range s.BlacklistedJTIs {
-		if e.Before(time.Now()) {
-			delete(s.BlacklistedJTIs, j)
-		}
+	if _, ok := s.Clients[id]; !ok {
+		return fosite.ErrNotFound
 	}
+	delete(s.Clients, id)
+	return nil
+}
 
-	if _, exists := s.BlacklistedJTIs[jti]; exists {
+func (s *MemoryStore) ClientAssertionJWTValid(_ context.Context, jti string) error {
+	if exp, exists := s.tokens().blacklistedJTIs.load(jti); exists && exp.(time.Time).After(time.Now()) {
 		return fosite.ErrJTIKnown
 	}
 
-	s.BlacklistedJTIs[jti] = exp
 	return nil
 }
 
-func (s *MemoryStore) CreateAuthorizeCodeSession(_ context.Context, code string, req fosite.Requester) error {
-	s.authorizeCodesMutex.Lock()
-	defer s.authorizeCodesMutex.Unlock()
+func (s *MemoryStore) SetClientAssertionJWT(_ context.Context, jti string, exp time.Time) error {
+	// delete expired jtis of the same shard
+	now := time.Now()
+	if !s.tokens().blacklistedJTIs.storeIf(jti, exp, func(e interface{}) bool { return !e.(time.Time).Before(now) }) {
+		return fosite.ErrJTIKnown
+	}
+	return nil
+}
 
-	s.AuthorizeCodes[code] = StoreAuthorizeCode{active: true, Requester: req}
+func (s *MemoryStore) CreateAuthorizeCodeSession(_ context.Context, code string, req fosite.Requester) error {
+	s.tokens().authorizeCodes.store(code, StoreAuthorizeCode{active: true, Requester: req})
 	return nil
 }
 
 func (s *MemoryStore) GetAuthorizeCodeSession(_ context.Context, code string, _ fosite.Session) (fosite.Requester, error) {
-	s.authorizeCodesMutex.RLock()
-	defer s.authorizeCodesMutex.RUnlock()
-
-	rel, ok := s.AuthorizeCodes[code]
+	value, ok := s.tokens().authorizeCodes.load(code)
 	if !ok {
 		return nil, fosite.ErrNotFound
 	}
+	rel := value.(StoreAuthorizeCode)
 	if !rel.active {
 		return rel, fosite.ErrInvalidatedAuthorizeCode
 	}
@@ -239,98 +371,67 @@ func (s *MemoryStore) GetAuthorizeCodeSession(_ context.Context, code string, _
 }
 
 func (s *MemoryStore) InvalidateAuthorizeCodeSession(ctx context.Context, code string) error {
-	s.authorizeCodesMutex.Lock()
-	defer s.authorizeCodesMutex.Unlock()
-
-	rel, ok := s.AuthorizeCodes[code]
-	if !ok {
+	if !s.tokens().authorizeCodes.update(code, func(value interface{}) interface{} {
+		rel := value.(StoreAuthorizeCode)
+		rel.active = false
+		return rel
+	}) {
 		return fosite.ErrNotFound
 	}
-	rel.active = false
-	s.AuthorizeCodes[code] = rel
 	return nil
 }
 
 func (s *MemoryStore) CreatePKCERequestSession(_ context.Context, code string, req fosite.Requester) error {
-	s.pkcesMutex.Lock()
-	defer s.pkcesMutex.Unlock()
-
-	s.PKCES[code] = req
+	s.tokens().pkces.store(code, req)
 	return nil
 }
 
 func (s *MemoryStore) GetPKCERequestSession(_ context.Context, code string, _ fosite.Session) (fosite.Requester, error) {
-	s.pkcesMutex.RLock()
-	defer s.pkcesMutex.RUnlock()
-
-	rel, ok := s.PKCES[code]
+	rel, ok := s.tokens().pkces.load(code)
 	if !ok {
 		return nil, fosite.ErrNotFound
 	}
-	return rel, nil
+	return rel.(fosite.Requester), nil
 }
 
 func (s *MemoryStore) DeletePKCERequestSession(_ context.Context, code string) error {
-	s.pkcesMutex.Lock()
-	defer s.pkcesMutex.Unlock()
-
-	delete(s.PKCES, code)
+	s.tokens().pkces.delete(code)
 	return nil
 }
 
 func (s *MemoryStore) CreateAccessTokenSession(_ context.Context, signature string, req fosite.Requester) error {
-	// We first lock accessTokenRequestIDsMutex and then accessTokensMutex because this is the same order
-	// locking happens in RevokeAccessToken and using the same order prevents deadlocks.
-	s.accessTokenRequestIDsMutex.Lock()
-	defer s.accessTokenRequestIDsMutex.Unlock()
-	s.accessTokensMutex.Lock()
-	defer s.accessTokensMutex.Unlock()
-
-	s.AccessTokens[signature] = req
-	s.AccessTokenRequestIDs[req.GetID()] = signature
+	// The token is stored before its request ID so that RevokeAccessToken never finds a dangling signature.
+	s.tokens().accessTokens.store(signature, req)
+	s.tokens().accessTokenRequestIDs.store(req.GetID(), signature)
 	return nil
 }
 
 func (s *MemoryStore) GetAccessTokenSession(_ context.Context, signature string, _ fosite.Session) (fosite.Requester, error) {
-	s.accessTokensMutex.RLock()
-	defer s.accessTokensMutex.RUnlock()
-
-	rel, ok := s.AccessTokens[signature]
+	rel, ok := s.tokens().accessTokens.load(signature)
 	if !ok {
 		return nil, fosite.ErrNotFound
 	}
-	return rel, nil
+	return rel.(fosite.Requester), nil
 }
 
 func (s *MemoryStore) DeleteAccessTokenSession(_ context.Context, signature string) error {
-	s.accessTokensMutex.Lock()
-	defer s.accessTokensMutex.Unlock()
-
-	delete(s.AccessTokens, signature)
+	s.tokens().accessTokens.delete(signature)
 	return nil
 }
 
 func (s *MemoryStore) CreateRefreshTokenSession(_ context.Context, signature string, req fosite.Requester) error {
-	// We first lock refreshTokenRequestIDsMutex and then refreshTokensMutex because this is the same order
-	// locking happens in RevokeRefreshToken and using the same order prevents deadlocks.
-	s.refreshTokenRequestIDsMutex.Lock()
-	defer s.refreshTokenRequestIDsMutex.Unlock()
-	s.refreshTokensMutex.Lock()
-	defer s.refreshTokensMutex.Unlock()
-
-	s.RefreshTokens[signature] = StoreRefreshToken{active: true, Requester: req}
-	s.RefreshTokenRequestIDs[req.GetID()] = signature
+	// The token is stored before its request ID so that RevokeRefreshToken never finds a dangling signature.
+	s.tokens().refreshTokens.store(signature, StoreRefreshToken{active: true, Requester: req})
+	s.tokens().refreshTokenRequestIDs.store(req.GetID(), signature)
 	return nil
 }
 
 func (s *MemoryStore) GetRefreshTokenSession(_ context.Context, signature string, _ fosite.Session) (fosite.Requester, error) {
-	s.refreshTokensMutex.RLock()
-	defer s.refreshTokensMutex.RUnlock()
-
-	rel, ok := s.RefreshTokens[signature]
+	value, ok := s.tokens().refreshTokens.load(signature)
 	if !ok {
 		return nil, fosite.ErrNotFound
 	}
+	rel := value.(StoreRefreshToken)
 	if !rel.active {
 		return rel, fosite.ErrInactiveToken
 	}
@@ -338,13 +439,50 @@ func (s *MemoryStore) GetRefreshTokenSession(_ context.Context, signature string
 }
 
 func (s *MemoryStore) DeleteRefreshTokenSession(_ context.Context, signature string) error {
-	s.refreshTokensMutex.Lock()
-	defer s.refreshTokensMutex.Unlock()
+	s.tokens().refreshTokens.delete(signature)
+	return nil
+}
 
-	delete(s.RefreshTokens, signature)
+func (s *MemoryStore) RotateRefreshToken(_ context.Context, signature string, rotatedAt time.Time) error {
+	if !s.tokens().refreshTokens.update(signature, func(value interface{}) interface{} {
+		rel := value.(StoreRefreshToken)
+		rel.rotatedAt = rotatedAt
+		return rel
+	}) {
+		return fosite.ErrNotFound
+	}
 	return nil
 }
 
+func (s *MemoryStore) GetRefreshTokenRotatedAt(_ context.Context, signature string) (time.Time, error) {
+	value, ok := s.tokens().refreshTokens.load(signature)
+	if !ok || value.(StoreRefreshToken).rotatedAt.IsZero() {
+		return time.Time{}, fosite.ErrNotFound
+	}
+	return value.(StoreRefreshToken).rotatedAt, nil
+}
+
+// GetActiveRefreshTokenSessions returns one request per grant, as rotated refresh tokens share the request ID.
+func (s *MemoryStore) GetActiveRefreshTokenSessions(_ context.Context, clientID string, subject string) ([]fosite.Requester, error) {
+	var requests []fosite.Requester
+	s.tokens().refreshTokens.each(func(signature string, value interface{}) {
+		rel := value.(StoreRefreshToken)
+		if !rel.active || rel.GetClient().GetID() != clientID {
+			return
+		} else if subject != "" && (rel.GetSession() == nil || rel.GetSession().GetSubject() != subject) {
+			return
+		} else if latest, ok := s.tokens().refreshTokenRequestIDs.load(rel.GetID()); !ok || latest.(string) != signature {
+			return
+		}
+		requests = append(requests, rel.Requester)
+	})
+
+	sort.Slice(requests, func(i, j int) bool {
+		return requests[i].GetRequestedAt().Before(requests[j].GetRequestedAt())
+	})
+	return requests, nil
+}
+
 func (s *MemoryStore) Authenticate(_ context.Context, name string, secret string) error {
 	s.usersMutex.RLock()
 	defer s.usersMutex.RUnlock()
@@ -360,32 +498,69 @@ func (s *MemoryStore) Authenticate(_ context.Context, name string, secret string
 }
 
 func (s *MemoryStore) RevokeRefreshToken(ctx context.Context, requestID string) error {
-	s.refreshTokenRequestIDsMutex.Lock()
-	defer s.refreshTokenRequestIDsMutex.Unlock()
-
-	if signature, exists := s.RefreshTokenRequestIDs[requestID]; exists {
-		rel, ok := s.RefreshTokens[signature]
-		if !ok {
+	s.refreshTokenRevocations.store(requestID, time.Now().UTC())
+	if signature, exists := s.tokens().refreshTokenRequestIDs.load(requestID); exists {
+		if !s.tokens().refreshTokens.update(signature.(string), func(value interface{}) interface{} {
+			rel := value.(StoreRefreshToken)
+			rel.active = false
+			return rel
+		}) {
 			return fosite.ErrNotFound
 		}
-		rel.active = false
-		s.RefreshTokens[signature] = rel
 	}
 	return nil
 }
 
-func (s *MemoryStore) RevokeAccessToken(ctx context.Context, requestID string) error {
-	s.accessTokenRequestIDsMutex.RLock()
-	defer s.accessTokenRequestIDsMutex.RUnlock()
+func (s *MemoryStore) GetRefreshTokenRevokedAt(_ context.Context, requestID string) (time.Time, error) {
+	value, ok := s.refreshTokenRevocations.load(requestID)
+	if !ok {
+		return time.Time{}, fosite.ErrNotFound
+	}
+	return value.(time.Time), nil
+}
 
-	if signature, exists := s.AccessTokenRequestIDs[requestID]; exists {
-		if err := s.DeleteAccessTokenSession(ctx, signature); err != nil {
+func (s *MemoryStore) RevokeAccessToken(ctx context.Context, requestID string) error {
+	if signature, exists := s.tokens().accessTokenRequestIDs.load(requestID); exists {
+		if err := s.DeleteAccessTokenSession(ctx, signature.(string)); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+func (s *MemoryStore) RevokeTokensByAuthorizeCode(_ context.Context, signature string) error {
+	value, ok := s.tokens().authorizeCodes.load(signature)
+	if !ok {
+		return fosite.ErrNotFound
+	}
+	requestID := value.(StoreAuthorizeCode).GetID()
+	s.refreshTokenRevocations.store(requestID, time.Now().UTC())
+
+	var accessTokens, refreshTokens []string
+	s.tokens().accessTokens.each(func(signature string, value interface{}) {
+		if value.(fosite.Requester).GetID() == requestID {
+			accessTokens = append(accessTokens, signature)
+		}
+	})
+	s.tokens().refreshTokens.each(func(signature string, value interface{}) {
+		if value.(StoreRefreshToken).GetID() == requestID {
+			refreshTokens = append(refreshTokens, signature)
+		}
+	})
+
+	for _, signature := range accessTokens {
+		s.tokens().accessTokens.delete(signature)
+	}
+	for _, signature := range refreshTokens {
+		s.tokens().refreshTokens.update(signature, func(value interface{}) interface{} {
+			rel := value.(StoreRefreshToken)
+			rel.active = false
+			return rel
+		})
+	}
+	return nil
+}
+
 func (s *MemoryStore) GetPublicKey(ctx context.Context, issuer string, subject string, keyId string) (*jose.JSONWebKey, error) {
 	s.issuerPublicKeysMutex.RLock()
 	defer s.issuerPublicKeysMutex.RUnlock()
@@ -437,6 +612,25 @@ func (s *MemoryStore) GetPublicKeyScopes(ctx context.Context, issuer string, sub
 	return nil, fosite.ErrNotFound
 }
 
+func (s *MemoryStore) IsAssertionUsed(ctx context.Context, id string) (bool, error) {
+	return s.IsJWTUsed(ctx, id)
+}
+
+func (s *MemoryStore) MarkAssertionUsedForTime(ctx context.Context, id string, exp time.Time) error {
+	return s.SetClientAssertionJWT(ctx, id, exp)
+}
+
+func (s *MemoryStore) GetClientAssertionKeys(ctx context.Context, clientID string) (*jose.JSONWebKeySet, error) {
+	s.issuerPublicKeysMutex.RLock()
+	defer s.issuerPublicKeysMutex.RUnlock()
+
+	if keys, ok := s.ClientAssertionKeys[clientID]; ok {
+		return keys, nil
+	}
+
+	return nil, fosite.ErrNotFound
+}
+
 func (s *MemoryStore) IsJWTUsed(ctx context.Context, jti string) (bool, error) {
 	err := s.ClientAssertionJWTValid(ctx, jti)
 	if err != nil {
@@ -449,3 +643,446 @@ func (s *MemoryStore) IsJWTUsed(ctx context.Context, jti string) (bool, error) {
 func (s *MemoryStore) MarkJWTUsedForTime(ctx context.Context, jti string, exp time.Time) error {
 	return s.SetClientAssertionJWT(ctx, jti, exp)
 }
+
+func (s *MemoryStore) IsNonceUsed(_ context.Context, clientID string, nonce string) (bool, error) {
+	exp, exists := s.usedNonces.load(clientID + "|" + nonce)
+	return exists && exp.(time.Time).After(time.Now()), nil
+}
+
+func (s *MemoryStore) MarkNonceUsedForTime(_ context.Context, clientID string, nonce string, exp time.Time) error {
+	now := time.Now()
+	if !s.usedNonces.storeIf(clientID+"|"+nonce, exp, func(e interface{}) bool { return !e.(time.Time).Before(now) }) {
+		return fosite.ErrInvalidRequest.WithHint("Parameter 'nonce' has already been used.")
+	}
+	return nil
+}
+
+func (s *MemoryStore) CreateDeviceCodeSession(_ context.Context, signature string, req fosite.Requester) error {
+	// We first lock deviceCodeRequestIDsMutex and then deviceCodesMutex because this is the same order
+	// locking happens in ApproveUserCodeSession and using the same order prevents deadlocks.
+	s.deviceCodeRequestIDsMutex.Lock()
+	defer s.deviceCodeRequestIDsMutex.Unlock()
+	s.deviceCodesMutex.Lock()
+	defer s.deviceCodesMutex.Unlock()
+
+	s.DeviceCodes[signature] = StoreDeviceCode{active: true, Requester: req}
+	s.DeviceCodeRequestIDs[req.GetID()] = signature
+	return nil
+}
+
+func (s *MemoryStore) GetDeviceCodeSession(_ context.Context, signature string, _ fosite.Session) (fosite.Requester, error) {
+	s.deviceCodesMutex.RLock()
+	defer s.deviceCodesMutex.RUnlock()
+
+	rel, ok := s.DeviceCodes[signature]
+	if !ok {
+		return nil, fosite.ErrNotFound
+	}
+	if !rel.active {
+		return rel, fosite.ErrInvalidatedDeviceCode
+	}
+	if rel.denied {
+		return rel, fosite.ErrAccessDenied
+	}
+	if !rel.approved {
+		return rel, fosite.ErrAuthorizationPending
+	}
+	return rel, nil
+}
+
+func (s *MemoryStore) InvalidateDeviceCodeSession(_ context.Context, signature string) error {
+	s.deviceCodesMutex.Lock()
+	defer s.deviceCodesMutex.Unlock()
+
+	rel, ok := s.DeviceCodes[signature]
+	if !ok {
+		return fosite.ErrNotFound
+	}
+	rel.active = false
+	s.DeviceCodes[signature] = rel
+	return nil
+}
+
+func (s *MemoryStore) TouchDeviceCodeSession(_ context.Context, signature string, now time.Time) (time.Time, time.Duration, error) {
+	s.deviceCodesMutex.Lock()
+	defer s.deviceCodesMutex.Unlock()
+
+	rel, ok := s.DeviceCodes[signature]
+	if !ok {
+		return time.Time{}, 0, fosite.ErrNotFound
+	}
+	lastPolledAt := rel.lastPolledAt
+	rel.lastPolledAt = now
+	s.DeviceCodes[signature] = rel
+	return lastPolledAt, rel.interval, nil
+}
+
+func (s *MemoryStore) SlowDownDeviceCodeSession(_ context.Context, signature string, interval time.Duration) error {
+	s.deviceCodesMutex.Lock()
+	defer s.deviceCodesMutex.Unlock()
+
+	rel, ok := s.DeviceCodes[signature]
+	if !ok {
+		return fosite.ErrNotFound
+	}
+	rel.interval = interval
+	s.DeviceCodes[signature] = rel
+	return nil
+}
+
+func (s *MemoryStore) CreatePreAuthorizedCodeSession(_ context.Context, signature string, txCodeSignature string, req fosite.Requester) error {
+	s.preAuthorizedCodes.store(signature, StorePreAuthorizedCode{active: true, txCodeSignature: txCodeSignature, Requester: req})
+	return nil
+}
+
+func (s *MemoryStore) GetPreAuthorizedCodeSession(_ context.Context, signature string, _ fosite.Session) (fosite.Requester, string, error) {
+	value, ok := s.preAuthorizedCodes.load(signature)
+	if !ok {
+		return nil, "", fosite.ErrNotFound
+	}
+	rel := value.(StorePreAuthorizedCode)
+	if !rel.active {
+		return rel, rel.txCodeSignature, fosite.ErrInvalidatedPreAuthorizedCode
+	}
+	return rel, rel.txCodeSignature, nil
+}
+
+func (s *MemoryStore) InvalidatePreAuthorizedCodeSession(_ context.Context, signature string) error {
+	if !s.preAuthorizedCodes.update(signature, func(value interface{}) interface{} {
+		rel := value.(StorePreAuthorizedCode)
+		rel.active = false
+		return rel
+	}) {
+		return fosite.ErrNotFound
+	}
+	return nil
+}
+
+func (s *MemoryStore) CreateUserCodeSession(_ context.Context, signature string, req fosite.Requester) error {
+	s.userCodesMutex.Lock()
+	defer s.userCodesMutex.Unlock()
+
+	s.UserCodes[signature] = StoreUserCode{active: true, Requester: req}
+	return nil
+}
+
+func (s *MemoryStore) GetUserCodeSession(_ context.Context, signature string, _ fosite.Session) (fosite.Requester, error) {
+	s.userCodesMutex.RLock()
+	defer s.userCodesMutex.RUnlock()
+
+	rel, ok := s.UserCodes[signature]
+	if !ok {
+		return nil, fosite.ErrNotFound
+	}
+	if !rel.active {
+		return rel, fosite.ErrInvalidatedUserCode
+	}
+	return rel, nil
+}
+
+func (s *MemoryStore) ApproveUserCodeSession(_ context.Context, signature string, req fosite.Requester) error {
+	return s.resolveUserCodeSession(signature, func(rel *StoreDeviceCode) {
+		rel.approved = true
+		rel.Requester = req
+	})
+}
+
+func (s *MemoryStore) DenyUserCodeSession(_ context.Context, signature string) error {
+	return s.resolveUserCodeSession(signature, func(rel *StoreDeviceCode) {
+		rel.denied = true
+	})
+}
+
+// resolveUserCodeSession invalidates the user code and applies the end user's decision to the device code
+// sharing the same request ID.
+func (s *MemoryStore) resolveUserCodeSession(signature string, resolve func(rel *StoreDeviceCode)) error {
+	s.userCodesMutex.Lock()
+	defer s.userCodesMutex.Unlock()
+	s.deviceCodeRequestIDsMutex.RLock()
+	defer s.deviceCodeRequestIDsMutex.RUnlock()
+	s.deviceCodesMutex.Lock()
+	defer s.deviceCodesMutex.Unlock()
+
+	userCode, ok := s.UserCodes[signature]
+	if !ok {
+		return fosite.ErrNotFound
+	}
+	if !userCode.active {
+		return fosite.ErrInvalidatedUserCode
+	}
+
+	deviceCodeSignature, ok := s.DeviceCodeRequestIDs[userCode.GetID()]
+	if !ok {
+		return fosite.ErrNotFound
+	}
+	deviceCode, ok := s.DeviceCodes[deviceCodeSignature]
+	if !ok {
+		return fosite.ErrNotFound
+	}
+
+	resolve(&deviceCode)
+	s.DeviceCodes[deviceCodeSignature] = deviceCode
+
+	userCode.active = false
+	s.UserCodes[signature] = userCode
+	return nil
+}
+
+func (s *MemoryStore) CreatePARSession(_ context.Context, requestURI string, request fosite.AuthorizeRequester) error {
+	s.parSessionsMutex.Lock()
+	defer s.parSessionsMutex.Unlock()
+
+	s.PARSessions[requestURI] = request
+	return nil
+}
+
+func (s *MemoryStore) GetPARSession(_ context.Context, requestURI string) (fosite.AuthorizeRequester, error) {
+	s.parSessionsMutex.RLock()
+	defer s.parSessionsMutex.RUnlock()
+
+	r, ok := s.PARSessions[requestURI]
+	if !ok {
+		return nil, fosite.ErrNotFound
+	}
+	return r, nil
+}
+
+func (s *MemoryStore) DeletePARSession(_ context.Context, requestURI string) error {
+	s.parSessionsMutex.Lock()
+	defer s.parSessionsMutex.Unlock()
+
+	delete(s.PARSessions, requestURI)
+	return nil
+}
+
+func (s *MemoryStore) CreateBackchannelAuthenticationSession(_ context.Context, signature string, req fosite.Requester) error {
+	s.cibaSessionsMutex.Lock()
+	defer s.cibaSessionsMutex.Unlock()
+
+	s.CIBASessions[signature] = StoreBackchannelAuthenticationRequest{active: true, Requester: req}
+	return nil
+}
+
+func (s *MemoryStore) GetBackchannelAuthenticationSession(_ context.Context, signature string, _ fosite.Session) (fosite.Requester, error) {
+	s.cibaSessionsMutex.RLock()
+	defer s.cibaSessionsMutex.RUnlock()
+
+	rel, ok := s.CIBASessions[signature]
+	if !ok {
+		return nil, fosite.ErrNotFound
+	}
+	if !rel.active {
+		return rel, fosite.ErrInvalidatedAuthRequestID
+	}
+	if rel.denied {
+		return rel, fosite.ErrAccessDenied
+	}
+	if !rel.approved {
+		return rel, fosite.ErrAuthorizationPending
+	}
+	return rel, nil
+}
+
+func (s *MemoryStore) ApproveBackchannelAuthenticationSession(_ context.Context, signature string, req fosite.Requester) error {
+	return s.resolveBackchannelAuthenticationSession(signature, func(rel *StoreBackchannelAuthenticationRequest) {
+		rel.approved = true
+		rel.Requester = req
+	})
+}
+
+func (s *MemoryStore) DenyBackchannelAuthenticationSession(_ context.Context, signature string) error {
+	return s.resolveBackchannelAuthenticationSession(signature, func(rel *StoreBackchannelAuthenticationRequest) {
+		rel.denied = true
+	})
+}
+
+// resolveBackchannelAuthenticationSession applies the end user's decision to a pending backchannel authentication
+// request.
+func (s *MemoryStore) resolveBackchannelAuthenticationSession(signature string, resolve func(rel *StoreBackchannelAuthenticationRequest)) error {
+	s.cibaSessionsMutex.Lock()
+	defer s.cibaSessionsMutex.Unlock()
+
+	rel, ok := s.CIBASessions[signature]
+	if !ok {
+		return fosite.ErrNotFound
+	}
+	if !rel.active || rel.approved || rel.denied {
+		return fosite.ErrInvalidatedAuthRequestID
+	}
+
+	resolve(&rel)
+	s.CIBASessions[signature] = rel
+	return nil
+}
+
+func (s *MemoryStore) InvalidateBackchannelAuthenticationSession(_ context.Context, signature string) error {
+	s.cibaSessionsMutex.Lock()
+	defer s.cibaSessionsMutex.Unlock()
+
+	rel, ok := s.CIBASessions[signature]
+	if !ok {
+		return fosite.ErrNotFound
+	}
+	rel.active = false
+	s.CIBASessions[signature] = rel
+	return nil
+}
+
+func (s *MemoryStore) TouchBackchannelAuthenticationSession(_ context.Context, signature string, now time.Time) (time.Time, time.Duration, error) {
+	s.cibaSessionsMutex.Lock()
+	defer s.cibaSessionsMutex.Unlock()
+
+	rel, ok := s.CIBASessions[signature]
+	if !ok {
+		return time.Time{}, 0, fosite.ErrNotFound
+	}
+	lastPolledAt := rel.lastPolledAt
+	rel.lastPolledAt = now
+	s.CIBASessions[signature] = rel
+	return lastPolledAt, rel.interval, nil
+}
+
+func (s *MemoryStore) SlowDownBackchannelAuthenticationSession(_ context.Context, signature string, interval time.Duration) error {
+	s.cibaSessionsMutex.Lock()
+	defer s.cibaSessionsMutex.Unlock()
+
+	rel, ok := s.CIBASessions[signature]
+	if !ok {
+		return fosite.ErrNotFound
+	}
+	rel.interval = interval
+	s.CIBASessions[signature] = rel
+	return nil
+}
+
+func (s *MemoryStore) FlushInactiveAccessTokens(_ context.Context, before time.Time, limit int) error {
+	removed := s.tokens().accessTokens.deleteIf(limit, func(_ string, value interface{}) bool {
+		return isExpired(value.(fosite.Requester), fosite.AccessToken, before)
+	})
+	for signature, value := range removed {
+		s.tokens().accessTokenRequestIDs.deleteWhen(value.(fosite.Requester).GetID(), isSignature(signature))
+	}
+	return nil
+}
+
+func (s *MemoryStore) FlushInactiveRefreshTokens(_ context.Context, before time.Time, limit int) error {
+	removed := s.tokens().refreshTokens.deleteIf(limit, func(_ string, value interface{}) bool {
+		rel := value.(StoreRefreshToken)
+		inactive := !rel.active && rel.GetRequestedAt().Before(before)
+		return inactive || isExpired(rel.Requester, fosite.RefreshToken, before)
+	})
+	for signature, value := range removed {
+		s.tokens().refreshTokenRequestIDs.deleteWhen(value.(StoreRefreshToken).GetID(), isSignature(signature))
+	}
+	return nil
+}
+
+func (s *MemoryStore) FlushExpiredAuthorizeCodes(_ context.Context, before time.Time, limit int) error {
+	s.tokens().authorizeCodes.deleteIf(limit, func(_ string, value interface{}) bool {
+		return isExpired(value.(StoreAuthorizeCode).Requester, fosite.AuthorizeCode, before)
+	})
+	return nil
+}
+
+// isSignature matches request ID index entries which still point to the given signature.
+func isSignature(signature string) func(value interface{}) bool {
+	return func(value interface{}) bool {
+		return value.(string) == signature
+	}
+}
+
+// isExpired returns true if the token expired before the given time. Tokens without expiry never expire.
+func isExpired(req fosite.Requester, tokenType fosite.TokenType, before time.Time) bool {
+	if req.GetSession() == nil {
+		return false
+	}
+	expiresAt := req.GetSession().GetExpiresAt(tokenType)
+	return !expiresAt.IsZero() && expiresAt.Before(before)
+}
+
+func consentKey(clientID, subject string) string {
+	return clientID + "\x00" + subject
+}
+
+func (s *MemoryStore) GetConsent(_ context.Context, clientID, subject string) (*fosite.Consent, error) {
+	value, ok := s.consents.load(consentKey(clientID, subject))
+	if !ok {
+		return nil, fosite.ErrNotFound
+	}
+	consent := value.(fosite.Consent)
+	return &consent, nil
+}
+
+func (s *MemoryStore) SetConsent(_ context.Context, consent *fosite.Consent) error {
+	s.consents.store(consentKey(consent.ClientID, consent.Subject), *consent)
+	return nil
+}
+
+func (s *MemoryStore) RevokeConsent(_ context.Context, clientID, subject string) error {
+	s.consents.delete(consentKey(clientID, subject))
+	return nil
+}
+
+func (s *MemoryStore) GetGrant(_ context.Context, id string) (*fosite.Grant, error) {
+	value, ok := s.grants.load(id)
+	if !ok {
+		return nil, fosite.ErrNotFound
+	}
+	grant := value.(fosite.Grant)
+	return &grant, nil
+}
+
+func (s *MemoryStore) SetGrant(_ context.Context, grant *fosite.Grant) error {
+	s.grants.store(grant.ID, *grant)
+	return nil
+}
+
+func (s *MemoryStore) RevokeGrant(ctx context.Context, id string) error {
+	value, ok := s.grants.load(id)
+	if !ok {
+		return fosite.ErrNotFound
+	}
+	for _, requestID := range value.(fosite.Grant).RequestIDs {
+		if err := s.RevokeAccessToken(ctx, requestID); err != nil {
+			return err
+		} else if err := s.RevokeRefreshToken(ctx, requestID); err != nil {
+			return err
+		}
+	}
+	s.grants.delete(id)
+	return nil
+}
+
+func (s *MemoryStore) AddLogoutSessionClient(_ context.Context, sessionID, subject, clientID string) error {
+	s.logoutSessions.upsert(sessionID, func(value interface{}, ok bool) interface{} {
+		session := fosite.LogoutSession{ID: sessionID, Subject: subject}
+		if ok {
+			session = value.(fosite.LogoutSession)
+		}
+		session.UpdatedAt = time.Now().UTC()
+
+		clientIDs := make([]string, 0, len(session.ClientIDs)+1)
+		for _, id := range session.ClientIDs {
+			if id != clientID {
+				clientIDs = append(clientIDs, id)
+			}
+		}
+		session.ClientIDs = append(clientIDs, clientID)
+		return session
+	})
+	return nil
+}
+
+func (s *MemoryStore) GetLogoutSession(_ context.Context, sessionID string) (*fosite.LogoutSession, error) {
+	value, ok := s.logoutSessions.load(sessionID)
+	if !ok {
+		return nil, fosite.ErrNotFound
+	}
+	session := value.(fosite.LogoutSession)
+	return &session, nil
+}
+
+func (s *MemoryStore) DeleteLogoutSession(_ context.Context, sessionID string) error {
+	s.logoutSessions.delete(sessionID)
+	return nil
+}
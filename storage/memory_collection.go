@@ -0,0 +1,183 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package storage
+
+import (
+	"reflect"
+	"sync"
+)
+
+// memoryCollection is a collection of the MemoryStore. It is either a shardedMap or a lockedMap.
+type memoryCollection interface {
+	load(key string) (interface{}, bool)
+	store(key string, value interface{})
+	delete(key string)
+	deleteWhen(key string, fn func(value interface{}) bool)
+	update(key string, fn func(value interface{}) interface{}) bool
+	storeIf(key string, value interface{}, keep func(value interface{}) bool) bool
+	deleteIf(limit int, fn func(key string, value interface{}) bool) map[string]interface{}
+	each(fn func(key string, value interface{}))
+	len() int
+}
+
+var (
+	_ memoryCollection = new(shardedMap)
+	_ memoryCollection = new(lockedMap)
+)
+
+// lockedMap is a memoryCollection backed by one of the exported maps of the MemoryStore, e.g. MemoryStore.AccessTokens,
+// which is guarded by a single lock.
+type lockedMap struct {
+	sync.RWMutex
+	m reflect.Value
+}
+
+// newLockedMap returns a lockedMap for the map the pointer points to, for example &s.AccessTokens.
+func newLockedMap(m interface{}) *lockedMap {
+	return &lockedMap{m: reflect.ValueOf(m).Elem()}
+}
+
+// set must be called with the lock held.
+func (m *lockedMap) set(key string, value interface{}) {
+	if m.m.IsNil() {
+		m.m.Set(reflect.MakeMap(m.m.Type()))
+	}
+	m.m.SetMapIndex(reflect.ValueOf(key), reflect.ValueOf(value))
+}
+
+// get must be called with the lock held.
+func (m *lockedMap) get(key string) (interface{}, bool) {
+	value := m.m.MapIndex(reflect.ValueOf(key))
+	if !value.IsValid() {
+		return nil, false
+	}
+	return value.Interface(), true
+}
+
+// remove must be called with the lock held.
+func (m *lockedMap) remove(key string) {
+	if !m.m.IsNil() {
+		m.m.SetMapIndex(reflect.ValueOf(key), reflect.Value{})
+	}
+}
+
+// keys must be called with the lock held.
+func (m *lockedMap) keys() []string {
+	keys := make([]string, 0, m.m.Len())
+	for _, k := range m.m.MapKeys() {
+		keys = append(keys, k.String())
+	}
+	return keys
+}
+
+func (m *lockedMap) load(key string) (interface{}, bool) {
+	m.RLock()
+	defer m.RUnlock()
+
+	return m.get(key)
+}
+
+func (m *lockedMap) store(key string, value interface{}) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.set(key, value)
+}
+
+func (m *lockedMap) delete(key string) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.remove(key)
+}
+
+func (m *lockedMap) deleteWhen(key string, fn func(value interface{}) bool) {
+	m.Lock()
+	defer m.Unlock()
+
+	if value, ok := m.get(key); ok && fn(value) {
+		m.remove(key)
+	}
+}
+
+func (m *lockedMap) update(key string, fn func(value interface{}) interface{}) bool {
+	m.Lock()
+	defer m.Unlock()
+
+	value, ok := m.get(key)
+	if !ok {
+		return false
+	}
+	m.set(key, fn(value))
+	return true
+}
+
+func (m *lockedMap) storeIf(key string, value interface{}, keep func(value interface{}) bool) bool {
+	m.Lock()
+	defer m.Unlock()
+
+	for _, k := range m.keys() {
+		if v, _ := m.get(k); !keep(v) {
+			m.remove(k)
+		}
+	}
+
+	if _, ok := m.get(key); ok {
+		return false
+	}
+	m.set(key, value)
+	return true
+}
+
+func (m *lockedMap) deleteIf(limit int, fn func(key string, value interface{}) bool) map[string]interface{} {
+	m.Lock()
+	defer m.Unlock()
+
+	removed := make(map[string]interface{})
+	for _, k := range m.keys() {
+		if limit > 0 && len(removed) >= limit {
+			break
+		}
+		if v, _ := m.get(k); fn(k, v) {
+			m.remove(k)
+			removed[k] = v
+		}
+	}
+	return removed
+}
+
+func (m *lockedMap) each(fn func(key string, value interface{})) {
+	m.RLock()
+	defer m.RUnlock()
+
+	for _, k := range m.keys() {
+		v, _ := m.get(k)
+		fn(k, v)
+	}
+}
+
+func (m *lockedMap) len() int {
+	m.RLock()
+	defer m.RUnlock()
+
+	return m.m.Len()
+}
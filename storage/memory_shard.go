@@ -0,0 +1,186 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package storage
+
+import "sync"
+
+// shardCount is the number of shards of a shardedMap. It must be a power of two.
+const shardCount = 32
+
+// shardedMap is a map split into shards which are locked independently, so that concurrent operations on different
+// keys rarely contend for the same lock. The zero value is ready to use.
+type shardedMap struct {
+	shards [shardCount]mapShard
+}
+
+type mapShard struct {
+	sync.RWMutex
+	items map[string]interface{}
+}
+
+// shard returns the shard of the key using the FNV-1a hash.
+func (m *shardedMap) shard(key string) *mapShard {
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return &m.shards[h&(shardCount-1)]
+}
+
+// set must be called with the lock of the shard held.
+func (s *mapShard) set(key string, value interface{}) {
+	if s.items == nil {
+		s.items = make(map[string]interface{})
+	}
+	s.items[key] = value
+}
+
+func (m *shardedMap) load(key string) (interface{}, bool) {
+	s := m.shard(key)
+	s.RLock()
+	defer s.RUnlock()
+
+	value, ok := s.items[key]
+	return value, ok
+}
+
+func (m *shardedMap) store(key string, value interface{}) {
+	s := m.shard(key)
+	s.Lock()
+	defer s.Unlock()
+
+	s.set(key, value)
+}
+
+func (m *shardedMap) delete(key string) {
+	s := m.shard(key)
+	s.Lock()
+	defer s.Unlock()
+
+	delete(s.items, key)
+}
+
+// deleteWhen removes the key if fn returns true for its value.
+func (m *shardedMap) deleteWhen(key string, fn func(value interface{}) bool) {
+	s := m.shard(key)
+	s.Lock()
+	defer s.Unlock()
+
+	if value, ok := s.items[key]; ok && fn(value) {
+		delete(s.items, key)
+	}
+}
+
+// update replaces the value of the key with the result of fn while holding the lock of the shard. It returns false
+// if the key does not exist.
+func (m *shardedMap) update(key string, fn func(value interface{}) interface{}) bool {
+	s := m.shard(key)
+	s.Lock()
+	defer s.Unlock()
+
+	value, ok := s.items[key]
+	if !ok {
+		return false
+	}
+	s.set(key, fn(value))
+	return true
+}
+
+// upsert stores the result of fn, which is passed the current value of the key and whether it exists, while holding
+// the lock of the shard.
+func (m *shardedMap) upsert(key string, fn func(value interface{}, ok bool) interface{}) {
+	s := m.shard(key)
+	s.Lock()
+	defer s.Unlock()
+
+	value, ok := s.items[key]
+	s.set(key, fn(value, ok))
+}
+
+// storeIf stores the value unless the key exists and keep returns true for the existing value. The remaining entries
+// of the shard for which keep returns false are removed as well. It returns false if the value was not stored.
+func (m *shardedMap) storeIf(key string, value interface{}, keep func(value interface{}) bool) bool {
+	s := m.shard(key)
+	s.Lock()
+	defer s.Unlock()
+
+	for k, v := range s.items {
+		if !keep(v) {
+			delete(s.items, k)
+		}
+	}
+
+	if _, ok := s.items[key]; ok {
+		return false
+	}
+	s.set(key, value)
+	return true
+}
+
+// deleteIf removes up to limit entries for which fn returns true, or all of them if limit is zero or less, and
+// returns the removed entries.
+func (m *shardedMap) deleteIf(limit int, fn func(key string, value interface{}) bool) map[string]interface{} {
+	removed := make(map[string]interface{})
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.Lock()
+		for k, v := range s.items {
+			if limit > 0 && len(removed) >= limit {
+				break
+			}
+			if fn(k, v) {
+				delete(s.items, k)
+				removed[k] = v
+			}
+		}
+		s.Unlock()
+
+		if limit > 0 && len(removed) >= limit {
+			break
+		}
+	}
+	return removed
+}
+
+// each calls fn for all entries, holding the read lock of one shard at a time.
+func (m *shardedMap) each(fn func(key string, value interface{})) {
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.RLock()
+		for k, v := range s.items {
+			fn(k, v)
+		}
+		s.RUnlock()
+	}
+}
+
+func (m *shardedMap) len() int {
+	n := 0
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.RLock()
+		n += len(s.items)
+		s.RUnlock()
+	}
+	return n
+}
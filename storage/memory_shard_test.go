@@ -0,0 +1,200 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package storage
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
+)
+
+func TestShardedMap(t *testing.T) {
+	var m shardedMap
+
+	_, ok := m.load("foo")
+	assert.False(t, ok)
+	assert.False(t, m.update("foo", func(value interface{}) interface{} { return value }))
+
+	m.store("foo", 1)
+	assert.True(t, m.update("foo", func(value interface{}) interface{} { return value.(int) + 1 }))
+	value, ok := m.load("foo")
+	require.True(t, ok)
+	assert.Equal(t, 2, value)
+
+	m.deleteWhen("foo", func(value interface{}) bool { return value.(int) == 1 })
+	assert.Equal(t, 1, m.len())
+	m.delete("foo")
+	assert.Equal(t, 0, m.len())
+
+	assert.True(t, m.storeIf("bar", 1, func(interface{}) bool { return true }))
+	assert.False(t, m.storeIf("bar", 2, func(interface{}) bool { return true }))
+	assert.True(t, m.storeIf("bar", 3, func(interface{}) bool { return false }))
+
+	for i := 0; i < 100; i++ {
+		m.store(strconv.Itoa(i), i)
+	}
+	removed := m.deleteIf(10, func(_ string, value interface{}) bool { return value.(int)%2 == 0 })
+	assert.Len(t, removed, 10)
+	assert.Equal(t, 91, m.len())
+}
+
+func TestLockedMap(t *testing.T) {
+	var values map[string]int
+	m := newLockedMap(&values)
+
+	_, ok := m.load("foo")
+	assert.False(t, ok)
+	m.delete("foo")
+	assert.False(t, m.update("foo", func(value interface{}) interface{} { return value }))
+
+	m.store("foo", 1)
+	assert.Equal(t, map[string]int{"foo": 1}, values)
+	assert.True(t, m.update("foo", func(value interface{}) interface{} { return value.(int) + 1 }))
+	value, ok := m.load("foo")
+	require.True(t, ok)
+	assert.Equal(t, 2, value)
+
+	assert.True(t, m.storeIf("bar", 1, func(value interface{}) bool { return value.(int) != 2 }))
+	assert.Equal(t, map[string]int{"bar": 1}, values)
+	assert.False(t, m.storeIf("bar", 2, func(interface{}) bool { return true }))
+
+	for i := 0; i < 100; i++ {
+		m.store(strconv.Itoa(i), i)
+	}
+	removed := m.deleteIf(10, func(_ string, value interface{}) bool { return value.(int)%2 == 0 })
+	assert.Len(t, removed, 10)
+	assert.Equal(t, 91, m.len())
+	assert.Len(t, values, 91)
+}
+
+func TestMemoryStore_Concurrency(t *testing.T) {
+	for _, c := range []struct {
+		d string
+		s *MemoryStore
+	}{
+		{d: "locked", s: NewMemoryStore()},
+		{d: "sharded", s: NewShardedMemoryStore()},
+		{d: "literal", s: &MemoryStore{}},
+	} {
+		t.Run("store="+c.d, func(t *testing.T) {
+			ctx := context.Background()
+			s := c.s
+
+			var wg sync.WaitGroup
+			for i := 0; i < 50; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					id := strconv.Itoa(i)
+					req := &fosite.Request{ID: id, Session: new(fosite.DefaultSession)}
+
+					require.NoError(t, s.CreateAccessTokenSession(ctx, "at-"+id, req))
+					require.NoError(t, s.CreateRefreshTokenSession(ctx, "rt-"+id, req))
+					require.NoError(t, s.SetClientAssertionJWT(ctx, "jti-"+id, time.Now().Add(time.Minute)))
+					require.NoError(t, s.RevokeAccessToken(ctx, id))
+					require.NoError(t, s.RevokeRefreshToken(ctx, id))
+				}(i)
+			}
+			wg.Wait()
+
+			assert.Equal(t, 0, s.tokens().accessTokens.len())
+			assert.Equal(t, 50, s.tokens().refreshTokens.len())
+			assert.Equal(t, 50, s.tokens().blacklistedJTIs.len())
+		})
+	}
+}
+
+func TestNewShardedMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewShardedMemoryStore()
+
+	require.NoError(t, s.CreateAccessTokenSession(ctx, "at", &fosite.Request{ID: "id"}))
+	_, err := s.GetAccessTokenSession(ctx, "at", nil)
+	require.NoError(t, err)
+	assert.Empty(t, s.AccessTokens)
+}
+
+func benchmarkMemoryStores(b *testing.B, fn func(b *testing.B, s *MemoryStore)) {
+	b.Run("store=locked", func(b *testing.B) { fn(b, NewMemoryStore()) })
+	b.Run("store=sharded", func(b *testing.B) { fn(b, NewShardedMemoryStore()) })
+}
+
+func BenchmarkMemoryStore_CreateAccessTokenSession(b *testing.B) {
+	benchmarkMemoryStores(b, func(b *testing.B, s *MemoryStore) {
+		ctx := context.Background()
+		var n int64
+
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				id := strconv.FormatInt(atomic.AddInt64(&n, 1), 10)
+				_ = s.CreateAccessTokenSession(ctx, id, &fosite.Request{ID: id})
+			}
+		})
+	})
+}
+
+func BenchmarkMemoryStore_GetAccessTokenSession(b *testing.B) {
+	benchmarkMemoryStores(b, func(b *testing.B, s *MemoryStore) {
+		ctx := context.Background()
+		for i := 0; i < 1000; i++ {
+			id := strconv.Itoa(i)
+			_ = s.CreateAccessTokenSession(ctx, id, &fosite.Request{ID: id})
+		}
+		var n int64
+
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				_, _ = s.GetAccessTokenSession(ctx, strconv.FormatInt(atomic.AddInt64(&n, 1)%1000, 10), nil)
+			}
+		})
+	})
+}
+
+func BenchmarkMemoryStore_RefreshRotation(b *testing.B) {
+	benchmarkMemoryStores(b, func(b *testing.B, s *MemoryStore) {
+		ctx := context.Background()
+		var n int64
+
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				id := strconv.FormatInt(atomic.AddInt64(&n, 1), 10)
+				req := &fosite.Request{ID: id}
+				_ = s.CreateRefreshTokenSession(ctx, "old-"+id, req)
+				_ = s.RevokeRefreshToken(ctx, id)
+				_ = s.RevokeAccessToken(ctx, id)
+				_ = s.CreateAccessTokenSession(ctx, "at-"+id, req)
+				_ = s.CreateRefreshTokenSession(ctx, "new-"+id, req)
+			}
+		})
+	})
+}
@@ -0,0 +1,430 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+// Package redis provides a Redis backed implementation of the fosite storage interfaces.
+//
+// The store persists clients, authorize codes, access and refresh tokens, PKCE and OpenID Connect sessions as well
+// as used JWT IDs. Entries that belong to a token expire together with the token: the Redis TTL of every entry is
+// derived from the expiry recorded in the request's session, so no additional garbage collection is required.
+// Entries without an expiry (for example refresh tokens with an unlimited lifespan) never expire.
+//
+// Pushed authorization requests, device and CIBA sessions as well as RFC7523 issuer keys are not covered by this
+// package.
+//
+// All keys are prefixed with the configured key prefix. When running against Redis Cluster use a prefix containing
+// a hash tag, e.g. "{fosite}:", so that keys which are updated together map to the same slot.
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	goredis "github.com/go-redis/redis/v8"
+
+	"github.com/ory/fosite"
+	"github.com/ory/x/errorsx"
+)
+
+// DefaultKeyPrefix is used when no key prefix is given to NewStore.
+const DefaultKeyPrefix = "fosite:"
+
+const (
+	fieldRequest   = "request"
+	fieldActive    = "active"
+	fieldRotatedAt = "rotated_at"
+)
+
+// Store implements fosite's storage interfaces on top of Redis.
+type Store struct {
+	Client    goredis.UniversalClient
+	KeyPrefix string
+
+	// SessionCodec, if set, encodes the sessions of stored requests instead of JSON, see fosite.SessionCodec.
+	SessionCodec fosite.SessionCodec
+}
+
+// NewStore returns a Store using the given client. An empty prefix is replaced with DefaultKeyPrefix.
+func NewStore(client goredis.UniversalClient, prefix string) *Store {
+	if prefix == "" {
+		prefix = DefaultKeyPrefix
+	}
+	return &Store{Client: client, KeyPrefix: prefix}
+}
+
+func (s *Store) key(kind, id string) string {
+	return s.KeyPrefix + kind + ":" + id
+}
+
+// ttl returns the time until the given expiry, or zero (no expiry) if the expiry is unset.
+func ttl(expiresAt time.Time) time.Duration {
+	if expiresAt.IsZero() {
+		return 0
+	}
+	if d := time.Until(expiresAt); d > 0 {
+		return d
+	}
+	// The entry expired already, keep it for the shortest possible time so reads fail consistently.
+	return time.Millisecond
+}
+
+func expiry(r fosite.Requester, tokenType fosite.TokenType) time.Duration {
+	if r.GetSession() == nil {
+		return 0
+	}
+	return ttl(r.GetSession().GetExpiresAt(tokenType))
+}
+
+func (s *Store) GetClient(ctx context.Context, id string) (fosite.Client, error) {
+	data, err := s.Client.Get(ctx, s.key("client", id)).Bytes()
+	if errorsx.Cause(err) == goredis.Nil {
+		return nil, fosite.ErrNotFound
+	} else if err != nil {
+		return nil, errorsx.WithStack(err)
+	}
+
+	var cl fosite.DefaultOpenIDConnectClient
+	if err := json.Unmarshal(data, &cl); err != nil {
+		return nil, errorsx.WithStack(err)
+	}
+	return &cl, nil
+}
+
+// CreateClient stores the client. Clients are persisted as JSON and read back as *fosite.DefaultOpenIDConnectClient.
+func (s *Store) CreateClient(ctx context.Context, client fosite.Client) error {
+	data, err := json.Marshal(client)
+	if err != nil {
+		return errorsx.WithStack(err)
+	}
+	return errorsx.WithStack(s.Client.Set(ctx, s.key("client", client.GetID()), data, 0).Err())
+}
+
+func (s *Store) UpdateClient(ctx context.Context, client fosite.Client) error {
+	data, err := json.Marshal(client)
+	if err != nil {
+		return errorsx.WithStack(err)
+	}
+	ok, err := s.Client.SetXX(ctx, s.key("client", client.GetID()), data, goredis.KeepTTL).Result()
+	if err != nil {
+		return errorsx.WithStack(err)
+	} else if !ok {
+		return fosite.ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) DeleteClient(ctx context.Context, id string) error {
+	n, err := s.Client.Del(ctx, s.key("client", id)).Result()
+	if err != nil {
+		return errorsx.WithStack(err)
+	} else if n == 0 {
+		return fosite.ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) ClientAssertionJWTValid(ctx context.Context, jti string) error {
+	n, err := s.Client.Exists(ctx, s.key("jti", jti)).Result()
+	if err != nil {
+		return errorsx.WithStack(err)
+	} else if n > 0 {
+		return fosite.ErrJTIKnown
+	}
+	return nil
+}
+
+// SetClientAssertionJWT marks the JTI as used until exp. Expired JTIs are removed by Redis.
+func (s *Store) SetClientAssertionJWT(ctx context.Context, jti string, exp time.Time) error {
+	d := time.Until(exp)
+	if d <= 0 {
+		return nil
+	}
+	ok, err := s.Client.SetNX(ctx, s.key("jti", jti), exp.Unix(), d).Result()
+	if err != nil {
+		return errorsx.WithStack(err)
+	} else if !ok {
+		return fosite.ErrJTIKnown
+	}
+	return nil
+}
+
+func (s *Store) IsJWTUsed(ctx context.Context, jti string) (bool, error) {
+	if err := s.ClientAssertionJWTValid(ctx, jti); errorsx.Cause(err) == fosite.ErrJTIKnown {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+	return false, nil
+}
+
+func (s *Store) MarkJWTUsedForTime(ctx context.Context, jti string, exp time.Time) error {
+	return s.SetClientAssertionJWT(ctx, jti, exp)
+}
+
+func (s *Store) CreateAuthorizeCodeSession(ctx context.Context, code string, req fosite.Requester) error {
+	return s.createHash(ctx, s.key("authorize_code", code), "", code, req, expiry(req, fosite.AuthorizeCode))
+}
+
+func (s *Store) GetAuthorizeCodeSession(ctx context.Context, code string, session fosite.Session) (fosite.Requester, error) {
+	r, active, err := s.getHash(ctx, s.key("authorize_code", code), session)
+	if err != nil {
+		return nil, err
+	} else if !active {
+		return r, fosite.ErrInvalidatedAuthorizeCode
+	}
+	return r, nil
+}
+
+func (s *Store) InvalidateAuthorizeCodeSession(ctx context.Context, code string) error {
+	return s.setIfExists(ctx, s.key("authorize_code", code), fieldActive, "0")
+}
+
+func (s *Store) CreatePKCERequestSession(ctx context.Context, code string, req fosite.Requester) error {
+	return s.createString(ctx, s.key("pkce", code), req, expiry(req, fosite.AuthorizeCode))
+}
+
+func (s *Store) GetPKCERequestSession(ctx context.Context, code string, session fosite.Session) (fosite.Requester, error) {
+	return s.getString(ctx, s.key("pkce", code), session)
+}
+
+func (s *Store) DeletePKCERequestSession(ctx context.Context, code string) error {
+	return errorsx.WithStack(s.Client.Del(ctx, s.key("pkce", code)).Err())
+}
+
+func (s *Store) CreateOpenIDConnectSession(ctx context.Context, authorizeCode string, req fosite.Requester) error {
+	return s.createString(ctx, s.key("oidc", authorizeCode), req, expiry(req, fosite.AuthorizeCode))
+}
+
+func (s *Store) GetOpenIDConnectSession(ctx context.Context, authorizeCode string, req fosite.Requester) (fosite.Requester, error) {
+	var session fosite.Session
+	if req != nil {
+		session = req.GetSession()
+	}
+	return s.getString(ctx, s.key("oidc", authorizeCode), session)
+}
+
+// DeleteOpenIDConnectSession is not really called from anywhere and it is deprecated.
+func (s *Store) DeleteOpenIDConnectSession(ctx context.Context, authorizeCode string) error {
+	return errorsx.WithStack(s.Client.Del(ctx, s.key("oidc", authorizeCode)).Err())
+}
+
+func (s *Store) CreateAccessTokenSession(ctx context.Context, signature string, req fosite.Requester) error {
+	return s.createHash(ctx, s.key("access_token", signature), s.key("access_token_request", req.GetID()), signature, req, expiry(req, fosite.AccessToken))
+}
+
+func (s *Store) GetAccessTokenSession(ctx context.Context, signature string, session fosite.Session) (fosite.Requester, error) {
+	r, _, err := s.getHash(ctx, s.key("access_token", signature), session)
+	return r, err
+}
+
+func (s *Store) DeleteAccessTokenSession(ctx context.Context, signature string) error {
+	return errorsx.WithStack(s.Client.Del(ctx, s.key("access_token", signature)).Err())
+}
+
+func (s *Store) CreateRefreshTokenSession(ctx context.Context, signature string, req fosite.Requester) error {
+	return s.createHash(ctx, s.key("refresh_token", signature), s.key("refresh_token_request", req.GetID()), signature, req, expiry(req, fosite.RefreshToken))
+}
+
+func (s *Store) GetRefreshTokenSession(ctx context.Context, signature string, session fosite.Session) (fosite.Requester, error) {
+	r, active, err := s.getHash(ctx, s.key("refresh_token", signature), session)
+	if err != nil {
+		return nil, err
+	} else if !active {
+		return r, fosite.ErrInactiveToken
+	}
+	return r, nil
+}
+
+func (s *Store) DeleteRefreshTokenSession(ctx context.Context, signature string) error {
+	return errorsx.WithStack(s.Client.Del(ctx, s.key("refresh_token", signature)).Err())
+}
+
+func (s *Store) RotateRefreshToken(ctx context.Context, signature string, rotatedAt time.Time) error {
+	return s.setIfExists(ctx, s.key("refresh_token", signature), fieldRotatedAt, rotatedAt.UTC().Format(time.RFC3339Nano))
+}
+
+func (s *Store) GetRefreshTokenRotatedAt(ctx context.Context, signature string) (time.Time, error) {
+	value, err := s.Client.HGet(ctx, s.key("refresh_token", signature), fieldRotatedAt).Result()
+	if errorsx.Cause(err) == goredis.Nil {
+		return time.Time{}, fosite.ErrNotFound
+	} else if err != nil {
+		return time.Time{}, errorsx.WithStack(err)
+	}
+	rotatedAt, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, errorsx.WithStack(err)
+	}
+	return rotatedAt, nil
+}
+
+// RevokeRefreshToken marks all refresh tokens issued for the request as inactive in a single transaction, and records
+// the time of the revocation, see oauth2.StatelessRefreshTokenStorage.
+func (s *Store) RevokeRefreshToken(ctx context.Context, requestID string) error {
+	index := s.key("refresh_token_request", requestID)
+	return s.Client.Watch(ctx, func(tx *goredis.Tx) error {
+		signatures, err := tx.SMembers(ctx, index).Result()
+		if err != nil {
+			return errorsx.WithStack(err)
+		}
+
+		keys := make([]string, 0, len(signatures))
+		for _, signature := range signatures {
+			key := s.key("refresh_token", signature)
+			if n, err := tx.Exists(ctx, key).Result(); err != nil {
+				return errorsx.WithStack(err)
+			} else if n > 0 {
+				keys = append(keys, key)
+			}
+		}
+
+		_, err = tx.TxPipelined(ctx, func(p goredis.Pipeliner) error {
+			for _, key := range keys {
+				p.HSet(ctx, key, fieldActive, "0")
+			}
+			p.Set(ctx, s.key("refresh_token_revocation", requestID), time.Now().UTC().Format(time.RFC3339Nano), 0)
+			return nil
+		})
+		return errorsx.WithStack(err)
+	}, index)
+}
+
+func (s *Store) GetRefreshTokenRevokedAt(ctx context.Context, requestID string) (time.Time, error) {
+	value, err := s.Client.Get(ctx, s.key("refresh_token_revocation", requestID)).Result()
+	if errorsx.Cause(err) == goredis.Nil {
+		return time.Time{}, fosite.ErrNotFound
+	} else if err != nil {
+		return time.Time{}, errorsx.WithStack(err)
+	}
+	revokedAt, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, errorsx.WithStack(err)
+	}
+	return revokedAt, nil
+}
+
+// RevokeAccessToken deletes all access tokens issued for the request in a single transaction.
+func (s *Store) RevokeAccessToken(ctx context.Context, requestID string) error {
+	index := s.key("access_token_request", requestID)
+	return s.Client.Watch(ctx, func(tx *goredis.Tx) error {
+		signatures, err := tx.SMembers(ctx, index).Result()
+		if err != nil {
+			return errorsx.WithStack(err)
+		}
+
+		_, err = tx.TxPipelined(ctx, func(p goredis.Pipeliner) error {
+			for _, signature := range signatures {
+				p.Del(ctx, s.key("access_token", signature))
+			}
+			p.Del(ctx, index)
+			return nil
+		})
+		return errorsx.WithStack(err)
+	}, index)
+}
+
+func (s *Store) createString(ctx context.Context, key string, req fosite.Requester, expiration time.Duration) error {
+	data, err := s.marshalRequest(req)
+	if err != nil {
+		return err
+	}
+	return errorsx.WithStack(s.Client.Set(ctx, key, data, expiration).Err())
+}
+
+func (s *Store) getString(ctx context.Context, key string, session fosite.Session) (fosite.Requester, error) {
+	data, err := s.Client.Get(ctx, key).Bytes()
+	if errorsx.Cause(err) == goredis.Nil {
+		return nil, fosite.ErrNotFound
+	} else if err != nil {
+		return nil, errorsx.WithStack(err)
+	}
+	return s.unmarshalRequest(ctx, data, session)
+}
+
+// createHash stores the request in an active hash and, if index is set, adds the signature to the request ID index
+// so the entry can be revoked by request ID. The index expires together with the most recently added entry.
+func (s *Store) createHash(ctx context.Context, key, index, signature string, req fosite.Requester, expiration time.Duration) error {
+	data, err := s.marshalRequest(req)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.Client.TxPipelined(ctx, func(p goredis.Pipeliner) error {
+		p.HSet(ctx, key, fieldRequest, data, fieldActive, "1")
+		if expiration > 0 {
+			p.PExpire(ctx, key, expiration)
+		}
+
+		if index != "" {
+			p.SAdd(ctx, index, signature)
+			if expiration > 0 {
+				p.PExpire(ctx, index, expiration)
+			} else {
+				p.Persist(ctx, index)
+			}
+		}
+		return nil
+	})
+	return errorsx.WithStack(err)
+}
+
+func (s *Store) getHash(ctx context.Context, key string, session fosite.Session) (fosite.Requester, bool, error) {
+	values, err := s.Client.HMGet(ctx, key, fieldRequest, fieldActive).Result()
+	if err != nil {
+		return nil, false, errorsx.WithStack(err)
+	}
+
+	data, ok := values[0].(string)
+	if !ok {
+		return nil, false, fosite.ErrNotFound
+	}
+
+	r, err := s.unmarshalRequest(ctx, []byte(data), session)
+	if err != nil {
+		return nil, false, err
+	}
+	return r, values[1] != "0", nil
+}
+
+// setIfExists sets a field of a hash only if the hash exists, otherwise fosite.ErrNotFound is returned.
+func (s *Store) setIfExists(ctx context.Context, key, field, value string) error {
+	var found bool
+	err := s.Client.Watch(ctx, func(tx *goredis.Tx) error {
+		n, err := tx.Exists(ctx, key).Result()
+		if err != nil {
+			return errorsx.WithStack(err)
+		} else if n == 0 {
+			return nil
+		}
+
+		found = true
+		_, err = tx.TxPipelined(ctx, func(p goredis.Pipeliner) error {
+			p.HSet(ctx, key, field, value)
+			return nil
+		})
+		return errorsx.WithStack(err)
+	}, key)
+	if err != nil {
+		return err
+	} else if !found {
+		return fosite.ErrNotFound
+	}
+	return nil
+}
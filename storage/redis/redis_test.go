@@ -0,0 +1,232 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/oauth2"
+	"github.com/ory/fosite/handler/openid"
+	"github.com/ory/fosite/handler/pkce"
+)
+
+var (
+	_ fosite.Storage                      = (*Store)(nil)
+	_ oauth2.CoreStorage                  = (*Store)(nil)
+	_ oauth2.TokenRevocationStorage       = (*Store)(nil)
+	_ oauth2.RefreshTokenRotationStorage  = (*Store)(nil)
+	_ oauth2.StatelessRefreshTokenStorage = (*Store)(nil)
+	_ openid.OpenIDConnectRequestStorage  = (*Store)(nil)
+	_ pkce.PKCERequestStorage             = (*Store)(nil)
+)
+
+func newTestStore(t *testing.T) (*Store, *miniredis.Miniredis) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	t.Cleanup(mr.Close)
+
+	s := NewStore(goredis.NewClient(&goredis.Options{Addr: mr.Addr()}), "")
+	require.NoError(t, s.CreateClient(context.Background(), &fosite.DefaultClient{ID: "foo", Scopes: []string{"offline"}}))
+	return s, mr
+}
+
+func newTestRequest(id string, expires map[fosite.TokenType]time.Time) *fosite.Request {
+	r := fosite.NewRequest()
+	r.ID = id
+	r.RequestedAt = time.Now().UTC().Round(time.Second)
+	r.Client = &fosite.DefaultClient{ID: "foo"}
+	r.RequestedScope = fosite.Arguments{"offline"}
+	r.GrantedScope = fosite.Arguments{"offline"}
+	r.Form.Set("foo", "bar")
+	r.Session = &fosite.DefaultSession{Subject: "peter", ExpiresAt: expires}
+	return r
+}
+
+func TestClients(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t)
+
+	cl, err := s.GetClient(ctx, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, "foo", cl.GetID())
+	assert.EqualValues(t, fosite.Arguments{"offline"}, cl.GetScopes())
+
+	require.NoError(t, s.UpdateClient(ctx, &fosite.DefaultClient{ID: "foo", Scopes: []string{"bar"}}))
+	cl, err = s.GetClient(ctx, "foo")
+	require.NoError(t, err)
+	assert.EqualValues(t, fosite.Arguments{"bar"}, cl.GetScopes())
+
+	assert.True(t, errors.Is(s.UpdateClient(ctx, &fosite.DefaultClient{ID: "bar"}), fosite.ErrNotFound))
+	require.NoError(t, s.DeleteClient(ctx, "foo"))
+	assert.True(t, errors.Is(s.DeleteClient(ctx, "foo"), fosite.ErrNotFound))
+	_, err = s.GetClient(ctx, "foo")
+	assert.True(t, errors.Is(err, fosite.ErrNotFound))
+}
+
+func TestClientAssertionJWT(t *testing.T) {
+	ctx := context.Background()
+	s, mr := newTestStore(t)
+
+	require.NoError(t, s.ClientAssertionJWTValid(ctx, "jti"))
+	require.NoError(t, s.SetClientAssertionJWT(ctx, "jti", time.Now().Add(time.Minute)))
+	assert.True(t, errors.Is(s.ClientAssertionJWTValid(ctx, "jti"), fosite.ErrJTIKnown))
+	assert.True(t, errors.Is(s.SetClientAssertionJWT(ctx, "jti", time.Now().Add(time.Minute)), fosite.ErrJTIKnown))
+
+	used, err := s.IsJWTUsed(ctx, "jti")
+	require.NoError(t, err)
+	assert.True(t, used)
+
+	mr.FastForward(time.Minute + time.Second)
+	require.NoError(t, s.ClientAssertionJWTValid(ctx, "jti"))
+	used, err = s.IsJWTUsed(ctx, "jti")
+	require.NoError(t, err)
+	assert.False(t, used)
+}
+
+func TestAuthorizeCodeSession(t *testing.T) {
+	ctx := context.Background()
+	s, mr := newTestStore(t)
+
+	req := newTestRequest("req", map[fosite.TokenType]time.Time{fosite.AuthorizeCode: time.Now().Add(time.Minute)})
+	require.NoError(t, s.CreateAuthorizeCodeSession(ctx, "code", req))
+
+	session := new(fosite.DefaultSession)
+	got, err := s.GetAuthorizeCodeSession(ctx, "code", session)
+	require.NoError(t, err)
+	assert.Equal(t, "req", got.GetID())
+	assert.Equal(t, req.RequestedAt, got.GetRequestedAt())
+	assert.Equal(t, "foo", got.GetClient().GetID())
+	assert.EqualValues(t, req.GrantedScope, got.GetGrantedScopes())
+	assert.Equal(t, "bar", got.GetRequestForm().Get("foo"))
+	assert.Equal(t, "peter", session.Subject)
+	assert.Equal(t, session, got.GetSession())
+
+	require.NoError(t, s.InvalidateAuthorizeCodeSession(ctx, "code"))
+	got, err = s.GetAuthorizeCodeSession(ctx, "code", new(fosite.DefaultSession))
+	assert.True(t, errors.Is(err, fosite.ErrInvalidatedAuthorizeCode))
+	require.NotNil(t, got)
+	assert.Equal(t, "req", got.GetID())
+
+	assert.True(t, errors.Is(s.InvalidateAuthorizeCodeSession(ctx, "unknown"), fosite.ErrNotFound))
+
+	mr.FastForward(time.Minute + time.Second)
+	_, err = s.GetAuthorizeCodeSession(ctx, "code", new(fosite.DefaultSession))
+	assert.True(t, errors.Is(err, fosite.ErrNotFound))
+}
+
+func TestAccessTokenSession(t *testing.T) {
+	ctx := context.Background()
+	s, mr := newTestStore(t)
+
+	req := newTestRequest("req", map[fosite.TokenType]time.Time{fosite.AccessToken: time.Now().Add(time.Hour)})
+	require.NoError(t, s.CreateAccessTokenSession(ctx, "at1", req))
+	require.NoError(t, s.CreateAccessTokenSession(ctx, "at2", req))
+	require.NoError(t, s.CreateAccessTokenSession(ctx, "other", newTestRequest("other", nil)))
+
+	_, err := s.GetAccessTokenSession(ctx, "at1", new(fosite.DefaultSession))
+	require.NoError(t, err)
+	assert.True(t, mr.TTL(s.key("access_token", "at1")) > 59*time.Minute)
+	assert.Equal(t, time.Duration(0), mr.TTL(s.key("access_token", "other")))
+
+	require.NoError(t, s.RevokeAccessToken(ctx, "req"))
+	for _, signature := range []string{"at1", "at2"} {
+		_, err = s.GetAccessTokenSession(ctx, signature, new(fosite.DefaultSession))
+		assert.True(t, errors.Is(err, fosite.ErrNotFound), signature)
+	}
+	_, err = s.GetAccessTokenSession(ctx, "other", nil)
+	require.NoError(t, err)
+
+	require.NoError(t, s.DeleteAccessTokenSession(ctx, "other"))
+	_, err = s.GetAccessTokenSession(ctx, "other", nil)
+	assert.True(t, errors.Is(err, fosite.ErrNotFound))
+}
+
+func TestRefreshTokenSession(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t)
+
+	req := newTestRequest("req", map[fosite.TokenType]time.Time{fosite.RefreshToken: time.Now().Add(time.Hour)})
+	require.NoError(t, s.CreateRefreshTokenSession(ctx, "rt", req))
+
+	_, err := s.GetRefreshTokenRotatedAt(ctx, "rt")
+	assert.True(t, errors.Is(err, fosite.ErrNotFound))
+
+	rotatedAt := time.Now().UTC()
+	require.NoError(t, s.RotateRefreshToken(ctx, "rt", rotatedAt))
+	got, err := s.GetRefreshTokenRotatedAt(ctx, "rt")
+	require.NoError(t, err)
+	assert.True(t, rotatedAt.Equal(got))
+	assert.True(t, errors.Is(s.RotateRefreshToken(ctx, "unknown", rotatedAt), fosite.ErrNotFound))
+
+	_, err = s.GetRefreshTokenRevokedAt(ctx, "req")
+	assert.True(t, errors.Is(err, fosite.ErrNotFound))
+
+	before := time.Now().UTC()
+	require.NoError(t, s.RevokeRefreshToken(ctx, "req"))
+	revokedAt, err := s.GetRefreshTokenRevokedAt(ctx, "req")
+	require.NoError(t, err)
+	assert.False(t, revokedAt.Before(before))
+
+	r, err := s.GetRefreshTokenSession(ctx, "rt", nil)
+	assert.True(t, errors.Is(err, fosite.ErrInactiveToken))
+	require.NotNil(t, r)
+	assert.Equal(t, "peter", r.GetSession().GetSubject())
+
+	require.NoError(t, s.DeleteRefreshTokenSession(ctx, "rt"))
+	_, err = s.GetRefreshTokenSession(ctx, "rt", nil)
+	assert.True(t, errors.Is(err, fosite.ErrNotFound))
+	require.NoError(t, s.RevokeRefreshToken(ctx, "req"))
+}
+
+func TestPKCEAndOpenIDConnectSessions(t *testing.T) {
+	ctx := context.Background()
+	s, mr := newTestStore(t)
+
+	req := newTestRequest("req", map[fosite.TokenType]time.Time{fosite.AuthorizeCode: time.Now().Add(time.Minute)})
+	req.Session = &openid.DefaultSession{Subject: "peter", ExpiresAt: req.Session.(*fosite.DefaultSession).ExpiresAt}
+	require.NoError(t, s.CreatePKCERequestSession(ctx, "code", req))
+	require.NoError(t, s.CreateOpenIDConnectSession(ctx, "code", req))
+
+	_, err := s.GetPKCERequestSession(ctx, "code", new(openid.DefaultSession))
+	require.NoError(t, err)
+
+	got, err := s.GetOpenIDConnectSession(ctx, "code", &fosite.Request{Session: new(openid.DefaultSession)})
+	require.NoError(t, err)
+	assert.Equal(t, "peter", got.GetSession().(*openid.DefaultSession).Subject)
+
+	require.NoError(t, s.DeletePKCERequestSession(ctx, "code"))
+	_, err = s.GetPKCERequestSession(ctx, "code", nil)
+	assert.True(t, errors.Is(err, fosite.ErrNotFound))
+
+	mr.FastForward(time.Minute + time.Second)
+	_, err = s.GetOpenIDConnectSession(ctx, "code", nil)
+	assert.True(t, errors.Is(err, fosite.ErrNotFound))
+}
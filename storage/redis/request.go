@@ -0,0 +1,54 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package redis
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/storage"
+	"github.com/ory/x/errorsx"
+)
+
+func (s *Store) marshalRequest(r fosite.Requester) ([]byte, error) {
+	stored, err := storage.NewStoredRequestWithCodec(r, s.SessionCodec)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(stored)
+	return data, errorsx.WithStack(err)
+}
+
+// unmarshalRequest decodes the request and resolves its client. The session is decoded into the given session.
+func (s *Store) unmarshalRequest(ctx context.Context, data []byte, session fosite.Session) (*fosite.Request, error) {
+	var stored storage.StoredRequest
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, errorsx.WithStack(err)
+	}
+
+	client, err := s.GetClient(ctx, stored.ClientID)
+	if err != nil {
+		return nil, err
+	}
+	return stored.ToRequestWithCodec(client, session, s.SessionCodec)
+}
@@ -0,0 +1,139 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package storage
+
+import (
+	"encoding/json"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/fosite"
+	"github.com/ory/x/errorsx"
+)
+
+// StoredRequest is the serializable form of a fosite.Requester used by storage implementations which persist
+// requests as JSON. The client is stored by reference and has to be resolved again when the request is loaded. The
+// session is stored as JSON in Session, or encoded by a fosite.SessionCodec in SessionData.
+type StoredRequest struct {
+	ID                            string                      `json:"id"`
+	RequestedAt                   time.Time                   `json:"requested_at"`
+	ClientID                      string                      `json:"client_id"`
+	RequestedScope                fosite.Arguments            `json:"requested_scope"`
+	GrantedScope                  fosite.Arguments            `json:"granted_scope"`
+	RequestedAudience             fosite.Arguments            `json:"requested_audience"`
+	GrantedAudience               fosite.Arguments            `json:"granted_audience"`
+	Form                          url.Values                  `json:"form"`
+	Session                       json.RawMessage             `json:"session,omitempty"`
+	SessionData                   []byte                      `json:"session_data,omitempty"`
+	RequestedAuthorizationDetails fosite.AuthorizationDetails `json:"requested_authorization_details,omitempty"`
+	GrantedAuthorizationDetails   fosite.AuthorizationDetails `json:"granted_authorization_details,omitempty"`
+}
+
+// NewStoredRequest converts the requester into its serializable form, with the session encoded as JSON.
+func NewStoredRequest(r fosite.Requester) (*StoredRequest, error) {
+	return NewStoredRequestWithCodec(r, nil)
+}
+
+// NewStoredRequestWithCodec converts the requester into its serializable form, with the session encoded by the
+// codec. If the codec is nil, the session is stored as JSON like NewStoredRequest does.
+func NewStoredRequestWithCodec(r fosite.Requester, codec fosite.SessionCodec) (*StoredRequest, error) {
+	var session json.RawMessage
+	var sessionData []byte
+	var err error
+	if codec == nil {
+		session, err = json.Marshal(r.GetSession())
+	} else {
+		sessionData, err = codec.Encode(r.GetSession())
+	}
+	if err != nil {
+		return nil, errorsx.WithStack(err)
+	}
+
+	var clientID string
+	if r.GetClient() != nil {
+		clientID = r.GetClient().GetID()
+	}
+
+	return &StoredRequest{
+		ID:                            r.GetID(),
+		RequestedAt:                   r.GetRequestedAt(),
+		ClientID:                      clientID,
+		RequestedScope:                r.GetRequestedScopes(),
+		GrantedScope:                  r.GetGrantedScopes(),
+		RequestedAudience:             r.GetRequestedAudience(),
+		GrantedAudience:               r.GetGrantedAudience(),
+		Form:                          r.GetRequestForm(),
+		Session:                       session,
+		SessionData:                   sessionData,
+		RequestedAuthorizationDetails: r.GetRequestedAuthorizationDetails(),
+		GrantedAuthorizationDetails:   r.GetAuthorizationDetails(),
+	}, nil
+}
+
+// ToRequest restores the request. The stored session is decoded into the given session, if session is nil a
+// *fosite.DefaultSession is used.
+func (s *StoredRequest) ToRequest(client fosite.Client, session fosite.Session) (*fosite.Request, error) {
+	return s.ToRequestWithCodec(client, session, nil)
+}
+
+// ToRequestWithCodec restores the request like ToRequest, decoding the session with the codec. Sessions stored as
+// JSON, for example before the codec was configured, are passed to the codec as well, which a
+// fosite.VersionedSessionCodec treats as version 0. If the codec is nil, only JSON sessions can be decoded.
+func (s *StoredRequest) ToRequestWithCodec(client fosite.Client, session fosite.Session, codec fosite.SessionCodec) (*fosite.Request, error) {
+	if session == nil {
+		session = &fosite.DefaultSession{}
+	}
+
+	data := s.SessionData
+	if len(data) == 0 {
+		data = s.Session
+	}
+	if len(data) > 0 {
+		if codec == nil {
+			if len(s.SessionData) > 0 {
+				return nil, errorsx.WithStack(errors.New("the session was encoded by a codec, but no codec is configured"))
+			}
+			codec = fosite.JSONSessionCodec{}
+		}
+		if err := codec.Decode(data, session); err != nil {
+			return nil, errorsx.WithStack(err)
+		}
+	}
+
+	r := fosite.NewRequest()
+	r.ID = s.ID
+	r.RequestedAt = s.RequestedAt
+	r.Client = client
+	r.RequestedScope = s.RequestedScope
+	r.GrantedScope = s.GrantedScope
+	r.RequestedAudience = s.RequestedAudience
+	r.GrantedAudience = s.GrantedAudience
+	r.Session = session
+	r.RequestedAuthorizationDetails = s.RequestedAuthorizationDetails
+	r.GrantedAuthorizationDetails = s.GrantedAuthorizationDetails
+	if s.Form != nil {
+		r.Form = s.Form
+	}
+	return r, nil
+}
@@ -0,0 +1,71 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package storage
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
+)
+
+func TestStoredRequest_SessionCodec(t *testing.T) {
+	r := fosite.NewRequest()
+	r.ID = "foo"
+	r.Session = &fosite.DefaultSession{Subject: "peter"}
+
+	// Requests stored as JSON, e.g. before a codec was configured.
+	legacy, err := NewStoredRequest(r)
+	require.NoError(t, err)
+	assert.Empty(t, legacy.SessionData)
+	assert.JSONEq(t, `{"ExpiresAt":null,"Username":"","Subject":"peter","Extra":null}`, string(legacy.Session))
+
+	codec := &fosite.VersionedSessionCodec{Codec: fosite.GobSessionCodec{}, Version: 1, Migrations: map[int]fosite.SessionMigration{
+		0: func(data []byte) ([]byte, error) {
+			var session fosite.DefaultSession
+			if err := json.Unmarshal(data, &session); err != nil {
+				return nil, err
+			}
+			return fosite.GobSessionCodec{}.Encode(&session)
+		},
+	}}
+	stored, err := NewStoredRequestWithCodec(r, codec)
+	require.NoError(t, err)
+	assert.Empty(t, stored.Session)
+	assert.NotEmpty(t, stored.SessionData)
+
+	for _, s := range []*StoredRequest{legacy, stored} {
+		restored, err := s.ToRequestWithCodec(nil, new(fosite.DefaultSession), codec)
+		require.NoError(t, err)
+		assert.Equal(t, "peter", restored.GetSession().GetSubject())
+	}
+
+	_, err = stored.ToRequest(nil, new(fosite.DefaultSession))
+	require.Error(t, err, "sessions encoded by a codec can not be decoded without it")
+
+	restored, err := legacy.ToRequest(nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "peter", restored.GetSession().GetSubject())
+}
@@ -0,0 +1,101 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package sql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Dialect describes the differences between the supported databases.
+type Dialect struct {
+	// Name is the name of the dialect, e.g. "postgres".
+	Name string
+
+	placeholder func(n int) string
+
+	textType      string
+	timestampType string
+	boolType      string
+
+	// deleteBatch returns a statement deleting at most a given number of rows matching the condition. The limit is
+	// the last argument.
+	deleteBatch func(table, condition string) string
+}
+
+var (
+	// Postgres is the dialect for PostgreSQL and CockroachDB.
+	Postgres = &Dialect{
+		Name:          "postgres",
+		placeholder:   func(n int) string { return "$" + strconv.Itoa(n) },
+		textType:      "TEXT",
+		timestampType: "TIMESTAMP",
+		boolType:      "BOOLEAN",
+		deleteBatch:   deleteBatchSubquery,
+	}
+
+	// MySQL is the dialect for MySQL and MariaDB. The connection must be opened with parseTime=true.
+	MySQL = &Dialect{
+		Name:          "mysql",
+		placeholder:   func(int) string { return "?" },
+		textType:      "MEDIUMTEXT",
+		timestampType: "DATETIME(6)",
+		boolType:      "BOOL",
+		deleteBatch: func(table, condition string) string {
+			return fmt.Sprintf("DELETE FROM %s WHERE %s LIMIT ?", table, condition)
+		},
+	}
+
+	// SQLite is the dialect for SQLite. It is meant for development and tests.
+	SQLite = &Dialect{
+		Name:          "sqlite3",
+		placeholder:   func(int) string { return "?" },
+		textType:      "TEXT",
+		timestampType: "DATETIME",
+		boolType:      "BOOLEAN",
+		deleteBatch:   deleteBatchSubquery,
+	}
+)
+
+func deleteBatchSubquery(table, condition string) string {
+	return fmt.Sprintf("DELETE FROM %[1]s WHERE signature IN (SELECT signature FROM %[1]s WHERE %[2]s LIMIT ?)", table, condition)
+}
+
+// rebind replaces the "?" placeholders of the query with the placeholders of the dialect.
+func (d *Dialect) rebind(query string) string {
+	if d.placeholder(1) == "?" {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteString(d.placeholder(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
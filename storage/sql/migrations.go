@@ -0,0 +1,124 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package sql
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ory/x/errorsx"
+)
+
+const migrationsTable = "fosite_schema_migrations"
+
+type migration struct {
+	version    int
+	statements func(d *Dialect) []string
+}
+
+// migrations must only ever be appended to, applied migrations are never changed.
+var migrations = []migration{
+	{version: 1, statements: createTables},
+	{version: 2, statements: createRefreshTokenRevocationTable},
+}
+
+func createTables(d *Dialect) []string {
+	statements := []string{
+		fmt.Sprintf("CREATE TABLE %s (id VARCHAR(255) NOT NULL PRIMARY KEY, data %s NOT NULL)", clientTable, d.textType),
+		fmt.Sprintf("CREATE TABLE %s (signature VARCHAR(64) NOT NULL PRIMARY KEY, expires_at %s NOT NULL)", jtiTable, d.timestampType),
+		fmt.Sprintf("CREATE INDEX %[1]s_expires_at_idx ON %[1]s (expires_at)", jtiTable),
+	}
+
+	for _, table := range requestTables {
+		statements = append(statements,
+			fmt.Sprintf(`CREATE TABLE %s (
+	signature VARCHAR(64) NOT NULL PRIMARY KEY,
+	request_id VARCHAR(255) NOT NULL,
+	client_id VARCHAR(255) NOT NULL,
+	requested_at %[2]s NOT NULL,
+	expires_at %[2]s NULL,
+	rotated_at %[2]s NULL,
+	active %[3]s NOT NULL,
+	data %[4]s NOT NULL
+)`, table, d.timestampType, d.boolType, d.textType),
+			fmt.Sprintf("CREATE INDEX %[1]s_request_id_idx ON %[1]s (request_id)", table),
+			fmt.Sprintf("CREATE INDEX %[1]s_client_id_idx ON %[1]s (client_id)", table),
+			fmt.Sprintf("CREATE INDEX %[1]s_expires_at_idx ON %[1]s (expires_at)", table),
+		)
+	}
+	return statements
+}
+
+// createRefreshTokenRevocationTable records when the refresh tokens of a request were revoked, which is required for
+// stateless refresh tokens, see oauth2.StatelessRefreshTokenStorage.
+func createRefreshTokenRevocationTable(d *Dialect) []string {
+	return []string{
+		fmt.Sprintf("CREATE TABLE %s (request_id VARCHAR(255) NOT NULL PRIMARY KEY, revoked_at %s NOT NULL)", refreshRevocationTable, d.timestampType),
+	}
+}
+
+// Migrate creates or upgrades the schema. Every migration is applied in its own transaction and recorded in the
+// fosite_schema_migrations table, so Migrate can safely be called on every start.
+func (s *Store) Migrate(ctx context.Context) error {
+	if _, err := s.DB.ExecContext(ctx, fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (version INTEGER NOT NULL PRIMARY KEY, applied_at %s NOT NULL)",
+		migrationsTable, s.Dialect.timestampType,
+	)); err != nil {
+		return errorsx.WithStack(err)
+	}
+
+	var current int
+	if err := s.DB.QueryRowContext(ctx, fmt.Sprintf("SELECT COALESCE(MAX(version), 0) FROM %s", migrationsTable)).Scan(&current); err != nil {
+		return errorsx.WithStack(err)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := s.apply(ctx, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) apply(ctx context.Context, m migration) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return errorsx.WithStack(err)
+	}
+
+	for _, statement := range m.statements(s.Dialect) {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			_ = tx.Rollback()
+			return errorsx.WithStack(fmt.Errorf("unable to apply migration %d: %w", m.version, err))
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, s.Dialect.rebind(fmt.Sprintf("INSERT INTO %s (version, applied_at) VALUES (?, ?)", migrationsTable)), m.version, time.Now().UTC()); err != nil {
+		_ = tx.Rollback()
+		return errorsx.WithStack(err)
+	}
+	return errorsx.WithStack(tx.Commit())
+}
@@ -0,0 +1,516 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+// Package sql provides an implementation of the fosite storage interfaces for PostgreSQL and MySQL using
+// database/sql. Drivers are not imported by this package, register them in your application.
+//
+// Token signatures and JWT IDs are stored as SHA-256 hashes so that signatures of any length (for example of JWT
+// access tokens) can be indexed efficiently. Expired rows are removed with DeleteExpired, which should be called
+// periodically.
+//
+// Pushed authorization requests, device and CIBA sessions as well as RFC7523 issuer keys are not covered by this
+// package.
+package sql
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/storage"
+	"github.com/ory/x/errorsx"
+)
+
+const (
+	clientTable  = "fosite_client"
+	jtiTable     = "fosite_jti_blacklist"
+	codeTable    = "fosite_authorize_code"
+	accessTable  = "fosite_access_token"
+	refreshTable = "fosite_refresh_token"
+	pkceTable    = "fosite_pkce"
+	oidcTable    = "fosite_oidc_session"
+
+	refreshRevocationTable = "fosite_refresh_token_revocation"
+)
+
+var requestTables = []string{codeTable, accessTable, refreshTable, pkceTable, oidcTable}
+
+// ErrNoTransaction is returned by Commit and Rollback if the context does not carry a transaction.
+var ErrNoTransaction = errors.New("no transaction found in context")
+
+// Store implements fosite's storage interfaces on top of a SQL database. It also implements storage.Transactional,
+// all operations called with a context returned by BeginTX are executed within that transaction.
+type Store struct {
+	DB      *sql.DB
+	Dialect *Dialect
+
+	// SessionCodec, if set, encodes the sessions of stored requests instead of JSON, see fosite.SessionCodec.
+	SessionCodec fosite.SessionCodec
+}
+
+// NewStore returns a Store for the database. Call Migrate to create the schema.
+func NewStore(db *sql.DB, dialect *Dialect) *Store {
+	return &Store{DB: db, Dialect: dialect}
+}
+
+type txKey struct{}
+
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func (s *Store) BeginTX(ctx context.Context) (context.Context, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return ctx, errorsx.WithStack(err)
+	}
+	return context.WithValue(ctx, txKey{}, tx), nil
+}
+
+func (s *Store) Commit(ctx context.Context) error {
+	tx, ok := ctx.Value(txKey{}).(*sql.Tx)
+	if !ok {
+		return errorsx.WithStack(ErrNoTransaction)
+	}
+	return errorsx.WithStack(tx.Commit())
+}
+
+func (s *Store) Rollback(ctx context.Context) error {
+	tx, ok := ctx.Value(txKey{}).(*sql.Tx)
+	if !ok {
+		return errorsx.WithStack(ErrNoTransaction)
+	}
+	return errorsx.WithStack(tx.Rollback())
+}
+
+func (s *Store) conn(ctx context.Context) queryer {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+	return s.DB
+}
+
+func (s *Store) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	result, err := s.conn(ctx).ExecContext(ctx, s.Dialect.rebind(query), args...)
+	return result, errorsx.WithStack(err)
+}
+
+func (s *Store) queryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return s.conn(ctx).QueryRowContext(ctx, s.Dialect.rebind(query), args...)
+}
+
+// inTransaction runs fn in the transaction of the context or, if there is none, in a new transaction.
+func (s *Store) inTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+
+	ctx, err := s.BeginTX(ctx)
+	if err != nil {
+		return err
+	}
+	if err := fn(ctx); err != nil {
+		_ = s.Rollback(ctx)
+		return err
+	}
+	return s.Commit(ctx)
+}
+
+func hash(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	if t.IsZero() {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
+
+// updated returns fosite.ErrNotFound if the update did not match a row.
+func (s *Store) updated(ctx context.Context, result sql.Result, table, column, value string) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return errorsx.WithStack(err)
+	} else if n > 0 {
+		return nil
+	}
+
+	// MySQL reports zero affected rows if the row was found but not changed.
+	var exists int
+	if err := s.queryRow(ctx, fmt.Sprintf("SELECT 1 FROM %s WHERE %s = ?", table, column), value).Scan(&exists); errors.Is(err, sql.ErrNoRows) {
+		return errorsx.WithStack(fosite.ErrNotFound)
+	} else if err != nil {
+		return errorsx.WithStack(err)
+	}
+	return nil
+}
+
+func (s *Store) GetClient(ctx context.Context, id string) (fosite.Client, error) {
+	var data string
+	if err := s.queryRow(ctx, fmt.Sprintf("SELECT data FROM %s WHERE id = ?", clientTable), id).Scan(&data); errors.Is(err, sql.ErrNoRows) {
+		return nil, errorsx.WithStack(fosite.ErrNotFound)
+	} else if err != nil {
+		return nil, errorsx.WithStack(err)
+	}
+
+	var cl fosite.DefaultOpenIDConnectClient
+	if err := json.Unmarshal([]byte(data), &cl); err != nil {
+		return nil, errorsx.WithStack(err)
+	}
+	return &cl, nil
+}
+
+// CreateClient stores the client. Clients are persisted as JSON and read back as *fosite.DefaultOpenIDConnectClient.
+func (s *Store) CreateClient(ctx context.Context, client fosite.Client) error {
+	data, err := json.Marshal(client)
+	if err != nil {
+		return errorsx.WithStack(err)
+	}
+	_, err = s.exec(ctx, fmt.Sprintf("INSERT INTO %s (id, data) VALUES (?, ?)", clientTable), client.GetID(), string(data))
+	return err
+}
+
+func (s *Store) UpdateClient(ctx context.Context, client fosite.Client) error {
+	data, err := json.Marshal(client)
+	if err != nil {
+		return errorsx.WithStack(err)
+	}
+	result, err := s.exec(ctx, fmt.Sprintf("UPDATE %s SET data = ? WHERE id = ?", clientTable), string(data), client.GetID())
+	if err != nil {
+		return err
+	}
+	return s.updated(ctx, result, clientTable, "id", client.GetID())
+}
+
+func (s *Store) DeleteClient(ctx context.Context, id string) error {
+	result, err := s.exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE id = ?", clientTable), id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return errorsx.WithStack(err)
+	} else if n == 0 {
+		return errorsx.WithStack(fosite.ErrNotFound)
+	}
+	return nil
+}
+
+func (s *Store) ClientAssertionJWTValid(ctx context.Context, jti string) error {
+	var exp time.Time
+	if err := s.queryRow(ctx, fmt.Sprintf("SELECT expires_at FROM %s WHERE signature = ?", jtiTable), hash(jti)).Scan(&exp); errors.Is(err, sql.ErrNoRows) {
+		return nil
+	} else if err != nil {
+		return errorsx.WithStack(err)
+	}
+
+	if exp.After(time.Now()) {
+		return errorsx.WithStack(fosite.ErrJTIKnown)
+	}
+	return nil
+}
+
+func (s *Store) SetClientAssertionJWT(ctx context.Context, jti string, exp time.Time) error {
+	return s.inTransaction(ctx, func(ctx context.Context) error {
+		// delete expired jtis
+		if _, err := s.exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE expires_at < ?", jtiTable), time.Now().UTC()); err != nil {
+			return err
+		}
+
+		if err := s.ClientAssertionJWTValid(ctx, jti); err != nil {
+			return err
+		}
+
+		_, err := s.exec(ctx, fmt.Sprintf("INSERT INTO %s (signature, expires_at) VALUES (?, ?)", jtiTable), hash(jti), exp.UTC())
+		return err
+	})
+}
+
+func (s *Store) IsJWTUsed(ctx context.Context, jti string) (bool, error) {
+	if err := s.ClientAssertionJWTValid(ctx, jti); errors.Is(err, fosite.ErrJTIKnown) {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+	return false, nil
+}
+
+func (s *Store) MarkJWTUsedForTime(ctx context.Context, jti string, exp time.Time) error {
+	return s.SetClientAssertionJWT(ctx, jti, exp)
+}
+
+func (s *Store) createSession(ctx context.Context, table, signature string, req fosite.Requester, tokenType fosite.TokenType) error {
+	stored, err := storage.NewStoredRequestWithCodec(req, s.SessionCodec)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return errorsx.WithStack(err)
+	}
+
+	var expiresAt time.Time
+	if req.GetSession() != nil {
+		expiresAt = req.GetSession().GetExpiresAt(tokenType)
+	}
+
+	_, err = s.exec(ctx, fmt.Sprintf(
+		"INSERT INTO %s (signature, request_id, client_id, requested_at, expires_at, active, data) VALUES (?, ?, ?, ?, ?, ?, ?)", table),
+		hash(signature), req.GetID(), stored.ClientID, req.GetRequestedAt().UTC(), nullTime(expiresAt), true, string(data),
+	)
+	return err
+}
+
+func (s *Store) getSession(ctx context.Context, table, signature string, session fosite.Session) (fosite.Requester, bool, error) {
+	var (
+		active bool
+		data   string
+	)
+	if err := s.queryRow(ctx, fmt.Sprintf("SELECT active, data FROM %s WHERE signature = ?", table), hash(signature)).Scan(&active, &data); errors.Is(err, sql.ErrNoRows) {
+		return nil, false, errorsx.WithStack(fosite.ErrNotFound)
+	} else if err != nil {
+		return nil, false, errorsx.WithStack(err)
+	}
+
+	var stored storage.StoredRequest
+	if err := json.Unmarshal([]byte(data), &stored); err != nil {
+		return nil, false, errorsx.WithStack(err)
+	}
+
+	client, err := s.GetClient(ctx, stored.ClientID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	r, err := stored.ToRequestWithCodec(client, session, s.SessionCodec)
+	if err != nil {
+		return nil, false, err
+	}
+	return r, active, nil
+}
+
+func (s *Store) deleteSession(ctx context.Context, table, signature string) error {
+	_, err := s.exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE signature = ?", table), hash(signature))
+	return err
+}
+
+func (s *Store) CreateAuthorizeCodeSession(ctx context.Context, code string, req fosite.Requester) error {
+	return s.createSession(ctx, codeTable, code, req, fosite.AuthorizeCode)
+}
+
+func (s *Store) GetAuthorizeCodeSession(ctx context.Context, code string, session fosite.Session) (fosite.Requester, error) {
+	r, active, err := s.getSession(ctx, codeTable, code, session)
+	if err != nil {
+		return nil, err
+	} else if !active {
+		return r, errorsx.WithStack(fosite.ErrInvalidatedAuthorizeCode)
+	}
+	return r, nil
+}
+
+func (s *Store) InvalidateAuthorizeCodeSession(ctx context.Context, code string) error {
+	result, err := s.exec(ctx, fmt.Sprintf("UPDATE %s SET active = ? WHERE signature = ?", codeTable), false, hash(code))
+	if err != nil {
+		return err
+	}
+	return s.updated(ctx, result, codeTable, "signature", hash(code))
+}
+
+func (s *Store) CreatePKCERequestSession(ctx context.Context, code string, req fosite.Requester) error {
+	return s.createSession(ctx, pkceTable, code, req, fosite.AuthorizeCode)
+}
+
+func (s *Store) GetPKCERequestSession(ctx context.Context, code string, session fosite.Session) (fosite.Requester, error) {
+	r, _, err := s.getSession(ctx, pkceTable, code, session)
+	return r, err
+}
+
+func (s *Store) DeletePKCERequestSession(ctx context.Context, code string) error {
+	return s.deleteSession(ctx, pkceTable, code)
+}
+
+func (s *Store) CreateOpenIDConnectSession(ctx context.Context, authorizeCode string, req fosite.Requester) error {
+	return s.createSession(ctx, oidcTable, authorizeCode, req, fosite.AuthorizeCode)
+}
+
+func (s *Store) GetOpenIDConnectSession(ctx context.Context, authorizeCode string, req fosite.Requester) (fosite.Requester, error) {
+	var session fosite.Session
+	if req != nil {
+		session = req.GetSession()
+	}
+	r, _, err := s.getSession(ctx, oidcTable, authorizeCode, session)
+	return r, err
+}
+
+// DeleteOpenIDConnectSession is not really called from anywhere and it is deprecated.
+func (s *Store) DeleteOpenIDConnectSession(ctx context.Context, authorizeCode string) error {
+	return s.deleteSession(ctx, oidcTable, authorizeCode)
+}
+
+func (s *Store) CreateAccessTokenSession(ctx context.Context, signature string, req fosite.Requester) error {
+	return s.createSession(ctx, accessTable, signature, req, fosite.AccessToken)
+}
+
+func (s *Store) GetAccessTokenSession(ctx context.Context, signature string, session fosite.Session) (fosite.Requester, error) {
+	r, _, err := s.getSession(ctx, accessTable, signature, session)
+	return r, err
+}
+
+func (s *Store) DeleteAccessTokenSession(ctx context.Context, signature string) error {
+	return s.deleteSession(ctx, accessTable, signature)
+}
+
+func (s *Store) CreateRefreshTokenSession(ctx context.Context, signature string, req fosite.Requester) error {
+	return s.createSession(ctx, refreshTable, signature, req, fosite.RefreshToken)
+}
+
+func (s *Store) GetRefreshTokenSession(ctx context.Context, signature string, session fosite.Session) (fosite.Requester, error) {
+	r, active, err := s.getSession(ctx, refreshTable, signature, session)
+	if err != nil {
+		return nil, err
+	} else if !active {
+		return r, errorsx.WithStack(fosite.ErrInactiveToken)
+	}
+	return r, nil
+}
+
+func (s *Store) DeleteRefreshTokenSession(ctx context.Context, signature string) error {
+	return s.deleteSession(ctx, refreshTable, signature)
+}
+
+func (s *Store) RotateRefreshToken(ctx context.Context, signature string, rotatedAt time.Time) error {
+	result, err := s.exec(ctx, fmt.Sprintf("UPDATE %s SET rotated_at = ? WHERE signature = ?", refreshTable), rotatedAt.UTC(), hash(signature))
+	if err != nil {
+		return err
+	}
+	return s.updated(ctx, result, refreshTable, "signature", hash(signature))
+}
+
+func (s *Store) GetRefreshTokenRotatedAt(ctx context.Context, signature string) (time.Time, error) {
+	var rotatedAt sql.NullTime
+	if err := s.queryRow(ctx, fmt.Sprintf("SELECT rotated_at FROM %s WHERE signature = ?", refreshTable), hash(signature)).Scan(&rotatedAt); errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, errorsx.WithStack(fosite.ErrNotFound)
+	} else if err != nil {
+		return time.Time{}, errorsx.WithStack(err)
+	} else if !rotatedAt.Valid {
+		return time.Time{}, errorsx.WithStack(fosite.ErrNotFound)
+	}
+	return rotatedAt.Time, nil
+}
+
+// RevokeRefreshToken marks all refresh tokens issued for the request as inactive and records the time of the
+// revocation, see oauth2.StatelessRefreshTokenStorage.
+func (s *Store) RevokeRefreshToken(ctx context.Context, requestID string) error {
+	return s.inTransaction(ctx, func(ctx context.Context) error {
+		if _, err := s.exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE request_id = ?", refreshRevocationTable), requestID); err != nil {
+			return err
+		}
+		if _, err := s.exec(ctx, fmt.Sprintf("INSERT INTO %s (request_id, revoked_at) VALUES (?, ?)", refreshRevocationTable), requestID, time.Now().UTC()); err != nil {
+			return err
+		}
+		_, err := s.exec(ctx, fmt.Sprintf("UPDATE %s SET active = ? WHERE request_id = ?", refreshTable), false, requestID)
+		return err
+	})
+}
+
+func (s *Store) GetRefreshTokenRevokedAt(ctx context.Context, requestID string) (time.Time, error) {
+	var revokedAt time.Time
+	if err := s.queryRow(ctx, fmt.Sprintf("SELECT revoked_at FROM %s WHERE request_id = ?", refreshRevocationTable), requestID).Scan(&revokedAt); errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, errorsx.WithStack(fosite.ErrNotFound)
+	} else if err != nil {
+		return time.Time{}, errorsx.WithStack(err)
+	}
+	return revokedAt, nil
+}
+
+func (s *Store) RevokeAccessToken(ctx context.Context, requestID string) error {
+	_, err := s.exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE request_id = ?", accessTable), requestID)
+	return err
+}
+
+// DeleteExpired removes all rows that expired before notAfter. Rows are deleted in batches of batchSize to keep
+// locks short. It returns the number of deleted rows.
+func (s *Store) DeleteExpired(ctx context.Context, notAfter time.Time, batchSize int) (int64, error) {
+	var total int64
+	for _, table := range append([]string{jtiTable}, requestTables...) {
+		n, err := s.deleteBatched(ctx, table, "expires_at < ?", 0, batchSize, notAfter.UTC())
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+func (s *Store) FlushInactiveAccessTokens(ctx context.Context, before time.Time, limit int) error {
+	_, err := s.deleteBatched(ctx, accessTable, "expires_at < ?", limit, 0, before.UTC())
+	return err
+}
+
+func (s *Store) FlushInactiveRefreshTokens(ctx context.Context, before time.Time, limit int) error {
+	_, err := s.deleteBatched(ctx, refreshTable, "expires_at < ? OR (active = ? AND requested_at < ?)", limit, 0, before.UTC(), false, before.UTC())
+	return err
+}
+
+func (s *Store) FlushExpiredAuthorizeCodes(ctx context.Context, before time.Time, limit int) error {
+	_, err := s.deleteBatched(ctx, codeTable, "expires_at < ?", limit, 0, before.UTC())
+	return err
+}
+
+// deleteBatched deletes up to limit rows matching the condition, or all of them if limit is zero or less, in batches
+// of batchSize rows. It returns the number of deleted rows.
+func (s *Store) deleteBatched(ctx context.Context, table, condition string, limit, batchSize int, args ...interface{}) (int64, error) {
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+
+	query := s.Dialect.rebind(s.Dialect.deleteBatch(table, condition))
+	var total int64
+	for limit <= 0 || total < int64(limit) {
+		size := int64(batchSize)
+		if limit > 0 && int64(limit)-total < size {
+			size = int64(limit) - total
+		}
+
+		result, err := s.conn(ctx).ExecContext(ctx, query, append(args, size)...)
+		if err != nil {
+			return total, errorsx.WithStack(err)
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return total, errorsx.WithStack(err)
+		}
+		total += n
+		if n < size {
+			break
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,292 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/oauth2"
+	"github.com/ory/fosite/handler/openid"
+	"github.com/ory/fosite/handler/pkce"
+	"github.com/ory/fosite/storage"
+)
+
+var (
+	_ fosite.Storage                      = (*Store)(nil)
+	_ oauth2.CoreStorage                  = (*Store)(nil)
+	_ oauth2.TokenRevocationStorage       = (*Store)(nil)
+	_ oauth2.RefreshTokenRotationStorage  = (*Store)(nil)
+	_ oauth2.StatelessRefreshTokenStorage = (*Store)(nil)
+	_ openid.OpenIDConnectRequestStorage  = (*Store)(nil)
+	_ pkce.PKCERequestStorage             = (*Store)(nil)
+	_ storage.Transactional               = (*Store)(nil)
+	_ storage.Expirable                   = (*Store)(nil)
+)
+
+func newTestStore(t *testing.T) *Store {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "fosite.db"))
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+
+	s := NewStore(db, SQLite)
+	require.NoError(t, s.Migrate(context.Background()))
+	require.NoError(t, s.CreateClient(context.Background(), &fosite.DefaultClient{ID: "foo", Scopes: []string{"offline"}}))
+	return s
+}
+
+func newTestRequest(id string, expires map[fosite.TokenType]time.Time) *fosite.Request {
+	r := fosite.NewRequest()
+	r.ID = id
+	r.RequestedAt = time.Now().UTC().Round(time.Second)
+	r.Client = &fosite.DefaultClient{ID: "foo"}
+	r.GrantedScope = fosite.Arguments{"offline"}
+	r.Form.Set("foo", "bar")
+	r.Session = &fosite.DefaultSession{Subject: "peter", ExpiresAt: expires}
+	return r
+}
+
+func TestRebind(t *testing.T) {
+	assert.Equal(t, "SELECT a FROM b WHERE c = $1 AND d = $2", Postgres.rebind("SELECT a FROM b WHERE c = ? AND d = ?"))
+	assert.Equal(t, "SELECT a FROM b WHERE c = ?", MySQL.rebind("SELECT a FROM b WHERE c = ?"))
+}
+
+func TestMigrate(t *testing.T) {
+	s := newTestStore(t)
+	require.NoError(t, s.Migrate(context.Background()))
+
+	for _, d := range []*Dialect{Postgres, MySQL, SQLite} {
+		for _, statement := range append(createTables(d), createRefreshTokenRevocationTable(d)...) {
+			assert.False(t, strings.Contains(statement, "%!"), statement)
+		}
+	}
+}
+
+func TestClients(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	cl, err := s.GetClient(ctx, "foo")
+	require.NoError(t, err)
+	assert.EqualValues(t, fosite.Arguments{"offline"}, cl.GetScopes())
+
+	require.NoError(t, s.UpdateClient(ctx, &fosite.DefaultClient{ID: "foo", Scopes: []string{"offline"}}))
+	assert.True(t, errors.Is(s.UpdateClient(ctx, &fosite.DefaultClient{ID: "bar"}), fosite.ErrNotFound))
+
+	require.NoError(t, s.DeleteClient(ctx, "foo"))
+	assert.True(t, errors.Is(s.DeleteClient(ctx, "foo"), fosite.ErrNotFound))
+	_, err = s.GetClient(ctx, "foo")
+	assert.True(t, errors.Is(err, fosite.ErrNotFound))
+}
+
+func TestClientAssertionJWT(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	require.NoError(t, s.SetClientAssertionJWT(ctx, "expired", time.Now().Add(-time.Minute)))
+	require.NoError(t, s.ClientAssertionJWTValid(ctx, "expired"))
+	require.NoError(t, s.SetClientAssertionJWT(ctx, "expired", time.Now().Add(time.Minute)))
+
+	require.NoError(t, s.MarkJWTUsedForTime(ctx, "jti", time.Now().Add(time.Minute)))
+	assert.True(t, errors.Is(s.SetClientAssertionJWT(ctx, "jti", time.Now().Add(time.Minute)), fosite.ErrJTIKnown))
+	used, err := s.IsJWTUsed(ctx, "jti")
+	require.NoError(t, err)
+	assert.True(t, used)
+}
+
+func TestAuthorizeCodeSession(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	req := newTestRequest("req", nil)
+	require.NoError(t, s.CreateAuthorizeCodeSession(ctx, "code", req))
+
+	session := new(fosite.DefaultSession)
+	got, err := s.GetAuthorizeCodeSession(ctx, "code", session)
+	require.NoError(t, err)
+	assert.Equal(t, "req", got.GetID())
+	assert.True(t, req.RequestedAt.Equal(got.GetRequestedAt()))
+	assert.Equal(t, "foo", got.GetClient().GetID())
+	assert.Equal(t, "bar", got.GetRequestForm().Get("foo"))
+	assert.Equal(t, "peter", session.Subject)
+
+	require.NoError(t, s.InvalidateAuthorizeCodeSession(ctx, "code"))
+	require.NoError(t, s.InvalidateAuthorizeCodeSession(ctx, "code"))
+	got, err = s.GetAuthorizeCodeSession(ctx, "code", nil)
+	assert.True(t, errors.Is(err, fosite.ErrInvalidatedAuthorizeCode))
+	require.NotNil(t, got)
+
+	assert.True(t, errors.Is(s.InvalidateAuthorizeCodeSession(ctx, "unknown"), fosite.ErrNotFound))
+}
+
+func TestTokenSessions(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	req := newTestRequest("req", nil)
+	require.NoError(t, s.CreateAccessTokenSession(ctx, strings.Repeat("a", 512), req))
+	require.NoError(t, s.CreateAccessTokenSession(ctx, "at", req))
+	require.NoError(t, s.CreateRefreshTokenSession(ctx, "rt", req))
+
+	_, err := s.GetAccessTokenSession(ctx, strings.Repeat("a", 512), nil)
+	require.NoError(t, err)
+
+	require.NoError(t, s.RevokeAccessToken(ctx, "req"))
+	_, err = s.GetAccessTokenSession(ctx, "at", nil)
+	assert.True(t, errors.Is(err, fosite.ErrNotFound))
+
+	_, err = s.GetRefreshTokenRotatedAt(ctx, "rt")
+	assert.True(t, errors.Is(err, fosite.ErrNotFound))
+	rotatedAt := time.Now().UTC().Round(time.Second)
+	require.NoError(t, s.RotateRefreshToken(ctx, "rt", rotatedAt))
+	got, err := s.GetRefreshTokenRotatedAt(ctx, "rt")
+	require.NoError(t, err)
+	assert.True(t, rotatedAt.Equal(got))
+
+	require.NoError(t, s.RevokeRefreshToken(ctx, "req"))
+	r, err := s.GetRefreshTokenSession(ctx, "rt", nil)
+	assert.True(t, errors.Is(err, fosite.ErrInactiveToken))
+	require.NotNil(t, r)
+
+	require.NoError(t, s.DeleteRefreshTokenSession(ctx, "rt"))
+	_, err = s.GetRefreshTokenSession(ctx, "rt", nil)
+	assert.True(t, errors.Is(err, fosite.ErrNotFound))
+}
+
+func TestPKCEAndOpenIDConnectSessions(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	req := newTestRequest("req", nil)
+	req.Session = &openid.DefaultSession{Subject: "peter"}
+	require.NoError(t, s.CreatePKCERequestSession(ctx, "code", req))
+	require.NoError(t, s.CreateOpenIDConnectSession(ctx, "code", req))
+
+	got, err := s.GetOpenIDConnectSession(ctx, "code", &fosite.Request{Session: new(openid.DefaultSession)})
+	require.NoError(t, err)
+	assert.Equal(t, "peter", got.GetSession().(*openid.DefaultSession).Subject)
+
+	_, err = s.GetPKCERequestSession(ctx, "code", nil)
+	require.NoError(t, err)
+	require.NoError(t, s.DeletePKCERequestSession(ctx, "code"))
+	_, err = s.GetPKCERequestSession(ctx, "code", nil)
+	assert.True(t, errors.Is(err, fosite.ErrNotFound))
+}
+
+func TestRefreshTokenRevokedAt(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	_, err := s.GetRefreshTokenRevokedAt(ctx, "req")
+	assert.True(t, errors.Is(err, fosite.ErrNotFound))
+
+	for i := 0; i < 2; i++ {
+		before := time.Now().UTC().Add(-time.Second)
+		require.NoError(t, s.RevokeRefreshToken(ctx, "req"))
+		revokedAt, err := s.GetRefreshTokenRevokedAt(ctx, "req")
+		require.NoError(t, err)
+		assert.True(t, revokedAt.After(before), "%s", revokedAt)
+	}
+}
+
+func TestTransaction(t *testing.T) {
+	s := newTestStore(t)
+
+	ctx, err := s.BeginTX(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, s.CreateAccessTokenSession(ctx, "at", newTestRequest("req", nil)))
+	_, err = s.GetAccessTokenSession(ctx, "at", nil)
+	require.NoError(t, err)
+	require.NoError(t, s.Rollback(ctx))
+
+	_, err = s.GetAccessTokenSession(context.Background(), "at", nil)
+	assert.True(t, errors.Is(err, fosite.ErrNotFound))
+	assert.True(t, errors.Is(s.Commit(context.Background()), ErrNoTransaction))
+}
+
+func TestDeleteExpired(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	expired := newTestRequest("expired", map[fosite.TokenType]time.Time{fosite.AccessToken: time.Now().Add(-time.Hour)})
+	for _, signature := range []string{"a", "b", "c"} {
+		require.NoError(t, s.CreateAccessTokenSession(ctx, signature, expired))
+	}
+	require.NoError(t, s.CreateAccessTokenSession(ctx, "valid", newTestRequest("valid", map[fosite.TokenType]time.Time{fosite.AccessToken: time.Now().Add(time.Hour)})))
+	require.NoError(t, s.CreateRefreshTokenSession(ctx, "unlimited", newTestRequest("unlimited", nil)))
+	require.NoError(t, s.SetClientAssertionJWT(ctx, "jti", time.Now().Add(time.Minute)))
+
+	n, err := s.DeleteExpired(ctx, time.Now(), 2)
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, n)
+
+	_, err = s.GetAccessTokenSession(ctx, "a", nil)
+	assert.True(t, errors.Is(err, fosite.ErrNotFound))
+	_, err = s.GetAccessTokenSession(ctx, "valid", nil)
+	require.NoError(t, err)
+	_, err = s.GetRefreshTokenSession(ctx, "unlimited", nil)
+	require.NoError(t, err)
+}
+
+func TestDeleteExpiredInTransaction(t *testing.T) {
+	s := newTestStore(t)
+	require.NoError(t, s.CreateAccessTokenSession(context.Background(), "expired", newTestRequest("expired", map[fosite.TokenType]time.Time{fosite.AccessToken: time.Now().Add(-time.Hour)})))
+
+	ctx, err := s.BeginTX(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, s.FlushInactiveAccessTokens(ctx, time.Now(), 0))
+	_, err = s.GetAccessTokenSession(ctx, "expired", nil)
+	assert.True(t, errors.Is(err, fosite.ErrNotFound))
+	require.NoError(t, s.Rollback(ctx))
+
+	_, err = s.GetAccessTokenSession(context.Background(), "expired", nil)
+	require.NoError(t, err)
+}
+
+func TestFlushInactiveRefreshTokens(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	require.NoError(t, s.CreateRefreshTokenSession(ctx, "expired", newTestRequest("expired", map[fosite.TokenType]time.Time{fosite.RefreshToken: time.Now().Add(-time.Hour)})))
+	require.NoError(t, s.CreateRefreshTokenSession(ctx, "revoked", newTestRequest("revoked", nil)))
+	require.NoError(t, s.RevokeRefreshToken(ctx, "revoked"))
+	require.NoError(t, s.CreateRefreshTokenSession(ctx, "active", newTestRequest("active", nil)))
+
+	require.NoError(t, s.FlushInactiveRefreshTokens(ctx, time.Now().Add(time.Minute), 0))
+	for _, signature := range []string{"expired", "revoked"} {
+		_, err := s.GetRefreshTokenSession(ctx, signature, nil)
+		assert.True(t, errors.Is(err, fosite.ErrNotFound), signature)
+	}
+	_, err := s.GetRefreshTokenSession(ctx, "active", nil)
+	require.NoError(t, err)
+}
@@ -1,6 +1,10 @@
 package storage
 
-import "context"
+import (
+	"context"
+
+	"github.com/ory/fosite"
+)
 
 // A storage provider that has support for transactions should implement this interface to ensure atomicity for certain flows
 // that require transactional semantics. Fosite will call these methods (when atomicity is required) if and only if the storage
@@ -21,12 +25,16 @@ type Transactional interface {
 }
 
 // MaybeBeginTx is a helper function that can be used to initiate a transaction if the supplied storage
-// implements the `Transactional` interface.
+// implements the `Transactional` interface. The returned context is marked with `fosite.StorageTransactionContextKey`.
 func MaybeBeginTx(ctx context.Context, storage interface{}) (context.Context, error) {
 	// the type assertion checks whether the dynamic type of `storage` implements `Transactional`
 	txnStorage, transactional := storage.(Transactional)
 	if transactional {
-		return txnStorage.BeginTX(ctx)
+		txnCtx, err := txnStorage.BeginTX(ctx)
+		if err != nil {
+			return txnCtx, err
+		}
+		return context.WithValue(txnCtx, fosite.StorageTransactionContextKey, true), nil
 	} else {
 		return ctx, nil
 	}
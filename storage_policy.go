@@ -0,0 +1,120 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/x/errorsx"
+)
+
+// StorageRetryHook decides whether a failed storage operation is retried. The attempt starts at 1 and idempotent
+// reports whether the operation only reads, see IsIdempotentStorageOperation. Retrying a write which failed after
+// it was applied may apply it twice. Hooks may block to back off, but must return once the context is done.
+type StorageRetryHook func(ctx context.Context, operation string, attempt int, idempotent bool, err error) bool
+
+// StoragePolicy bounds the time storage operations, for example "CreateAccessTokenSession", may take and retries
+// failed operations. Storage implementations must honor the deadline of the context they are called with.
+type StoragePolicy struct {
+	// Timeout is the deadline of storage operations without an entry in OperationTimeouts. Zero means no deadline.
+	Timeout time.Duration
+
+	// OperationTimeouts overrides Timeout per operation, keyed by the name of the storage method.
+	OperationTimeouts map[string]time.Duration
+
+	// RetryHook, if set, is called when an operation fails and decides whether it is retried. Operations within a
+	// storage transaction are never retried, because the transaction may be aborted.
+	RetryHook StorageRetryHook
+}
+
+func (p *StoragePolicy) timeout(operation string) time.Duration {
+	if timeout, ok := p.OperationTimeouts[operation]; ok {
+		return timeout
+	}
+	return p.Timeout
+}
+
+// idempotentStorageOperations are the storage operations without the "Get" or "Is" prefix which only read.
+var idempotentStorageOperations = map[string]bool{
+	"Authenticate":            true,
+	"ClientAssertionJWTValid": true,
+}
+
+// IsIdempotentStorageOperation reports whether the storage operation only reads and can be retried safely.
+func IsIdempotentStorageOperation(operation string) bool {
+	return strings.HasPrefix(operation, "Get") || strings.HasPrefix(operation, "Is") || idempotentStorageOperations[operation]
+}
+
+// StorageOperation calls fn with a context bounded by the deadline of the operation, using the StoragePolicy of the
+// context which is set by the endpoints of the provider. Operations exceeding their deadline fail with
+// ErrServerError. Without a StoragePolicy fn is called with ctx.
+//
+// Operations are not retried if the context carries a storage transaction, see StorageTransactionContextKey.
+func StorageOperation(ctx context.Context, operation string, fn func(ctx context.Context) error) error {
+	if ctx == nil {
+		return fn(ctx)
+	}
+
+	policy, ok := ctx.Value(StoragePolicyContextKey).(*StoragePolicy)
+	if !ok || policy == nil {
+		return fn(ctx)
+	}
+
+	idempotent := IsIdempotentStorageOperation(operation)
+	inTx, _ := ctx.Value(StorageTransactionContextKey).(bool)
+	for attempt := 1; ; attempt++ {
+		err := policy.do(ctx, operation, fn)
+		if err == nil || ctx.Err() != nil || inTx || policy.RetryHook == nil || !policy.RetryHook(ctx, operation, attempt, idempotent, err) {
+			return err
+		}
+	}
+}
+
+func (p *StoragePolicy) do(ctx context.Context, operation string, fn func(ctx context.Context) error) error {
+	timeout := p.timeout(operation)
+	if timeout <= 0 {
+		return fn(ctx)
+	}
+
+	opCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := fn(opCtx)
+	if err != nil && (errors.Is(err, context.DeadlineExceeded) || errors.Is(opCtx.Err(), context.DeadlineExceeded)) {
+		return errorsx.WithStack(ErrServerError.WithHintf("The storage operation '%s' did not complete within %s.", operation, timeout).WithWrap(err).WithDebug(err.Error()))
+	}
+	return err
+}
+
+// withStoragePolicy adds the StoragePolicy of the provider to the context, unless the context already carries one.
+func (f *Fosite) withStoragePolicy(ctx context.Context) context.Context {
+	if f.StoragePolicy == nil || ctx == nil {
+		return ctx
+	} else if _, ok := ctx.Value(StoragePolicyContextKey).(*StoragePolicy); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, StoragePolicyContextKey, f.StoragePolicy)
+}
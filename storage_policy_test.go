@@ -0,0 +1,186 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/storage"
+)
+
+func TestStorageOperation(t *testing.T) {
+	block := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	for _, c := range []struct {
+		d         string
+		operation string
+		tx        bool
+		policy    *StoragePolicy
+		fn        func(ctx context.Context) error
+		calls     int
+		err       error
+		deadline  bool
+	}{
+		{
+			d:   "should pass through without policy",
+			fn:  func(ctx context.Context) error { return ErrNotFound },
+			err: ErrNotFound, calls: 1,
+		},
+		{
+			d:      "should set the deadline of the operation",
+			policy: &StoragePolicy{Timeout: time.Minute},
+			fn: func(ctx context.Context) error {
+				_, ok := ctx.Deadline()
+				assert.True(t, ok)
+				return nil
+			},
+			calls: 1, deadline: true,
+		},
+		{
+			d:      "should fail with a server error once the deadline is exceeded",
+			policy: &StoragePolicy{Timeout: 10 * time.Millisecond},
+			fn:     block,
+			err:    ErrServerError, calls: 1,
+		},
+		{
+			d:      "should prefer the timeout of the operation",
+			policy: &StoragePolicy{Timeout: time.Hour, OperationTimeouts: map[string]time.Duration{"GetClient": 10 * time.Millisecond}},
+			fn:     block,
+			err:    ErrServerError, calls: 1,
+		},
+		{
+			d:      "should not set a deadline if the timeout of the operation is zero",
+			policy: &StoragePolicy{Timeout: time.Minute, OperationTimeouts: map[string]time.Duration{"GetClient": 0}},
+			fn: func(ctx context.Context) error {
+				_, ok := ctx.Deadline()
+				assert.False(t, ok)
+				return nil
+			},
+			calls: 1,
+		},
+		{
+			d: "should retry while the hook allows it",
+			policy: &StoragePolicy{RetryHook: func(ctx context.Context, operation string, attempt int, idempotent bool, err error) bool {
+				assert.Equal(t, "GetClient", operation)
+				assert.True(t, idempotent)
+				return attempt < 3
+			}},
+			fn:  func(ctx context.Context) error { return ErrNotFound },
+			err: ErrNotFound, calls: 3,
+		},
+		{
+			d:         "should tell the hook that writes are not idempotent",
+			operation: "CreateAccessTokenSession",
+			policy: &StoragePolicy{RetryHook: func(ctx context.Context, operation string, attempt int, idempotent bool, err error) bool {
+				assert.False(t, idempotent)
+				return idempotent
+			}},
+			fn:  func(ctx context.Context) error { return ErrServerError },
+			err: ErrServerError, calls: 1,
+		},
+		{
+			d:  "should not retry within a transaction",
+			tx: true,
+			policy: &StoragePolicy{RetryHook: func(ctx context.Context, operation string, attempt int, idempotent bool, err error) bool {
+				t.Error("the hook must not be called within a transaction")
+				return true
+			}},
+			fn:  func(ctx context.Context) error { return ErrNotFound },
+			err: ErrNotFound, calls: 1,
+		},
+	} {
+		t.Run("case="+c.d, func(t *testing.T) {
+			ctx := context.Background()
+			if c.policy != nil {
+				ctx = context.WithValue(ctx, StoragePolicyContextKey, c.policy)
+			}
+
+			if c.tx {
+				ctx = context.WithValue(ctx, StorageTransactionContextKey, true)
+			}
+			operation := c.operation
+			if operation == "" {
+				operation = "GetClient"
+			}
+
+			var calls int
+			err := StorageOperation(ctx, operation, func(ctx context.Context) error {
+				calls++
+				return c.fn(ctx)
+			})
+			assert.Equal(t, c.calls, calls)
+			if c.err == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, c.err), "%+v", err)
+		})
+	}
+}
+
+type slowClientStore struct {
+	*storage.MemoryStore
+}
+
+func (s *slowClientStore) GetClient(ctx context.Context, id string) (Client, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestStoragePolicyTimesOutSlowStorage(t *testing.T) {
+	config := &compose.Config{StoragePolicy: &StoragePolicy{Timeout: 10 * time.Millisecond}}
+	store := &slowClientStore{MemoryStore: storage.NewExampleStore()}
+	f := compose.Compose(config, store, compose.NewOAuth2HMACStrategy(config, []byte("some-secret-thats-random-some-secret-thats-random-"), nil), nil, compose.OAuth2ClientCredentialsGrantFactory)
+
+	r := httptest.NewRequest("POST", "/token", strings.NewReader(url.Values{"grant_type": {"client_credentials"}}.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.SetBasicAuth("my-client", "foobar")
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := f.NewAccessRequest(context.Background(), r, new(DefaultSession))
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, ErrServerError), "%+v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the access request did not time out")
+	}
+}
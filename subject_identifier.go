@@ -0,0 +1,219 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+
+	"github.com/ory/go-convenience/stringslice"
+	"github.com/ory/x/errorsx"
+	"github.com/pkg/errors"
+)
+
+const (
+	// SubjectTypePublic is the subject identifier type which provides the same "sub" value to all clients, see
+	// https://openid.net/specs/openid-connect-core-1_0.html#SubjectIDTypes
+	SubjectTypePublic = "public"
+
+	// SubjectTypePairwise is the subject identifier type which provides a different "sub" value to each sector,
+	// see https://openid.net/specs/openid-connect-core-1_0.html#PairwiseAlg
+	SubjectTypePairwise = "pairwise"
+)
+
+// SubjectIdentifierAlgorithm computes the "sub" value a client receives for an end-user.
+type SubjectIdentifierAlgorithm interface {
+	// Obfuscate returns the subject identifier of the end-user identified by subject for the client.
+	Obfuscate(ctx context.Context, subject string, client Client) (string, error)
+}
+
+// SubjectIdentifierAlgorithmFunc is an adapter to allow the use of ordinary functions as SubjectIdentifierAlgorithm.
+type SubjectIdentifierAlgorithmFunc func(ctx context.Context, subject string, client Client) (string, error)
+
+func (f SubjectIdentifierAlgorithmFunc) Obfuscate(ctx context.Context, subject string, client Client) (string, error) {
+	return f(ctx, subject, client)
+}
+
+// PublicSubjectIdentifierAlgorithm returns the local subject identifier unchanged.
+var PublicSubjectIdentifierAlgorithm SubjectIdentifierAlgorithm = SubjectIdentifierAlgorithmFunc(func(_ context.Context, subject string, _ Client) (string, error) {
+	return subject, nil
+})
+
+// PairwiseSubjectIdentifierAlgorithm computes pairwise subject identifiers as the hex encoded SHA-256 hash of the
+// client's sector identifier, the local subject identifier, and Salt, as described in
+// https://openid.net/specs/openid-connect-core-1_0.html#PairwiseAlg
+type PairwiseSubjectIdentifierAlgorithm struct {
+	// Salt must be kept secret, otherwise the local subject identifiers can be recovered from the pairwise ones.
+	Salt []byte
+}
+
+func (a *PairwiseSubjectIdentifierAlgorithm) Obfuscate(_ context.Context, subject string, client Client) (string, error) {
+	if subject == "" {
+		return "", nil
+	}
+
+	sectorIdentifier, err := GetSectorIdentifier(client)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	_, _ = hash.Write([]byte(sectorIdentifier))
+	_, _ = hash.Write([]byte(subject))
+	_, _ = hash.Write(a.Salt)
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// SubjectIdentifierAlgorithms maps subject identifier types to the algorithm computing them.
+type SubjectIdentifierAlgorithms map[string]SubjectIdentifierAlgorithm
+
+// Obfuscate returns the subject identifier of the end-user for the client, using the algorithm of the subject type
+// the client registered. Clients which did not register a subject type receive public subject identifiers. The
+// subject is returned unchanged if no algorithms are configured.
+func (a SubjectIdentifierAlgorithms) Obfuscate(ctx context.Context, subject string, client Client) (string, error) {
+	if len(a) == 0 || client == nil {
+		return subject, nil
+	}
+
+	subjectType := SubjectTypePublic
+	if sc, ok := client.(SubjectTypeClient); ok && sc.GetSubjectType() != "" {
+		subjectType = sc.GetSubjectType()
+	}
+
+	algorithm, ok := a[subjectType]
+	if !ok {
+		if subjectType == SubjectTypePublic {
+			return subject, nil
+		}
+		return "", errorsx.WithStack(ErrServerError.WithDebugf("The subject type '%s' of OAuth 2.0 Client '%s' is not supported by this server.", subjectType, client.GetID()))
+	}
+	return algorithm.Obfuscate(ctx, subject, client)
+}
+
+// SubjectTypes returns the supported subject types in lexical order, which always include public subject identifiers.
+func (a SubjectIdentifierAlgorithms) SubjectTypes() []string {
+	types := []string{SubjectTypePublic}
+	for subjectType := range a {
+		if subjectType != SubjectTypePublic {
+			types = append(types, subjectType)
+		}
+	}
+	sort.Strings(types)
+	return types
+}
+
+// GetSectorIdentifier returns the host of the client's sector_identifier_uri or, if none was registered, the host
+// shared by all of its redirect URIs, see https://openid.net/specs/openid-connect-core-1_0.html#PairwiseAlg
+func GetSectorIdentifier(client Client) (string, error) {
+	if sc, ok := client.(SubjectTypeClient); ok && sc.GetSectorIdentifierURI() != "" {
+		u, err := url.Parse(sc.GetSectorIdentifierURI())
+		if err != nil || u.Host == "" {
+			return "", errorsx.WithStack(ErrServerError.WithDebugf("The sector_identifier_uri of OAuth 2.0 Client '%s' is not a valid URL.", client.GetID()))
+		}
+		return u.Host, nil
+	}
+
+	var host string
+	for _, raw := range client.GetRedirectURIs() {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return "", errorsx.WithStack(ErrServerError.WithWrap(err).WithDebugf("The redirect URI '%s' of OAuth 2.0 Client '%s' is not a valid URL.", raw, client.GetID()))
+		}
+		if host != "" && host != u.Host {
+			return "", errorsx.WithStack(ErrServerError.WithDebugf("OAuth 2.0 Client '%s' registered redirect URIs with different hosts, but no sector_identifier_uri.", client.GetID()))
+		}
+		host = u.Host
+	}
+
+	if host == "" {
+		return "", errorsx.WithStack(ErrServerError.WithDebugf("OAuth 2.0 Client '%s' registered neither a sector_identifier_uri nor a redirect URI.", client.GetID()))
+	}
+	return host, nil
+}
+
+// ValidateSectorIdentifierURI fetches the JSON array of redirect URIs located at sectorIdentifierURI and checks that
+// it contains all of redirectURIs, see https://openid.net/specs/openid-connect-registration-1_0.html#SectorIdentifierValidation
+//
+// If redirectURIs use different hosts, sectorIdentifierURI is required. The HTTP client defaults to http.DefaultClient.
+func ValidateSectorIdentifierURI(ctx context.Context, hc *http.Client, sectorIdentifierURI string, redirectURIs []string) error {
+	if sectorIdentifierURI == "" {
+		hosts := map[string]bool{}
+		for _, raw := range redirectURIs {
+			u, err := url.Parse(raw)
+			if err != nil {
+				return errors.Errorf("The redirect URI '%s' is not a valid URL.", raw)
+			}
+			hosts[u.Host] = true
+		}
+		if len(hosts) > 1 {
+			return errors.New("The parameter 'sector_identifier_uri' is required if the redirect URIs use different hosts.")
+		}
+		return nil
+	}
+
+	u, err := url.Parse(sectorIdentifierURI)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return errors.Errorf("The sector identifier URI '%s' must be an absolute HTTPS URL.", sectorIdentifierURI)
+	}
+
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+
+	req, err := http.NewRequest("GET", sectorIdentifierURI, nil)
+	if err != nil {
+		return errorsx.WithStack(err)
+	}
+
+	res, err := hc.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.Errorf("Unable to fetch the sector identifier URI '%s' because: %s.", sectorIdentifierURI, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return errors.Errorf("Unable to fetch the sector identifier URI '%s' because status code '%d' was expected, but got '%d'.", sectorIdentifierURI, http.StatusOK, res.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return errors.Errorf("Unable to read the sector identifier URI '%s' because: %s.", sectorIdentifierURI, err)
+	}
+
+	var registered []string
+	if err := json.Unmarshal(body, &registered); err != nil {
+		return errors.Errorf("The sector identifier URI '%s' must contain a JSON array of redirect URIs.", sectorIdentifierURI)
+	}
+
+	for _, redirectURI := range redirectURIs {
+		if !stringslice.Has(registered, redirectURI) {
+			return errors.Errorf("The redirect URI '%s' is not contained in the sector identifier URI '%s'.", redirectURI, sectorIdentifierURI)
+		}
+	}
+	return nil
+}
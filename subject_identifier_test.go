@@ -0,0 +1,157 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/fosite"
+)
+
+func TestSubjectIdentifierAlgorithms(t *testing.T) {
+	algorithms := SubjectIdentifierAlgorithms{
+		SubjectTypePairwise: &PairwiseSubjectIdentifierAlgorithm{Salt: []byte("some-salt")},
+	}
+	newClient := func(subjectType, sectorIdentifierURI string, redirectURIs ...string) Client {
+		return &DefaultOpenIDConnectClient{
+			DefaultClient:       &DefaultClient{ID: "foo", RedirectURIs: redirectURIs},
+			SubjectType:         subjectType,
+			SectorIdentifierURI: sectorIdentifierURI,
+		}
+	}
+	obfuscate := func(t *testing.T, client Client) string {
+		subject, err := algorithms.Obfuscate(context.Background(), "peter", client)
+		require.NoError(t, err)
+		return subject
+	}
+
+	t.Run("case=public clients receive the local subject", func(t *testing.T) {
+		assert.Equal(t, "peter", obfuscate(t, newClient("", "", "https://a.example.com/cb")))
+		assert.Equal(t, "peter", obfuscate(t, newClient(SubjectTypePublic, "", "https://a.example.com/cb")))
+		assert.Equal(t, "peter", obfuscate(t, &DefaultClient{ID: "foo"}))
+	})
+
+	t.Run("case=pairwise subjects are consistent within a sector", func(t *testing.T) {
+		sub := obfuscate(t, newClient(SubjectTypePairwise, "", "https://a.example.com/cb"))
+		assert.NotEqual(t, "peter", sub)
+		assert.Equal(t, sub, obfuscate(t, newClient(SubjectTypePairwise, "", "https://a.example.com/other")))
+		assert.Equal(t, sub, obfuscate(t, newClient(SubjectTypePairwise, "https://a.example.com/sector.json", "https://b.example.com/cb", "https://c.example.com/cb")))
+		assert.NotEqual(t, sub, obfuscate(t, newClient(SubjectTypePairwise, "", "https://b.example.com/cb")))
+	})
+
+	t.Run("case=pairwise subjects depend on the salt", func(t *testing.T) {
+		other := SubjectIdentifierAlgorithms{
+			SubjectTypePairwise: &PairwiseSubjectIdentifierAlgorithm{Salt: []byte("other-salt")},
+		}
+		client := newClient(SubjectTypePairwise, "", "https://a.example.com/cb")
+		sub, err := other.Obfuscate(context.Background(), "peter", client)
+		require.NoError(t, err)
+		assert.NotEqual(t, obfuscate(t, client), sub)
+	})
+
+	t.Run("case=should fail because the sector identifier is ambiguous", func(t *testing.T) {
+		_, err := algorithms.Obfuscate(context.Background(), "peter", newClient(SubjectTypePairwise, "", "https://a.example.com/cb", "https://b.example.com/cb"))
+		require.EqualError(t, err, ErrServerError.Error())
+	})
+
+	t.Run("case=should fail because the subject type is not supported", func(t *testing.T) {
+		_, err := algorithms.Obfuscate(context.Background(), "peter", newClient("foo", "", "https://a.example.com/cb"))
+		require.EqualError(t, err, ErrServerError.Error())
+	})
+
+	t.Run("case=the subject is unchanged without algorithms", func(t *testing.T) {
+		subject, err := SubjectIdentifierAlgorithms(nil).Obfuscate(context.Background(), "peter", newClient(SubjectTypePairwise, ""))
+		require.NoError(t, err)
+		assert.Equal(t, "peter", subject)
+	})
+
+	assert.Equal(t, []string{SubjectTypePairwise, SubjectTypePublic}, algorithms.SubjectTypes())
+}
+
+func TestValidateSectorIdentifierURI(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sector.json":
+			_, _ = rw.Write([]byte(`["https://a.example.com/cb","https://b.example.com/cb"]`))
+		case "/invalid.json":
+			_, _ = rw.Write([]byte(`{}`))
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	for k, c := range []struct {
+		d            string
+		uri          string
+		redirectURIs []string
+		expectErr    bool
+	}{
+		{d: "should pass because the redirect uris share a host", redirectURIs: []string{"https://a.example.com/cb", "https://a.example.com/other"}},
+		{d: "should fail because the redirect uris use different hosts", redirectURIs: []string{"https://a.example.com/cb", "https://b.example.com/cb"}, expectErr: true},
+		{d: "should pass because all redirect uris are listed", uri: ts.URL + "/sector.json", redirectURIs: []string{"https://a.example.com/cb", "https://b.example.com/cb"}},
+		{d: "should fail because a redirect uri is not listed", uri: ts.URL + "/sector.json", redirectURIs: []string{"https://c.example.com/cb"}, expectErr: true},
+		{d: "should fail because the document is not an array", uri: ts.URL + "/invalid.json", redirectURIs: []string{"https://a.example.com/cb"}, expectErr: true},
+		{d: "should fail because the document does not exist", uri: ts.URL + "/missing.json", redirectURIs: []string{"https://a.example.com/cb"}, expectErr: true},
+		{d: "should fail because the uri does not use https", uri: "http://a.example.com/sector.json", redirectURIs: []string{"https://a.example.com/cb"}, expectErr: true},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			err := ValidateSectorIdentifierURI(context.Background(), ts.Client(), c.uri, c.redirectURIs)
+			if c.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestWriteIntrospectionResponseWithPairwiseSubject(t *testing.T) {
+	algorithms := SubjectIdentifierAlgorithms{
+		SubjectTypePairwise: &PairwiseSubjectIdentifierAlgorithm{Salt: []byte("some-salt")},
+	}
+	f := &Fosite{SubjectIdentifierAlgorithms: algorithms}
+	client := &DefaultOpenIDConnectClient{
+		DefaultClient: &DefaultClient{ID: "foo", RedirectURIs: []string{"https://a.example.com/cb"}},
+		SubjectType:   SubjectTypePairwise,
+	}
+
+	ar := NewAccessRequest(&DefaultSession{Subject: "peter"})
+	ar.Client = client
+	rw := httptest.NewRecorder()
+	f.WriteIntrospectionResponse(rw, &IntrospectionResponse{Active: true, TokenUse: AccessToken, AccessRequester: ar})
+
+	var response map[string]interface{}
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&response))
+	expected, err := algorithms.Obfuscate(context.Background(), "peter", client)
+	require.NoError(t, err)
+	assert.Equal(t, expected, response["sub"])
+	assert.NotEqual(t, "peter", response["sub"])
+}
@@ -0,0 +1,160 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+// Package aead implements tokens which carry their payload encrypted with an authenticated encryption scheme, so that
+// they can be validated and inspected without hitting the storage. Unlike JWTs, the payload is not visible to
+// clients.
+package aead
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/chacha20poly1305"
+
+	"github.com/ory/x/errorsx"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/token/hmac"
+)
+
+// Algorithm is an authenticated encryption algorithm.
+type Algorithm string
+
+const (
+	// XChaCha20Poly1305 uses 192 bit random nonces and is the default algorithm.
+	XChaCha20Poly1305 Algorithm = "XChaCha20-Poly1305"
+
+	// AES256GCM uses 96 bit random nonces. Prefer it if AES is hardware accelerated.
+	AES256GCM Algorithm = "AES-256-GCM"
+)
+
+const (
+	// the secrets should have at least 32 characters, like the secrets of HMAC tokens
+	minimumSecretLength = 32
+
+	// keyIDSeparator separates the key ID prefix from the encrypted payload.
+	keyIDSeparator = "~"
+)
+
+var b64 = base64.URLEncoding.WithPadding(base64.NoPadding)
+
+// AEADStrategy encrypts payloads into tokens and decrypts them.
+//
+// Secrets are rotated by setting a new GlobalSecret and moving the previous one to RotatedGlobalSecrets. Tokens are
+// prefixed with the key ID of the secret they were encrypted with, so that decryption picks the matching secret.
+type AEADStrategy struct {
+	// Algorithm defaults to XChaCha20Poly1305.
+	Algorithm            Algorithm
+	GlobalSecret         []byte
+	RotatedGlobalSecrets [][]byte
+}
+
+// Encrypt encrypts the payload with the GlobalSecret and returns the token and its signature, which is the
+// authentication tag of the ciphertext.
+func (s *AEADStrategy) Encrypt(payload []byte) (token string, signature string, err error) {
+	aead, err := s.newAEAD(s.GlobalSecret)
+	if err != nil {
+		return "", "", err
+	}
+
+	nonce, err := hmac.RandomBytes(aead.NonceSize())
+	if err != nil {
+		return "", "", err
+	}
+
+	keyID := hmac.KeyID(s.GlobalSecret)
+	token = keyID + keyIDSeparator + b64.EncodeToString(aead.Seal(nonce, nonce, payload, []byte(keyID)))
+	return token, s.Signature(token), nil
+}
+
+// Decrypt verifies the token and returns its payload.
+func (s *AEADStrategy) Decrypt(token string) ([]byte, error) {
+	parts := strings.SplitN(token, keyIDSeparator, 2)
+	if len(parts) != 2 {
+		return nil, errorsx.WithStack(fosite.ErrInvalidTokenFormat)
+	}
+
+	ciphertext, err := b64.DecodeString(parts[1])
+	if err != nil {
+		return nil, errorsx.WithStack(fosite.ErrInvalidTokenFormat.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	for _, secret := range append([][]byte{s.GlobalSecret}, s.RotatedGlobalSecrets...) {
+		if len(secret) == 0 || hmac.KeyID(secret) != parts[0] {
+			continue
+		}
+
+		aead, err := s.newAEAD(secret)
+		if err != nil {
+			return nil, err
+		} else if len(ciphertext) < aead.NonceSize()+aead.Overhead() {
+			return nil, errorsx.WithStack(fosite.ErrInvalidTokenFormat)
+		}
+
+		payload, err := aead.Open(nil, ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():], []byte(parts[0]))
+		if err != nil {
+			return nil, errorsx.WithStack(fosite.ErrTokenSignatureMismatch)
+		}
+		return payload, nil
+	}
+
+	return nil, errorsx.WithStack(fosite.ErrTokenSignatureMismatch)
+}
+
+// Signature returns the authentication tag of the token, which is unique for each token as nonces are random.
+func (s *AEADStrategy) Signature(token string) string {
+	parts := strings.SplitN(token, keyIDSeparator, 2)
+	if len(parts) != 2 {
+		return ""
+	}
+
+	ciphertext, err := b64.DecodeString(parts[1])
+	if err != nil || len(ciphertext) < 16 {
+		return ""
+	}
+	return b64.EncodeToString(ciphertext[len(ciphertext)-16:])
+}
+
+func (s *AEADStrategy) newAEAD(secret []byte) (cipher.AEAD, error) {
+	if len(secret) < minimumSecretLength {
+		return nil, errors.Errorf("secret for encrypting tokens is expected to be 32 byte long, got %d byte", len(secret))
+	}
+
+	// The key is derived from the secret, so that secrets of any length can be used.
+	key := sha256.Sum256(secret)
+	switch s.Algorithm {
+	case "", XChaCha20Poly1305:
+		return chacha20poly1305.NewX(key[:])
+	case AES256GCM:
+		block, err := aes.NewCipher(key[:])
+		if err != nil {
+			return nil, errorsx.WithStack(err)
+		}
+		return cipher.NewGCM(block)
+	default:
+		return nil, errors.Errorf("authenticated encryption algorithm '%s' is not supported", s.Algorithm)
+	}
+}
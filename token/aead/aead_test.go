@@ -0,0 +1,95 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package aead
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/token/hmac"
+)
+
+func TestEncryptFailsWithShortCredentials(t *testing.T) {
+	s := AEADStrategy{GlobalSecret: []byte("foo")}
+	token, signature, err := s.Encrypt([]byte("payload"))
+	require.Error(t, err)
+	require.Empty(t, token)
+	require.Empty(t, signature)
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	for _, algorithm := range []Algorithm{"", XChaCha20Poly1305, AES256GCM} {
+		t.Run(fmt.Sprintf("algorithm=%s", algorithm), func(t *testing.T) {
+			s := &AEADStrategy{Algorithm: algorithm, GlobalSecret: []byte("1234567890123456789012345678901234567890")}
+
+			token, signature, err := s.Encrypt([]byte("payload"))
+			require.NoError(t, err)
+			assert.NotContains(t, token, "payload")
+			assert.NotEmpty(t, signature)
+			assert.Equal(t, signature, s.Signature(token))
+
+			// The key ID prefix must not reveal the key the payload is encrypted with.
+			key := sha256.Sum256(s.GlobalSecret)
+			assert.True(t, strings.HasPrefix(token, hmac.KeyID(s.GlobalSecret)+keyIDSeparator))
+			assert.False(t, strings.HasPrefix(token, b64.EncodeToString(key[:6])))
+
+			other, otherSignature, err := s.Encrypt([]byte("payload"))
+			require.NoError(t, err)
+			assert.NotEqual(t, token, other)
+			assert.NotEqual(t, signature, otherSignature)
+
+			payload, err := s.Decrypt(token)
+			require.NoError(t, err)
+			assert.Equal(t, "payload", string(payload))
+
+			tampered := []byte(token)
+			tampered[len(tampered)-2] ^= 1
+			_, err = s.Decrypt(string(tampered))
+			require.Error(t, err)
+
+			_, err = s.Decrypt("foo")
+			require.ErrorIs(t, err, fosite.ErrInvalidTokenFormat)
+		})
+	}
+}
+
+func TestDecryptWithRotatedSecrets(t *testing.T) {
+	oldSecret := []byte("1234567890123456789012345678901234567890")
+	s := &AEADStrategy{GlobalSecret: oldSecret}
+	token, _, err := s.Encrypt([]byte("payload"))
+	require.NoError(t, err)
+
+	s = &AEADStrategy{GlobalSecret: []byte("0987654321098765432109876543210987654321")}
+	_, err = s.Decrypt(token)
+	require.ErrorIs(t, err, fosite.ErrTokenSignatureMismatch)
+
+	s.RotatedGlobalSecrets = [][]byte{oldSecret}
+	payload, err := s.Decrypt(token)
+	require.NoError(t, err)
+	assert.Equal(t, "payload", string(payload))
+}
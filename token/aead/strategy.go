@@ -0,0 +1,169 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package aead
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/pborman/uuid"
+
+	"github.com/ory/x/errorsx"
+
+	"github.com/ory/fosite"
+)
+
+// Claims is the payload of the tokens issued by Strategy.
+type Claims struct {
+	TokenType fosite.TokenType `json:"typ"`
+	ID        string           `json:"jti"`
+	ClientID  string           `json:"client_id,omitempty"`
+	Scope     fosite.Arguments `json:"scope,omitempty"`
+	ExpiresAt int64            `json:"exp,omitempty"`
+
+	// RedirectURI, CodeChallenge and CodeChallengeMethod are only set for authorization codes.
+	RedirectURI         string `json:"redirect_uri,omitempty"`
+	CodeChallenge       string `json:"code_challenge,omitempty"`
+	CodeChallengeMethod string `json:"code_challenge_method,omitempty"`
+}
+
+// Strategy is an alternative to the HMAC based oauth2.CoreStrategy. The type, the expiry, the client and the
+// granted scope of a token are encrypted into the token itself, so that tokens can be partially validated without
+// hitting the storage using Decode.
+type Strategy struct {
+	AEAD                  *AEADStrategy
+	AccessTokenLifespan   time.Duration
+	RefreshTokenLifespan  time.Duration
+	AuthorizeCodeLifespan time.Duration
+}
+
+func (s *Strategy) AccessTokenSignature(token string) string {
+	return s.AEAD.Signature(token)
+}
+
+func (s *Strategy) RefreshTokenSignature(token string) string {
+	return s.AEAD.Signature(token)
+}
+
+func (s *Strategy) AuthorizeCodeSignature(token string) string {
+	return s.AEAD.Signature(token)
+}
+
+func (s *Strategy) GenerateAccessToken(_ context.Context, requester fosite.Requester) (token string, signature string, err error) {
+	return s.generate(fosite.AccessToken, requester, s.AccessTokenLifespan)
+}
+
+func (s *Strategy) GenerateRefreshToken(_ context.Context, requester fosite.Requester) (token string, signature string, err error) {
+	return s.generate(fosite.RefreshToken, requester, s.RefreshTokenLifespan)
+}
+
+func (s *Strategy) GenerateAuthorizeCode(_ context.Context, requester fosite.Requester) (token string, signature string, err error) {
+	return s.generate(fosite.AuthorizeCode, requester, s.AuthorizeCodeLifespan)
+}
+
+func (s *Strategy) ValidateAccessToken(ctx context.Context, r fosite.Requester, token string) error {
+	return s.validate(ctx, fosite.AccessToken, r, token)
+}
+
+func (s *Strategy) ValidateRefreshToken(ctx context.Context, r fosite.Requester, token string) error {
+	if session := r.GetSession().GetExpiresAt(fosite.RefreshTokenSession); !session.IsZero() && session.Before(time.Now().UTC()) {
+		return errorsx.WithStack(fosite.ErrTokenExpired.WithHintf("Refresh token expired at '%s' because the maximum lifetime of the grant has been reached.", session))
+	}
+	return s.validate(ctx, fosite.RefreshToken, r, token)
+}
+
+func (s *Strategy) ValidateAuthorizeCode(ctx context.Context, r fosite.Requester, token string) error {
+	return s.validate(ctx, fosite.AuthorizeCode, r, token)
+}
+
+// Decode decrypts the token and verifies its type and expiry without hitting the storage.
+func (s *Strategy) Decode(_ context.Context, tokenType fosite.TokenType, token string) (*Claims, error) {
+	payload, err := s.AEAD.Decrypt(token)
+	if err != nil {
+		return nil, err
+	}
+
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, errorsx.WithStack(fosite.ErrInvalidTokenFormat.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	if claims.TokenType != tokenType {
+		return nil, errorsx.WithStack(fosite.ErrInvalidTokenFormat.WithHintf("Expected a token of type '%s' but got '%s'.", tokenType, claims.TokenType))
+	} else if claims.ExpiresAt != 0 && time.Unix(claims.ExpiresAt, 0).Before(time.Now().UTC()) {
+		return nil, errorsx.WithStack(fosite.ErrTokenExpired.WithHintf("Token expired at '%s'.", time.Unix(claims.ExpiresAt, 0).UTC()))
+	}
+
+	return &claims, nil
+}
+
+func (s *Strategy) generate(tokenType fosite.TokenType, requester fosite.Requester, lifespan time.Duration) (string, string, error) {
+	claims := &Claims{
+		TokenType: tokenType,
+		ID:        uuid.New(),
+		Scope:     requester.GetGrantedScopes(),
+	}
+	if client := requester.GetClient(); client != nil {
+		claims.ClientID = client.GetID()
+	}
+
+	exp := requester.GetSession().GetExpiresAt(tokenType)
+	if exp.IsZero() && lifespan > 0 {
+		exp = time.Now().UTC().Add(lifespan)
+	}
+	if !exp.IsZero() {
+		claims.ExpiresAt = exp.Unix()
+	}
+
+	if tokenType == fosite.AuthorizeCode {
+		form := requester.GetRequestForm()
+		claims.RedirectURI = form.Get("redirect_uri")
+		claims.CodeChallenge = form.Get("code_challenge")
+		claims.CodeChallengeMethod = form.Get("code_challenge_method")
+		if claims.CodeChallenge != "" && claims.CodeChallengeMethod == "" {
+			claims.CodeChallengeMethod = "plain"
+		}
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", "", errorsx.WithStack(err)
+	}
+	return s.AEAD.Encrypt(payload)
+}
+
+func (s *Strategy) validate(ctx context.Context, tokenType fosite.TokenType, r fosite.Requester, token string) error {
+	claims, err := s.Decode(ctx, tokenType, token)
+	if err != nil {
+		return err
+	}
+
+	if client := r.GetClient(); client != nil && claims.ClientID != client.GetID() {
+		return errorsx.WithStack(fosite.ErrTokenClaim.WithHint("The token was issued to another client."))
+	}
+
+	if exp := r.GetSession().GetExpiresAt(tokenType); !exp.IsZero() && exp.Before(time.Now().UTC()) {
+		return errorsx.WithStack(fosite.ErrTokenExpired.WithHintf("Token expired at '%s'.", exp))
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package aead_test
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/oauth2"
+	"github.com/ory/fosite/token/aead"
+)
+
+var _ oauth2.CoreStrategy = new(aead.Strategy)
+
+func TestStrategy(t *testing.T) {
+	s := &aead.Strategy{
+		AEAD:                  &aead.AEADStrategy{GlobalSecret: []byte("1234567890123456789012345678901234567890")},
+		AccessTokenLifespan:   time.Hour,
+		RefreshTokenLifespan:  time.Hour,
+		AuthorizeCodeLifespan: time.Minute,
+	}
+
+	request := &fosite.Request{
+		Client:       &fosite.DefaultClient{ID: "foo"},
+		GrantedScope: fosite.Arguments{"offline", "photos"},
+		Form:         url.Values{"redirect_uri": {"https://foo.bar/cb"}, "code_challenge": {"challenge"}},
+		Session:      new(fosite.DefaultSession),
+	}
+
+	token, signature, err := s.GenerateAccessToken(context.Background(), request)
+	require.NoError(t, err)
+	assert.Equal(t, signature, s.AccessTokenSignature(token))
+	require.NoError(t, s.ValidateAccessToken(context.Background(), request, token))
+
+	claims, err := s.Decode(context.Background(), fosite.AccessToken, token)
+	require.NoError(t, err)
+	assert.Equal(t, "foo", claims.ClientID)
+	assert.Equal(t, fosite.Arguments{"offline", "photos"}, claims.Scope)
+	assert.WithinDuration(t, time.Now().UTC().Add(time.Hour), time.Unix(claims.ExpiresAt, 0), time.Second*5)
+	assert.Empty(t, claims.RedirectURI)
+
+	t.Run("case=rejects tokens of another type", func(t *testing.T) {
+		require.ErrorIs(t, s.ValidateRefreshToken(context.Background(), request, token), fosite.ErrInvalidTokenFormat)
+		require.ErrorIs(t, s.ValidateAuthorizeCode(context.Background(), request, token), fosite.ErrInvalidTokenFormat)
+	})
+
+	t.Run("case=rejects tokens of another client", func(t *testing.T) {
+		other := &fosite.Request{Client: &fosite.DefaultClient{ID: "bar"}, Session: new(fosite.DefaultSession)}
+		require.ErrorIs(t, s.ValidateAccessToken(context.Background(), other, token), fosite.ErrTokenClaim)
+	})
+
+	t.Run("case=rejects expired tokens", func(t *testing.T) {
+		request.Session.SetExpiresAt(fosite.AccessToken, time.Now().UTC().Add(-time.Minute))
+		defer request.Session.SetExpiresAt(fosite.AccessToken, time.Time{})
+
+		expired, _, err := s.GenerateAccessToken(context.Background(), request)
+		require.NoError(t, err)
+		_, err = s.Decode(context.Background(), fosite.AccessToken, expired)
+		require.ErrorIs(t, err, fosite.ErrTokenExpired)
+	})
+
+	t.Run("case=authorization codes carry the redirect_uri and the code challenge", func(t *testing.T) {
+		code, _, err := s.GenerateAuthorizeCode(context.Background(), request)
+		require.NoError(t, err)
+		require.NoError(t, s.ValidateAuthorizeCode(context.Background(), request, code))
+
+		claims, err := s.Decode(context.Background(), fosite.AuthorizeCode, code)
+		require.NoError(t, err)
+		assert.Equal(t, "https://foo.bar/cb", claims.RedirectURI)
+		assert.Equal(t, "challenge", claims.CodeChallenge)
+		assert.Equal(t, "plain", claims.CodeChallengeMethod)
+	})
+
+	t.Run("case=refresh tokens expire with the grant", func(t *testing.T) {
+		refresh, _, err := s.GenerateRefreshToken(context.Background(), request)
+		require.NoError(t, err)
+		require.NoError(t, s.ValidateRefreshToken(context.Background(), request, refresh))
+
+		request.Session.SetExpiresAt(fosite.RefreshTokenSession, time.Now().UTC().Add(-time.Minute))
+		require.ErrorIs(t, s.ValidateRefreshToken(context.Background(), request, refresh), fosite.ErrTokenExpired)
+	})
+}
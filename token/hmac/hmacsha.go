@@ -26,9 +26,11 @@ package hmac
 
 import (
 	"crypto/hmac"
+	"crypto/sha256"
 	"crypto/sha512"
 	"encoding/base64"
 	"fmt"
+	"hash"
 	"strings"
 	"sync"
 
@@ -40,23 +42,62 @@ import (
 )
 
 // HMACStrategy is responsible for generating and validating challenges.
+//
+// Secrets are rotated by setting a new GlobalSecret and moving the previous one to RotatedGlobalSecrets. New
+// tokens are always signed with the GlobalSecret, while tokens signed with a rotated secret remain valid.
 type HMACStrategy struct {
+	// TokenEntropy is the number of random bytes of the token. It defaults to 32 bytes and must be at least 16
+	// bytes (128 bit) long, smaller values are raised to 16.
 	TokenEntropy         int
 	GlobalSecret         []byte
 	RotatedGlobalSecrets [][]byte
+
+	// Hash is the hash function of the HMAC. Defaults to SHA-512/256. Changing the hash function invalidates all
+	// previously issued tokens.
+	Hash func() hash.Hash
+
+	// SignatureLength, if set, truncates the signature of tokens to the given number of bytes to issue shorter
+	// tokens. It must be at least 16 bytes long, smaller values are raised to 16. Defaults to the size of the hash.
+	SignatureLength int
+
+	// VersionedTokens, if set to true, prefixes generated tokens with the KeyID of the GlobalSecret, so that
+	// validation picks the matching secret instead of trying all of them. Tokens without a prefix are still
+	// validated against all secrets.
+	VersionedTokens bool
 	sync.Mutex
 }
 
 const (
-	// key should be at least 256 bit long, making it
-	minimumEntropy = 32
+	// key should be at least 256 bit long by default, making it
+	defaultEntropy = 32
+
+	// rfc6749 Section 10.10 requires tokens to be at least 128 bit long
+	minimumEntropy = 16
+
+	// signatures of less than 128 bit are too easy to forge
+	minimumSignatureLength = 16
 
 	// the secrets (client and global) should each have at least 16 characters making it harder to guess them
 	minimumSecretLength = 32
 )
 
+// keyIDSeparator separates the key ID prefix of versioned tokens from the token. It is not part of the base64 url
+// alphabet and therefore can not be confused with the token itself.
+const keyIDSeparator = "~"
+
 var b64 = base64.URLEncoding.WithPadding(base64.NoPadding)
 
+// keyIDLabel is the message authenticated with the secret to derive its key ID.
+const keyIDLabel = "fosite-key-id"
+
+// KeyID returns the identifier prefixing versioned tokens signed with the secret. It is derived from the secret
+// using HMAC-SHA256, so that it remains stable when the secret is rotated without revealing a fingerprint of it.
+func KeyID(secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	_, _ = mac.Write([]byte(keyIDLabel))
+	return b64.EncodeToString(mac.Sum(nil)[:6])
+}
+
 // Generate generates a token and a matching signature or returns an error.
 // This method implements rfc6819 Section 5.1.4.2.2: Use High Entropy for Secrets.
 func (c *HMACStrategy) Generate() (string, string, error) {
@@ -70,7 +111,9 @@ func (c *HMACStrategy) Generate() (string, string, error) {
 	var signingKey [32]byte
 	copy(signingKey[:], c.GlobalSecret)
 
-	if c.TokenEntropy < minimumEntropy {
+	if c.TokenEntropy == 0 {
+		c.TokenEntropy = defaultEntropy
+	} else if c.TokenEntropy < minimumEntropy {
 		c.TokenEntropy = minimumEntropy
 	}
 
@@ -86,15 +129,22 @@ func (c *HMACStrategy) Generate() (string, string, error) {
 		return "", "", errorsx.WithStack(err)
 	}
 
-	signature := generateHMAC(tokenKey, &signingKey)
+	signature := c.generateHMAC(tokenKey, &signingKey)
 
 	encodedSignature := b64.EncodeToString(signature)
 	encodedToken := fmt.Sprintf("%s.%s", b64.EncodeToString(tokenKey), encodedSignature)
+	if c.VersionedTokens {
+		encodedToken = KeyID(c.GlobalSecret) + keyIDSeparator + encodedToken
+	}
 	return encodedToken, encodedSignature, nil
 }
 
 // Validate validates a token and returns its signature or an error if the token is not valid.
 func (c *HMACStrategy) Validate(token string) (err error) {
+	if parts := strings.SplitN(token, keyIDSeparator, 2); len(parts) == 2 {
+		return c.validateVersioned(parts[0], parts[1])
+	}
+
 	var keys [][]byte
 
 	if len(c.GlobalSecret) > 0 {
@@ -121,6 +171,16 @@ func (c *HMACStrategy) Validate(token string) (err error) {
 	return err
 }
 
+// validateVersioned validates the token using the secret identified by the key ID.
+func (c *HMACStrategy) validateVersioned(keyID string, token string) error {
+	for _, secret := range append([][]byte{c.GlobalSecret}, c.RotatedGlobalSecrets...) {
+		if len(secret) > 0 && KeyID(secret) == keyID {
+			return c.validate(secret, token)
+		}
+	}
+	return errorsx.WithStack(fosite.ErrTokenSignatureMismatch)
+}
+
 func (c *HMACStrategy) validate(secret []byte, token string) error {
 	if len(secret) < minimumSecretLength {
 		return errors.Errorf("secret for signing HMAC-SHA512/256 is expected to be 32 byte long, got %d byte", len(secret))
@@ -150,7 +210,7 @@ func (c *HMACStrategy) validate(secret []byte, token string) error {
 		return errorsx.WithStack(err)
 	}
 
-	expectedMAC := generateHMAC(decodedTokenKey, &signingKey)
+	expectedMAC := c.generateHMAC(decodedTokenKey, &signingKey)
 	if !hmac.Equal(expectedMAC, decodedTokenSignature) {
 		// Hash is invalid
 		return errorsx.WithStack(fosite.ErrTokenSignatureMismatch)
@@ -169,12 +229,61 @@ func (c *HMACStrategy) Signature(token string) string {
 	return split[1]
 }
 
-func generateHMAC(data []byte, key *[32]byte) []byte {
-	h := hmac.New(sha512.New512_256, key[:])
-	// sha512.digest.Write() always returns nil for err, the panic should never happen
+// GenerateHMACForString returns the encoded HMAC-SHA512/256 of the given text, signed with the global secret. It
+// is used to derive storage signatures for values which do not carry their own signature, such as user codes.
+func (c *HMACStrategy) GenerateHMACForString(text string) (string, error) {
+	if len(c.GlobalSecret) < minimumSecretLength {
+		return "", errors.Errorf("secret for signing HMAC-SHA512/256 is expected to be 32 byte long, got %d byte", len(c.GlobalSecret))
+	}
+
+	var signingKey [32]byte
+	copy(signingKey[:], c.GlobalSecret)
+
+	return b64.EncodeToString(c.generateHMAC([]byte(text), &signingKey)), nil
+}
+
+// GenerateHMACsForString returns the encoded HMAC-SHA512/256 of the given text for the global secret followed by
+// the rotated global secrets. It is used to look up values signed with GenerateHMACForString before the global
+// secret was rotated.
+func (c *HMACStrategy) GenerateHMACsForString(text string) ([]string, error) {
+	if len(c.GlobalSecret) < minimumSecretLength {
+		return nil, errors.Errorf("secret for signing HMAC-SHA512/256 is expected to be 32 byte long, got %d byte", len(c.GlobalSecret))
+	}
+
+	var signatures []string
+	for _, secret := range append([][]byte{c.GlobalSecret}, c.RotatedGlobalSecrets...) {
+		if len(secret) < minimumSecretLength {
+			continue
+		}
+
+		var signingKey [32]byte
+		copy(signingKey[:], secret)
+		signatures = append(signatures, b64.EncodeToString(c.generateHMAC([]byte(text), &signingKey)))
+	}
+	return signatures, nil
+}
+
+func (c *HMACStrategy) generateHMAC(data []byte, key *[32]byte) []byte {
+	hashFunc := c.Hash
+	if hashFunc == nil {
+		hashFunc = sha512.New512_256
+	}
+
+	h := hmac.New(hashFunc, key[:])
+	// hash.Hash.Write() never returns an error, the panic should never happen
 	_, err := h.Write(data)
 	if err != nil {
 		panic(err)
 	}
-	return h.Sum(nil)
+
+	mac := h.Sum(nil)
+	if length := c.SignatureLength; length > 0 {
+		if length < minimumSignatureLength {
+			length = minimumSignatureLength
+		}
+		if length < len(mac) {
+			mac = mac[:length]
+		}
+	}
+	return mac
 }
@@ -22,6 +22,8 @@
 package hmac
 
 import (
+	"crypto/sha256"
+	"strings"
 	"testing"
 
 	"github.com/ory/fosite"
@@ -131,3 +133,152 @@ func TestValidateWithRotatedKeyInvalid(t *testing.T) {
 
 	require.EqualError(t, new(HMACStrategy).Validate(token), "a secret for signing HMAC-SHA512/256 is expected to be defined, but none were")
 }
+
+func TestValidateVersionedTokens(t *testing.T) {
+	old := HMACStrategy{
+		GlobalSecret:    []byte("1234567890123456789012345678901234567890"),
+		VersionedTokens: true,
+	}
+	now := HMACStrategy{
+		GlobalSecret: []byte("0000000090123456789012345678901234567890"),
+		RotatedGlobalSecrets: [][]byte{
+			// Would fail if validation tried all secrets.
+			[]byte("abcdefgh90123456789012345678901"),
+			[]byte("1234567890123456789012345678901234567890"),
+		},
+		VersionedTokens: true,
+	}
+
+	token, signature, err := old.Generate()
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(token, KeyID(old.GlobalSecret)+"~"))
+	assert.Equal(t, signature, now.Signature(token))
+	require.NoError(t, old.Validate(token))
+	require.NoError(t, now.Validate(token))
+
+	newToken, _, err := now.Generate()
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(newToken, KeyID(now.GlobalSecret)+"~"))
+	require.NoError(t, now.Validate(newToken))
+	require.EqualError(t, old.Validate(newToken), fosite.ErrTokenSignatureMismatch.Error())
+
+	t.Run("case=tampered key id", func(t *testing.T) {
+		tampered := KeyID(now.GlobalSecret) + token[strings.Index(token, "~"):]
+		require.EqualError(t, now.Validate(tampered), fosite.ErrTokenSignatureMismatch.Error())
+	})
+
+	t.Run("case=unversioned tokens are still accepted", func(t *testing.T) {
+		legacy := HMACStrategy{GlobalSecret: now.GlobalSecret}
+		legacyToken, _, err := legacy.Generate()
+		require.NoError(t, err)
+		require.NoError(t, now.Validate(legacyToken))
+	})
+}
+
+func TestKeyID(t *testing.T) {
+	secret := []byte("1234567890123456789012345678901234567890")
+	sum := sha256.Sum256(secret)
+
+	assert.Equal(t, KeyID(secret), KeyID(secret))
+	assert.Len(t, KeyID(secret), 8)
+	assert.NotEqual(t, b64.EncodeToString(sum[:6]), KeyID(secret), "should not be a fingerprint of the secret")
+	assert.NotEqual(t, KeyID(secret), KeyID([]byte("0000000090123456789012345678901234567890")))
+}
+
+func TestGenerateHMACForString(t *testing.T) {
+	cg := HMACStrategy{GlobalSecret: []byte("1234567890123456789012345678901234567890")}
+
+	a, err := cg.GenerateHMACForString("BCDFGHJK")
+	require.NoError(t, err)
+	require.NotEmpty(t, a)
+
+	b, err := cg.GenerateHMACForString("BCDFGHJK")
+	require.NoError(t, err)
+	assert.Equal(t, a, b)
+
+	c, err := cg.GenerateHMACForString("BCDFGHJL")
+	require.NoError(t, err)
+	assert.NotEqual(t, a, c)
+
+	_, err = (&HMACStrategy{GlobalSecret: []byte("foo")}).GenerateHMACForString("BCDFGHJK")
+	require.Error(t, err)
+}
+
+func TestPresets(t *testing.T) {
+	for name, c := range map[string]struct {
+		preset Preset
+		length int
+	}{
+		"default":      {preset: DefaultPreset, length: 87},
+		"high entropy": {preset: HighEntropyPreset, length: 130},
+		"compact":      {preset: CompactPreset, length: 45},
+	} {
+		t.Run("preset="+name, func(t *testing.T) {
+			cg := &HMACStrategy{GlobalSecret: []byte("1234567890123456789012345678901234567890")}
+			c.preset.Apply(cg)
+
+			token, signature, err := cg.Generate()
+			require.NoError(t, err)
+			assert.Len(t, token, c.length)
+			assert.Equal(t, signature, cg.Signature(token))
+			require.NoError(t, cg.Validate(token))
+
+			// Only the hash function and signature length are required to validate tokens.
+			other := &HMACStrategy{GlobalSecret: cg.GlobalSecret}
+			if name == "compact" {
+				assert.Error(t, other.Validate(token))
+			} else {
+				assert.NoError(t, other.Validate(token))
+			}
+		})
+	}
+}
+
+func TestGenerateEnforcesMinimums(t *testing.T) {
+	cg := &HMACStrategy{
+		GlobalSecret:    []byte("1234567890123456789012345678901234567890"),
+		TokenEntropy:    4,
+		SignatureLength: 4,
+	}
+
+	token, signature, err := cg.Generate()
+	require.NoError(t, err)
+	assert.Equal(t, minimumEntropy, cg.TokenEntropy)
+
+	key, err := b64.DecodeString(strings.Split(token, ".")[0])
+	require.NoError(t, err)
+	assert.Len(t, key, minimumEntropy)
+
+	decoded, err := b64.DecodeString(signature)
+	require.NoError(t, err)
+	assert.Len(t, decoded, minimumSignatureLength)
+}
+
+func BenchmarkPresets(b *testing.B) {
+	for name, preset := range map[string]Preset{
+		"default":      DefaultPreset,
+		"high entropy": HighEntropyPreset,
+		"compact":      CompactPreset,
+	} {
+		cg := &HMACStrategy{GlobalSecret: []byte("1234567890123456789012345678901234567890")}
+		preset.Apply(cg)
+
+		token, _, err := cg.Generate()
+		require.NoError(b, err)
+
+		b.Run("preset="+name+"/op=generate", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, _, err := cg.Generate(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run("preset="+name+"/op=validate", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if err := cg.Validate(token); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package hmac
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+)
+
+// Preset is a combination of token entropy, signature length and hash function. The presets below are covered by
+// BenchmarkPresets.
+type Preset struct {
+	TokenEntropy    int
+	SignatureLength int
+	Hash            func() hash.Hash
+}
+
+var (
+	// DefaultPreset issues tokens with 256 bit of entropy and a SHA-512/256 signature. Unversioned
+	// tokens are 87 characters long.
+	DefaultPreset = Preset{TokenEntropy: 32, Hash: sha512.New512_256}
+
+	// HighEntropyPreset issues tokens with 512 bit of entropy and a SHA-512/256 signature. Unversioned
+	// tokens are 130 characters long.
+	HighEntropyPreset = Preset{TokenEntropy: 64, Hash: sha512.New512_256}
+
+	// CompactPreset issues tokens with 128 bit of entropy and a SHA-256 signature truncated to 128 bit, for example
+	// for delivery by SMS. Unversioned tokens are 45 characters long.
+	CompactPreset = Preset{TokenEntropy: 16, SignatureLength: 16, Hash: sha256.New}
+)
+
+// Apply configures the strategy to issue tokens of the preset.
+func (p Preset) Apply(c *HMACStrategy) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.TokenEntropy = p.TokenEntropy
+	c.SignatureLength = p.SignatureLength
+	c.Hash = p.Hash
+}
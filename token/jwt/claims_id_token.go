@@ -22,6 +22,7 @@
 package jwt
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/pborman/uuid"
@@ -43,6 +44,41 @@ type IDTokenClaims struct {
 	AuthenticationMethodsReferences     []string
 	CodeHash                            string
 	Extra                               map[string]interface{}
+
+	// ClaimNames maps the names of aggregated and distributed claims to the keys of their sources in ClaimSources.
+	ClaimNames map[string]string
+
+	// ClaimSources contains the sources of aggregated and distributed claims, see
+	// https://openid.net/specs/openid-connect-core-1_0.html#AggregatedDistributedClaims
+	ClaimSources map[string]ClaimSource
+}
+
+// ClaimSource references claims asserted by a claims provider other than the OpenID Provider. A source is either
+// aggregated, i.e. JWT is set, or distributed, i.e. Endpoint is set.
+type ClaimSource struct {
+	// JWT is a JWT signed by the claims provider containing the aggregated claims.
+	JWT string `json:"JWT,omitempty"`
+
+	// Endpoint is the OAuth 2.0 resource endpoint from which the distributed claims can be retrieved.
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// AccessToken is used to retrieve the distributed claims from Endpoint. If empty, the client is expected to
+	// obtain an access token by other means.
+	AccessToken string `json:"access_token,omitempty"`
+}
+
+func (s ClaimSource) toMap() map[string]interface{} {
+	ret := map[string]interface{}{}
+	if s.JWT != "" {
+		ret["JWT"] = s.JWT
+	}
+	if s.Endpoint != "" {
+		ret["endpoint"] = s.Endpoint
+	}
+	if s.AccessToken != "" {
+		ret["access_token"] = s.AccessToken
+	}
+	return ret
 }
 
 // ToMap will transform the headers to a map structure
@@ -127,6 +163,22 @@ func (c *IDTokenClaims) ToMap() map[string]interface{} {
 		delete(ret, "amr")
 	}
 
+	if len(c.ClaimNames) > 0 {
+		names := make(map[string]interface{}, len(c.ClaimNames))
+		for claim, source := range c.ClaimNames {
+			names[claim] = source
+		}
+		sources := make(map[string]interface{}, len(c.ClaimSources))
+		for key, source := range c.ClaimSources {
+			sources[key] = source.toMap()
+		}
+		ret["_claim_names"] = names
+		ret["_claim_sources"] = sources
+	} else {
+		delete(ret, "_claim_names")
+		delete(ret, "_claim_sources")
+	}
+
 	return ret
 
 }
@@ -139,6 +191,46 @@ func (c *IDTokenClaims) Add(key string, value interface{}) {
 	c.Extra[key] = value
 }
 
+// AddAggregatedClaims references the claims contained in the JWT signed by a claims provider.
+func (c *IDTokenClaims) AddAggregatedClaims(source string, jwt string, claims ...string) {
+	c.addClaimSource(source, ClaimSource{JWT: jwt}, claims)
+}
+
+// AddDistributedClaims references the claims which can be retrieved from the endpoint of a claims provider
+// using the access token.
+func (c *IDTokenClaims) AddDistributedClaims(source string, endpoint string, accessToken string, claims ...string) {
+	c.addClaimSource(source, ClaimSource{Endpoint: endpoint, AccessToken: accessToken}, claims)
+}
+
+func (c *IDTokenClaims) addClaimSource(key string, source ClaimSource, claims []string) {
+	if c.ClaimNames == nil {
+		c.ClaimNames = make(map[string]string)
+	}
+	if c.ClaimSources == nil {
+		c.ClaimSources = make(map[string]ClaimSource)
+	}
+	c.ClaimSources[key] = source
+	for _, claim := range claims {
+		c.ClaimNames[claim] = key
+	}
+}
+
+// ValidateClaimSources checks that every aggregated or distributed claim references a known source and that each
+// source is either aggregated or distributed.
+func (c *IDTokenClaims) ValidateClaimSources() error {
+	for claim, key := range c.ClaimNames {
+		if _, ok := c.ClaimSources[key]; !ok {
+			return fmt.Errorf("claim '%s' references the unknown claim source '%s'", claim, key)
+		}
+	}
+	for key, source := range c.ClaimSources {
+		if (source.JWT == "") == (source.Endpoint == "") {
+			return fmt.Errorf("claim source '%s' must either contain a JWT or an endpoint", key)
+		}
+	}
+	return nil
+}
+
 // Get will get a value from the extra field based on a given key
 func (c *IDTokenClaims) Get(key string) interface{} {
 	return c.ToMap()[key]
@@ -94,3 +94,32 @@ func TestIDTokenClaimsToMap(t *testing.T) {
 		"nonce":     idTokenClaims.Nonce,
 	}, idTokenClaims.ToMap())
 }
+
+func TestIDTokenClaimsAggregatedAndDistributedClaims(t *testing.T) {
+	idTokenClaims := &IDTokenClaims{Subject: "peter"}
+	idTokenClaims.AddAggregatedClaims("src1", "eyJhbGciOiJSUzI1NiJ9.eyJhZGRyZXNzIjp7fX0.c2ln", "address", "phone_number")
+	idTokenClaims.AddDistributedClaims("src2", "https://bank.example.com/claim_source", "ksj3n283dke", "payment_info")
+	assert.NoError(t, idTokenClaims.ValidateClaimSources())
+
+	claims := idTokenClaims.ToMap()
+	assert.Equal(t, map[string]interface{}{
+		"address":      "src1",
+		"phone_number": "src1",
+		"payment_info": "src2",
+	}, claims["_claim_names"])
+	assert.Equal(t, map[string]interface{}{
+		"src1": map[string]interface{}{"JWT": "eyJhbGciOiJSUzI1NiJ9.eyJhZGRyZXNzIjp7fX0.c2ln"},
+		"src2": map[string]interface{}{"endpoint": "https://bank.example.com/claim_source", "access_token": "ksj3n283dke"},
+	}, claims["_claim_sources"])
+	assert.NotContains(t, (&IDTokenClaims{}).ToMap(), "_claim_names")
+
+	assert.Error(t, (&IDTokenClaims{ClaimNames: map[string]string{"address": "unknown"}}).ValidateClaimSources())
+	assert.Error(t, (&IDTokenClaims{
+		ClaimNames:   map[string]string{"address": "src1"},
+		ClaimSources: map[string]ClaimSource{"src1": {}},
+	}).ValidateClaimSources())
+	assert.Error(t, (&IDTokenClaims{
+		ClaimNames:   map[string]string{"address": "src1"},
+		ClaimSources: map[string]ClaimSource{"src1": {JWT: "a.b.c", Endpoint: "https://bank.example.com/claim_source"}},
+	}).ValidateClaimSources())
+}
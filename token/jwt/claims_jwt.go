@@ -181,6 +181,14 @@ func (c *JWTClaims) FromMap(m map[string]interface{}) {
 				c.Audience = []string{s}
 			} else if s, ok := v.([]string); ok {
 				c.Audience = s
+			} else if s, ok := v.([]interface{}); ok {
+				// Decoded JSON arrays
+				c.Audience = make([]string, 0, len(s))
+				for _, vi := range s {
+					if s, ok := vi.(string); ok {
+						c.Audience = append(c.Audience, s)
+					}
+				}
 			}
 		case "iat":
 			c.IssuedAt = toTime(v, c.IssuedAt)
@@ -0,0 +1,87 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package jwt
+
+import (
+	"context"
+
+	"github.com/ory/x/errorsx"
+	"github.com/pkg/errors"
+	"gopkg.in/square/go-jose.v2"
+)
+
+// DefaultContentEncryption is used if a client registered a JWE alg but no enc, as defined in
+// https://openid.net/specs/openid-connect-registration-1_0.html#ClientMetadata
+const DefaultContentEncryption = string(jose.A128CBC_HS256)
+
+// Encrypter encrypts signed JSON Web Tokens, producing nested JWTs as defined in
+// https://tools.ietf.org/html/rfc7519#section-5.2
+type Encrypter interface {
+	// Encrypt encrypts the signed token for the recipient's public key using the JWE alg and enc.
+	Encrypt(ctx context.Context, token string, key *jose.JSONWebKey, alg string, enc string) (string, error)
+}
+
+// DefaultEncrypter encrypts tokens using go-jose.
+type DefaultEncrypter struct{}
+
+func (e *DefaultEncrypter) Encrypt(_ context.Context, token string, key *jose.JSONWebKey, alg string, enc string) (string, error) {
+	if enc == "" {
+		enc = DefaultContentEncryption
+	}
+
+	encrypter, err := jose.NewEncrypter(
+		jose.ContentEncryption(enc),
+		jose.Recipient{Algorithm: jose.KeyAlgorithm(alg), Key: key.Key, KeyID: key.KeyID},
+		(&jose.EncrypterOptions{}).WithType("JWT").WithContentType("JWT"),
+	)
+	if err != nil {
+		return "", errorsx.WithStack(err)
+	}
+
+	object, err := encrypter.Encrypt([]byte(token))
+	if err != nil {
+		return "", errorsx.WithStack(err)
+	}
+
+	return object.CompactSerialize()
+}
+
+// FindEncryptionKey returns the first public key of the set which may be used for encrypting with the JWE alg.
+func FindEncryptionKey(set *jose.JSONWebKeySet, alg string) (*jose.JSONWebKey, error) {
+	if set != nil {
+		for _, key := range set.Keys {
+			if key.Use != "" && key.Use != "enc" {
+				continue
+			} else if key.Algorithm != "" && key.Algorithm != alg {
+				continue
+			}
+
+			public := key.Public()
+			if public.Key == nil {
+				continue
+			}
+			return &public, nil
+		}
+	}
+
+	return nil, errors.Errorf("unable to find a JSON Web Key for encrypting with algorithm '%s'", alg)
+}
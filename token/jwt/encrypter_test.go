@@ -0,0 +1,70 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package jwt
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+)
+
+func TestFindEncryptionKey(t *testing.T) {
+	key := MustRSAKey()
+	set := &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+		{Key: &key.PublicKey, KeyID: "sig", Use: "sig"},
+		{Key: &key.PublicKey, KeyID: "other-alg", Algorithm: string(jose.RSA1_5)},
+		{Key: key, KeyID: "enc", Use: "enc"},
+	}}
+
+	found, err := FindEncryptionKey(set, string(jose.RSA_OAEP))
+	require.NoError(t, err)
+	assert.Equal(t, "enc", found.KeyID)
+	assert.True(t, found.IsPublic())
+
+	_, err = FindEncryptionKey(&jose.JSONWebKeySet{Keys: set.Keys[:1]}, string(jose.RSA_OAEP))
+	assert.Error(t, err)
+
+	_, err = FindEncryptionKey(nil, string(jose.RSA_OAEP))
+	assert.Error(t, err)
+}
+
+func TestDefaultEncrypter(t *testing.T) {
+	key := MustRSAKey()
+	strategy := &RS256JWTStrategy{PrivateKey: key}
+	token, _, err := strategy.Generate(context.Background(), MapClaims{"sub": "peter"}, header)
+	require.NoError(t, err)
+
+	encrypted, err := new(DefaultEncrypter).Encrypt(context.Background(), token, &jose.JSONWebKey{Key: &key.PublicKey, KeyID: "enc"}, string(jose.RSA_OAEP_256), "")
+	require.NoError(t, err)
+
+	object, err := jose.ParseEncrypted(encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "enc", object.Header.KeyID)
+	assert.Equal(t, DefaultContentEncryption, object.Header.ExtraHeaders[jose.HeaderKey("enc")])
+
+	decrypted, err := object.Decrypt(key)
+	require.NoError(t, err)
+	assert.Equal(t, token, string(decrypted))
+}
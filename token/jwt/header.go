@@ -30,9 +30,9 @@ func NewHeaders() *Headers {
 	return &Headers{Extra: map[string]interface{}{}}
 }
 
-// ToMap will transform the headers to a map structure
+// ToMap will transform the headers to a map structure. The "typ" header defaults to "JWT" unless set explicitly.
 func (h *Headers) ToMap() map[string]interface{} {
-	var filter = map[string]bool{"alg": true, "typ": true}
+	var filter = map[string]bool{"alg": true}
 	var extra = map[string]interface{}{}
 
 	// filter known values from extra.
@@ -35,3 +35,12 @@ func TestHeaderToMap(t *testing.T) {
 		"foo": "bar",
 	}, header.ToMap())
 }
+
+func TestHeaderToMapKeepsType(t *testing.T) {
+	header := &Headers{}
+	header.Add("alg", "none")
+	header.Add("typ", "token-introspection+jwt")
+	assert.Equal(t, map[string]interface{}{
+		"typ": "token-introspection+jwt",
+	}, header.ToMap())
+}
@@ -28,8 +28,10 @@ import (
 	"context"
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rsa"
 	"crypto/sha256"
+	_ "crypto/sha512"
 	"strings"
 
 	"github.com/ory/x/errorsx"
@@ -47,20 +49,43 @@ type JWTStrategy interface {
 	GetSigningMethodLength() int
 }
 
-var SHA256HashSize = crypto.SHA256.Size()
+var (
+	SHA256HashSize = crypto.SHA256.Size()
+	SHA384HashSize = crypto.SHA384.Size()
+	SHA512HashSize = crypto.SHA512.Size()
+)
 
 // RS256JWTStrategy is responsible for generating and validating JWT challenges
 type RS256JWTStrategy struct {
 	PrivateKey interface{}
+
+	// KeyProvider, if set, takes precedence over PrivateKey. Tokens are signed with the provider's current
+	// key and carry its "kid" header, and any key in the provider's published key set is accepted during
+	// validation.
+	KeyProvider KeyProvider
 }
 
 // Generate generates a new authorize code or returns an error. set secret
 func (j *RS256JWTStrategy) Generate(ctx context.Context, claims MapClaims, header Mapper) (string, string, error) {
-	return generateToken(claims, header, jose.RS256, j.PrivateKey)
+	if j.KeyProvider != nil {
+		key, err := j.KeyProvider.GetSigningKey(ctx)
+		if err != nil {
+			return "", "", errorsx.WithStack(err)
+		}
+		return generateToken(claims, header, jose.RS256, key.Key, key.KeyID)
+	}
+	return generateToken(claims, header, jose.RS256, j.PrivateKey, "")
 }
 
 // Validate validates a token and returns its signature or an error if the token is not valid.
 func (j *RS256JWTStrategy) Validate(ctx context.Context, token string) (string, error) {
+	if j.KeyProvider != nil {
+		if _, err := ParseWithClaims(token, MapClaims{}, keyProviderKeyfunc(ctx, j.KeyProvider)); err != nil {
+			return "", err
+		}
+		return getTokenSignature(token)
+	}
+
 	switch t := j.PrivateKey.(type) {
 	case *rsa.PrivateKey:
 		return validateToken(token, t.PublicKey)
@@ -73,6 +98,10 @@ func (j *RS256JWTStrategy) Validate(ctx context.Context, token string) (string,
 
 // Decode will decode a JWT token
 func (j *RS256JWTStrategy) Decode(ctx context.Context, token string) (*Token, error) {
+	if j.KeyProvider != nil {
+		return ParseWithClaims(token, MapClaims{}, keyProviderKeyfunc(ctx, j.KeyProvider))
+	}
+
 	switch t := j.PrivateKey.(type) {
 	case *rsa.PrivateKey:
 		return decodeToken(token, t.PublicKey)
@@ -105,7 +134,7 @@ type ES256JWTStrategy struct {
 
 // Generate generates a new authorize code or returns an error. set secret
 func (j *ES256JWTStrategy) Generate(ctx context.Context, claims MapClaims, header Mapper) (string, string, error) {
-	return generateToken(claims, header, jose.ES256, j.PrivateKey)
+	return generateToken(claims, header, jose.ES256, j.PrivateKey, "")
 }
 
 // Validate validates a token and returns its signature or an error if the token is not valid.
@@ -147,13 +176,180 @@ func (j *ES256JWTStrategy) GetSigningMethodLength() int {
 	return SHA256HashSize
 }
 
-func generateToken(claims MapClaims, header Mapper, signingMethod jose.SignatureAlgorithm, privateKey interface{}) (rawToken string, sig string, err error) {
+// ES384JWTStrategy is responsible for generating and validating JWT challenges
+type ES384JWTStrategy struct {
+	PrivateKey interface{}
+}
+
+// Generate generates a new authorize code or returns an error. set secret
+func (j *ES384JWTStrategy) Generate(ctx context.Context, claims MapClaims, header Mapper) (string, string, error) {
+	return generateToken(claims, header, jose.ES384, j.PrivateKey, "")
+}
+
+// Validate validates a token and returns its signature or an error if the token is not valid.
+func (j *ES384JWTStrategy) Validate(ctx context.Context, token string) (string, error) {
+	key, err := verificationKey(j.PrivateKey)
+	if err != nil {
+		return "", errors.New("Unable to validate token. Invalid PrivateKey type")
+	}
+	return validateToken(token, key)
+}
+
+// Decode will decode a JWT token
+func (j *ES384JWTStrategy) Decode(ctx context.Context, token string) (*Token, error) {
+	key, err := verificationKey(j.PrivateKey)
+	if err != nil {
+		return nil, errors.New("Unable to decode token. Invalid PrivateKey type")
+	}
+	return decodeToken(token, key)
+}
+
+// GetSignature will return the signature of a token
+func (j *ES384JWTStrategy) GetSignature(ctx context.Context, token string) (string, error) {
+	return getTokenSignature(token)
+}
+
+// Hash will return a given hash based on the byte input or an error upon fail
+func (j *ES384JWTStrategy) Hash(ctx context.Context, in []byte) ([]byte, error) {
+	return hashWith(crypto.SHA384, in)
+}
+
+// GetSigningMethodLength will return the length of the signing method
+func (j *ES384JWTStrategy) GetSigningMethodLength() int {
+	return SHA384HashSize
+}
+
+// ES512JWTStrategy is responsible for generating and validating JWT challenges
+type ES512JWTStrategy struct {
+	PrivateKey interface{}
+}
+
+// Generate generates a new authorize code or returns an error. set secret
+func (j *ES512JWTStrategy) Generate(ctx context.Context, claims MapClaims, header Mapper) (string, string, error) {
+	return generateToken(claims, header, jose.ES512, j.PrivateKey, "")
+}
+
+// Validate validates a token and returns its signature or an error if the token is not valid.
+func (j *ES512JWTStrategy) Validate(ctx context.Context, token string) (string, error) {
+	key, err := verificationKey(j.PrivateKey)
+	if err != nil {
+		return "", errors.New("Unable to validate token. Invalid PrivateKey type")
+	}
+	return validateToken(token, key)
+}
+
+// Decode will decode a JWT token
+func (j *ES512JWTStrategy) Decode(ctx context.Context, token string) (*Token, error) {
+	key, err := verificationKey(j.PrivateKey)
+	if err != nil {
+		return nil, errors.New("Unable to decode token. Invalid PrivateKey type")
+	}
+	return decodeToken(token, key)
+}
+
+// GetSignature will return the signature of a token
+func (j *ES512JWTStrategy) GetSignature(ctx context.Context, token string) (string, error) {
+	return getTokenSignature(token)
+}
+
+// Hash will return a given hash based on the byte input or an error upon fail
+func (j *ES512JWTStrategy) Hash(ctx context.Context, in []byte) ([]byte, error) {
+	return hashWith(crypto.SHA512, in)
+}
+
+// GetSigningMethodLength will return the length of the signing method
+func (j *ES512JWTStrategy) GetSigningMethodLength() int {
+	return SHA512HashSize
+}
+
+// EdDSAJWTStrategy is responsible for generating and validating JWT challenges
+type EdDSAJWTStrategy struct {
+	PrivateKey interface{}
+}
+
+// Generate generates a new authorize code or returns an error. set secret
+func (j *EdDSAJWTStrategy) Generate(ctx context.Context, claims MapClaims, header Mapper) (string, string, error) {
+	return generateToken(claims, header, jose.EdDSA, j.PrivateKey, "")
+}
+
+// Validate validates a token and returns its signature or an error if the token is not valid.
+func (j *EdDSAJWTStrategy) Validate(ctx context.Context, token string) (string, error) {
+	key, err := verificationKey(j.PrivateKey)
+	if err != nil {
+		return "", errors.New("Unable to validate token. Invalid PrivateKey type")
+	}
+	return validateToken(token, key)
+}
+
+// Decode will decode a JWT token
+func (j *EdDSAJWTStrategy) Decode(ctx context.Context, token string) (*Token, error) {
+	key, err := verificationKey(j.PrivateKey)
+	if err != nil {
+		return nil, errors.New("Unable to decode token. Invalid PrivateKey type")
+	}
+	return decodeToken(token, key)
+}
+
+// GetSignature will return the signature of a token
+func (j *EdDSAJWTStrategy) GetSignature(ctx context.Context, token string) (string, error) {
+	return getTokenSignature(token)
+}
+
+// Hash will return a given hash based on the byte input or an error upon fail
+func (j *EdDSAJWTStrategy) Hash(ctx context.Context, in []byte) ([]byte, error) {
+	return hashWith(crypto.SHA512, in)
+}
+
+// GetSigningMethodLength will return the length of the signing method
+func (j *EdDSAJWTStrategy) GetSigningMethodLength() int {
+	return SHA512HashSize
+}
+
+// NewJWTStrategy returns the JWTStrategy for the given JWS algorithm signing with privateKey. Supported algorithms
+// are RS256, ES256, ES384, ES512 and EdDSA.
+func NewJWTStrategy(alg string, privateKey interface{}) (JWTStrategy, error) {
+	switch jose.SignatureAlgorithm(alg) {
+	case jose.RS256:
+		return &RS256JWTStrategy{PrivateKey: privateKey}, nil
+	case jose.ES256:
+		return &ES256JWTStrategy{PrivateKey: privateKey}, nil
+	case jose.ES384:
+		return &ES384JWTStrategy{PrivateKey: privateKey}, nil
+	case jose.ES512:
+		return &ES512JWTStrategy{PrivateKey: privateKey}, nil
+	case jose.EdDSA:
+		return &EdDSAJWTStrategy{PrivateKey: privateKey}, nil
+	default:
+		return nil, errors.Errorf("Signing algorithm \"%s\" is not supported", alg)
+	}
+}
+
+// verificationKey returns the public key belonging to privateKey.
+func verificationKey(privateKey interface{}) (interface{}, error) {
+	switch t := privateKey.(type) {
+	case *rsa.PrivateKey:
+		return t.PublicKey, nil
+	case *ecdsa.PrivateKey:
+		return t.PublicKey, nil
+	case ed25519.PrivateKey:
+		return &jose.JSONWebKey{Key: t.Public()}, nil
+	case jose.OpaqueSigner:
+		return t.Public(), nil
+	default:
+		return nil, errors.Errorf("Unsupported private key type %T", privateKey)
+	}
+}
+
+func generateToken(claims MapClaims, header Mapper, signingMethod jose.SignatureAlgorithm, privateKey interface{}, keyID string) (rawToken string, sig string, err error) {
 	if header == nil || claims == nil {
 		err = errors.New("Either claims or header is nil.")
 		return
 	}
 
 	token := NewWithClaims(signingMethod, claims)
+	if keyID != "" {
+		token.Header["kid"] = keyID
+	}
 	token.Header = assign(token.Header, header.ToMap())
 
 	rawToken, err = token.SignedString(privateKey)
@@ -195,6 +391,15 @@ func hashSHA256(in []byte) ([]byte, error) {
 	return hash.Sum([]byte{}), nil
 }
 
+func hashWith(h crypto.Hash, in []byte) ([]byte, error) {
+	hash := h.New()
+	_, err := hash.Write(in)
+	if err != nil {
+		return []byte{}, errorsx.WithStack(err)
+	}
+	return hash.Sum([]byte{}), nil
+}
+
 func assign(a, b map[string]interface{}) map[string]interface{} {
 	for k, w := range b {
 		if _, ok := a[k]; ok {
@@ -23,6 +23,7 @@ package jwt
 
 import (
 	"context"
+	"crypto/elliptic"
 	"fmt"
 	"strings"
 	"testing"
@@ -116,6 +117,33 @@ func TestGenerateJWT(t *testing.T) {
 				strategy.(*ES256JWTStrategy).PrivateKey = MustECDSAKey()
 			},
 		},
+		{
+			d: "ES384JWTStrategy",
+			strategy: &ES384JWTStrategy{
+				PrivateKey: mustECDSAKey(elliptic.P384()),
+			},
+			resetKey: func(strategy JWTStrategy) {
+				strategy.(*ES384JWTStrategy).PrivateKey = mustECDSAKey(elliptic.P384())
+			},
+		},
+		{
+			d: "ES512JWTStrategy",
+			strategy: &ES512JWTStrategy{
+				PrivateKey: mustECDSAKey(elliptic.P521()),
+			},
+			resetKey: func(strategy JWTStrategy) {
+				strategy.(*ES512JWTStrategy).PrivateKey = mustECDSAKey(elliptic.P521())
+			},
+		},
+		{
+			d: "EdDSAJWTStrategy",
+			strategy: &EdDSAJWTStrategy{
+				PrivateKey: mustEd25519Key(),
+			},
+			resetKey: func(strategy JWTStrategy) {
+				strategy.(*EdDSAJWTStrategy).PrivateKey = mustEd25519Key()
+			},
+		},
 	} {
 		t.Run(fmt.Sprintf("case=%d/strategy=%s", k, tc.d), func(t *testing.T) {
 			claims := &JWTClaims{
@@ -199,3 +227,31 @@ func TestValidateSignatureRejectsJWT(t *testing.T) {
 		})
 	}
 }
+
+func TestNewJWTStrategy(t *testing.T) {
+	for _, tc := range []struct {
+		alg string
+		key interface{}
+	}{
+		{alg: "RS256", key: MustRSAKey()},
+		{alg: "ES256", key: MustECDSAKey()},
+		{alg: "ES384", key: mustECDSAKey(elliptic.P384())},
+		{alg: "ES512", key: mustECDSAKey(elliptic.P521())},
+		{alg: "EdDSA", key: mustEd25519Key()},
+	} {
+		t.Run("alg="+tc.alg, func(t *testing.T) {
+			strategy, err := NewJWTStrategy(tc.alg, tc.key)
+			require.NoError(t, err)
+
+			token, _, err := strategy.Generate(context.TODO(), MapClaims{"foo": "bar"}, header)
+			require.NoError(t, err)
+
+			decoded, err := strategy.Decode(context.TODO(), token)
+			require.NoError(t, err)
+			assert.EqualValues(t, tc.alg, decoded.Header["alg"])
+		})
+	}
+
+	_, err := NewJWTStrategy("HS256", []byte("secret"))
+	require.Error(t, err)
+}
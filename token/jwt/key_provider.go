@@ -0,0 +1,147 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package jwt
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+	"gopkg.in/square/go-jose.v2"
+)
+
+// KeyProvider supplies the keys used by a JWTStrategy. It allows signing keys to be rotated at runtime
+// without re-creating the strategy.
+type KeyProvider interface {
+	// GetSigningKey returns the key which is currently used for signing tokens. The key's KeyID is
+	// emitted as the "kid" header of every token signed with it.
+	GetSigningKey(ctx context.Context) (*jose.JSONWebKey, error)
+
+	// GetPublicKeys returns the key set which is published as JWKS. Tokens signed with any of these
+	// keys are accepted during validation.
+	GetPublicKeys(ctx context.Context) (*jose.JSONWebKeySet, error)
+}
+
+// RotatingKeyProvider is a thread safe, in-memory KeyProvider. Keys replaced using Rotate remain published
+// so that tokens signed with them stay valid until they expire.
+type RotatingKeyProvider struct {
+	sync.RWMutex
+
+	current   *jose.JSONWebKey
+	published []jose.JSONWebKey
+}
+
+// NewRotatingKeyProvider returns a RotatingKeyProvider which signs with the given key.
+func NewRotatingKeyProvider(key *jose.JSONWebKey) *RotatingKeyProvider {
+	p := new(RotatingKeyProvider)
+	p.Rotate(key)
+	return p
+}
+
+// Rotate makes key the current signing key and adds its public part to the published key set.
+func (p *RotatingKeyProvider) Rotate(key *jose.JSONWebKey) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.current = key
+	p.published = append([]jose.JSONWebKey{publicJSONWebKey(key)}, p.published...)
+}
+
+// Retire removes the key identified by kid from the published key set. Tokens signed with it are no longer
+// accepted. The current signing key can not be retired.
+func (p *RotatingKeyProvider) Retire(kid string) {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.current != nil && p.current.KeyID == kid {
+		return
+	}
+
+	published := make([]jose.JSONWebKey, 0, len(p.published))
+	for _, k := range p.published {
+		if k.KeyID != kid {
+			published = append(published, k)
+		}
+	}
+	p.published = published
+}
+
+// GetSigningKey returns the current signing key.
+func (p *RotatingKeyProvider) GetSigningKey(_ context.Context) (*jose.JSONWebKey, error) {
+	p.RLock()
+	defer p.RUnlock()
+
+	if p.current == nil {
+		return nil, errors.New("No signing key has been configured")
+	}
+	return p.current, nil
+}
+
+// GetPublicKeys returns the public parts of the current and all previously rotated keys.
+func (p *RotatingKeyProvider) GetPublicKeys(_ context.Context) (*jose.JSONWebKeySet, error) {
+	p.RLock()
+	defer p.RUnlock()
+
+	keys := make([]jose.JSONWebKey, len(p.published))
+	copy(keys, p.published)
+	return &jose.JSONWebKeySet{Keys: keys}, nil
+}
+
+func publicJSONWebKey(key *jose.JSONWebKey) jose.JSONWebKey {
+	if key.IsPublic() {
+		return *key
+	}
+	if signer, ok := key.Key.(jose.OpaqueSigner); ok {
+		pub := *signer.Public()
+		if pub.KeyID == "" {
+			pub.KeyID = key.KeyID
+		}
+		return pub
+	}
+	return key.Public()
+}
+
+// keyProviderKeyfunc returns a Keyfunc which resolves the verification key from the published key set
+// using the token's "kid" header. Tokens without a "kid" are verified using the current signing key.
+func keyProviderKeyfunc(ctx context.Context, provider KeyProvider) Keyfunc {
+	return func(t *Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		if kid == "" {
+			key, err := provider.GetSigningKey(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return publicJSONWebKey(key).Key, nil
+		}
+
+		set, err := provider.GetPublicKeys(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		keys := set.Key(kid)
+		if len(keys) == 0 {
+			return nil, errors.Errorf("Unable to find a published key with kid \"%s\"", kid)
+		}
+		return publicJSONWebKey(&keys[0]).Key, nil
+	}
+}
@@ -0,0 +1,85 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package jwt
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+)
+
+func TestRS256JWTStrategyWithKeyProvider(t *testing.T) {
+	ctx := context.Background()
+	claims := &JWTClaims{ExpiresAt: time.Now().UTC().Add(time.Hour)}
+
+	provider := NewRotatingKeyProvider(&jose.JSONWebKey{Key: MustRSAKey(), KeyID: "key-1", Algorithm: "RS256", Use: "sig"})
+	strategy := &RS256JWTStrategy{KeyProvider: provider}
+
+	first, _, err := strategy.Generate(ctx, claims.ToMapClaims(), header)
+	require.NoError(t, err)
+
+	decoded, err := strategy.Decode(ctx, first)
+	require.NoError(t, err)
+	assert.Equal(t, "key-1", decoded.Header["kid"])
+	assert.Equal(t, "bar", decoded.Header["foo"])
+
+	provider.Rotate(&jose.JSONWebKey{Key: MustRSAKey(), KeyID: "key-2", Algorithm: "RS256", Use: "sig"})
+
+	second, _, err := strategy.Generate(ctx, claims.ToMapClaims(), header)
+	require.NoError(t, err)
+
+	decoded, err = strategy.Decode(ctx, second)
+	require.NoError(t, err)
+	assert.Equal(t, "key-2", decoded.Header["kid"])
+
+	// Tokens signed with a previously published key remain valid.
+	_, err = strategy.Validate(ctx, first)
+	require.NoError(t, err)
+	_, err = strategy.Validate(ctx, second)
+	require.NoError(t, err)
+
+	keys, err := provider.GetPublicKeys(ctx)
+	require.NoError(t, err)
+	require.Len(t, keys.Keys, 2)
+	for _, k := range keys.Keys {
+		assert.True(t, k.IsPublic())
+	}
+
+	// The current key can not be retired, previous ones can.
+	provider.Retire("key-2")
+	provider.Retire("key-1")
+
+	_, err = strategy.Validate(ctx, first)
+	require.Error(t, err)
+	_, err = strategy.Validate(ctx, second)
+	require.NoError(t, err)
+
+	// Tokens without kid are verified using the current signing key only.
+	unversioned, _, err := (&RS256JWTStrategy{PrivateKey: MustRSAKey()}).Generate(ctx, claims.ToMapClaims(), header)
+	require.NoError(t, err)
+	_, err = strategy.Validate(ctx, unversioned)
+	require.Error(t, err)
+}
@@ -0,0 +1,152 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package jwt
+
+import (
+	"context"
+	"crypto"
+
+	"github.com/ory/x/errorsx"
+	"github.com/pkg/errors"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/cryptosigner"
+)
+
+// Signer signs JSON Web Tokens without exposing the private key to the process, for example using AWS KMS,
+// GCP KMS or a PKCS#11 hardware security module.
+type Signer interface {
+	// Sign returns the compact serialization of a JSON Web Token with the given headers and claims. The token
+	// must be signed using Algorithm and carry KeyID as its "kid" header.
+	Sign(ctx context.Context, headers Mapper, claims MapClaims) (string, error)
+
+	// KeyID returns the identifier of the signing key or an empty string.
+	KeyID() string
+
+	// Algorithm returns the JWS algorithm used for signing.
+	Algorithm() jose.SignatureAlgorithm
+
+	// Public returns the public key used to verify tokens signed by this signer.
+	Public() crypto.PublicKey
+}
+
+// NewCryptoSigner returns a Signer signing with the given crypto.Signer, which is the interface implemented by
+// most KMS and PKCS#11 client libraries. The signer must hold an RSA, ECDSA or Ed25519 key matching alg.
+func NewCryptoSigner(signer crypto.Signer, alg jose.SignatureAlgorithm, keyID string) Signer {
+	return &cryptoSigner{signer: signer, opaque: cryptosigner.Opaque(signer), alg: alg, keyID: keyID}
+}
+
+type cryptoSigner struct {
+	signer crypto.Signer
+	opaque jose.OpaqueSigner
+	alg    jose.SignatureAlgorithm
+	keyID  string
+}
+
+func (s *cryptoSigner) Sign(ctx context.Context, headers Mapper, claims MapClaims) (string, error) {
+	token, _, err := generateToken(claims, headers, s.alg, s.opaque, s.keyID)
+	return token, err
+}
+
+func (s *cryptoSigner) KeyID() string {
+	return s.keyID
+}
+
+func (s *cryptoSigner) Algorithm() jose.SignatureAlgorithm {
+	return s.alg
+}
+
+func (s *cryptoSigner) Public() crypto.PublicKey {
+	return s.signer.Public()
+}
+
+// SignerJWTStrategy is a JWTStrategy which delegates signing to a Signer. Tokens are validated using the
+// signer's public key.
+type SignerJWTStrategy struct {
+	Signer Signer
+}
+
+// Generate generates a new token signed by the Signer.
+func (j *SignerJWTStrategy) Generate(ctx context.Context, claims MapClaims, header Mapper) (string, string, error) {
+	if header == nil || claims == nil {
+		return "", "", errors.New("Either claims or header is nil.")
+	}
+
+	rawToken, err := j.Signer.Sign(ctx, header, claims)
+	if err != nil {
+		return "", "", errorsx.WithStack(err)
+	}
+
+	sig, err := getTokenSignature(rawToken)
+	if err != nil {
+		return "", "", err
+	}
+	return rawToken, sig, nil
+}
+
+// Validate validates a token and returns its signature or an error if the token is not valid.
+func (j *SignerJWTStrategy) Validate(ctx context.Context, token string) (string, error) {
+	if _, err := j.Decode(ctx, token); err != nil {
+		return "", err
+	}
+	return getTokenSignature(token)
+}
+
+// Decode will decode a JWT token
+func (j *SignerJWTStrategy) Decode(ctx context.Context, token string) (*Token, error) {
+	t, err := decodeToken(token, &jose.JSONWebKey{Key: j.Signer.Public()})
+	if err != nil {
+		return t, err
+	}
+
+	if t.Method != j.Signer.Algorithm() {
+		return t, &ValidationError{Errors: ValidationErrorSignatureInvalid, text: "token was signed using an unexpected algorithm"}
+	}
+	return t, nil
+}
+
+// GetSignature will return the signature of a token
+func (j *SignerJWTStrategy) GetSignature(ctx context.Context, token string) (string, error) {
+	return getTokenSignature(token)
+}
+
+// Hash will return a given hash based on the byte input or an error upon fail
+func (j *SignerJWTStrategy) Hash(ctx context.Context, in []byte) ([]byte, error) {
+	return hashWith(signingMethodHash(j.Signer.Algorithm()), in)
+}
+
+// GetSigningMethodLength will return the length of the signing method
+func (j *SignerJWTStrategy) GetSigningMethodLength() int {
+	return signingMethodHash(j.Signer.Algorithm()).Size()
+}
+
+// signingMethodHash returns the hash function used by the JWS algorithm, which is also used to compute
+// at_hash and c_hash values.
+func signingMethodHash(alg jose.SignatureAlgorithm) crypto.Hash {
+	switch alg {
+	case jose.RS384, jose.PS384, jose.ES384:
+		return crypto.SHA384
+	case jose.RS512, jose.PS512, jose.ES512, jose.EdDSA:
+		return crypto.SHA512
+	default:
+		return crypto.SHA256
+	}
+}
@@ -0,0 +1,113 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/elliptic"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+)
+
+// hsmSigner only exposes the crypto.Signer interface of a key, like a KMS or PKCS#11 client library would.
+type hsmSigner struct {
+	key   crypto.Signer
+	calls int
+}
+
+func (s *hsmSigner) Public() crypto.PublicKey {
+	return s.key.Public()
+}
+
+func (s *hsmSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	s.calls++
+	return s.key.Sign(rand, digest, opts)
+}
+
+func TestSignerJWTStrategy(t *testing.T) {
+	for _, tc := range []struct {
+		alg    jose.SignatureAlgorithm
+		key    crypto.Signer
+		length int
+	}{
+		{alg: jose.RS256, key: MustRSAKey(), length: SHA256HashSize},
+		{alg: jose.PS384, key: MustRSAKey(), length: SHA384HashSize},
+		{alg: jose.ES256, key: MustECDSAKey(), length: SHA256HashSize},
+		{alg: jose.ES512, key: mustECDSAKey(elliptic.P521()), length: SHA512HashSize},
+		{alg: jose.EdDSA, key: mustEd25519Key(), length: SHA512HashSize},
+	} {
+		t.Run("alg="+string(tc.alg), func(t *testing.T) {
+			hsm := &hsmSigner{key: tc.key}
+			strategy := &SignerJWTStrategy{Signer: NewCryptoSigner(hsm, tc.alg, "hsm-key")}
+
+			token, sig, err := strategy.Generate(context.TODO(), MapClaims{"foo": "bar"}, header)
+			require.NoError(t, err)
+			assert.Equal(t, 1, hsm.calls)
+			assert.NotEmpty(t, sig)
+
+			validated, err := strategy.Validate(context.TODO(), token)
+			require.NoError(t, err)
+			assert.Equal(t, sig, validated)
+
+			decoded, err := strategy.Decode(context.TODO(), token)
+			require.NoError(t, err)
+			assert.EqualValues(t, tc.alg, decoded.Header["alg"])
+			assert.Equal(t, "hsm-key", decoded.Header["kid"])
+			assert.Equal(t, "bar", decoded.Header["foo"])
+			assert.Equal(t, "bar", decoded.Claims["foo"])
+
+			assert.Equal(t, tc.length, strategy.GetSigningMethodLength())
+			hash, err := strategy.Hash(context.TODO(), []byte("foo"))
+			require.NoError(t, err)
+			assert.Len(t, hash, tc.length)
+		})
+	}
+
+	t.Run("case=tokens of other keys or algorithms are rejected", func(t *testing.T) {
+		key := MustRSAKey()
+		strategy := &SignerJWTStrategy{Signer: NewCryptoSigner(key, jose.RS256, "")}
+
+		other, _, err := (&RS256JWTStrategy{PrivateKey: MustRSAKey()}).Generate(context.TODO(), MapClaims{"foo": "bar"}, header)
+		require.NoError(t, err)
+		_, err = strategy.Validate(context.TODO(), other)
+		assert.Error(t, err)
+
+		ps256, _, err := (&SignerJWTStrategy{Signer: NewCryptoSigner(key, jose.PS256, "")}).Generate(context.TODO(), MapClaims{"foo": "bar"}, header)
+		require.NoError(t, err)
+		_, err = strategy.Validate(context.TODO(), ps256)
+		assert.Error(t, err)
+	})
+
+	t.Run("case=tokens are compatible with the private key strategies", func(t *testing.T) {
+		key := MustECDSAKey()
+		token, _, err := (&SignerJWTStrategy{Signer: NewCryptoSigner(&hsmSigner{key: key}, jose.ES256, "")}).Generate(context.TODO(), MapClaims{"foo": "bar"}, header)
+		require.NoError(t, err)
+
+		_, err = (&ES256JWTStrategy{PrivateKey: key}).Validate(context.TODO(), token)
+		assert.NoError(t, err)
+	})
+}
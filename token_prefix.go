@@ -0,0 +1,72 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"strings"
+
+	"github.com/ory/x/errorsx"
+)
+
+// TokenPrefixes maps token types to the prefix of tokens of that type. Prefixing tokens allows leaked credential
+// scanners, such as GitHub secret scanning, to identify them.
+type TokenPrefixes map[TokenType]string
+
+// DefaultTokenPrefixes are the prefixes used by ORY for access tokens, refresh tokens and authorize codes.
+var DefaultTokenPrefixes = TokenPrefixes{
+	AccessToken:   "ory_at_",
+	RefreshToken:  "ory_rt_",
+	AuthorizeCode: "ory_ac_",
+}
+
+// TokenType returns the type of the token identified by its prefix. If prefixes overlap, the longest matching
+// prefix wins.
+func (p TokenPrefixes) TokenType(token string) (TokenType, bool) {
+	var tokenType TokenType
+	var matched string
+	for t, prefix := range p {
+		if prefix != "" && len(prefix) > len(matched) && strings.HasPrefix(token, prefix) {
+			tokenType, matched = t, prefix
+		}
+	}
+	return tokenType, matched != ""
+}
+
+// Trim removes the prefix of the token type from the token. Tokens without prefix are returned as is, so that
+// tokens issued before prefixes were configured remain valid. Tokens prefixed with the prefix of another token type
+// are rejected.
+func (p TokenPrefixes) Trim(tokenType TokenType, token string) (string, error) {
+	actual, ok := p.TokenType(token)
+	if !ok {
+		return token, nil
+	} else if actual != tokenType {
+		return "", errorsx.WithStack(ErrInvalidTokenFormat.WithHintf("Expected a token of type '%s' but got a token of type '%s'.", tokenType, actual))
+	}
+	return strings.TrimPrefix(token, p[tokenType]), nil
+}
+
+// TokenTypeFromString returns the type of the token identified by its DefaultTokenPrefixes prefix, or an empty
+// TokenType if the token is not prefixed.
+func TokenTypeFromString(token string) TokenType {
+	tokenType, _ := DefaultTokenPrefixes.TokenType(token)
+	return tokenType
+}
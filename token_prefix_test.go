@@ -0,0 +1,69 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/fosite"
+)
+
+func TestTokenTypeFromString(t *testing.T) {
+	for k, c := range []struct {
+		token  string
+		expect TokenType
+	}{
+		{token: "ory_at_foo.bar", expect: AccessToken},
+		{token: "ory_rt_foo.bar", expect: RefreshToken},
+		{token: "ory_ac_foo.bar", expect: AuthorizeCode},
+		{token: "foo.bar", expect: ""},
+		{token: "ory_xx_foo.bar", expect: ""},
+	} {
+		t.Run(fmt.Sprintf("case=%d/token=%s", k, c.token), func(t *testing.T) {
+			assert.Equal(t, c.expect, TokenTypeFromString(c.token))
+		})
+	}
+}
+
+func TestTokenPrefixes(t *testing.T) {
+	prefixes := TokenPrefixes{AccessToken: "at_", RefreshToken: "at_rt_"}
+
+	tokenType, ok := prefixes.TokenType("at_rt_foo")
+	assert.True(t, ok)
+	assert.Equal(t, RefreshToken, tokenType, "the longest prefix wins")
+
+	token, err := prefixes.Trim(AccessToken, "at_foo")
+	require.NoError(t, err)
+	assert.Equal(t, "foo", token)
+
+	token, err = prefixes.Trim(AccessToken, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, "foo", token)
+
+	_, err = prefixes.Trim(AccessToken, "at_rt_foo")
+	assert.True(t, errors.Is(err, ErrInvalidTokenFormat))
+}
@@ -0,0 +1,32 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import "context"
+
+// TokenQuotaPolicy is consulted by NewAccessResponse before tokens are issued, to limit the number of grants a
+// subject or client may accumulate, for example because of credential stuffing. A policy rejects the request by
+// returning an error, or makes room by revoking older grants. See oauth2.DefaultTokenQuotaPolicy.
+type TokenQuotaPolicy interface {
+	// EnforceTokenQuota is called with the validated access request before the tokens are issued.
+	EnforceTokenQuota(ctx context.Context, requester AccessRequester) error
+}
@@ -0,0 +1,122 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the name of the tracer used for instrumenting fosite.
+const TracerName = "github.com/ory/fosite"
+
+// Attributes set on the spans created by fosite.
+const (
+	AttributeGrantType    = attribute.Key("fosite.grant_type")
+	AttributeResponseType = attribute.Key("fosite.response_type")
+	AttributeClientID     = attribute.Key("fosite.client_id")
+	AttributeHandler      = attribute.Key("fosite.handler")
+	AttributeActive       = attribute.Key("fosite.active")
+	AttributeRequestID    = attribute.Key("fosite.request_id")
+)
+
+var noopTracer = trace.NewNoopTracerProvider().Tracer(TracerName)
+
+// GetTracer returns Tracer if set. Defaults to a tracer which does not record spans.
+func (f *Fosite) GetTracer() trace.Tracer {
+	if f.Tracer == nil {
+		return noopTracer
+	}
+	return f.Tracer
+}
+
+// startSpan starts a span using the tracer of the provider.
+func (f *Fosite) startSpan(ctx context.Context, name string, opts ...trace.SpanOption) (context.Context, trace.Span) {
+	if ctx == nil {
+		// Not all callers pass a context.
+		ctx = context.Background()
+	}
+	if id, _ := ctx.Value(RequestIDContextKey).(string); id != "" {
+		opts = append(opts, trace.WithAttributes(AttributeRequestID.String(id)))
+	}
+	return f.GetTracer().Start(ctx, name, opts...)
+}
+
+// endSpan adds the attributes of the request to span, records err, if any, and ends the span.
+func endSpan(span trace.Span, requester Requester, err error) {
+	if requester != nil && requester.GetClient() != nil {
+		span.SetAttributes(AttributeClientID.String(requester.GetClient().GetID()))
+	}
+	if ar, ok := requester.(AccessRequester); ok && len(ar.GetGrantTypes()) > 0 {
+		span.SetAttributes(AttributeGrantType.String(strings.Join(ar.GetGrantTypes(), " ")))
+	}
+	if ar, ok := requester.(AuthorizeRequester); ok && len(ar.GetResponseTypes()) > 0 {
+		span.SetAttributes(AttributeResponseType.String(strings.Join(ar.GetResponseTypes(), " ")))
+	}
+	recordSpanError(span, err)
+	span.End()
+}
+
+func recordSpanError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, ErrorToRFC6749Error(err).ErrorField)
+}
+
+// traceHandler calls fn in a span carrying the type of the handler. ErrUnknownRequest is not recorded as an error.
+func (f *Fosite) traceHandler(ctx context.Context, name string, handler interface{}, fn func(ctx context.Context) error) error {
+	ctx, span := f.startSpan(ctx, name, trace.WithAttributes(AttributeHandler.String(fmt.Sprintf("%T", handler))))
+	defer span.End()
+
+	err := fn(ctx)
+	if !errors.Is(err, ErrUnknownRequest) {
+		recordSpanError(span, err)
+	}
+	return err
+}
+
+// getClient loads the client from the store in a span and reports the storage latency to the MetricsReporter.
+func (f *Fosite) getClient(ctx context.Context, id string) (Client, error) {
+	ctx, span := f.startSpan(ctx, "fosite.Storage.GetClient")
+	defer span.End()
+
+	start := time.Now()
+	var client Client
+	err := StorageOperation(ctx, "GetClient", func(ctx context.Context) (err error) {
+		client, err = f.Store.GetClient(ctx, id)
+		return err
+	})
+	f.GetMetricsReporter().StorageOperationCompleted(ctx, "GetClient", time.Since(start), err)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		recordSpanError(span, err)
+	}
+	return client, err
+}
@@ -0,0 +1,105 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/oteltest"
+
+	. "github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/storage"
+)
+
+func TestTracing(t *testing.T) {
+	recorder := new(oteltest.SpanRecorder)
+	config := &compose.Config{TracerProvider: oteltest.NewTracerProvider(oteltest.WithSpanRecorder(recorder))}
+	f := compose.Compose(
+		config,
+		storage.NewExampleStore(),
+		compose.NewOAuth2HMACStrategy(config, []byte("some-secret-thats-random-some-secret-thats-random-"), nil),
+		nil,
+		compose.OAuth2ClientCredentialsGrantFactory,
+	)
+
+	newRequest := func(secret string) *http.Request {
+		r := httptest.NewRequest("POST", "/token", strings.NewReader(url.Values{"grant_type": {"client_credentials"}}.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.SetBasicAuth("my-client", secret)
+		return r
+	}
+
+	spans := func() map[string]*oteltest.Span {
+		result := map[string]*oteltest.Span{}
+		for _, span := range recorder.Completed() {
+			result[span.Name()] = span
+		}
+		return result
+	}
+
+	t.Run("case=successful token request", func(t *testing.T) {
+		ar, err := f.NewAccessRequest(context.Background(), newRequest("foobar"), &DefaultSession{})
+		require.NoError(t, err)
+		_, err = f.NewAccessResponse(context.Background(), ar)
+		require.NoError(t, err)
+
+		completed := spans()
+		for _, name := range []string{
+			"fosite.NewAccessRequest",
+			"fosite.NewAccessResponse",
+			"fosite.Storage.GetClient",
+			"fosite.TokenEndpointHandler.HandleTokenEndpointRequest",
+			"fosite.TokenEndpointHandler.PopulateTokenEndpointResponse",
+		} {
+			require.Contains(t, completed, name)
+		}
+
+		span := completed["fosite.NewAccessRequest"]
+		assert.Equal(t, "client_credentials", span.Attributes()[AttributeGrantType].AsString())
+		assert.Equal(t, "my-client", span.Attributes()[AttributeClientID].AsString())
+		assert.Equal(t, codes.Unset, span.StatusCode())
+
+		handler := completed["fosite.TokenEndpointHandler.HandleTokenEndpointRequest"]
+		assert.Equal(t, "*oauth2.ClientCredentialsGrantHandler", handler.Attributes()[AttributeHandler].AsString())
+		assert.Equal(t, span.SpanContext().SpanID(), handler.ParentSpanID())
+	})
+
+	t.Run("case=failed token request", func(t *testing.T) {
+		_, err := f.NewAccessRequest(context.Background(), newRequest("invalid"), &DefaultSession{})
+		require.Error(t, err)
+
+		recorded := recorder.Completed()
+		span := recorded[len(recorded)-1]
+		assert.Equal(t, "fosite.NewAccessRequest", span.Name())
+		assert.Equal(t, codes.Error, span.StatusCode())
+		assert.Equal(t, "invalid_client", span.StatusMessage())
+	})
+}